@@ -8,7 +8,6 @@ package addrmgr
 import (
 	"container/list"
 	crand "crypto/rand" // for seeding
-	"encoding/base32"
 	"encoding/binary"
 	"encoding/json"
 	"fmt"
@@ -47,6 +46,17 @@ type AddrManager struct {
 	lamtx          sync.Mutex
 	localAddresses map[string]*localAddress
 	version        int
+
+	// triedCollisions maps the keys of new addresses which could not be
+	// moved to their full tried bucket to the tried address they would
+	// replace, which is tested with a feeler connection first.
+	triedCollisions map[string]*KnownAddress
+
+	// asmap groups IPv4 and IPv6 addresses by autonomous system when set.
+	// It is set before the address manager is started and never changed
+	// afterwards, so it does not need to be protected for concurrent
+	// access.
+	asmap *ASMap
 }
 
 type serializedKnownAddress struct {
@@ -67,10 +77,11 @@ type serializedAddrManager struct {
 	Addresses    []*serializedKnownAddress
 	NewBuckets   [newBucketCount][]string // string is NetAddressKey
 	TriedBuckets [triedBucketCount][]string
+	ASMap        string `json:",omitempty"` // checksum of the asmap
 }
 
 type localAddress struct {
-	na    *wire.NetAddress
+	na    *wire.NetAddressV2
 	score AddressPriority
 }
 
@@ -157,13 +168,31 @@ const (
 	// will share with a call to AddressCache.
 	getAddrPercent = 23
 
+	// triedCollisionSize is the maximum number of new addresses waiting
+	// for the tried address they collide with to be tested.
+	triedCollisionSize = 10
+
+	// triedReplacementWindow is the duration during which a tried address
+	// which connected successfully or was attempted is not replaced by a
+	// colliding address.
+	triedReplacementWindow = 4 * time.Hour
+
+	// collisionTestWindow is the duration after which a colliding address
+	// replaces the tried address it collides with when the latter could
+	// not be tested.
+	collisionTestWindow = 40 * time.Minute
+
+	// feelerTimeout is the duration a tried address is given to connect
+	// after it was attempted before it is considered unreachable.
+	feelerTimeout = time.Minute
+
 	// serialisationVersion is the current version of the on-disk format.
-	serialisationVersion = 2
+	serialisationVersion = 3
 )
 
 // updateAddress is a helper function to either update an address already known
 // to the address manager, or to add the address if not already known.
-func (a *AddrManager) updateAddress(netAddr, srcAddr *wire.NetAddress) {
+func (a *AddrManager) updateAddress(netAddr, srcAddr *wire.NetAddressV2) {
 	// Filter out non-routable addresses. Note that non-routable
 	// also includes invalid and local addresses.
 	if !IsRoutable(netAddr) {
@@ -294,14 +323,14 @@ func (a *AddrManager) pickTried(bucket int) *list.Element {
 	return oldestElem
 }
 
-func (a *AddrManager) getNewBucket(netAddr, srcAddr *wire.NetAddress) int {
+func (a *AddrManager) getNewBucket(netAddr, srcAddr *wire.NetAddressV2) int {
 	// bitcoind:
 	// doublesha256(key + sourcegroup + int64(doublesha256(key + group + sourcegroup))%bucket_per_source_group) % num_new_buckets
 
 	data1 := []byte{}
 	data1 = append(data1, a.key[:]...)
-	data1 = append(data1, []byte(GroupKey(netAddr))...)
-	data1 = append(data1, []byte(GroupKey(srcAddr))...)
+	data1 = append(data1, []byte(a.GroupKey(netAddr))...)
+	data1 = append(data1, []byte(a.GroupKey(srcAddr))...)
 	hash1 := chainhash.DoubleHashB(data1)
 	hash64 := binary.LittleEndian.Uint64(hash1)
 	hash64 %= newBucketsPerGroup
@@ -309,14 +338,14 @@ func (a *AddrManager) getNewBucket(netAddr, srcAddr *wire.NetAddress) int {
 	binary.LittleEndian.PutUint64(hashbuf[:], hash64)
 	data2 := []byte{}
 	data2 = append(data2, a.key[:]...)
-	data2 = append(data2, GroupKey(srcAddr)...)
+	data2 = append(data2, a.GroupKey(srcAddr)...)
 	data2 = append(data2, hashbuf[:]...)
 
 	hash2 := chainhash.DoubleHashB(data2)
 	return int(binary.LittleEndian.Uint64(hash2) % newBucketCount)
 }
 
-func (a *AddrManager) getTriedBucket(netAddr *wire.NetAddress) int {
+func (a *AddrManager) getTriedBucket(netAddr *wire.NetAddressV2) int {
 	// bitcoind hashes this as:
 	// doublesha256(key + group + truncate_to_64bits(doublesha256(key)) % buckets_per_group) % num_buckets
 	data1 := []byte{}
@@ -329,7 +358,7 @@ func (a *AddrManager) getTriedBucket(netAddr *wire.NetAddress) int {
 	binary.LittleEndian.PutUint64(hashbuf[:], hash64)
 	data2 := []byte{}
 	data2 = append(data2, a.key[:]...)
-	data2 = append(data2, GroupKey(netAddr)...)
+	data2 = append(data2, a.GroupKey(netAddr)...)
 	data2 = append(data2, hashbuf[:]...)
 
 	hash2 := chainhash.DoubleHashB(data2)
@@ -367,6 +396,9 @@ func (a *AddrManager) savePeers() {
 	sam := new(serializedAddrManager)
 	sam.Version = a.version
 	copy(sam.Key[:], a.key[:])
+	if a.asmap != nil {
+		sam.ASMap = a.asmap.Checksum()
+	}
 
 	sam.Addresses = make([]*serializedKnownAddress, len(a.addrIndex))
 	i := 0
@@ -543,12 +575,71 @@ func (a *AddrManager) deserializePeers(filePath string) error {
 		}
 	}
 
+	// The buckets depend on the groups of the addresses, so they are
+	// rebuilt when the addresses were saved with another asmap or without
+	// one.
+	var checksum string
+	if a.asmap != nil {
+		checksum = a.asmap.Checksum()
+	}
+	if sam.ASMap != checksum {
+		log.Infof("Asmap changed since the addresses were saved, "+
+			"rebucketing %d addresses", len(a.addrIndex))
+		a.rebucket()
+	}
+
 	return nil
 }
 
-// DeserializeNetAddress converts a given address string to a *wire.NetAddress.
+// rebucket places all known addresses in the buckets their current groups map
+// them to.  Tried addresses whose tried bucket is full are moved back to the
+// new buckets, and addresses whose new bucket is full are forgotten.
+//
+// This function MUST be called with the address manager lock held (for writes).
+func (a *AddrManager) rebucket() {
+	var tried []*KnownAddress
+	for i := range a.addrTried {
+		for e := a.addrTried[i].Front(); e != nil; e = e.Next() {
+			tried = append(tried, e.Value.(*KnownAddress))
+		}
+		a.addrTried[i] = list.New()
+	}
+	for i := range a.addrNew {
+		a.addrNew[i] = make(map[string]*KnownAddress)
+	}
+	a.nTried = 0
+	a.nNew = 0
+
+	for _, ka := range tried {
+		bucket := a.getTriedBucket(ka.na)
+		if a.addrTried[bucket].Len() < triedBucketSize {
+			a.addrTried[bucket].PushBack(ka)
+			a.nTried++
+			continue
+		}
+		ka.tried = false
+	}
+
+	for k, ka := range a.addrIndex {
+		if ka.tried {
+			continue
+		}
+		ka.refs = 0
+		bucket := a.getNewBucket(ka.na, ka.srcAddr)
+		if len(a.addrNew[bucket]) >= newBucketSize {
+			delete(a.addrIndex, k)
+			continue
+		}
+		ka.refs++
+		a.addrNew[bucket][k] = ka
+		a.nNew++
+	}
+}
+
+// DeserializeNetAddress converts a given address string to a
+// *wire.NetAddressV2.
 func (a *AddrManager) DeserializeNetAddress(addr string,
-	services wire.ServiceFlag) (*wire.NetAddress, error) {
+	services wire.ServiceFlag) (*wire.NetAddressV2, error) {
 
 	host, portStr, err := net.SplitHostPort(addr)
 	if err != nil {
@@ -597,7 +688,7 @@ func (a *AddrManager) Stop() error {
 // AddAddresses adds new addresses to the address manager.  It enforces a max
 // number of addresses and silently ignores duplicate addresses.  It is
 // safe for concurrent access.
-func (a *AddrManager) AddAddresses(addrs []*wire.NetAddress, srcAddr *wire.NetAddress) {
+func (a *AddrManager) AddAddresses(addrs []*wire.NetAddressV2, srcAddr *wire.NetAddressV2) {
 	a.mtx.Lock()
 	defer a.mtx.Unlock()
 
@@ -609,7 +700,7 @@ func (a *AddrManager) AddAddresses(addrs []*wire.NetAddress, srcAddr *wire.NetAd
 // AddAddress adds a new address to the address manager.  It enforces a max
 // number of addresses and silently ignores duplicate addresses.  It is
 // safe for concurrent access.
-func (a *AddrManager) AddAddress(addr, srcAddr *wire.NetAddress) {
+func (a *AddrManager) AddAddress(addr, srcAddr *wire.NetAddressV2) {
 	a.mtx.Lock()
 	defer a.mtx.Unlock()
 
@@ -617,7 +708,7 @@ func (a *AddrManager) AddAddress(addr, srcAddr *wire.NetAddress) {
 }
 
 // AddAddressByIP adds an address where we are given an ip:port and not a
-// wire.NetAddress.
+// wire.NetAddressV2.
 func (a *AddrManager) AddAddressByIP(addrIP string) error {
 	// Split IP and port
 	addr, portStr, err := net.SplitHostPort(addrIP)
@@ -633,7 +724,7 @@ func (a *AddrManager) AddAddressByIP(addrIP string) error {
 	if err != nil {
 		return fmt.Errorf("invalid port %s: %v", portStr, err)
 	}
-	na := wire.NewNetAddressIPPort(ip, uint16(port), 0)
+	na := wire.NewNetAddressV2IPPort(ip, uint16(port), 0)
 	a.AddAddress(na, na) // XXX use correct src address
 	return nil
 }
@@ -662,7 +753,7 @@ func (a *AddrManager) NeedMoreAddresses() bool {
 
 // AddressCache returns the current address cache.  It must be treated as
 // read-only (but since it is a copy now, this is not as dangerous).
-func (a *AddrManager) AddressCache() []*wire.NetAddress {
+func (a *AddrManager) AddressCache() []*wire.NetAddressV2 {
 	allAddr := a.getAddresses()
 
 	numAddresses := len(allAddr) * getAddrPercent / 100
@@ -684,7 +775,7 @@ func (a *AddrManager) AddressCache() []*wire.NetAddress {
 
 // getAddresses returns all of the addresses currently found within the
 // manager's address cache.
-func (a *AddrManager) getAddresses() []*wire.NetAddress {
+func (a *AddrManager) getAddresses() []*wire.NetAddressV2 {
 	a.mtx.Lock()
 	defer a.mtx.Unlock()
 
@@ -693,7 +784,7 @@ func (a *AddrManager) getAddresses() []*wire.NetAddress {
 		return nil
 	}
 
-	addrs := make([]*wire.NetAddress, 0, addrIndexLen)
+	addrs := make([]*wire.NetAddressV2, 0, addrIndexLen)
 	for _, v := range a.addrIndex {
 		addrs = append(addrs, v.na)
 	}
@@ -706,6 +797,7 @@ func (a *AddrManager) getAddresses() []*wire.NetAddress {
 func (a *AddrManager) reset() {
 
 	a.addrIndex = make(map[string]*KnownAddress)
+	a.triedCollisions = make(map[string]*KnownAddress)
 
 	// fill key with bytes from a good random source.
 	io.ReadFull(crand.Reader, a.key[:])
@@ -718,23 +810,27 @@ func (a *AddrManager) reset() {
 }
 
 // HostToNetAddress returns a netaddress given a host address.  If the address
-// is a Tor .onion address this will be taken care of.  Else if the host is
-// not an IP address it will be resolved (via Tor if required).
-func (a *AddrManager) HostToNetAddress(host string, port uint16, services wire.ServiceFlag) (*wire.NetAddress, error) {
-	// Tor address is 16 char base32 + ".onion"
-	var ip net.IP
-	if len(host) == 22 && host[16:] == ".onion" {
-		// go base32 encoding uses capitals (as does the rfc
-		// but Tor and bitcoind tend to user lowercase, so we switch
-		// case here.
-		data, err := base32.StdEncoding.DecodeString(
-			strings.ToUpper(host[:16]))
+// is a Tor .onion or I2P .b32.i2p address this will be taken care of, and IPv6
+// addresses in fc00::/8 are CJDNS addresses.  Else if the host is not an IP
+// address it will be resolved (via Tor if required).
+func (a *AddrManager) HostToNetAddress(host string, port uint16, services wire.ServiceFlag) (*wire.NetAddressV2, error) {
+	if strings.HasSuffix(host, onionSuffix) {
+		netID, addr, err := ParseOnionHost(host)
+		if err != nil {
+			return nil, err
+		}
+		return wire.NewNetAddressV2(netID, addr, port, services), nil
+	}
+	if strings.HasSuffix(host, i2pSuffix) {
+		addr, err := parseI2PHost(host)
 		if err != nil {
 			return nil, err
 		}
-		prefix := []byte{0xfd, 0x87, 0xd8, 0x7e, 0xeb, 0x43}
-		ip = net.IP(append(prefix, data...))
-	} else if ip = net.ParseIP(host); ip == nil {
+		return wire.NewNetAddressV2(wire.NetI2P, addr, port, services), nil
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil {
 		ips, err := a.lookupFunc(host)
 		if err != nil {
 			return nil, err
@@ -744,29 +840,20 @@ func (a *AddrManager) HostToNetAddress(host string, port uint16, services wire.S
 		}
 		ip = ips[0]
 	}
-
-	return wire.NewNetAddressIPPort(ip, port, services), nil
-}
-
-// ipString returns a string for the ip from the provided NetAddress. If the
-// ip is in the range used for Tor addresses then it will be transformed into
-// the relevant .onion address.
-func ipString(na *wire.NetAddress) string {
-	if IsOnionCatTor(na) {
-		// We know now that na.IP is long enough.
-		base32 := base32.StdEncoding.EncodeToString(na.IP[6:])
-		return strings.ToLower(base32) + ".onion"
+	if ip.To4() == nil && ip[0] == 0xfc {
+		return wire.NewNetAddressV2(wire.NetCJDNS, ip.To16(), port,
+			services), nil
 	}
 
-	return na.IP.String()
+	return wire.NewNetAddressV2IPPort(ip, port, services), nil
 }
 
-// NetAddressKey returns a string key in the form of ip:port for IPv4 addresses
-// or [ip]:port for IPv6 addresses.
-func NetAddressKey(na *wire.NetAddress) string {
+// NetAddressKey returns a string key in the form of host:port for IPv4, Tor and
+// I2P addresses or [host]:port for IPv6 and CJDNS addresses.
+func NetAddressKey(na *wire.NetAddressV2) string {
 	port := strconv.FormatUint(uint64(na.Port), 10)
 
-	return net.JoinHostPort(ipString(na), port)
+	return net.JoinHostPort(hostString(na), port)
 }
 
 // GetAddress returns a single address that should be routable.  It picks a
@@ -809,44 +896,51 @@ func (a *AddrManager) GetAddress() *KnownAddress {
 			}
 			factor *= 1.2
 		}
-	} else {
-		// new node.
-		// XXX use a closure/function to avoid repeating this.
-		large := 1 << 30
-		factor := 1.0
-		for {
-			// Pick a random bucket.
-			bucket := a.rand.Intn(len(a.addrNew))
-			if len(a.addrNew[bucket]) == 0 {
-				continue
-			}
-			// Then, a random entry in it.
-			var ka *KnownAddress
-			nth := a.rand.Intn(len(a.addrNew[bucket]))
-			for _, value := range a.addrNew[bucket] {
-				if nth == 0 {
-					ka = value
-				}
-				nth--
-			}
-			randval := a.rand.Intn(large)
-			if float64(randval) < (factor * ka.chance() * float64(large)) {
-				log.Tracef("Selected %v from new bucket",
-					NetAddressKey(ka.na))
-				return ka
+	}
+
+	return a.getNewAddress()
+}
+
+// getNewAddress returns a random address of the new buckets with preference
+// given to ones that have not been used recently.  There must be at least one
+// address in the new buckets.
+//
+// This function MUST be called with the address manager lock held (for writes).
+func (a *AddrManager) getNewAddress() *KnownAddress {
+	large := 1 << 30
+	factor := 1.0
+	for {
+		// Pick a random bucket.
+		bucket := a.rand.Intn(len(a.addrNew))
+		if len(a.addrNew[bucket]) == 0 {
+			continue
+		}
+		// Then, a random entry in it.
+		var ka *KnownAddress
+		nth := a.rand.Intn(len(a.addrNew[bucket]))
+		for _, value := range a.addrNew[bucket] {
+			if nth == 0 {
+				ka = value
 			}
-			factor *= 1.2
+			nth--
 		}
+		randval := a.rand.Intn(large)
+		if float64(randval) < (factor * ka.chance() * float64(large)) {
+			log.Tracef("Selected %v from new bucket",
+				NetAddressKey(ka.na))
+			return ka
+		}
+		factor *= 1.2
 	}
 }
 
-func (a *AddrManager) find(addr *wire.NetAddress) *KnownAddress {
+func (a *AddrManager) find(addr *wire.NetAddressV2) *KnownAddress {
 	return a.addrIndex[NetAddressKey(addr)]
 }
 
 // Attempt increases the given address' attempt counter and updates
 // the last attempt time.
-func (a *AddrManager) Attempt(addr *wire.NetAddress) {
+func (a *AddrManager) Attempt(addr *wire.NetAddressV2) {
 	a.mtx.Lock()
 	defer a.mtx.Unlock()
 
@@ -864,7 +958,7 @@ func (a *AddrManager) Attempt(addr *wire.NetAddress) {
 // Connected Marks the given address as currently connected and working at the
 // current time.  The address must already be known to AddrManager else it will
 // be ignored.
-func (a *AddrManager) Connected(addr *wire.NetAddress) {
+func (a *AddrManager) Connected(addr *wire.NetAddressV2) {
 	a.mtx.Lock()
 	defer a.mtx.Unlock()
 
@@ -887,7 +981,7 @@ func (a *AddrManager) Connected(addr *wire.NetAddress) {
 // Good marks the given address as good.  To be called after a successful
 // connection and version exchange.  If the address is unknown to the address
 // manager it will be ignored.
-func (a *AddrManager) Good(addr *wire.NetAddress) {
+func (a *AddrManager) Good(addr *wire.NetAddressV2) {
 	a.mtx.Lock()
 	defer a.mtx.Unlock()
 
@@ -908,11 +1002,27 @@ func (a *AddrManager) Good(addr *wire.NetAddress) {
 		return
 	}
 
-	// ok, need to move it to tried.
+	// When the tried bucket is full, the address it would replace is tested
+	// first, so an attacker can't flush the tried addresses with addresses
+	// it controls.  The address stays in the new buckets meanwhile.
+	bucket := a.getTriedBucket(ka.na)
+	if a.addrTried[bucket].Len() >= triedBucketSize {
+		a.addTriedCollision(ka, bucket)
+		return
+	}
+
+	a.moveToTried(ka, bucket, nil)
+}
 
+// moveToTried moves the passed address from the new buckets to the passed
+// tried bucket.  When the entry of the bucket to replace is passed, its
+// address is moved back to the new buckets to make room.
+//
+// This function MUST be called with the address manager lock held (for writes).
+func (a *AddrManager) moveToTried(ka *KnownAddress, bucket int, entry *list.Element) {
 	// remove from all new buckets.
 	// record one of the buckets in question and call it the `first'
-	addrKey := NetAddressKey(addr)
+	addrKey := NetAddressKey(ka.na)
 	oldBucket := -1
 	for i := range a.addrNew {
 		// we check for existence so we can record the first one
@@ -931,10 +1041,8 @@ func (a *AddrManager) Good(addr *wire.NetAddress) {
 		return
 	}
 
-	bucket := a.getTriedBucket(ka.na)
-
 	// Room in this tried bucket?
-	if a.addrTried[bucket].Len() < triedBucketSize {
+	if entry == nil {
 		ka.tried = true
 		a.addrTried[bucket].PushBack(ka)
 		a.nTried++
@@ -942,7 +1050,6 @@ func (a *AddrManager) Good(addr *wire.NetAddress) {
 	}
 
 	// No room, we have to evict something else.
-	entry := a.pickTried(bucket)
 	rmka := entry.Value.(*KnownAddress)
 
 	// First bucket it would have been put in.
@@ -973,8 +1080,136 @@ func (a *AddrManager) Good(addr *wire.NetAddress) {
 	a.addrNew[newBucket][rmkey] = rmka
 }
 
+// addTriedCollision records that the passed address could not be moved to the
+// passed full tried bucket.  The address of the bucket it would replace is
+// tested with a feeler connection before the collision is resolved.  The
+// collision is dropped when there are too many of them already.
+//
+// This function MUST be called with the address manager lock held (for writes).
+func (a *AddrManager) addTriedCollision(ka *KnownAddress, bucket int) {
+	addrKey := NetAddressKey(ka.na)
+	if _, ok := a.triedCollisions[addrKey]; ok {
+		return
+	}
+	if len(a.triedCollisions) >= triedCollisionSize {
+		log.Tracef("Dropping tried collision of %s: too many pending "+
+			"collisions", addrKey)
+		return
+	}
+
+	old := a.pickTried(bucket).Value.(*KnownAddress)
+	log.Debugf("Address %s collides with %s in tried, testing the latter",
+		addrKey, NetAddressKey(old.na))
+	a.triedCollisions[addrKey] = old
+}
+
+// findTried returns the entry of the passed tried bucket holding the passed
+// address, or nil when it is not in the bucket.
+//
+// This function MUST be called with the address manager lock held (for reads).
+func (a *AddrManager) findTried(bucket int, ka *KnownAddress) *list.Element {
+	for e := a.addrTried[bucket].Front(); e != nil; e = e.Next() {
+		if e.Value.(*KnownAddress) == ka {
+			return e
+		}
+	}
+	return nil
+}
+
+// resolveCollisions resolves the pending tried collisions following the
+// reference implementation.  A tried address which connected recently is kept
+// and the colliding address stays in the new buckets.  A tried address which
+// failed to connect when it was tested, or which could not be tested in time,
+// is replaced by the colliding address.  Other collisions are left pending
+// until the tried address is tested.
+//
+// This function MUST be called with the address manager lock held (for writes).
+func (a *AddrManager) resolveCollisions() {
+	now := time.Now()
+	for addrKey, old := range a.triedCollisions {
+		// The colliding address may have been removed or moved to
+		// tried since.
+		ka := a.addrIndex[addrKey]
+		if ka == nil || ka.tried {
+			delete(a.triedCollisions, addrKey)
+			continue
+		}
+
+		// The tried address may have been replaced since, in which
+		// case there might be room in the bucket now, or the address
+		// which replaced it is tested instead.
+		bucket := a.getTriedBucket(ka.na)
+		entry := a.findTried(bucket, old)
+		if entry == nil {
+			if a.addrTried[bucket].Len() < triedBucketSize {
+				a.moveToTried(ka, bucket, nil)
+				delete(a.triedCollisions, addrKey)
+				continue
+			}
+			entry = a.pickTried(bucket)
+			old = entry.Value.(*KnownAddress)
+			a.triedCollisions[addrKey] = old
+		}
+
+		oldKey := NetAddressKey(old.na)
+		switch {
+		case now.Sub(old.lastsuccess) < triedReplacementWindow:
+			log.Debugf("Keeping %s in tried over colliding %s: "+
+				"connected recently", oldKey, addrKey)
+			delete(a.triedCollisions, addrKey)
+
+		case now.Sub(old.lastattempt) < triedReplacementWindow:
+			// Give the address time to connect when it is being
+			// tested.
+			if now.Sub(old.lastattempt) < feelerTimeout {
+				continue
+			}
+			log.Debugf("Replacing %s with colliding %s in tried: "+
+				"failed to connect", oldKey, addrKey)
+			a.moveToTried(ka, bucket, entry)
+			delete(a.triedCollisions, addrKey)
+
+		case now.Sub(ka.lastsuccess) > collisionTestWindow:
+			log.Debugf("Replacing %s with colliding %s in tried: "+
+				"not tested in time", oldKey, addrKey)
+			a.moveToTried(ka, bucket, entry)
+			delete(a.triedCollisions, addrKey)
+		}
+	}
+}
+
+// GetFeelerAddress returns an address to test with a short-lived feeler
+// connection, or nil when there is none.  Pending tried collisions are resolved
+// first, and the tried address of a remaining collision is returned when there
+// is one so it is tested before being replaced.  Otherwise, a random address of
+// the new buckets is returned so working addresses are moved to tried once
+// they are marked good.
+func (a *AddrManager) GetFeelerAddress() *KnownAddress {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	a.resolveCollisions()
+
+	if len(a.triedCollisions) > 0 {
+		nth := a.rand.Intn(len(a.triedCollisions))
+		for _, old := range a.triedCollisions {
+			if nth == 0 {
+				log.Tracef("Selected %v from tried collisions",
+					NetAddressKey(old.na))
+				return old
+			}
+			nth--
+		}
+	}
+
+	if a.nNew == 0 {
+		return nil
+	}
+	return a.getNewAddress()
+}
+
 // SetServices sets the services for the giiven address to the provided value.
-func (a *AddrManager) SetServices(addr *wire.NetAddress, services wire.ServiceFlag) {
+func (a *AddrManager) SetServices(addr *wire.NetAddressV2, services wire.ServiceFlag) {
 	a.mtx.Lock()
 	defer a.mtx.Unlock()
 
@@ -992,11 +1227,25 @@ func (a *AddrManager) SetServices(addr *wire.NetAddress, services wire.ServiceFl
 	}
 }
 
+// Services returns the services known to be supported by the given address
+// and whether the address is known at all.
+func (a *AddrManager) Services(addr *wire.NetAddressV2) (wire.ServiceFlag, bool) {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	ka := a.find(addr)
+	if ka == nil {
+		return 0, false
+	}
+	return ka.na.Services, true
+}
+
 // AddLocalAddress adds na to the list of known local addresses to advertise
 // with the given priority.
-func (a *AddrManager) AddLocalAddress(na *wire.NetAddress, priority AddressPriority) error {
+func (a *AddrManager) AddLocalAddress(na *wire.NetAddressV2, priority AddressPriority) error {
 	if !IsRoutable(na) {
-		return fmt.Errorf("address %s is not routable", na.IP)
+		return fmt.Errorf("address %s is not routable",
+			NetAddressKey(na))
 	}
 
 	a.lamtx.Lock()
@@ -1019,7 +1268,7 @@ func (a *AddrManager) AddLocalAddress(na *wire.NetAddress, priority AddressPrior
 
 // getReachabilityFrom returns the relative reachability of the provided local
 // address to the provided remote address.
-func getReachabilityFrom(localAddr, remoteAddr *wire.NetAddress) int {
+func getReachabilityFrom(localAddr, remoteAddr *wire.NetAddressV2) int {
 	const (
 		Unreachable = 0
 		Default     = iota
@@ -1034,8 +1283,8 @@ func getReachabilityFrom(localAddr, remoteAddr *wire.NetAddress) int {
 		return Unreachable
 	}
 
-	if IsOnionCatTor(remoteAddr) {
-		if IsOnionCatTor(localAddr) {
+	if IsTor(remoteAddr) {
+		if IsTor(localAddr) {
 			return Private
 		}
 
@@ -1046,6 +1295,16 @@ func getReachabilityFrom(localAddr, remoteAddr *wire.NetAddress) int {
 		return Default
 	}
 
+	// I2P and CJDNS peers can only reach addresses of their own network
+	// privately, which are preferred over any other.
+	if IsI2P(remoteAddr) || IsCJDNS(remoteAddr) {
+		if localAddr.NetID == remoteAddr.NetID {
+			return Private
+		}
+
+		return Default
+	}
+
 	if IsRFC4380(remoteAddr) {
 		if !IsRoutable(localAddr) {
 			return Default
@@ -1066,6 +1325,12 @@ func getReachabilityFrom(localAddr, remoteAddr *wire.NetAddress) int {
 		if IsRoutable(localAddr) && IsIPv4(localAddr) {
 			return Ipv4
 		}
+
+		// Addresses of other networks, such as hidden services, can
+		// still be relayed to peers which can reach them.
+		if IsRoutable(localAddr) && localAddr.IP() == nil {
+			return Default
+		}
 		return Unreachable
 	}
 
@@ -1076,7 +1341,7 @@ func getReachabilityFrom(localAddr, remoteAddr *wire.NetAddress) int {
 		tunnelled = true
 	}
 
-	if !IsRoutable(localAddr) {
+	if !IsRoutable(localAddr) || localAddr.IP() == nil {
 		return Default
 	}
 
@@ -1098,13 +1363,13 @@ func getReachabilityFrom(localAddr, remoteAddr *wire.NetAddress) int {
 
 // GetBestLocalAddress returns the most appropriate local address to use
 // for the given remote address.
-func (a *AddrManager) GetBestLocalAddress(remoteAddr *wire.NetAddress) *wire.NetAddress {
+func (a *AddrManager) GetBestLocalAddress(remoteAddr *wire.NetAddressV2) *wire.NetAddressV2 {
 	a.lamtx.Lock()
 	defer a.lamtx.Unlock()
 
 	bestreach := 0
 	var bestscore AddressPriority
-	var bestAddress *wire.NetAddress
+	var bestAddress *wire.NetAddressV2
 	for _, la := range a.localAddresses {
 		reach := getReachabilityFrom(la.na, remoteAddr)
 		if reach > bestreach ||
@@ -1115,26 +1380,54 @@ func (a *AddrManager) GetBestLocalAddress(remoteAddr *wire.NetAddress) *wire.Net
 		}
 	}
 	if bestAddress != nil {
-		log.Debugf("Suggesting address %s:%d for %s:%d", bestAddress.IP,
-			bestAddress.Port, remoteAddr.IP, remoteAddr.Port)
+		log.Debugf("Suggesting address %s for %s",
+			NetAddressKey(bestAddress), NetAddressKey(remoteAddr))
 	} else {
-		log.Debugf("No worthy address for %s:%d", remoteAddr.IP,
-			remoteAddr.Port)
+		log.Debugf("No worthy address for %s", NetAddressKey(remoteAddr))
 
 		// Send something unroutable if nothing suitable.
 		var ip net.IP
-		if !IsIPv4(remoteAddr) && !IsOnionCatTor(remoteAddr) {
+		if !IsIPv4(remoteAddr) && !IsTor(remoteAddr) {
 			ip = net.IPv6zero
 		} else {
 			ip = net.IPv4zero
 		}
 		services := wire.SFNodeNetwork | wire.SFNodeWitness | wire.SFNodeBloom
-		bestAddress = wire.NewNetAddressIPPort(ip, 0, services)
+		bestAddress = wire.NewNetAddressV2IPPort(ip, 0, services)
 	}
 
 	return bestAddress
 }
 
+// SetASMap sets the asmap used to group IPv4 and IPv6 addresses by autonomous
+// system instead of by prefix.  It must be called before Start.
+func (a *AddrManager) SetASMap(m *ASMap) {
+	a.asmap = m
+}
+
+// ASN returns the number of the autonomous system the passed address belongs
+// to according to the asmap, or 0 when it is unknown or no asmap is set.
+func (a *AddrManager) ASN(na *wire.NetAddressV2) uint32 {
+	if a.asmap == nil || !IsRoutable(na) {
+		return 0
+	}
+	if na.NetID != wire.NetIPv4 && na.NetID != wire.NetIPv6 {
+		return 0
+	}
+	return a.asmap.ASN(na.IP())
+}
+
+// GroupKey returns a string representing the network group an address is part
+// of.  It is the autonomous system of IPv4 and IPv6 addresses mapped by the
+// asmap, as the string "as" followed by its number, and the group returned by
+// the GroupKey function otherwise.
+func (a *AddrManager) GroupKey(na *wire.NetAddressV2) string {
+	if asn := a.ASN(na); asn != 0 {
+		return fmt.Sprintf("as%d", asn)
+	}
+	return GroupKey(na)
+}
+
 // New returns a new bitcoin address manager.
 // Use Start to begin processing asynchronous address updates.
 func New(dataDir string, lookupFunc func(string) ([]net.IP, error)) *AddrManager {
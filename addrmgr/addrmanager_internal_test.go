@@ -1,51 +1,54 @@
 package addrmgr
 
 import (
+	"bytes"
+	"fmt"
 	"io/ioutil"
 	"math/rand"
 	"net"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/eager7/dashd/wire"
 )
 
-// randAddr generates a *wire.NetAddress backed by a random IPv4/IPv6 address.
-func randAddr(t *testing.T) *wire.NetAddress {
+// randAddr generates a *wire.NetAddressV2 backed by a random IPv4, IPv6, Tor v3
+// or I2P address.
+func randAddr(t *testing.T) *wire.NetAddressV2 {
 	t.Helper()
 
-	ipv4 := rand.Intn(2) == 0
-	var ip net.IP
-	if ipv4 {
-		var b [4]byte
-		if _, err := rand.Read(b[:]); err != nil {
-			t.Fatal(err)
-		}
-		ip = b[:]
-	} else {
-		var b [16]byte
-		if _, err := rand.Read(b[:]); err != nil {
-			t.Fatal(err)
-		}
-		ip = b[:]
+	netIDs := []wire.NetworkID{wire.NetIPv4, wire.NetIPv6, wire.NetTorV3,
+		wire.NetI2P}
+	netID := netIDs[rand.Intn(len(netIDs))]
+	addr := make([]byte, netID.AddrSize())
+	if _, err := rand.Read(addr); err != nil {
+		t.Fatal(err)
+	}
+
+	// Avoid the unique local IPv6 range, which is not routable.
+	if netID == wire.NetIPv6 {
+		addr[0] = 0x20
 	}
 
-	return &wire.NetAddress{
+	return &wire.NetAddressV2{
 		Services: wire.ServiceFlag(rand.Uint64()),
-		IP:       ip,
+		NetID:    netID,
+		Addr:     addr,
 		Port:     uint16(rand.Uint32()),
 	}
 }
 
 // assertAddr ensures that the two addresses match. The timestamp is not
 // checked as it does not affect uniquely identifying a specific address.
-func assertAddr(t *testing.T, got, expected *wire.NetAddress) {
+func assertAddr(t *testing.T, got, expected *wire.NetAddressV2) {
 	if got.Services != expected.Services {
 		t.Fatalf("expected address services %v, got %v",
 			expected.Services, got.Services)
 	}
-	if !got.IP.Equal(expected.IP) {
-		t.Fatalf("expected address IP %v, got %v", expected.IP, got.IP)
+	if got.NetID != expected.NetID || !bytes.Equal(got.Addr, expected.Addr) {
+		t.Fatalf("expected address %v %x, got %v %x", expected.NetID,
+			expected.Addr, got.NetID, got.Addr)
 	}
 	if got.Port != expected.Port {
 		t.Fatalf("expected address port %d, got %d", expected.Port,
@@ -56,7 +59,7 @@ func assertAddr(t *testing.T, got, expected *wire.NetAddress) {
 // assertAddrs ensures that the manager's address cache matches the given
 // expected addresses.
 func assertAddrs(t *testing.T, addrMgr *AddrManager,
-	expectedAddrs map[string]*wire.NetAddress) {
+	expectedAddrs map[string]*wire.NetAddressV2) {
 
 	t.Helper()
 
@@ -96,7 +99,7 @@ func TestAddrManagerSerialization(t *testing.T) {
 	// We'll be adding 5 random addresses to the manager.
 	const numAddrs = 5
 
-	expectedAddrs := make(map[string]*wire.NetAddress, numAddrs)
+	expectedAddrs := make(map[string]*wire.NetAddressV2, numAddrs)
 	for i := 0; i < numAddrs; i++ {
 		addr := randAddr(t)
 		expectedAddrs[NetAddressKey(addr)] = addr
@@ -141,7 +144,7 @@ func TestAddrManagerV1ToV2(t *testing.T) {
 	// each addresses' services will not be stored.
 	const numAddrs = 5
 
-	expectedAddrs := make(map[string]*wire.NetAddress, numAddrs)
+	expectedAddrs := make(map[string]*wire.NetAddressV2, numAddrs)
 	for i := 0; i < numAddrs; i++ {
 		addr := randAddr(t)
 		expectedAddrs[NetAddressKey(addr)] = addr
@@ -192,3 +195,139 @@ func TestAddrManagerV1ToV2(t *testing.T) {
 	addrMgr.loadPeers()
 	assertAddrs(t, addrMgr, expectedAddrs)
 }
+
+// newCollisionAddrManager returns an address manager with a new address whose
+// tried bucket is full, along with the new address and the tried address it
+// collides with, which is the one seen the longest time ago.
+func newCollisionAddrManager(t *testing.T) (*AddrManager, *KnownAddress, *KnownAddress) {
+	t.Helper()
+
+	addrMgr := New("testcollision", nil)
+	na := wire.NewNetAddressV2IPPort(net.ParseIP("173.194.115.66"), 8333,
+		wire.SFNodeNetwork)
+	addrMgr.AddAddress(na, na)
+	ka := addrMgr.find(na)
+	if ka == nil {
+		t.Fatal("address not added")
+	}
+
+	// Fill the tried bucket of the address.
+	now := time.Now()
+	bucket := addrMgr.getTriedBucket(na)
+	var old *KnownAddress
+	for i := 0; i < triedBucketSize; i++ {
+		tna := wire.NewNetAddressV2IPPort(net.IPv4(12, 1, byte(i>>8),
+			byte(i)), 8333, wire.SFNodeNetwork)
+		tna.Timestamp = now.Add(-time.Duration(i) * time.Minute)
+		tka := &KnownAddress{na: tna, srcAddr: tna, tried: true}
+		addrMgr.addrIndex[NetAddressKey(tna)] = tka
+		addrMgr.addrTried[bucket].PushBack(tka)
+		addrMgr.nTried++
+		old = tka
+	}
+
+	addrMgr.Good(na)
+	if ka.tried {
+		t.Fatal("address moved to a full tried bucket")
+	}
+	if got := addrMgr.triedCollisions[NetAddressKey(na)]; got != old {
+		t.Fatal("tried collision not recorded")
+	}
+	return addrMgr, ka, old
+}
+
+// TestResolveCollisions ensures tried collisions are resolved according to the
+// outcome of the test of the tried address they collide with.
+func TestResolveCollisions(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	tests := []struct {
+		name        string
+		lastSuccess time.Time // of the tried address
+		lastAttempt time.Time // of the tried address
+		goodTime    time.Time // of the new address
+		replaced    bool
+		pending     bool
+	}{{
+		name:        "tried address connected recently",
+		lastSuccess: now.Add(-time.Hour),
+		lastAttempt: now.Add(-time.Hour),
+		goodTime:    now,
+	}, {
+		name:        "tried address failed to connect",
+		lastSuccess: now.Add(-5 * time.Hour),
+		lastAttempt: now.Add(-2 * time.Minute),
+		goodTime:    now,
+		replaced:    true,
+	}, {
+		name:        "tried address being tested",
+		lastSuccess: now.Add(-5 * time.Hour),
+		lastAttempt: now.Add(-10 * time.Second),
+		goodTime:    now,
+		pending:     true,
+	}, {
+		name:     "tried address not tested yet",
+		goodTime: now.Add(-10 * time.Minute),
+		pending:  true,
+	}, {
+		name:     "tried address not tested in time",
+		goodTime: now.Add(-time.Hour),
+		replaced: true,
+	}}
+
+	for _, test := range tests {
+		addrMgr, ka, old := newCollisionAddrManager(t)
+		old.lastsuccess = test.lastSuccess
+		old.lastattempt = test.lastAttempt
+		ka.lastsuccess = test.goodTime
+
+		// The tried address is tested while the collision is pending.
+		feeler := addrMgr.GetFeelerAddress()
+		pending := len(addrMgr.triedCollisions) != 0
+		if pending != test.pending {
+			t.Errorf("%s: collision pending %v, want %v", test.name,
+				pending, test.pending)
+			continue
+		}
+		if pending && feeler != old {
+			t.Errorf("%s: feeler address %v, want %v", test.name,
+				NetAddressKey(feeler.na), NetAddressKey(old.na))
+			continue
+		}
+
+		if ka.tried != test.replaced || old.tried == test.replaced {
+			t.Errorf("%s: new address tried %v, old address tried "+
+				"%v, want replaced %v", test.name, ka.tried,
+				old.tried, test.replaced)
+			continue
+		}
+		if test.replaced && old.refs != 1 {
+			t.Errorf("%s: replaced address in %d new buckets, "+
+				"want 1", test.name, old.refs)
+		}
+		if addrMgr.nTried != triedBucketSize || addrMgr.nNew != 1 {
+			t.Errorf("%s: got %d tried and %d new addresses, want "+
+				"%d and 1", test.name, addrMgr.nTried,
+				addrMgr.nNew, triedBucketSize)
+		}
+	}
+}
+
+// TestTriedCollisionSize ensures the number of pending tried collisions is
+// limited.
+func TestTriedCollisionSize(t *testing.T) {
+	t.Parallel()
+
+	addrMgr, ka, old := newCollisionAddrManager(t)
+	bucket := addrMgr.getTriedBucket(ka.na)
+
+	addrMgr.triedCollisions = make(map[string]*KnownAddress)
+	for i := 0; i < triedCollisionSize; i++ {
+		addrMgr.triedCollisions[fmt.Sprintf("collision%d", i)] = old
+	}
+	addrMgr.addTriedCollision(ka, bucket)
+	if _, ok := addrMgr.triedCollisions[NetAddressKey(ka.na)]; ok {
+		t.Fatal("tried collision recorded past the limit")
+	}
+}
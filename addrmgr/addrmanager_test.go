@@ -19,7 +19,7 @@ import (
 // naTest is used to describe a test to be performed against the NetAddressKey
 // method.
 type naTest struct {
-	in   wire.NetAddress
+	in   wire.NetAddressV2
 	want string
 }
 
@@ -93,11 +93,16 @@ func addNaTests() {
 
 func addNaTest(ip string, port uint16, want string) {
 	nip := net.ParseIP(ip)
-	na := *wire.NewNetAddressIPPort(nip, port, wire.SFNodeNetwork)
+	na := *wire.NewNetAddressV2IPPort(nip, port, wire.SFNodeNetwork)
 	test := naTest{na, want}
 	naTests = append(naTests, test)
 }
 
+// ipAddr returns an address without port and services for the passed IP.
+func ipAddr(ip string) wire.NetAddressV2 {
+	return *wire.NewNetAddressV2IPPort(net.ParseIP(ip), 0, 0)
+}
+
 func lookupFunc(host string) ([]net.IP, error) {
 	return nil, errors.New("not implemented")
 }
@@ -157,37 +162,37 @@ func TestAddAddressByIP(t *testing.T) {
 
 func TestAddLocalAddress(t *testing.T) {
 	var tests = []struct {
-		address  wire.NetAddress
+		address  wire.NetAddressV2
 		priority addrmgr.AddressPriority
 		valid    bool
 	}{
 		{
-			wire.NetAddress{IP: net.ParseIP("192.168.0.100")},
+			ipAddr("192.168.0.100"),
 			addrmgr.InterfacePrio,
 			false,
 		},
 		{
-			wire.NetAddress{IP: net.ParseIP("204.124.1.1")},
+			ipAddr("204.124.1.1"),
 			addrmgr.InterfacePrio,
 			true,
 		},
 		{
-			wire.NetAddress{IP: net.ParseIP("204.124.1.1")},
+			ipAddr("204.124.1.1"),
 			addrmgr.BoundPrio,
 			true,
 		},
 		{
-			wire.NetAddress{IP: net.ParseIP("::1")},
+			ipAddr("::1"),
 			addrmgr.InterfacePrio,
 			false,
 		},
 		{
-			wire.NetAddress{IP: net.ParseIP("fe80::1")},
+			ipAddr("fe80::1"),
 			addrmgr.InterfacePrio,
 			false,
 		},
 		{
-			wire.NetAddress{IP: net.ParseIP("2620:100::1")},
+			ipAddr("2620:100::1"),
 			addrmgr.InterfacePrio,
 			true,
 		},
@@ -197,12 +202,12 @@ func TestAddLocalAddress(t *testing.T) {
 		result := amgr.AddLocalAddress(&test.address, test.priority)
 		if result == nil && !test.valid {
 			t.Errorf("TestAddLocalAddress test #%d failed: %s should have "+
-				"been accepted", x, test.address.IP)
+				"been accepted", x, test.address.IP())
 			continue
 		}
 		if result != nil && test.valid {
 			t.Errorf("TestAddLocalAddress test #%d failed: %s should not have "+
-				"been accepted", x, test.address.IP)
+				"been accepted", x, test.address.IP())
 			continue
 		}
 	}
@@ -230,6 +235,29 @@ func TestAttempt(t *testing.T) {
 	}
 }
 
+func TestServices(t *testing.T) {
+	n := addrmgr.New("testservices", lookupFunc)
+
+	// Add a new address and get it
+	err := n.AddAddressByIP(someIP + ":8333")
+	if err != nil {
+		t.Fatalf("Adding address failed: %v", err)
+	}
+	na := n.GetAddress().NetAddress()
+
+	n.SetServices(na, wire.SFNodeNetwork|wire.SFNodeP2PV2)
+	services, ok := n.Services(na)
+	if !ok || services != wire.SFNodeNetwork|wire.SFNodeP2PV2 {
+		t.Errorf("Services: got %v (%v), want %v", services, ok,
+			wire.SFNodeNetwork|wire.SFNodeP2PV2)
+	}
+
+	unknown := wire.NewNetAddressV2IPPort(net.ParseIP("1.2.3.4"), 8333, 0)
+	if _, ok := n.Services(unknown); ok {
+		t.Errorf("Services: unknown address is known")
+	}
+}
+
 func TestConnected(t *testing.T) {
 	n := addrmgr.New("testconnected", lookupFunc)
 
@@ -257,7 +285,7 @@ func TestNeedMoreAddresses(t *testing.T) {
 	if !b {
 		t.Errorf("Expected that we need more addresses")
 	}
-	addrs := make([]*wire.NetAddress, addrsToAdd)
+	addrs := make([]*wire.NetAddressV2, addrsToAdd)
 
 	var err error
 	for i := 0; i < addrsToAdd; i++ {
@@ -268,7 +296,7 @@ func TestNeedMoreAddresses(t *testing.T) {
 		}
 	}
 
-	srcAddr := wire.NewNetAddressIPPort(net.IPv4(173, 144, 173, 111), 8333, 0)
+	srcAddr := wire.NewNetAddressV2IPPort(net.IPv4(173, 144, 173, 111), 8333, 0)
 
 	n.AddAddresses(addrs, srcAddr)
 	numAddrs := n.NumAddresses()
@@ -285,7 +313,7 @@ func TestNeedMoreAddresses(t *testing.T) {
 func TestGood(t *testing.T) {
 	n := addrmgr.New("testgood", lookupFunc)
 	addrsToAdd := 64 * 64
-	addrs := make([]*wire.NetAddress, addrsToAdd)
+	addrs := make([]*wire.NetAddressV2, addrsToAdd)
 
 	var err error
 	for i := 0; i < addrsToAdd; i++ {
@@ -296,7 +324,7 @@ func TestGood(t *testing.T) {
 		}
 	}
 
-	srcAddr := wire.NewNetAddressIPPort(net.IPv4(173, 144, 173, 111), 8333, 0)
+	srcAddr := wire.NewNetAddressV2IPPort(net.IPv4(173, 144, 173, 111), 8333, 0)
 
 	n.AddAddresses(addrs, srcAddr)
 	for _, addr := range addrs {
@@ -331,8 +359,8 @@ func TestGetAddress(t *testing.T) {
 	if ka == nil {
 		t.Fatalf("Did not get an address where there is one in the pool")
 	}
-	if ka.NetAddress().IP.String() != someIP {
-		t.Errorf("Wrong IP: got %v, want %v", ka.NetAddress().IP.String(), someIP)
+	if ka.NetAddress().IP().String() != someIP {
+		t.Errorf("Wrong IP: got %v, want %v", ka.NetAddress().IP().String(), someIP)
 	}
 
 	// Mark this as a good address and get it
@@ -341,8 +369,8 @@ func TestGetAddress(t *testing.T) {
 	if ka == nil {
 		t.Fatalf("Did not get an address where there is one in the pool")
 	}
-	if ka.NetAddress().IP.String() != someIP {
-		t.Errorf("Wrong IP: got %v, want %v", ka.NetAddress().IP.String(), someIP)
+	if ka.NetAddress().IP().String() != someIP {
+		t.Errorf("Wrong IP: got %v, want %v", ka.NetAddress().IP().String(), someIP)
 	}
 
 	numAddrs := n.NumAddresses()
@@ -352,51 +380,51 @@ func TestGetAddress(t *testing.T) {
 }
 
 func TestGetBestLocalAddress(t *testing.T) {
-	localAddrs := []wire.NetAddress{
-		{IP: net.ParseIP("192.168.0.100")},
-		{IP: net.ParseIP("::1")},
-		{IP: net.ParseIP("fe80::1")},
-		{IP: net.ParseIP("2001:470::1")},
+	localAddrs := []wire.NetAddressV2{
+		ipAddr("192.168.0.100"),
+		ipAddr("::1"),
+		ipAddr("fe80::1"),
+		ipAddr("2001:470::1"),
 	}
 
 	var tests = []struct {
-		remoteAddr wire.NetAddress
-		want0      wire.NetAddress
-		want1      wire.NetAddress
-		want2      wire.NetAddress
-		want3      wire.NetAddress
+		remoteAddr wire.NetAddressV2
+		want0      wire.NetAddressV2
+		want1      wire.NetAddressV2
+		want2      wire.NetAddressV2
+		want3      wire.NetAddressV2
 	}{
 		{
 			// Remote connection from public IPv4
-			wire.NetAddress{IP: net.ParseIP("204.124.8.1")},
-			wire.NetAddress{IP: net.IPv4zero},
-			wire.NetAddress{IP: net.IPv4zero},
-			wire.NetAddress{IP: net.ParseIP("204.124.8.100")},
-			wire.NetAddress{IP: net.ParseIP("fd87:d87e:eb43:25::1")},
+			ipAddr("204.124.8.1"),
+			ipAddr("0.0.0.0"),
+			ipAddr("0.0.0.0"),
+			ipAddr("204.124.8.100"),
+			ipAddr("fd87:d87e:eb43:25::1"),
 		},
 		{
 			// Remote connection from private IPv4
-			wire.NetAddress{IP: net.ParseIP("172.16.0.254")},
-			wire.NetAddress{IP: net.IPv4zero},
-			wire.NetAddress{IP: net.IPv4zero},
-			wire.NetAddress{IP: net.IPv4zero},
-			wire.NetAddress{IP: net.IPv4zero},
+			ipAddr("172.16.0.254"),
+			ipAddr("0.0.0.0"),
+			ipAddr("0.0.0.0"),
+			ipAddr("0.0.0.0"),
+			ipAddr("0.0.0.0"),
 		},
 		{
 			// Remote connection from public IPv6
-			wire.NetAddress{IP: net.ParseIP("2602:100:abcd::102")},
-			wire.NetAddress{IP: net.IPv6zero},
-			wire.NetAddress{IP: net.ParseIP("2001:470::1")},
-			wire.NetAddress{IP: net.ParseIP("2001:470::1")},
-			wire.NetAddress{IP: net.ParseIP("2001:470::1")},
+			ipAddr("2602:100:abcd::102"),
+			ipAddr("::"),
+			ipAddr("2001:470::1"),
+			ipAddr("2001:470::1"),
+			ipAddr("2001:470::1"),
 		},
 		/* XXX
 		{
 			// Remote connection from Tor
-			wire.NetAddress{IP: net.ParseIP("fd87:d87e:eb43::100")},
-			wire.NetAddress{IP: net.IPv4zero},
-			wire.NetAddress{IP: net.ParseIP("204.124.8.100")},
-			wire.NetAddress{IP: net.ParseIP("fd87:d87e:eb43:25::1")},
+			ipAddr("fd87:d87e:eb43::100"),
+			ipAddr("0.0.0.0"),
+			ipAddr("204.124.8.100"),
+			ipAddr("fd87:d87e:eb43:25::1"),
 		},
 		*/
 	}
@@ -406,9 +434,9 @@ func TestGetBestLocalAddress(t *testing.T) {
 	// Test against default when there's no address
 	for x, test := range tests {
 		got := amgr.GetBestLocalAddress(&test.remoteAddr)
-		if !test.want0.IP.Equal(got.IP) {
+		if !test.want0.IP().Equal(got.IP()) {
 			t.Errorf("TestGetBestLocalAddress test1 #%d failed for remote address %s: want %s got %s",
-				x, test.remoteAddr.IP, test.want1.IP, got.IP)
+				x, test.remoteAddr.IP(), test.want1.IP(), got.IP())
 			continue
 		}
 	}
@@ -420,37 +448,37 @@ func TestGetBestLocalAddress(t *testing.T) {
 	// Test against want1
 	for x, test := range tests {
 		got := amgr.GetBestLocalAddress(&test.remoteAddr)
-		if !test.want1.IP.Equal(got.IP) {
+		if !test.want1.IP().Equal(got.IP()) {
 			t.Errorf("TestGetBestLocalAddress test1 #%d failed for remote address %s: want %s got %s",
-				x, test.remoteAddr.IP, test.want1.IP, got.IP)
+				x, test.remoteAddr.IP(), test.want1.IP(), got.IP())
 			continue
 		}
 	}
 
 	// Add a public IP to the list of local addresses.
-	localAddr := wire.NetAddress{IP: net.ParseIP("204.124.8.100")}
+	localAddr := ipAddr("204.124.8.100")
 	amgr.AddLocalAddress(&localAddr, addrmgr.InterfacePrio)
 
 	// Test against want2
 	for x, test := range tests {
 		got := amgr.GetBestLocalAddress(&test.remoteAddr)
-		if !test.want2.IP.Equal(got.IP) {
+		if !test.want2.IP().Equal(got.IP()) {
 			t.Errorf("TestGetBestLocalAddress test2 #%d failed for remote address %s: want %s got %s",
-				x, test.remoteAddr.IP, test.want2.IP, got.IP)
+				x, test.remoteAddr.IP(), test.want2.IP(), got.IP())
 			continue
 		}
 	}
 	/*
 		// Add a Tor generated IP address
-		localAddr = wire.NetAddress{IP: net.ParseIP("fd87:d87e:eb43:25::1")}
+		localAddr = ipAddr("fd87:d87e:eb43:25::1")
 		amgr.AddLocalAddress(&localAddr, addrmgr.ManualPrio)
 
 		// Test against want3
 		for x, test := range tests {
 			got := amgr.GetBestLocalAddress(&test.remoteAddr)
-			if !test.want3.IP.Equal(got.IP) {
+			if !test.want3.IP().Equal(got.IP()) {
 				t.Errorf("TestGetBestLocalAddress test3 #%d failed for remote address %s: want %s got %s",
-					x, test.remoteAddr.IP, test.want3.IP, got.IP)
+					x, test.remoteAddr.IP(), test.want3.IP(), got.IP())
 				continue
 			}
 		}
@@ -470,3 +498,64 @@ func TestNetAddressKey(t *testing.T) {
 	}
 
 }
+
+// TestHostToNetAddress ensures Tor, I2P and CJDNS host names are converted to
+// addresses of their networks and back to the same host names.
+func TestHostToNetAddress(t *testing.T) {
+	tests := []struct {
+		host  string
+		netID wire.NetworkID
+		valid bool
+	}{
+		{"173.194.115.66", wire.NetIPv4, true},
+		{"2001:470::1", wire.NetIPv6, true},
+		{"fc32:17ea:e415:c3bf:9808:149d:b5a2:c9aa", wire.NetCJDNS, true},
+		{"aaaaaaaaaaaaaaaa.onion", wire.NetTorV2, true},
+		{"2gzyxa5ihm7nsggfxnu52rck2vv4rvmdlkiu3zzui5du4xyclen53wid.onion",
+			wire.NetTorV3, true},
+		{"ukeu3k5oycgaauneqgtnvselmt4yemvoilkln7jpvamvfx7dnkdq.b32.i2p",
+			wire.NetI2P, true},
+
+		// Bad checksum.
+		{"2gzyxa5ihm7nsggfxnu52rck2vv4rvmdlkiu3zzui5du4xyclen53wic.onion",
+			0, false},
+		// Bad length.
+		{"aaaaaaaaaaaaaaaaaa.onion", 0, false},
+		{"ukeu3k5oycgaauneqgtnvselmt4yemvoilkln7jpvamvfx7dnk.b32.i2p",
+			0, false},
+	}
+
+	amgr := addrmgr.New("testhosttonetaddress", lookupFunc)
+	for i, test := range tests {
+		na, err := amgr.HostToNetAddress(test.host, 9999,
+			wire.SFNodeNetwork)
+		if !test.valid {
+			if err == nil {
+				t.Errorf("HostToNetAddress #%d: %s should have "+
+					"been rejected", i, test.host)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("HostToNetAddress #%d: unexpected error %v", i,
+				err)
+			continue
+		}
+		if na.NetID != test.netID {
+			t.Errorf("HostToNetAddress #%d: wrong network - got %v, "+
+				"want %v", i, na.NetID, test.netID)
+			continue
+		}
+		if !addrmgr.IsRoutable(na) {
+			t.Errorf("HostToNetAddress #%d: %s is not routable", i,
+				test.host)
+		}
+
+		key := addrmgr.NetAddressKey(na)
+		want := net.JoinHostPort(test.host, "9999")
+		if key != want {
+			t.Errorf("NetAddressKey #%d\n got: %s want: %s", i, key,
+				want)
+		}
+	}
+}
@@ -0,0 +1,306 @@
+// Copyright (c) 2013-2016 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package addrmgr
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"math/bits"
+	"net"
+)
+
+// asmapInvalid is returned by the decoding functions of an asmap when the
+// value straddles the end of the asmap.
+const asmapInvalid = 0xffffffff
+
+// asmapOpcode is an instruction of an asmap.
+type asmapOpcode uint32
+
+// These constants are the instructions of an asmap.
+const (
+	// asmapReturn returns the ASN which follows it.
+	asmapReturn asmapOpcode = 0
+
+	// asmapJump skips the number of bits of the asmap which follows it when
+	// the next bit of the IP address is set.
+	asmapJump asmapOpcode = 1
+
+	// asmapMatch compares the next bits of the IP address with those which
+	// follow it, and returns the default ASN when they differ.
+	asmapMatch asmapOpcode = 2
+
+	// asmapDefault sets the default ASN to the ASN which follows it.
+	asmapDefault asmapOpcode = 3
+)
+
+// The values of an asmap are encoded with a variable number of bits.  The
+// number of bits is picked by a unary prefix, each bit of which selects the
+// next of these sizes.
+var (
+	asmapTypeBitSizes  = []uint8{0, 0, 1}
+	asmapASNBitSizes   = []uint8{15, 16, 17, 18, 19, 20, 21, 22, 23, 24}
+	asmapMatchBitSizes = []uint8{1, 2, 3, 4, 5, 6, 7, 8}
+	asmapJumpBitSizes  = []uint8{5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16,
+		17, 18, 19, 20, 21, 22, 23, 24, 25, 26, 27, 28, 29, 30}
+)
+
+// errInvalidASMap is returned when an asmap is malformed.
+var errInvalidASMap = errors.New("invalid asmap")
+
+// ASMap maps IP addresses to the autonomous system (AS) they belong to.  It is
+// encoded compactly as the bits of a program which walks a binary trie of the
+// bits of an IP address to find the number of its AS (ASN), in the format used
+// by the reference implementation.
+type ASMap struct {
+	data     []byte
+	checksum string
+}
+
+// NewASMap returns the asmap encoded by the passed data after making sure it
+// is well formed, so looking up any IP address succeeds.
+func NewASMap(data []byte) (*ASMap, error) {
+	m := &ASMap{data: data}
+	if !m.sane() {
+		return nil, errInvalidASMap
+	}
+	sum := sha256.Sum256(data)
+	m.checksum = hex.EncodeToString(sum[:])
+	return m, nil
+}
+
+// LoadASMap returns the asmap read from the file with the passed path.
+func LoadASMap(path string) (*ASMap, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewASMap(data)
+}
+
+// Checksum returns the hex encoded SHA256 of the asmap, which identifies it.
+func (m *ASMap) Checksum() string {
+	return m.checksum
+}
+
+// ASN returns the number of the autonomous system the passed IP address belongs
+// to, or 0 when it is unknown.
+func (m *ASMap) ASN(ip net.IP) uint32 {
+	ip16 := ip.To16()
+	if ip16 == nil {
+		return 0
+	}
+	ipBit := func(i int) bool {
+		return ip16[i/8]>>(7-uint(i%8))&1 == 1
+	}
+
+	r := asmapReader{m: m}
+	remaining := 8 * net.IPv6len
+	var defaultASN uint32
+	for r.pos < r.len() {
+		switch asmapOpcode(r.decode(0, asmapTypeBitSizes)) {
+		case asmapReturn:
+			return r.decode(1, asmapASNBitSizes)
+
+		case asmapJump:
+			jump := r.decode(17, asmapJumpBitSizes)
+			if ipBit(8*net.IPv6len - remaining) {
+				r.pos += int(jump)
+			}
+			remaining--
+
+		case asmapMatch:
+			match := r.decode(2, asmapMatchBitSizes)
+			matchLen := bits.Len32(match) - 1
+			for i := 0; i < matchLen; i++ {
+				want := match>>uint(matchLen-1-i)&1 == 1
+				if ipBit(8*net.IPv6len-remaining) != want {
+					return defaultASN
+				}
+				remaining--
+			}
+
+		case asmapDefault:
+			defaultASN = r.decode(1, asmapASNBitSizes)
+
+		default:
+			// Not reached since the asmap is known to be sane.
+			return 0
+		}
+	}
+
+	// Not reached since the asmap is known to be sane.
+	return 0
+}
+
+// sane returns whether the asmap is well formed, which means that looking up
+// any IP address returns an ASN without reading past its end.  It also rejects
+// asmaps which are not encoded in their most compact form, following the
+// reference implementation.
+func (m *ASMap) sane() bool {
+	type jump struct {
+		offset    int
+		remaining int
+	}
+
+	r := asmapReader{m: m}
+	remaining := 8 * net.IPv6len
+	var jumps []jump
+	prevOpcode := asmapJump
+	hadIncompleteMatch := false
+	for r.pos < r.len() {
+		// Jumping into the middle of the previous instruction is not
+		// allowed.
+		if len(jumps) > 0 && r.pos >= jumps[len(jumps)-1].offset {
+			return false
+		}
+
+		opcode := r.decode(0, asmapTypeBitSizes)
+		switch asmapOpcode(opcode) {
+		case asmapReturn:
+			// A default followed by a return could be a single
+			// return.
+			if prevOpcode == asmapDefault {
+				return false
+			}
+			if r.decode(1, asmapASNBitSizes) == asmapInvalid {
+				return false
+			}
+
+			// The asmap ends when there is nothing left to jump to,
+			// with at most 7 bits of zero padding.
+			if len(jumps) == 0 {
+				if r.len()-r.pos > 7 {
+					return false
+				}
+				for ; r.pos < r.len(); r.pos++ {
+					if r.bit(r.pos) {
+						return false
+					}
+				}
+				return true
+			}
+
+			// Otherwise, continue as if the last jump was taken,
+			// which must land right after the return.
+			last := jumps[len(jumps)-1]
+			if r.pos != last.offset {
+				return false
+			}
+			remaining = last.remaining
+			jumps = jumps[:len(jumps)-1]
+			prevOpcode = asmapJump
+
+		case asmapJump:
+			offset := r.decode(17, asmapJumpBitSizes)
+			if offset == asmapInvalid || int(offset) > r.len()-r.pos {
+				return false
+			}
+			if remaining == 0 {
+				return false
+			}
+			remaining--
+			target := r.pos + int(offset)
+			if len(jumps) > 0 && target >= jumps[len(jumps)-1].offset {
+				return false
+			}
+			jumps = append(jumps, jump{target, remaining})
+			prevOpcode = asmapJump
+
+		case asmapMatch:
+			match := r.decode(2, asmapMatchBitSizes)
+			if match == asmapInvalid {
+				return false
+			}
+			matchLen := bits.Len32(match) - 1
+
+			// At most one match of a sequence may be shorter than
+			// the maximum.
+			if prevOpcode != asmapMatch {
+				hadIncompleteMatch = false
+			}
+			if matchLen < 8 && hadIncompleteMatch {
+				return false
+			}
+			hadIncompleteMatch = matchLen < 8
+			if remaining < matchLen {
+				return false
+			}
+			remaining -= matchLen
+			prevOpcode = asmapMatch
+
+		case asmapDefault:
+			// Successive defaults could be a single default.
+			if prevOpcode == asmapDefault {
+				return false
+			}
+			if r.decode(1, asmapASNBitSizes) == asmapInvalid {
+				return false
+			}
+			prevOpcode = asmapDefault
+
+		default:
+			// The instruction straddles the end of the asmap.
+			return false
+		}
+	}
+
+	// The asmap ended without a final return.
+	return false
+}
+
+// asmapReader reads the bits of an asmap, least significant bit of each byte
+// first.
+type asmapReader struct {
+	m   *ASMap
+	pos int
+}
+
+// len returns the number of bits of the asmap.
+func (r *asmapReader) len() int {
+	return 8 * len(r.m.data)
+}
+
+// bit returns the bit of the asmap at the passed position.
+func (r *asmapReader) bit(pos int) bool {
+	return r.m.data[pos/8]>>uint(pos%8)&1 == 1
+}
+
+// decode decodes the value at the current position of the asmap, which is at
+// least minVal and encoded with one of the passed numbers of bits, and moves
+// past it.  It returns asmapInvalid when the value straddles the end of the
+// asmap.
+func (r *asmapReader) decode(minVal uint32, bitSizes []uint8) uint32 {
+	val := minVal
+	for i, size := range bitSizes {
+		// Each bit of the prefix selects the next size, except that
+		// the last size is selected once all others were skipped.
+		next := false
+		if i != len(bitSizes)-1 {
+			if r.pos >= r.len() {
+				break
+			}
+			next = r.bit(r.pos)
+			r.pos++
+		}
+		if next {
+			val += 1 << size
+			continue
+		}
+
+		for b := uint8(0); b < size; b++ {
+			if r.pos >= r.len() {
+				return asmapInvalid
+			}
+			if r.bit(r.pos) {
+				val += 1 << (size - 1 - b)
+			}
+			r.pos++
+		}
+		return val
+	}
+	return asmapInvalid
+}
@@ -0,0 +1,258 @@
+// Copyright (c) 2013-2016 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package addrmgr_test
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"testing"
+
+	"github.com/eager7/dashd/addrmgr"
+	"github.com/eager7/dashd/wire"
+)
+
+// asmapNode is a node of the binary trie of IP address bits encoded by an
+// asmap.  A node with an ASN is a leaf, and a missing child is unmapped.
+type asmapNode struct {
+	asn      uint32
+	children [2]*asmapNode
+}
+
+// insert maps the passed prefix of the passed IP address to the passed ASN.
+func (n *asmapNode) insert(ip net.IP, prefixLen int, asn uint32) {
+	ip16 := ip.To16()
+	for i := 0; i < prefixLen; i++ {
+		bit := ip16[i/8] >> uint(7-i%8) & 1
+		if n.children[bit] == nil {
+			n.children[bit] = &asmapNode{}
+		}
+		n = n.children[bit]
+	}
+	n.asn = asn
+}
+
+// asmapWriter writes the bits of an asmap, least significant bit of each byte
+// first.
+type asmapWriter struct {
+	bits []bool
+}
+
+// writeBits writes the passed number of low bits of the passed value, most
+// significant bit first.
+func (w *asmapWriter) writeBits(val uint32, n uint8) {
+	for i := int(n) - 1; i >= 0; i-- {
+		w.bits = append(w.bits, val>>uint(i)&1 == 1)
+	}
+}
+
+// encode writes the passed value, which is at least minVal, with the smallest
+// of the passed numbers of bits it fits in.
+func (w *asmapWriter) encode(val, minVal uint32, bitSizes []uint8) {
+	val -= minVal
+	for i, size := range bitSizes {
+		if i != len(bitSizes)-1 {
+			if val >= 1<<size {
+				w.bits = append(w.bits, true)
+				val -= 1 << size
+				continue
+			}
+			w.bits = append(w.bits, false)
+		}
+		w.writeBits(val, size)
+		return
+	}
+}
+
+// bytes returns the written bits padded with zeros to a whole number of bytes.
+func (w *asmapWriter) bytes() []byte {
+	data := make([]byte, (len(w.bits)+7)/8)
+	for i, bit := range w.bits {
+		if bit {
+			data[i/8] |= 1 << uint(i%8)
+		}
+	}
+	return data
+}
+
+// The encodings of the values of an asmap.
+var (
+	testASNBitSizes   = []uint8{15, 16, 17, 18, 19, 20, 21, 22, 23, 24}
+	testMatchBitSizes = []uint8{1, 2, 3, 4, 5, 6, 7, 8}
+	testJumpBitSizes  = []uint8{5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16,
+		17, 18, 19, 20, 21, 22, 23, 24, 25, 26, 27, 28, 29, 30}
+)
+
+// compile writes the instructions looking up the IP addresses of the trie
+// rooted at the passed node.  It only uses the return, jump and match
+// instructions, so unmapped addresses fall back to the initial default ASN 0.
+func compile(w *asmapWriter, n *asmapNode) {
+	// Follow the nodes with a single child, matching up to 8 bits at once.
+	var match []uint32
+	for n.asn == 0 && (n.children[0] == nil) != (n.children[1] == nil) {
+		bit := uint32(0)
+		if n.children[1] != nil {
+			bit = 1
+		}
+		match = append(match, bit)
+		n = n.children[bit]
+	}
+	for len(match) > 0 {
+		size := len(match)
+		if size > 8 {
+			size = 8
+		}
+		val := uint32(1)
+		for _, bit := range match[:size] {
+			val = val<<1 | bit
+		}
+		match = match[size:]
+
+		w.writeBits(0x3, 2)
+		w.writeBits(0, 1)
+		w.encode(val, 2, testMatchBitSizes)
+	}
+
+	if n.asn != 0 {
+		w.writeBits(0, 1)
+		w.encode(n.asn, 1, testASNBitSizes)
+		return
+	}
+
+	// The jump skips the instructions of the first child when the next
+	// bit of the address is set.
+	var left asmapWriter
+	compile(&left, n.children[0])
+	w.writeBits(0x2, 2)
+	w.encode(uint32(len(left.bits)), 17, testJumpBitSizes)
+	w.bits = append(w.bits, left.bits...)
+	compile(w, n.children[1])
+}
+
+// testASMap returns an asmap of a few IPv4 and IPv6 prefixes.
+func testASMap(t *testing.T) *addrmgr.ASMap {
+	root := &asmapNode{}
+	root.insert(net.ParseIP("1.2.0.0"), 96+16, 100)
+	root.insert(net.ParseIP("1.3.0.0"), 96+16, 200)
+	root.insert(net.ParseIP("8.8.0.0"), 96+16, 15169)
+	root.insert(net.ParseIP("2a00:1450::"), 32, 15169)
+	root.insert(net.ParseIP("2a02:4780::"), 32, 400000)
+
+	var w asmapWriter
+	compile(&w, root)
+	m, err := addrmgr.NewASMap(w.bytes())
+	if err != nil {
+		t.Fatalf("NewASMap: unexpected error %v", err)
+	}
+	return m
+}
+
+// TestASMap ensures IP addresses are mapped to the ASN of the longest prefix
+// of the asmap they match, and that malformed asmaps are rejected.
+func TestASMap(t *testing.T) {
+	m := testASMap(t)
+
+	tests := []struct {
+		ip   string
+		want uint32
+	}{
+		{"1.2.3.4", 100},
+		{"1.2.255.255", 100},
+		{"1.3.0.1", 200},
+		{"1.4.0.1", 0},
+		{"8.8.8.8", 15169},
+		{"9.9.9.9", 0},
+		{"2a00:1450:4001::1", 15169},
+		{"2a02:4780:1::1", 400000},
+		{"2a03::1", 0},
+	}
+	for _, test := range tests {
+		got := m.ASN(net.ParseIP(test.ip))
+		if got != test.want {
+			t.Errorf("ASN %s: got %d, want %d", test.ip, got,
+				test.want)
+		}
+	}
+
+	var w asmapWriter
+	compile(&w, &asmapNode{asn: 1})
+	valid := w.bytes()
+	invalid := map[string][]byte{
+		"empty":     nil,
+		"truncated": valid[:len(valid)-1],
+		"padded":    append(valid, 0),
+		"garbage":   {0xff, 0xff, 0xff, 0xff},
+	}
+	for name, data := range invalid {
+		if _, err := addrmgr.NewASMap(data); err == nil {
+			t.Errorf("NewASMap %s: no error for invalid asmap", name)
+		}
+	}
+}
+
+// TestGroupKeyASMap ensures the address manager groups addresses by autonomous
+// system when they are mapped by its asmap, and by prefix otherwise.
+func TestGroupKeyASMap(t *testing.T) {
+	n := addrmgr.New("testgroupkeyasmap", lookupFunc)
+	n.SetASMap(testASMap(t))
+
+	tests := []struct {
+		ip        string
+		wantASN   uint32
+		wantGroup string
+	}{
+		{"8.8.8.8", 15169, "as15169"},
+		{"2a00:1450:4001::1", 15169, "as15169"},
+		{"1.2.3.4", 100, "as100"},
+		{"1.4.0.1", 0, "1.4.0.0"},
+		{"10.0.0.1", 0, "unroutable"},
+	}
+	for _, test := range tests {
+		na := wire.NewNetAddressV2IPPort(net.ParseIP(test.ip), 9999,
+			wire.SFNodeNetwork)
+		if got := n.ASN(na); got != test.wantASN {
+			t.Errorf("ASN %s: got %d, want %d", test.ip, got,
+				test.wantASN)
+		}
+		if got := n.GroupKey(na); got != test.wantGroup {
+			t.Errorf("GroupKey %s: got %q, want %q", test.ip, got,
+				test.wantGroup)
+		}
+	}
+}
+
+// TestASMapRebucket ensures addresses saved without an asmap are kept when
+// they are loaded with one.
+func TestASMapRebucket(t *testing.T) {
+	dir, err := ioutil.TempDir("", "asmaprebucket")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	n := addrmgr.New(dir, lookupFunc)
+	n.Start()
+	src := wire.NewNetAddressV2IPPort(net.ParseIP("173.194.115.66"), 9999,
+		wire.SFNodeNetwork)
+	for _, ip := range []string{"8.8.8.8", "8.8.4.4", "1.2.3.4", "1.4.0.1"} {
+		na := wire.NewNetAddressV2IPPort(net.ParseIP(ip), 9999,
+			wire.SFNodeNetwork)
+		n.AddAddress(na, src)
+		if ip == "8.8.8.8" {
+			n.Good(na)
+		}
+	}
+	if err := n.Stop(); err != nil {
+		t.Fatalf("Address Manager failed to stop: %v", err)
+	}
+
+	n = addrmgr.New(dir, lookupFunc)
+	n.SetASMap(testASMap(t))
+	n.Start()
+	defer n.Stop()
+	if got := n.NumAddresses(); got != 4 {
+		t.Fatalf("NumAddresses: got %d addresses, want 4", got)
+	}
+}
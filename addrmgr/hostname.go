@@ -0,0 +1,135 @@
+// Copyright (c) 2013-2014 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package addrmgr
+
+import (
+	"bytes"
+	"encoding/base32"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/eager7/dashd/wire"
+	"golang.org/x/crypto/sha3"
+)
+
+const (
+	// onionSuffix is the suffix of the host names of Tor hidden services.
+	onionSuffix = ".onion"
+
+	// i2pSuffix is the suffix of the base32 host names of I2P destinations.
+	i2pSuffix = ".b32.i2p"
+
+	// torV3Version is the version byte of a Tor v3 hidden service address.
+	torV3Version = 0x03
+
+	// torV3ChecksumLen is the size of the checksum of a Tor v3 hidden
+	// service address.
+	torV3ChecksumLen = 2
+)
+
+// torV3ChecksumPrefix is prepended to the public key and version of a Tor v3
+// hidden service address to compute its checksum.
+var torV3ChecksumPrefix = []byte(".onion checksum")
+
+// hostEncoding is the base32 encoding used by the host names of Tor hidden
+// services and I2P destinations.  Go uses capitals (as does the rfc) but Tor
+// and I2P use lowercase, so the host names are switched case when encoding and
+// decoding.
+var hostEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// torV3Checksum returns the checksum of the passed Tor v3 public key as defined
+// by the Tor rendezvous specification.
+func torV3Checksum(pubKey []byte) []byte {
+	h := sha3.New256()
+	h.Write(torV3ChecksumPrefix)
+	h.Write(pubKey)
+	h.Write([]byte{torV3Version})
+	return h.Sum(nil)[:torV3ChecksumLen]
+}
+
+// ParseOnionHost decodes the passed .onion host name and returns the network of
+// the hidden service, which is either Tor v2 or Tor v3, along with its address
+// as it is relayed in addrv2 messages.  The checksum and version of Tor v3
+// addresses are validated.
+func ParseOnionHost(host string) (wire.NetworkID, []byte, error) {
+	if !strings.HasSuffix(host, onionSuffix) {
+		return 0, nil, fmt.Errorf("%s is not an onion address", host)
+	}
+	data, err := hostEncoding.DecodeString(
+		strings.ToUpper(strings.TrimSuffix(host, onionSuffix)))
+	if err != nil {
+		return 0, nil, fmt.Errorf("malformed onion address %s: %v", host,
+			err)
+	}
+
+	switch len(data) {
+	case wire.NetTorV2.AddrSize():
+		return wire.NetTorV2, data, nil
+
+	// A Tor v3 address is made of the public key followed by its checksum
+	// and version.
+	case wire.NetTorV3.AddrSize() + torV3ChecksumLen + 1:
+		pubKey := data[:wire.NetTorV3.AddrSize()]
+		checksum := data[len(pubKey) : len(pubKey)+torV3ChecksumLen]
+		if data[len(data)-1] != torV3Version {
+			return 0, nil, fmt.Errorf("onion address %s has "+
+				"unknown version %d", host, data[len(data)-1])
+		}
+		if !bytes.Equal(checksum, torV3Checksum(pubKey)) {
+			return 0, nil, fmt.Errorf("onion address %s has an "+
+				"invalid checksum", host)
+		}
+		return wire.NetTorV3, pubKey, nil
+	}
+
+	return 0, nil, fmt.Errorf("onion address %s has an invalid length", host)
+}
+
+// parseI2PHost decodes the passed .b32.i2p host name and returns the hash of
+// the I2P destination.
+func parseI2PHost(host string) ([]byte, error) {
+	data, err := hostEncoding.DecodeString(
+		strings.ToUpper(strings.TrimSuffix(host, i2pSuffix)))
+	if err != nil {
+		return nil, fmt.Errorf("malformed i2p address %s: %v", host, err)
+	}
+	if len(data) != wire.NetI2P.AddrSize() {
+		return nil, fmt.Errorf("i2p address %s has an invalid length",
+			host)
+	}
+	return data, nil
+}
+
+// hostString returns the host name of the passed address.  This is the IP
+// address for IPv4, IPv6 and CJDNS addresses, the .onion host name for Tor
+// addresses and the .b32.i2p host name for I2P addresses.
+func hostString(na *wire.NetAddressV2) string {
+	switch na.NetID {
+	case wire.NetIPv4, wire.NetIPv6, wire.NetCJDNS:
+		return net.IP(na.Addr).String()
+
+	case wire.NetTorV2:
+		return strings.ToLower(hostEncoding.EncodeToString(na.Addr)) +
+			onionSuffix
+
+	case wire.NetTorV3:
+		data := make([]byte, 0, len(na.Addr)+torV3ChecksumLen+1)
+		data = append(data, na.Addr...)
+		data = append(data, torV3Checksum(na.Addr)...)
+		data = append(data, torV3Version)
+		return strings.ToLower(hostEncoding.EncodeToString(data)) +
+			onionSuffix
+
+	case wire.NetI2P:
+		return strings.ToLower(hostEncoding.EncodeToString(na.Addr)) +
+			i2pSuffix
+	}
+
+	// Addresses of unknown networks are never stored by the address
+	// manager, but still get a unique name.
+	return fmt.Sprintf("net%d-%s", na.NetID, hex.EncodeToString(na.Addr))
+}
@@ -71,19 +71,6 @@ var (
 	// rfc6598Net specifies the IPv4 block as defined by RFC6598 (100.64.0.0/10)
 	rfc6598Net = ipNet("100.64.0.0", 10, 32)
 
-	// onionCatNet defines the IPv6 address block used to support Tor.
-	// bitcoind encodes a .onion address as a 16 byte number by decoding the
-	// address prior to the .onion (i.e. the key hash) base32 into a ten
-	// byte number. It then stores the first 6 bytes of the address as
-	// 0xfd, 0x87, 0xd8, 0x7e, 0xeb, 0x43.
-	//
-	// This is the same range used by OnionCat, which is part part of the
-	// RFC4193 unique local IPv6 range.
-	//
-	// In summary the format is:
-	// { magic 6 bytes, 10 bytes base32 decode of key hash }
-	onionCatNet = ipNet("fd87:d87e:eb43::", 48, 128)
-
 	// zero4Net defines the IPv4 address block for address staring with 0
 	// (0.0.0.0/8).
 	zero4Net = ipNet("0.0.0.0", 8, 32)
@@ -100,29 +87,50 @@ func ipNet(ip string, ones, bits int) net.IPNet {
 }
 
 // IsIPv4 returns whether or not the given address is an IPv4 address.
-func IsIPv4(na *wire.NetAddress) bool {
-	return na.IP.To4() != nil
+func IsIPv4(na *wire.NetAddressV2) bool {
+	return na.NetID == wire.NetIPv4
 }
 
 // IsLocal returns whether or not the given address is a local address.
-func IsLocal(na *wire.NetAddress) bool {
-	return na.IP.IsLoopback() || zero4Net.Contains(na.IP)
+func IsLocal(na *wire.NetAddressV2) bool {
+	return na.IP().IsLoopback() || zero4Net.Contains(na.IP())
+}
+
+// IsTorV2 returns whether or not the passed address is a Tor v2 hidden service
+// address.  Peers which don't support addrv2 messages relay these in the IPv6
+// range used by OnionCat (fd87:d87e:eb43::/48).
+func IsTorV2(na *wire.NetAddressV2) bool {
+	return na.NetID == wire.NetTorV2
+}
+
+// IsTorV3 returns whether or not the passed address is a Tor v3 hidden service
+// address.
+func IsTorV3(na *wire.NetAddressV2) bool {
+	return na.NetID == wire.NetTorV3
+}
+
+// IsTor returns whether or not the passed address is a Tor hidden service
+// address of any version.
+func IsTor(na *wire.NetAddressV2) bool {
+	return IsTorV2(na) || IsTorV3(na)
+}
+
+// IsI2P returns whether or not the passed address is an I2P address.
+func IsI2P(na *wire.NetAddressV2) bool {
+	return na.NetID == wire.NetI2P
 }
 
-// IsOnionCatTor returns whether or not the passed address is in the IPv6 range
-// used by bitcoin to support Tor (fd87:d87e:eb43::/48).  Note that this range
-// is the same range used by OnionCat, which is part of the RFC4193 unique local
-// IPv6 range.
-func IsOnionCatTor(na *wire.NetAddress) bool {
-	return onionCatNet.Contains(na.IP)
+// IsCJDNS returns whether or not the passed address is a CJDNS address.
+func IsCJDNS(na *wire.NetAddressV2) bool {
+	return na.NetID == wire.NetCJDNS
 }
 
 // IsRFC1918 returns whether or not the passed address is part of the IPv4
 // private network address space as defined by RFC1918 (10.0.0.0/8,
 // 172.16.0.0/12, or 192.168.0.0/16).
-func IsRFC1918(na *wire.NetAddress) bool {
+func IsRFC1918(na *wire.NetAddressV2) bool {
 	for _, rfc := range rfc1918Nets {
-		if rfc.Contains(na.IP) {
+		if rfc.Contains(na.IP()) {
 			return true
 		}
 	}
@@ -131,58 +139,58 @@ func IsRFC1918(na *wire.NetAddress) bool {
 
 // IsRFC2544 returns whether or not the passed address is part of the IPv4
 // address space as defined by RFC2544 (198.18.0.0/15)
-func IsRFC2544(na *wire.NetAddress) bool {
-	return rfc2544Net.Contains(na.IP)
+func IsRFC2544(na *wire.NetAddressV2) bool {
+	return rfc2544Net.Contains(na.IP())
 }
 
 // IsRFC3849 returns whether or not the passed address is part of the IPv6
 // documentation range as defined by RFC3849 (2001:DB8::/32).
-func IsRFC3849(na *wire.NetAddress) bool {
-	return rfc3849Net.Contains(na.IP)
+func IsRFC3849(na *wire.NetAddressV2) bool {
+	return rfc3849Net.Contains(na.IP())
 }
 
 // IsRFC3927 returns whether or not the passed address is part of the IPv4
 // autoconfiguration range as defined by RFC3927 (169.254.0.0/16).
-func IsRFC3927(na *wire.NetAddress) bool {
-	return rfc3927Net.Contains(na.IP)
+func IsRFC3927(na *wire.NetAddressV2) bool {
+	return rfc3927Net.Contains(na.IP())
 }
 
 // IsRFC3964 returns whether or not the passed address is part of the IPv6 to
 // IPv4 encapsulation range as defined by RFC3964 (2002::/16).
-func IsRFC3964(na *wire.NetAddress) bool {
-	return rfc3964Net.Contains(na.IP)
+func IsRFC3964(na *wire.NetAddressV2) bool {
+	return rfc3964Net.Contains(na.IP())
 }
 
 // IsRFC4193 returns whether or not the passed address is part of the IPv6
 // unique local range as defined by RFC4193 (FC00::/7).
-func IsRFC4193(na *wire.NetAddress) bool {
-	return rfc4193Net.Contains(na.IP)
+func IsRFC4193(na *wire.NetAddressV2) bool {
+	return rfc4193Net.Contains(na.IP())
 }
 
 // IsRFC4380 returns whether or not the passed address is part of the IPv6
 // teredo tunneling over UDP range as defined by RFC4380 (2001::/32).
-func IsRFC4380(na *wire.NetAddress) bool {
-	return rfc4380Net.Contains(na.IP)
+func IsRFC4380(na *wire.NetAddressV2) bool {
+	return rfc4380Net.Contains(na.IP())
 }
 
 // IsRFC4843 returns whether or not the passed address is part of the IPv6
 // ORCHID range as defined by RFC4843 (2001:10::/28).
-func IsRFC4843(na *wire.NetAddress) bool {
-	return rfc4843Net.Contains(na.IP)
+func IsRFC4843(na *wire.NetAddressV2) bool {
+	return rfc4843Net.Contains(na.IP())
 }
 
 // IsRFC4862 returns whether or not the passed address is part of the IPv6
 // stateless address autoconfiguration range as defined by RFC4862 (FE80::/64).
-func IsRFC4862(na *wire.NetAddress) bool {
-	return rfc4862Net.Contains(na.IP)
+func IsRFC4862(na *wire.NetAddressV2) bool {
+	return rfc4862Net.Contains(na.IP())
 }
 
 // IsRFC5737 returns whether or not the passed address is part of the IPv4
 // documentation address space as defined by RFC5737 (192.0.2.0/24,
 // 198.51.100.0/24, 203.0.113.0/24)
-func IsRFC5737(na *wire.NetAddress) bool {
+func IsRFC5737(na *wire.NetAddressV2) bool {
 	for _, rfc := range rfc5737Net {
-		if rfc.Contains(na.IP) {
+		if rfc.Contains(na.IP()) {
 			return true
 		}
 	}
@@ -192,90 +200,104 @@ func IsRFC5737(na *wire.NetAddress) bool {
 
 // IsRFC6052 returns whether or not the passed address is part of the IPv6
 // well-known prefix range as defined by RFC6052 (64:FF9B::/96).
-func IsRFC6052(na *wire.NetAddress) bool {
-	return rfc6052Net.Contains(na.IP)
+func IsRFC6052(na *wire.NetAddressV2) bool {
+	return rfc6052Net.Contains(na.IP())
 }
 
 // IsRFC6145 returns whether or not the passed address is part of the IPv6 to
 // IPv4 translated address range as defined by RFC6145 (::FFFF:0:0:0/96).
-func IsRFC6145(na *wire.NetAddress) bool {
-	return rfc6145Net.Contains(na.IP)
+func IsRFC6145(na *wire.NetAddressV2) bool {
+	return rfc6145Net.Contains(na.IP())
 }
 
 // IsRFC6598 returns whether or not the passed address is part of the IPv4
 // shared address space specified by RFC6598 (100.64.0.0/10)
-func IsRFC6598(na *wire.NetAddress) bool {
-	return rfc6598Net.Contains(na.IP)
+func IsRFC6598(na *wire.NetAddressV2) bool {
+	return rfc6598Net.Contains(na.IP())
 }
 
 // IsValid returns whether or not the passed address is valid.  The address is
 // considered invalid under the following circumstances:
 // IPv4: It is either a zero or all bits set address.
 // IPv6: It is either a zero or RFC3849 documentation address.
-func IsValid(na *wire.NetAddress) bool {
-	// IsUnspecified returns if address is 0, so only all bits set, and
-	// RFC3849 need to be explicitly checked.
-	return na.IP != nil && !(na.IP.IsUnspecified() ||
-		na.IP.Equal(net.IPv4bcast))
+// Others: Its network is unknown or its size doesn't match the network.
+func IsValid(na *wire.NetAddressV2) bool {
+	if ip := na.IP(); ip != nil {
+		// IsUnspecified returns if address is 0, so only all bits set,
+		// and RFC3849 need to be explicitly checked.
+		return !(ip.IsUnspecified() || ip.Equal(net.IPv4bcast))
+	}
+
+	size := na.NetID.AddrSize()
+	return size != 0 && len(na.Addr) == size
 }
 
 // IsRoutable returns whether or not the passed address is routable over
 // the public internet.  This is true as long as the address is valid and is not
 // in any reserved ranges.
-func IsRoutable(na *wire.NetAddress) bool {
+func IsRoutable(na *wire.NetAddressV2) bool {
 	return IsValid(na) && !(IsRFC1918(na) || IsRFC2544(na) ||
 		IsRFC3927(na) || IsRFC4862(na) || IsRFC3849(na) ||
 		IsRFC4843(na) || IsRFC5737(na) || IsRFC6598(na) ||
-		IsLocal(na) || (IsRFC4193(na) && !IsOnionCatTor(na)))
+		IsLocal(na) || IsRFC4193(na))
 }
 
 // GroupKey returns a string representing the network group an address is part
 // of.  This is the /16 for IPv4, the /32 (/36 for he.net) for IPv6, the string
-// "local" for a local address, the string "tor:key" where key is the /4 of the
-// onion address for Tor address, and the string "unroutable" for an unroutable
-// address.
-func GroupKey(na *wire.NetAddress) string {
+// "local" for a local address, the string "tor:key", "i2p:key" or "cjdns:key"
+// where key is the /4 of the address for Tor, I2P and CJDNS addresses, and the
+// string "unroutable" for an unroutable address.
+func GroupKey(na *wire.NetAddressV2) string {
 	if IsLocal(na) {
 		return "local"
 	}
 	if !IsRoutable(na) {
 		return "unroutable"
 	}
+	switch {
+	case IsTor(na):
+		// group is keyed off the first 4 bits of the actual onion key.
+		return fmt.Sprintf("tor:%d", na.Addr[0]&((1<<4)-1))
+	case IsI2P(na):
+		return fmt.Sprintf("i2p:%d", na.Addr[0]&((1<<4)-1))
+	case IsCJDNS(na):
+		// All CJDNS addresses start with 0xfc, so the group is keyed
+		// off the first 4 bits after it.
+		return fmt.Sprintf("cjdns:%d", na.Addr[1]>>4)
+	}
+
+	ip := na.IP()
 	if IsIPv4(na) {
-		return na.IP.Mask(net.CIDRMask(16, 32)).String()
+		return ip.Mask(net.CIDRMask(16, 32)).String()
 	}
 	if IsRFC6145(na) || IsRFC6052(na) {
 		// last four bytes are the ip address
-		ip := na.IP[12:16]
+		ip := ip[12:16]
 		return ip.Mask(net.CIDRMask(16, 32)).String()
 	}
 
 	if IsRFC3964(na) {
-		ip := na.IP[2:6]
+		ip := ip[2:6]
 		return ip.Mask(net.CIDRMask(16, 32)).String()
 
 	}
 	if IsRFC4380(na) {
 		// teredo tunnels have the last 4 bytes as the v4 address XOR
 		// 0xff.
-		ip := net.IP(make([]byte, 4))
-		for i, byte := range na.IP[12:16] {
-			ip[i] = byte ^ 0xff
+		v4 := net.IP(make([]byte, 4))
+		for i, byte := range ip[12:16] {
+			v4[i] = byte ^ 0xff
 		}
-		return ip.Mask(net.CIDRMask(16, 32)).String()
-	}
-	if IsOnionCatTor(na) {
-		// group is keyed off the first 4 bits of the actual onion key.
-		return fmt.Sprintf("tor:%d", na.IP[6]&((1<<4)-1))
+		return v4.Mask(net.CIDRMask(16, 32)).String()
 	}
 
 	// OK, so now we know ourselves to be a IPv6 address.
 	// bitcoind uses /32 for everything, except for Hurricane Electric's
 	// (he.net) IP range, which it uses /36 for.
 	bits := 32
-	if heNet.Contains(na.IP) {
+	if heNet.Contains(ip) {
 		bits = 36
 	}
 
-	return na.IP.Mask(net.CIDRMask(bits, 128)).String()
+	return ip.Mask(net.CIDRMask(bits, 128)).String()
 }
@@ -0,0 +1,121 @@
+// Copyright (c) 2013-2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+
+	"github.com/eager7/dashd/addrmgr"
+	"github.com/eager7/dashd/wire"
+)
+
+// anchorsFilename is the name of the file in the data directory which holds the
+// addresses of the block-relay-only peers connected at shutdown.  They are the
+// anchors connected to first on the next start, so an attacker can't take over
+// all of the outbound connections while the node restarts.
+const anchorsFilename = "anchors.dat"
+
+// writeAnchors writes the passed anchors to the file with the passed path.
+// They are serialized like the addresses of an addrv2 message.
+func writeAnchors(path string, anchors []*wire.NetAddressV2) error {
+	msg := wire.NewMsgAddrV2()
+	if err := msg.AddAddresses(anchors...); err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	err := msg.BtcEncode(&buf, wire.ProtocolVersion, wire.BaseEncoding)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, buf.Bytes(), 0600)
+}
+
+// readAnchors reads the anchors written by writeAnchors from the file with the
+// passed path and removes it, so the anchors are not used again after a crash
+// when they may no longer be connected.  No anchors are returned when the file
+// does not exist.
+func readAnchors(path string) ([]*wire.NetAddressV2, error) {
+	serialized, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Remove(path); err != nil {
+		return nil, err
+	}
+
+	var msg wire.MsgAddrV2
+	err = msg.BtcDecode(bytes.NewReader(serialized), wire.ProtocolVersion,
+		wire.BaseEncoding)
+	if err != nil {
+		return nil, err
+	}
+	return msg.AddrList, nil
+}
+
+// saveAnchors writes the addresses of the connected block-relay-only peers to
+// the anchors file.  It is invoked from the peerHandler goroutine on shutdown.
+func (s *server) saveAnchors(state *peerState) {
+	var anchors []*wire.NetAddressV2
+	for _, sp := range state.outboundPeers {
+		if !sp.blockRelayOnly || !sp.VerAckReceived() || sp.NA() == nil {
+			continue
+		}
+		anchors = append(anchors, sp.NA())
+		if len(anchors) == defaultTargetBlockRelayOnly {
+			break
+		}
+	}
+	if len(anchors) == 0 {
+		return
+	}
+
+	path := filepath.Join(cfg.DataDir, anchorsFilename)
+	if err := writeAnchors(path, anchors); err != nil {
+		srvrLog.Errorf("Unable to save anchors to %s: %v", path, err)
+		return
+	}
+	srvrLog.Infof("Saved %d block-relay-only %s to %s", len(anchors),
+		pickNoun(uint64(len(anchors)), "anchor", "anchors"), path)
+}
+
+// loadAnchors returns the addresses of the block-relay-only peers saved by
+// saveAnchors on the last shutdown.  Addresses of networks which can't be
+// dialed are skipped.
+func (s *server) loadAnchors() []net.Addr {
+	path := filepath.Join(cfg.DataDir, anchorsFilename)
+	anchors, err := readAnchors(path)
+	if err != nil {
+		srvrLog.Warnf("Unable to load anchors from %s: %v", path, err)
+		return nil
+	}
+
+	addrs := make([]net.Addr, 0, len(anchors))
+	for _, na := range anchors {
+		if (cfg.NoOnion && addrmgr.IsTor(na)) ||
+			(s.i2p == nil && addrmgr.IsI2P(na)) {
+			continue
+		}
+		addr, err := addrStringToNetAddr(addrmgr.NetAddressKey(na))
+		if err != nil {
+			srvrLog.Debugf("Skipping anchor %s: %v",
+				addrmgr.NetAddressKey(na), err)
+			continue
+		}
+		addrs = append(addrs, addr)
+	}
+	if len(addrs) > 0 {
+		srvrLog.Infof("Loaded %d block-relay-only %s from %s", len(addrs),
+			pickNoun(uint64(len(addrs)), "anchor", "anchors"), path)
+	}
+	return addrs
+}
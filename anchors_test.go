@@ -0,0 +1,62 @@
+// Copyright (c) 2013-2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/eager7/dashd/wire"
+)
+
+// TestAnchors ensures anchors written to a file are read back as they were,
+// and that the file is removed once read.
+func TestAnchors(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "anchors")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+	path := filepath.Join(tempDir, anchorsFilename)
+
+	// No anchors are read when there is no file.
+	anchors, err := readAnchors(path)
+	if err != nil {
+		t.Fatalf("readAnchors: unexpected error %v", err)
+	}
+	if len(anchors) != 0 {
+		t.Fatalf("readAnchors: got %d anchors without a file",
+			len(anchors))
+	}
+
+	ipv4 := wire.NewNetAddressV2IPPort(net.ParseIP("173.194.115.66"),
+		9999, wire.SFNodeNetwork)
+	ipv4.Timestamp = time.Unix(0x495fab29, 0)
+	torV3 := wire.NewNetAddressV2(wire.NetTorV3, make([]byte, 32), 9999,
+		wire.SFNodeNetwork|wire.SFNodeP2PV2)
+	torV3.Timestamp = time.Unix(0x495fab29, 0)
+	want := []*wire.NetAddressV2{ipv4, torV3}
+
+	if err := writeAnchors(path, want); err != nil {
+		t.Fatalf("writeAnchors: unexpected error %v", err)
+	}
+	anchors, err = readAnchors(path)
+	if err != nil {
+		t.Fatalf("readAnchors: unexpected error %v", err)
+	}
+	if !reflect.DeepEqual(anchors, want) {
+		t.Fatalf("readAnchors: got %v, want %v", anchors, want)
+	}
+
+	// The anchors are only used once.
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("anchors file not removed: %v", err)
+	}
+}
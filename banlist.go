@@ -0,0 +1,268 @@
+// Copyright (c) 2013-2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// banListFilename is the name of the file in the data directory which
+	// holds the banned subnets, so bans survive a restart.
+	banListFilename = "banlist.json"
+
+	// banListVersion is the version of the serialized ban list.
+	banListVersion = 1
+)
+
+// banEntry is a ban of a subnet, or of a single Tor or I2P host which has no
+// subnet.
+type banEntry struct {
+	address string
+	subnet  *net.IPNet
+	created time.Time
+	until   time.Time
+}
+
+// matches returns whether the ban applies to the passed host, which is either
+// an IP address or a Tor or I2P host.
+func (e *banEntry) matches(host string) bool {
+	if e.subnet == nil {
+		return strings.EqualFold(e.address, host)
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && e.subnet.Contains(ip)
+}
+
+// serializedBanEntry is the form of a ban entry stored in the ban list file.
+type serializedBanEntry struct {
+	Address     string `json:"address"`
+	BanCreated  int64  `json:"ban_created"`
+	BannedUntil int64  `json:"banned_until"`
+}
+
+// serializedBanList is the form of the ban list stored in the ban list file.
+type serializedBanList struct {
+	Version    int                   `json:"version"`
+	BannedNets []*serializedBanEntry `json:"banned_nets"`
+}
+
+// parseBanAddress parses the passed address of a ban, which is an IP address,
+// a subnet in CIDR notation, or a Tor or I2P host.  It returns the address in
+// its canonical form, where IP addresses are subnets of a single address,
+// along with the subnet, which is nil for Tor and I2P hosts.
+func parseBanAddress(addr string) (string, *net.IPNet, error) {
+	if strings.Contains(addr, "/") {
+		_, subnet, err := net.ParseCIDR(addr)
+		if err != nil {
+			return "", nil, fmt.Errorf("invalid subnet %q", addr)
+		}
+		return subnet.String(), subnet, nil
+	}
+
+	if ip := net.ParseIP(addr); ip != nil {
+		bits := 8 * net.IPv6len
+		if ip4 := ip.To4(); ip4 != nil {
+			ip = ip4
+			bits = 8 * net.IPv4len
+		}
+		subnet := &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}
+		return subnet.String(), subnet, nil
+	}
+
+	host := strings.ToLower(addr)
+	if strings.HasSuffix(host, ".onion") || strings.HasSuffix(host, ".i2p") {
+		return host, nil, nil
+	}
+	return "", nil, fmt.Errorf("invalid IP address or subnet %q", addr)
+}
+
+// banList is the list of banned subnets.  It is only accessed from the
+// peerHandler goroutine, so it is not safe for concurrent access.
+type banList struct {
+	path    string
+	entries map[string]*banEntry
+}
+
+// newBanList returns an empty ban list which is saved to the file with the
+// passed path.
+func newBanList(path string) *banList {
+	return &banList{
+		path:    path,
+		entries: make(map[string]*banEntry),
+	}
+}
+
+// add bans the subnet with the passed canonical address until the passed time,
+// replacing any existing ban of the same subnet, and returns the new ban.
+func (bl *banList) add(addr string, subnet *net.IPNet, until time.Time) *banEntry {
+	e := &banEntry{
+		address: addr,
+		subnet:  subnet,
+		created: time.Now(),
+		until:   until,
+	}
+	bl.entries[addr] = e
+	return e
+}
+
+// has returns whether the subnet with the passed canonical address is banned.
+func (bl *banList) has(addr string) bool {
+	e, ok := bl.entries[addr]
+	return ok && time.Now().Before(e.until)
+}
+
+// remove lifts the ban of the subnet with the passed canonical address.  It
+// returns whether the subnet was banned.
+func (bl *banList) remove(addr string) bool {
+	banned := bl.has(addr)
+	delete(bl.entries, addr)
+	return banned
+}
+
+// clear lifts all bans.
+func (bl *banList) clear() {
+	bl.entries = make(map[string]*banEntry)
+}
+
+// bannedUntil returns the time until which the passed host is banned, and
+// whether it is banned at all.  Expired bans are removed along the way.
+func (bl *banList) bannedUntil(host string) (time.Time, bool) {
+	var until time.Time
+	now := time.Now()
+	for addr, e := range bl.entries {
+		if !e.matches(host) {
+			continue
+		}
+		if !now.Before(e.until) {
+			delete(bl.entries, addr)
+			continue
+		}
+		if e.until.After(until) {
+			until = e.until
+		}
+	}
+	return until, !until.IsZero()
+}
+
+// sweep removes the expired bans.
+func (bl *banList) sweep() {
+	now := time.Now()
+	for addr, e := range bl.entries {
+		if !now.Before(e.until) {
+			delete(bl.entries, addr)
+		}
+	}
+}
+
+// list returns the bans which have not expired yet, ordered by address.
+func (bl *banList) list() []*banEntry {
+	bl.sweep()
+	entries := make([]*banEntry, 0, len(bl.entries))
+	for _, e := range bl.entries {
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].address < entries[j].address
+	})
+	return entries
+}
+
+// load reads the bans saved to the ban list file, skipping those which expired
+// in the meantime.  Nothing is loaded when there is no file.
+func (bl *banList) load() error {
+	serialized, err := ioutil.ReadFile(bl.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var sbl serializedBanList
+	if err := json.Unmarshal(serialized, &sbl); err != nil {
+		return err
+	}
+	if sbl.Version > banListVersion {
+		return fmt.Errorf("unknown version %d of ban list", sbl.Version)
+	}
+
+	now := time.Now()
+	for _, se := range sbl.BannedNets {
+		addr, subnet, err := parseBanAddress(se.Address)
+		if err != nil {
+			return err
+		}
+		until := time.Unix(se.BannedUntil, 0)
+		if !now.Before(until) {
+			continue
+		}
+		bl.entries[addr] = &banEntry{
+			address: addr,
+			subnet:  subnet,
+			created: time.Unix(se.BanCreated, 0),
+			until:   until,
+		}
+	}
+	return nil
+}
+
+// save writes the bans which have not expired yet to the ban list file.  The
+// file is written to a temporary file first and then renamed, so a crash does
+// not leave a partially written ban list behind.
+func (bl *banList) save() error {
+	sbl := serializedBanList{Version: banListVersion}
+	for _, e := range bl.list() {
+		sbl.BannedNets = append(sbl.BannedNets, &serializedBanEntry{
+			Address:     e.address,
+			BanCreated:  e.created.Unix(),
+			BannedUntil: e.until.Unix(),
+		})
+	}
+	serialized, err := json.MarshalIndent(&sbl, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmpPath := bl.path + ".new"
+	if err := ioutil.WriteFile(tmpPath, serialized, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, bl.path)
+}
+
+// loadBanList returns the ban list saved to the data directory.  An empty ban
+// list is returned when it can't be loaded.
+func (s *server) loadBanList() *banList {
+	bl := newBanList(filepath.Join(cfg.DataDir, banListFilename))
+	if err := bl.load(); err != nil {
+		srvrLog.Errorf("Unable to load ban list from %s: %v", bl.path, err)
+		bl.clear()
+		return bl
+	}
+	if len(bl.entries) > 0 {
+		srvrLog.Infof("Loaded %d banned %s from %s", len(bl.entries),
+			pickNoun(uint64(len(bl.entries)), "subnet", "subnets"),
+			bl.path)
+	}
+	return bl
+}
+
+// saveBanList writes the ban list to the data directory.  It is invoked from
+// the peerHandler goroutine whenever the bans change.
+func (s *server) saveBanList(state *peerState) {
+	if err := state.banned.save(); err != nil {
+		srvrLog.Errorf("Unable to save ban list to %s: %v",
+			state.banned.path, err)
+	}
+}
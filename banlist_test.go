@@ -0,0 +1,143 @@
+// Copyright (c) 2013-2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestParseBanAddress ensures the addresses of bans are parsed to their
+// canonical form and invalid ones are rejected.
+func TestParseBanAddress(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		subnet  bool
+		wantErr bool
+	}{
+		{in: "192.168.0.1", want: "192.168.0.1/32", subnet: true},
+		{in: "192.168.0.77/24", want: "192.168.0.0/24", subnet: true},
+		{in: "2001:470::1", want: "2001:470::1/128", subnet: true},
+		{in: "2001:470::/32", want: "2001:470::/32", subnet: true},
+		{in: "::ffff:10.0.0.1", want: "10.0.0.1/32", subnet: true},
+		{in: "ExampleOnionHost.onion", want: "exampleonionhost.onion"},
+		{in: "example.b32.i2p", want: "example.b32.i2p"},
+		{in: "192.168.0.1/33", wantErr: true},
+		{in: "example.com", wantErr: true},
+		{in: "", wantErr: true},
+	}
+
+	for _, test := range tests {
+		addr, subnet, err := parseBanAddress(test.in)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("parseBanAddress %q: no error", test.in)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseBanAddress %q: unexpected error %v", test.in,
+				err)
+			continue
+		}
+		if addr != test.want {
+			t.Errorf("parseBanAddress %q: got %q, want %q", test.in,
+				addr, test.want)
+		}
+		if (subnet != nil) != test.subnet {
+			t.Errorf("parseBanAddress %q: got subnet %v", test.in,
+				subnet)
+		}
+	}
+}
+
+// TestBanList ensures bans apply to the hosts of their subnet until they
+// expire, and that they survive being saved and loaded.
+func TestBanList(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "banlist")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+	path := filepath.Join(tempDir, banListFilename)
+
+	bl := newBanList(path)
+	ban := func(s string, until time.Time) {
+		addr, subnet, err := parseBanAddress(s)
+		if err != nil {
+			t.Fatalf("parseBanAddress %q: unexpected error %v", s, err)
+		}
+		bl.add(addr, subnet, until)
+	}
+	now := time.Now()
+	ban("10.1.0.0/16", now.Add(time.Hour))
+	ban("10.1.2.3", now.Add(2*time.Hour))
+	ban("2001:470::/32", now.Add(time.Hour))
+	ban("exampleonionhost.onion", now.Add(time.Hour))
+	ban("192.168.0.1", now.Add(-time.Second))
+
+	tests := []struct {
+		host   string
+		banned bool
+	}{
+		{"10.1.255.255", true},
+		{"10.2.0.1", false},
+		{"2001:470:1::1", true},
+		{"2001:471::1", false},
+		{"ExampleOnionHost.onion", true},
+		{"otheronionhost.onion", false},
+		{"192.168.0.1", false},
+	}
+	for _, test := range tests {
+		if _, banned := bl.bannedUntil(test.host); banned != test.banned {
+			t.Errorf("bannedUntil %s: got banned %v, want %v",
+				test.host, banned, test.banned)
+		}
+	}
+
+	// The longest ban of a host applies.
+	until, _ := bl.bannedUntil("10.1.2.3")
+	if !until.Equal(now.Add(2 * time.Hour)) {
+		t.Errorf("bannedUntil: got %v, want %v", until,
+			now.Add(2*time.Hour))
+	}
+
+	// The expired ban was removed while checking the banned hosts.
+	if bl.has("192.168.0.1/32") || len(bl.entries) != 4 {
+		t.Fatalf("expired ban not removed: %v", bl.entries)
+	}
+
+	if err := bl.save(); err != nil {
+		t.Fatalf("save: unexpected error %v", err)
+	}
+	loaded := newBanList(path)
+	if err := loaded.load(); err != nil {
+		t.Fatalf("load: unexpected error %v", err)
+	}
+	want := bl.list()
+	got := loaded.list()
+	if len(got) != len(want) {
+		t.Fatalf("load: got %d bans, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i].address != want[i].address ||
+			got[i].until.Unix() != want[i].until.Unix() ||
+			got[i].created.Unix() != want[i].created.Unix() {
+
+			t.Errorf("load: got ban %+v, want %+v", got[i], want[i])
+		}
+	}
+
+	if !loaded.remove("10.1.0.0/16") || loaded.remove("10.1.0.0/16") {
+		t.Fatal("remove: unexpected result")
+	}
+	if _, banned := loaded.bannedUntil("10.1.2.4"); banned {
+		t.Fatal("bannedUntil: host banned after its ban was removed")
+	}
+}
@@ -68,6 +68,7 @@ func (b *BlockChain) maybeAcceptBlock(block *dashutil.Block, flags BehaviorFlags
 	newNode.status = statusDataStored
 
 	b.index.AddNode(newNode)
+	delete(b.headerIndex, newNode.hash)
 	err = b.index.flushToDB()
 	if err != nil {
 		return false, err
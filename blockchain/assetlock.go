@@ -0,0 +1,316 @@
+// Copyright (c) 2013-2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/eager7/dashd/chaincfg/chainhash"
+	"github.com/eager7/dashd/database"
+	"github.com/eager7/dashd/txscript"
+	"github.com/eager7/dashd/wire"
+	"github.com/eager7/dashutil"
+)
+
+const (
+	// MaxAssetUnlockOutputs is the maximum number of outputs allowed in an
+	// asset unlock transaction.
+	MaxAssetUnlockOutputs = 32
+
+	// AssetUnlockExpiryBlocks is the number of blocks after the height
+	// requested by Platform during which an asset unlock transaction may
+	// be mined.
+	AssetUnlockExpiryBlocks = 48
+
+	// assetUnlockRequestIDPrefix is the prefix hashed together with the
+	// withdrawal index to form the signing request ID of an asset unlock.
+	assetUnlockRequestIDPrefix = "plwdtx"
+
+	// assetUnlockActiveQuorums is the number of the most recently mined
+	// platform quorums which may sign asset unlocks.
+	assetUnlockActiveQuorums = 2
+)
+
+// calcQuorumSignHash returns the hash which a quorum of the given LLMQ type
+// signs when it reaches consensus on the message identified by msgHash for
+// the request identified by requestID.
+func calcQuorumSignHash(llmqType uint8, quorumHash, requestID, msgHash *chainhash.Hash) chainhash.Hash {
+	var buf bytes.Buffer
+	buf.Grow(1 + 3*chainhash.HashSize)
+	buf.WriteByte(llmqType)
+	buf.Write(quorumHash[:])
+	buf.Write(requestID[:])
+	buf.Write(msgHash[:])
+	return chainhash.DoubleHashH(buf.Bytes())
+}
+
+// IsAssetLockTx returns whether or not the passed transaction is an asset lock
+// special transaction.
+func IsAssetLockTx(msgTx *wire.MsgTx) bool {
+	return msgTx.IsSpecial() && msgTx.Type == wire.TxTypeAssetLock
+}
+
+// IsAssetUnlockTx returns whether or not the passed transaction is an asset
+// unlock special transaction.
+func IsAssetUnlockTx(msgTx *wire.MsgTx) bool {
+	return msgTx.IsSpecial() && msgTx.Type == wire.TxTypeAssetUnlock
+}
+
+// assetLockAmount returns the amount locked by the passed asset lock
+// transaction, which is the value of its OP_RETURN output.
+func assetLockAmount(msgTx *wire.MsgTx) int64 {
+	for _, txOut := range msgTx.TxOut {
+		script := txOut.PkScript
+		if len(script) > 0 && script[0] == txscript.OP_RETURN {
+			return txOut.Value
+		}
+	}
+
+	return 0
+}
+
+// assetUnlockAmount returns the amount released from the credit pool by the
+// passed asset unlock transaction, which includes the fee.
+func assetUnlockAmount(msgTx *wire.MsgTx, payload *wire.AssetUnlockPayload) int64 {
+	amount := int64(payload.Fee)
+	for _, txOut := range msgTx.TxOut {
+		amount += txOut.Value
+	}
+
+	return amount
+}
+
+// checkAssetLockSanity performs context free checks on an asset lock
+// transaction.  The transaction must have a single OP_RETURN output without
+// data which holds the locked amount, and the credit outputs of the payload
+// must pay the same amount to pubkey hashes.
+func checkAssetLockSanity(msgTx *wire.MsgTx) error {
+	var lockedAmount int64
+	for _, txOut := range msgTx.TxOut {
+		script := txOut.PkScript
+		if len(script) == 0 || script[0] != txscript.OP_RETURN {
+			continue
+		}
+
+		if len(script) != 2 || script[1] != txscript.OP_0 {
+			str := "asset lock OP_RETURN output must not carry data"
+			return ruleError(ErrBadAssetLock, str)
+		}
+		if txOut.Value == 0 {
+			str := "asset lock OP_RETURN output must lock a " +
+				"non-zero amount"
+			return ruleError(ErrBadAssetLock, str)
+		}
+		if lockedAmount != 0 {
+			str := "asset lock has more than one OP_RETURN output"
+			return ruleError(ErrBadAssetLock, str)
+		}
+		lockedAmount = txOut.Value
+	}
+	if lockedAmount == 0 {
+		str := "asset lock does not have an OP_RETURN output"
+		return ruleError(ErrBadAssetLock, str)
+	}
+
+	payload, err := msgTx.AssetLockPayload()
+	if err != nil {
+		str := fmt.Sprintf("invalid asset lock payload: %v", err)
+		return ruleError(ErrBadAssetLock, str)
+	}
+	if payload.Version == 0 ||
+		payload.Version > wire.AssetLockPayloadVersion {
+
+		str := fmt.Sprintf("asset lock payload version %d is not "+
+			"supported", payload.Version)
+		return ruleError(ErrBadAssetLock, str)
+	}
+	if len(payload.CreditOutputs) == 0 {
+		str := "asset lock payload has no credit outputs"
+		return ruleError(ErrBadAssetLock, str)
+	}
+
+	var creditAmount int64
+	for _, creditOut := range payload.CreditOutputs {
+		if creditOut.Value <= 0 || creditOut.Value > dashutil.MaxSatoshi ||
+			creditAmount+creditOut.Value > dashutil.MaxSatoshi {
+
+			str := fmt.Sprintf("asset lock credit output value of "+
+				"%v is out of range", creditOut.Value)
+			return ruleError(ErrBadAssetLock, str)
+		}
+		creditAmount += creditOut.Value
+
+		class := txscript.GetScriptClass(creditOut.PkScript)
+		if class != txscript.PubKeyHashTy {
+			str := fmt.Sprintf("asset lock credit output script "+
+				"is %v instead of %v", class,
+				txscript.PubKeyHashTy)
+			return ruleError(ErrBadAssetLock, str)
+		}
+	}
+	if creditAmount != lockedAmount {
+		str := fmt.Sprintf("asset lock credit outputs pay %v instead "+
+			"of the locked amount of %v", creditAmount,
+			lockedAmount)
+		return ruleError(ErrBadAssetLock, str)
+	}
+
+	return nil
+}
+
+// checkAssetUnlockSanity performs context free checks on an asset unlock
+// transaction.
+func checkAssetUnlockSanity(msgTx *wire.MsgTx) error {
+	if len(msgTx.TxIn) != 0 {
+		str := "asset unlock must not have any inputs"
+		return ruleError(ErrBadAssetUnlock, str)
+	}
+	if len(msgTx.TxOut) > MaxAssetUnlockOutputs {
+		str := fmt.Sprintf("asset unlock has %d outputs which is more "+
+			"than the max allowed of %d", len(msgTx.TxOut),
+			MaxAssetUnlockOutputs)
+		return ruleError(ErrBadAssetUnlock, str)
+	}
+
+	payload, err := msgTx.AssetUnlockPayload()
+	if err != nil {
+		str := fmt.Sprintf("invalid asset unlock payload: %v", err)
+		return ruleError(ErrBadAssetUnlock, str)
+	}
+	if payload.Version == 0 ||
+		payload.Version > wire.AssetUnlockPayloadVersion {
+
+		str := fmt.Sprintf("asset unlock payload version %d is not "+
+			"supported", payload.Version)
+		return ruleError(ErrBadAssetUnlock, str)
+	}
+
+	return nil
+}
+
+// AssetUnlockSignHash returns the hash which must be signed by a quorum of the
+// given LLMQ type in order to authorize the passed asset unlock transaction.
+// It commits to the quorum, the withdrawal index and the transaction itself
+// with an empty quorum signature.
+func AssetUnlockSignHash(llmqType uint8, msgTx *wire.MsgTx, payload *wire.AssetUnlockPayload) (chainhash.Hash, error) {
+	// The request ID only commits to the withdrawal index so the same
+	// withdrawal can never be signed twice.
+	var buf bytes.Buffer
+	err := wire.WriteVarString(&buf, 0, assetUnlockRequestIDPrefix)
+	if err != nil {
+		return chainhash.Hash{}, err
+	}
+	var index [8]byte
+	binary.LittleEndian.PutUint64(index[:], payload.Index)
+	buf.Write(index[:])
+	requestID := chainhash.DoubleHashH(buf.Bytes())
+
+	// The message hash is the hash of the transaction with the quorum
+	// signature removed from the payload.
+	unsigned := *payload
+	unsigned.QuorumSig = [wire.QuorumSigSize]byte{}
+	buf.Reset()
+	if err := unsigned.Serialize(&buf); err != nil {
+		return chainhash.Hash{}, err
+	}
+	txCopy := *msgTx
+	txCopy.Payload = buf.Bytes()
+	msgHash := txCopy.TxHash()
+
+	return calcQuorumSignHash(llmqType, &payload.QuorumHash, &requestID,
+		&msgHash), nil
+}
+
+// checkAssetUnlockContext performs the checks on an asset unlock transaction
+// which depend on the block after the passed node, such as the expiry of the
+// withdrawal and the quorum which signed it, which must be one of the most
+// recently mined platform quorums.
+//
+// The quorum signature itself is not verified.  The public keys of mined
+// quorums are taken from their commitments, which are not verified against the
+// members of the quorums, so any miner could mine a quorum with a key of its
+// choosing and sign withdrawals with it.
+//
+// This function MUST be called with the chain state lock held (for reads).
+func (b *BlockChain) checkAssetUnlockContext(dbTx database.Tx, prevNode *blockNode, msgTx *wire.MsgTx, payload *wire.AssetUnlockPayload) error {
+	// The withdrawal must be mined within the expiry window starting at
+	// the height requested by Platform.
+	requestedHeight := int64(payload.RequestedHeight)
+	if int64(prevNode.height) < requestedHeight ||
+		int64(prevNode.height) >= requestedHeight+AssetUnlockExpiryBlocks {
+
+		str := fmt.Sprintf("asset unlock with index %d requested at "+
+			"height %d is not valid after height %d", payload.Index,
+			requestedHeight, prevNode.height)
+		return ruleError(ErrBadAssetUnlock, str)
+	}
+
+	// The quorum which signed the withdrawal must be one of the most
+	// recently mined platform quorums.
+	llmqType := b.chainParams.LLMQTypePlatform
+	quorums, err := b.scanQuorums(dbTx, prevNode, llmqType,
+		assetUnlockActiveQuorums)
+	if err != nil {
+		return err
+	}
+	for _, quorum := range quorums {
+		if quorum.quorumHash == payload.QuorumHash {
+			return nil
+		}
+	}
+	str := fmt.Sprintf("asset unlock with index %d is signed by quorum %v "+
+		"which is not one of the %d most recent platform quorums",
+		payload.Index, payload.QuorumHash, assetUnlockActiveQuorums)
+	return ruleError(ErrBadAssetUnlock, str)
+}
+
+// CheckAssetUnlockTx ensures the passed asset unlock transaction is valid for
+// inclusion in the block after the end of the current best chain.  Since the
+// quorum signature is not verified, passing the checks does not mean the
+// withdrawal has been authorized by Platform.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) CheckAssetUnlockTx(tx *dashutil.Tx) error {
+	b.chainLock.RLock()
+	defer b.chainLock.RUnlock()
+
+	msgTx := tx.MsgTx()
+	if !IsAssetUnlockTx(msgTx) {
+		str := fmt.Sprintf("transaction %v is not an asset unlock",
+			tx.Hash())
+		return ruleError(ErrBadAssetUnlock, str)
+	}
+
+	tip := b.bestChain.Tip()
+	if tip.height+1 < b.chainParams.V20Height {
+		str := fmt.Sprintf("asset unlock transactions are not active "+
+			"before height %d", b.chainParams.V20Height)
+		return ruleError(ErrSpecialTxNotActive, str)
+	}
+
+	if err := checkAssetUnlockSanity(msgTx); err != nil {
+		return err
+	}
+	payload, err := msgTx.AssetUnlockPayload()
+	if err != nil {
+		return err
+	}
+
+	return b.db.View(func(dbTx database.Tx) error {
+		used, err := b.isAssetUnlockIndexUsed(dbTx, tip, payload.Index)
+		if err != nil {
+			return err
+		}
+		if used {
+			str := fmt.Sprintf("asset unlock index %d has already "+
+				"been used", payload.Index)
+			return ruleError(ErrBadAssetUnlock, str)
+		}
+
+		return b.checkAssetUnlockContext(dbTx, tip, msgTx, payload)
+	})
+}
@@ -0,0 +1,196 @@
+// Copyright (c) 2013-2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/eager7/dashd/chaincfg/chainhash"
+	"github.com/eager7/dashd/wire"
+)
+
+// assumeValidMinDepth is the number of blocks worth of work, at the difficulty
+// of the best header, which must sit on top of a block before its scripts are
+// assumed to be valid.  It is about two weeks of blocks like in Dash Core,
+// which discourages attempts to get an invalid block assumed valid by
+// convincing users to configure it.
+const assumeValidMinDepth = 8064
+
+const (
+	// maxHeaderForkDepth is the number of blocks below the tip of the best
+	// known chain of headers after which the headers which are not part of
+	// it are removed, since their blocks are unlikely to ever be needed.
+	// It matches the number of blocks pruned nodes keep to handle
+	// reorganizations.
+	maxHeaderForkDepth = MinBlocksToKeep
+
+	// headerPruneInterval is the number of blocks the best known chain of
+	// headers grows by between the removals of the stale headers.
+	headerPruneInterval = 2016
+)
+
+// ProcessBlockHeader adds the passed header to the known block headers when it
+// connects to a known block or header and passes the proof of work and context
+// checks.  The best known header chain, the one with the most work, is used to
+// decide whether the blocks below the assumed valid block can be trusted, so
+// the headers of blocks which have not been downloaded yet can be added ahead
+// of the blocks themselves.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) ProcessBlockHeader(header *wire.BlockHeader) error {
+	b.chainLock.Lock()
+	defer b.chainLock.Unlock()
+
+	hash := header.BlockHash()
+	if b.lookupHeaderNode(&hash) != nil {
+		return nil
+	}
+
+	prevNode := b.lookupHeaderNode(&header.PrevBlock)
+	if prevNode == nil {
+		str := fmt.Sprintf("previous block %s is unknown",
+			header.PrevBlock)
+		return ruleError(ErrPreviousBlockUnknown, str)
+	} else if b.index.NodeStatus(prevNode).KnownInvalid() {
+		str := fmt.Sprintf("previous block %s is known to be invalid",
+			header.PrevBlock)
+		return ruleError(ErrInvalidAncestorBlock, str)
+	}
+
+	err := checkBlockHeaderSanity(header, b.chainParams.PowLimit,
+		b.timeSource, BFNone)
+	if err != nil {
+		return err
+	}
+	err = b.checkBlockHeaderContext(header, prevNode, BFNone)
+	if err != nil {
+		return err
+	}
+
+	node := newBlockNode(header, prevNode)
+	b.headerIndex[hash] = node
+	if node.workSum.Cmp(b.bestHeaders.Tip().workSum) > 0 {
+		// Remove the stale headers when the best known chain of headers
+		// is reorganized to another fork and periodically as it grows.
+		prevTip := b.bestHeaders.Tip()
+		b.bestHeaders.SetTip(node)
+		if prevNode.hash != prevTip.hash ||
+			node.height%headerPruneInterval == 0 {
+
+			b.pruneHeaders()
+		}
+	}
+	return nil
+}
+
+// pruneHeaders removes the headers added with ProcessBlockHeader which are not
+// part of the best known chain of headers and are more than maxHeaderForkDepth
+// blocks below its tip.  The nodes of headers are replaced by the nodes of
+// their blocks once they are processed, so the hashes are compared instead of
+// the nodes.
+//
+// This function MUST be called with the chain state lock held (for writes).
+func (b *BlockChain) pruneHeaders() {
+	headers := b.bestHeaderChain()
+	pruneHeight := headers.Height() - maxHeaderForkDepth
+	for hash, node := range b.headerIndex {
+		if node.height >= pruneHeight {
+			continue
+		}
+		if headerNode := headers.NodeByHeight(node.height); headerNode != nil &&
+			headerNode.hash == hash {
+
+			continue
+		}
+		delete(b.headerIndex, hash)
+	}
+}
+
+// HaveHeader returns whether or not the header of the block with the passed
+// hash is known, either because the block has been processed or because the
+// header has been added with ProcessBlockHeader.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) HaveHeader(hash *chainhash.Hash) bool {
+	b.chainLock.RLock()
+	defer b.chainLock.RUnlock()
+
+	return b.lookupHeaderNode(hash) != nil
+}
+
+// IsKnownInvalid returns whether or not the block with the passed hash has been
+// processed and found to be invalid, either because it failed validation or
+// because one of its ancestors did.  Blocks which were rejected before being
+// added to the block index, such as those with a malformed body, are not known
+// to be invalid since a valid block with the same header may still exist.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) IsKnownInvalid(hash *chainhash.Hash) bool {
+	node := b.index.LookupNode(hash)
+	return node != nil && b.index.NodeStatus(node).KnownInvalid()
+}
+
+// AssumeValid returns the hash of the block whose ancestors are assumed to have
+// valid scripts, or nil when all scripts are checked.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) AssumeValid() *chainhash.Hash {
+	if b.assumeValid == zeroHash {
+		return nil
+	}
+
+	hash := b.assumeValid
+	return &hash
+}
+
+// lookupHeaderNode returns the node of the block or header with the passed
+// hash, or nil when it is unknown.
+//
+// This function MUST be called with the chain state lock held (for reads).
+func (b *BlockChain) lookupHeaderNode(hash *chainhash.Hash) *blockNode {
+	if node := b.index.LookupNode(hash); node != nil {
+		return node
+	}
+	return b.headerIndex[*hash]
+}
+
+// isAssumedValid returns whether or not the scripts of the passed block can be
+// assumed to be valid instead of being checked.  This is the case when the
+// block is an ancestor of the assumed valid block, which is part of the best
+// known header chain and has enough work on top of it.
+//
+// This function MUST be called with the chain state lock held (for reads).
+func (b *BlockChain) isAssumedValid(node *blockNode) bool {
+	if b.assumeValid == zeroHash {
+		return false
+	}
+
+	headers := b.bestHeaderChain()
+
+	// Both the assumed valid block and the passed block must be part of
+	// the best known header chain.  Since the nodes of headers are replaced
+	// by the nodes of their blocks once they are processed, the hashes are
+	// compared instead of the nodes.
+	assumeValidNode := b.lookupHeaderNode(&b.assumeValid)
+	if assumeValidNode == nil || node.height > assumeValidNode.height {
+		return false
+	}
+	headerNode := headers.NodeByHeight(assumeValidNode.height)
+	if headerNode == nil || headerNode.hash != b.assumeValid {
+		return false
+	}
+	headerNode = headers.NodeByHeight(node.height)
+	if headerNode == nil || headerNode.hash != node.hash {
+		return false
+	}
+
+	// Require enough work on top of the assumed valid block.
+	tip := headers.Tip()
+	minWork := new(big.Int).Mul(CalcWork(tip.bits),
+		big.NewInt(assumeValidMinDepth))
+	work := new(big.Int).Sub(tip.workSum, assumeValidNode.workSum)
+	return work.Cmp(minWork) >= 0
+}
@@ -0,0 +1,83 @@
+// Copyright (c) 2013-2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"testing"
+
+	"github.com/eager7/dashd/chaincfg"
+	"github.com/eager7/dashd/chaincfg/chainhash"
+	"github.com/eager7/dashd/wire"
+)
+
+// TestIsAssumedValid ensures the scripts of blocks are only assumed valid when
+// they are ancestors of the assumed valid block and enough work has been done
+// on top of it.
+func TestIsAssumedValid(t *testing.T) {
+	t.Parallel()
+
+	// workNodes returns a chain of nodes extending the passed parent which
+	// all have the same non-zero work.
+	workNodes := func(parent *blockNode, numNodes int) []*blockNode {
+		nodes := make([]*blockNode, numNodes)
+		tip := parent
+		for i := range nodes {
+			header := wire.BlockHeader{
+				Bits:  0x207fffff,
+				Nonce: testNoncePrng.Uint32(),
+			}
+			if tip != nil {
+				header.PrevBlock = tip.hash
+			}
+			nodes[i] = newBlockNode(&header, tip)
+			tip = nodes[i]
+		}
+		return nodes
+	}
+
+	// Construct a main chain with enough work on top of the block at
+	// height 10 and a side chain forking from it at height 5.
+	mainNodes := workNodes(nil, assumeValidMinDepth+20)
+	sideNodes := workNodes(mainNodes[4], 3)
+	b := &BlockChain{
+		index:       newBlockIndex(nil, &chaincfg.RegressionNetParams),
+		bestChain:   newChainView(tstTip(mainNodes[:12])),
+		bestHeaders: newChainView(tstTip(mainNodes)),
+	}
+	for _, node := range mainNodes[:12] {
+		b.index.AddNode(node)
+	}
+	for _, node := range sideNodes {
+		b.index.AddNode(node)
+	}
+	b.headerIndex = make(map[chainhash.Hash]*blockNode)
+	for _, node := range mainNodes[12:] {
+		b.headerIndex[node.hash] = node
+	}
+
+	tests := []struct {
+		name        string
+		assumeValid *blockNode
+		node        *blockNode
+		want        bool
+	}{
+		{"disabled", nil, mainNodes[5], false},
+		{"ancestor", mainNodes[10], mainNodes[5], true},
+		{"assumed valid block", mainNodes[10], mainNodes[10], true},
+		{"descendant", mainNodes[10], mainNodes[11], false},
+		{"side chain", mainNodes[10], sideNodes[1], false},
+		{"not enough work", mainNodes[30], mainNodes[5], false},
+		{"side chain assumed valid", sideNodes[2], sideNodes[0], false},
+	}
+	for _, test := range tests {
+		b.assumeValid = zeroHash
+		if test.assumeValid != nil {
+			b.assumeValid = test.assumeValid.hash
+		}
+		if got := b.isAssumedValid(test.node); got != test.want {
+			t.Errorf("%s: got %v, want %v", test.name, got, test.want)
+		}
+	}
+}
@@ -101,6 +101,11 @@ type BlockChain struct {
 	sigCache            *txscript.SigCache
 	indexManager        IndexManager
 	hashCache           *txscript.HashCache
+	pruneTarget         uint64
+	utxoCache           *utxoCache
+	historyCache        *utxoCache
+	assumeValid         chainhash.Hash
+	utxoSnapshots       []chaincfg.UtxoSnapshot
 
 	// The following fields are calculated based upon the provided chain
 	// parameters.  They are also set when the instance is created and
@@ -126,6 +131,34 @@ type BlockChain struct {
 	index     *blockIndex
 	bestChain *chainView
 
+	// These fields track the headers of blocks which have not necessarily
+	// been processed yet.  They are protected by the chain lock.
+	//
+	// headerIndex houses the nodes of headers added with
+	// ProcessBlockHeader until their blocks are added to the block index.
+	//
+	// bestHeaders tracks the best known chain of headers, which may be
+	// ahead of the best chain.
+	headerIndex map[chainhash.Hash]*blockNode
+	bestHeaders *chainView
+
+	// These fields track the validation of the blocks below the base of a
+	// loaded utxo snapshot.  They are protected by the chain lock.
+	//
+	// snapshotBase is the block the snapshot was taken at.  It is nil when
+	// no snapshot has been loaded or the history below it has been
+	// validated.
+	//
+	// snapshotContentHash is the content hash of the snapshot, which the
+	// utxo set resulting from the history has to match.
+	//
+	// historyTip is the last validated block below the snapshot base.  It
+	// moves beyond the base while the optional indexes are caught up with
+	// the main chain after the history has been validated.
+	snapshotBase        *blockNode
+	snapshotContentHash chainhash.Hash
+	historyTip          *blockNode
+
 	// These fields are related to handling of orphan blocks.  They are
 	// protected by a combination of the chain lock and the orphan lock.
 	orphanLock   sync.RWMutex
@@ -152,6 +185,11 @@ type BlockChain struct {
 	stateLock     sync.RWMutex
 	stateSnapshot *BestState
 
+	// lowestAvailHeight is the height of the lowest block of the main chain
+	// which is still stored.  It is only non-zero when blocks have been
+	// pruned and is protected by the state lock.
+	lowestAvailHeight int32
+
 	// The following caches are used to efficiently keep track of the
 	// current deployment threshold state of each rule change deployment.
 	//
@@ -166,8 +204,12 @@ type BlockChain struct {
 	//
 	// deploymentCaches caches the current deployment threshold state for
 	// blocks in each of the actively defined deployments.
+	//
+	// ehfCaches caches the current state of each of the defined EHF
+	// deployments which are enabled by masternode hard fork signals.
 	warningCaches    []thresholdStateCache
 	deploymentCaches []thresholdStateCache
+	ehfCaches        []ehfStateCache
 
 	// The following fields are used to determine if certain warnings have
 	// already been shown.
@@ -608,6 +650,8 @@ func (b *BlockChain) connectBlock(node *blockNode, block *dashutil.Block,
 		curTotalTxns+numTxns, node.CalcPastMedianTime())
 
 	// Atomically insert info into the database.
+	var pruned []chainhash.Hash
+	flushUtxos := b.utxoCache.needsFlush()
 	err = b.db.Update(func(dbTx database.Tx) error {
 		// Update best block state.
 		err := dbPutBestState(dbTx, state, node.workSum)
@@ -622,12 +666,15 @@ func (b *BlockChain) connectBlock(node *blockNode, block *dashutil.Block,
 			return err
 		}
 
-		// Update the utxo set using the state of the utxo view.  This
-		// entails removing all of the utxos spent and adding the new
-		// ones created by the block.
-		err = dbPutUtxoView(dbTx, view)
-		if err != nil {
-			return err
+		// Write the changes to the utxo set held by the utxo cache along
+		// with those of the block to the database when the cache is due
+		// to be flushed.  Otherwise, the changes of the block are only
+		// added to the cache once the transaction is committed.
+		if flushUtxos {
+			err = b.utxoCache.flush(dbTx, view, &node.hash)
+			if err != nil {
+				return err
+			}
 		}
 
 		// Update the transaction spend journal by adding a record for
@@ -637,21 +684,70 @@ func (b *BlockChain) connectBlock(node *blockNode, block *dashutil.Block,
 			return err
 		}
 
+		// Update the statistics of the utxo set with the outputs
+		// created and spent by the block.
+		err = connectUtxoStats(dbTx, utxoMuHashKeyName, node, block,
+			stxos)
+		if err != nil {
+			return err
+		}
+
+		// Update the credit pool and the used asset unlock indexes
+		// with the asset locks and unlocks in the block.
+		err = b.connectCreditPool(dbTx, node, block)
+		if err != nil {
+			return err
+		}
+
+		// Update the masternode hard fork signals with those in the
+		// block.
+		err = b.connectMnHfSignals(dbTx, node, block)
+		if err != nil {
+			return err
+		}
+
+		// Record the quorums whose commitments are mined by the block.
+		err = b.connectMinedQuorums(dbTx, node, block)
+		if err != nil {
+			return err
+		}
+
 		// Allow the index manager to call each of the currently active
 		// optional indexes with the block being connected so they can
-		// update themselves accordingly.
-		if b.indexManager != nil {
+		// update themselves accordingly.  The indexes follow the
+		// history below a loaded utxo snapshot instead until it has
+		// been validated.
+		if b.indexManager != nil && b.snapshotBase == nil {
 			err := b.indexManager.ConnectBlock(dbTx, block, stxos)
 			if err != nil {
 				return err
 			}
 		}
 
+		// Delete the oldest blocks when the stored blocks exceed the
+		// prune target.  Nothing is deleted until the history below a
+		// loaded utxo snapshot has been validated, since the indexes
+		// are caught up with the blocks after it then.
+		if b.pruneTarget != 0 && b.snapshotBase == nil {
+			pruned, err = b.pruneBlocks(dbTx, node)
+			if err != nil {
+				return err
+			}
+		}
+
 		return nil
 	})
 	if err != nil {
 		return err
 	}
+	if flushUtxos {
+		b.utxoCache.flushed(&node.hash)
+	} else {
+		b.utxoCache.commit(view)
+	}
+	if len(pruned) > 0 {
+		b.markPruned(pruned)
+	}
 
 	// Prune fully spent entries and mark all entries in the view unmodified
 	// now that the modifications have been committed to the database.
@@ -734,10 +830,13 @@ func (b *BlockChain) disconnectBlock(node *blockNode, block *dashutil.Block, vie
 			return err
 		}
 
-		// Update the utxo set using the state of the utxo view.  This
-		// entails restoring all of the utxos spent and removing the new
-		// ones created by the block.
-		err = dbPutUtxoView(dbTx, view)
+		// Update the utxo set using the state of the utxo cache and
+		// view.  This entails restoring all of the utxos spent and
+		// removing the new ones created by the block.  The utxo cache is
+		// always flushed here since the spend journal entry needed to
+		// disconnect the block again after an unclean shutdown is
+		// removed.
+		err = b.utxoCache.flush(dbTx, view, &prevNode.hash)
 		if err != nil {
 			return err
 		}
@@ -756,10 +855,36 @@ func (b *BlockChain) disconnectBlock(node *blockNode, block *dashutil.Block, vie
 			return err
 		}
 
+		// Revert the statistics of the utxo set to those as of the
+		// previous block.
+		err = disconnectUtxoStats(dbTx, node, block, stxos)
+		if err != nil {
+			return err
+		}
+
+		// Remove the credit pool of the block along with the asset
+		// unlock indexes it used.
+		err = dbRemoveCreditPool(dbTx, block)
+		if err != nil {
+			return err
+		}
+
+		// Remove the masternode hard fork signals as of the block.
+		err = dbRemoveMnHfSignals(dbTx, block.Hash())
+		if err != nil {
+			return err
+		}
+
+		// Remove the quorums whose commitments are mined by the block.
+		err = dbRemoveMinedQuorums(dbTx, node.height, block)
+		if err != nil {
+			return err
+		}
+
 		// Allow the index manager to call each of the currently active
 		// optional indexes with the block being disconnected so they
 		// can update themselves accordingly.
-		if b.indexManager != nil {
+		if b.indexManager != nil && b.snapshotBase == nil {
 			err := b.indexManager.DisconnectBlock(dbTx, block, stxos)
 			if err != nil {
 				return err
@@ -772,6 +897,8 @@ func (b *BlockChain) disconnectBlock(node *blockNode, block *dashutil.Block, vie
 		return err
 	}
 
+	b.utxoCache.flushed(&prevNode.hash)
+
 	// Prune fully spent entries and mark all entries in the view unmodified
 	// now that the modifications have been committed to the database.
 	view.commit()
@@ -886,7 +1013,7 @@ func (b *BlockChain) reorganizeChain(detachNodes, attachNodes *list.List) error
 
 		// Load all of the utxos referenced by the block that aren't
 		// already in the view.
-		err = view.fetchInputUtxos(b.db, block)
+		err = view.fetchInputUtxos(b.utxoCache, block)
 		if err != nil {
 			return err
 		}
@@ -906,7 +1033,7 @@ func (b *BlockChain) reorganizeChain(detachNodes, attachNodes *list.List) error
 		detachBlocks = append(detachBlocks, block)
 		detachSpentTxOuts = append(detachSpentTxOuts, stxos)
 
-		err = view.disconnectTransactions(b.db, block, stxos)
+		err = view.disconnectTransactions(b.utxoCache, block, stxos)
 		if err != nil {
 			return err
 		}
@@ -953,7 +1080,7 @@ func (b *BlockChain) reorganizeChain(detachNodes, attachNodes *list.List) error
 		// checkConnectBlock gets skipped, we still need to update the UTXO
 		// view.
 		if b.index.NodeStatus(n).KnownValid() {
-			err = view.fetchInputUtxos(b.db, block)
+			err = view.fetchInputUtxos(b.utxoCache, block)
 			if err != nil {
 				return err
 			}
@@ -1005,14 +1132,14 @@ func (b *BlockChain) reorganizeChain(detachNodes, attachNodes *list.List) error
 
 		// Load all of the utxos referenced by the block that aren't
 		// already in the view.
-		err := view.fetchInputUtxos(b.db, block)
+		err := view.fetchInputUtxos(b.utxoCache, block)
 		if err != nil {
 			return err
 		}
 
 		// Update the view to unspend all of the spent txos and remove
 		// the utxos created by the block.
-		err = view.disconnectTransactions(b.db, block,
+		err = view.disconnectTransactions(b.utxoCache, block,
 			detachSpentTxOuts[i])
 		if err != nil {
 			return err
@@ -1032,7 +1159,7 @@ func (b *BlockChain) reorganizeChain(detachNodes, attachNodes *list.List) error
 
 		// Load all of the utxos referenced by the block that aren't
 		// already in the view.
-		err := view.fetchInputUtxos(b.db, block)
+		err := view.fetchInputUtxos(b.utxoCache, block)
 		if err != nil {
 			return err
 		}
@@ -1131,7 +1258,7 @@ func (b *BlockChain) connectBestChain(node *blockNode, block *dashutil.Block, fl
 		// utxos, spend them, and add the new utxos being created by
 		// this block.
 		if fastAdd {
-			err := view.fetchInputUtxos(b.db, block)
+			err := view.fetchInputUtxos(b.utxoCache, block)
 			if err != nil {
 				return false, err
 			}
@@ -1678,6 +1805,14 @@ type Config struct {
 	// checkpoints.
 	Checkpoints []chaincfg.Checkpoint
 
+	// UtxoSnapshots hold caller-defined snapshots of the utxo set the
+	// chain may be bootstrapped from in addition to the snapshots pinned
+	// by the UtxoSnapshots in ChainParams.
+	//
+	// This field can be nil if the caller does not wish to specify any
+	// snapshots.
+	UtxoSnapshots []chaincfg.UtxoSnapshot
+
 	// TimeSource defines the median time source to use for things such as
 	// block processing and determining whether or not the chain is current.
 	//
@@ -1711,6 +1846,27 @@ type Config struct {
 	// This field can be nil if the caller is not interested in using a
 	// signature cache.
 	HashCache *txscript.HashCache
+
+	// UtxoCacheMaxSize defines the maximum size in bytes of the unspent
+	// transaction outputs held in memory before they are written to the
+	// database.  When it is zero, the outputs are written with every
+	// block.
+	UtxoCacheMaxSize uint64
+
+	// AssumeValid defines the hash of a block whose ancestors are assumed to
+	// have valid scripts, so their scripts are not checked once the block
+	// is part of the best known header chain with enough work on top of it.
+	//
+	// This field can be the zero hash to check the scripts of all blocks.
+	AssumeValid chainhash.Hash
+
+	// Prune defines the target size in bytes of the stored blocks.  When
+	// the stored blocks exceed it, the oldest blocks are deleted while the
+	// latest MinBlocksToKeep blocks of the main chain are always kept.
+	//
+	// This field can be zero to keep all blocks.  Otherwise, it must be at
+	// least MinPruneTarget.
+	Prune uint64
 }
 
 // New returns a BlockChain instance using the provided configuration details.
@@ -1725,6 +1881,10 @@ func New(config *Config) (*BlockChain, error) {
 	if config.TimeSource == nil {
 		return nil, AssertError("blockchain.New timesource is nil")
 	}
+	if config.Prune != 0 && config.Prune < MinPruneTarget {
+		return nil, AssertError("blockchain.New prune target is below " +
+			"the minimum")
+	}
 
 	// Generate a checkpoint by height map from the provided checkpoints
 	// and assert the provided checkpoints are sorted by height as required.
@@ -1745,6 +1905,10 @@ func New(config *Config) (*BlockChain, error) {
 	}
 
 	params := config.ChainParams
+	utxoSnapshots := make([]chaincfg.UtxoSnapshot, 0,
+		len(params.UtxoSnapshots)+len(config.UtxoSnapshots))
+	utxoSnapshots = append(utxoSnapshots, params.UtxoSnapshots...)
+	utxoSnapshots = append(utxoSnapshots, config.UtxoSnapshots...)
 	targetTimespan := int64(10*time.Minute / time.Second)
 	targetTimePerBlock := int64(10*time.Minute / time.Second)
 	adjustmentFactor := int64(1000)
@@ -1761,11 +1925,20 @@ func New(config *Config) (*BlockChain, error) {
 		blocksPerRetarget:   int32(targetTimespan / targetTimePerBlock),
 		index:               newBlockIndex(config.DB, params),
 		hashCache:           config.HashCache,
-		bestChain:           newChainView(nil),
-		orphans:             make(map[chainhash.Hash]*orphanBlock),
-		prevOrphans:         make(map[chainhash.Hash][]*orphanBlock),
-		warningCaches:       newThresholdCaches(vbNumBits),
-		deploymentCaches:    newThresholdCaches(1),
+		pruneTarget:         config.Prune,
+		utxoCache: newUtxoCache(config.DB, utxoSetBucketName,
+			utxoStateConsistencyKeyName, config.UtxoCacheMaxSize),
+		historyCache: newUtxoCache(config.DB, historyUtxoSetBucketName,
+			historyUtxoStateConsistencyKeyName, 0),
+		assumeValid:      config.AssumeValid,
+		utxoSnapshots:    utxoSnapshots,
+		bestChain:        newChainView(nil),
+		headerIndex:      make(map[chainhash.Hash]*blockNode),
+		orphans:          make(map[chainhash.Hash]*orphanBlock),
+		prevOrphans:      make(map[chainhash.Hash][]*orphanBlock),
+		warningCaches:    newThresholdCaches(vbNumBits),
+		deploymentCaches: newThresholdCaches(1),
+		ehfCaches:        newEHFStateCaches(chaincfg.DefinedEHFDeployments),
 	}
 
 	// Initialize the chain state from the passed database.  When the db
@@ -1774,12 +1947,37 @@ func New(config *Config) (*BlockChain, error) {
 	if err := b.initChainState(); err != nil {
 		return nil, err
 	}
+	b.bestHeaders = newChainView(b.bestChain.Tip())
+
+	// Determine the lowest block which is still stored when blocks have
+	// been pruned.
+	if err := b.initPruneState(); err != nil {
+		return nil, err
+	}
 
 	// Perform any upgrades to the various chain-specific buckets as needed.
 	if err := b.maybeUpgradeDbBuckets(config.Interrupt); err != nil {
 		return nil, err
 	}
 
+	// Recover the utxo set when the utxo cache was not flushed due to an
+	// unclean shutdown.
+	if err := b.initUtxoState(config.Interrupt); err != nil {
+		return nil, err
+	}
+
+	// Calculate the statistics of the utxo set when they have not been
+	// tracked yet.
+	if err := b.initUtxoStats(); err != nil {
+		return nil, err
+	}
+
+	// Load the progress of validating the history below a loaded utxo
+	// snapshot.
+	if err := b.initHistoryState(); err != nil {
+		return nil, err
+	}
+
 	// Initialize and catch up all of the currently active optional indexes
 	// as needed.
 	if config.IndexManager != nil {
@@ -1789,6 +1987,16 @@ func New(config *Config) (*BlockChain, error) {
 		}
 	}
 
+	// Complete the validation of the history below a loaded utxo snapshot
+	// when all of its blocks were added before the last shutdown.  This
+	// is done once the indexes are initialized, since they are caught up
+	// with the main chain afterwards.
+	if b.snapshotBase != nil {
+		if err := b.maybeFinishHistory(); err != nil {
+			return nil, err
+		}
+	}
+
 	// Initialize rule change threshold state caches.
 	if err := b.initThresholdCaches(); err != nil {
 		return nil, err
@@ -67,6 +67,27 @@ var (
 	// unspent transaction output set.
 	utxoSetBucketName = []byte("utxosetv2")
 
+	// utxoStateConsistencyKeyName is the name of the db key used to store
+	// the hash of the block the utxo set in the database is consistent
+	// with.  It lags behind the best chain state while the utxo cache holds
+	// changes which have not been flushed yet.
+	utxoStateConsistencyKeyName = []byte("utxostateconsistency")
+
+	// utxoSnapshotBaseKeyName is the name of the db key used to store the
+	// hash of the block a loaded utxo snapshot was taken at along with its
+	// content hash until the history below it has been validated.
+	utxoSnapshotBaseKeyName = []byte("utxosnapshotbase")
+
+	// historyUtxoSetBucketName is the name of the db bucket used to house
+	// the unspent transaction output set resulting from validating the
+	// blocks below the base of a loaded utxo snapshot.
+	historyUtxoSetBucketName = []byte("historyutxoset")
+
+	// historyUtxoStateConsistencyKeyName is the name of the db key used to
+	// store the hash of the last validated block below the base of a
+	// loaded utxo snapshot.
+	historyUtxoStateConsistencyKeyName = []byte("historyutxostateconsistency")
+
 	// byteOrder is the preferred byte order used for serializing numeric
 	// fields for storage in the database.
 	byteOrder = binary.LittleEndian
@@ -692,17 +713,18 @@ func deserializeUtxoEntry(serialized []byte) (*UtxoEntry, error) {
 	return entry, nil
 }
 
-// dbFetchUtxoEntryByHash attempts to find and fetch a utxo for the given hash.
-// It uses a cursor and seek to try and do this as efficiently as possible.
+// dbFetchUtxoEntryByHash attempts to find and fetch a utxo for the given hash
+// from the utxo set housed in the bucket with the passed name.  It uses a
+// cursor and seek to try and do this as efficiently as possible.
 //
 // When there are no entries for the provided hash, nil will be returned for the
 // both the entry and the error.
-func dbFetchUtxoEntryByHash(dbTx database.Tx, hash *chainhash.Hash) (*UtxoEntry, error) {
+func dbFetchUtxoEntryByHash(dbTx database.Tx, bucketName []byte, hash *chainhash.Hash) (*UtxoEntry, error) {
 	// Attempt to find an entry by seeking for the hash along with a zero
 	// index.  Due to the fact the keys are serialized as <hash><index>,
 	// where the index uses an MSB encoding, if there are any entries for
 	// the hash at all, one will be found.
-	cursor := dbTx.Metadata().Bucket(utxoSetBucketName).Cursor()
+	cursor := dbTx.Metadata().Bucket(bucketName).Cursor()
 	key := outpointKey(wire.OutPoint{Hash: *hash, Index: 0})
 	ok := cursor.Seek(*key)
 	recycleOutpointKey(key)
@@ -725,15 +747,16 @@ func dbFetchUtxoEntryByHash(dbTx database.Tx, hash *chainhash.Hash) (*UtxoEntry,
 }
 
 // dbFetchUtxoEntry uses an existing database transaction to fetch the specified
-// transaction output from the utxo set.
+// transaction output from the utxo set housed in the bucket with the passed
+// name.
 //
 // When there is no entry for the provided output, nil will be returned for both
 // the entry and the error.
-func dbFetchUtxoEntry(dbTx database.Tx, outpoint wire.OutPoint) (*UtxoEntry, error) {
+func dbFetchUtxoEntry(dbTx database.Tx, bucketName []byte, outpoint wire.OutPoint) (*UtxoEntry, error) {
 	// Fetch the unspent transaction output information for the passed
 	// transaction output.  Return now when there is no entry.
 	key := outpointKey(outpoint)
-	utxoBucket := dbTx.Metadata().Bucket(utxoSetBucketName)
+	utxoBucket := dbTx.Metadata().Bucket(bucketName)
 	serializedUtxo := utxoBucket.Get(*key)
 	recycleOutpointKey(key)
 	if serializedUtxo == nil {
@@ -766,13 +789,13 @@ func dbFetchUtxoEntry(dbTx database.Tx, outpoint wire.OutPoint) (*UtxoEntry, err
 	return entry, nil
 }
 
-// dbPutUtxoView uses an existing database transaction to update the utxo set
-// in the database based on the provided utxo view contents and state.  In
-// particular, only the entries that have been marked as modified are written
-// to the database.
-func dbPutUtxoView(dbTx database.Tx, view *UtxoViewpoint) error {
-	utxoBucket := dbTx.Metadata().Bucket(utxoSetBucketName)
-	for outpoint, entry := range view.entries {
+// dbPutUtxoEntries uses an existing database transaction to update the utxo
+// set housed in the bucket with the passed name based on the provided utxo
+// entries, such as those of a utxo view or the utxo cache.  In particular, only
+// the entries that have been marked as modified are written to the database.
+func dbPutUtxoEntries(dbTx database.Tx, bucketName []byte, entries map[wire.OutPoint]*UtxoEntry) error {
+	utxoBucket := dbTx.Metadata().Bucket(bucketName)
+	for outpoint, entry := range entries {
 		// No need to update the database if the entry was not modified.
 		if entry == nil || !entry.isModified() {
 			continue
@@ -809,6 +832,36 @@ func dbPutUtxoView(dbTx database.Tx, view *UtxoViewpoint) error {
 	return nil
 }
 
+// dbPutUtxoStateConsistency uses an existing database transaction to record the
+// hash of the block a utxo set in the database is consistent with under the
+// passed key.
+func dbPutUtxoStateConsistency(dbTx database.Tx, keyName []byte, hash *chainhash.Hash) error {
+	return dbTx.Metadata().Put(keyName, hash[:])
+}
+
+// dbFetchUtxoStateConsistency uses an existing database transaction to fetch
+// the hash of the block a utxo set in the database is consistent with from
+// the passed key.
+//
+// When there is no record, such as for databases created before the utxo cache
+// existed, nil will be returned for both the hash and the error.
+func dbFetchUtxoStateConsistency(dbTx database.Tx, keyName []byte) (*chainhash.Hash, error) {
+	serialized := dbTx.Metadata().Get(keyName)
+	if serialized == nil {
+		return nil, nil
+	}
+	if len(serialized) != chainhash.HashSize {
+		return nil, database.Error{
+			ErrorCode:   database.ErrCorruption,
+			Description: "corrupt utxo state consistency record",
+		}
+	}
+
+	var hash chainhash.Hash
+	copy(hash[:], serialized)
+	return &hash, nil
+}
+
 // -----------------------------------------------------------------------------
 // The block index consists of two buckets with an entry for every block in the
 // main chain.  One bucket is for the hash to height mapping and the other is
@@ -1092,6 +1145,13 @@ func (b *BlockChain) createChainState() error {
 			return err
 		}
 
+		// The empty utxo set is consistent with the genesis block.
+		err = dbPutUtxoStateConsistency(dbTx,
+			utxoStateConsistencyKeyName, &node.hash)
+		if err != nil {
+			return err
+		}
+
 		// Store the genesis block into the database.
 		return dbStoreBlock(dbTx, genesisBlock)
 	})
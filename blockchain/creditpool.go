@@ -0,0 +1,607 @@
+// Copyright (c) 2013-2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/eager7/dashd/chaincfg"
+	"github.com/eager7/dashd/chaincfg/chainhash"
+	"github.com/eager7/dashd/database"
+	"github.com/eager7/dashd/wire"
+	"github.com/eager7/dashutil"
+)
+
+const (
+	// creditPoolBlocksToTrace is the number of blocks over which recently
+	// unlocked amounts are taken into account when calculating the limit
+	// of the credit pool.
+	creditPoolBlocksToTrace = 576
+
+	// creditPoolLimitLow is the amount which may always be unlocked within
+	// creditPoolBlocksToTrace blocks as long as the credit pool holds it.
+	creditPoolLimitLow = 100 * dashutil.SatoshiPerBitcoin
+
+	// creditPoolLimitHigh is the maximum amount which may be unlocked
+	// within creditPoolBlocksToTrace blocks.
+	creditPoolLimitHigh = 1000 * dashutil.SatoshiPerBitcoin
+
+	// creditPoolEntrySize is the size of a serialized credit pool entry.
+	creditPoolEntrySize = 32
+)
+
+var (
+	// creditPoolBucketName is the name of the db bucket used to house the
+	// credit pool as of each connected block.
+	creditPoolBucketName = []byte("creditpool")
+
+	// assetUnlockIndexBucketName is the name of the db bucket used to house
+	// the withdrawal indexes of the asset unlocks in the main chain.
+	assetUnlockIndexBucketName = []byte("assetunlockidx")
+)
+
+// CreditPool describes the state of the credit pool as of a given block.  The
+// credit pool holds the funds locked by asset lock transactions for use on
+// Platform, which are released again by asset unlock transactions.
+type CreditPool struct {
+	// Locked is the total amount held by the credit pool.
+	Locked int64
+
+	// CurrentLimit is the maximum amount which may be unlocked by the
+	// asset unlocks of the next block.
+	CurrentLimit int64
+
+	// LatelyUnlocked is the amount unlocked within the last
+	// creditPoolBlocksToTrace blocks.
+	LatelyUnlocked int64
+
+	// Unlocked is the amount unlocked by the block itself.
+	Unlocked int64
+}
+
+// creditPoolDiff describes the changes a block makes to the credit pool.  The
+// platform reward is the share of the masternode reward which is reallocated
+// to the credit pool once the MN_RR EHF deployment is active.
+type creditPoolDiff struct {
+	locked         int64
+	unlocked       int64
+	platformReward int64
+	indexes        []uint64
+}
+
+// calcCreditPoolDiff returns the amounts locked and unlocked by the asset
+// locks and unlocks of the passed block along with the withdrawal indexes
+// used by the asset unlocks.
+func calcCreditPoolDiff(block *dashutil.Block) (*creditPoolDiff, error) {
+	var diff creditPoolDiff
+	for _, tx := range block.Transactions() {
+		msgTx := tx.MsgTx()
+		switch {
+		case IsAssetLockTx(msgTx):
+			diff.locked += assetLockAmount(msgTx)
+
+		case IsAssetUnlockTx(msgTx):
+			payload, err := msgTx.AssetUnlockPayload()
+			if err != nil {
+				str := fmt.Sprintf("invalid asset unlock "+
+					"payload: %v", err)
+				return nil, ruleError(ErrBadAssetUnlock, str)
+			}
+			diff.unlocked += assetUnlockAmount(msgTx, payload)
+			diff.indexes = append(diff.indexes, payload.Index)
+		}
+	}
+
+	return &diff, nil
+}
+
+// calcPlatformReward returns the share of the masternode reward of the block
+// after the passed node which is reallocated to the credit pool once the MN_RR
+// EHF deployment is active.
+//
+// MN_RR can only activate after the v20 hard fork, from which on the block
+// subsidy starts at a fixed 5 coins regardless of the difficulty and declines
+// by a fourteenth every subsidy halving interval.  A fifth of it goes to
+// superblocks and masternodes receive three quarters of the rest, of which
+// 37.5 percent is reallocated to the credit pool.
+func calcPlatformReward(prevNode *blockNode, chainParams *chaincfg.Params) int64 {
+	prevHeight := prevNode.height
+	subsidy := int64(5 * dashutil.SatoshiPerBitcoin)
+	interval := chainParams.SubsidyHalvingInterval
+	for i := interval; i <= prevHeight; i += interval {
+		subsidy -= subsidy / 14
+	}
+	if prevHeight > chainParams.BudgetPaymentsStartHeight {
+		subsidy -= subsidy / 5
+	}
+
+	masternodeReward := subsidy * 3 / 4
+	return masternodeReward * 375 / 1000
+}
+
+// blockCreditPoolDiff returns the changes the passed block, which follows the
+// passed node, makes to the credit pool including the platform reward.
+//
+// This function MUST be called with the chain state lock held (for writes).
+func (b *BlockChain) blockCreditPoolDiff(dbTx database.Tx, prevNode *blockNode, block *dashutil.Block) (*creditPoolDiff, error) {
+	diff, err := calcCreditPoolDiff(block)
+	if err != nil {
+		return nil, err
+	}
+
+	state, err := b.fetchEHFDeploymentState(dbTx, prevNode,
+		chaincfg.EHFDeploymentMnRR)
+	if err != nil {
+		return nil, err
+	}
+	if state.state == ThresholdActive {
+		diff.platformReward = calcPlatformReward(prevNode, b.chainParams)
+	}
+
+	return diff, nil
+}
+
+// nextCreditPool returns the credit pool which results from applying the
+// passed diff to the previous credit pool.  The distantUnlocked parameter is
+// the amount unlocked by the block which drops out of the window of recently
+// unlocked amounts.
+func nextCreditPool(prev *CreditPool, diff *creditPoolDiff, distantUnlocked int64) *CreditPool {
+	locked := prev.Locked + diff.locked - diff.unlocked + diff.platformReward
+	latelyUnlocked := prev.LatelyUnlocked + diff.unlocked - distantUnlocked
+
+	// Up to creditPoolLimitLow may be unlocked at any time, but beyond
+	// that only a tenth of the pool may be unlocked within the window, and
+	// never more than creditPoolLimitHigh.
+	limit := locked
+	if limit+latelyUnlocked > creditPoolLimitLow {
+		limit = (limit + latelyUnlocked) / 10
+		if limit < creditPoolLimitLow {
+			limit = creditPoolLimitLow
+		}
+		limit -= latelyUnlocked
+		if limit < 0 {
+			limit = 0
+		}
+	}
+	if limit > creditPoolLimitHigh-latelyUnlocked {
+		limit = creditPoolLimitHigh - latelyUnlocked
+	}
+
+	return &CreditPool{
+		Locked:         locked,
+		CurrentLimit:   limit,
+		LatelyUnlocked: latelyUnlocked,
+		Unlocked:       diff.unlocked,
+	}
+}
+
+// -----------------------------------------------------------------------------
+// The credit pool as of each block in the main chain from the v20 hard fork
+// on is stored in the credit pool bucket keyed by the block hash.  Since the
+// entry of a block is removed when it is disconnected, the entries also serve
+// as the undo data of the credit pool.
+//
+// The serialized format is:
+//
+//   <locked><current limit><lately unlocked><unlocked>
+//
+//   Field             Type     Size
+//   locked            int64    8
+//   current limit     int64    8
+//   lately unlocked   int64    8
+//   unlocked          int64    8
+//
+// The withdrawal indexes used by the asset unlocks in the main chain are stored
+// in the asset unlock index bucket keyed by the big-endian index with the hash
+// of the block which contains the asset unlock as the value.
+// -----------------------------------------------------------------------------
+
+// serializeCreditPool returns the serialization of the passed credit pool.
+func serializeCreditPool(pool *CreditPool) []byte {
+	serialized := make([]byte, creditPoolEntrySize)
+	byteOrder.PutUint64(serialized[0:8], uint64(pool.Locked))
+	byteOrder.PutUint64(serialized[8:16], uint64(pool.CurrentLimit))
+	byteOrder.PutUint64(serialized[16:24], uint64(pool.LatelyUnlocked))
+	byteOrder.PutUint64(serialized[24:32], uint64(pool.Unlocked))
+	return serialized
+}
+
+// deserializeCreditPool decodes a credit pool from the passed serialized
+// bytes.
+func deserializeCreditPool(serialized []byte) (*CreditPool, error) {
+	if len(serialized) != creditPoolEntrySize {
+		return nil, database.Error{
+			ErrorCode: database.ErrCorruption,
+			Description: fmt.Sprintf("corrupt credit pool entry "+
+				"of %d bytes", len(serialized)),
+		}
+	}
+
+	return &CreditPool{
+		Locked:         int64(byteOrder.Uint64(serialized[0:8])),
+		CurrentLimit:   int64(byteOrder.Uint64(serialized[8:16])),
+		LatelyUnlocked: int64(byteOrder.Uint64(serialized[16:24])),
+		Unlocked:       int64(byteOrder.Uint64(serialized[24:32])),
+	}, nil
+}
+
+// assetUnlockIndexKey returns the key of the passed withdrawal index in the
+// asset unlock index bucket.
+func assetUnlockIndexKey(index uint64) []byte {
+	var key [8]byte
+	binary.BigEndian.PutUint64(key[:], index)
+	return key[:]
+}
+
+// dbCreateCreditPoolBuckets creates the buckets used to house the credit pool
+// and the used asset unlock indexes when they do not exist yet.
+func dbCreateCreditPoolBuckets(dbTx database.Tx) error {
+	meta := dbTx.Metadata()
+	_, err := meta.CreateBucketIfNotExists(creditPoolBucketName)
+	if err != nil {
+		return err
+	}
+	_, err = meta.CreateBucketIfNotExists(assetUnlockIndexBucketName)
+	return err
+}
+
+// dbFetchCreditPool uses an existing database transaction to fetch the stored
+// credit pool as of the block with the given hash.  It returns nil when there
+// is no stored entry for the block.
+func dbFetchCreditPool(dbTx database.Tx, hash *chainhash.Hash) (*CreditPool, error) {
+	bucket := dbTx.Metadata().Bucket(creditPoolBucketName)
+	serialized := bucket.Get(hash[:])
+	if serialized == nil {
+		return nil, nil
+	}
+
+	return deserializeCreditPool(serialized)
+}
+
+// dbPutCreditPool uses an existing database transaction to store the credit
+// pool as of the passed block along with the withdrawal indexes used by it.
+func dbPutCreditPool(dbTx database.Tx, hash *chainhash.Hash, pool *CreditPool, indexes []uint64) error {
+	meta := dbTx.Metadata()
+	err := meta.Bucket(creditPoolBucketName).Put(hash[:],
+		serializeCreditPool(pool))
+	if err != nil {
+		return err
+	}
+
+	indexBucket := meta.Bucket(assetUnlockIndexBucketName)
+	for _, index := range indexes {
+		err := indexBucket.Put(assetUnlockIndexKey(index), hash[:])
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// dbRemoveCreditPool uses an existing database transaction to remove the
+// credit pool as of the passed block along with the withdrawal indexes used by
+// its asset unlocks.
+func dbRemoveCreditPool(dbTx database.Tx, block *dashutil.Block) error {
+	meta := dbTx.Metadata()
+	err := meta.Bucket(creditPoolBucketName).Delete(block.Hash()[:])
+	if err != nil {
+		return err
+	}
+
+	indexBucket := meta.Bucket(assetUnlockIndexBucketName)
+	for _, tx := range block.Transactions() {
+		msgTx := tx.MsgTx()
+		if !IsAssetUnlockTx(msgTx) {
+			continue
+		}
+		payload, err := msgTx.AssetUnlockPayload()
+		if err != nil {
+			return err
+		}
+
+		// Only remove the index when it was stored for this block.
+		key := assetUnlockIndexKey(payload.Index)
+		hash := indexBucket.Get(key)
+		if hash != nil && bytes.Equal(hash, block.Hash()[:]) {
+			if err := indexBucket.Delete(key); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// fetchCreditPool returns the credit pool as of the passed block node.  Nodes
+// before the v20 hard fork have an empty credit pool.  The credit pool of
+// blocks which are not stored, such as those of a side chain being validated
+// during a reorganize, is calculated from the most recent stored ancestor.
+//
+// This function MUST be called with the chain state lock held (for reads).
+func (b *BlockChain) fetchCreditPool(dbTx database.Tx, node *blockNode) (*CreditPool, error) {
+	// Find the most recent ancestor with a known credit pool while
+	// tracking the nodes it needs to be calculated for.
+	pool := &CreditPool{}
+	var needed []*blockNode
+	for n := node; n != nil && n.height >= b.chainParams.V20Height; n = n.parent {
+		stored, err := dbFetchCreditPool(dbTx, &n.hash)
+		if err != nil {
+			return nil, err
+		}
+		if stored != nil {
+			pool = stored
+			break
+		}
+		needed = append(needed, n)
+	}
+
+	// Apply the blocks in order starting with the oldest one.
+	calculated := make(map[*blockNode]*CreditPool, len(needed))
+	for i := len(needed) - 1; i >= 0; i-- {
+		n := needed[i]
+		block, err := dbFetchBlockByNode(dbTx, n)
+		if err != nil {
+			return nil, err
+		}
+		diff, err := b.blockCreditPoolDiff(dbTx, n.parent, block)
+		if err != nil {
+			return nil, err
+		}
+
+		var distantUnlocked int64
+		distant := n.RelativeAncestor(creditPoolBlocksToTrace)
+		if distant != nil && distant.height >= b.chainParams.V20Height {
+			distantPool, ok := calculated[distant]
+			if !ok {
+				distantPool, err = b.fetchCreditPool(dbTx, distant)
+				if err != nil {
+					return nil, err
+				}
+			}
+			distantUnlocked = distantPool.Unlocked
+		}
+
+		pool = nextCreditPool(pool, diff, distantUnlocked)
+		calculated[n] = pool
+	}
+
+	return pool, nil
+}
+
+// isAssetUnlockIndexUsed returns whether or not the passed withdrawal index
+// has been used by an asset unlock in the chain ending with the passed node.
+//
+// This function MUST be called with the chain state lock held (for reads).
+func (b *BlockChain) isAssetUnlockIndexUsed(dbTx database.Tx, node *blockNode, index uint64) (bool, error) {
+	// The index bucket only contains the indexes of the main chain, so look
+	// at the blocks of the chain after the fork point first.
+	fork := b.bestChain.FindFork(node)
+	for n := node; n != nil && n != fork; n = n.parent {
+		if n.height < b.chainParams.V20Height {
+			break
+		}
+		block, err := dbFetchBlockByNode(dbTx, n)
+		if err != nil {
+			return false, err
+		}
+		diff, err := calcCreditPoolDiff(block)
+		if err != nil {
+			return false, err
+		}
+		for _, used := range diff.indexes {
+			if used == index {
+				return true, nil
+			}
+		}
+	}
+
+	serialized := dbTx.Metadata().Bucket(assetUnlockIndexBucketName).Get(
+		assetUnlockIndexKey(index))
+	if serialized == nil {
+		return false, nil
+	}
+	var hash chainhash.Hash
+	copy(hash[:], serialized)
+	usedNode := b.index.LookupNode(&hash)
+	return usedNode != nil && fork != nil &&
+		fork.Ancestor(usedNode.height) == usedNode, nil
+}
+
+// checkCreditPool ensures the asset locks and unlocks of the passed block are
+// active and valid in the context of the chain, that the asset unlocks do not
+// withdraw more than the limit of the credit pool and that the credit pool
+// balance committed to by the coinbase matches the calculated one, which
+// includes the platform reward once the MN_RR EHF deployment is active.
+//
+// This function MUST be called with the chain state lock held (for writes).
+func (b *BlockChain) checkCreditPool(node *blockNode, block *dashutil.Block) error {
+	if node.height < b.chainParams.V20Height {
+		for _, tx := range block.Transactions() {
+			msgTx := tx.MsgTx()
+			if IsAssetLockTx(msgTx) || IsAssetUnlockTx(msgTx) {
+				str := fmt.Sprintf("transaction %v of type %v "+
+					"is not active before height %d",
+					tx.Hash(), msgTx.Type,
+					b.chainParams.V20Height)
+				return ruleError(ErrSpecialTxNotActive, str)
+			}
+		}
+		return nil
+	}
+
+	return b.db.View(func(dbTx database.Tx) error {
+		prevPool, err := b.fetchCreditPool(dbTx, node.parent)
+		if err != nil {
+			return err
+		}
+
+		var unlocked int64
+		indexes := make(map[uint64]struct{})
+		for _, tx := range block.Transactions() {
+			msgTx := tx.MsgTx()
+			if !IsAssetUnlockTx(msgTx) {
+				continue
+			}
+			payload, err := msgTx.AssetUnlockPayload()
+			if err != nil {
+				str := fmt.Sprintf("invalid asset unlock "+
+					"payload: %v", err)
+				return ruleError(ErrBadAssetUnlock, str)
+			}
+
+			// Each withdrawal index may only be used once.
+			if _, ok := indexes[payload.Index]; ok {
+				str := fmt.Sprintf("block contains more than "+
+					"one asset unlock with index %d",
+					payload.Index)
+				return ruleError(ErrBadAssetUnlock, str)
+			}
+			indexes[payload.Index] = struct{}{}
+			used, err := b.isAssetUnlockIndexUsed(dbTx, node.parent,
+				payload.Index)
+			if err != nil {
+				return err
+			}
+			if used {
+				str := fmt.Sprintf("asset unlock index %d has "+
+					"already been used", payload.Index)
+				return ruleError(ErrBadAssetUnlock, str)
+			}
+
+			err = b.checkAssetUnlockContext(dbTx, node.parent, msgTx,
+				payload)
+			if err != nil {
+				return err
+			}
+
+			unlocked += assetUnlockAmount(msgTx, payload)
+			if unlocked > prevPool.CurrentLimit {
+				str := fmt.Sprintf("asset unlocks in block "+
+					"withdraw %v which is more than the "+
+					"credit pool limit of %v", unlocked,
+					prevPool.CurrentLimit)
+				return ruleError(ErrAssetUnlockLimit, str)
+			}
+		}
+
+		// Ensure the credit pool balance the coinbase commits to, if
+		// any, matches the balance after applying the block.
+		coinbase := block.Transactions()[0].MsgTx()
+		if !coinbase.IsSpecial() || coinbase.Type != wire.TxTypeCoinBase {
+			return nil
+		}
+		cbPayload, err := coinbase.CoinbasePayload()
+		if err != nil {
+			str := fmt.Sprintf("invalid coinbase payload: %v", err)
+			return ruleError(ErrBadCreditPoolBalance, str)
+		}
+		if cbPayload.Version < wire.CbTxVersionCreditPool {
+			return nil
+		}
+		diff, err := b.blockCreditPoolDiff(dbTx, node.parent, block)
+		if err != nil {
+			return err
+		}
+		locked := prevPool.Locked + diff.locked - diff.unlocked +
+			diff.platformReward
+		if cbPayload.CreditPoolBalance != locked {
+			str := fmt.Sprintf("coinbase commits to a credit pool "+
+				"balance of %v instead of the expected %v",
+				cbPayload.CreditPoolBalance, locked)
+			return ruleError(ErrBadCreditPoolBalance, str)
+		}
+
+		return nil
+	})
+}
+
+// connectCreditPool stores the credit pool as of the passed block, which must
+// be the new tip of the main chain, along with the used withdrawal indexes.
+//
+// This function MUST be called with the chain state lock held (for writes).
+func (b *BlockChain) connectCreditPool(dbTx database.Tx, node *blockNode, block *dashutil.Block) error {
+	if node.height < b.chainParams.V20Height {
+		return nil
+	}
+
+	prevPool, err := b.fetchCreditPool(dbTx, node.parent)
+	if err != nil {
+		return err
+	}
+	diff, err := b.blockCreditPoolDiff(dbTx, node.parent, block)
+	if err != nil {
+		return err
+	}
+
+	var distantUnlocked int64
+	distant := node.RelativeAncestor(creditPoolBlocksToTrace)
+	if distant != nil && distant.height >= b.chainParams.V20Height {
+		distantPool, err := b.fetchCreditPool(dbTx, distant)
+		if err != nil {
+			return err
+		}
+		distantUnlocked = distantPool.Unlocked
+	}
+
+	pool := nextCreditPool(prevPool, diff, distantUnlocked)
+	return dbPutCreditPool(dbTx, &node.hash, pool, diff.indexes)
+}
+
+// CreditPool returns the credit pool as of the block with the given hash.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) CreditPool(hash *chainhash.Hash) (*CreditPool, error) {
+	// The write lock is needed since calculating the credit pool of a block
+	// which is not stored updates the EHF deployment state caches.
+	b.chainLock.Lock()
+	defer b.chainLock.Unlock()
+
+	node := b.index.LookupNode(hash)
+	if node == nil {
+		return nil, fmt.Errorf("block %s is not known", hash)
+	}
+
+	var pool *CreditPool
+	err := b.db.View(func(dbTx database.Tx) error {
+		var err error
+		pool, err = b.fetchCreditPool(dbTx, node)
+		return err
+	})
+	return pool, err
+}
+
+// AssetUnlockHeight returns the height of the block in the main chain which
+// contains the asset unlock with the passed withdrawal index.  The boolean is
+// false when the index has not been used in the main chain.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) AssetUnlockHeight(index uint64) (int32, bool, error) {
+	b.chainLock.RLock()
+	defer b.chainLock.RUnlock()
+
+	var hash *chainhash.Hash
+	err := b.db.View(func(dbTx database.Tx) error {
+		bucket := dbTx.Metadata().Bucket(assetUnlockIndexBucketName)
+		serialized := bucket.Get(assetUnlockIndexKey(index))
+		if serialized != nil {
+			hash = new(chainhash.Hash)
+			copy(hash[:], serialized)
+		}
+		return nil
+	})
+	if err != nil || hash == nil {
+		return 0, false, err
+	}
+
+	node := b.index.LookupNode(hash)
+	if node == nil || !b.bestChain.Contains(node) {
+		return 0, false, nil
+	}
+
+	return node.height, true, nil
+}
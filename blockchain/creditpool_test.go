@@ -0,0 +1,54 @@
+// Copyright (c) 2013-2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"testing"
+
+	"github.com/eager7/dashd/chaincfg"
+)
+
+// TestCalcPlatformReward ensures the platform reward is calculated from the
+// fixed block subsidy base of the v20 hard fork, including on regtest where
+// the difficulty would otherwise yield a higher subsidy.
+func TestCalcPlatformReward(t *testing.T) {
+	t.Parallel()
+
+	params := &chaincfg.RegressionNetParams
+	tests := []struct {
+		name       string
+		prevHeight int32
+		want       int64
+	}{
+		{"first block", 0, 140625000},
+		{"after the first subsidy decline", 199, 130580357},
+		{"final block without superblock share", 1000, 90147655},
+		{"first block with superblock share", 1001, 72118125},
+		{"after the seventh subsidy decline", 1050, 66966830},
+	}
+	for _, test := range tests {
+		prevNode := &blockNode{height: test.prevHeight}
+		got := calcPlatformReward(prevNode, params)
+		if got != test.want {
+			t.Errorf("%s: got %d, want %d", test.name, got, test.want)
+		}
+	}
+
+	// Pin the credit pool balance at regtest height 1100 when MN_RR is
+	// active from height 1000 on and no funds are locked or unlocked.
+	pool := &CreditPool{}
+	for height := int32(1000); height <= 1100; height++ {
+		prevNode := &blockNode{height: height - 1}
+		diff := &creditPoolDiff{
+			platformReward: calcPlatformReward(prevNode, params),
+		}
+		pool = nextCreditPool(pool, diff, 0)
+	}
+	const wantLocked = 7062424935
+	if pool.Locked != wantLocked {
+		t.Fatalf("unexpected credit pool balance at height 1100: got "+
+			"%d, want %d", pool.Locked, wantLocked)
+	}
+}
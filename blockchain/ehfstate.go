@@ -0,0 +1,271 @@
+// Copyright (c) 2016-2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"fmt"
+
+	"github.com/eager7/dashd/chaincfg"
+	"github.com/eager7/dashd/chaincfg/chainhash"
+	"github.com/eager7/dashd/database"
+)
+
+// ehfState describes the threshold state of an EHF deployment for the blocks
+// of a window along with the height of the first block the state applies to.
+type ehfState struct {
+	state ThresholdState
+	since int32
+}
+
+// ehfStateCache provides a type to cache the states of an EHF deployment for
+// each window.  Unlike the threshold states of BIP0009 deployments, the
+// threshold of an EHF deployment depends on the number of windows it has been
+// voted on, so the height each state was reached at is cached as well.
+type ehfStateCache struct {
+	entries map[chainhash.Hash]ehfState
+}
+
+// Lookup returns the EHF state associated with the given hash along with a
+// boolean that indicates whether or not it is valid.
+func (c *ehfStateCache) Lookup(hash *chainhash.Hash) (ehfState, bool) {
+	state, ok := c.entries[*hash]
+	return state, ok
+}
+
+// Update updates the cache to contain the provided hash to EHF state mapping.
+func (c *ehfStateCache) Update(hash *chainhash.Hash, state ehfState) {
+	c.entries[*hash] = state
+}
+
+// newEHFStateCaches returns a new array of caches to be used when calculating
+// the states of EHF deployments.
+func newEHFStateCaches(numCaches uint32) []ehfStateCache {
+	caches := make([]ehfStateCache, numCaches)
+	for i := 0; i < len(caches); i++ {
+		caches[i] = ehfStateCache{
+			entries: make(map[chainhash.Hash]ehfState),
+		}
+	}
+	return caches
+}
+
+// ehfThreshold returns the number of votes required to lock in the passed EHF
+// deployment during a window when it has already been voted on for the given
+// number of previous windows.  The threshold falls quadratically from the
+// start threshold until it reaches the minimum threshold.
+func ehfThreshold(deployment *chaincfg.EHFDeployment, attempt int64) uint32 {
+	if deployment.FalloffCoeff == 0 {
+		return deployment.ThresholdStart
+	}
+
+	threshold := int64(deployment.ThresholdStart) - attempt*attempt*
+		int64(deployment.MinerConfirmationWindow)/100/
+		int64(deployment.FalloffCoeff)
+	if threshold < int64(deployment.ThresholdMin) {
+		return deployment.ThresholdMin
+	}
+	return uint32(threshold)
+}
+
+// ehfVoteCondition returns whether or not the passed block votes for the EHF
+// deployment with the given version bit.
+func ehfVoteCondition(node *blockNode, bit uint8) bool {
+	conditionMask := uint32(1) << bit
+	version := uint32(node.version)
+	return (version&vbTopMask == vbTopBits) && (version&conditionMask != 0)
+}
+
+// isEHFSignaled returns whether or not a masternode quorum has signaled the
+// passed EHF deployment in the chain ending with the passed node.
+//
+// This function MUST be called with the chain state lock held (for reads).
+func (b *BlockChain) isEHFSignaled(dbTx database.Tx, node *blockNode, deployment *chaincfg.EHFDeployment) (bool, error) {
+	if node.height < b.chainParams.V20Height {
+		return false, nil
+	}
+
+	signals, err := b.fetchMnHfSignals(dbTx, node)
+	if err != nil {
+		return false, err
+	}
+
+	_, ok := b.activeMnHfSignals(node, signals)[deployment.BitNumber]
+	return ok, nil
+}
+
+// ehfDeploymentState returns the state of the given EHF deployment for the
+// block AFTER the given node.  The state machine matches the one used for
+// BIP0009 deployments with two differences: a deployment only moves from the
+// defined to the started state once a masternode quorum has signaled it, and
+// the number of votes required to lock it in falls with each window it fails
+// to do so.  The cache is used to ensure the states for previous windows are
+// only calculated once.
+//
+// This function MUST be called with the chain state lock held (for writes).
+func (b *BlockChain) ehfDeploymentState(prevNode *blockNode, deploymentID uint32) (ehfState, error) {
+	var state ehfState
+	err := b.db.View(func(dbTx database.Tx) error {
+		var err error
+		state, err = b.fetchEHFDeploymentState(dbTx, prevNode,
+			deploymentID)
+		return err
+	})
+	return state, err
+}
+
+// fetchEHFDeploymentState is the same as ehfDeploymentState except it uses an
+// existing database transaction to look up the masternode hard fork signals.
+//
+// This function MUST be called with the chain state lock held (for writes).
+func (b *BlockChain) fetchEHFDeploymentState(dbTx database.Tx, prevNode *blockNode, deploymentID uint32) (ehfState, error) {
+	if deploymentID >= uint32(len(b.chainParams.EHFDeployments)) {
+		return ehfState{state: ThresholdFailed}, DeploymentError(deploymentID)
+	}
+	deployment := &b.chainParams.EHFDeployments[deploymentID]
+	cache := &b.ehfCaches[deploymentID]
+
+	// The state for the window that contains the genesis block is defined
+	// by definition.
+	window := int32(deployment.MinerConfirmationWindow)
+	if prevNode == nil || (prevNode.height+1) < window {
+		return ehfState{state: ThresholdDefined}, nil
+	}
+
+	// Get the ancestor that is the last block of the previous window in
+	// order to get its state.  This can be done because the state is the
+	// same for all blocks within a given window.
+	prevNode = prevNode.Ancestor(prevNode.height -
+		(prevNode.height+1)%window)
+
+	// Iterate backwards through each of the previous windows to find the
+	// most recently cached state.
+	var neededStates []*blockNode
+	for prevNode != nil {
+		// Nothing more to do if the state of the block is already
+		// cached.
+		if _, ok := cache.Lookup(&prevNode.hash); ok {
+			break
+		}
+
+		// The state is simply defined if the start time hasn't been
+		// been reached yet.
+		medianTime := prevNode.CalcPastMedianTime()
+		if uint64(medianTime.Unix()) < deployment.StartTime {
+			cache.Update(&prevNode.hash, ehfState{state: ThresholdDefined})
+			break
+		}
+
+		// Add this node to the list of nodes that need the state
+		// calculated and cached.
+		neededStates = append(neededStates, prevNode)
+
+		// Get the ancestor that is the last block of the previous
+		// window.
+		prevNode = prevNode.RelativeAncestor(window)
+	}
+
+	// Start with the state for the most recent window that has a cached
+	// state.
+	state := ehfState{state: ThresholdDefined}
+	if prevNode != nil {
+		var ok bool
+		state, ok = cache.Lookup(&prevNode.hash)
+		if !ok {
+			return ehfState{state: ThresholdFailed}, AssertError(
+				fmt.Sprintf("fetchEHFDeploymentState: cache lookup "+
+					"failed for %v", prevNode.hash))
+		}
+	}
+
+	// Since each state depends on the state of the previous window,
+	// iterate starting from the oldest unknown window.
+	for neededNum := len(neededStates) - 1; neededNum >= 0; neededNum-- {
+		prevNode := neededStates[neededNum]
+		nextHeight := prevNode.height + 1
+
+		switch state.state {
+		case ThresholdDefined:
+			// The deployment fails if it expires before it is
+			// signaled and locked in.
+			medianTime := prevNode.CalcPastMedianTime()
+			medianTimeUnix := uint64(medianTime.Unix())
+			if medianTimeUnix >= deployment.ExpireTime {
+				state = ehfState{ThresholdFailed, nextHeight}
+				break
+			}
+
+			// Voting starts once the start time has been reached
+			// and a masternode quorum has signaled the deployment.
+			if medianTimeUnix < deployment.StartTime {
+				break
+			}
+			signaled, err := b.isEHFSignaled(dbTx, prevNode,
+				deployment)
+			if err != nil {
+				return ehfState{state: ThresholdFailed}, err
+			}
+			if signaled {
+				state = ehfState{ThresholdStarted, nextHeight}
+			}
+
+		case ThresholdStarted:
+			// The deployment fails if it expires before it is
+			// locked in.
+			medianTime := prevNode.CalcPastMedianTime()
+			if uint64(medianTime.Unix()) >= deployment.ExpireTime {
+				state = ehfState{ThresholdFailed, nextHeight}
+				break
+			}
+
+			// Count the votes of the miners in the window.
+			var count uint32
+			countNode := prevNode
+			for i := int32(0); i < window; i++ {
+				if ehfVoteCondition(countNode, deployment.BitNumber) {
+					count++
+				}
+				countNode = countNode.parent
+			}
+
+			// The state is locked in if the number of votes meets
+			// the threshold for the number of windows voted on
+			// before this one.
+			attempt := int64(nextHeight-window-state.since) /
+				int64(window)
+			if count >= ehfThreshold(deployment, attempt) {
+				state = ehfState{ThresholdLockedIn, nextHeight}
+			}
+
+		case ThresholdLockedIn:
+			// The new rule becomes active when its previous state
+			// was locked in.
+			state = ehfState{ThresholdActive, nextHeight}
+
+		// Nothing to do if the previous state is active or failed since
+		// they are both terminal states.
+		case ThresholdActive:
+		case ThresholdFailed:
+		}
+
+		// Update the cache to avoid recalculating the state in the
+		// future.
+		cache.Update(&prevNode.hash, state)
+	}
+
+	return state, nil
+}
+
+// EHFThresholdState returns the state of the given EHF deployment ID for the
+// block AFTER the end of the current best chain along with the height of the
+// first block the state applies to.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) EHFThresholdState(deploymentID uint32) (ThresholdState, int32, error) {
+	b.chainLock.Lock()
+	state, err := b.ehfDeploymentState(b.bestChain.Tip(), deploymentID)
+	b.chainLock.Unlock()
+
+	return state.state, state.since, err
+}
@@ -0,0 +1,79 @@
+// Copyright (c) 2016-2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/eager7/dashd/chaincfg"
+)
+
+// TestEHFThreshold ensures the number of votes required to lock in an EHF
+// deployment falls from the start threshold to the minimum threshold.
+func TestEHFThreshold(t *testing.T) {
+	deployment := chaincfg.MainNetParams.EHFDeployments[chaincfg.EHFDeploymentMnRR]
+	tests := []struct {
+		attempt int64
+		want    uint32
+	}{
+		{0, 3226},
+		{1, 3218},
+		{2, 3194},
+		{5, 3025},
+		{9, 2573},
+		{10, 2420},
+		{20, 2420},
+	}
+
+	for _, test := range tests {
+		got := ehfThreshold(&deployment, test.attempt)
+		if got != test.want {
+			t.Errorf("ehfThreshold(%d): got %d, want %d",
+				test.attempt, got, test.want)
+		}
+	}
+
+	// A deployment without a falloff always requires the start threshold.
+	deployment.FalloffCoeff = 0
+	if got := ehfThreshold(&deployment, 100); got != 3226 {
+		t.Errorf("ehfThreshold without falloff: got %d, want %d", got,
+			3226)
+	}
+}
+
+// TestMnHfSignalsSerialization ensures serializing and deserializing the
+// masternode hard fork signals works as expected.
+func TestMnHfSignalsSerialization(t *testing.T) {
+	tests := []mnhfSignals{
+		{},
+		{10: 1987800},
+		{0: 1, 10: 2, 28: 0x7fffffff},
+	}
+
+	for i, signals := range tests {
+		serialized := serializeMnHfSignals(signals)
+		if len(serialized) != 1+len(signals)*mnhfSignalEntrySize {
+			t.Errorf("serializeMnHfSignals #%d: unexpected length %d",
+				i, len(serialized))
+			continue
+		}
+		got, err := deserializeMnHfSignals(serialized)
+		if err != nil {
+			t.Errorf("deserializeMnHfSignals #%d: unexpected error %v",
+				i, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, signals) {
+			t.Errorf("deserializeMnHfSignals #%d: got %v, want %v",
+				i, got, signals)
+		}
+	}
+
+	// Truncated entries must be rejected as corrupt.
+	if _, err := deserializeMnHfSignals([]byte{0x01, 0x0a}); err == nil {
+		t.Error("deserializeMnHfSignals: did not reject truncated entry")
+	}
+}
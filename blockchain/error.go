@@ -220,6 +220,38 @@ const (
 	// current chain tip. This is not a block validation rule, but is required
 	// for block proposals submitted via getblocktemplate RPC.
 	ErrPrevBlockNotBest
+
+	// ErrSpecialTxNotActive indicates a transaction is a special
+	// transaction of a type which is unknown or not active yet at the
+	// height of the block.
+	ErrSpecialTxNotActive
+
+	// ErrBadAssetLock indicates an asset lock transaction is malformed or
+	// its credit outputs do not match the locked amount.
+	ErrBadAssetLock
+
+	// ErrBadAssetUnlock indicates an asset unlock transaction is
+	// malformed, reuses a withdrawal index, has expired or is not properly
+	// signed by a platform quorum.
+	ErrBadAssetUnlock
+
+	// ErrAssetUnlockLimit indicates the asset unlock transactions in a
+	// block withdraw more than the current limit of the credit pool.
+	ErrAssetUnlockLimit
+
+	// ErrBadCreditPoolBalance indicates the credit pool balance committed
+	// to in the coinbase transaction does not match the calculated balance.
+	ErrBadCreditPoolBalance
+
+	// ErrBadMnHfSignal indicates a masternode hard fork signal transaction
+	// is malformed, signals a version bit which is not available for
+	// signaling or already signaled, or is not properly signed by a
+	// quorum.
+	ErrBadMnHfSignal
+
+	// ErrBadQuorumCommitment indicates a quorum commitment transaction is
+	// malformed.
+	ErrBadQuorumCommitment
 )
 
 // Map of ErrorCode values back to their constant names for pretty printing.
@@ -267,6 +299,13 @@ var errorCodeStrings = map[ErrorCode]string{
 	ErrPreviousBlockUnknown:      "ErrPreviousBlockUnknown",
 	ErrInvalidAncestorBlock:      "ErrInvalidAncestorBlock",
 	ErrPrevBlockNotBest:          "ErrPrevBlockNotBest",
+	ErrSpecialTxNotActive:        "ErrSpecialTxNotActive",
+	ErrBadAssetLock:              "ErrBadAssetLock",
+	ErrBadAssetUnlock:            "ErrBadAssetUnlock",
+	ErrAssetUnlockLimit:          "ErrAssetUnlockLimit",
+	ErrBadCreditPoolBalance:      "ErrBadCreditPoolBalance",
+	ErrBadMnHfSignal:             "ErrBadMnHfSignal",
+	ErrBadQuorumCommitment:       "ErrBadQuorumCommitment",
 }
 
 // String returns the ErrorCode as a human-readable name.
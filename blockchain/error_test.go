@@ -58,6 +58,13 @@ func TestErrorCodeStringer(t *testing.T) {
 		{ErrPreviousBlockUnknown, "ErrPreviousBlockUnknown"},
 		{ErrInvalidAncestorBlock, "ErrInvalidAncestorBlock"},
 		{ErrPrevBlockNotBest, "ErrPrevBlockNotBest"},
+		{ErrSpecialTxNotActive, "ErrSpecialTxNotActive"},
+		{ErrBadAssetLock, "ErrBadAssetLock"},
+		{ErrBadAssetUnlock, "ErrBadAssetUnlock"},
+		{ErrAssetUnlockLimit, "ErrAssetUnlockLimit"},
+		{ErrBadCreditPoolBalance, "ErrBadCreditPoolBalance"},
+		{ErrBadMnHfSignal, "ErrBadMnHfSignal"},
+		{ErrBadQuorumCommitment, "ErrBadQuorumCommitment"},
 		{0xffff, "Unknown ErrorCode (65535)"},
 	}
 
@@ -0,0 +1,192 @@
+// Copyright (c) 2013-2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"github.com/eager7/dashd/chaincfg/chainhash"
+)
+
+// bestHeaderChain returns the best known chain of headers, which is the main
+// chain when no headers with more work have been added.
+//
+// This function MUST be called with the chain state lock held (for reads).
+func (b *BlockChain) bestHeaderChain() *chainView {
+	if b.bestChain.Tip().workSum.Cmp(b.bestHeaders.Tip().workSum) > 0 {
+		return b.bestChain
+	}
+	return b.bestHeaders
+}
+
+// BestHeader returns the hash and height of the tip of the best known chain of
+// headers.  It is the tip of the main chain unless headers with more work have
+// been added with ProcessBlockHeader.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) BestHeader() (*chainhash.Hash, int32) {
+	b.chainLock.RLock()
+	tip := b.bestHeaderChain().Tip()
+	b.chainLock.RUnlock()
+	return &tip.hash, tip.height
+}
+
+// LatestHeaderLocator returns a block locator for the tip of the best known
+// chain of headers, which is used to request the headers after it.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) LatestHeaderLocator() BlockLocator {
+	b.chainLock.RLock()
+	locator := b.bestHeaderChain().BlockLocator(nil)
+	b.chainLock.RUnlock()
+	return locator
+}
+
+// NextBlocksToDownload returns the hashes of up to the passed number of blocks
+// of the best known chain of headers which need to be downloaded and processed
+// to extend the main chain to its tip, ordered by height, along with the height
+// of the first one.  The blocks which are already known are skipped, so the
+// first returned block always connects to a known block.
+//
+// When one of the blocks of the best known chain of headers is known to be
+// invalid, none of the blocks after it can be connected, so the headers after
+// it are dropped and the blocks of the next best chain of headers are returned
+// instead.  Nothing is returned when the main chain is the best known chain of
+// headers or when none of the headers could be dropped.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) NextBlocksToDownload(maxHashes int) ([]chainhash.Hash, int32) {
+	b.chainLock.Lock()
+	defer b.chainLock.Unlock()
+
+	for {
+		hashes, startHeight, invalid := b.nextBlocksToDownload(maxHashes)
+		if !invalid {
+			return hashes, startHeight
+		}
+		if !b.dropInvalidHeaders() {
+			return nil, 0
+		}
+	}
+}
+
+// nextBlocksToDownload returns the hashes of up to the passed number of blocks
+// of the best known chain of headers which need to be downloaded, along with
+// the height of the first one.  Nothing is returned and the final result is
+// true when one of the blocks of the chain is known to be invalid.
+//
+// This function MUST be called with the chain state lock held (for reads).
+func (b *BlockChain) nextBlocksToDownload(maxHashes int) ([]chainhash.Hash, int32, bool) {
+	headers := b.bestHeaderChain()
+	if headers == b.bestChain {
+		return nil, 0, false
+	}
+
+	// Find the height of the final common block of the header chain and
+	// the main chain.  The nodes of headers are replaced by the nodes of
+	// their blocks once they are processed, so the hashes are compared
+	// instead of the nodes.  Since both chains share all blocks below the
+	// fork, a binary search finds it.
+	low, high := int32(0), b.bestChain.Height()
+	if headers.Height() < high {
+		high = headers.Height()
+	}
+	for low < high {
+		mid := low + (high-low+1)/2
+		if headers.NodeByHeight(mid).hash == b.bestChain.NodeByHeight(mid).hash {
+			low = mid
+		} else {
+			high = mid - 1
+		}
+	}
+
+	// Skip the blocks of the header chain which have already been added
+	// to the block index, such as those of a side chain the header chain
+	// follows.
+	height := low + 1
+	for ; height <= headers.Height(); height++ {
+		node := b.index.LookupNode(&headers.NodeByHeight(height).hash)
+		if node == nil {
+			break
+		}
+		if b.index.NodeStatus(node).KnownInvalid() {
+			return nil, 0, true
+		}
+	}
+
+	startHeight := height
+	var hashes []chainhash.Hash
+	for ; height <= headers.Height() && len(hashes) < maxHashes; height++ {
+		hashes = append(hashes, headers.NodeByHeight(height).hash)
+	}
+	return hashes, startHeight, false
+}
+
+// dropInvalidHeaders removes the headers of the blocks which descend from a
+// block known to be invalid from the known headers, and selects the chain of
+// the remaining headers with the most work as the best known chain of headers.
+// It falls back to the main chain when none of the remaining headers has more
+// work than it.  The return value is false when no header has been dropped.
+//
+// This function MUST be called with the chain state lock held (for writes).
+func (b *BlockChain) dropInvalidHeaders() bool {
+	// The nodes of the headers keep pointing to the nodes of the headers
+	// of their ancestors after those are replaced by the nodes of their
+	// blocks, so the ancestors are looked up by hash.  The blocks which
+	// descend from an invalid block aren't always marked invalid
+	// themselves, so the ancestors of the first block which is found are
+	// checked as well, down to the main chain which only has valid blocks.
+	// The results are cached since most headers share their ancestors.
+	invalid := make(map[*blockNode]bool)
+	isInvalid := func(node *blockNode) bool {
+		var path []*blockNode
+		var result bool
+		for n := node; n != nil; n = n.parent {
+			if cached, exists := invalid[n]; exists {
+				result = cached
+				break
+			}
+			path = append(path, n)
+			indexNode := b.index.LookupNode(&n.hash)
+			if indexNode == nil {
+				continue
+			}
+			for ; indexNode != nil; indexNode = indexNode.parent {
+				if b.bestChain.Contains(indexNode) {
+					break
+				}
+				if b.index.NodeStatus(indexNode).KnownInvalid() {
+					result = true
+					break
+				}
+			}
+			break
+		}
+		for _, n := range path {
+			invalid[n] = result
+		}
+		return result
+	}
+
+	var numDropped int
+	best := b.bestChain.Tip()
+	for hash, node := range b.headerIndex {
+		if isInvalid(node) {
+			delete(b.headerIndex, hash)
+			numDropped++
+			continue
+		}
+		if node.workSum.Cmp(best.workSum) > 0 {
+			best = node
+		}
+	}
+	b.bestHeaders.SetTip(best)
+	if numDropped == 0 {
+		return false
+	}
+
+	log.Infof("Dropped %d headers descending from invalid blocks, the "+
+		"best known header is now %v (height %d)", numDropped,
+		best.hash, best.height)
+	return true
+}
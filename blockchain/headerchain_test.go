@@ -0,0 +1,186 @@
+// Copyright (c) 2013-2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/eager7/dashd/chaincfg"
+	"github.com/eager7/dashd/chaincfg/chainhash"
+	"github.com/eager7/dashd/wire"
+)
+
+// TestNextBlocksToDownload ensures the blocks of the best known header chain
+// which are not known yet are returned in order starting after the fork with
+// the main chain.
+func TestNextBlocksToDownload(t *testing.T) {
+	t.Parallel()
+
+	// workNodes returns a chain of nodes extending the passed parent which
+	// all have the same non-zero work.
+	workNodes := func(parent *blockNode, numNodes int) []*blockNode {
+		nodes := make([]*blockNode, numNodes)
+		tip := parent
+		for i := range nodes {
+			header := wire.BlockHeader{
+				Bits:  0x207fffff,
+				Nonce: testNoncePrng.Uint32(),
+			}
+			if tip != nil {
+				header.PrevBlock = tip.hash
+			}
+			nodes[i] = newBlockNode(&header, tip)
+			tip = nodes[i]
+		}
+		return nodes
+	}
+
+	// hashesOf returns the hashes of the passed nodes.
+	hashesOf := func(nodes []*blockNode) []chainhash.Hash {
+		hashes := make([]chainhash.Hash, 0, len(nodes))
+		for _, node := range nodes {
+			hashes = append(hashes, node.hash)
+		}
+		return hashes
+	}
+
+	// Construct a header chain of 20 blocks of which the first 8 have been
+	// processed.  The main chain holds copies of the nodes of the
+	// processed blocks, like the nodes which replace those of headers once
+	// the blocks are added.  A side chain of the main chain forks at height
+	// 5 and the third block of the header chain after the processed ones
+	// is in the block index already.
+	headerNodes := workNodes(nil, 20)
+	mainNodes := make([]*blockNode, 8)
+	for i := range mainNodes {
+		node := *headerNodes[i]
+		if i > 0 {
+			node.parent = mainNodes[i-1]
+		}
+		mainNodes[i] = &node
+	}
+	b := &BlockChain{
+		index:       newBlockIndex(nil, &chaincfg.RegressionNetParams),
+		bestChain:   newChainView(tstTip(mainNodes)),
+		bestHeaders: newChainView(tstTip(headerNodes)),
+	}
+	for _, node := range mainNodes {
+		b.index.AddNode(node)
+	}
+
+	hashes, height := b.NextBlocksToDownload(5)
+	if height != 8 || !reflect.DeepEqual(hashes, hashesOf(headerNodes[8:13])) {
+		t.Fatalf("unexpected blocks to download at height %d: %v",
+			height, hashes)
+	}
+
+	// Blocks which are known already are skipped.
+	b.index.AddNode(headerNodes[8])
+	hashes, height = b.NextBlocksToDownload(100)
+	if height != 9 || !reflect.DeepEqual(hashes, hashesOf(headerNodes[9:])) {
+		t.Fatalf("unexpected blocks to download at height %d: %v",
+			height, hashes)
+	}
+
+	// Nothing is downloaded past a block known to be invalid.
+	b.index.AddNode(headerNodes[9])
+	b.index.SetStatusFlags(headerNodes[9], statusValidateFailed)
+	if hashes, _ := b.NextBlocksToDownload(100); len(hashes) != 0 {
+		t.Fatalf("unexpected blocks to download past an invalid "+
+			"block: %v", hashes)
+	}
+
+	// Nothing is downloaded when the main chain has more work.
+	sideNodes := workNodes(mainNodes[5], 20)
+	b.bestChain.SetTip(tstTip(sideNodes))
+	if hashes, _ := b.NextBlocksToDownload(100); len(hashes) != 0 {
+		t.Fatalf("unexpected blocks to download for a main chain "+
+			"with more work: %v", hashes)
+	}
+}
+
+// TestDropInvalidHeaders ensures the headers which descend from a block known to
+// be invalid are dropped, even when the blocks between them aren't marked
+// invalid, and that the blocks of the next best chain of headers are returned
+// instead.
+func TestDropInvalidHeaders(t *testing.T) {
+	t.Parallel()
+
+	// workNodes returns a chain of nodes extending the passed parent which
+	// all have the same non-zero work.
+	workNodes := func(parent *blockNode, numNodes int) []*blockNode {
+		nodes := make([]*blockNode, numNodes)
+		tip := parent
+		for i := range nodes {
+			header := wire.BlockHeader{
+				Bits:  0x207fffff,
+				Nonce: testNoncePrng.Uint32(),
+			}
+			if tip != nil {
+				header.PrevBlock = tip.hash
+			}
+			nodes[i] = newBlockNode(&header, tip)
+			tip = nodes[i]
+		}
+		return nodes
+	}
+
+	// Construct a header chain of 20 blocks of which the first 8 are in
+	// the main chain.  The block at height 8 is invalid and the block at
+	// height 9 is in the block index without being marked invalid, so the
+	// headers after it only descend from the invalid block through it.  A
+	// shorter chain of headers forks from the tip of the main chain.
+	headerNodes := workNodes(nil, 20)
+	altNodes := workNodes(headerNodes[7], 5)
+	b := &BlockChain{
+		index:       newBlockIndex(nil, &chaincfg.RegressionNetParams),
+		bestChain:   newChainView(headerNodes[7]),
+		bestHeaders: newChainView(tstTip(headerNodes)),
+		headerIndex: make(map[chainhash.Hash]*blockNode),
+	}
+	for _, node := range headerNodes[:10] {
+		b.index.AddNode(node)
+	}
+	b.index.SetStatusFlags(headerNodes[8], statusValidateFailed)
+	for _, node := range headerNodes[10:] {
+		b.headerIndex[node.hash] = node
+	}
+	for _, node := range altNodes {
+		b.headerIndex[node.hash] = node
+	}
+
+	hashes, height := b.NextBlocksToDownload(100)
+	if height != 8 || len(hashes) != len(altNodes) {
+		t.Fatalf("unexpected blocks to download at height %d: %v",
+			height, hashes)
+	}
+	for i, node := range altNodes {
+		if hashes[i] != node.hash {
+			t.Fatalf("unexpected block to download at height %d: "+
+				"got %v, want %v", height+int32(i), hashes[i],
+				node.hash)
+		}
+	}
+	if len(b.headerIndex) != len(altNodes) {
+		t.Fatalf("unexpected number of headers left: got %d, want %d",
+			len(b.headerIndex), len(altNodes))
+	}
+	if tip := b.bestHeaders.Tip(); tip != altNodes[len(altNodes)-1] {
+		t.Fatalf("unexpected best header %v", tip.hash)
+	}
+
+	// Nothing is downloaded once the remaining headers descend from the
+	// invalid block too.
+	b.index.AddNode(altNodes[0])
+	b.index.SetStatusFlags(altNodes[0], statusValidateFailed)
+	if hashes, _ := b.NextBlocksToDownload(100); len(hashes) != 0 {
+		t.Fatalf("unexpected blocks to download past an invalid "+
+			"block: %v", hashes)
+	}
+	if len(b.headerIndex) != 0 {
+		t.Fatalf("unexpected headers left: %d", len(b.headerIndex))
+	}
+}
@@ -0,0 +1,697 @@
+// Copyright (c) 2016 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package indexers
+
+import (
+	"bytes"
+	"encoding/binary"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/eager7/dashd/blockchain"
+	"github.com/eager7/dashd/chaincfg"
+	"github.com/eager7/dashd/chaincfg/chainhash"
+	"github.com/eager7/dashd/database"
+	"github.com/eager7/dashd/txscript"
+	"github.com/eager7/dashd/wire"
+	"github.com/eager7/dashutil"
+)
+
+const (
+	// addrDeltaIndexName is the human-readable name for the index.
+	addrDeltaIndexName = "address delta index"
+
+	// addrDeltaKeySize is the number of bytes a key in the deltas bucket
+	// consumes.  It consists of the address key + 4 bytes block height + 4
+	// bytes index of the transaction in the block + 32 bytes transaction
+	// hash + 4 bytes input or output index + 1 byte spending flag.
+	addrDeltaKeySize = addrKeySize + 4 + 4 + chainhash.HashSize + 4 + 1
+
+	// addrUtxoKeySize is the number of bytes a key in the unspent outputs
+	// bucket consumes.  It consists of the address key + 32 bytes
+	// transaction hash + 4 bytes output index.
+	addrUtxoKeySize = addrKeySize + chainhash.HashSize + 4
+
+	// addrUtxoValueMinSize is the minimum number of bytes a value in the
+	// unspent outputs bucket consumes.  It consists of 8 bytes amount + 4
+	// bytes block height + 1 byte coinbase flag, followed by the public
+	// key script.
+	addrUtxoValueMinSize = 8 + 4 + 1
+)
+
+var (
+	// addrDeltaIndexKey is the key of the address delta index and the db
+	// bucket used to house it.
+	addrDeltaIndexKey = []byte("addrdeltaidx")
+
+	// addrDeltasBucketName is the name of the bucket, below the index
+	// bucket, which houses the balance changes of the addresses.
+	addrDeltasBucketName = []byte("deltas")
+
+	// addrUtxosBucketName is the name of the bucket, below the index
+	// bucket, which houses the unspent outputs of the addresses.
+	addrUtxosBucketName = []byte("utxos")
+)
+
+// -----------------------------------------------------------------------------
+// The address delta index records every change to the balance of an address
+// and the set of outputs paying to an address which are unspent.  This is the
+// data the Insight-style address RPCs of Dash Core are based on.
+//
+// Only addresses which are paid by a standard pay-to-pubkey-hash,
+// pay-to-pubkey or pay-to-script-hash output are indexed.  Pay-to-pubkey
+// outputs are indexed under the hash of the public key.
+//
+// Each balance change is stored with a key of the address key, the big endian
+// height of the block, the big endian index of the transaction in the block,
+// the transaction hash, the big endian output index, or input index for
+// spends, and a flag which is set for spends.  The value is the signed
+// amount, which is negative for spends.  The big endian fields make the keys
+// of an address sort by height and position in the block, so ranges of
+// heights can be read with a cursor.
+//
+// Each unspent output is stored with a key of the address key, the hash of
+// the transaction and the big endian output index.  The value is the amount,
+// the height of the block, a coinbase flag and the public key script.
+// -----------------------------------------------------------------------------
+
+// AddrDelta describes a change to the balance of an address by a transaction
+// in the main chain.
+type AddrDelta struct {
+	// TxHash is the hash of the transaction.
+	TxHash chainhash.Hash
+
+	// Index is the index of the output which pays the address or, for
+	// spends, the index of the input which spends an output paying it.
+	Index uint32
+
+	// Spending is set when the change is a spend.
+	Spending bool
+
+	// Height is the height of the block containing the transaction.
+	Height int32
+
+	// BlockIndex is the index of the transaction in the block.
+	BlockIndex uint32
+
+	// Amount is the amount of the change, which is negative for spends.
+	Amount int64
+}
+
+// AddrUtxo describes an unspent output paying an address.
+type AddrUtxo struct {
+	OutPoint   wire.OutPoint
+	Amount     int64
+	PkScript   []byte
+	Height     int32
+	IsCoinBase bool
+}
+
+// UnconfirmedAddrDelta describes a change to the balance of an address by a
+// transaction in the memory pool.
+type UnconfirmedAddrDelta struct {
+	// TxHash is the hash of the transaction.
+	TxHash chainhash.Hash
+
+	// Index is the index of the output which pays the address or, for
+	// spends, the index of the input which spends an output paying it.
+	Index uint32
+
+	// Amount is the amount of the change, which is negative for spends.
+	Amount int64
+
+	// Time is when the transaction was added to the memory pool.
+	Time time.Time
+
+	// PrevOut is the spent output for spends and nil otherwise.
+	PrevOut *wire.OutPoint
+}
+
+// addrDeltaKey returns the key of the passed balance change of the passed
+// address in the deltas bucket.
+func addrDeltaKey(addrKey [addrKeySize]byte, delta *AddrDelta) []byte {
+	key := make([]byte, addrDeltaKeySize)
+	offset := copy(key, addrKey[:])
+	binary.BigEndian.PutUint32(key[offset:], uint32(delta.Height))
+	offset += 4
+	binary.BigEndian.PutUint32(key[offset:], delta.BlockIndex)
+	offset += 4
+	offset += copy(key[offset:], delta.TxHash[:])
+	binary.BigEndian.PutUint32(key[offset:], delta.Index)
+	offset += 4
+	if delta.Spending {
+		key[offset] = 1
+	}
+	return key
+}
+
+// deserializeAddrDelta decodes the balance change stored with the passed key
+// and value in the deltas bucket.
+func deserializeAddrDelta(key, value []byte) (*AddrDelta, error) {
+	if len(key) != addrDeltaKeySize || len(value) != 8 {
+		return nil, errDeserialize("unexpected address delta size")
+	}
+
+	var delta AddrDelta
+	offset := addrKeySize
+	delta.Height = int32(binary.BigEndian.Uint32(key[offset:]))
+	offset += 4
+	delta.BlockIndex = binary.BigEndian.Uint32(key[offset:])
+	offset += 4
+	offset += copy(delta.TxHash[:], key[offset:])
+	delta.Index = binary.BigEndian.Uint32(key[offset:])
+	offset += 4
+	delta.Spending = key[offset] != 0
+	delta.Amount = int64(byteOrder.Uint64(value))
+	return &delta, nil
+}
+
+// addrUtxoKey returns the key of the passed output paying the passed address
+// in the unspent outputs bucket.
+func addrUtxoKey(addrKey [addrKeySize]byte, outPoint *wire.OutPoint) []byte {
+	key := make([]byte, addrUtxoKeySize)
+	offset := copy(key, addrKey[:])
+	offset += copy(key[offset:], outPoint.Hash[:])
+	binary.BigEndian.PutUint32(key[offset:], outPoint.Index)
+	return key
+}
+
+// serializeAddrUtxo returns the value of the passed unspent output in the
+// unspent outputs bucket.
+func serializeAddrUtxo(utxo *AddrUtxo) []byte {
+	value := make([]byte, addrUtxoValueMinSize+len(utxo.PkScript))
+	byteOrder.PutUint64(value, uint64(utxo.Amount))
+	byteOrder.PutUint32(value[8:], uint32(utxo.Height))
+	if utxo.IsCoinBase {
+		value[12] = 1
+	}
+	copy(value[addrUtxoValueMinSize:], utxo.PkScript)
+	return value
+}
+
+// deserializeAddrUtxo decodes the unspent output stored with the passed key
+// and value in the unspent outputs bucket.
+func deserializeAddrUtxo(key, value []byte) (*AddrUtxo, error) {
+	if len(key) != addrUtxoKeySize || len(value) < addrUtxoValueMinSize {
+		return nil, errDeserialize("unexpected address utxo size")
+	}
+
+	var utxo AddrUtxo
+	copy(utxo.OutPoint.Hash[:], key[addrKeySize:])
+	utxo.OutPoint.Index = binary.BigEndian.Uint32(
+		key[addrKeySize+chainhash.HashSize:])
+	utxo.Amount = int64(byteOrder.Uint64(value))
+	utxo.Height = int32(byteOrder.Uint32(value[8:]))
+	utxo.IsCoinBase = value[12] != 0
+	utxo.PkScript = make([]byte, len(value)-addrUtxoValueMinSize)
+	copy(utxo.PkScript, value[addrUtxoValueMinSize:])
+	return &utxo, nil
+}
+
+// AddrDeltaIndex implements an index of the balance changes and unspent
+// outputs of addresses.  Unlike the AddrIndex, which only links addresses to
+// the transactions involving them, it records the amounts involved, so the
+// balance of an address can be determined without loading any transactions.
+//
+// In addition, support is provided for a memory-only index of the balance
+// changes by unconfirmed transactions such as those which are kept in the
+// memory pool before inclusion in a block.
+type AddrDeltaIndex struct {
+	// The following fields are set when the instance is created and can't
+	// be changed afterwards, so there is no need to protect them with a
+	// separate mutex.
+	db          database.DB
+	chainParams *chaincfg.Params
+
+	// The following fields are used to quickly look up the balance
+	// changes of transactions that have not been included into a block
+	// yet.  They are protected by the unconfirmedLock field.
+	//
+	// The deltasByAddr field maps addresses to the balance changes made
+	// to them by each unconfirmed transaction, while the addrsByTx field
+	// is the reverse and allows removing transactions efficiently.
+	unconfirmedLock sync.RWMutex
+	deltasByAddr    map[[addrKeySize]byte]map[chainhash.Hash][]UnconfirmedAddrDelta
+	addrsByTx       map[chainhash.Hash]map[[addrKeySize]byte]struct{}
+}
+
+// Ensure the AddrDeltaIndex type implements the Indexer interface.
+var _ Indexer = (*AddrDeltaIndex)(nil)
+
+// Ensure the AddrDeltaIndex type implements the NeedsInputser interface.
+var _ NeedsInputser = (*AddrDeltaIndex)(nil)
+
+// NeedsInputs signals that the index requires the referenced inputs in order
+// to properly create the index.
+//
+// This implements the NeedsInputser interface.
+func (idx *AddrDeltaIndex) NeedsInputs() bool {
+	return true
+}
+
+// Init is only provided to satisfy the Indexer interface as there is nothing to
+// initialize for this index.
+//
+// This is part of the Indexer interface.
+func (idx *AddrDeltaIndex) Init() error {
+	// Nothing to do.
+	return nil
+}
+
+// Key returns the database key to use for the index as a byte slice.
+//
+// This is part of the Indexer interface.
+func (idx *AddrDeltaIndex) Key() []byte {
+	return addrDeltaIndexKey
+}
+
+// Name returns the human-readable name of the index.
+//
+// This is part of the Indexer interface.
+func (idx *AddrDeltaIndex) Name() string {
+	return addrDeltaIndexName
+}
+
+// Create is invoked when the indexer manager determines the index needs
+// to be created for the first time.  It creates the buckets for the balance
+// changes and the unspent outputs.
+//
+// This is part of the Indexer interface.
+func (idx *AddrDeltaIndex) Create(dbTx database.Tx) error {
+	bucket, err := dbTx.Metadata().CreateBucket(addrDeltaIndexKey)
+	if err != nil {
+		return err
+	}
+	if _, err := bucket.CreateBucket(addrDeltasBucketName); err != nil {
+		return err
+	}
+	_, err = bucket.CreateBucket(addrUtxosBucketName)
+	return err
+}
+
+// indexedAddrKey returns the address key of the single address paid by the
+// passed public key script.  False is returned when the script is not one of
+// the indexed standard forms.
+func (idx *AddrDeltaIndex) indexedAddrKey(pkScript []byte) ([addrKeySize]byte, bool) {
+	class, addrs, _, err := txscript.ExtractPkScriptAddrs(pkScript,
+		idx.chainParams)
+	if err != nil || len(addrs) != 1 {
+		return [addrKeySize]byte{}, false
+	}
+	switch class {
+	case txscript.PubKeyHashTy, txscript.PubKeyTy, txscript.ScriptHashTy:
+	default:
+		return [addrKeySize]byte{}, false
+	}
+	addrKey, err := addrToKey(addrs[0])
+	if err != nil {
+		return [addrKeySize]byte{}, false
+	}
+	return addrKey, true
+}
+
+// ConnectBlock is invoked by the index manager when a new block has been
+// connected to the main chain.  This indexer adds the balance changes made by
+// the transactions in the block and updates the unspent outputs of the
+// addresses accordingly.
+//
+// This is part of the Indexer interface.
+func (idx *AddrDeltaIndex) ConnectBlock(dbTx database.Tx, block *dashutil.Block,
+	stxos []blockchain.SpentTxOut) error {
+
+	bucket := dbTx.Metadata().Bucket(addrDeltaIndexKey)
+	deltas := bucket.Bucket(addrDeltasBucketName)
+	utxos := bucket.Bucket(addrUtxosBucketName)
+
+	stxoIndex := 0
+	for txIdx, tx := range block.Transactions() {
+		msgTx := tx.MsgTx()
+		delta := AddrDelta{
+			TxHash:     *tx.Hash(),
+			Height:     block.Height(),
+			BlockIndex: uint32(txIdx),
+		}
+
+		// Coinbases do not reference any inputs.
+		if txIdx != 0 {
+			for i, txIn := range msgTx.TxIn {
+				stxo := &stxos[stxoIndex]
+				stxoIndex++
+				addrKey, ok := idx.indexedAddrKey(stxo.PkScript)
+				if !ok {
+					continue
+				}
+
+				delta.Index = uint32(i)
+				delta.Spending = true
+				delta.Amount = -stxo.Amount
+				err := deltas.Put(addrDeltaKey(addrKey, &delta),
+					serializeAmount(delta.Amount))
+				if err != nil {
+					return err
+				}
+				err = utxos.Delete(addrUtxoKey(addrKey,
+					&txIn.PreviousOutPoint))
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		for i, txOut := range msgTx.TxOut {
+			addrKey, ok := idx.indexedAddrKey(txOut.PkScript)
+			if !ok {
+				continue
+			}
+
+			delta.Index = uint32(i)
+			delta.Spending = false
+			delta.Amount = txOut.Value
+			err := deltas.Put(addrDeltaKey(addrKey, &delta),
+				serializeAmount(delta.Amount))
+			if err != nil {
+				return err
+			}
+			utxo := AddrUtxo{
+				OutPoint:   wire.OutPoint{Hash: *tx.Hash(), Index: uint32(i)},
+				Amount:     txOut.Value,
+				PkScript:   txOut.PkScript,
+				Height:     block.Height(),
+				IsCoinBase: txIdx == 0,
+			}
+			err = utxos.Put(addrUtxoKey(addrKey, &utxo.OutPoint),
+				serializeAddrUtxo(&utxo))
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// DisconnectBlock is invoked by the index manager when a block has been
+// disconnected from the main chain.  This indexer removes the balance changes
+// made by the transactions in the block and restores the unspent outputs they
+// spent.
+//
+// This is part of the Indexer interface.
+func (idx *AddrDeltaIndex) DisconnectBlock(dbTx database.Tx, block *dashutil.Block,
+	stxos []blockchain.SpentTxOut) error {
+
+	bucket := dbTx.Metadata().Bucket(addrDeltaIndexKey)
+	deltas := bucket.Bucket(addrDeltasBucketName)
+	utxos := bucket.Bucket(addrUtxosBucketName)
+
+	// The transactions are undone in reverse order so outputs which are
+	// created and spent in the block are restored before they are
+	// removed.
+	transactions := block.Transactions()
+	stxoIndex := len(stxos)
+	for txIdx := len(transactions) - 1; txIdx >= 0; txIdx-- {
+		tx := transactions[txIdx]
+		msgTx := tx.MsgTx()
+		delta := AddrDelta{
+			TxHash:     *tx.Hash(),
+			Height:     block.Height(),
+			BlockIndex: uint32(txIdx),
+		}
+
+		for i, txOut := range msgTx.TxOut {
+			addrKey, ok := idx.indexedAddrKey(txOut.PkScript)
+			if !ok {
+				continue
+			}
+
+			delta.Index = uint32(i)
+			err := deltas.Delete(addrDeltaKey(addrKey, &delta))
+			if err != nil {
+				return err
+			}
+			outPoint := wire.OutPoint{Hash: *tx.Hash(), Index: uint32(i)}
+			err = utxos.Delete(addrUtxoKey(addrKey, &outPoint))
+			if err != nil {
+				return err
+			}
+		}
+
+		if txIdx == 0 {
+			continue
+		}
+		stxoIndex -= len(msgTx.TxIn)
+		for i, txIn := range msgTx.TxIn {
+			stxo := &stxos[stxoIndex+i]
+			addrKey, ok := idx.indexedAddrKey(stxo.PkScript)
+			if !ok {
+				continue
+			}
+
+			delta.Index = uint32(i)
+			delta.Spending = true
+			err := deltas.Delete(addrDeltaKey(addrKey, &delta))
+			if err != nil {
+				return err
+			}
+			delta.Spending = false
+			utxo := AddrUtxo{
+				OutPoint:   txIn.PreviousOutPoint,
+				Amount:     stxo.Amount,
+				PkScript:   stxo.PkScript,
+				Height:     stxo.Height,
+				IsCoinBase: stxo.IsCoinBase,
+			}
+			err = utxos.Put(addrUtxoKey(addrKey, &utxo.OutPoint),
+				serializeAddrUtxo(&utxo))
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// serializeAmount returns the serialization of the passed amount.
+func serializeAmount(amount int64) []byte {
+	var value [8]byte
+	byteOrder.PutUint64(value[:], uint64(amount))
+	return value[:]
+}
+
+// AddrDeltas returns the balance changes of the passed address in the blocks
+// from the start height to the end height, both inclusive, ordered by height
+// and position in the block.  An end height of zero or less includes all
+// blocks after the start height.
+//
+// This function is safe for concurrent access.
+func (idx *AddrDeltaIndex) AddrDeltas(addr dashutil.Address, start, end int32) ([]AddrDelta, error) {
+	addrKey, err := addrToKey(addr)
+	if err != nil {
+		return nil, err
+	}
+	if start < 0 {
+		start = 0
+	}
+
+	var results []AddrDelta
+	err = idx.db.View(func(dbTx database.Tx) error {
+		seek := make([]byte, addrKeySize+4)
+		copy(seek, addrKey[:])
+		binary.BigEndian.PutUint32(seek[addrKeySize:], uint32(start))
+
+		cursor := dbTx.Metadata().Bucket(addrDeltaIndexKey).
+			Bucket(addrDeltasBucketName).Cursor()
+		for ok := cursor.Seek(seek); ok; ok = cursor.Next() {
+			key := cursor.Key()
+			if !bytes.HasPrefix(key, addrKey[:]) {
+				break
+			}
+			delta, err := deserializeAddrDelta(key, cursor.Value())
+			if err != nil {
+				return err
+			}
+			if end > 0 && delta.Height > end {
+				break
+			}
+			results = append(results, *delta)
+		}
+		return nil
+	})
+	return results, err
+}
+
+// AddrUtxos returns the unspent outputs paying the passed address ordered by
+// the height of the block they were created in.
+//
+// This function is safe for concurrent access.
+func (idx *AddrDeltaIndex) AddrUtxos(addr dashutil.Address) ([]AddrUtxo, error) {
+	addrKey, err := addrToKey(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []AddrUtxo
+	err = idx.db.View(func(dbTx database.Tx) error {
+		cursor := dbTx.Metadata().Bucket(addrDeltaIndexKey).
+			Bucket(addrUtxosBucketName).Cursor()
+		for ok := cursor.Seek(addrKey[:]); ok; ok = cursor.Next() {
+			key := cursor.Key()
+			if !bytes.HasPrefix(key, addrKey[:]) {
+				break
+			}
+			utxo, err := deserializeAddrUtxo(key, cursor.Value())
+			if err != nil {
+				return err
+			}
+			results = append(results, *utxo)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Height < results[j].Height
+	})
+	return results, nil
+}
+
+// addUnconfirmedDelta adds the passed balance change of the passed address to
+// the unconfirmed (memory-only) index.
+//
+// This function MUST be called with the unconfirmed lock held (for writes).
+func (idx *AddrDeltaIndex) addUnconfirmedDelta(addrKey [addrKeySize]byte, delta UnconfirmedAddrDelta) {
+	addrDeltas := idx.deltasByAddr[addrKey]
+	if addrDeltas == nil {
+		addrDeltas = make(map[chainhash.Hash][]UnconfirmedAddrDelta)
+		idx.deltasByAddr[addrKey] = addrDeltas
+	}
+	addrDeltas[delta.TxHash] = append(addrDeltas[delta.TxHash], delta)
+
+	addrs := idx.addrsByTx[delta.TxHash]
+	if addrs == nil {
+		addrs = make(map[[addrKeySize]byte]struct{})
+		idx.addrsByTx[delta.TxHash] = addrs
+	}
+	addrs[addrKey] = struct{}{}
+}
+
+// AddUnconfirmedTx adds the balance changes made by the transaction to the
+// unconfirmed (memory-only) index.
+//
+// NOTE: This transaction MUST have already been validated by the memory pool
+// before calling this function with it and have all of the inputs available in
+// the provided utxo view.  Failure to do so could result in some or all
+// balance changes not being indexed.
+//
+// This function is safe for concurrent access.
+func (idx *AddrDeltaIndex) AddUnconfirmedTx(tx *dashutil.Tx, utxoView *blockchain.UtxoViewpoint) {
+	now := time.Now()
+
+	idx.unconfirmedLock.Lock()
+	defer idx.unconfirmedLock.Unlock()
+
+	for i, txIn := range tx.MsgTx().TxIn {
+		entry := utxoView.LookupEntry(txIn.PreviousOutPoint)
+		if entry == nil {
+			// Ignore missing entries.  This should never happen
+			// in practice since the function comments specifically
+			// call out all inputs must be available.
+			continue
+		}
+		addrKey, ok := idx.indexedAddrKey(entry.PkScript())
+		if !ok {
+			continue
+		}
+		prevOut := txIn.PreviousOutPoint
+		idx.addUnconfirmedDelta(addrKey, UnconfirmedAddrDelta{
+			TxHash:  *tx.Hash(),
+			Index:   uint32(i),
+			Amount:  -entry.Amount(),
+			Time:    now,
+			PrevOut: &prevOut,
+		})
+	}
+
+	for i, txOut := range tx.MsgTx().TxOut {
+		addrKey, ok := idx.indexedAddrKey(txOut.PkScript)
+		if !ok {
+			continue
+		}
+		idx.addUnconfirmedDelta(addrKey, UnconfirmedAddrDelta{
+			TxHash: *tx.Hash(),
+			Index:  uint32(i),
+			Amount: txOut.Value,
+			Time:   now,
+		})
+	}
+}
+
+// RemoveUnconfirmedTx removes the passed transaction from the unconfirmed
+// (memory-only) index.
+//
+// This function is safe for concurrent access.
+func (idx *AddrDeltaIndex) RemoveUnconfirmedTx(hash *chainhash.Hash) {
+	idx.unconfirmedLock.Lock()
+	defer idx.unconfirmedLock.Unlock()
+
+	for addrKey := range idx.addrsByTx[*hash] {
+		delete(idx.deltasByAddr[addrKey], *hash)
+		if len(idx.deltasByAddr[addrKey]) == 0 {
+			delete(idx.deltasByAddr, addrKey)
+		}
+	}
+	delete(idx.addrsByTx, *hash)
+}
+
+// UnconfirmedDeltasForAddress returns the balance changes of the passed
+// address made by the transactions currently in the unconfirmed (memory-only)
+// index, ordered by the time the transactions were added.  Unsupported address
+// types are ignored and will result in no results.
+//
+// This function is safe for concurrent access.
+func (idx *AddrDeltaIndex) UnconfirmedDeltasForAddress(addr dashutil.Address) []UnconfirmedAddrDelta {
+	// Ignore unsupported address types.
+	addrKey, err := addrToKey(addr)
+	if err != nil {
+		return nil
+	}
+
+	idx.unconfirmedLock.RLock()
+	var results []UnconfirmedAddrDelta
+	for _, deltas := range idx.deltasByAddr[addrKey] {
+		results = append(results, deltas...)
+	}
+	idx.unconfirmedLock.RUnlock()
+
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Time.Before(results[j].Time)
+	})
+	return results
+}
+
+// NewAddrDeltaIndex returns a new instance of an indexer that is used to
+// record the balance changes and unspent outputs of all addresses in the
+// blockchain.
+//
+// It implements the Indexer interface which plugs into the IndexManager that in
+// turn is used by the blockchain package.  This allows the index to be
+// seamlessly maintained along with the chain.
+func NewAddrDeltaIndex(db database.DB, chainParams *chaincfg.Params) *AddrDeltaIndex {
+	return &AddrDeltaIndex{
+		db:           db,
+		chainParams:  chainParams,
+		deltasByAddr: make(map[[addrKeySize]byte]map[chainhash.Hash][]UnconfirmedAddrDelta),
+		addrsByTx:    make(map[chainhash.Hash]map[[addrKeySize]byte]struct{}),
+	}
+}
+
+// DropAddrDeltaIndex drops the address delta index from the provided database
+// if it exists.
+func DropAddrDeltaIndex(db database.DB, interrupt <-chan struct{}) error {
+	return dropIndex(db, addrDeltaIndexKey, addrDeltaIndexName, interrupt)
+}
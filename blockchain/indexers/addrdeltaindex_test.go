@@ -0,0 +1,70 @@
+// Copyright (c) 2016 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package indexers
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/eager7/dashd/chaincfg/chainhash"
+	"github.com/eager7/dashd/wire"
+)
+
+// TestAddrDeltaSerialization ensures balance changes and unspent outputs
+// round trip through their serialized forms and that the delta keys sort by
+// height and position in the block.
+func TestAddrDeltaSerialization(t *testing.T) {
+	t.Parallel()
+
+	var addrKey [addrKeySize]byte
+	addrKey[0] = addrKeyTypePubKeyHash
+	addrKey[1] = 0x55
+
+	txHash := chainhash.HashH([]byte("tx"))
+	deltas := []AddrDelta{
+		{TxHash: txHash, Index: 1, Height: 99, BlockIndex: 3, Amount: 5000},
+		{TxHash: txHash, Index: 0, Spending: true, Height: 100,
+			BlockIndex: 1, Amount: -5000},
+		{TxHash: txHash, Index: 2, Height: 100, BlockIndex: 2, Amount: 1},
+	}
+	var prevKey []byte
+	for i := range deltas {
+		key := addrDeltaKey(addrKey, &deltas[i])
+		if prevKey != nil && bytes.Compare(prevKey, key) >= 0 {
+			t.Fatalf("delta #%d: key does not sort after previous key", i)
+		}
+		prevKey = key
+
+		got, err := deserializeAddrDelta(key,
+			serializeAmount(deltas[i].Amount))
+		if err != nil {
+			t.Fatalf("delta #%d: unexpected error: %v", i, err)
+		}
+		if !reflect.DeepEqual(*got, deltas[i]) {
+			t.Fatalf("delta #%d: mismatched delta - got %+v, want %+v",
+				i, *got, deltas[i])
+		}
+	}
+	if _, err := deserializeAddrDelta(prevKey[1:], serializeAmount(0)); err == nil {
+		t.Fatal("short delta key: expected error")
+	}
+
+	utxo := AddrUtxo{
+		OutPoint:   *wire.NewOutPoint(&txHash, 7),
+		Amount:     123456789,
+		PkScript:   []byte{0x76, 0xa9, 0x14},
+		Height:     42,
+		IsCoinBase: true,
+	}
+	got, err := deserializeAddrUtxo(addrUtxoKey(addrKey, &utxo.OutPoint),
+		serializeAddrUtxo(&utxo))
+	if err != nil {
+		t.Fatalf("utxo: unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(*got, utxo) {
+		t.Fatalf("utxo: mismatched utxo - got %+v, want %+v", *got, utxo)
+	}
+}
@@ -7,6 +7,7 @@ package indexers
 import (
 	"bytes"
 	"fmt"
+	"strings"
 
 	"github.com/eager7/dashd/blockchain"
 	"github.com/eager7/dashd/chaincfg/chainhash"
@@ -306,6 +307,13 @@ func (m *Manager) Init(chain *blockchain.BlockChain, interrupt <-chan struct{})
 			var block *dashutil.Block
 			err := m.db.View(func(dbTx database.Tx) error {
 				blockBytes, err := dbTx.FetchBlock(hash)
+				if isBlockNotFoundErr(err) && chain.IsPruneMode() {
+					return fmt.Errorf("unable to remove "+
+						"orphaned block %v from %s since "+
+						"it has been pruned -- drop the "+
+						"index or resync without --prune",
+						hash, indexer.Name())
+				}
 				if err != nil {
 					return err
 				}
@@ -365,7 +373,15 @@ func (m *Manager) Init(chain *blockchain.BlockChain, interrupt <-chan struct{})
 	// lowest one so the catchup code only needs to start at the earliest
 	// block and is able to skip connecting the block for the indexes that
 	// don't need it.
+	//
+	// The indexes follow the history below a loaded utxo snapshot instead
+	// of the main chain until it has been validated, so they are only
+	// caught up to the validated history then.
 	bestHeight := chain.BestSnapshot().Height
+	historyHeight, validatingHistory := chain.HistoryTipHeight()
+	if validatingHistory {
+		bestHeight = historyHeight
+	}
 	lowestHeight := bestHeight
 	indexerHeights := make([]int32, len(m.enabledIndexes))
 	err = m.db.View(func(dbTx database.Tx) error {
@@ -394,6 +410,23 @@ func (m *Manager) Init(chain *blockchain.BlockChain, interrupt <-chan struct{})
 		return nil
 	}
 
+	// The blocks needed to catch up the indexes are no longer available
+	// when they have been pruned, so the indexes which are too far behind
+	// can't be brought up to date.
+	lowestAvailHeight := chain.LowestAvailableHeight()
+	if !validatingHistory && lowestHeight+1 < lowestAvailHeight {
+		var names []string
+		for i, indexer := range m.enabledIndexes {
+			if indexerHeights[i]+1 < lowestAvailHeight {
+				names = append(names, indexer.Name())
+			}
+		}
+		return fmt.Errorf("unable to catch up %s since the blocks "+
+			"below height %d have been pruned -- disable the "+
+			"index or resync without --prune",
+			strings.Join(names, ", "), lowestAvailHeight)
+	}
+
 	// Create a progress logger for the indexing process below.
 	progressLogger := newBlockProgressLogger("Indexed", log)
 
@@ -456,6 +489,13 @@ func (m *Manager) Init(chain *blockchain.BlockChain, interrupt <-chan struct{})
 	return nil
 }
 
+// isBlockNotFoundErr returns whether or not the passed error is a database
+// error with the ErrBlockNotFound error code.
+func isBlockNotFoundErr(err error) bool {
+	dbErr, ok := err.(database.Error)
+	return ok && dbErr.ErrorCode == database.ErrBlockNotFound
+}
+
 // indexNeedsInputs returns whether or not the index needs access to the txouts
 // referenced by the transaction inputs being indexed.
 func indexNeedsInputs(index Indexer) bool {
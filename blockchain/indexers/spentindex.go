@@ -0,0 +1,291 @@
+// Copyright (c) 2016 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package indexers
+
+import (
+	"sync"
+
+	"github.com/eager7/dashd/blockchain"
+	"github.com/eager7/dashd/chaincfg/chainhash"
+	"github.com/eager7/dashd/database"
+	"github.com/eager7/dashd/wire"
+	"github.com/eager7/dashutil"
+)
+
+const (
+	// spentIndexName is the human-readable name for the index.
+	spentIndexName = "spent index"
+
+	// spentKeySize is the number of bytes a key in the spent index
+	// consumes.  It consists of the 32 bytes hash of the transaction which
+	// created the output + 4 bytes output index.
+	spentKeySize = chainhash.HashSize + 4
+
+	// spentValueSize is the number of bytes a value in the spent index
+	// consumes.  It consists of the 32 bytes hash of the spending
+	// transaction + 4 bytes input index + 4 bytes block height.
+	spentValueSize = chainhash.HashSize + 4 + 4
+
+	// UnconfirmedSpendHeight is the height reported for spends by
+	// transactions which are not in a block yet.
+	UnconfirmedSpendHeight = -1
+)
+
+var (
+	// spentIndexKey is the key of the spent index and the db bucket used
+	// to house it.
+	spentIndexKey = []byte("spentidx")
+)
+
+// -----------------------------------------------------------------------------
+// The spent index maps every output spent in the main chain to the input which
+// spends it, matching the -spentindex of Dash Core.
+//
+// Each spend is stored with a key of the hash of the transaction which created
+// the output and the output index.  The value is the hash of the spending
+// transaction, the index of the spending input and the height of the block
+// containing the spending transaction.
+// -----------------------------------------------------------------------------
+
+// SpentInfo describes the input which spends an output.
+type SpentInfo struct {
+	// TxHash is the hash of the spending transaction.
+	TxHash chainhash.Hash
+
+	// Index is the index of the spending input.
+	Index uint32
+
+	// Height is the height of the block containing the spending
+	// transaction, or UnconfirmedSpendHeight when the spending
+	// transaction is in the memory pool.
+	Height int32
+}
+
+// spentKey returns the key of the passed outpoint in the spent index.
+func spentKey(outPoint *wire.OutPoint) []byte {
+	key := make([]byte, spentKeySize)
+	copy(key, outPoint.Hash[:])
+	byteOrder.PutUint32(key[chainhash.HashSize:], outPoint.Index)
+	return key
+}
+
+// serializeSpentInfo returns the value of the passed spend in the spent index.
+func serializeSpentInfo(info *SpentInfo) []byte {
+	value := make([]byte, spentValueSize)
+	copy(value, info.TxHash[:])
+	byteOrder.PutUint32(value[chainhash.HashSize:], info.Index)
+	byteOrder.PutUint32(value[chainhash.HashSize+4:], uint32(info.Height))
+	return value
+}
+
+// deserializeSpentInfo decodes the passed value of the spent index.
+func deserializeSpentInfo(value []byte) (*SpentInfo, error) {
+	if len(value) != spentValueSize {
+		return nil, errDeserialize("unexpected spent info size")
+	}
+
+	var info SpentInfo
+	copy(info.TxHash[:], value)
+	info.Index = byteOrder.Uint32(value[chainhash.HashSize:])
+	info.Height = int32(byteOrder.Uint32(value[chainhash.HashSize+4:]))
+	return &info, nil
+}
+
+// SpentIndex implements an index which maps spent outputs to the inputs which
+// spend them.
+//
+// In addition, support is provided for a memory-only index of the spends by
+// unconfirmed transactions such as those which are kept in the memory pool
+// before inclusion in a block.
+type SpentIndex struct {
+	// The following field is set when the instance is created and can't
+	// be changed afterwards, so there is no need to protect it with a
+	// separate mutex.
+	db database.DB
+
+	// The following fields are used to quickly look up the spends of
+	// transactions that have not been included into a block yet.  They
+	// are protected by the unconfirmedLock field.
+	//
+	// The spendsByOutPoint field maps outputs to the unconfirmed input
+	// spending them, while the outPointsByTx field is the reverse and
+	// allows removing transactions efficiently.
+	unconfirmedLock  sync.RWMutex
+	spendsByOutPoint map[wire.OutPoint]SpentInfo
+	outPointsByTx    map[chainhash.Hash][]wire.OutPoint
+}
+
+// Ensure the SpentIndex type implements the Indexer interface.
+var _ Indexer = (*SpentIndex)(nil)
+
+// Init is only provided to satisfy the Indexer interface as there is nothing to
+// initialize for this index.
+//
+// This is part of the Indexer interface.
+func (idx *SpentIndex) Init() error {
+	// Nothing to do.
+	return nil
+}
+
+// Key returns the database key to use for the index as a byte slice.
+//
+// This is part of the Indexer interface.
+func (idx *SpentIndex) Key() []byte {
+	return spentIndexKey
+}
+
+// Name returns the human-readable name of the index.
+//
+// This is part of the Indexer interface.
+func (idx *SpentIndex) Name() string {
+	return spentIndexName
+}
+
+// Create is invoked when the indexer manager determines the index needs
+// to be created for the first time.  It creates the bucket for the index.
+//
+// This is part of the Indexer interface.
+func (idx *SpentIndex) Create(dbTx database.Tx) error {
+	_, err := dbTx.Metadata().CreateBucket(spentIndexKey)
+	return err
+}
+
+// ConnectBlock is invoked by the index manager when a new block has been
+// connected to the main chain.  This indexer adds the spends of all outputs
+// spent by the transactions in the block.
+//
+// This is part of the Indexer interface.
+func (idx *SpentIndex) ConnectBlock(dbTx database.Tx, block *dashutil.Block,
+	stxos []blockchain.SpentTxOut) error {
+
+	bucket := dbTx.Metadata().Bucket(spentIndexKey)
+	for _, tx := range block.Transactions()[1:] {
+		for i, txIn := range tx.MsgTx().TxIn {
+			info := SpentInfo{
+				TxHash: *tx.Hash(),
+				Index:  uint32(i),
+				Height: block.Height(),
+			}
+			err := bucket.Put(spentKey(&txIn.PreviousOutPoint),
+				serializeSpentInfo(&info))
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// DisconnectBlock is invoked by the index manager when a block has been
+// disconnected from the main chain.  This indexer removes the spends of all
+// outputs spent by the transactions in the block.
+//
+// This is part of the Indexer interface.
+func (idx *SpentIndex) DisconnectBlock(dbTx database.Tx, block *dashutil.Block,
+	stxos []blockchain.SpentTxOut) error {
+
+	bucket := dbTx.Metadata().Bucket(spentIndexKey)
+	for _, tx := range block.Transactions()[1:] {
+		for _, txIn := range tx.MsgTx().TxIn {
+			err := bucket.Delete(spentKey(&txIn.PreviousOutPoint))
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// SpentInfo returns the input which spends the passed output.  The spends by
+// the transactions in the unconfirmed (memory-only) index take precedence over
+// those in the main chain.  Nil is returned when the output is not spent.
+//
+// This function is safe for concurrent access.
+func (idx *SpentIndex) SpentInfo(outPoint *wire.OutPoint) (*SpentInfo, error) {
+	idx.unconfirmedLock.RLock()
+	info, ok := idx.spendsByOutPoint[*outPoint]
+	idx.unconfirmedLock.RUnlock()
+	if ok {
+		return &info, nil
+	}
+
+	var spentInfo *SpentInfo
+	err := idx.db.View(func(dbTx database.Tx) error {
+		value := dbTx.Metadata().Bucket(spentIndexKey).Get(spentKey(outPoint))
+		if value == nil {
+			return nil
+		}
+		var err error
+		spentInfo, err = deserializeSpentInfo(value)
+		return err
+	})
+	return spentInfo, err
+}
+
+// AddUnconfirmedTx adds the spends of the transaction to the unconfirmed
+// (memory-only) index.
+//
+// NOTE: This transaction MUST have already been validated by the memory pool
+// before calling this function with it.
+//
+// This function is safe for concurrent access.
+func (idx *SpentIndex) AddUnconfirmedTx(tx *dashutil.Tx) {
+	idx.unconfirmedLock.Lock()
+	defer idx.unconfirmedLock.Unlock()
+
+	txIns := tx.MsgTx().TxIn
+	outPoints := make([]wire.OutPoint, 0, len(txIns))
+	for i, txIn := range txIns {
+		idx.spendsByOutPoint[txIn.PreviousOutPoint] = SpentInfo{
+			TxHash: *tx.Hash(),
+			Index:  uint32(i),
+			Height: UnconfirmedSpendHeight,
+		}
+		outPoints = append(outPoints, txIn.PreviousOutPoint)
+	}
+	idx.outPointsByTx[*tx.Hash()] = outPoints
+}
+
+// RemoveUnconfirmedTx removes the passed transaction from the unconfirmed
+// (memory-only) index.
+//
+// This function is safe for concurrent access.
+func (idx *SpentIndex) RemoveUnconfirmedTx(hash *chainhash.Hash) {
+	idx.unconfirmedLock.Lock()
+	defer idx.unconfirmedLock.Unlock()
+
+	for _, outPoint := range idx.outPointsByTx[*hash] {
+		// Only remove the spend when it still belongs to the transaction
+		// since a conflicting transaction may have replaced it.
+		if info, ok := idx.spendsByOutPoint[outPoint]; ok &&
+			info.TxHash == *hash {
+
+			delete(idx.spendsByOutPoint, outPoint)
+		}
+	}
+	delete(idx.outPointsByTx, *hash)
+}
+
+// NewSpentIndex returns a new instance of an indexer that is used to map all
+// spent outputs in the blockchain to the inputs which spend them.
+//
+// It implements the Indexer interface which plugs into the IndexManager that in
+// turn is used by the blockchain package.  This allows the index to be
+// seamlessly maintained along with the chain.
+func NewSpentIndex(db database.DB) *SpentIndex {
+	return &SpentIndex{
+		db:               db,
+		spendsByOutPoint: make(map[wire.OutPoint]SpentInfo),
+		outPointsByTx:    make(map[chainhash.Hash][]wire.OutPoint),
+	}
+}
+
+// DropSpentIndex drops the spent index from the provided database if it
+// exists.
+func DropSpentIndex(db database.DB, interrupt <-chan struct{}) error {
+	return dropIndex(db, spentIndexKey, spentIndexName, interrupt)
+}
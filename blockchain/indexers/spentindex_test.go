@@ -0,0 +1,76 @@
+// Copyright (c) 2016 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package indexers
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/eager7/dashd/chaincfg/chainhash"
+	"github.com/eager7/dashd/wire"
+	"github.com/eager7/dashutil"
+)
+
+// TestSpentInfoSerialization ensures spends round trip through their
+// serialized form.
+func TestSpentInfoSerialization(t *testing.T) {
+	t.Parallel()
+
+	tests := []SpentInfo{
+		{TxHash: chainhash.HashH([]byte("a")), Index: 3, Height: 1000},
+		{TxHash: chainhash.HashH([]byte("b")), Index: 0,
+			Height: UnconfirmedSpendHeight},
+	}
+	for i, test := range tests {
+		got, err := deserializeSpentInfo(serializeSpentInfo(&test))
+		if err != nil {
+			t.Fatalf("test #%d: unexpected error: %v", i, err)
+		}
+		if !reflect.DeepEqual(*got, test) {
+			t.Fatalf("test #%d: mismatched spend - got %+v, want %+v",
+				i, *got, test)
+		}
+	}
+
+	if _, err := deserializeSpentInfo(make([]byte, spentValueSize-1)); err == nil {
+		t.Fatal("short value: expected error")
+	}
+}
+
+// TestSpentIndexUnconfirmed ensures the spends of unconfirmed transactions are
+// added and removed from the memory-only index.
+func TestSpentIndexUnconfirmed(t *testing.T) {
+	t.Parallel()
+
+	prevHash := chainhash.HashH([]byte("prev"))
+	prevOut := wire.OutPoint{Hash: prevHash, Index: 1}
+	msgTx := wire.NewMsgTx(wire.TxVersion)
+	msgTx.AddTxIn(wire.NewTxIn(&wire.OutPoint{Hash: prevHash}, nil, nil))
+	msgTx.AddTxIn(wire.NewTxIn(&prevOut, nil, nil))
+	msgTx.AddTxOut(wire.NewTxOut(1, nil))
+	tx := dashutil.NewTx(msgTx)
+
+	idx := NewSpentIndex(nil)
+	idx.AddUnconfirmedTx(tx)
+	got, err := idx.SpentInfo(&prevOut)
+	if err != nil {
+		t.Fatalf("SpentInfo: unexpected error: %v", err)
+	}
+	want := SpentInfo{
+		TxHash: *tx.Hash(),
+		Index:  1,
+		Height: UnconfirmedSpendHeight,
+	}
+	if got == nil || *got != want {
+		t.Fatalf("SpentInfo: mismatched spend - got %+v, want %+v", got,
+			want)
+	}
+
+	idx.RemoveUnconfirmedTx(tx.Hash())
+	if len(idx.spendsByOutPoint) != 0 || len(idx.outPointsByTx) != 0 {
+		t.Fatalf("RemoveUnconfirmedTx: %d spends and %d transactions "+
+			"left", len(idx.spendsByOutPoint), len(idx.outPointsByTx))
+	}
+}
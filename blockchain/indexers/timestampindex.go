@@ -0,0 +1,228 @@
+// Copyright (c) 2016 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package indexers
+
+import (
+	"encoding/binary"
+
+	"github.com/eager7/dashd/blockchain"
+	"github.com/eager7/dashd/chaincfg/chainhash"
+	"github.com/eager7/dashd/database"
+	"github.com/eager7/dashutil"
+)
+
+const (
+	// timestampIndexName is the human-readable name for the index.
+	timestampIndexName = "timestamp index"
+
+	// blockTimestampKeySize is the number of bytes a key in the timestamps
+	// bucket consumes.  It consists of the 4 bytes big endian logical
+	// timestamp + 32 bytes block hash.
+	blockTimestampKeySize = 4 + chainhash.HashSize
+)
+
+var (
+	// timestampIndexKey is the key of the timestamp index and the db
+	// bucket used to house it.
+	timestampIndexKey = []byte("timestampidx")
+
+	// timestampsBucketName is the name of the bucket, below the index
+	// bucket, which houses the blocks ordered by their logical timestamp.
+	timestampsBucketName = []byte("timestamps")
+
+	// blockTimestampsBucketName is the name of the bucket, below the index
+	// bucket, which maps block hashes to their logical timestamp.
+	blockTimestampsBucketName = []byte("blocktimestamps")
+)
+
+// -----------------------------------------------------------------------------
+// The timestamp index orders blocks by their logical timestamp, matching the
+// -timestampindex of Dash Core.  Since the timestamps of blocks are not
+// required to increase, the logical timestamp of a block is its timestamp or,
+// when that is not after the logical timestamp of its parent, the logical
+// timestamp of the parent plus one.
+//
+// Each block is stored with a key of the big endian logical timestamp and the
+// block hash and an empty value, so ranges of time can be read with a cursor.
+// In addition, the logical timestamp of each block is stored with the block
+// hash as key, so the logical timestamp of the parent of a block can be found.
+//
+// Like in Dash Core, the entries of blocks which are disconnected from the
+// main chain are kept, so the index also reports blocks which are no longer
+// part of the main chain.
+// -----------------------------------------------------------------------------
+
+// TimestampBlock describes a block in the timestamp index.
+type TimestampBlock struct {
+	// Hash is the hash of the block.
+	Hash chainhash.Hash
+
+	// LogicalTime is the logical timestamp of the block.
+	LogicalTime uint32
+}
+
+// blockTimestampKey returns the key of the passed block in the timestamps
+// bucket.
+func blockTimestampKey(block *TimestampBlock) []byte {
+	key := make([]byte, blockTimestampKeySize)
+	binary.BigEndian.PutUint32(key, block.LogicalTime)
+	copy(key[4:], block.Hash[:])
+	return key
+}
+
+// deserializeBlockTimestampKey decodes the passed key of the timestamps bucket.
+func deserializeBlockTimestampKey(key []byte) (*TimestampBlock, error) {
+	if len(key) != blockTimestampKeySize {
+		return nil, errDeserialize("unexpected block timestamp key size")
+	}
+
+	var block TimestampBlock
+	block.LogicalTime = binary.BigEndian.Uint32(key)
+	copy(block.Hash[:], key[4:])
+	return &block, nil
+}
+
+// TimestampIndex implements an index which orders blocks by their logical
+// timestamp.
+type TimestampIndex struct {
+	db database.DB
+}
+
+// Ensure the TimestampIndex type implements the Indexer interface.
+var _ Indexer = (*TimestampIndex)(nil)
+
+// Init is only provided to satisfy the Indexer interface as there is nothing to
+// initialize for this index.
+//
+// This is part of the Indexer interface.
+func (idx *TimestampIndex) Init() error {
+	// Nothing to do.
+	return nil
+}
+
+// Key returns the database key to use for the index as a byte slice.
+//
+// This is part of the Indexer interface.
+func (idx *TimestampIndex) Key() []byte {
+	return timestampIndexKey
+}
+
+// Name returns the human-readable name of the index.
+//
+// This is part of the Indexer interface.
+func (idx *TimestampIndex) Name() string {
+	return timestampIndexName
+}
+
+// Create is invoked when the indexer manager determines the index needs
+// to be created for the first time.  It creates the buckets for the blocks
+// ordered by time and the logical timestamps of the blocks.
+//
+// This is part of the Indexer interface.
+func (idx *TimestampIndex) Create(dbTx database.Tx) error {
+	bucket, err := dbTx.Metadata().CreateBucket(timestampIndexKey)
+	if err != nil {
+		return err
+	}
+	if _, err := bucket.CreateBucket(timestampsBucketName); err != nil {
+		return err
+	}
+	_, err = bucket.CreateBucket(blockTimestampsBucketName)
+	return err
+}
+
+// ConnectBlock is invoked by the index manager when a new block has been
+// connected to the main chain.  This indexer adds the block under its logical
+// timestamp.
+//
+// This is part of the Indexer interface.
+func (idx *TimestampIndex) ConnectBlock(dbTx database.Tx, block *dashutil.Block,
+	stxos []blockchain.SpentTxOut) error {
+
+	bucket := dbTx.Metadata().Bucket(timestampIndexKey)
+	blockTimestamps := bucket.Bucket(blockTimestampsBucketName)
+
+	// The block may have been indexed before it was disconnected from the
+	// main chain, in which case it keeps its logical timestamp.
+	if blockTimestamps.Get(block.Hash()[:]) != nil {
+		return nil
+	}
+
+	header := &block.MsgBlock().Header
+	entry := TimestampBlock{
+		Hash:        *block.Hash(),
+		LogicalTime: uint32(header.Timestamp.Unix()),
+	}
+	if prev := blockTimestamps.Get(header.PrevBlock[:]); len(prev) == 4 {
+		prevLogicalTime := byteOrder.Uint32(prev)
+		if entry.LogicalTime <= prevLogicalTime {
+			entry.LogicalTime = prevLogicalTime + 1
+		}
+	}
+
+	err := bucket.Bucket(timestampsBucketName).Put(blockTimestampKey(&entry),
+		nil)
+	if err != nil {
+		return err
+	}
+	var value [4]byte
+	byteOrder.PutUint32(value[:], entry.LogicalTime)
+	return blockTimestamps.Put(entry.Hash[:], value[:])
+}
+
+// DisconnectBlock is invoked by the index manager when a block has been
+// disconnected from the main chain.  Like in Dash Core, the entries of the
+// block are kept, so there is nothing to do.
+//
+// This is part of the Indexer interface.
+func (idx *TimestampIndex) DisconnectBlock(dbTx database.Tx, block *dashutil.Block,
+	stxos []blockchain.SpentTxOut) error {
+
+	// Nothing to do.
+	return nil
+}
+
+// BlocksInRange returns the blocks with a logical timestamp from low,
+// inclusive, to high, exclusive, ordered by their logical timestamp.
+//
+// This function is safe for concurrent access.
+func (idx *TimestampIndex) BlocksInRange(low, high uint32) ([]TimestampBlock, error) {
+	var results []TimestampBlock
+	err := idx.db.View(func(dbTx database.Tx) error {
+		var seek [4]byte
+		binary.BigEndian.PutUint32(seek[:], low)
+
+		cursor := dbTx.Metadata().Bucket(timestampIndexKey).
+			Bucket(timestampsBucketName).Cursor()
+		for ok := cursor.Seek(seek[:]); ok; ok = cursor.Next() {
+			block, err := deserializeBlockTimestampKey(cursor.Key())
+			if err != nil {
+				return err
+			}
+			if block.LogicalTime >= high {
+				break
+			}
+			results = append(results, *block)
+		}
+		return nil
+	})
+	return results, err
+}
+
+// NewTimestampIndex returns a new instance of an indexer that is used to order
+// all blocks in the blockchain by their logical timestamp.
+//
+// It implements the Indexer interface which plugs into the IndexManager that in
+// turn is used by the blockchain package.  This allows the index to be
+// seamlessly maintained along with the chain.
+func NewTimestampIndex(db database.DB) *TimestampIndex {
+	return &TimestampIndex{db: db}
+}
+
+// DropTimestampIndex drops the timestamp index from the provided database if
+// it exists.
+func DropTimestampIndex(db database.DB, interrupt <-chan struct{}) error {
+	return dropIndex(db, timestampIndexKey, timestampIndexName, interrupt)
+}
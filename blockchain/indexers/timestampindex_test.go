@@ -0,0 +1,99 @@
+// Copyright (c) 2016 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package indexers
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/eager7/dashd/chaincfg/chainhash"
+	"github.com/eager7/dashd/database"
+	_ "github.com/eager7/dashd/database/ffldb"
+	"github.com/eager7/dashd/wire"
+	"github.com/eager7/dashutil"
+)
+
+// TestTimestampIndex ensures blocks are indexed under their logical timestamp,
+// which is increased past the logical timestamp of the parent when needed, and
+// that the entries survive disconnecting the blocks.
+func TestTimestampIndex(t *testing.T) {
+	t.Parallel()
+
+	dbPath, err := ioutil.TempDir("", "timestampindex")
+	if err != nil {
+		t.Fatalf("TempDir: unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dbPath)
+	db, err := database.Create("ffldb", filepath.Join(dbPath, "db"),
+		wire.MainNet)
+	if err != nil {
+		t.Fatalf("Create: unexpected error: %v", err)
+	}
+	defer db.Close()
+
+	idx := NewTimestampIndex(db)
+	err = db.Update(func(dbTx database.Tx) error {
+		return idx.Create(dbTx)
+	})
+	if err != nil {
+		t.Fatalf("Create index: unexpected error: %v", err)
+	}
+
+	// Connect a chain of blocks where the second block has a timestamp
+	// before the first one, followed by disconnecting the last block.
+	var prevHash chainhash.Hash
+	var blocks []*dashutil.Block
+	for i, timestamp := range []int64{1000, 990, 1005} {
+		header := wire.BlockHeader{
+			PrevBlock: prevHash,
+			Timestamp: time.Unix(timestamp, 0),
+			Nonce:     uint32(i),
+		}
+		block := dashutil.NewBlock(wire.NewMsgBlock(&header))
+		err := db.Update(func(dbTx database.Tx) error {
+			return idx.ConnectBlock(dbTx, block, nil)
+		})
+		if err != nil {
+			t.Fatalf("ConnectBlock #%d: unexpected error: %v", i, err)
+		}
+		blocks = append(blocks, block)
+		prevHash = *block.Hash()
+	}
+	err = db.Update(func(dbTx database.Tx) error {
+		return idx.DisconnectBlock(dbTx, blocks[2], nil)
+	})
+	if err != nil {
+		t.Fatalf("DisconnectBlock: unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		low, high uint32
+		want      []TimestampBlock
+	}{
+		{low: 0, high: 2000, want: []TimestampBlock{
+			{Hash: *blocks[0].Hash(), LogicalTime: 1000},
+			{Hash: *blocks[1].Hash(), LogicalTime: 1001},
+			{Hash: *blocks[2].Hash(), LogicalTime: 1005},
+		}},
+		{low: 1001, high: 1005, want: []TimestampBlock{
+			{Hash: *blocks[1].Hash(), LogicalTime: 1001},
+		}},
+		{low: 990, high: 1000, want: nil},
+	}
+	for i, test := range tests {
+		got, err := idx.BlocksInRange(test.low, test.high)
+		if err != nil {
+			t.Fatalf("BlocksInRange #%d: unexpected error: %v", i, err)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Fatalf("BlocksInRange #%d: mismatched blocks - got %v, "+
+				"want %v", i, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,406 @@
+// Copyright (c) 2013-2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/eager7/dashd/chaincfg"
+	"github.com/eager7/dashd/chaincfg/chainhash"
+	"github.com/eager7/dashd/database"
+	"github.com/eager7/dashd/wire"
+	"github.com/eager7/dashutil"
+)
+
+const (
+	// mnhfRequestIDPrefix is the prefix hashed together with the version
+	// bit to form the signing request ID of a masternode hard fork signal.
+	mnhfRequestIDPrefix = "mnhf"
+
+	// mnhfSignalEntrySize is the size of a single serialized signal, which
+	// consists of the version bit and the height of the block containing
+	// the signal.
+	mnhfSignalEntrySize = 5
+)
+
+// mnhfSignalsBucketName is the name of the db bucket used to house the
+// masternode hard fork signals as of each connected block.
+var mnhfSignalsBucketName = []byte("mnhfsignals")
+
+// mnhfSignals maps the version bits signaled by masternode quorums to the
+// height of the block which contains the signal.
+type mnhfSignals map[uint8]int32
+
+// IsMnHfTx returns whether or not the passed transaction is a masternode hard
+// fork signal special transaction.
+func IsMnHfTx(msgTx *wire.MsgTx) bool {
+	return msgTx.IsSpecial() && msgTx.Type == wire.TxTypeMnHfSignal
+}
+
+// checkMnHfTxSanity performs context free checks on a masternode hard fork
+// signal transaction.
+func checkMnHfTxSanity(msgTx *wire.MsgTx) error {
+	payload, err := msgTx.MnHfTxPayload()
+	if err != nil {
+		str := fmt.Sprintf("invalid masternode hard fork signal "+
+			"payload: %v", err)
+		return ruleError(ErrBadMnHfSignal, str)
+	}
+	if payload.Version == 0 || payload.Version > wire.MnHfTxPayloadVersion {
+		str := fmt.Sprintf("masternode hard fork signal payload "+
+			"version %d is not supported", payload.Version)
+		return ruleError(ErrBadMnHfSignal, str)
+	}
+	if payload.Signal.VersionBit >= vbNumBits {
+		str := fmt.Sprintf("masternode hard fork signal for version "+
+			"bit %d which is not less than %d",
+			payload.Signal.VersionBit, vbNumBits)
+		return ruleError(ErrBadMnHfSignal, str)
+	}
+
+	return nil
+}
+
+// MnHfSignHash returns the hash which must be signed by a quorum of the given
+// LLMQ type in order to authorize the passed masternode hard fork signal.  It
+// commits to the quorum, the signaled version bit and the transaction itself
+// with an empty signature.
+func MnHfSignHash(llmqType uint8, msgTx *wire.MsgTx, payload *wire.MnHfTxPayload) (chainhash.Hash, error) {
+	// The request ID only commits to the version bit so each bit can only
+	// be signed once by a quorum.
+	var buf bytes.Buffer
+	err := wire.WriteVarString(&buf, 0, mnhfRequestIDPrefix)
+	if err != nil {
+		return chainhash.Hash{}, err
+	}
+	var bit [8]byte
+	binary.LittleEndian.PutUint64(bit[:], uint64(payload.Signal.VersionBit))
+	buf.Write(bit[:])
+	requestID := chainhash.DoubleHashH(buf.Bytes())
+
+	// The message hash is the hash of the transaction with the signature
+	// removed from the payload.
+	unsigned := *payload
+	unsigned.Signal.Sig = [wire.QuorumSigSize]byte{}
+	buf.Reset()
+	if err := unsigned.Serialize(&buf); err != nil {
+		return chainhash.Hash{}, err
+	}
+	txCopy := *msgTx
+	txCopy.Payload = buf.Bytes()
+	msgHash := txCopy.TxHash()
+
+	return calcQuorumSignHash(llmqType, &payload.Signal.QuorumHash,
+		&requestID, &msgHash), nil
+}
+
+// serializeMnHfSignals returns the passed signals serialized as a count byte
+// followed by the version bit and little-endian block height of each signal.
+func serializeMnHfSignals(signals mnhfSignals) []byte {
+	serialized := make([]byte, 1, 1+len(signals)*mnhfSignalEntrySize)
+	serialized[0] = byte(len(signals))
+	for bit, height := range signals {
+		var entry [mnhfSignalEntrySize]byte
+		entry[0] = bit
+		binary.LittleEndian.PutUint32(entry[1:], uint32(height))
+		serialized = append(serialized, entry[:]...)
+	}
+
+	return serialized
+}
+
+// deserializeMnHfSignals decodes signals serialized by serializeMnHfSignals.
+func deserializeMnHfSignals(serialized []byte) (mnhfSignals, error) {
+	if len(serialized) == 0 ||
+		len(serialized) != 1+int(serialized[0])*mnhfSignalEntrySize {
+
+		return nil, database.Error{
+			ErrorCode: database.ErrCorruption,
+			Description: fmt.Sprintf("corrupt masternode hard fork "+
+				"signals entry of length %d", len(serialized)),
+		}
+	}
+
+	signals := make(mnhfSignals, serialized[0])
+	for offset := 1; offset < len(serialized); offset += mnhfSignalEntrySize {
+		entry := serialized[offset : offset+mnhfSignalEntrySize]
+		signals[entry[0]] = int32(binary.LittleEndian.Uint32(entry[1:]))
+	}
+
+	return signals, nil
+}
+
+// dbCreateMnHfSignalsBucket creates the bucket used to house the masternode
+// hard fork signals when it does not exist yet.
+func dbCreateMnHfSignalsBucket(dbTx database.Tx) error {
+	_, err := dbTx.Metadata().CreateBucketIfNotExists(mnhfSignalsBucketName)
+	return err
+}
+
+// dbFetchMnHfSignals uses an existing database transaction to fetch the stored
+// masternode hard fork signals as of the block with the given hash.  It
+// returns nil when there is no stored entry for the block.
+func dbFetchMnHfSignals(dbTx database.Tx, hash *chainhash.Hash) (mnhfSignals, error) {
+	bucket := dbTx.Metadata().Bucket(mnhfSignalsBucketName)
+	serialized := bucket.Get(hash[:])
+	if serialized == nil {
+		return nil, nil
+	}
+
+	return deserializeMnHfSignals(serialized)
+}
+
+// dbPutMnHfSignals uses an existing database transaction to store the
+// masternode hard fork signals as of the block with the given hash.
+func dbPutMnHfSignals(dbTx database.Tx, hash *chainhash.Hash, signals mnhfSignals) error {
+	bucket := dbTx.Metadata().Bucket(mnhfSignalsBucketName)
+	return bucket.Put(hash[:], serializeMnHfSignals(signals))
+}
+
+// dbRemoveMnHfSignals uses an existing database transaction to remove the
+// masternode hard fork signals as of the block with the given hash.
+func dbRemoveMnHfSignals(dbTx database.Tx, hash *chainhash.Hash) error {
+	return dbTx.Metadata().Bucket(mnhfSignalsBucketName).Delete(hash[:])
+}
+
+// blockMnHfSignals returns the payloads of the masternode hard fork signal
+// transactions in the passed block along with the transactions.
+func blockMnHfSignals(block *dashutil.Block) ([]*wire.MnHfTxPayload, []*dashutil.Tx, error) {
+	var payloads []*wire.MnHfTxPayload
+	var txns []*dashutil.Tx
+	for _, tx := range block.Transactions() {
+		msgTx := tx.MsgTx()
+		if !IsMnHfTx(msgTx) {
+			continue
+		}
+		payload, err := msgTx.MnHfTxPayload()
+		if err != nil {
+			str := fmt.Sprintf("invalid masternode hard fork "+
+				"signal payload: %v", err)
+			return nil, nil, ruleError(ErrBadMnHfSignal, str)
+		}
+		payloads = append(payloads, payload)
+		txns = append(txns, tx)
+	}
+
+	return payloads, txns, nil
+}
+
+// nextMnHfSignals returns the signals which result from adding the signals of
+// the passed payloads mined at the given height to the previous signals.
+func nextMnHfSignals(prev mnhfSignals, payloads []*wire.MnHfTxPayload, height int32) mnhfSignals {
+	signals := make(mnhfSignals, len(prev)+len(payloads))
+	for bit, signalHeight := range prev {
+		signals[bit] = signalHeight
+	}
+	for _, payload := range payloads {
+		signals[payload.Signal.VersionBit] = height
+	}
+
+	return signals
+}
+
+// fetchMnHfSignals returns the masternode hard fork signals mined in the chain
+// ending with the passed block node.  There are no signals before the v20
+// hard fork.  The signals of blocks which are not stored, such as those of a
+// side chain being validated during a reorganize, are calculated from the
+// most recent stored ancestor.
+//
+// This function MUST be called with the chain state lock held (for reads).
+func (b *BlockChain) fetchMnHfSignals(dbTx database.Tx, node *blockNode) (mnhfSignals, error) {
+	// Find the most recent ancestor with known signals while tracking the
+	// nodes they need to be calculated for.
+	signals := make(mnhfSignals)
+	var needed []*blockNode
+	for n := node; n != nil && n.height >= b.chainParams.V20Height; n = n.parent {
+		stored, err := dbFetchMnHfSignals(dbTx, &n.hash)
+		if err != nil {
+			return nil, err
+		}
+		if stored != nil {
+			signals = stored
+			break
+		}
+		needed = append(needed, n)
+	}
+
+	// Apply the blocks in order starting with the oldest one.
+	for i := len(needed) - 1; i >= 0; i-- {
+		n := needed[i]
+		block, err := dbFetchBlockByNode(dbTx, n)
+		if err != nil {
+			return nil, err
+		}
+		payloads, _, err := blockMnHfSignals(block)
+		if err != nil {
+			return nil, err
+		}
+		signals = nextMnHfSignals(signals, payloads, n.height)
+	}
+
+	return signals, nil
+}
+
+// activeMnHfSignals returns the subset of the passed signals mined in the
+// chain ending with the passed node which still apply to a defined EHF
+// deployment.  Signals which were mined before the start time of the
+// deployment using the same bit, such as those for an earlier deployment
+// which reused the bit, have expired.
+//
+// This function MUST be called with the chain state lock held (for reads).
+func (b *BlockChain) activeMnHfSignals(node *blockNode, signals mnhfSignals) mnhfSignals {
+	active := make(mnhfSignals, len(signals))
+	for bit, height := range signals {
+		deployment := b.ehfDeploymentByBit(bit)
+		if deployment == nil {
+			continue
+		}
+		signalNode := node.Ancestor(height)
+		if signalNode == nil {
+			continue
+		}
+		medianTime := uint64(signalNode.CalcPastMedianTime().Unix())
+		if medianTime < deployment.StartTime {
+			continue
+		}
+		active[bit] = height
+	}
+
+	return active
+}
+
+// ehfDeploymentByBit returns the EHF deployment which uses the passed version
+// bit or nil when there is none.
+func (b *BlockChain) ehfDeploymentByBit(bit uint8) *chaincfg.EHFDeployment {
+	for id := range b.chainParams.EHFDeployments {
+		deployment := &b.chainParams.EHFDeployments[id]
+		if deployment.BitNumber == bit {
+			return deployment
+		}
+	}
+
+	return nil
+}
+
+// checkMnHfSignals ensures the masternode hard fork signals of the passed
+// block are active, signal version bits of EHF deployments which are
+// accepting signals and have not been signaled yet, and are signed by a mined
+// quorum.
+//
+// The quorum signature itself is not verified since the public keys of mined
+// quorums are not verified against the members of the quorums.  See
+// checkQuorumCommitmentSanity.
+//
+// This function MUST be called with the chain state lock held (for writes).
+func (b *BlockChain) checkMnHfSignals(node *blockNode, block *dashutil.Block) error {
+	payloads, txns, err := blockMnHfSignals(block)
+	if err != nil {
+		return err
+	}
+	if len(payloads) == 0 {
+		return nil
+	}
+	if node.height < b.chainParams.V20Height {
+		str := fmt.Sprintf("transaction %v of type %v is not active "+
+			"before height %d", txns[0].Hash(), wire.TxTypeMnHfSignal,
+			b.chainParams.V20Height)
+		return ruleError(ErrSpecialTxNotActive, str)
+	}
+
+	return b.db.View(func(dbTx database.Tx) error {
+		signals, err := b.fetchMnHfSignals(dbTx, node.parent)
+		if err != nil {
+			return err
+		}
+		signals = b.activeMnHfSignals(node.parent, signals)
+
+		medianTime := uint64(node.parent.CalcPastMedianTime().Unix())
+		for _, payload := range payloads {
+			bit := payload.Signal.VersionBit
+			deployment := b.ehfDeploymentByBit(bit)
+			if deployment == nil || medianTime < deployment.StartTime ||
+				medianTime >= deployment.ExpireTime {
+
+				str := fmt.Sprintf("masternode hard fork signal "+
+					"for version bit %d which is not "+
+					"accepting signals", bit)
+				return ruleError(ErrBadMnHfSignal, str)
+			}
+
+			// Each version bit may only be signaled once.
+			if _, ok := signals[bit]; ok {
+				str := fmt.Sprintf("masternode hard fork signal "+
+					"for version bit %d which has already "+
+					"been signaled", bit)
+				return ruleError(ErrBadMnHfSignal, str)
+			}
+			signals[bit] = node.height
+
+			// The signal must be signed by a quorum of the hard
+			// fork signaling LLMQ type which has been mined.
+			quorumHash := &payload.Signal.QuorumHash
+			quorum, err := b.fetchMinedQuorum(dbTx, node.parent,
+				b.chainParams.LLMQTypeMnhf, quorumHash)
+			if err != nil {
+				return err
+			}
+			if quorum == nil {
+				str := fmt.Sprintf("masternode hard fork signal "+
+					"for version bit %d is signed by unknown "+
+					"quorum %v", bit, quorumHash)
+				return ruleError(ErrBadMnHfSignal, str)
+			}
+		}
+
+		return nil
+	})
+}
+
+// connectMnHfSignals stores the masternode hard fork signals as of the passed
+// block, which must be the new tip of the main chain.
+//
+// This function MUST be called with the chain state lock held (for writes).
+func (b *BlockChain) connectMnHfSignals(dbTx database.Tx, node *blockNode, block *dashutil.Block) error {
+	if node.height < b.chainParams.V20Height {
+		return nil
+	}
+
+	prevSignals, err := b.fetchMnHfSignals(dbTx, node.parent)
+	if err != nil {
+		return err
+	}
+	payloads, _, err := blockMnHfSignals(block)
+	if err != nil {
+		return err
+	}
+
+	signals := nextMnHfSignals(prevSignals, payloads, node.height)
+	return dbPutMnHfSignals(dbTx, &node.hash, signals)
+}
+
+// MnHfSignals returns the version bits which have been signaled by masternode
+// quorums in the main chain and still apply to a defined EHF deployment,
+// mapped to the height of the block containing the signal.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) MnHfSignals() (map[uint8]int32, error) {
+	b.chainLock.RLock()
+	defer b.chainLock.RUnlock()
+
+	tip := b.bestChain.Tip()
+	var signals mnhfSignals
+	err := b.db.View(func(dbTx database.Tx) error {
+		var err error
+		signals, err = b.fetchMnHfSignals(dbTx, tip)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return b.activeMnHfSignals(tip, signals), nil
+}
@@ -0,0 +1,150 @@
+// Copyright (c) 2015-2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"math/big"
+
+	"github.com/eager7/dashd/chaincfg/chainhash"
+	"github.com/eager7/dashd/database"
+	"golang.org/x/crypto/chacha20"
+)
+
+const (
+	// muHashElementSize is the size of the elements of the multiplicative
+	// group MuHash3072 operates in.
+	muHashElementSize = 384
+
+	// muHashStateSize is the size of a serialized MuHash3072 state.
+	muHashStateSize = 2 * muHashElementSize
+)
+
+var (
+	// muHashPrime is the modulus of the multiplicative group MuHash3072
+	// operates in, which is the largest 3072-bit safe prime 2^3072 - 1103717.
+	muHashPrime = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 3072),
+		big.NewInt(1103717))
+
+	// muHashZeroNonce is the nonce of the keystream which expands hashed
+	// data into a group element.
+	muHashZeroNonce = make([]byte, chacha20.NonceSize)
+)
+
+// muHash3072 is a rolling hash of a set of data elements which is compatible
+// with the MuHash3072 used by Bitcoin Core.  Elements can be added and removed
+// in any order and the resulting hash only depends on the set of elements.
+//
+// Each element is hashed to a 3072-bit number which is multiplied into the
+// numerator when the element is added and into the denominator when it is
+// removed.  The hash of the set is the SHA256 of the numerator divided by the
+// denominator in the group.
+type muHash3072 struct {
+	numerator   big.Int
+	denominator big.Int
+}
+
+// newMuHash3072 returns a MuHash3072 of the empty set.
+func newMuHash3072() *muHash3072 {
+	var m muHash3072
+	m.numerator.SetInt64(1)
+	m.denominator.SetInt64(1)
+	return &m
+}
+
+// muHashElement returns the group element the passed data hashes to.  The
+// SHA256 of the data is used as the key of a ChaCha20 keystream which is
+// interpreted as a little-endian number.
+func muHashElement(data []byte) *big.Int {
+	key := sha256.Sum256(data)
+	cipher, err := chacha20.NewUnauthenticatedCipher(key[:], muHashZeroNonce)
+	if err != nil {
+		// The key and nonce sizes are always valid.
+		panic(err)
+	}
+	var stream [muHashElementSize]byte
+	cipher.XORKeyStream(stream[:], stream[:])
+	return leBytesToInt(stream[:])
+}
+
+// leBytesToInt returns the number the passed little-endian bytes encode.
+func leBytesToInt(b []byte) *big.Int {
+	be := make([]byte, len(b))
+	for i := range b {
+		be[len(b)-1-i] = b[i]
+	}
+	return new(big.Int).SetBytes(be)
+}
+
+// putIntLE serializes the passed number, which must be less than the group
+// prime, into the passed buffer as a little-endian number.
+func putIntLE(target []byte, n *big.Int) {
+	be := n.Bytes()
+	for i := range target[:muHashElementSize] {
+		target[i] = 0
+	}
+	for i := range be {
+		target[len(be)-1-i] = be[i]
+	}
+}
+
+// Add adds the passed data element to the set.
+func (m *muHash3072) Add(data []byte) {
+	m.numerator.Mul(&m.numerator, muHashElement(data))
+	m.numerator.Mod(&m.numerator, muHashPrime)
+}
+
+// Remove removes the passed data element from the set.
+func (m *muHash3072) Remove(data []byte) {
+	m.denominator.Mul(&m.denominator, muHashElement(data))
+	m.denominator.Mod(&m.denominator, muHashPrime)
+}
+
+// Finalize returns the hash of the set.
+func (m *muHash3072) Finalize() chainhash.Hash {
+	var n big.Int
+	n.ModInverse(&m.denominator, muHashPrime)
+	n.Mul(&n, &m.numerator)
+	n.Mod(&n, muHashPrime)
+
+	var serialized [muHashElementSize]byte
+	putIntLE(serialized[:], &n)
+	return chainhash.Hash(sha256.Sum256(serialized[:]))
+}
+
+// serialize returns the serialization of the state of the hash, which is the
+// numerator followed by the denominator as little-endian numbers.
+func (m *muHash3072) serialize() []byte {
+	serialized := make([]byte, muHashStateSize)
+	putIntLE(serialized[:muHashElementSize], &m.numerator)
+	putIntLE(serialized[muHashElementSize:], &m.denominator)
+	return serialized
+}
+
+// deserializeMuHash3072 decodes the state of a hash from the passed serialized
+// bytes.
+func deserializeMuHash3072(serialized []byte) (*muHash3072, error) {
+	if len(serialized) != muHashStateSize {
+		return nil, database.Error{
+			ErrorCode: database.ErrCorruption,
+			Description: fmt.Sprintf("corrupt muhash state of %d "+
+				"bytes", len(serialized)),
+		}
+	}
+
+	var m muHash3072
+	m.numerator.Set(leBytesToInt(serialized[:muHashElementSize]))
+	m.denominator.Set(leBytesToInt(serialized[muHashElementSize:]))
+	if m.numerator.Cmp(muHashPrime) >= 0 || m.denominator.Sign() == 0 ||
+		m.denominator.Cmp(muHashPrime) >= 0 {
+
+		return nil, database.Error{
+			ErrorCode:   database.ErrCorruption,
+			Description: "corrupt muhash state",
+		}
+	}
+	return &m, nil
+}
@@ -0,0 +1,70 @@
+// Copyright (c) 2015-2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"testing"
+)
+
+// TestMuHash3072 ensures the MuHash3072 of sets matches the one calculated by
+// Bitcoin Core and only depends on the elements of the set.
+func TestMuHash3072(t *testing.T) {
+	t.Parallel()
+
+	// element returns the 32-byte data element whose first byte is the
+	// passed value, as used by the Bitcoin Core tests.
+	element := func(i byte) []byte {
+		data := make([]byte, 32)
+		data[0] = i
+		return data
+	}
+
+	// Start with the set containing only the first element as Bitcoin
+	// Core does.
+	m := newMuHash3072()
+	m.Add(element(0))
+	m.Add(element(1))
+	m.Remove(element(2))
+	const want = "10d312b100cbd32ada024a6646e40d3482fcff103668d2625f10002a607d5863"
+	if got := m.Finalize(); got.String() != want {
+		t.Fatalf("Finalize: got %v, want %v", got, want)
+	}
+
+	// Adding and removing elements in a different order must result in
+	// the same hash.
+	m2 := newMuHash3072()
+	m2.Remove(element(2))
+	m2.Add(element(3))
+	m2.Add(element(1))
+	m2.Remove(element(3))
+	m2.Add(element(0))
+	if m2.Finalize() != m.Finalize() {
+		t.Fatalf("Finalize: got %v for the same set in a different "+
+			"order, want %v", m2.Finalize(), m.Finalize())
+	}
+
+	// Removing all elements must result in the hash of the empty set.
+	m.Remove(element(0))
+	m.Remove(element(1))
+	m.Add(element(2))
+	if m.Finalize() != newMuHash3072().Finalize() {
+		t.Fatalf("Finalize: got %v for the empty set, want %v",
+			m.Finalize(), newMuHash3072().Finalize())
+	}
+
+	// The state must round trip through its serialization.
+	m3, err := deserializeMuHash3072(m2.serialize())
+	if err != nil {
+		t.Fatalf("deserializeMuHash3072: unexpected error: %v", err)
+	}
+	if m3.Finalize() != m2.Finalize() {
+		t.Fatalf("deserializeMuHash3072: got hash %v, want %v",
+			m3.Finalize(), m2.Finalize())
+	}
+	if _, err := deserializeMuHash3072(make([]byte, muHashStateSize)); err == nil {
+		t.Fatal("deserializeMuHash3072: unexpected success with zero " +
+			"denominator")
+	}
+}
@@ -0,0 +1,139 @@
+// Copyright (c) 2013-2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"github.com/eager7/dashd/chaincfg/chainhash"
+	"github.com/eager7/dashd/database"
+)
+
+const (
+	// MinBlocksToKeep is the number of blocks at the end of the main chain
+	// which are never pruned.  This keeps the data needed to handle
+	// reorganizations and is the number of recent blocks a pruned node is
+	// expected to serve to its peers per BIP0159.
+	MinBlocksToKeep = 288
+
+	// MinPruneTarget is the minimum target size in bytes of the stored
+	// blocks when pruning is enabled.
+	MinPruneTarget = 550 * 1024 * 1024
+)
+
+// initPruneState determines the height of the lowest block of the main chain
+// which is still stored when the database has been pruned.  It is only called
+// while the chain instance is created.
+func (b *BlockChain) initPruneState() error {
+	return b.db.View(func(dbTx database.Tx) error {
+		beenPruned, err := dbTx.BeenPruned()
+		if err != nil || !beenPruned {
+			return err
+		}
+
+		// The blocks are pruned in the order they are stored, so the
+		// pruned blocks of the main chain are a prefix of it and the
+		// lowest stored one can be found with a binary search.
+		low, high := int32(0), b.bestChain.Height()
+		for low < high {
+			mid := low + (high-low)/2
+			node := b.bestChain.NodeByHeight(mid)
+			exists, err := dbTx.HasBlock(&node.hash)
+			if err != nil {
+				return err
+			}
+			if exists {
+				high = mid
+			} else {
+				low = mid + 1
+			}
+		}
+		b.lowestAvailHeight = low
+
+		log.Infof("Blocks are pruned below height %d", low)
+		return nil
+	})
+}
+
+// pruneBlocks deletes the oldest stored blocks when the stored blocks use more
+// than the prune target, along with their spend journal entries which are no
+// longer needed since the blocks can't be disconnected anymore.  The passed
+// node, which is about to become the end of the main chain, and its latest
+// ancestors are always kept, as are the blocks connected since the utxo cache
+// was last flushed since they are needed to recover the utxo set after an
+// unclean shutdown.  The hashes of the deleted blocks are returned.
+//
+// This function MUST be called with the chain state lock held (for writes).
+func (b *BlockChain) pruneBlocks(dbTx database.Tx, node *blockNode) ([]chainhash.Hash, error) {
+	var flushHeight int32
+	lastFlush := b.utxoCache.lastFlush()
+	if flushNode := b.index.LookupNode(&lastFlush); flushNode != nil {
+		flushHeight = flushNode.height
+	}
+
+	keep := make([]chainhash.Hash, 0, MinBlocksToKeep)
+	for n := node; n != nil; n = n.parent {
+		if len(keep) >= MinBlocksToKeep && n.height <= flushHeight {
+			break
+		}
+		keep = append(keep, n.hash)
+	}
+
+	pruned, err := dbTx.PruneBlocks(b.pruneTarget, keep)
+	if err != nil {
+		return nil, err
+	}
+	for i := range pruned {
+		err := dbRemoveSpendJournalEntry(dbTx, &pruned[i])
+		if err != nil {
+			return nil, err
+		}
+	}
+	return pruned, nil
+}
+
+// markPruned updates the block index and the lowest stored block of the main
+// chain after the passed blocks have been deleted from the database.
+//
+// This function MUST be called with the chain state lock held (for writes).
+func (b *BlockChain) markPruned(pruned []chainhash.Hash) {
+	lowestAvailHeight := b.LowestAvailableHeight()
+	for i := range pruned {
+		node := b.index.LookupNode(&pruned[i])
+		if node == nil {
+			continue
+		}
+		b.index.UnsetStatusFlags(node, statusDataStored)
+		if b.bestChain.Contains(node) && node.height >= lowestAvailHeight {
+			lowestAvailHeight = node.height + 1
+		}
+	}
+
+	b.stateLock.Lock()
+	b.lowestAvailHeight = lowestAvailHeight
+	b.stateLock.Unlock()
+
+	log.Infof("Pruned %d blocks, blocks are pruned below height %d",
+		len(pruned), lowestAvailHeight)
+}
+
+// IsPruneMode returns whether or not the chain deletes old blocks to limit the
+// space used by the stored blocks.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) IsPruneMode() bool {
+	return b.pruneTarget != 0
+}
+
+// LowestAvailableHeight returns the height of the lowest block of the main
+// chain which is still stored.  All blocks of the main chain from this height
+// on are available, while lower blocks have been pruned.  It is zero when no
+// blocks have been pruned.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) LowestAvailableHeight() int32 {
+	b.stateLock.RLock()
+	height := b.lowestAvailHeight
+	b.stateLock.RUnlock()
+	return height
+}
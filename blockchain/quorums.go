@@ -0,0 +1,471 @@
+// Copyright (c) 2013-2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	"github.com/eager7/dashd/chaincfg/chainhash"
+	"github.com/eager7/dashd/database"
+	"github.com/eager7/dashd/wire"
+	"github.com/eager7/dashutil"
+)
+
+const (
+	// minedQuorumKeySize is the size of a key of the mined quorums bucket,
+	// which consists of the LLMQ type and the quorum hash.
+	minedQuorumKeySize = 1 + chainhash.HashSize
+
+	// minedQuorumHeightKeySize is the size of a key of the mined quorums by
+	// height bucket, which consists of the LLMQ type, the big-endian mined
+	// height and the quorum hash.
+	minedQuorumHeightKeySize = 1 + 4 + chainhash.HashSize
+
+	// minedQuorumEntrySize is the size of a value of the mined quorums by
+	// height bucket, which consists of the commitment version and the
+	// quorum public key.
+	minedQuorumEntrySize = 2 + wire.BLSPubKeySize
+
+	// quorumBackfillBlocks is the number of blocks before the v20 hard
+	// fork whose quorum commitments are recorded when upgrading a database
+	// which did not track them yet.  Every LLMQ type whose quorums are
+	// referenced by transactions checked by this package mines a new quorum
+	// at least once a day, so a day of blocks covers all quorums which may
+	// still sign.
+	quorumBackfillBlocks = 576
+)
+
+var (
+	// minedQuorumsBucketName is the name of the db bucket used to house the
+	// height of the block in the main chain which mined each quorum.
+	minedQuorumsBucketName = []byte("minedquorums")
+
+	// minedQuorumsByHeightBucketName is the name of the db bucket used to
+	// house the public keys of the quorums mined in the main chain ordered
+	// by the height they were mined at.
+	minedQuorumsByHeightBucketName = []byte("minedquorumsbyheight")
+
+	// minedQuorumsBackfillKeyName is the name of the db key used to store
+	// the height of the next block whose mined quorums are recorded while
+	// a database which did not track them yet is upgraded.
+	minedQuorumsBackfillKeyName = []byte("minedquorumsbackfill")
+)
+
+// minedQuorum describes a quorum whose final commitment has been mined.
+type minedQuorum struct {
+	quorumHash chainhash.Hash
+	height     int32
+	version    uint16
+	pubKey     [wire.BLSPubKeySize]byte
+}
+
+// newMinedQuorum returns the quorum of the passed commitment mined at the
+// passed height.
+func newMinedQuorum(commitment *wire.QuorumCommitment, height int32) *minedQuorum {
+	return &minedQuorum{
+		quorumHash: commitment.QuorumHash,
+		height:     height,
+		version:    commitment.Version,
+		pubKey:     commitment.QuorumPublicKey,
+	}
+}
+
+// IsQuorumCommitmentTx returns whether or not the passed transaction is a
+// quorum commitment special transaction.
+func IsQuorumCommitmentTx(msgTx *wire.MsgTx) bool {
+	return msgTx.IsSpecial() && msgTx.Type == wire.TxTypeQuorumCommitment
+}
+
+// checkQuorumCommitmentSanity performs context free checks on a quorum
+// commitment transaction.  The commitment itself is not verified against the
+// members of the quorum since that requires the deterministic masternode list,
+// which is maintained outside of this package, including the proof of service
+// penalties, which are not processed.  Any miner could therefore mine a quorum
+// with a public key of its choosing, so the public keys of mined quorums are
+// never used to verify what quorums sign.
+func checkQuorumCommitmentSanity(msgTx *wire.MsgTx) error {
+	payload, err := msgTx.QuorumCommitmentPayload()
+	if err != nil {
+		str := fmt.Sprintf("invalid quorum commitment payload: %v", err)
+		return ruleError(ErrBadQuorumCommitment, str)
+	}
+	if payload.Version == 0 ||
+		payload.Version > wire.QuorumCommitmentPayloadVersion {
+
+		str := fmt.Sprintf("quorum commitment payload version %d is "+
+			"not supported", payload.Version)
+		return ruleError(ErrBadQuorumCommitment, str)
+	}
+	version := payload.Commitment.Version
+	if version == 0 || version > wire.QuorumCommitmentVersionBasicIndexed {
+		str := fmt.Sprintf("quorum commitment version %d is not "+
+			"supported", version)
+		return ruleError(ErrBadQuorumCommitment, str)
+	}
+
+	return nil
+}
+
+// blockQuorumCommitments returns the commitments of the quorums mined by the
+// passed block.  Null commitments, which miners include for quorums that
+// failed to form, are skipped.
+func blockQuorumCommitments(block *dashutil.Block) ([]*wire.QuorumCommitment, error) {
+	var commitments []*wire.QuorumCommitment
+	for _, tx := range block.Transactions() {
+		msgTx := tx.MsgTx()
+		if !IsQuorumCommitmentTx(msgTx) {
+			continue
+		}
+		payload, err := msgTx.QuorumCommitmentPayload()
+		if err != nil {
+			str := fmt.Sprintf("invalid quorum commitment "+
+				"payload: %v", err)
+			return nil, ruleError(ErrBadQuorumCommitment, str)
+		}
+		if payload.Commitment.IsNull() {
+			continue
+		}
+		commitments = append(commitments, &payload.Commitment)
+	}
+
+	return commitments, nil
+}
+
+// -----------------------------------------------------------------------------
+// The quorums mined in the main chain are stored in two buckets.  The mined
+// quorums bucket maps the LLMQ type and quorum hash of each quorum to the
+// big-endian height of the block which mined its commitment.  The mined quorums
+// by height bucket is keyed by the LLMQ type, the big-endian mined height and
+// the quorum hash so the most recent quorums of a type can be found with a
+// cursor.  Its values are serialized as follows:
+//
+//   <version><public key>
+//
+//   Field             Type     Size
+//   version           uint16   2
+//   public key        []byte   48
+// -----------------------------------------------------------------------------
+
+// minedQuorumKey returns the key of the passed quorum in the mined quorums
+// bucket.
+func minedQuorumKey(llmqType uint8, quorumHash *chainhash.Hash) []byte {
+	key := make([]byte, minedQuorumKeySize)
+	key[0] = llmqType
+	copy(key[1:], quorumHash[:])
+	return key
+}
+
+// minedQuorumHeightKey returns the key of the passed quorum mined at the passed
+// height in the mined quorums by height bucket.
+func minedQuorumHeightKey(llmqType uint8, height int32, quorumHash *chainhash.Hash) []byte {
+	key := make([]byte, minedQuorumHeightKeySize)
+	key[0] = llmqType
+	binary.BigEndian.PutUint32(key[1:5], uint32(height))
+	copy(key[5:], quorumHash[:])
+	return key
+}
+
+// deserializeMinedQuorum decodes the quorum stored with the passed key and
+// value in the mined quorums by height bucket.
+func deserializeMinedQuorum(key, serialized []byte) (*minedQuorum, error) {
+	if len(key) != minedQuorumHeightKeySize ||
+		len(serialized) != minedQuorumEntrySize {
+
+		return nil, database.Error{
+			ErrorCode: database.ErrCorruption,
+			Description: fmt.Sprintf("corrupt mined quorum entry "+
+				"of %d bytes", len(serialized)),
+		}
+	}
+
+	var quorum minedQuorum
+	quorum.height = int32(binary.BigEndian.Uint32(key[1:5]))
+	copy(quorum.quorumHash[:], key[5:])
+	quorum.version = byteOrder.Uint16(serialized[0:2])
+	copy(quorum.pubKey[:], serialized[2:])
+	return &quorum, nil
+}
+
+// dbCreateMinedQuorumsBuckets creates the buckets used to house the quorums
+// mined in the main chain when they do not exist yet.
+func dbCreateMinedQuorumsBuckets(dbTx database.Tx) error {
+	meta := dbTx.Metadata()
+	_, err := meta.CreateBucketIfNotExists(minedQuorumsBucketName)
+	if err != nil {
+		return err
+	}
+	_, err = meta.CreateBucketIfNotExists(minedQuorumsByHeightBucketName)
+	return err
+}
+
+// dbPutMinedQuorums uses an existing database transaction to store the quorums
+// whose commitments are mined by the passed block.
+func dbPutMinedQuorums(dbTx database.Tx, height int32, block *dashutil.Block) error {
+	commitments, err := blockQuorumCommitments(block)
+	if err != nil {
+		return err
+	}
+
+	meta := dbTx.Metadata()
+	bucket := meta.Bucket(minedQuorumsBucketName)
+	heightBucket := meta.Bucket(minedQuorumsByHeightBucketName)
+	for _, commitment := range commitments {
+		var serializedHeight [4]byte
+		binary.BigEndian.PutUint32(serializedHeight[:], uint32(height))
+		key := minedQuorumKey(commitment.LLMQType, &commitment.QuorumHash)
+		err := bucket.Put(key, serializedHeight[:])
+		if err != nil {
+			return err
+		}
+
+		var entry [minedQuorumEntrySize]byte
+		byteOrder.PutUint16(entry[0:2], commitment.Version)
+		copy(entry[2:], commitment.QuorumPublicKey[:])
+		key = minedQuorumHeightKey(commitment.LLMQType, height,
+			&commitment.QuorumHash)
+		if err := heightBucket.Put(key, entry[:]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// dbRemoveMinedQuorums uses an existing database transaction to remove the
+// quorums whose commitments are mined by the passed block.
+func dbRemoveMinedQuorums(dbTx database.Tx, height int32, block *dashutil.Block) error {
+	commitments, err := blockQuorumCommitments(block)
+	if err != nil {
+		return err
+	}
+
+	meta := dbTx.Metadata()
+	bucket := meta.Bucket(minedQuorumsBucketName)
+	heightBucket := meta.Bucket(minedQuorumsByHeightBucketName)
+	for _, commitment := range commitments {
+		key := minedQuorumHeightKey(commitment.LLMQType, height,
+			&commitment.QuorumHash)
+		if err := heightBucket.Delete(key); err != nil {
+			return err
+		}
+
+		// Only remove the mined height when it was stored for this
+		// block.
+		key = minedQuorumKey(commitment.LLMQType, &commitment.QuorumHash)
+		serialized := bucket.Get(key)
+		if serialized != nil &&
+			int32(binary.BigEndian.Uint32(serialized)) == height {
+
+			if err := bucket.Delete(key); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// scanQuorums returns up to count of the most recent quorums of the passed
+// LLMQ type mined in the chain ending with the passed node, ordered from the
+// most recently mined one.
+//
+// This function MUST be called with the chain state lock held (for reads).
+func (b *BlockChain) scanQuorums(dbTx database.Tx, node *blockNode, llmqType uint8, count int) ([]*minedQuorum, error) {
+	// The buckets only contain the quorums of the main chain, so look at
+	// the blocks of the chain after the fork point first.
+	var quorums []*minedQuorum
+	fork := b.bestChain.FindFork(node)
+	for n := node; n != nil && n != fork && len(quorums) < count; n = n.parent {
+		block, err := dbFetchBlockByNode(dbTx, n)
+		if err != nil {
+			return nil, err
+		}
+		commitments, err := blockQuorumCommitments(block)
+		if err != nil {
+			return nil, err
+		}
+		for _, commitment := range commitments {
+			if commitment.LLMQType != llmqType {
+				continue
+			}
+			quorums = append(quorums, newMinedQuorum(commitment,
+				n.height))
+			if len(quorums) == count {
+				break
+			}
+		}
+	}
+	if fork == nil || len(quorums) == count {
+		return quorums, nil
+	}
+
+	// Walk the quorums of the type mined in the main chain up to the fork
+	// point backwards starting with the most recent one.
+	cursor := dbTx.Metadata().Bucket(minedQuorumsByHeightBucketName).Cursor()
+	seek := minedQuorumHeightKey(llmqType, fork.height+1, &chainhash.Hash{})
+	ok := cursor.Seek(seek)
+	if ok {
+		ok = cursor.Prev()
+	} else {
+		ok = cursor.Last()
+	}
+	for ; ok && len(quorums) < count; ok = cursor.Prev() {
+		key := cursor.Key()
+		if len(key) == 0 || key[0] != llmqType {
+			break
+		}
+		quorum, err := deserializeMinedQuorum(key, cursor.Value())
+		if err != nil {
+			return nil, err
+		}
+		quorums = append(quorums, quorum)
+	}
+
+	return quorums, nil
+}
+
+// fetchMinedQuorum returns the quorum of the passed LLMQ type with the passed
+// quorum hash when its commitment was mined in the chain ending with the passed
+// node.  It returns nil when it was not.
+//
+// This function MUST be called with the chain state lock held (for reads).
+func (b *BlockChain) fetchMinedQuorum(dbTx database.Tx, node *blockNode, llmqType uint8, quorumHash *chainhash.Hash) (*minedQuorum, error) {
+	// The buckets only contain the quorums of the main chain, so look at
+	// the blocks of the chain after the fork point first.
+	fork := b.bestChain.FindFork(node)
+	for n := node; n != nil && n != fork; n = n.parent {
+		block, err := dbFetchBlockByNode(dbTx, n)
+		if err != nil {
+			return nil, err
+		}
+		commitments, err := blockQuorumCommitments(block)
+		if err != nil {
+			return nil, err
+		}
+		for _, commitment := range commitments {
+			if commitment.LLMQType == llmqType &&
+				commitment.QuorumHash == *quorumHash {
+
+				return newMinedQuorum(commitment, n.height), nil
+			}
+		}
+	}
+	if fork == nil {
+		return nil, nil
+	}
+
+	meta := dbTx.Metadata()
+	serialized := meta.Bucket(minedQuorumsBucketName).Get(
+		minedQuorumKey(llmqType, quorumHash))
+	if len(serialized) != 4 {
+		return nil, nil
+	}
+	height := int32(binary.BigEndian.Uint32(serialized))
+	if height > fork.height {
+		return nil, nil
+	}
+	key := minedQuorumHeightKey(llmqType, height, quorumHash)
+	entry := meta.Bucket(minedQuorumsByHeightBucketName).Get(key)
+	if entry == nil {
+		return nil, nil
+	}
+	return deserializeMinedQuorum(key, entry)
+}
+
+// connectMinedQuorums stores the quorums whose commitments are mined by the
+// passed block, which must be the new tip of the main chain.
+func (b *BlockChain) connectMinedQuorums(dbTx database.Tx, node *blockNode, block *dashutil.Block) error {
+	return dbPutMinedQuorums(dbTx, node.height, block)
+}
+
+// backfillMinedQuorums records the quorums mined by the blocks of the main
+// chain from quorumBackfillBlocks blocks before the v20 hard fork on.  It is
+// used to upgrade databases which were created before mined quorums were
+// tracked, and resumes an interrupted upgrade where it left off.
+//
+// This function MUST be called with the chain state lock held (for writes).
+func (b *BlockChain) backfillMinedQuorums(interrupt <-chan struct{}) error {
+	start := b.chainParams.V20Height - quorumBackfillBlocks
+	if start < 1 {
+		start = 1
+	}
+	err := b.db.Update(func(dbTx database.Tx) error {
+		meta := dbTx.Metadata()
+		if serialized := meta.Get(minedQuorumsBackfillKeyName); serialized != nil {
+			start = int32(byteOrder.Uint32(serialized))
+			return nil
+		}
+		if meta.Bucket(minedQuorumsBucketName) != nil {
+			start = -1
+			return nil
+		}
+		if err := dbCreateMinedQuorumsBuckets(dbTx); err != nil {
+			return err
+		}
+		var serialized [4]byte
+		byteOrder.PutUint32(serialized[:], uint32(start))
+		return meta.Put(minedQuorumsBackfillKeyName, serialized[:])
+	})
+	if err != nil || start < 0 {
+		return err
+	}
+
+	tip := b.bestChain.Tip()
+	if low := b.lowestAvailHeight; start < low && start <= tip.height {
+		log.Warnf("Unable to record the quorums mined before height %d "+
+			"since the blocks are not available", low)
+		start = low
+	}
+	begin := time.Now()
+	if start <= tip.height {
+		log.Infof("Recording the quorums mined since height %d.  This "+
+			"might take a while...", start)
+	}
+	const batchSize = 2000
+	for height := start; height <= tip.height; height += batchSize {
+		err := b.db.Update(func(dbTx database.Tx) error {
+			end := height + batchSize
+			for h := height; h < end && h <= tip.height; h++ {
+				node := b.bestChain.NodeByHeight(h)
+				block, err := dbFetchBlockByNode(dbTx, node)
+				if err != nil {
+					return err
+				}
+				err = dbPutMinedQuorums(dbTx, h, block)
+				if err != nil {
+					return err
+				}
+			}
+
+			var serialized [4]byte
+			byteOrder.PutUint32(serialized[:], uint32(end))
+			return dbTx.Metadata().Put(minedQuorumsBackfillKeyName,
+				serialized[:])
+		})
+		if err != nil {
+			return err
+		}
+
+		if interruptRequested(interrupt) {
+			return errInterruptRequested
+		}
+	}
+
+	err = b.db.Update(func(dbTx database.Tx) error {
+		return dbTx.Metadata().Delete(minedQuorumsBackfillKeyName)
+	})
+	if err != nil {
+		return err
+	}
+
+	if start <= tip.height {
+		seconds := int64(time.Since(begin) / time.Second)
+		log.Infof("Done recording the mined quorums in %d seconds",
+			seconds)
+	}
+	return nil
+}
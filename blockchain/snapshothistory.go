@@ -0,0 +1,371 @@
+// Copyright (c) 2015-2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"crypto/sha256"
+	"fmt"
+
+	"github.com/eager7/dashd/chaincfg/chainhash"
+	"github.com/eager7/dashd/database"
+	"github.com/eager7/dashutil"
+)
+
+// initHistoryState loads the progress of validating the history below the base
+// of a loaded utxo snapshot.  It also removes the unspent outputs written by an
+// attempt to load a snapshot which did not complete.
+func (b *BlockChain) initHistoryState() error {
+	var baseRecord []byte
+	var historyHash *chainhash.Hash
+	err := b.db.View(func(dbTx database.Tx) error {
+		baseRecord = dbTx.Metadata().Get(utxoSnapshotBaseKeyName)
+		var err error
+		historyHash, err = dbFetchUtxoStateConsistency(dbTx,
+			historyUtxoStateConsistencyKeyName)
+		return err
+	})
+	if err != nil || baseRecord == nil {
+		return err
+	}
+	if len(baseRecord) != 2*chainhash.HashSize {
+		return database.Error{
+			ErrorCode:   database.ErrCorruption,
+			Description: "corrupt utxo snapshot base record",
+		}
+	}
+
+	var baseHash chainhash.Hash
+	copy(baseHash[:], baseRecord)
+	base := b.index.LookupNode(&baseHash)
+	if base == nil || historyHash == nil {
+		log.Warnf("Removing utxo snapshot at block %v which was not "+
+			"loaded completely", baseHash)
+		return b.resetUtxoSnapshotLoad()
+	}
+	historyTip := b.index.LookupNode(historyHash)
+	if !b.bestChain.Contains(base) || historyTip == nil ||
+		!b.bestChain.Contains(historyTip) {
+
+		return AssertError(fmt.Sprintf("history below utxo snapshot at "+
+			"block %v is validated up to block %v which is not in "+
+			"the main chain", baseHash, historyHash))
+	}
+	b.snapshotBase = base
+	copy(b.snapshotContentHash[:], baseRecord[chainhash.HashSize:])
+	b.historyTip = historyTip
+	b.historyCache.flushed(historyHash)
+	if base.height > b.lowestAvailHeight {
+		b.lowestAvailHeight = base.height
+	}
+
+	log.Infof("Validating the history below the utxo snapshot at height "+
+		"%d in the background, validated up to height %d", base.height,
+		historyTip.height)
+	return nil
+}
+
+// HistoryTipHeight returns the height of the final block below the base of a
+// loaded utxo snapshot whose history has been validated, and whether or not
+// such a history is being validated.  The optional indexes follow the history
+// instead of the main chain until it has been validated, after which they are
+// caught up with the main chain.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) HistoryTipHeight() (int32, bool) {
+	b.chainLock.RLock()
+	defer b.chainLock.RUnlock()
+
+	if b.snapshotBase == nil {
+		return 0, false
+	}
+	return b.historyTip.height, true
+}
+
+// NextHistoricalBlocks returns the hashes of up to the passed number of the
+// next blocks below the base of a loaded utxo snapshot which need to be added
+// with ProcessHistoricalBlock, ordered by height.  Nothing is returned when no
+// snapshot has been loaded or the history has been validated.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) NextHistoricalBlocks(maxHashes int) []chainhash.Hash {
+	b.chainLock.RLock()
+	defer b.chainLock.RUnlock()
+
+	if b.snapshotBase == nil {
+		return nil
+	}
+
+	var hashes []chainhash.Hash
+	node := b.bestChain.Next(b.historyTip)
+	if b.historyTip.height >= b.snapshotBase.height {
+		node = nil
+	}
+	for node != nil && node != b.snapshotBase && len(hashes) < maxHashes {
+		hashes = append(hashes, node.hash)
+		node = b.bestChain.Next(node)
+	}
+	return hashes
+}
+
+// ProcessHistoricalBlock validates the passed block, which must be the next
+// block below the base of a loaded utxo snapshot as returned by
+// NextHistoricalBlocks, and stores it.
+//
+// The blocks are validated against a separate utxo set built from the history
+// alone.  Once all blocks below the snapshot base have been added, the utxo set
+// resulting from them is compared with the snapshot, which completes the
+// validation of the chain.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) ProcessHistoricalBlock(block *dashutil.Block) error {
+	b.chainLock.Lock()
+	defer b.chainLock.Unlock()
+
+	if b.snapshotBase == nil {
+		return fmt.Errorf("no history below a utxo snapshot needs to " +
+			"be validated")
+	}
+	node := b.bestChain.Next(b.historyTip)
+	if node == nil || node.height >= b.snapshotBase.height ||
+		node.hash != *block.Hash() {
+		return fmt.Errorf("block %v is not the next block below the "+
+			"utxo snapshot", block.Hash())
+	}
+
+	if err := b.connectHistoricalBlock(node, block); err != nil {
+		return err
+	}
+	return b.maybeFinishHistory()
+}
+
+// connectHistoricalBlock validates the passed block below the base of a loaded
+// utxo snapshot against the utxo set of the history and stores it along with
+// the resulting changes.
+//
+// This function MUST be called with the chain state lock held (for writes).
+func (b *BlockChain) connectHistoricalBlock(node *blockNode, block *dashutil.Block) error {
+	block.SetHeight(node.height)
+	err := checkBlockSanity(block, b.chainParams.PowLimit, b.timeSource,
+		BFNone)
+	if err != nil {
+		return err
+	}
+	if err := b.checkBlockContext(block, node.parent, BFNone); err != nil {
+		return err
+	}
+
+	// The inputs are loaded from the utxo set of the history before the
+	// block is checked, so none of them are loaded from the utxo set of
+	// the main chain.
+	view := NewUtxoViewpoint()
+	view.SetBestHash(&node.parent.hash)
+	if err := view.fetchInputUtxos(b.historyCache, block); err != nil {
+		return err
+	}
+	stxos := make([]SpentTxOut, 0, countSpentOutputs(block))
+	if err := b.checkConnectBlock(node, block, view, &stxos); err != nil {
+		return err
+	}
+
+	err = b.db.Update(func(dbTx database.Tx) error {
+		if err := dbStoreBlock(dbTx, block); err != nil {
+			return err
+		}
+		err := dbPutSpendJournalEntry(dbTx, block.Hash(), stxos)
+		if err != nil {
+			return err
+		}
+		err = b.historyCache.flush(dbTx, view, &node.hash)
+		if err != nil {
+			return err
+		}
+		err = connectUtxoStats(dbTx, historyUtxoMuHashKeyName, node,
+			block, stxos)
+		if err != nil {
+			return err
+		}
+
+		// Recalculate the Dash specific chain state of the block, which
+		// replaces the state loaded from the snapshot for the most
+		// recent blocks.
+		if err := b.connectCreditPool(dbTx, node, block); err != nil {
+			return err
+		}
+		if err := b.connectMnHfSignals(dbTx, node, block); err != nil {
+			return err
+		}
+		if err := b.connectMinedQuorums(dbTx, node, block); err != nil {
+			return err
+		}
+
+		// The optional indexes are built from the history, since the
+		// blocks below the snapshot base were not available when the
+		// snapshot was loaded.
+		if b.indexManager != nil {
+			return b.indexManager.ConnectBlock(dbTx, block, stxos)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	b.historyCache.flushed(&node.hash)
+	b.historyTip = node
+
+	b.index.SetStatusFlags(node, statusDataStored|statusValid)
+	if err := b.index.flushToDB(); err != nil {
+		return err
+	}
+
+	if node.height%10000 == 0 {
+		log.Infof("Validated the history below the utxo snapshot at "+
+			"height %d up to height %d", b.snapshotBase.height,
+			node.height)
+	}
+	return nil
+}
+
+// maybeFinishHistory validates the base of a loaded utxo snapshot once all of
+// the blocks below it have been validated and ensures the resulting utxo set
+// and chain state match the snapshot.  The optional indexes are then caught up
+// with the main chain, after which the snapshot is no longer tracked.
+//
+// This function MUST be called with the chain state lock held (for writes).
+func (b *BlockChain) maybeFinishHistory() error {
+	base := b.snapshotBase
+	if b.historyTip == base.parent {
+		var block *dashutil.Block
+		err := b.db.View(func(dbTx database.Tx) error {
+			var err error
+			block, err = dbFetchBlockByNode(dbTx, base)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		if err := b.connectHistoricalBlock(base, block); err != nil {
+			return err
+		}
+	}
+	if b.historyTip.height < base.height {
+		return nil
+	}
+
+	// The history tip moves beyond the snapshot base while the indexes
+	// are caught up, so the utxo set resulting from the history has
+	// already been checked when it is past the base.
+	if b.historyTip == base {
+		if err := b.checkHistoryContentHash(); err != nil {
+			return err
+		}
+	}
+	if err := b.catchUpHistoryIndexes(); err != nil {
+		return err
+	}
+
+	err := b.db.Update(func(dbTx database.Tx) error {
+		meta := dbTx.Metadata()
+		if err := meta.DeleteBucket(historyUtxoSetBucketName); err != nil {
+			return err
+		}
+		err := meta.Delete(historyUtxoStateConsistencyKeyName)
+		if err != nil {
+			return err
+		}
+		if err := meta.Delete(historyUtxoMuHashKeyName); err != nil {
+			return err
+		}
+		return meta.Delete(utxoSnapshotBaseKeyName)
+	})
+	if err != nil {
+		return err
+	}
+	b.snapshotBase = nil
+	b.historyTip = nil
+
+	b.stateLock.Lock()
+	if b.pruneTarget == 0 {
+		b.lowestAvailHeight = 0
+	}
+	b.stateLock.Unlock()
+
+	log.Infof("Validated the history below the utxo snapshot at block %v "+
+		"(height %d)", base.hash, base.height)
+	return nil
+}
+
+// checkHistoryContentHash ensures the utxo set and chain state resulting from
+// the history below a loaded utxo snapshot match the snapshot.
+//
+// This function MUST be called with the chain state lock held (for reads).
+func (b *BlockChain) checkHistoryContentHash() error {
+	base := b.snapshotBase
+	var contentHash chainhash.Hash
+	err := b.db.View(func(dbTx database.Tx) error {
+		hasher := sha256.New()
+		_, err := writeUtxoRecords(dbTx, historyUtxoSetBucketName, hasher)
+		if err != nil {
+			return err
+		}
+		err = b.writeChainStateRecords(dbTx, base, hasher)
+		if err != nil {
+			return err
+		}
+		contentHash = chainhash.HashH(hasher.Sum(nil))
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if contentHash != b.snapshotContentHash {
+		str := fmt.Sprintf("the history below the utxo snapshot at "+
+			"block %v results in content hash %v instead of %v",
+			base.hash, contentHash, b.snapshotContentHash)
+		log.Errorf("%s -- the utxo snapshot is invalid", str)
+		return AssertError(str)
+	}
+	return nil
+}
+
+// catchUpHistoryIndexes connects the blocks of the main chain after the base of
+// a loaded utxo snapshot to the optional indexes once the history below it has
+// been validated, since the indexes follow the history until then.  The history
+// tip tracks the progress, so it is resumed after an unclean shutdown.
+//
+// This function MUST be called with the chain state lock held (for writes).
+func (b *BlockChain) catchUpHistoryIndexes() error {
+	if b.indexManager == nil {
+		return nil
+	}
+
+	node := b.bestChain.Next(b.historyTip)
+	if node != nil {
+		log.Infof("Catching up indexes from height %d to %d",
+			b.historyTip.height, b.bestChain.Height())
+	}
+	for ; node != nil; node = b.bestChain.Next(node) {
+		err := b.db.Update(func(dbTx database.Tx) error {
+			block, err := dbFetchBlockByNode(dbTx, node)
+			if err != nil {
+				return err
+			}
+			stxos, err := dbFetchSpendJournalEntry(dbTx, block)
+			if err != nil {
+				return err
+			}
+			err = b.indexManager.ConnectBlock(dbTx, block, stxos)
+			if err != nil {
+				return err
+			}
+			return dbPutUtxoStateConsistency(dbTx,
+				historyUtxoStateConsistencyKeyName, &node.hash)
+		})
+		if err != nil {
+			return err
+		}
+		b.historyTip = node
+	}
+	return nil
+}
@@ -600,5 +600,20 @@ func (b *BlockChain) maybeUpgradeDbBuckets(interrupt <-chan struct{}) error {
 		}
 	}
 
-	return nil
+	// Create the credit pool and masternode hard fork signal buckets for
+	// databases which were created before they existed.  The entries of
+	// any blocks connected in the meantime are calculated on demand.
+	err = b.db.Update(func(dbTx database.Tx) error {
+		if err := dbCreateCreditPoolBuckets(dbTx); err != nil {
+			return err
+		}
+		return dbCreateMnHfSignalsBucket(dbTx)
+	})
+	if err != nil {
+		return err
+	}
+
+	// Record the quorums mined by the blocks which were connected before
+	// mined quorums were tracked.
+	return b.backfillMinedQuorums(interrupt)
 }
@@ -0,0 +1,380 @@
+// Copyright (c) 2015-2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/eager7/dashd/chaincfg/chainhash"
+	"github.com/eager7/dashd/database"
+	"github.com/eager7/dashd/wire"
+	"github.com/eager7/dashutil"
+)
+
+const (
+	// DefaultUtxoCacheMaxSize is the default maximum size in bytes of the
+	// unspent transaction outputs held by the utxo cache.
+	DefaultUtxoCacheMaxSize = 250 * 1024 * 1024
+
+	// utxoFlushPeriodicInterval is the maximum time between flushes of the
+	// utxo cache.  It limits the number of blocks which have to be replayed
+	// to recover the utxo set after an unclean shutdown.
+	utxoFlushPeriodicInterval = 5 * time.Minute
+
+	// utxoEntryOverhead is a rough estimate of the number of bytes a cached
+	// utxo entry consumes in addition to its public key script.  It covers
+	// the entry itself, the outpoint used as its key and the overhead of
+	// the map housing it.
+	utxoEntryOverhead = 128
+)
+
+// utxoEntrySize returns the estimated number of bytes the passed entry consumes
+// in the utxo cache.
+func utxoEntrySize(entry *UtxoEntry) uint64 {
+	return utxoEntryOverhead + uint64(len(entry.pkScript))
+}
+
+// utxoCache houses unspent transaction outputs of the main chain in memory in
+// front of the utxo set in the database.  Outputs loaded from the database are
+// kept in the cache and all modifications made by connecting and disconnecting
+// blocks are applied to it, so the database is only updated when the cache is
+// flushed.  This avoids writing outputs which are created and spent in between
+// flushes at all and allows the writes of many blocks to be batched.
+//
+// Since the best chain state is still updated with every block, the hash of
+// the block the utxo set in the database is consistent with is recorded with
+// every flush, so the blocks connected after the last flush can be replayed
+// after an unclean shutdown.
+type utxoCache struct {
+	// The following fields are set when the instance is created and can't
+	// be changed afterwards, so there is no need to protect them with a
+	// separate mutex.
+	db             database.DB
+	bucketName     []byte
+	consistencyKey []byte
+	maxSize        uint64
+
+	// mtx protects the following fields.
+	//
+	// The entries field maps outputs to their cached entries.  Modified
+	// entries have not been written to the database yet, where spent ones
+	// still need to be removed from it.  Fresh entries don't exist in the
+	// database, so they can be removed from the cache once they are spent.
+	mtx           sync.Mutex
+	entries       map[wire.OutPoint]*UtxoEntry
+	totalSize     uint64
+	lastFlushHash chainhash.Hash
+	lastFlushTime time.Time
+}
+
+// newUtxoCache returns a new empty utxo cache in front of the utxo set housed
+// in the bucket with the passed name which holds up to about maxSize bytes of
+// outputs.  The hash of the block the utxo set is consistent with is recorded
+// under the passed key with every flush.
+func newUtxoCache(db database.DB, bucketName, consistencyKey []byte, maxSize uint64) *utxoCache {
+	return &utxoCache{
+		db:             db,
+		bucketName:     bucketName,
+		consistencyKey: consistencyKey,
+		maxSize:        maxSize,
+		entries:        make(map[wire.OutPoint]*UtxoEntry),
+		lastFlushTime:  time.Now(),
+	}
+}
+
+// fetchEntries adds the requested unspent transaction outputs to the passed
+// entries, loading those which are not cached from the database.  Outputs
+// which are spent or otherwise don't exist result in nil entries.  The added
+// entries are copies, so they may be freely modified.
+//
+// This function is safe for concurrent access.
+func (c *utxoCache) fetchEntries(outpoints map[wire.OutPoint]struct{},
+	entries map[wire.OutPoint]*UtxoEntry) error {
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	var missing []wire.OutPoint
+	for outpoint := range outpoints {
+		cached, ok := c.entries[outpoint]
+		if !ok {
+			missing = append(missing, outpoint)
+			continue
+		}
+		entries[outpoint] = cached.viewEntry()
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+
+	return c.db.View(func(dbTx database.Tx) error {
+		for _, outpoint := range missing {
+			entry, err := dbFetchUtxoEntry(dbTx, c.bucketName, outpoint)
+			if err != nil {
+				return err
+			}
+			if entry == nil {
+				entries[outpoint] = nil
+				continue
+			}
+
+			c.entries[outpoint] = entry
+			c.totalSize += utxoEntrySize(entry)
+			entries[outpoint] = entry.Clone()
+		}
+		return nil
+	})
+}
+
+// fetchEntryByHash attempts to find any utxo for the given hash in the
+// database and, failing that, in the cache.  The details it is used for, the
+// height and coinbase flag, are the same for all outputs of a transaction, so
+// entries which are spent but not yet removed from the database will do.
+//
+// This function is safe for concurrent access.
+func (c *utxoCache) fetchEntryByHash(hash *chainhash.Hash) (*UtxoEntry, error) {
+	var entry *UtxoEntry
+	err := c.db.View(func(dbTx database.Tx) error {
+		var err error
+		entry, err = dbFetchUtxoEntryByHash(dbTx, c.bucketName, hash)
+		return err
+	})
+	if err != nil || entry != nil {
+		return entry, err
+	}
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	for outpoint, cached := range c.entries {
+		if outpoint.Hash == *hash {
+			return cached.viewEntry(), nil
+		}
+	}
+	return nil, nil
+}
+
+// commit applies the modified entries of the passed view, which must be a view
+// of the end of the main chain, to the cache.
+//
+// This function is safe for concurrent access.
+func (c *utxoCache) commit(view *UtxoViewpoint) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	for outpoint, entry := range view.entries {
+		if entry == nil || !entry.isModified() {
+			continue
+		}
+
+		// All outputs of the database referenced by the view are loaded
+		// through the cache and only removed from it by flushes, which
+		// write the spent outputs.  So an unspent output which is not
+		// cached, such as a newly created or resurrected one, does not
+		// exist in the database.
+		fresh := !entry.IsSpent()
+		if cached := c.entries[outpoint]; cached != nil {
+			fresh = cached.isFresh()
+			c.totalSize -= utxoEntrySize(cached)
+		}
+
+		// Spent outputs which never made it to the database are simply
+		// forgotten.
+		if entry.IsSpent() && fresh {
+			delete(c.entries, outpoint)
+			continue
+		}
+
+		cached := entry.Clone()
+		cached.packedFlags |= tfModified
+		if fresh {
+			cached.packedFlags |= tfFresh
+		}
+		if cached.IsSpent() {
+			cached.pkScript = nil
+		}
+		c.entries[outpoint] = cached
+		c.totalSize += utxoEntrySize(cached)
+	}
+}
+
+// needsFlush returns whether or not the cache should be flushed because it
+// exceeds its maximum size or was last flushed too long ago.  A cache without
+// a maximum size is always flushed.
+//
+// This function is safe for concurrent access.
+func (c *utxoCache) needsFlush() bool {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	return c.maxSize == 0 || c.totalSize > c.maxSize ||
+		time.Since(c.lastFlushTime) >= utxoFlushPeriodicInterval
+}
+
+// flush uses an existing database transaction to write the modified entries of
+// the cache followed by those of the passed view, which may be nil, to the
+// database and records the utxo set is consistent with the block with the
+// passed hash.  The flushed method MUST be called once the transaction has been
+// committed.
+//
+// This function is safe for concurrent access.
+func (c *utxoCache) flush(dbTx database.Tx, view *UtxoViewpoint, hash *chainhash.Hash) error {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if err := dbPutUtxoEntries(dbTx, c.bucketName, c.entries); err != nil {
+		return err
+	}
+	if view != nil {
+		if err := dbPutUtxoEntries(dbTx, c.bucketName, view.entries); err != nil {
+			return err
+		}
+	}
+	return dbPutUtxoStateConsistency(dbTx, c.consistencyKey, hash)
+}
+
+// flushed empties the cache once its entries have been written to the database
+// by a committed transaction and records the block with the passed hash as the
+// one the utxo set in the database is consistent with.
+//
+// This function is safe for concurrent access.
+func (c *utxoCache) flushed(hash *chainhash.Hash) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	log.Debugf("Flushed %d utxo cache entries (%d bytes) at block %v",
+		len(c.entries), c.totalSize, hash)
+
+	c.entries = make(map[wire.OutPoint]*UtxoEntry)
+	c.totalSize = 0
+	c.lastFlushHash = *hash
+	c.lastFlushTime = time.Now()
+}
+
+// lastFlush returns the hash of the block the utxo set in the database is
+// consistent with.
+//
+// This function is safe for concurrent access.
+func (c *utxoCache) lastFlush() chainhash.Hash {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	return c.lastFlushHash
+}
+
+// initUtxoState makes the utxo set in the database consistent with the best
+// chain.  After an unclean shutdown, the changes of the blocks connected since
+// the utxo cache was last flushed are missing from it, so those blocks are
+// replayed.
+func (b *BlockChain) initUtxoState(interrupt <-chan struct{}) error {
+	tip := b.bestChain.Tip()
+	var consistentHash *chainhash.Hash
+	err := b.db.View(func(dbTx database.Tx) error {
+		var err error
+		consistentHash, err = dbFetchUtxoStateConsistency(dbTx,
+			utxoStateConsistencyKeyName)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	// Databases created before the utxo cache existed were updated with
+	// every block, so they are consistent with the best chain.
+	if consistentHash == nil {
+		err := b.db.Update(func(dbTx database.Tx) error {
+			return dbPutUtxoStateConsistency(dbTx,
+				utxoStateConsistencyKeyName, &tip.hash)
+		})
+		if err != nil {
+			return err
+		}
+		b.utxoCache.flushed(&tip.hash)
+		return nil
+	}
+	b.utxoCache.flushed(consistentHash)
+	if *consistentHash == tip.hash {
+		return nil
+	}
+
+	// Blocks are always flushed when they are disconnected, so the block
+	// the utxo set is consistent with must be part of the main chain.
+	node := b.index.LookupNode(consistentHash)
+	if node == nil || !b.bestChain.Contains(node) {
+		return AssertError(fmt.Sprintf("utxo set is consistent with "+
+			"block %v which is not in the main chain", consistentHash))
+	}
+
+	log.Infof("Replaying %d blocks to recover the utxo set after an "+
+		"unclean shutdown", tip.height-node.height)
+	for n := b.bestChain.Next(node); n != nil; n = b.bestChain.Next(n) {
+		if interruptRequested(interrupt) {
+			return errInterruptRequested
+		}
+
+		var block *dashutil.Block
+		err := b.db.View(func(dbTx database.Tx) error {
+			var err error
+			block, err = dbFetchBlockByNode(dbTx, n)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+
+		view := NewUtxoViewpoint()
+		err = view.fetchInputUtxos(b.utxoCache, block)
+		if err != nil {
+			return err
+		}
+		err = view.connectTransactions(block, nil)
+		if err != nil {
+			return err
+		}
+
+		if n != tip && !b.utxoCache.needsFlush() {
+			b.utxoCache.commit(view)
+			continue
+		}
+		err = b.db.Update(func(dbTx database.Tx) error {
+			return b.utxoCache.flush(dbTx, view, &n.hash)
+		})
+		if err != nil {
+			return err
+		}
+		b.utxoCache.flushed(&n.hash)
+	}
+
+	return nil
+}
+
+// FlushUtxoCache writes all unspent transaction output changes held in memory
+// to the database.  It is called on shutdown and before the utxo set in the
+// database is read directly.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) FlushUtxoCache() error {
+	b.chainLock.Lock()
+	defer b.chainLock.Unlock()
+
+	return b.flushUtxoCache()
+}
+
+// flushUtxoCache writes all unspent transaction output changes held in memory
+// to the database.
+//
+// This function MUST be called with the chain state lock held (for writes).
+func (b *BlockChain) flushUtxoCache() error {
+	tip := b.bestChain.Tip()
+	err := b.db.Update(func(dbTx database.Tx) error {
+		return b.utxoCache.flush(dbTx, nil, &tip.hash)
+	})
+	if err != nil {
+		return err
+	}
+	b.utxoCache.flushed(&tip.hash)
+	return nil
+}
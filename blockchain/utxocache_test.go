@@ -0,0 +1,128 @@
+// Copyright (c) 2015-2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/eager7/dashd/chaincfg/chainhash"
+	"github.com/eager7/dashd/database"
+	"github.com/eager7/dashd/wire"
+)
+
+// TestUtxoCache ensures the utxo cache holds the changes of committed views
+// until it is flushed, forgets fresh outputs which are spent before being
+// flushed and removes spent outputs from the database once flushed.
+func TestUtxoCache(t *testing.T) {
+	t.Parallel()
+
+	dbPath, err := ioutil.TempDir("", "utxocache")
+	if err != nil {
+		t.Fatalf("TempDir: unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dbPath)
+	db, err := database.Create(testDbType, filepath.Join(dbPath, "db"),
+		wire.MainNet)
+	if err != nil {
+		t.Fatalf("Create: unexpected error: %v", err)
+	}
+	defer db.Close()
+	err = db.Update(func(dbTx database.Tx) error {
+		_, err := dbTx.Metadata().CreateBucket(utxoSetBucketName)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("CreateBucket: unexpected error: %v", err)
+	}
+
+	cache := newUtxoCache(db, utxoSetBucketName,
+		utxoStateConsistencyKeyName, DefaultUtxoCacheMaxSize)
+	txOut := wire.NewTxOut(5000, []byte{0x51})
+	outA := wire.OutPoint{Hash: chainhash.HashH([]byte("a"))}
+	outB := wire.OutPoint{Hash: chainhash.HashH([]byte("b"))}
+	allOuts := map[wire.OutPoint]struct{}{outA: {}, outB: {}}
+
+	// dbHasEntry returns whether or not the database contains the output.
+	dbHasEntry := func(outpoint wire.OutPoint) bool {
+		var entry *UtxoEntry
+		err := db.View(func(dbTx database.Tx) error {
+			var err error
+			entry, err = dbFetchUtxoEntry(dbTx, utxoSetBucketName, outpoint)
+			return err
+		})
+		if err != nil {
+			t.Fatalf("dbFetchUtxoEntry: unexpected error: %v", err)
+		}
+		return entry != nil
+	}
+
+	// Add both outputs and ensure they are only held by the cache.
+	view := NewUtxoViewpoint()
+	view.addTxOut(outA, txOut, false, 1)
+	view.addTxOut(outB, txOut, false, 1)
+	cache.commit(view)
+	view = NewUtxoViewpoint()
+	if err := view.fetchUtxosMain(cache, allOuts); err != nil {
+		t.Fatalf("fetchUtxosMain: unexpected error: %v", err)
+	}
+	if view.LookupEntry(outA) == nil || view.LookupEntry(outB) == nil {
+		t.Fatal("fetchUtxosMain: missing cached outputs")
+	}
+	if dbHasEntry(outA) || dbHasEntry(outB) {
+		t.Fatal("database contains outputs before flush")
+	}
+
+	// Spend the first output before flushing, which must not leave any
+	// trace, and flush the second one.
+	view.LookupEntry(outA).Spend()
+	cache.commit(view)
+	if _, ok := cache.entries[outA]; ok {
+		t.Fatal("cache contains spent fresh output")
+	}
+	hash := chainhash.HashH([]byte("block"))
+	err = db.Update(func(dbTx database.Tx) error {
+		return cache.flush(dbTx, nil, &hash)
+	})
+	if err != nil {
+		t.Fatalf("flush: unexpected error: %v", err)
+	}
+	cache.flushed(&hash)
+	if dbHasEntry(outA) || !dbHasEntry(outB) {
+		t.Fatal("database does not match flushed outputs")
+	}
+	if len(cache.entries) != 0 || cache.totalSize != 0 {
+		t.Fatalf("flushed cache holds %d entries (%d bytes)",
+			len(cache.entries), cache.totalSize)
+	}
+
+	// Spend the flushed output, which must keep it in the cache as spent
+	// until it is removed from the database by the next flush.
+	view = NewUtxoViewpoint()
+	if err := view.fetchUtxosMain(cache, allOuts); err != nil {
+		t.Fatalf("fetchUtxosMain: unexpected error: %v", err)
+	}
+	view.LookupEntry(outB).Spend()
+	cache.commit(view)
+	view = NewUtxoViewpoint()
+	if err := view.fetchUtxosMain(cache, allOuts); err != nil {
+		t.Fatalf("fetchUtxosMain: unexpected error: %v", err)
+	}
+	if view.LookupEntry(outB) != nil {
+		t.Fatal("fetchUtxosMain: spent output is available")
+	}
+	err = db.Update(func(dbTx database.Tx) error {
+		return cache.flush(dbTx, nil, &hash)
+	})
+	if err != nil {
+		t.Fatalf("flush: unexpected error: %v", err)
+	}
+	cache.flushed(&hash)
+	if dbHasEntry(outB) {
+		t.Fatal("database contains flushed spent output")
+	}
+}
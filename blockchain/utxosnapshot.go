@@ -0,0 +1,670 @@
+// Copyright (c) 2015-2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/eager7/dashd/chaincfg"
+	"github.com/eager7/dashd/chaincfg/chainhash"
+	"github.com/eager7/dashd/database"
+	"github.com/eager7/dashd/wire"
+	"github.com/eager7/dashutil"
+)
+
+// -----------------------------------------------------------------------------
+// A utxo snapshot contains everything needed to continue the chain from the
+// block it was taken at, the snapshot base, without processing the blocks
+// before it.
+//
+// The serialized format is:
+//
+//   <header><block headers><base block><utxo records><chain state records>
+//
+//   Field                Type               Size
+//   magic                [4]byte            4
+//   version              uint32             4
+//   network              wire.BitcoinNet    4
+//   base hash            chainhash.Hash     32
+//   base height          int32              4
+//   total txns           uint64             8
+//   block headers        []wire.BlockHeader 80 * base height
+//   base block           wire.MsgBlock      variable
+//   utxo records         variable           variable
+//   utxos end            byte               1
+//   chain state records  variable           variable
+//   chain state end      byte               1
+//
+// The integers are encoded in little endian.  The block headers are those of
+// the main chain from height one up to and including the snapshot base.
+//
+// Each utxo record is the key of the output in the utxo set followed by the
+// serialized utxo entry as described for the utxo set, both encoded as
+// variable length byte arrays.  The records are ordered by their keys and the
+// utxos are terminated by an empty key.
+//
+// The chain state records hold the Dash specific state needed to connect the
+// blocks after the snapshot base, namely the credit pools and masternode hard
+// fork signals of the most recent blocks and the used asset unlock indexes.
+// Each record is a type byte followed by the key and value of the entry in
+// the bucket identified by the type, both encoded as variable length byte
+// arrays, and the records are terminated by a zero type byte.
+//
+// The content hash of a snapshot is the double sha256 of the utxo records
+// followed by the chain state records, excluding the terminators.
+// -----------------------------------------------------------------------------
+
+const (
+	// utxoSnapshotVersion is the current version of the utxo snapshot
+	// format.
+	utxoSnapshotVersion = 1
+
+	// utxoSnapshotBatchSize is the number of utxo records of a snapshot
+	// which are written to the database by a single transaction while the
+	// snapshot is loaded.
+	utxoSnapshotBatchSize = 100000
+
+	// maxUtxoSnapshotKeySize is the maximum size of a key of a utxo or
+	// chain state record of a snapshot.
+	maxUtxoSnapshotKeySize = chainhash.HashSize + 10
+)
+
+// The following constants identify the types of the chain state records of a
+// utxo snapshot.
+const (
+	snapshotRecordEnd byte = iota
+	snapshotRecordCreditPool
+	snapshotRecordAssetUnlockIndex
+	snapshotRecordMnHfSignals
+	snapshotRecordMinedQuorum
+	snapshotRecordMinedQuorumByHeight
+)
+
+var (
+	// utxoSnapshotMagic identifies the start of a utxo snapshot.
+	utxoSnapshotMagic = [4]byte{'u', 't', 'x', 'o'}
+
+	// snapshotRecordBuckets maps the chain state record types of a utxo
+	// snapshot to the names of the buckets they belong to.
+	snapshotRecordBuckets = map[byte][]byte{
+		snapshotRecordCreditPool:          creditPoolBucketName,
+		snapshotRecordAssetUnlockIndex:    assetUnlockIndexBucketName,
+		snapshotRecordMnHfSignals:         mnhfSignalsBucketName,
+		snapshotRecordMinedQuorum:         minedQuorumsBucketName,
+		snapshotRecordMinedQuorumByHeight: minedQuorumsByHeightBucketName,
+	}
+)
+
+// UtxoSnapshotInfo describes a snapshot of the unspent transaction output set.
+type UtxoSnapshotInfo struct {
+	BlockHash   chainhash.Hash // The hash of the snapshot base.
+	Height      int32          // The height of the snapshot base.
+	TotalTxns   uint64         // The total number of txns in the chain.
+	NumUtxos    uint64         // The number of unspent outputs.
+	ContentHash chainhash.Hash // The content hash of the snapshot.
+}
+
+// serializeUtxoSnapshotHeader writes the header of a utxo snapshot of the
+// passed network described by the passed info to the writer.
+func serializeUtxoSnapshotHeader(w io.Writer, net wire.BitcoinNet, info *UtxoSnapshotInfo) error {
+	var buf [4 + 4 + 4 + chainhash.HashSize + 4 + 8]byte
+	copy(buf[:4], utxoSnapshotMagic[:])
+	byteOrder.PutUint32(buf[4:8], utxoSnapshotVersion)
+	byteOrder.PutUint32(buf[8:12], uint32(net))
+	copy(buf[12:44], info.BlockHash[:])
+	byteOrder.PutUint32(buf[44:48], uint32(info.Height))
+	byteOrder.PutUint64(buf[48:56], info.TotalTxns)
+	_, err := w.Write(buf[:])
+	return err
+}
+
+// deserializeUtxoSnapshotHeader reads the header of a utxo snapshot from the
+// reader and returns the network the snapshot belongs to along with the info
+// it describes.  The number of unspent outputs and the content hash are not
+// part of the header and therefore left unset.
+func deserializeUtxoSnapshotHeader(r io.Reader) (wire.BitcoinNet, *UtxoSnapshotInfo, error) {
+	var buf [4 + 4 + 4 + chainhash.HashSize + 4 + 8]byte
+	if _, err := io.ReadFull(r, buf[:]); err != nil {
+		return 0, nil, err
+	}
+	if !bytes.Equal(buf[:4], utxoSnapshotMagic[:]) {
+		return 0, nil, errDeserialize("not a utxo snapshot")
+	}
+	version := byteOrder.Uint32(buf[4:8])
+	if version != utxoSnapshotVersion {
+		return 0, nil, errDeserialize(fmt.Sprintf("unsupported utxo "+
+			"snapshot version %d", version))
+	}
+
+	net := wire.BitcoinNet(byteOrder.Uint32(buf[8:12]))
+	info := &UtxoSnapshotInfo{
+		Height:    int32(byteOrder.Uint32(buf[44:48])),
+		TotalTxns: byteOrder.Uint64(buf[48:56]),
+	}
+	copy(info.BlockHash[:], buf[12:44])
+	if info.Height < 1 {
+		return 0, nil, errDeserialize(fmt.Sprintf("invalid utxo "+
+			"snapshot base height %d", info.Height))
+	}
+	return net, info, nil
+}
+
+// writeSnapshotRecord writes the passed key and value as variable length byte
+// arrays to the writer.
+func writeSnapshotRecord(w io.Writer, key, value []byte) error {
+	if err := wire.WriteVarBytes(w, 0, key); err != nil {
+		return err
+	}
+	return wire.WriteVarBytes(w, 0, value)
+}
+
+// readSnapshotRecord reads a key and value written by writeSnapshotRecord from
+// the reader.  The value is not read when the key is empty.
+func readSnapshotRecord(r io.Reader) ([]byte, []byte, error) {
+	key, err := wire.ReadVarBytes(r, 0, maxUtxoSnapshotKeySize, "key")
+	if err != nil || len(key) == 0 {
+		return nil, nil, err
+	}
+	value, err := wire.ReadVarBytes(r, 0, wire.MaxBlockPayload, "value")
+	if err != nil {
+		return nil, nil, err
+	}
+	return key, value, nil
+}
+
+// writeUtxoRecords writes the utxo records for all unspent outputs of the utxo
+// set housed in the bucket with the passed name to the writer and returns the
+// number of written records.
+func writeUtxoRecords(dbTx database.Tx, bucketName []byte, w io.Writer) (uint64, error) {
+	var numUtxos uint64
+	err := dbTx.Metadata().Bucket(bucketName).ForEach(func(k, v []byte) error {
+		numUtxos++
+		return writeSnapshotRecord(w, k, v)
+	})
+	return numUtxos, err
+}
+
+// writeChainStateRecords writes the chain state records needed to connect the
+// blocks after the passed block to the writer.  They consist of the credit
+// pools and masternode hard fork signals of the blocks the credit pool limit
+// depends on, the asset unlock indexes used by the passed block and its
+// ancestors and the quorums mined by them.
+//
+// This function MUST be called with the chain state lock held (for reads).
+func (b *BlockChain) writeChainStateRecords(dbTx database.Tx, base *blockNode, w io.Writer) error {
+	// writeRecord writes a record of the passed type to the writer.
+	writeRecord := func(recordType byte, key, value []byte) error {
+		if _, err := w.Write([]byte{recordType}); err != nil {
+			return err
+		}
+		return writeSnapshotRecord(w, key, value)
+	}
+
+	// Collect the most recent blocks with a credit pool ordered from oldest
+	// to newest.
+	var nodes []*blockNode
+	for n := base; n != nil && n.height >= b.chainParams.V20Height &&
+		len(nodes) <= creditPoolBlocksToTrace; n = n.parent {
+
+		nodes = append(nodes, n)
+	}
+
+	meta := dbTx.Metadata()
+	poolBucket := meta.Bucket(creditPoolBucketName)
+	signalsBucket := meta.Bucket(mnhfSignalsBucketName)
+	for i := len(nodes) - 1; i >= 0; i-- {
+		hash := nodes[i].hash
+		if pool := poolBucket.Get(hash[:]); pool != nil {
+			err := writeRecord(snapshotRecordCreditPool, hash[:], pool)
+			if err != nil {
+				return err
+			}
+		}
+		if signals := signalsBucket.Get(hash[:]); signals != nil {
+			err := writeRecord(snapshotRecordMnHfSignals, hash[:],
+				signals)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	// Only include the asset unlock indexes used by the passed block and
+	// its ancestors since blocks after it might have been connected.
+	indexBucket := meta.Bucket(assetUnlockIndexBucketName)
+	err := indexBucket.ForEach(func(k, v []byte) error {
+		var hash chainhash.Hash
+		copy(hash[:], v)
+		usedNode := b.index.LookupNode(&hash)
+		if usedNode == nil || base.Ancestor(usedNode.height) != usedNode {
+			return nil
+		}
+		return writeRecord(snapshotRecordAssetUnlockIndex, k, v)
+	})
+	if err != nil {
+		return err
+	}
+
+	// Likewise, only include the quorums mined by the passed block and its
+	// ancestors, which are all quorums of the main chain up to its height.
+	quorumBucket := meta.Bucket(minedQuorumsBucketName)
+	err = quorumBucket.ForEach(func(k, v []byte) error {
+		if len(v) != 4 || int32(binary.BigEndian.Uint32(v)) > base.height {
+			return nil
+		}
+		return writeRecord(snapshotRecordMinedQuorum, k, v)
+	})
+	if err != nil {
+		return err
+	}
+	heightBucket := meta.Bucket(minedQuorumsByHeightBucketName)
+	return heightBucket.ForEach(func(k, v []byte) error {
+		if len(k) != minedQuorumHeightKeySize ||
+			int32(binary.BigEndian.Uint32(k[1:5])) > base.height {
+
+			return nil
+		}
+		return writeRecord(snapshotRecordMinedQuorumByHeight, k, v)
+	})
+}
+
+// pinnedUtxoSnapshot returns the utxo snapshot pinned by the chain parameters
+// or the chain configuration which was taken at the block with the passed
+// hash, or nil when there is none.
+func (b *BlockChain) pinnedUtxoSnapshot(hash *chainhash.Hash) *chaincfg.UtxoSnapshot {
+	for i := range b.utxoSnapshots {
+		snapshot := &b.utxoSnapshots[i]
+		if snapshot.BlockHash.IsEqual(hash) {
+			return snapshot
+		}
+	}
+	return nil
+}
+
+// DumpUtxoSnapshot writes a snapshot of the unspent transaction output set as
+// of the end of the current best chain to the passed writer.  Block processing
+// is suspended while the snapshot is written.  See LoadUtxoSnapshot for how the
+// snapshot can be used.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) DumpUtxoSnapshot(w io.Writer) (*UtxoSnapshotInfo, error) {
+	b.chainLock.Lock()
+	defer b.chainLock.Unlock()
+
+	// The utxo set in the database is read directly, so all changes held
+	// in memory need to be written to it first.
+	if err := b.flushUtxoCache(); err != nil {
+		return nil, err
+	}
+
+	tip := b.bestChain.Tip()
+	if tip.height == 0 {
+		return nil, errors.New("the utxo set of the genesis block " +
+			"can't be dumped")
+	}
+	b.stateLock.RLock()
+	info := &UtxoSnapshotInfo{
+		BlockHash: tip.hash,
+		Height:    tip.height,
+		TotalTxns: b.stateSnapshot.TotalTxns,
+	}
+	b.stateLock.RUnlock()
+
+	err := b.db.View(func(dbTx database.Tx) error {
+		block, err := dbFetchBlockByNode(dbTx, tip)
+		if err != nil {
+			return err
+		}
+
+		err = serializeUtxoSnapshotHeader(w, b.chainParams.Net, info)
+		if err != nil {
+			return err
+		}
+		for height := int32(1); height <= tip.height; height++ {
+			header := b.bestChain.NodeByHeight(height).Header()
+			if err := header.Serialize(w); err != nil {
+				return err
+			}
+		}
+		if err := block.MsgBlock().Serialize(w); err != nil {
+			return err
+		}
+
+		hasher := sha256.New()
+		records := io.MultiWriter(w, hasher)
+		info.NumUtxos, err = writeUtxoRecords(dbTx, utxoSetBucketName,
+			records)
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write([]byte{0}); err != nil {
+			return err
+		}
+		err = b.writeChainStateRecords(dbTx, tip, records)
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write([]byte{snapshotRecordEnd}); err != nil {
+			return err
+		}
+
+		info.ContentHash = chainhash.HashH(hasher.Sum(nil))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	log.Infof("Dumped utxo snapshot with %d outputs at block %v "+
+		"(height %d, content hash %v)", info.NumUtxos, info.BlockHash,
+		info.Height, info.ContentHash)
+	return info, nil
+}
+
+// resetUtxoSnapshotLoad removes the unspent outputs written by an incomplete
+// attempt to load a utxo snapshot from the database.
+func (b *BlockChain) resetUtxoSnapshotLoad() error {
+	return b.db.Update(func(dbTx database.Tx) error {
+		meta := dbTx.Metadata()
+		if err := meta.DeleteBucket(utxoSetBucketName); err != nil {
+			return err
+		}
+		if _, err := meta.CreateBucket(utxoSetBucketName); err != nil {
+			return err
+		}
+		return meta.Delete(utxoSnapshotBaseKeyName)
+	})
+}
+
+// LoadUtxoSnapshot bootstraps the chain from a snapshot of the unspent
+// transaction output set read from the passed reader, which must have been
+// written by DumpUtxoSnapshot.  The snapshot must have been taken at a block
+// pinned by the UtxoSnapshots of the chain parameters or the chain
+// configuration with a matching content hash, and it can only be loaded while
+// the chain does not contain any blocks beyond the genesis block.
+//
+// Once the snapshot is loaded, the block it was taken at becomes the end of
+// the best chain.  The blocks below it are missing, so they need to be added
+// with ProcessHistoricalBlock afterwards.  Doing so validates them in the
+// background and ensures they result in the same utxo set as the snapshot.
+// The optional indexes, such as the masternode list, are built from those
+// blocks as they are added and caught up with the main chain once all of them
+// have been validated.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) LoadUtxoSnapshot(r io.Reader) (*UtxoSnapshotInfo, error) {
+	b.chainLock.Lock()
+	defer b.chainLock.Unlock()
+
+	genesis := b.bestChain.Genesis()
+	if b.bestChain.Tip() != genesis {
+		return nil, errors.New("utxo snapshots can only be loaded " +
+			"before any blocks are processed")
+	}
+
+	net, info, err := deserializeUtxoSnapshotHeader(r)
+	if err != nil {
+		return nil, err
+	}
+	if net != b.chainParams.Net {
+		return nil, fmt.Errorf("utxo snapshot is for network %v instead "+
+			"of %v", net, b.chainParams.Net)
+	}
+	pinned := b.pinnedUtxoSnapshot(&info.BlockHash)
+	if pinned == nil || pinned.Height != info.Height {
+		return nil, fmt.Errorf("utxo snapshot at block %v (height %d) "+
+			"is not pinned by the chain parameters", info.BlockHash,
+			info.Height)
+	}
+
+	// Read the headers of the main chain up to the snapshot base, which
+	// are all committed to by its hash.
+	log.Infof("Loading utxo snapshot at block %v (height %d)",
+		info.BlockHash, info.Height)
+	nodes := make([]*blockNode, 0, info.Height)
+	tip := genesis
+	for height := int32(1); height <= info.Height; height++ {
+		var header wire.BlockHeader
+		if err := header.Deserialize(r); err != nil {
+			return nil, err
+		}
+		if header.PrevBlock != tip.hash {
+			return nil, fmt.Errorf("utxo snapshot header at height "+
+				"%d does not connect to the previous one", height)
+		}
+		tip = newBlockNode(&header, tip)
+		tip.status = statusValid
+		nodes = append(nodes, tip)
+	}
+	if tip.hash != info.BlockHash {
+		return nil, fmt.Errorf("utxo snapshot headers end at block %v "+
+			"instead of %v", tip.hash, info.BlockHash)
+	}
+	tip.status |= statusDataStored
+
+	var msgBlock wire.MsgBlock
+	if err := msgBlock.Deserialize(r); err != nil {
+		return nil, err
+	}
+	block := dashutil.NewBlock(&msgBlock)
+	block.SetHeight(tip.height)
+	if *block.Hash() != tip.hash {
+		return nil, fmt.Errorf("utxo snapshot contains block %v "+
+			"instead of %v", block.Hash(), tip.hash)
+	}
+
+	// Record that a snapshot is being loaded before writing any outputs,
+	// so they are removed on startup should the load not complete.
+	baseRecord := make([]byte, 2*chainhash.HashSize)
+	copy(baseRecord, tip.hash[:])
+	copy(baseRecord[chainhash.HashSize:], pinned.ContentHash[:])
+	err = b.db.Update(func(dbTx database.Tx) error {
+		return dbTx.Metadata().Put(utxoSnapshotBaseKeyName, baseRecord)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Write the unspent outputs to the utxo set in batches.
+	hasher := sha256.New()
+	for done := false; !done; {
+		err := b.db.Update(func(dbTx database.Tx) error {
+			utxoBucket := dbTx.Metadata().Bucket(utxoSetBucketName)
+			for i := 0; i < utxoSnapshotBatchSize; i++ {
+				key, value, err := readSnapshotRecord(r)
+				if err != nil {
+					return err
+				}
+				if key == nil {
+					done = true
+					return nil
+				}
+
+				// Ensure the record holds a valid output.
+				if len(key) <= chainhash.HashSize {
+					return errDeserialize("invalid utxo key")
+				}
+				idxBytes := key[chainhash.HashSize:]
+				if _, size := deserializeVLQ(idxBytes); size != len(idxBytes) {
+					return errDeserialize("invalid utxo key")
+				}
+				if _, err := deserializeUtxoEntry(value); err != nil {
+					return err
+				}
+
+				err = writeSnapshotRecord(hasher, key, value)
+				if err != nil {
+					return err
+				}
+				if err := utxoBucket.Put(key, value); err != nil {
+					return err
+				}
+				info.NumUtxos++
+			}
+			return nil
+		})
+		if err != nil {
+			if resetErr := b.resetUtxoSnapshotLoad(); resetErr != nil {
+				log.Errorf("Unable to remove incomplete utxo "+
+					"snapshot: %v", resetErr)
+			}
+			return nil, err
+		}
+		log.Debugf("Loaded %d utxo snapshot outputs", info.NumUtxos)
+	}
+
+	// Read the chain state records, which are only written once the
+	// content hash has been verified.
+	type stateRecord struct {
+		bucketName []byte
+		key, value []byte
+	}
+	var records []stateRecord
+	for {
+		var recordType [1]byte
+		_, err := io.ReadFull(r, recordType[:])
+		if err == nil && recordType[0] == snapshotRecordEnd {
+			break
+		}
+		var key, value []byte
+		if err == nil {
+			key, value, err = readSnapshotRecord(r)
+		}
+		bucketName, ok := snapshotRecordBuckets[recordType[0]]
+		if err == nil && (!ok || key == nil) {
+			err = errDeserialize("invalid chain state record")
+		}
+		if err == nil {
+			hasher.Write(recordType[:])
+			err = writeSnapshotRecord(hasher, key, value)
+		}
+		if err != nil {
+			if resetErr := b.resetUtxoSnapshotLoad(); resetErr != nil {
+				log.Errorf("Unable to remove incomplete utxo "+
+					"snapshot: %v", resetErr)
+			}
+			return nil, err
+		}
+		records = append(records, stateRecord{bucketName, key, value})
+	}
+
+	info.ContentHash = chainhash.HashH(hasher.Sum(nil))
+	if info.ContentHash != *pinned.ContentHash {
+		if err := b.resetUtxoSnapshotLoad(); err != nil {
+			log.Errorf("Unable to remove invalid utxo snapshot: %v",
+				err)
+		}
+		return nil, fmt.Errorf("utxo snapshot has content hash %v "+
+			"instead of %v", info.ContentHash, pinned.ContentHash)
+	}
+
+	// Calculate the statistics of the loaded utxo set, which are tracked
+	// from the snapshot base on.
+	var stats *utxoStatsState
+	err = b.db.View(func(dbTx database.Tx) error {
+		var err error
+		stats, err = dbCalcUtxoStatsState(dbTx, utxoSetBucketName,
+			info.TotalTxns)
+		return err
+	})
+	if err != nil {
+		if resetErr := b.resetUtxoSnapshotLoad(); resetErr != nil {
+			log.Errorf("Unable to remove incomplete utxo snapshot: "+
+				"%v", resetErr)
+		}
+		return nil, err
+	}
+
+	// Make the snapshot base the end of the best chain.
+	numTxns := uint64(len(msgBlock.Transactions))
+	state := newBestState(tip, uint64(msgBlock.SerializeSize()),
+		uint64(GetBlockWeight(block)), numTxns, info.TotalTxns,
+		tip.CalcPastMedianTime())
+	err = b.db.Update(func(dbTx database.Tx) error {
+		for _, node := range nodes {
+			if err := dbStoreBlockNode(dbTx, node); err != nil {
+				return err
+			}
+			err := dbPutBlockIndex(dbTx, &node.hash, node.height)
+			if err != nil {
+				return err
+			}
+		}
+		if err := dbStoreBlock(dbTx, block); err != nil {
+			return err
+		}
+
+		meta := dbTx.Metadata()
+		for _, record := range records {
+			bucket := meta.Bucket(record.bucketName)
+			if err := bucket.Put(record.key, record.value); err != nil {
+				return err
+			}
+		}
+
+		err := dbPutBestState(dbTx, state, tip.workSum)
+		if err != nil {
+			return err
+		}
+		err = dbPutUtxoStateConsistency(dbTx,
+			utxoStateConsistencyKeyName, &tip.hash)
+		if err != nil {
+			return err
+		}
+		err = dbPutUtxoStats(dbTx, utxoMuHashKeyName, &tip.hash, stats)
+		if err != nil {
+			return err
+		}
+
+		// The history below the snapshot base is validated starting
+		// from an empty utxo set at the genesis block.
+		_, err = meta.CreateBucketIfNotExists(historyUtxoSetBucketName)
+		if err != nil {
+			return err
+		}
+		return dbPutUtxoStateConsistency(dbTx,
+			historyUtxoStateConsistencyKeyName, &genesis.hash)
+	})
+	if err != nil {
+		if resetErr := b.resetUtxoSnapshotLoad(); resetErr != nil {
+			log.Errorf("Unable to remove incomplete utxo snapshot: "+
+				"%v", resetErr)
+		}
+		return nil, err
+	}
+
+	for _, node := range nodes {
+		b.index.addNode(node)
+		delete(b.headerIndex, node.hash)
+	}
+	b.bestChain.SetTip(tip)
+	if tip.workSum.Cmp(b.bestHeaders.Tip().workSum) > 0 {
+		b.bestHeaders.SetTip(tip)
+	}
+	b.utxoCache.flushed(&tip.hash)
+	b.historyCache.flushed(&genesis.hash)
+	b.snapshotBase = tip
+	b.snapshotContentHash = info.ContentHash
+	b.historyTip = genesis
+
+	// Search for the latest checkpoint again now that the best chain
+	// contains them.
+	b.checkpointNode = nil
+	b.nextCheckpoint = nil
+
+	b.stateLock.Lock()
+	b.stateSnapshot = state
+	b.lowestAvailHeight = tip.height
+	b.stateLock.Unlock()
+
+	log.Infof("Loaded utxo snapshot with %d outputs at block %v "+
+		"(height %d)", info.NumUtxos, info.BlockHash, info.Height)
+	return info, nil
+}
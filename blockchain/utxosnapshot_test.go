@@ -0,0 +1,92 @@
+// Copyright (c) 2015-2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/eager7/dashd/chaincfg/chainhash"
+	"github.com/eager7/dashd/wire"
+)
+
+// TestUtxoSnapshotHeader ensures utxo snapshot headers round trip and invalid
+// headers are rejected.
+func TestUtxoSnapshotHeader(t *testing.T) {
+	t.Parallel()
+
+	info := &UtxoSnapshotInfo{
+		BlockHash: chainhash.HashH([]byte("base")),
+		Height:    1000,
+		TotalTxns: 12345,
+	}
+	var buf bytes.Buffer
+	err := serializeUtxoSnapshotHeader(&buf, wire.TestNet3, info)
+	if err != nil {
+		t.Fatalf("serializeUtxoSnapshotHeader: unexpected error: %v", err)
+	}
+	serialized := buf.Bytes()
+
+	net, gotInfo, err := deserializeUtxoSnapshotHeader(bytes.NewReader(serialized))
+	if err != nil {
+		t.Fatalf("deserializeUtxoSnapshotHeader: unexpected error: %v", err)
+	}
+	if net != wire.TestNet3 {
+		t.Errorf("deserializeUtxoSnapshotHeader: got network %v, want %v",
+			net, wire.TestNet3)
+	}
+	if !reflect.DeepEqual(gotInfo, info) {
+		t.Errorf("deserializeUtxoSnapshotHeader: got %+v, want %+v",
+			gotInfo, info)
+	}
+
+	tests := []struct {
+		name   string
+		mutate func(serialized []byte)
+	}{
+		{"bad magic", func(s []byte) { s[0] = 'x' }},
+		{"unsupported version", func(s []byte) { s[4]++ }},
+		{"zero height", func(s []byte) { copy(s[44:48], make([]byte, 4)) }},
+	}
+	for _, test := range tests {
+		corrupted := append([]byte(nil), serialized...)
+		test.mutate(corrupted)
+		_, _, err := deserializeUtxoSnapshotHeader(bytes.NewReader(corrupted))
+		if !isDeserializeErr(err) {
+			t.Errorf("%s: got error %v, want deserialize error",
+				test.name, err)
+		}
+	}
+}
+
+// TestSnapshotRecords ensures the records of utxo snapshots round trip and an
+// empty key terminates them.
+func TestSnapshotRecords(t *testing.T) {
+	t.Parallel()
+
+	key := []byte("key")
+	value := bytes.Repeat([]byte{0x51}, 300)
+	var buf bytes.Buffer
+	if err := writeSnapshotRecord(&buf, key, value); err != nil {
+		t.Fatalf("writeSnapshotRecord: unexpected error: %v", err)
+	}
+	buf.WriteByte(0)
+
+	r := bytes.NewReader(buf.Bytes())
+	gotKey, gotValue, err := readSnapshotRecord(r)
+	if err != nil {
+		t.Fatalf("readSnapshotRecord: unexpected error: %v", err)
+	}
+	if !bytes.Equal(gotKey, key) || !bytes.Equal(gotValue, value) {
+		t.Fatalf("readSnapshotRecord: got %x/%x, want %x/%x", gotKey,
+			gotValue, key, value)
+	}
+	gotKey, gotValue, err = readSnapshotRecord(r)
+	if err != nil || gotKey != nil || gotValue != nil {
+		t.Fatalf("readSnapshotRecord: got %x/%x (err %v) for terminator",
+			gotKey, gotValue, err)
+	}
+}
@@ -0,0 +1,443 @@
+// Copyright (c) 2015-2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/eager7/dashd/chaincfg/chainhash"
+	"github.com/eager7/dashd/database"
+	"github.com/eager7/dashd/txscript"
+	"github.com/eager7/dashd/wire"
+	"github.com/eager7/dashutil"
+)
+
+const (
+	// utxoStatsEntrySize is the size of a serialized utxo set statistics
+	// entry.
+	utxoStatsEntrySize = chainhash.HashSize + 32
+)
+
+var (
+	// utxoStatsBucketName is the name of the db bucket used to house the
+	// statistics of the utxo set as of each block in the main chain.
+	utxoStatsBucketName = []byte("utxostats")
+
+	// utxoMuHashKeyName is the name of the db key used to store the state
+	// of the rolling hash of the utxo set as of the end of the main chain.
+	utxoMuHashKeyName = []byte("utxomuhash")
+
+	// historyUtxoMuHashKeyName is the name of the db key used to store the
+	// state of the rolling hash of the utxo set resulting from the history
+	// below the base of a loaded utxo snapshot.
+	historyUtxoMuHashKeyName = []byte("historyutxomuhash")
+)
+
+// UtxoStats describes the unspent transaction output set as of a block in the
+// main chain.
+type UtxoStats struct {
+	// MuHash is the MuHash3072 of the unspent outputs, which is compatible
+	// with the muhash reported by Bitcoin Core.
+	MuHash chainhash.Hash
+
+	// TotalTxns is the total number of transactions in the chain up to and
+	// including the block.
+	TotalTxns uint64
+
+	// NumUtxos is the number of unspent outputs.
+	NumUtxos uint64
+
+	// TotalAmount is the total amount of the unspent outputs.
+	TotalAmount int64
+
+	// DiskSize is the size of the unspent outputs in the database.
+	DiskSize uint64
+}
+
+// utxoStatsElement returns the serialization of an unspent output which is
+// added to the MuHash3072 of the utxo set.  It matches the serialization used
+// by Bitcoin Core, which is:
+//
+//	<outpoint><header code><amount><script len><pkscript>
+//
+//	Field         Type            Size
+//	outpoint      wire.OutPoint   36
+//	header code   uint32          4
+//	amount        int64           8
+//	script len    VarInt          variable
+//	pkscript      []byte          variable
+//
+// The header code encodes the height of the block containing the output
+// shifted over one bit and the coinbase flag in the lowest bit.
+func utxoStatsElement(outpoint *wire.OutPoint, amount int64, pkScript []byte, height int32, isCoinBase bool) []byte {
+	var buf bytes.Buffer
+	buf.Grow(chainhash.HashSize + 16 +
+		wire.VarIntSerializeSize(uint64(len(pkScript))) + len(pkScript))
+	buf.Write(outpoint.Hash[:])
+
+	var scratch [8]byte
+	byteOrder.PutUint32(scratch[:4], outpoint.Index)
+	buf.Write(scratch[:4])
+	headerCode := uint32(height) << 1
+	if isCoinBase {
+		headerCode |= 0x01
+	}
+	byteOrder.PutUint32(scratch[:4], headerCode)
+	buf.Write(scratch[:4])
+	byteOrder.PutUint64(scratch[:], uint64(amount))
+	buf.Write(scratch[:])
+	wire.WriteVarInt(&buf, 0, uint64(len(pkScript)))
+	buf.Write(pkScript)
+	return buf.Bytes()
+}
+
+// utxoDiskSize returns the size of the key and the serialized entry of the
+// passed unspent output in the utxo set bucket.
+func utxoDiskSize(outpoint *wire.OutPoint, amount int64, pkScript []byte, height int32, isCoinBase bool) uint64 {
+	headerCode := uint64(height) << 1
+	if isCoinBase {
+		headerCode |= 0x01
+	}
+	return uint64(chainhash.HashSize + serializeSizeVLQ(uint64(outpoint.Index)) +
+		serializeSizeVLQ(headerCode) +
+		compressedTxOutSize(uint64(amount), pkScript))
+}
+
+// utxoStatsState tracks the statistics of a utxo set while outputs are added
+// to and removed from it.
+type utxoStatsState struct {
+	muHash *muHash3072
+	stats  UtxoStats
+}
+
+// addOutput adds the passed unspent output to the statistics.
+func (s *utxoStatsState) addOutput(outpoint *wire.OutPoint, amount int64, pkScript []byte, height int32, isCoinBase bool) {
+	s.muHash.Add(utxoStatsElement(outpoint, amount, pkScript, height,
+		isCoinBase))
+	s.stats.NumUtxos++
+	s.stats.TotalAmount += amount
+	s.stats.DiskSize += utxoDiskSize(outpoint, amount, pkScript, height,
+		isCoinBase)
+}
+
+// removeOutput removes the passed spent output from the statistics.
+func (s *utxoStatsState) removeOutput(outpoint *wire.OutPoint, amount int64, pkScript []byte, height int32, isCoinBase bool) {
+	s.muHash.Remove(utxoStatsElement(outpoint, amount, pkScript, height,
+		isCoinBase))
+	s.stats.NumUtxos--
+	s.stats.TotalAmount -= amount
+	s.stats.DiskSize -= utxoDiskSize(outpoint, amount, pkScript, height,
+		isCoinBase)
+}
+
+// applyBlock updates the statistics with the outputs created and spent by the
+// passed block, or reverts them when disconnect is true.  The stxos must be
+// the outputs spent by the block in the order of its inputs.
+func (s *utxoStatsState) applyBlock(block *dashutil.Block, stxos []SpentTxOut, disconnect bool) error {
+	if len(stxos) != countSpentOutputs(block) {
+		return AssertError("utxo set statistics updated with " +
+			"inconsistent spent transaction out information")
+	}
+
+	add, remove := s.addOutput, s.removeOutput
+	numTxns := uint64(len(block.Transactions()))
+	if disconnect {
+		add, remove = remove, add
+		s.stats.TotalTxns -= numTxns
+	} else {
+		s.stats.TotalTxns += numTxns
+	}
+
+	var stxoIdx int
+	for txIdx, tx := range block.Transactions() {
+		isCoinBase := txIdx == 0
+		if !isCoinBase {
+			for _, txIn := range tx.MsgTx().TxIn {
+				stxo := &stxos[stxoIdx]
+				stxoIdx++
+				remove(&txIn.PreviousOutPoint, stxo.Amount,
+					stxo.PkScript, stxo.Height,
+					stxo.IsCoinBase)
+			}
+		}
+
+		outpoint := wire.OutPoint{Hash: *tx.Hash()}
+		for txOutIdx, txOut := range tx.MsgTx().TxOut {
+			// Provably unspendable outputs are never added to the
+			// utxo set.
+			if txscript.IsUnspendable(txOut.PkScript) {
+				continue
+			}
+			outpoint.Index = uint32(txOutIdx)
+			add(&outpoint, txOut.Value, txOut.PkScript,
+				block.Height(), isCoinBase)
+		}
+	}
+
+	s.stats.MuHash = s.muHash.Finalize()
+	return nil
+}
+
+// -----------------------------------------------------------------------------
+// The statistics of the utxo set as of each block in the main chain are stored
+// in the utxo stats bucket keyed by the block hash.  The state of the rolling
+// hash of the utxo set as of the end of the main chain is stored separately,
+// since the hashes of the entries are finalized and can't be updated.
+//
+// The serialized format of the entries is:
+//
+//   <muhash><total txns><num utxos><total amount><disk size>
+//
+//   Field          Type             Size
+//   muhash         chainhash.Hash   chainhash.HashSize
+//   total txns     uint64           8
+//   num utxos      uint64           8
+//   total amount   int64            8
+//   disk size      uint64           8
+//
+// The statistics of the genesis block, whose outputs are not spendable, are
+// not stored.
+// -----------------------------------------------------------------------------
+
+// serializeUtxoStats returns the serialization of the passed utxo set
+// statistics.
+func serializeUtxoStats(stats *UtxoStats) []byte {
+	serialized := make([]byte, utxoStatsEntrySize)
+	offset := copy(serialized, stats.MuHash[:])
+	byteOrder.PutUint64(serialized[offset:], stats.TotalTxns)
+	byteOrder.PutUint64(serialized[offset+8:], stats.NumUtxos)
+	byteOrder.PutUint64(serialized[offset+16:], uint64(stats.TotalAmount))
+	byteOrder.PutUint64(serialized[offset+24:], stats.DiskSize)
+	return serialized
+}
+
+// deserializeUtxoStats decodes utxo set statistics from the passed serialized
+// bytes.
+func deserializeUtxoStats(serialized []byte) (*UtxoStats, error) {
+	if len(serialized) != utxoStatsEntrySize {
+		return nil, database.Error{
+			ErrorCode: database.ErrCorruption,
+			Description: fmt.Sprintf("corrupt utxo stats entry "+
+				"of %d bytes", len(serialized)),
+		}
+	}
+
+	var stats UtxoStats
+	offset := copy(stats.MuHash[:], serialized)
+	stats.TotalTxns = byteOrder.Uint64(serialized[offset:])
+	stats.NumUtxos = byteOrder.Uint64(serialized[offset+8:])
+	stats.TotalAmount = int64(byteOrder.Uint64(serialized[offset+16:]))
+	stats.DiskSize = byteOrder.Uint64(serialized[offset+24:])
+	return &stats, nil
+}
+
+// dbFetchUtxoStats uses an existing database transaction to fetch the utxo set
+// statistics as of the passed block node.  It returns nil when there are no
+// stored statistics for the block.
+func dbFetchUtxoStats(dbTx database.Tx, node *blockNode) (*UtxoStats, error) {
+	// The outputs of the genesis block are never added to the utxo set.
+	if node.parent == nil {
+		return &UtxoStats{
+			MuHash:    newMuHash3072().Finalize(),
+			TotalTxns: 1,
+		}, nil
+	}
+
+	serialized := dbTx.Metadata().Bucket(utxoStatsBucketName).Get(node.hash[:])
+	if serialized == nil {
+		return nil, nil
+	}
+	return deserializeUtxoStats(serialized)
+}
+
+// dbPutUtxoStats uses an existing database transaction to store the utxo set
+// statistics as of the passed block along with the state of the rolling hash
+// of the utxo set under the passed key.
+func dbPutUtxoStats(dbTx database.Tx, muHashKey []byte, hash *chainhash.Hash, state *utxoStatsState) error {
+	meta := dbTx.Metadata()
+	err := meta.Bucket(utxoStatsBucketName).Put(hash[:],
+		serializeUtxoStats(&state.stats))
+	if err != nil {
+		return err
+	}
+	return meta.Put(muHashKey, state.muHash.serialize())
+}
+
+// dbFetchUtxoStatsState uses an existing database transaction to load the
+// state of the utxo set statistics as of the passed block node, whose rolling
+// hash is stored under the passed key.  The rolling hash of the empty set is
+// used when the node is the genesis block.
+func dbFetchUtxoStatsState(dbTx database.Tx, muHashKey []byte, node *blockNode) (*utxoStatsState, error) {
+	stats, err := dbFetchUtxoStats(dbTx, node)
+	if err != nil {
+		return nil, err
+	}
+	var muHash *muHash3072
+	if node.parent == nil {
+		muHash = newMuHash3072()
+	} else if serialized := dbTx.Metadata().Get(muHashKey); serialized != nil {
+		muHash, err = deserializeMuHash3072(serialized)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if stats == nil || muHash == nil {
+		return nil, AssertError(fmt.Sprintf("utxo set statistics as "+
+			"of block %v are missing", node.hash))
+	}
+
+	return &utxoStatsState{muHash: muHash, stats: *stats}, nil
+}
+
+// dbCalcUtxoStatsState uses an existing database transaction to calculate the
+// state of the utxo set statistics from all unspent outputs in the utxo set
+// held by the passed bucket.  The passed number of transactions is the total
+// number of transactions in the chain up to the block the utxo set is as of.
+func dbCalcUtxoStatsState(dbTx database.Tx, bucketName []byte, totalTxns uint64) (*utxoStatsState, error) {
+	state := &utxoStatsState{
+		muHash: newMuHash3072(),
+		stats:  UtxoStats{TotalTxns: totalTxns},
+	}
+	cursor := dbTx.Metadata().Bucket(bucketName).Cursor()
+	for ok := cursor.First(); ok; ok = cursor.Next() {
+		key := cursor.Key()
+		if len(key) <= chainhash.HashSize {
+			return nil, database.Error{
+				ErrorCode:   database.ErrCorruption,
+				Description: "corrupt utxo key",
+			}
+		}
+		var outpoint wire.OutPoint
+		copy(outpoint.Hash[:], key)
+		index, _ := deserializeVLQ(key[chainhash.HashSize:])
+		outpoint.Index = uint32(index)
+
+		entry, err := deserializeUtxoEntry(cursor.Value())
+		if err != nil {
+			return nil, err
+		}
+		state.addOutput(&outpoint, entry.Amount(), entry.PkScript(),
+			entry.BlockHeight(), entry.IsCoinBase())
+	}
+	state.stats.MuHash = state.muHash.Finalize()
+	return state, nil
+}
+
+// connectUtxoStats stores the utxo set statistics as of the passed block, which
+// must be connected to its parent, from those of its parent.  The rolling hash
+// of the utxo set is stored under the passed key.
+func connectUtxoStats(dbTx database.Tx, muHashKey []byte, node *blockNode, block *dashutil.Block, stxos []SpentTxOut) error {
+	state, err := dbFetchUtxoStatsState(dbTx, muHashKey, node.parent)
+	if err != nil {
+		return err
+	}
+	if err := state.applyBlock(block, stxos, false); err != nil {
+		return err
+	}
+	return dbPutUtxoStats(dbTx, muHashKey, &node.hash, state)
+}
+
+// disconnectUtxoStats removes the utxo set statistics as of the passed block,
+// which must be the end of the main chain, and reverts the rolling hash of the
+// utxo set to its parent.
+//
+// The statistics of the parent are calculated from those of the block, since
+// they are not stored when the statistics were first calculated from the utxo
+// set at the block.
+func disconnectUtxoStats(dbTx database.Tx, node *blockNode, block *dashutil.Block, stxos []SpentTxOut) error {
+	state, err := dbFetchUtxoStatsState(dbTx, utxoMuHashKeyName, node)
+	if err != nil {
+		return err
+	}
+	if err := state.applyBlock(block, stxos, true); err != nil {
+		return err
+	}
+	err = dbTx.Metadata().Bucket(utxoStatsBucketName).Delete(node.hash[:])
+	if err != nil {
+		return err
+	}
+	if node.parent.parent == nil {
+		return dbTx.Metadata().Delete(utxoMuHashKeyName)
+	}
+	return dbPutUtxoStats(dbTx, utxoMuHashKeyName, &node.parent.hash, state)
+}
+
+// initUtxoStats ensures the statistics of the utxo set as of the end of the
+// main chain are available.  They are calculated from the utxo set for
+// databases which were created before the statistics were tracked, after which
+// the statistics of every connected block are stored.
+//
+// The utxo set in the database MUST be consistent with the end of the main
+// chain when this function is called.
+func (b *BlockChain) initUtxoStats() error {
+	tip := b.bestChain.Tip()
+	var haveStats bool
+	err := b.db.Update(func(dbTx database.Tx) error {
+		meta := dbTx.Metadata()
+		_, err := meta.CreateBucketIfNotExists(utxoStatsBucketName)
+		if err != nil {
+			return err
+		}
+		haveStats = tip.parent == nil || meta.Get(utxoMuHashKeyName) != nil
+		return nil
+	})
+	if err != nil || haveStats {
+		return err
+	}
+
+	log.Infof("Calculating the statistics of the utxo set.  This might " +
+		"take a while...")
+	var state *utxoStatsState
+	err = b.db.View(func(dbTx database.Tx) error {
+		var err error
+		state, err = dbCalcUtxoStatsState(dbTx, utxoSetBucketName,
+			b.stateSnapshot.TotalTxns)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	err = b.db.Update(func(dbTx database.Tx) error {
+		return dbPutUtxoStats(dbTx, utxoMuHashKeyName, &tip.hash, state)
+	})
+	if err != nil {
+		return err
+	}
+	log.Infof("Calculated the statistics of %d unspent outputs",
+		state.stats.NumUtxos)
+	return nil
+}
+
+// UtxoStats returns the statistics of the utxo set as of the block with the
+// given hash, which must be in the main chain.  The statistics are available
+// for all blocks connected to the main chain since they were first tracked.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) UtxoStats(hash *chainhash.Hash) (*UtxoStats, error) {
+	b.chainLock.RLock()
+	defer b.chainLock.RUnlock()
+
+	node := b.index.LookupNode(hash)
+	if node == nil || !b.bestChain.Contains(node) {
+		str := fmt.Sprintf("block %s is not in the main chain", hash)
+		return nil, errNotInMainChain(str)
+	}
+
+	var stats *UtxoStats
+	err := b.db.View(func(dbTx database.Tx) error {
+		var err error
+		stats, err = dbFetchUtxoStats(dbTx, node)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if stats == nil {
+		return nil, fmt.Errorf("the statistics of the utxo set as of "+
+			"block %v are not available", hash)
+	}
+	return stats, nil
+}
@@ -0,0 +1,97 @@
+// Copyright (c) 2015-2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/eager7/dashd/chaincfg/chainhash"
+	"github.com/eager7/dashd/txscript"
+	"github.com/eager7/dashd/wire"
+	"github.com/eager7/dashutil"
+)
+
+// TestUtxoStatsApplyBlock ensures the utxo set statistics are updated with the
+// outputs created and spent by blocks and reverted when they are disconnected.
+func TestUtxoStatsApplyBlock(t *testing.T) {
+	t.Parallel()
+
+	spendScript := []byte{txscript.OP_TRUE}
+	prevOut := wire.OutPoint{Hash: chainhash.HashH([]byte("prev")), Index: 1}
+	stxo := SpentTxOut{
+		Amount:     7000,
+		PkScript:   spendScript,
+		Height:     50,
+		IsCoinBase: true,
+	}
+
+	// Start from a utxo set which only holds the spent output.
+	state := &utxoStatsState{muHash: newMuHash3072()}
+	state.stats.TotalTxns = 10
+	state.addOutput(&prevOut, stxo.Amount, stxo.PkScript, stxo.Height,
+		stxo.IsCoinBase)
+	state.stats.MuHash = state.muHash.Finalize()
+	initial := state.stats
+
+	coinbase := wire.NewMsgTx(1)
+	coinbase.AddTxIn(&wire.TxIn{
+		PreviousOutPoint: wire.OutPoint{Index: wire.MaxPrevOutIndex},
+		SignatureScript:  []byte{0x01, 0x64},
+	})
+	coinbase.AddTxOut(wire.NewTxOut(5000, spendScript))
+	coinbase.AddTxOut(wire.NewTxOut(0, []byte{txscript.OP_RETURN}))
+	spend := wire.NewMsgTx(1)
+	spend.AddTxIn(wire.NewTxIn(&prevOut, nil, nil))
+	spend.AddTxOut(wire.NewTxOut(6000, spendScript))
+	block := dashutil.NewBlock(&wire.MsgBlock{
+		Transactions: []*wire.MsgTx{coinbase, spend},
+	})
+	block.SetHeight(100)
+
+	err := state.applyBlock(block, []SpentTxOut{stxo}, false)
+	if err != nil {
+		t.Fatalf("applyBlock: unexpected error: %v", err)
+	}
+
+	// The utxo set must now hold the spendable outputs of the block.
+	want := &utxoStatsState{muHash: newMuHash3072()}
+	want.stats.TotalTxns = 12
+	txns := block.Transactions()
+	want.addOutput(&wire.OutPoint{Hash: *txns[0].Hash()}, 5000, spendScript,
+		100, true)
+	want.addOutput(&wire.OutPoint{Hash: *txns[1].Hash()}, 6000, spendScript,
+		100, false)
+	want.stats.MuHash = want.muHash.Finalize()
+	if !reflect.DeepEqual(state.stats, want.stats) {
+		t.Fatalf("applyBlock: got stats %+v, want %+v", state.stats,
+			want.stats)
+	}
+
+	// The statistics must round trip through their serialization.
+	gotStats, err := deserializeUtxoStats(serializeUtxoStats(&state.stats))
+	if err != nil {
+		t.Fatalf("deserializeUtxoStats: unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(gotStats, &state.stats) {
+		t.Fatalf("deserializeUtxoStats: got %+v, want %+v", gotStats,
+			state.stats)
+	}
+
+	// Disconnecting the block must revert the statistics.
+	err = state.applyBlock(block, []SpentTxOut{stxo}, true)
+	if err != nil {
+		t.Fatalf("applyBlock: unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(state.stats, initial) {
+		t.Fatalf("applyBlock: got stats %+v after disconnecting, "+
+			"want %+v", state.stats, initial)
+	}
+
+	// The stxos must match the inputs of the block.
+	if err := state.applyBlock(block, nil, false); err == nil {
+		t.Fatal("applyBlock: unexpected success with missing stxos")
+	}
+}
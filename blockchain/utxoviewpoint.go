@@ -8,7 +8,6 @@ import (
 	"fmt"
 
 	"github.com/eager7/dashd/chaincfg/chainhash"
-	"github.com/eager7/dashd/database"
 	"github.com/eager7/dashd/txscript"
 	"github.com/eager7/dashd/wire"
 	"github.com/eager7/dashutil"
@@ -28,6 +27,10 @@ const (
 	// tfModified indicates that a txout has been modified since it was
 	// loaded.
 	tfModified
+
+	// tfFresh indicates that a txout in the utxo cache does not exist in
+	// the database.
+	tfFresh
 )
 
 // UtxoEntry houses details about an individual transaction output in a utxo
@@ -58,6 +61,23 @@ func (entry *UtxoEntry) isModified() bool {
 	return entry.packedFlags&tfModified == tfModified
 }
 
+// isFresh returns whether or not the output does not exist in the database.
+func (entry *UtxoEntry) isFresh() bool {
+	return entry.packedFlags&tfFresh == tfFresh
+}
+
+// viewEntry returns a copy of the cached entry to be added to a utxo view, or
+// nil when the output has been spent.
+func (entry *UtxoEntry) viewEntry() *UtxoEntry {
+	if entry.IsSpent() {
+		return nil
+	}
+
+	viewEntry := entry.Clone()
+	viewEntry.packedFlags &^= tfModified | tfFresh
+	return viewEntry
+}
+
 // IsCoinBase returns whether or not the output was contained in a coinbase
 // transaction.
 func (entry *UtxoEntry) IsCoinBase() bool {
@@ -280,8 +300,8 @@ func (view *UtxoViewpoint) connectTransactions(block *dashutil.Block, stxos *[]S
 
 // fetchEntryByHash attempts to find any available utxo for the given hash by
 // searching the entire set of possible outputs for the given hash.  It checks
-// the view first and then falls back to the database if needed.
-func (view *UtxoViewpoint) fetchEntryByHash(db database.DB, hash *chainhash.Hash) (*UtxoEntry, error) {
+// the view first and then falls back to the utxo cache and database if needed.
+func (view *UtxoViewpoint) fetchEntryByHash(cache *utxoCache, hash *chainhash.Hash) (*UtxoEntry, error) {
 	// First attempt to find a utxo with the provided hash in the view.
 	prevOut := wire.OutPoint{Hash: *hash}
 	for idx := uint32(0); idx < MaxOutputsPerBlock; idx++ {
@@ -292,23 +312,17 @@ func (view *UtxoViewpoint) fetchEntryByHash(db database.DB, hash *chainhash.Hash
 		}
 	}
 
-	// Check the database since it doesn't exist in the view.  This will
-	// often by the case since only specifically referenced utxos are loaded
-	// into the view.
-	var entry *UtxoEntry
-	err := db.View(func(dbTx database.Tx) error {
-		var err error
-		entry, err = dbFetchUtxoEntryByHash(dbTx, hash)
-		return err
-	})
-	return entry, err
+	// Check the utxo cache and database since it doesn't exist in the
+	// view.  This will often by the case since only specifically referenced
+	// utxos are loaded into the view.
+	return cache.fetchEntryByHash(hash)
 }
 
 // disconnectTransactions updates the view by removing all of the transactions
 // created by the passed block, restoring all utxos the transactions spent by
 // using the provided spent txo information, and setting the best hash for the
 // view to the block before the passed block.
-func (view *UtxoViewpoint) disconnectTransactions(db database.DB, block *dashutil.Block, stxos []SpentTxOut) error {
+func (view *UtxoViewpoint) disconnectTransactions(cache *utxoCache, block *dashutil.Block, stxos []SpentTxOut) error {
 	// Sanity check the correct number of stxos are provided.
 	if len(stxos) != countSpentOutputs(block) {
 		return AssertError("disconnectTransactions called with bad " +
@@ -406,7 +420,7 @@ func (view *UtxoViewpoint) disconnectTransactions(db database.DB, block *dashuti
 			// only ever run with the new v2 format, this code path
 			// will never run.
 			if stxo.Height == 0 {
-				utxo, err := view.fetchEntryByHash(db, txHash)
+				utxo, err := view.fetchEntryByHash(cache, txHash)
 				if err != nil {
 					return err
 				}
@@ -459,7 +473,7 @@ func (view *UtxoViewpoint) commit() {
 			continue
 		}
 
-		entry.packedFlags ^= tfModified
+		entry.packedFlags &^= tfModified
 	}
 }
 
@@ -470,7 +484,7 @@ func (view *UtxoViewpoint) commit() {
 // Upon completion of this function, the view will contain an entry for each
 // requested outpoint.  Spent outputs, or those which otherwise don't exist,
 // will result in a nil entry in the view.
-func (view *UtxoViewpoint) fetchUtxosMain(db database.DB, outpoints map[wire.OutPoint]struct{}) error {
+func (view *UtxoViewpoint) fetchUtxosMain(cache *utxoCache, outpoints map[wire.OutPoint]struct{}) error {
 	// Nothing to do if there are no requested outputs.
 	if len(outpoints) == 0 {
 		return nil
@@ -483,24 +497,13 @@ func (view *UtxoViewpoint) fetchUtxosMain(db database.DB, outpoints map[wire.Out
 	// will result in nil entries in the view.  This is intentionally done
 	// so other code can use the presence of an entry in the store as a way
 	// to unnecessarily avoid attempting to reload it from the database.
-	return db.View(func(dbTx database.Tx) error {
-		for outpoint := range outpoints {
-			entry, err := dbFetchUtxoEntry(dbTx, outpoint)
-			if err != nil {
-				return err
-			}
-
-			view.entries[outpoint] = entry
-		}
-
-		return nil
-	})
+	return cache.fetchEntries(outpoints, view.entries)
 }
 
 // fetchUtxos loads the unspent transaction outputs for the provided set of
 // outputs into the view from the database as needed unless they already exist
 // in the view in which case they are ignored.
-func (view *UtxoViewpoint) fetchUtxos(db database.DB, outpoints map[wire.OutPoint]struct{}) error {
+func (view *UtxoViewpoint) fetchUtxos(cache *utxoCache, outpoints map[wire.OutPoint]struct{}) error {
 	// Nothing to do if there are no requested outputs.
 	if len(outpoints) == 0 {
 		return nil
@@ -518,7 +521,7 @@ func (view *UtxoViewpoint) fetchUtxos(db database.DB, outpoints map[wire.OutPoin
 	}
 
 	// Request the input utxos from the database.
-	return view.fetchUtxosMain(db, neededSet)
+	return view.fetchUtxosMain(cache, neededSet)
 }
 
 // fetchInputUtxos loads the unspent transaction outputs for the inputs
@@ -526,7 +529,7 @@ func (view *UtxoViewpoint) fetchUtxos(db database.DB, outpoints map[wire.OutPoin
 // database as needed.  In particular, referenced entries that are earlier in
 // the block are added to the view and entries that are already in the view are
 // not modified.
-func (view *UtxoViewpoint) fetchInputUtxos(db database.DB, block *dashutil.Block) error {
+func (view *UtxoViewpoint) fetchInputUtxos(cache *utxoCache, block *dashutil.Block) error {
 	// Build a map of in-flight transactions because some of the inputs in
 	// this block could be referencing other transactions earlier in this
 	// block which are not yet in the chain.
@@ -573,7 +576,7 @@ func (view *UtxoViewpoint) fetchInputUtxos(db database.DB, block *dashutil.Block
 	}
 
 	// Request the input utxos from the database.
-	return view.fetchUtxosMain(db, neededSet)
+	return view.fetchUtxosMain(cache, neededSet)
 }
 
 // NewUtxoViewpoint returns a new empty unspent transaction output view.
@@ -609,7 +612,7 @@ func (b *BlockChain) FetchUtxoView(tx *dashutil.Tx) (*UtxoViewpoint, error) {
 	// chain.
 	view := NewUtxoViewpoint()
 	b.chainLock.RLock()
-	err := view.fetchUtxosMain(b.db, neededSet)
+	err := view.fetchUtxosMain(b.utxoCache, neededSet)
 	b.chainLock.RUnlock()
 	return view, err
 }
@@ -628,15 +631,12 @@ func (b *BlockChain) FetchUtxoEntry(outpoint wire.OutPoint) (*UtxoEntry, error)
 	b.chainLock.RLock()
 	defer b.chainLock.RUnlock()
 
-	var entry *UtxoEntry
-	err := b.db.View(func(dbTx database.Tx) error {
-		var err error
-		entry, err = dbFetchUtxoEntry(dbTx, outpoint)
-		return err
-	})
+	entries := make(map[wire.OutPoint]*UtxoEntry, 1)
+	err := b.utxoCache.fetchEntries(map[wire.OutPoint]struct{}{outpoint: {}},
+		entries)
 	if err != nil {
 		return nil, err
 	}
 
-	return entry, nil
+	return entries[outpoint], nil
 }
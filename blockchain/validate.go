@@ -203,14 +203,22 @@ func CalcBlockSubsidy(height int32, chainParams *chaincfg.Params) int64 {
 // CheckTransactionSanity performs some preliminary checks on a transaction to
 // ensure it is sane.  These checks are context free.
 func CheckTransactionSanity(tx *dashutil.Tx) error {
-	// A transaction must have at least one input.
+	// A transaction must have at least one input unless it is an asset
+	// unlock which releases funds from the credit pool instead or a
+	// masternode hard fork signal or quorum commitment which does not
+	// transfer any funds.
 	msgTx := tx.MsgTx()
-	if len(msgTx.TxIn) == 0 {
+	if len(msgTx.TxIn) == 0 && !IsAssetUnlockTx(msgTx) && !IsMnHfTx(msgTx) &&
+		!IsQuorumCommitmentTx(msgTx) {
+
 		return ruleError(ErrNoTxInputs, "transaction has no inputs")
 	}
 
-	// A transaction must have at least one output.
-	if len(msgTx.TxOut) == 0 {
+	// A transaction must have at least one output unless it is a
+	// masternode hard fork signal or quorum commitment.
+	if len(msgTx.TxOut) == 0 && !IsMnHfTx(msgTx) &&
+		!IsQuorumCommitmentTx(msgTx) {
+
 		return ruleError(ErrNoTxOutputs, "transaction has no outputs")
 	}
 
@@ -294,6 +302,19 @@ func CheckTransactionSanity(tx *dashutil.Tx) error {
 		}
 	}
 
+	// Ensure the payload of asset lock and unlock transactions,
+	// masternode hard fork signals and quorum commitments is well formed.
+	switch {
+	case IsAssetLockTx(msgTx):
+		return checkAssetLockSanity(msgTx)
+	case IsAssetUnlockTx(msgTx):
+		return checkAssetUnlockSanity(msgTx)
+	case IsMnHfTx(msgTx):
+		return checkMnHfTxSanity(msgTx)
+	case IsQuorumCommitmentTx(msgTx):
+		return checkQuorumCommitmentSanity(msgTx)
+	}
+
 	return nil
 }
 
@@ -840,7 +861,7 @@ func (b *BlockChain) checkBIP0030(node *blockNode, block *dashutil.Block, view *
 			fetchSet[prevOut] = struct{}{}
 		}
 	}
-	err := view.fetchUtxos(b.db, fetchSet)
+	err := view.fetchUtxos(b.utxoCache, fetchSet)
 	if err != nil {
 		return err
 	}
@@ -877,6 +898,17 @@ func CheckTransactionInputs(tx *dashutil.Tx, txHeight int32, utxoView *UtxoViewp
 		return 0, nil
 	}
 
+	// Asset unlock transactions have no inputs either.  The outputs are
+	// funded by the credit pool and the fee is specified by the payload.
+	if IsAssetUnlockTx(tx.MsgTx()) {
+		payload, err := tx.MsgTx().AssetUnlockPayload()
+		if err != nil {
+			str := fmt.Sprintf("invalid asset unlock payload: %v", err)
+			return 0, ruleError(ErrBadAssetUnlock, str)
+		}
+		return int64(payload.Fee), nil
+	}
+
 	txHash := tx.Hash()
 	var totalSatoshiIn int64
 	for txInIndex, txIn := range tx.MsgTx().TxIn {
@@ -1036,7 +1068,7 @@ func (b *BlockChain) checkConnectBlock(node *blockNode, block *dashutil.Block, v
 	//
 	// These utxo entries are needed for verification of things such as
 	// transaction inputs, counting pay-to-script-hashes, and scripts.
-	err := view.fetchInputUtxos(b.db, block)
+	err := view.fetchInputUtxos(b.utxoCache, block)
 	if err != nil {
 		return err
 	}
@@ -1141,18 +1173,29 @@ func (b *BlockChain) checkConnectBlock(node *blockNode, block *dashutil.Block, v
 		return ruleError(ErrBadCoinbaseValue, str)
 	}
 
-	// Don't run scripts if this node is before the latest known good
-	// checkpoint since the validity is verified via the checkpoints (all
-	// transactions are included in the merkle root hash and any changes
-	// will therefore be detected by the next checkpoint).  This is a huge
-	// optimization because running the scripts is the most time consuming
-	// portion of block handling.
-	checkpoint := b.LatestCheckpoint()
-	runScripts := true
-	if checkpoint != nil && node.height <= checkpoint.Height {
-		runScripts = false
+	// Ensure the asset locks and unlocks in the block are valid in the
+	// context of the chain and that the withdrawals do not exceed the
+	// limit of the credit pool.
+	err = b.checkCreditPool(node, block)
+	if err != nil {
+		return err
+	}
+
+	// Ensure the masternode hard fork signals in the block are valid in
+	// the context of the chain.
+	err = b.checkMnHfSignals(node, block)
+	if err != nil {
+		return err
 	}
 
+	// Don't run scripts if this node is an ancestor of the assumed valid
+	// block since the validity is verified via that block (all
+	// transactions are included in the merkle root hash and any changes
+	// would therefore result in a different chain of blocks).  This is a
+	// huge optimization because running the scripts is the most time
+	// consuming portion of block handling.
+	runScripts := !b.isAssumedValid(node)
+
 	// Blocks created after the BIP0016 activation time need to have the
 	// pay-to-script-hash checks enabled.
 	var scriptFlags txscript.ScriptFlags
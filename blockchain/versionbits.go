@@ -212,6 +212,18 @@ func (b *BlockChain) calcNextBlockVersion(prevNode *blockNode) (int32, error) {
 			expectedVersion |= uint32(1) << 1
 		}
 	}
+	for id := range b.chainParams.EHFDeployments {
+		state, err := b.ehfDeploymentState(prevNode, uint32(id))
+		if err != nil {
+			return 0, err
+		}
+		if state.state == ThresholdStarted ||
+			state.state == ThresholdLockedIn {
+
+			bit := b.chainParams.EHFDeployments[id].BitNumber
+			expectedVersion |= uint32(1) << bit
+		}
+	}
 	return int32(expectedVersion), nil
 }
 
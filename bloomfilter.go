@@ -0,0 +1,186 @@
+// Copyright (c) 2013-2016 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"github.com/eager7/dashd/chaincfg/chainhash"
+	"github.com/eager7/dashd/txscript"
+	"github.com/eager7/dashd/wire"
+	"github.com/eager7/dashutil"
+	"github.com/eager7/dashutil/bloom"
+)
+
+// filterMatchesScript returns whether or not the bloom filter matches any of
+// the data pushes in the passed script.
+func filterMatchesScript(filter *bloom.Filter, script []byte) bool {
+	// A script that fails to parse never matches.  This mirrors the
+	// behavior of the standard output matching in the bloom filter.
+	pushes, err := txscript.PushedData(script)
+	if err != nil {
+		return false
+	}
+	for _, data := range pushes {
+		if len(data) != 0 && filter.Matches(data) {
+			return true
+		}
+	}
+	return false
+}
+
+// filterUpdateAll returns whether or not the passed filter is configured to
+// add matching elements back into itself.
+func filterUpdateAll(filter *bloom.Filter) bool {
+	return filter.MsgFilterLoad().Flags == wire.BloomUpdateAll
+}
+
+// matchSpecialTxAndUpdate returns true if the bloom filter matches data within
+// the extra payload of the passed special transaction as described by DIP0002.
+// This allows SPV clients to track the masternodes they own, operate or vote
+// for.  If the filter does match, it will also be updated depending on the
+// bloom update flags of the loaded filter.
+func matchSpecialTxAndUpdate(filter *bloom.Filter, tx *dashutil.Tx) bool {
+	msgTx := tx.MsgTx()
+	if !msgTx.IsSpecial() {
+		return false
+	}
+
+	switch msgTx.Type {
+	case wire.TxTypeProRegTx:
+		payload, err := msgTx.ProRegTxPayload()
+		if err != nil {
+			return false
+		}
+		if filter.MatchesOutPoint(&payload.CollateralOutpoint) ||
+			filter.Matches(payload.KeyIDOwner[:]) ||
+			filter.Matches(payload.KeyIDVoting[:]) ||
+			filterMatchesScript(filter, payload.ScriptPayout) {
+
+			// The hash of the registration is the ProTxHash which
+			// all future updates of the masternode refer to.
+			if filterUpdateAll(filter) {
+				filter.AddHash(tx.Hash())
+			}
+			return true
+		}
+
+	case wire.TxTypeProUpServTx:
+		payload, err := msgTx.ProUpServTxPayload()
+		if err != nil {
+			return false
+		}
+		if filter.Matches(payload.ProTxHash[:]) {
+			return true
+		}
+		if filterMatchesScript(filter, payload.ScriptOperatorPayout) {
+			if filterUpdateAll(filter) {
+				filter.AddHash(&payload.ProTxHash)
+			}
+			return true
+		}
+
+	case wire.TxTypeProUpRegTx:
+		payload, err := msgTx.ProUpRegTxPayload()
+		if err != nil {
+			return false
+		}
+		if filter.Matches(payload.ProTxHash[:]) {
+			return true
+		}
+		if filter.Matches(payload.KeyIDVoting[:]) ||
+			filterMatchesScript(filter, payload.ScriptPayout) {
+
+			if filterUpdateAll(filter) {
+				filter.AddHash(&payload.ProTxHash)
+			}
+			return true
+		}
+
+	case wire.TxTypeProUpRevTx:
+		payload, err := msgTx.ProUpRevTxPayload()
+		if err != nil {
+			return false
+		}
+		return filter.Matches(payload.ProTxHash[:])
+
+	case wire.TxTypeAssetLock:
+		payload, err := msgTx.AssetLockPayload()
+		if err != nil {
+			return false
+		}
+
+		// Credit outputs are matched the same way as regular outputs
+		// and the resulting outpoints are added to the filter so the
+		// asset unlock spending them is matched as well.
+		for i, txOut := range payload.CreditOutputs {
+			if !filterMatchesScript(filter, txOut.PkScript) {
+				continue
+			}
+
+			outpoint := wire.NewOutPoint(tx.Hash(), uint32(i))
+			switch filter.MsgFilterLoad().Flags {
+			case wire.BloomUpdateAll:
+				filter.AddOutPoint(outpoint)
+			case wire.BloomUpdateP2PubkeyOnly:
+				class := txscript.GetScriptClass(txOut.PkScript)
+				if class == txscript.PubKeyTy ||
+					class == txscript.MultiSigTy {
+
+					filter.AddOutPoint(outpoint)
+				}
+			}
+			return true
+		}
+	}
+
+	return false
+}
+
+// filterMatchTxAndUpdate returns true if the bloom filter matches data within
+// the passed transaction, including the extra payload of special transactions,
+// otherwise false is returned.  If the filter does match the passed
+// transaction, it will also update the filter depending on the bloom update
+// flags set via the loaded filter if needed.
+func filterMatchTxAndUpdate(filter *bloom.Filter, tx *dashutil.Tx) bool {
+	// Both checks are always performed since each of them may update the
+	// filter with elements needed to match later transactions.
+	matched := matchSpecialTxAndUpdate(filter, tx)
+	if filter.MatchTxAndUpdate(tx) {
+		matched = true
+	}
+	return matched
+}
+
+// txHashFilter returns a bloom filter which matches the transactions with the
+// passed hashes.  It uses the maximum size and number of hash functions, so
+// other transactions practically never match it.
+func txHashFilter(hashes []*chainhash.Hash) *bloom.Filter {
+	filter := bloom.LoadFilter(wire.NewMsgFilterLoad(
+		make([]byte, wire.MaxFilterLoadFilterSize),
+		wire.MaxFilterLoadHashFuncs, 0, wire.BloomUpdateNone))
+	for _, hash := range hashes {
+		filter.AddHash(hash)
+	}
+	return filter
+}
+
+// newMerkleBlock returns a new *wire.MsgMerkleBlock and an array of the matched
+// transaction index numbers based on the passed block and filter.  Unlike the
+// merkle blocks built by the bloom package, transactions are also matched
+// against the extra payload of special transactions.  The partial merkle tree
+// is still built by the bloom package from a filter of the matched hashes.
+func newMerkleBlock(block *dashutil.Block, filter *bloom.Filter) (*wire.MsgMerkleBlock, []uint32) {
+	var matchedIndices []uint32
+	var matchedHashes []*chainhash.Hash
+	for txIndex, tx := range block.Transactions() {
+		if filterMatchTxAndUpdate(filter, tx) {
+			matchedIndices = append(matchedIndices, uint32(txIndex))
+			matchedHashes = append(matchedHashes, tx.Hash())
+		}
+	}
+
+	msgMerkleBlock, _ := bloom.NewMerkleBlock(block,
+		txHashFilter(matchedHashes))
+	return msgMerkleBlock, matchedIndices
+}
@@ -0,0 +1,145 @@
+// Copyright (c) 2013-2016 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/eager7/dashd/chaincfg/chainhash"
+	"github.com/eager7/dashd/wire"
+	"github.com/eager7/dashutil"
+	"github.com/eager7/dashutil/bloom"
+)
+
+// newSpecialTx returns a special transaction of the passed type with the
+// serialized payload attached.
+func newSpecialTx(t *testing.T, txType wire.TxType, payload interface {
+	Serialize(w io.Writer) error
+}) *dashutil.Tx {
+
+	var buf bytes.Buffer
+	if err := payload.Serialize(&buf); err != nil {
+		t.Fatalf("Serialize: unexpected error %v", err)
+	}
+	return dashutil.NewTx(&wire.MsgTx{
+		Version: wire.SpecialTxVersion,
+		Type:    txType,
+		TxIn: []*wire.TxIn{{
+			PreviousOutPoint: wire.OutPoint{Index: 1},
+			Sequence:         wire.MaxTxInSequenceNum,
+		}},
+		Payload: buf.Bytes(),
+	})
+}
+
+// TestFilterMatchProTx ensures the bloom filter matching follows a masternode
+// from its registration through the updates referencing its ProTxHash.
+func TestFilterMatchProTx(t *testing.T) {
+	ownerKeyID := [wire.KeyIDSize]byte{0x01, 0x02, 0x03}
+	reg := &wire.ProRegTxPayload{
+		Version:      wire.ProTxVersionLegacyBLS,
+		KeyIDOwner:   ownerKeyID,
+		KeyIDVoting:  [wire.KeyIDSize]byte{0x04},
+		ScriptPayout: []byte{0x51},
+		Sig:          []byte{},
+	}
+	regTx := newSpecialTx(t, wire.TxTypeProRegTx, reg)
+
+	upServ := &wire.ProUpServTxPayload{
+		Version:              wire.ProTxVersionLegacyBLS,
+		ProTxHash:            *regTx.Hash(),
+		ScriptOperatorPayout: []byte{},
+	}
+	upServTx := newSpecialTx(t, wire.TxTypeProUpServTx, upServ)
+
+	upRev := &wire.ProUpRevTxPayload{
+		Version:   wire.ProTxVersionLegacyBLS,
+		ProTxHash: chainhash.Hash{0xff},
+	}
+	upRevTx := newSpecialTx(t, wire.TxTypeProUpRevTx, upRev)
+
+	// A filter without the ProTxHash must not match the update until the
+	// registration was matched through the owner key.
+	filter := bloom.NewFilter(10, 0, 0.000001, wire.BloomUpdateAll)
+	filter.Add(ownerKeyID[:])
+	if filterMatchTxAndUpdate(filter, upServTx) {
+		t.Fatal("update service matched before registration")
+	}
+	if !filterMatchTxAndUpdate(filter, regTx) {
+		t.Fatal("registration did not match owner key")
+	}
+	if !filterMatchTxAndUpdate(filter, upServTx) {
+		t.Fatal("update service did not match ProTxHash")
+	}
+	if filterMatchTxAndUpdate(filter, upRevTx) {
+		t.Fatal("revocation of unrelated masternode matched")
+	}
+
+	// Filters which are not updated must only match the registration.
+	filter = bloom.NewFilter(10, 0, 0.000001, wire.BloomUpdateNone)
+	filter.Add(ownerKeyID[:])
+	if !filterMatchTxAndUpdate(filter, regTx) {
+		t.Fatal("registration did not match owner key")
+	}
+	if filterMatchTxAndUpdate(filter, upServTx) {
+		t.Fatal("update service matched without update flag")
+	}
+
+	// Merkle blocks must include matched special transactions.
+	filter = bloom.NewFilter(10, 0, 0.000001, wire.BloomUpdateAll)
+	filter.AddOutPoint(&reg.CollateralOutpoint)
+	block := dashutil.NewBlock(&wire.MsgBlock{
+		Transactions: []*wire.MsgTx{
+			upRevTx.MsgTx(), regTx.MsgTx(), upServTx.MsgTx(),
+		},
+	})
+	merkle, matched := newMerkleBlock(block, filter)
+	if len(matched) != 2 || matched[0] != 1 || matched[1] != 2 {
+		t.Fatalf("newMerkleBlock: unexpected matches %v", matched)
+	}
+	if merkle.Transactions != 3 || len(merkle.Hashes) != 3 {
+		t.Fatalf("newMerkleBlock: got %d transactions and %d hashes, "+
+			"want 3 and 3", merkle.Transactions, len(merkle.Hashes))
+	}
+}
+
+// TestFilterMatchAssetLock ensures credit outputs of asset lock transactions
+// are matched and their outpoints added to the filter.
+func TestFilterMatchAssetLock(t *testing.T) {
+	pubKeyHash := []byte{
+		0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a,
+		0x0b, 0x0c, 0x0d, 0x0e, 0x0f, 0x10, 0x11, 0x12, 0x13, 0x14,
+	}
+	pkScript := append([]byte{0x76, 0xa9, 0x14}, pubKeyHash...)
+	pkScript = append(pkScript, 0x88, 0xac)
+	lock := &wire.AssetLockPayload{
+		Version: wire.AssetLockPayloadVersion,
+		CreditOutputs: []*wire.TxOut{
+			{Value: 1000, PkScript: []byte{0x51}},
+			{Value: 2000, PkScript: pkScript},
+		},
+	}
+	var buf bytes.Buffer
+	if err := lock.Serialize(&buf); err != nil {
+		t.Fatalf("Serialize: unexpected error %v", err)
+	}
+	tx := dashutil.NewTx(&wire.MsgTx{
+		Version: wire.SpecialTxVersion,
+		Type:    wire.TxTypeAssetLock,
+		TxOut:   []*wire.TxOut{{Value: 3000, PkScript: []byte{0x6a}}},
+		Payload: buf.Bytes(),
+	})
+
+	filter := bloom.NewFilter(10, 0, 0.000001, wire.BloomUpdateAll)
+	filter.Add(pubKeyHash)
+	if !filterMatchTxAndUpdate(filter, tx) {
+		t.Fatal("asset lock did not match credit output")
+	}
+	if !filter.MatchesOutPoint(wire.NewOutPoint(tx.Hash(), 1)) {
+		t.Fatal("credit outpoint was not added to the filter")
+	}
+}
@@ -0,0 +1,189 @@
+// Copyright (c) 2013-2016 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package bls
+
+import (
+	"errors"
+	"math/big"
+)
+
+const (
+	// PubKeySize is the size of a serialized public key.
+	PubKeySize = 48
+
+	// SignatureSize is the size of a serialized signature.
+	SignatureSize = 96
+
+	// PrivKeySize is the size of a serialized private key.
+	PrivKeySize = 32
+
+	// g1Size and g2Size are the sizes of compressed points of G1 and G2.
+	g1Size = PubKeySize
+	g2Size = SignatureSize
+
+	// These flags are stored in the three most significant bits of
+	// compressed points.
+	flagCompressed = 0x80
+	flagInfinity   = 0x40
+	flagSign       = 0x20
+
+	// legacyFlagSign is the flag which holds the sign of the y coordinate
+	// in the legacy serialization of public keys.
+	legacyFlagSign = 0x80
+)
+
+// basicSchemeDST is the domain separation tag of the basic scheme, which Dash
+// uses for all signatures since the basic BLS scheme was activated.
+var basicSchemeDST = []byte("BLS_SIG_BLS12381G2_XMD:SHA-256_SSWU_RO_NUL_")
+
+var (
+	// rBig is the order of G1 and G2.
+	rBig = fromHex("73eda753299d7d483339d80809a1d80553bda402fffe5bfeffffffff00000001")
+
+	// blsXAbs is the absolute value of the parameter x = -0xd201000000010000
+	// of the curve.
+	blsXAbs = fromHex("d201000000010000")
+)
+
+// PublicKey is a BLS public key, which is a point of G1.
+type PublicKey struct {
+	point g1Point
+}
+
+// ParsePubKey decodes a public key in the serialization of the basic scheme,
+// which is the compressed point format of the ZCash BLS12-381 specification.
+// The key must be in G1 and must not be the point at infinity.
+func ParsePubKey(b []byte) (*PublicKey, error) {
+	pt, err := g1FromBytes(b)
+	if err != nil {
+		return nil, err
+	}
+	if pt.isInfinity() {
+		return nil, errors.New("public key is the point at infinity")
+	}
+	return &PublicKey{point: *pt}, nil
+}
+
+// ParseLegacyPubKey decodes a public key in the legacy serialization which
+// Dash used before the basic BLS scheme was activated, and which provider
+// transactions of version 1 still carry.  It is the big endian x coordinate
+// with the most significant bit holding the sign of y, which is set when y is
+// the lexicographically largest of the two candidates like the sign flag of the
+// ZCash format.  The two bits below it are ignored.
+func ParseLegacyPubKey(b []byte) (*PublicKey, error) {
+	if len(b) != PubKeySize {
+		return nil, errors.New("invalid length of public key")
+	}
+
+	var buf [PubKeySize]byte
+	copy(buf[:], b)
+	buf[0] &^= flagCompressed | flagInfinity | flagSign
+	var x fe
+	if !x.setBytes(buf[:]) {
+		return nil, errors.New("public key x coordinate out of range")
+	}
+	y, ok := g1Y(&x)
+	if !ok {
+		return nil, errors.New("public key is not on the curve")
+	}
+	if y.isLexLargest() != (b[0]&legacyFlagSign != 0) {
+		y.neg(&y)
+	}
+	pt := g1Point{x: x, y: y, z: feOne}
+	if !pt.isInSubgroup() {
+		return nil, errors.New("public key is not in the subgroup")
+	}
+	return &PublicKey{point: pt}, nil
+}
+
+// Serialize returns the public key in the serialization of the basic scheme.
+func (k *PublicKey) Serialize() []byte {
+	return k.point.bytes()
+}
+
+// Signature is a BLS signature, which is a point of G2.
+type Signature struct {
+	point g2Point
+}
+
+// ParseSignature decodes a signature in the serialization of the basic scheme,
+// which is the compressed point format of the ZCash BLS12-381 specification.
+// The signature must be in G2.
+func ParseSignature(b []byte) (*Signature, error) {
+	pt, err := g2FromBytes(b)
+	if err != nil {
+		return nil, err
+	}
+	return &Signature{point: *pt}, nil
+}
+
+// Serialize returns the signature in the serialization of the basic scheme.
+func (sig *Signature) Serialize() []byte {
+	return sig.point.bytes()
+}
+
+// Verify returns whether the signature is a valid signature of the passed
+// message by the passed public key under the basic scheme.  Dash signs hashes,
+// so the message is usually the 32 bytes of a hash.
+func (sig *Signature) Verify(msg []byte, key *PublicKey) bool {
+	return sig.verify(msg, key, basicSchemeDST)
+}
+
+// verify returns whether the signature is a valid signature of the passed
+// message by the passed public key, hashing the message to G2 with the passed
+// domain separation tag.
+func (sig *Signature) verify(msg []byte, key *PublicKey, dst []byte) bool {
+	if sig.point.isInfinity() || key.point.isInfinity() {
+		return false
+	}
+
+	// e(pk, H(m)) = e(g1, sig) is checked as e(pk, H(m)) * e(-g1, sig) = 1.
+	h := hashToG2(msg, dst)
+	var negG1 g1Point
+	negG1.neg(&g1Generator)
+	ps := []*g1Point{&key.point, &negG1}
+	qs := []*g2Point{&h, &sig.point}
+	return pairingProductIsOne(ps, qs)
+}
+
+// PrivateKey is a BLS private key, which is a scalar less than the order of
+// G1.
+type PrivateKey struct {
+	k *big.Int
+}
+
+// PrivKeyFromBytes decodes a big endian private key.  The key must be less
+// than the order of G1 and must not be zero.
+func PrivKeyFromBytes(b []byte) (*PrivateKey, error) {
+	if len(b) != PrivKeySize {
+		return nil, errors.New("invalid length of private key")
+	}
+	k := new(big.Int).SetBytes(b)
+	if k.Sign() == 0 || k.Cmp(rBig) >= 0 {
+		return nil, errors.New("private key out of range")
+	}
+	return &PrivateKey{k: k}, nil
+}
+
+// PubKey returns the public key of the private key.
+func (k *PrivateKey) PubKey() *PublicKey {
+	var pk PublicKey
+	pk.point.mul(&g1Generator, k.k)
+	return &pk
+}
+
+// Sign returns the signature of the passed message under the basic scheme.
+func (k *PrivateKey) Sign(msg []byte) *Signature {
+	return k.sign(msg, basicSchemeDST)
+}
+
+// sign returns the signature of the passed message, hashing the message to G2
+// with the passed domain separation tag.
+func (k *PrivateKey) sign(msg []byte, dst []byte) *Signature {
+	var sig Signature
+	h := hashToG2(msg, dst)
+	sig.point.mul(&h, k.k)
+	return &sig
+}
@@ -0,0 +1,326 @@
+// Copyright (c) 2013-2016 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package bls
+
+import (
+	"bytes"
+	"encoding/hex"
+	"math/big"
+	"math/rand"
+	"testing"
+)
+
+// hexToBytes converts the passed hex string into bytes and will panic if there
+// is an error.  This is only provided for the hard-coded constants so errors in
+// the source code can be detected.  It will only (and must only) be called with
+// hard-coded values.
+func hexToBytes(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic("invalid hex in source file: " + s)
+	}
+	return b
+}
+
+// randFe returns a random element of Fp along with its value.
+func randFe(rng *rand.Rand) (fe, *big.Int) {
+	n := new(big.Int).Rand(rng, pBig)
+	var z fe
+	z.setBig(n)
+	return z, n
+}
+
+// randFe12 returns a random element of Fp12.
+func randFe12(rng *rand.Rand) fe12 {
+	var z fe12
+	for _, c := range []*fe2{&z.c0.c0, &z.c0.c1, &z.c0.c2, &z.c1.c0,
+		&z.c1.c1, &z.c1.c2} {
+
+		c.c0, _ = randFe(rng)
+		c.c1, _ = randFe(rng)
+	}
+	return z
+}
+
+// TestField ensures the arithmetic of the base field and its extensions
+// agrees with integer arithmetic modulo p and with the field axioms.
+func TestField(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 100; i++ {
+		x, xn := randFe(rng)
+		y, yn := randFe(rng)
+
+		var z fe
+		want := new(big.Int)
+		if z.mul(&x, &y).big().Cmp(want.Mul(xn, yn).Mod(want, pBig)) != 0 {
+			t.Fatalf("mul: got %x, want %x", z.big(), want)
+		}
+		if z.add(&x, &y).big().Cmp(want.Add(xn, yn).Mod(want, pBig)) != 0 {
+			t.Fatalf("add: got %x, want %x", z.big(), want)
+		}
+		if z.sub(&x, &y).big().Cmp(want.Sub(xn, yn).Mod(want, pBig)) != 0 {
+			t.Fatalf("sub: got %x, want %x", z.big(), want)
+		}
+		if !z.inverse(&x).mul(&z, &x).equal(&feOne) {
+			t.Fatalf("inverse of %x is wrong", xn)
+		}
+		z.square(&x)
+		var s fe
+		if !s.sqrt(&z) || !s.square(&s).equal(&z) {
+			t.Fatalf("sqrt of square %x failed", z.big())
+		}
+		if !s.setBytes(z.bytes()) || !s.equal(&z) {
+			t.Fatalf("bytes of %x do not round trip", z.big())
+		}
+
+		x2 := fe2{c0: x, c1: y}
+		var z2, s2 fe2
+		if !z2.inverse(&x2).mul(&z2, &x2).equal(&fe2One) {
+			t.Fatal("fe2 inverse is wrong")
+		}
+		z2.square(&x2)
+		if !s2.sqrt(&z2) || !s2.square(&s2).equal(&z2) {
+			t.Fatal("fe2 sqrt of square failed")
+		}
+		if !z2.isSquare() {
+			t.Fatal("fe2 square is not a square")
+		}
+
+		x12 := randFe12(rng)
+		y12 := randFe12(rng)
+		var z12, w12 fe12
+		if !z12.inverse(&x12).mul(&z12, &x12).isOne() {
+			t.Fatal("fe12 inverse is wrong")
+		}
+		z12.mul(&x12, &y12)
+		w12.mul(&y12, &x12)
+		if !z12.equal(&w12) {
+			t.Fatal("fe12 mul is not commutative")
+		}
+		if i < 3 {
+			z12.frobenius(&x12)
+			w12.exp(&x12, pBig)
+			if !z12.equal(&w12) {
+				t.Fatal("fe12 frobenius is not exponentiation by p")
+			}
+		}
+	}
+}
+
+// TestGenerators ensures the generators are on their curves, in their
+// subgroups and encode to their standard compressed encodings.
+func TestGenerators(t *testing.T) {
+	if !g1Generator.isOnCurve() || !g1Generator.isInSubgroup() {
+		t.Fatal("G1 generator is not in G1")
+	}
+	if !g2Generator.isOnCurve() || !g2Generator.isInSubgroup() {
+		t.Fatal("G2 generator is not in G2")
+	}
+
+	g1 := hexToBytes("97f1d3a73197d7942695638c4fa9ac0fc3688c4f9774b905a14e3a3f" +
+		"171bac586c55e83ff97a1aeffb3af00adb22c6bb")
+	if got := g1Generator.bytes(); !bytes.Equal(got, g1) {
+		t.Fatalf("G1 generator: got %x, want %x", got, g1)
+	}
+	g2 := hexToBytes("93e02b6052719f607dacd3a088274f65596bd0d09920b61ab5da61bb" +
+		"dc7f5049334cf11213945d57e5ac7d055d042b7e024aa2b2f08f0a9126080527" +
+		"2dc51051c6e47ad4fa403b02b4510b647ae3d1770bac0326a805bbefd48056c8" +
+		"c121bdb8")
+	if got := g2Generator.bytes(); !bytes.Equal(got, g2) {
+		t.Fatalf("G2 generator: got %x, want %x", got, g2)
+	}
+
+	p1, err := g1FromBytes(g1)
+	if err != nil || !p1.equal(&g1Generator) {
+		t.Fatalf("G1 generator does not decode: %v", err)
+	}
+	p2, err := g2FromBytes(g2)
+	if err != nil || !p2.equal(&g2Generator) {
+		t.Fatalf("G2 generator does not decode: %v", err)
+	}
+}
+
+// TestExpandMessageXMD ensures messages are expanded as in the test vectors of
+// the hash to curve specification.
+func TestExpandMessageXMD(t *testing.T) {
+	dst := []byte("QUUX-V01-CS02-with-expander-SHA256-128")
+	want := hexToBytes("68a985b87eb6b46952128911f2a4412bbc302a9d759667f87f7a21d803f07235")
+	if got := expandMessageXMD(nil, dst, 0x20); !bytes.Equal(got, want) {
+		t.Fatalf("expandMessageXMD: got %x, want %x", got, want)
+	}
+}
+
+// TestHashToG2 ensures messages hash to the points of the test vectors of the
+// hash to curve specification.
+func TestHashToG2(t *testing.T) {
+	dst := []byte("QUUX-V01-CS02-with-BLS12381G2_XMD:SHA-256_SSWU_RO_")
+	p := hashToG2(nil, dst)
+	x, y := p.affine()
+	wantX := fe2FromHex("0141ebfbdca40eb85b87142e130ab689c673cf60f1a3e98d69335266f30d9b8d4ac44c1038e9dcdd5393faf5c41fb78a",
+		"05cb8437535e20ecffaef7752baddf98034139c38452458baeefab379ba13dff5bf5dd71b72418717047f5b0f37da03d")
+	wantY := fe2FromHex("0503921d7f6a12805e72940b963c0cf3471c7b2a524950ca195d11062ee75ec076daf2d4bc358c4b190c0c98064fdd92",
+		"12424ac32561493f3fe3c260708a12b7c620e7be00099a974e259ddc7d1f6395c3c811cdd19f1e8dbf3e9ecfdcbab8d6")
+	if !x.equal(&wantX) || !y.equal(&wantY) {
+		t.Fatalf("hashToG2: got (%x + %x*u, %x + %x*u)", x.c0.big(),
+			x.c1.big(), y.c0.big(), y.c1.big())
+	}
+}
+
+// TestClearCofactor ensures clearing the cofactor with the endomorphism psi
+// agrees with multiplying by the effective cofactor of G2.
+func TestClearCofactor(t *testing.T) {
+	hEff := fromHex("bc69f08f2ee75b3584c6a0ea91b352888e2a8e9145ad7689986ff031508ffe1329c2f178731db956d82bf015d1212b02ec0ec69d7477c1ae954cbc06689f6a359894c0adebbf6b4e8020005aaa95551")
+	u := hashToFieldFp2([]byte("clear cofactor"), basicSchemeDST, 1)
+	x, y := mapToCurveSSWU(&u[0])
+	p := isoMap(&x, &y)
+	if !p.isOnCurve() {
+		t.Fatal("isogeny does not map to the twist")
+	}
+	got := clearCofactor(&p)
+	var want g2Point
+	want.mul(&p, hEff)
+	if !got.equal(&want) || !got.isInSubgroup() {
+		t.Fatal("clearCofactor does not multiply by the effective cofactor")
+	}
+}
+
+// TestPairing ensures the pairing is bilinear and not degenerate.
+func TestPairing(t *testing.T) {
+	a := big.NewInt(0x1234567)
+	b := big.NewInt(0x7654321)
+	var aP, negP g1Point
+	var bQ g2Point
+	aP.mul(&g1Generator, a)
+	bQ.mul(&g2Generator, b)
+	negP.neg(&g1Generator)
+	var abQ g2Point
+	abQ.mul(&g2Generator, new(big.Int).Mul(a, b))
+
+	// e(aP, bQ) * e(-P, abQ) = 1
+	if !pairingProductIsOne([]*g1Point{&aP, &negP}, []*g2Point{&bQ, &abQ}) {
+		t.Fatal("pairing is not bilinear")
+	}
+	if pairingProductIsOne([]*g1Point{&aP}, []*g2Point{&bQ}) {
+		t.Fatal("pairing is degenerate")
+	}
+}
+
+// TestFinalExponentiation ensures the final exponentiation raises to the power
+// 3*(p^12 - 1)/r.
+func TestFinalExponentiation(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	f := randFe12(rng)
+	p6 := new(big.Int).Exp(pBig, big.NewInt(6), nil)
+	e := new(big.Int).Mul(p6, p6)
+	e.Sub(e, big.NewInt(1)).Div(e, rBig).Mul(e, big.NewInt(3))
+	var want fe12
+	want.exp(&f, e)
+	if got := finalExponentiation(&f); !got.equal(&want) {
+		t.Fatal("finalExponentiation does not raise to 3*(p^12 - 1)/r")
+	}
+}
+
+// TestSignVerify ensures signatures match known test vectors, verify with
+// the key which made them and fail with other keys and messages.
+func TestSignVerify(t *testing.T) {
+	// The sign test vector of the Ethereum consensus specification, which
+	// uses the proof of possession scheme.
+	popDST := []byte("BLS_SIG_BLS12381G2_XMD:SHA-256_SSWU_RO_POP_")
+	priv, err := PrivKeyFromBytes(hexToBytes("263dbd792f5b1be47ed85f8938c0f29586af0d3ac7b977f21c278fe1462040e3"))
+	if err != nil {
+		t.Fatalf("PrivKeyFromBytes: unexpected error %v", err)
+	}
+	wantPub := hexToBytes("a491d1b0ecd9bb917989f0e74f0dea0422eac4a873e5e2644f368dffb9a6e20fd6e10c1b77654d067c0618f6e5a7f79a")
+	if got := priv.PubKey().Serialize(); !bytes.Equal(got, wantPub) {
+		t.Fatalf("PubKey: got %x, want %x", got, wantPub)
+	}
+	msg := make([]byte, 32)
+	wantSig := hexToBytes("b6ed936746e01f8ecf281f020953fbf1f01debd5657c4a383940b020b26507f6076334f91e2366c96e9ab279fb5158090352ea1c5b0c9274504f4f0e7053af24802e51e4568d164fe986834f41e55c8e850ce1f98458c0cfc9ab380b55285a55")
+	if got := priv.sign(msg, popDST).Serialize(); !bytes.Equal(got, wantSig) {
+		t.Fatalf("sign: got %x, want %x", got, wantSig)
+	}
+	sig, err := ParseSignature(wantSig)
+	if err != nil {
+		t.Fatalf("ParseSignature: unexpected error %v", err)
+	}
+	pub, err := ParsePubKey(wantPub)
+	if err != nil {
+		t.Fatalf("ParsePubKey: unexpected error %v", err)
+	}
+	if !sig.verify(msg, pub, popDST) {
+		t.Fatal("verify: valid signature rejected")
+	}
+
+	// Signatures of the basic scheme only verify for the signed message and
+	// the key which signed it.
+	sig = priv.Sign(msg)
+	if !sig.Verify(msg, pub) {
+		t.Fatal("Verify: valid signature rejected")
+	}
+	if sig.Verify(append(msg, 0), pub) {
+		t.Fatal("Verify: signature of other message accepted")
+	}
+	if sig.verify(msg, pub, popDST) {
+		t.Fatal("verify: signature of other scheme accepted")
+	}
+	other, _ := PrivKeyFromBytes(hexToBytes("47b8192d77bf871b62e87859d653922725724a5c031afeabc60bcef5ff665138"))
+	if sig.Verify(msg, other.PubKey()) {
+		t.Fatal("Verify: signature of other key accepted")
+	}
+
+	// The legacy serialization holds the sign of y in the most significant
+	// bit, so it decodes to the same key, and signatures only verify for
+	// the key with the serialized sign.
+	legacy := priv.PubKey().Serialize()
+	legacySign := legacy[0]&flagSign != 0
+	legacy[0] &^= flagCompressed | flagSign
+	if legacySign {
+		legacy[0] |= legacyFlagSign
+	}
+	legacyPub, err := ParseLegacyPubKey(legacy)
+	if err != nil {
+		t.Fatalf("ParseLegacyPubKey: unexpected error %v", err)
+	}
+	if !bytes.Equal(legacyPub.Serialize(), priv.PubKey().Serialize()) {
+		t.Fatalf("ParseLegacyPubKey: got key %x, want %x",
+			legacyPub.Serialize(), priv.PubKey().Serialize())
+	}
+	if !sig.Verify(msg, legacyPub) {
+		t.Fatal("Verify: valid signature rejected for legacy key")
+	}
+	legacy[0] ^= legacyFlagSign
+	negLegacyPub, err := ParseLegacyPubKey(legacy)
+	if err != nil {
+		t.Fatalf("ParseLegacyPubKey: unexpected error %v", err)
+	}
+	if sig.Verify(msg, negLegacyPub) {
+		t.Fatal("Verify: signature accepted for the negated legacy key")
+	}
+
+	invalid := map[string][]byte{
+		"infinity":     append([]byte{0xc0}, make([]byte, 47)...),
+		"short":        wantPub[:47],
+		"uncompressed": append([]byte{0x11}, wantPub[1:]...),
+		"not on curve": append([]byte{0x80}, bytes.Repeat([]byte{0x01}, 47)...),
+	}
+	for name, b := range invalid {
+		if _, err := ParsePubKey(b); err == nil {
+			t.Errorf("ParsePubKey %s: no error", name)
+		}
+	}
+}
+
+// BenchmarkVerify benchmarks the verification of a signature.
+func BenchmarkVerify(b *testing.B) {
+	priv, _ := PrivKeyFromBytes(hexToBytes("263dbd792f5b1be47ed85f8938c0f29586af0d3ac7b977f21c278fe1462040e3"))
+	pub := priv.PubKey()
+	msg := make([]byte, 32)
+	sig := priv.Sign(msg)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		sig.Verify(msg, pub)
+	}
+}
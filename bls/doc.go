@@ -0,0 +1,23 @@
+// Copyright (c) 2013-2016 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+/*
+Package bls implements the BLS signatures over the BLS12-381 curve which Dash
+uses for masternode operator keys and the threshold signatures of long living
+masternode quorums (LLMQs).
+
+Public keys are points of G1 and signatures points of G2.  Since the basic BLS
+scheme was activated, Dash signs with the basic scheme of the IETF BLS
+signature draft, hashing messages to G2 as specified by the hash to curve
+specification (RFC 9380) with the domain separation tag
+BLS_SIG_BLS12381G2_XMD:SHA-256_SSWU_RO_NUL_, and serializes points in the
+compressed format of the ZCash BLS12-381 specification.  Public keys which
+were registered before the activation keep their legacy serialization in
+provider transactions of version 1, which ParseLegacyPubKey decodes.
+
+The package only verifies signatures and signs for testing purposes.  It is
+written for clarity and does not protect private keys against timing attacks,
+so it must not be used to sign with keys which need to be kept secret.
+*/
+package bls
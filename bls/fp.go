@@ -0,0 +1,266 @@
+// Copyright (c) 2013-2016 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package bls
+
+import (
+	"math/big"
+	"math/bits"
+)
+
+// fe is an element of the base field Fp of BLS12-381.  It is stored as six
+// little endian 64-bit limbs in Montgomery form, that is, the element a is
+// stored as a*R mod p with R = 2^384.
+type fe [6]uint64
+
+var (
+	// pBig is the prime modulus of the base field.
+	pBig = fromHex("1a0111ea397fe69a4b1ba7b6434bacd764774b84f38512bf6730d2a0" +
+		"f6b0f6241eabfffeb153ffffb9feffffffffaaab")
+
+	// pMinus1Half is (p-1)/2, the largest element which is not
+	// lexicographically largest.
+	pMinus1Half = new(big.Int).Rsh(pBig, 1)
+
+	// pPlus1Quarter is the exponent used to compute square roots, which
+	// works since p = 3 mod 4.
+	pPlus1Quarter = new(big.Int).Rsh(new(big.Int).Add(pBig, big.NewInt(1)), 2)
+
+	// p holds the limbs of the modulus.
+	p = limbs(pBig)
+
+	// pInv is -p^-1 mod 2^64, used by the Montgomery reduction.
+	pInv = func() uint64 {
+		inv := uint64(1)
+		for i := 0; i < 6; i++ {
+			inv *= 2 - p[0]*inv
+		}
+		return -inv
+	}()
+
+	// r2 is R^2 mod p, used to convert elements to Montgomery form.
+	r2 = limbs(new(big.Int).Mod(new(big.Int).Lsh(big.NewInt(1), 768), pBig))
+
+	// feOne is the multiplicative identity in Montgomery form.
+	feOne = limbs(new(big.Int).Mod(new(big.Int).Lsh(big.NewInt(1), 384), pBig))
+)
+
+// fromHex returns the integer encoded by the passed hex string.  It panics
+// when the string is invalid, so it must only be used for constants.
+func fromHex(s string) *big.Int {
+	n, ok := new(big.Int).SetString(s, 16)
+	if !ok {
+		panic("invalid hex constant " + s)
+	}
+	return n
+}
+
+// limbs returns the limbs of the passed integer, which must be less than
+// 2^384.
+func limbs(n *big.Int) fe {
+	var z fe
+	b := n.Bytes()
+	for i := 0; i < len(b); i++ {
+		z[i/8] |= uint64(b[len(b)-1-i]) << uint(8*(i%8))
+	}
+	return z
+}
+
+// setBig sets z to the passed integer reduced modulo p.
+func (z *fe) setBig(n *big.Int) *fe {
+	t := limbs(new(big.Int).Mod(n, pBig))
+	return z.mul(&t, &r2)
+}
+
+// big returns the canonical value of z as an integer.
+func (z *fe) big() *big.Int {
+	t := fe{1}
+	t.mul(z, &t)
+	var b [48]byte
+	for i := 0; i < 48; i++ {
+		b[47-i] = byte(t[i/8] >> uint(8*(i%8)))
+	}
+	return new(big.Int).SetBytes(b[:])
+}
+
+// setBytes sets z to the big endian integer encoded by the passed 48 bytes.
+// It returns false when the integer is not less than p.
+func (z *fe) setBytes(b []byte) bool {
+	n := new(big.Int).SetBytes(b)
+	if n.Cmp(pBig) >= 0 {
+		return false
+	}
+	z.setBig(n)
+	return true
+}
+
+// bytes returns the 48 byte big endian encoding of z.
+func (z *fe) bytes() []byte {
+	var b [48]byte
+	n := z.big().Bytes()
+	copy(b[48-len(n):], n)
+	return b[:]
+}
+
+// isZero returns whether z is zero.
+func (z *fe) isZero() bool {
+	return *z == fe{}
+}
+
+// equal returns whether z and x are equal.
+func (z *fe) equal(x *fe) bool {
+	return *z == *x
+}
+
+// reduceOnce sets z to t, minus p if t is not less than p.
+func (z *fe) reduceOnce(t *fe) *fe {
+	var s fe
+	var b uint64
+	for i := 0; i < 6; i++ {
+		s[i], b = bits.Sub64(t[i], p[i], b)
+	}
+	if b == 0 {
+		*z = s
+	} else {
+		*z = *t
+	}
+	return z
+}
+
+// add sets z to x+y.
+func (z *fe) add(x, y *fe) *fe {
+	var t fe
+	var c uint64
+	for i := 0; i < 6; i++ {
+		t[i], c = bits.Add64(x[i], y[i], c)
+	}
+	return z.reduceOnce(&t)
+}
+
+// double sets z to 2x.
+func (z *fe) double(x *fe) *fe {
+	return z.add(x, x)
+}
+
+// sub sets z to x-y.
+func (z *fe) sub(x, y *fe) *fe {
+	var t fe
+	var b uint64
+	for i := 0; i < 6; i++ {
+		t[i], b = bits.Sub64(x[i], y[i], b)
+	}
+	if b != 0 {
+		var c uint64
+		for i := 0; i < 6; i++ {
+			t[i], c = bits.Add64(t[i], p[i], c)
+		}
+	}
+	*z = t
+	return z
+}
+
+// neg sets z to -x.
+func (z *fe) neg(x *fe) *fe {
+	var zero fe
+	return z.sub(&zero, x)
+}
+
+// mul sets z to x*y using the coarsely integrated operand scanning method of
+// Montgomery multiplication.
+func (z *fe) mul(x, y *fe) *fe {
+	var t [8]uint64
+	for i := 0; i < 6; i++ {
+		var c, cc uint64
+		for j := 0; j < 6; j++ {
+			hi, lo := bits.Mul64(x[j], y[i])
+			lo, cc = bits.Add64(lo, t[j], 0)
+			hi += cc
+			lo, cc = bits.Add64(lo, c, 0)
+			hi += cc
+			t[j], c = lo, hi
+		}
+		t[6], cc = bits.Add64(t[6], c, 0)
+		t[7] = cc
+
+		m := t[0] * pInv
+		hi, lo := bits.Mul64(m, p[0])
+		_, cc = bits.Add64(lo, t[0], 0)
+		c = hi + cc
+		for j := 1; j < 6; j++ {
+			hi, lo = bits.Mul64(m, p[j])
+			lo, cc = bits.Add64(lo, t[j], 0)
+			hi += cc
+			lo, cc = bits.Add64(lo, c, 0)
+			hi += cc
+			t[j-1], c = lo, hi
+		}
+		t[5], cc = bits.Add64(t[6], c, 0)
+		t[6] = t[7] + cc
+	}
+
+	r := fe{t[0], t[1], t[2], t[3], t[4], t[5]}
+	return z.reduceOnce(&r)
+}
+
+// square sets z to x^2.
+func (z *fe) square(x *fe) *fe {
+	return z.mul(x, x)
+}
+
+// exp sets z to x^e.
+func (z *fe) exp(x *fe, e *big.Int) *fe {
+	r := feOne
+	base := *x
+	for i := e.BitLen() - 1; i >= 0; i-- {
+		r.square(&r)
+		if e.Bit(i) == 1 {
+			r.mul(&r, &base)
+		}
+	}
+	*z = r
+	return z
+}
+
+// inverse sets z to x^-1, or to zero when x is zero.
+func (z *fe) inverse(x *fe) *fe {
+	n := x.big()
+	if n.ModInverse(n, pBig) == nil {
+		*z = fe{}
+		return z
+	}
+	return z.setBig(n)
+}
+
+// sqrt sets z to a square root of x and returns whether x is a square.  z is
+// left unchanged when it is not.
+func (z *fe) sqrt(x *fe) bool {
+	var s, check fe
+	s.exp(x, pPlus1Quarter)
+	if !check.square(&s).equal(x) {
+		return false
+	}
+	*z = s
+	return true
+}
+
+// isSquare returns whether z is a square, which is the case for zero.
+func (z *fe) isSquare() bool {
+	var t fe
+	t.exp(z, pMinus1Half)
+	return t.isZero() || t.equal(&feOne)
+}
+
+// sgn0 returns the parity of the canonical value of z as defined by the
+// hash to curve specification.
+func (z *fe) sgn0() uint {
+	t := fe{1}
+	t.mul(z, &t)
+	return uint(t[0] & 1)
+}
+
+// isLexLargest returns whether z is greater than (p-1)/2, which determines
+// the sign flag of compressed points.
+func (z *fe) isLexLargest() bool {
+	return z.big().Cmp(pMinus1Half) > 0
+}
@@ -0,0 +1,226 @@
+// Copyright (c) 2013-2016 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package bls
+
+import (
+	"math/big"
+)
+
+// fe6 is an element c0 + c1*v + c2*v^2 of Fp6 = Fp2[v]/(v^3-(1+u)).
+type fe6 struct {
+	c0, c1, c2 fe2
+}
+
+// fe12 is an element c0 + c1*w of Fp12 = Fp6[w]/(w^2-v), the field the
+// pairing maps to.
+type fe12 struct {
+	c0, c1 fe6
+}
+
+var (
+	// fe12One is the multiplicative identity of Fp12.
+	fe12One = fe12{c0: fe6{c0: fe2One}}
+
+	// frobeniusCoeffs holds (1+u)^(i*(p-1)/6) for i = 0..5, the factors
+	// the Frobenius endomorphism multiplies the coefficient of w^i with.
+	frobeniusCoeffs = func() [6]fe2 {
+		var coeffs [6]fe2
+		xi := fe2{c0: feOne, c1: feOne}
+		e := new(big.Int).Sub(pBig, big.NewInt(1))
+		e.Div(e, big.NewInt(6))
+		var g fe2
+		g.exp(&xi, e)
+		coeffs[0] = fe2One
+		for i := 1; i < 6; i++ {
+			coeffs[i].mul(&coeffs[i-1], &g)
+		}
+		return coeffs
+	}()
+)
+
+// add sets z to x+y.
+func (z *fe6) add(x, y *fe6) *fe6 {
+	z.c0.add(&x.c0, &y.c0)
+	z.c1.add(&x.c1, &y.c1)
+	z.c2.add(&x.c2, &y.c2)
+	return z
+}
+
+// sub sets z to x-y.
+func (z *fe6) sub(x, y *fe6) *fe6 {
+	z.c0.sub(&x.c0, &y.c0)
+	z.c1.sub(&x.c1, &y.c1)
+	z.c2.sub(&x.c2, &y.c2)
+	return z
+}
+
+// neg sets z to -x.
+func (z *fe6) neg(x *fe6) *fe6 {
+	z.c0.neg(&x.c0)
+	z.c1.neg(&x.c1)
+	z.c2.neg(&x.c2)
+	return z
+}
+
+// mul sets z to x*y using Karatsuba multiplication.
+func (z *fe6) mul(x, y *fe6) *fe6 {
+	var v0, v1, v2, s, t, c0, c1, c2 fe2
+	v0.mul(&x.c0, &y.c0)
+	v1.mul(&x.c1, &y.c1)
+	v2.mul(&x.c2, &y.c2)
+
+	// c0 = ((x1+x2)(y1+y2) - v1 - v2)(1+u) + v0
+	s.add(&x.c1, &x.c2)
+	t.add(&y.c1, &y.c2)
+	c0.mul(&s, &t).sub(&c0, &v1).sub(&c0, &v2).mulByNonResidue(&c0)
+	c0.add(&c0, &v0)
+
+	// c1 = (x0+x1)(y0+y1) - v0 - v1 + (1+u)v2
+	s.add(&x.c0, &x.c1)
+	t.add(&y.c0, &y.c1)
+	c1.mul(&s, &t).sub(&c1, &v0).sub(&c1, &v1)
+	t.mulByNonResidue(&v2)
+	c1.add(&c1, &t)
+
+	// c2 = (x0+x2)(y0+y2) - v0 - v2 + v1
+	s.add(&x.c0, &x.c2)
+	t.add(&y.c0, &y.c2)
+	c2.mul(&s, &t).sub(&c2, &v0).sub(&c2, &v2).add(&c2, &v1)
+
+	z.c0, z.c1, z.c2 = c0, c1, c2
+	return z
+}
+
+// mulByV sets z to x*v.
+func (z *fe6) mulByV(x *fe6) *fe6 {
+	var t fe2
+	t.mulByNonResidue(&x.c2)
+	z.c2 = x.c1
+	z.c1 = x.c0
+	z.c0 = t
+	return z
+}
+
+// inverse sets z to x^-1.
+func (z *fe6) inverse(x *fe6) *fe6 {
+	var t0, t1, t2, s, d fe2
+
+	// t0 = x0^2 - (1+u)*x1*x2
+	s.mul(&x.c1, &x.c2).mulByNonResidue(&s)
+	t0.square(&x.c0).sub(&t0, &s)
+
+	// t1 = (1+u)*x2^2 - x0*x1
+	s.mul(&x.c0, &x.c1)
+	t1.square(&x.c2).mulByNonResidue(&t1).sub(&t1, &s)
+
+	// t2 = x1^2 - x0*x2
+	s.mul(&x.c0, &x.c2)
+	t2.square(&x.c1).sub(&t2, &s)
+
+	// d = x0*t0 + (1+u)*(x2*t1 + x1*t2)
+	s.mul(&x.c2, &t1)
+	d.mul(&x.c1, &t2).add(&d, &s).mulByNonResidue(&d)
+	s.mul(&x.c0, &t0)
+	d.add(&d, &s).inverse(&d)
+
+	z.c0.mul(&t0, &d)
+	z.c1.mul(&t1, &d)
+	z.c2.mul(&t2, &d)
+	return z
+}
+
+// equal returns whether z and x are equal.
+func (z *fe12) equal(x *fe12) bool {
+	return z.c0.c0.equal(&x.c0.c0) && z.c0.c1.equal(&x.c0.c1) &&
+		z.c0.c2.equal(&x.c0.c2) && z.c1.c0.equal(&x.c1.c0) &&
+		z.c1.c1.equal(&x.c1.c1) && z.c1.c2.equal(&x.c1.c2)
+}
+
+// isOne returns whether z is the multiplicative identity.
+func (z *fe12) isOne() bool {
+	return z.equal(&fe12One)
+}
+
+// mul sets z to x*y using Karatsuba multiplication.
+func (z *fe12) mul(x, y *fe12) *fe12 {
+	var t0, t1, s, t, c0 fe6
+	t0.mul(&x.c0, &y.c0)
+	t1.mul(&x.c1, &y.c1)
+	c0.mulByV(&t1).add(&c0, &t0)
+	s.add(&x.c0, &x.c1)
+	t.add(&y.c0, &y.c1)
+	z.c1.mul(&s, &t).sub(&z.c1, &t0).sub(&z.c1, &t1)
+	z.c0 = c0
+	return z
+}
+
+// square sets z to x^2.  With ab = x0*x1, the square is
+// (x0+x1)(x0+v*x1) - ab - v*ab + 2ab*w.
+func (z *fe12) square(x *fe12) *fe12 {
+	var ab, s, t fe6
+	ab.mul(&x.c0, &x.c1)
+	s.add(&x.c0, &x.c1)
+	t.mulByV(&x.c1).add(&t, &x.c0)
+	s.mul(&s, &t).sub(&s, &ab)
+	t.mulByV(&ab)
+	z.c0.sub(&s, &t)
+	z.c1.add(&ab, &ab)
+	return z
+}
+
+// conjugate sets z to c0 - c1*w, which is x^(p^6).
+func (z *fe12) conjugate(x *fe12) *fe12 {
+	z.c0 = x.c0
+	z.c1.neg(&x.c1)
+	return z
+}
+
+// inverse sets z to x^-1.
+func (z *fe12) inverse(x *fe12) *fe12 {
+	var t0, t1 fe6
+	t0.mul(&x.c0, &x.c0)
+	t1.mul(&x.c1, &x.c1)
+	t1.mulByV(&t1)
+	t0.sub(&t0, &t1).inverse(&t0)
+	z.c0.mul(&x.c0, &t0)
+	t0.neg(&t0)
+	z.c1.mul(&x.c1, &t0)
+	return z
+}
+
+// frobenius sets z to x^p.  Writing x as the sum of c_i*w^i with c_i in Fp2,
+// x^p is the sum of conj(c_i)*w^(i*p), and w^(i*p) = (1+u)^(i*(p-1)/6)*w^i.
+func (z *fe12) frobenius(x *fe12) *fe12 {
+	coeffs := [6]*fe2{&x.c0.c0, &x.c1.c0, &x.c0.c1, &x.c1.c1, &x.c0.c2,
+		&x.c1.c2}
+	var r [6]fe2
+	for i, c := range coeffs {
+		r[i].conjugate(c).mul(&r[i], &frobeniusCoeffs[i])
+	}
+	z.c0.c0, z.c1.c0, z.c0.c1, z.c1.c1, z.c0.c2, z.c1.c2 = r[0], r[1],
+		r[2], r[3], r[4], r[5]
+	return z
+}
+
+// expByX sets z to x^(-0xd201000000010000), the power of the curve parameter,
+// for an element x of the cyclotomic subgroup, whose inverse is its conjugate.
+func (z *fe12) expByX(x *fe12) *fe12 {
+	z.exp(x, blsXAbs)
+	return z.conjugate(z)
+}
+
+// exp sets z to x^e.
+func (z *fe12) exp(x *fe12, e *big.Int) *fe12 {
+	r := fe12One
+	base := *x
+	for i := e.BitLen() - 1; i >= 0; i-- {
+		r.square(&r)
+		if e.Bit(i) == 1 {
+			r.mul(&r, &base)
+		}
+	}
+	*z = r
+	return z
+}
@@ -0,0 +1,217 @@
+// Copyright (c) 2013-2016 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package bls
+
+import (
+	"math/big"
+)
+
+// fe2 is an element c0 + c1*u of the quadratic extension Fp2 = Fp[u]/(u^2+1).
+type fe2 struct {
+	c0, c1 fe
+}
+
+// fe2One is the multiplicative identity of Fp2.
+var fe2One = fe2{c0: feOne}
+
+// fe2FromHex returns the element of Fp2 with the passed hex encoded
+// coefficients.  It must only be used for constants.
+func fe2FromHex(c0, c1 string) fe2 {
+	var z fe2
+	z.c0.setBig(fromHex(c0))
+	z.c1.setBig(fromHex(c1))
+	return z
+}
+
+// isZero returns whether z is zero.
+func (z *fe2) isZero() bool {
+	return z.c0.isZero() && z.c1.isZero()
+}
+
+// equal returns whether z and x are equal.
+func (z *fe2) equal(x *fe2) bool {
+	return z.c0.equal(&x.c0) && z.c1.equal(&x.c1)
+}
+
+// add sets z to x+y.
+func (z *fe2) add(x, y *fe2) *fe2 {
+	z.c0.add(&x.c0, &y.c0)
+	z.c1.add(&x.c1, &y.c1)
+	return z
+}
+
+// double sets z to 2x.
+func (z *fe2) double(x *fe2) *fe2 {
+	return z.add(x, x)
+}
+
+// sub sets z to x-y.
+func (z *fe2) sub(x, y *fe2) *fe2 {
+	z.c0.sub(&x.c0, &y.c0)
+	z.c1.sub(&x.c1, &y.c1)
+	return z
+}
+
+// neg sets z to -x.
+func (z *fe2) neg(x *fe2) *fe2 {
+	z.c0.neg(&x.c0)
+	z.c1.neg(&x.c1)
+	return z
+}
+
+// conjugate sets z to the conjugate of x, which is also its image under the
+// Frobenius endomorphism.
+func (z *fe2) conjugate(x *fe2) *fe2 {
+	z.c0 = x.c0
+	z.c1.neg(&x.c1)
+	return z
+}
+
+// mul sets z to x*y.
+func (z *fe2) mul(x, y *fe2) *fe2 {
+	var v0, v1, s, t fe
+	v0.mul(&x.c0, &y.c0)
+	v1.mul(&x.c1, &y.c1)
+	s.add(&x.c0, &x.c1)
+	t.add(&y.c0, &y.c1)
+	s.mul(&s, &t)
+	s.sub(&s, &v0)
+	z.c1.sub(&s, &v1)
+	z.c0.sub(&v0, &v1)
+	return z
+}
+
+// mulFe sets z to x*y for an element y of the base field.
+func (z *fe2) mulFe(x *fe2, y *fe) *fe2 {
+	z.c0.mul(&x.c0, y)
+	z.c1.mul(&x.c1, y)
+	return z
+}
+
+// square sets z to x^2.
+func (z *fe2) square(x *fe2) *fe2 {
+	var s, d, m fe
+	s.add(&x.c0, &x.c1)
+	d.sub(&x.c0, &x.c1)
+	m.mul(&x.c0, &x.c1)
+	z.c0.mul(&s, &d)
+	z.c1.double(&m)
+	return z
+}
+
+// mulByNonResidue sets z to x*(1+u), where 1+u is the non-residue used to
+// build the sextic extension.
+func (z *fe2) mulByNonResidue(x *fe2) *fe2 {
+	var t fe
+	t.sub(&x.c0, &x.c1)
+	z.c1.add(&x.c0, &x.c1)
+	z.c0 = t
+	return z
+}
+
+// norm returns c0^2 + c1^2, the norm of z over Fp.
+func (z *fe2) norm() fe {
+	var n, t fe
+	n.square(&z.c0)
+	t.square(&z.c1)
+	return *n.add(&n, &t)
+}
+
+// inverse sets z to x^-1, or to zero when x is zero.
+func (z *fe2) inverse(x *fe2) *fe2 {
+	n := x.norm()
+	n.inverse(&n)
+	z.c0.mul(&x.c0, &n)
+	n.neg(&n)
+	z.c1.mul(&x.c1, &n)
+	return z
+}
+
+// exp sets z to x^e.
+func (z *fe2) exp(x *fe2, e *big.Int) *fe2 {
+	r := fe2One
+	base := *x
+	for i := e.BitLen() - 1; i >= 0; i-- {
+		r.square(&r)
+		if e.Bit(i) == 1 {
+			r.mul(&r, &base)
+		}
+	}
+	*z = r
+	return z
+}
+
+// isSquare returns whether z is a square, which is the case exactly when its
+// norm is a square of the base field.
+func (z *fe2) isSquare() bool {
+	n := z.norm()
+	return n.isSquare()
+}
+
+// sqrt sets z to a square root of x and returns whether x is a square.  z is
+// left unchanged when it is not.
+func (z *fe2) sqrt(x *fe2) bool {
+	if x.c1.isZero() {
+		// The square root of an element of the base field is either in
+		// the base field or a multiple of u.
+		var s fe
+		if s.sqrt(&x.c0) {
+			z.c0, z.c1 = s, fe{}
+			return true
+		}
+		var n fe
+		n.neg(&x.c0)
+		if !s.sqrt(&n) {
+			return false
+		}
+		z.c0, z.c1 = fe{}, s
+		return true
+	}
+
+	// With a = sqrt(c0^2 + c1^2), the square root is x0 + x1*u where x0 is
+	// a square root of (c0 + a)/2 or (c0 - a)/2 and x1 = c1 / (2*x0).
+	n := x.norm()
+	var a fe
+	if !a.sqrt(&n) {
+		return false
+	}
+	var half, d, x0 fe
+	half.setBig(big.NewInt(2)).inverse(&half)
+	d.add(&x.c0, &a).mul(&d, &half)
+	if !x0.sqrt(&d) {
+		d.sub(&x.c0, &a).mul(&d, &half)
+		if !x0.sqrt(&d) {
+			return false
+		}
+	}
+	var x1 fe
+	x1.double(&x0).inverse(&x1).mul(&x1, &x.c1)
+
+	var s, check fe2
+	s.c0, s.c1 = x0, x1
+	if !check.square(&s).equal(x) {
+		return false
+	}
+	*z = s
+	return true
+}
+
+// sgn0 returns the sign of z as defined by the hash to curve specification.
+func (z *fe2) sgn0() uint {
+	sign0 := z.c0.sgn0()
+	if sign0 == 1 || !z.c0.isZero() {
+		return sign0
+	}
+	return z.c1.sgn0()
+}
+
+// isLexLargest returns whether z is lexicographically largest, comparing c1
+// first, which determines the sign flag of compressed points.
+func (z *fe2) isLexLargest() bool {
+	if !z.c1.isZero() {
+		return z.c1.isLexLargest()
+	}
+	return z.c0.isLexLargest()
+}
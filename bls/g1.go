@@ -0,0 +1,240 @@
+// Copyright (c) 2013-2016 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package bls
+
+import (
+	"errors"
+	"math/big"
+)
+
+// g1Point is a point of the curve E: y^2 = x^3 + 4 over Fp in Jacobian
+// coordinates, where (x, y, z) represents the affine point (x/z^2, y/z^3).
+// The point at infinity has z = 0.
+type g1Point struct {
+	x, y, z fe
+}
+
+var (
+	// g1B is the constant b of the curve equation.
+	g1B = func() fe {
+		var b fe
+		b.setBig(big.NewInt(4))
+		return b
+	}()
+
+	// g1Generator is the standard generator of G1.
+	g1Generator = func() g1Point {
+		var g g1Point
+		g.x.setBig(fromHex("17f1d3a73197d7942695638c4fa9ac0fc3688c4f9774b90" +
+			"5a14e3a3f171bac586c55e83ff97a1aeffb3af00adb22c6bb"))
+		g.y.setBig(fromHex("08b3f481e3aaa0f1a09e30ed741d8ae4fcf5e095d5d00af" +
+			"600db18cb2c04b3edd03cc744a2888ae40caa232946c5e7e1"))
+		g.z = feOne
+		return g
+	}()
+)
+
+// isInfinity returns whether p is the point at infinity.
+func (p *g1Point) isInfinity() bool {
+	return p.z.isZero()
+}
+
+// affine returns the affine coordinates of p, which must not be the point at
+// infinity.
+func (p *g1Point) affine() (fe, fe) {
+	var zInv, zInv2, x, y fe
+	zInv.inverse(&p.z)
+	zInv2.square(&zInv)
+	x.mul(&p.x, &zInv2)
+	y.mul(&p.y, &zInv2).mul(&y, &zInv)
+	return x, y
+}
+
+// isOnCurve returns whether p is on the curve.
+func (p *g1Point) isOnCurve() bool {
+	if p.isInfinity() {
+		return true
+	}
+	x, y := p.affine()
+	var lhs, rhs fe
+	lhs.square(&y)
+	rhs.square(&x).mul(&rhs, &x).add(&rhs, &g1B)
+	return lhs.equal(&rhs)
+}
+
+// equal returns whether p and q are the same point.
+func (p *g1Point) equal(q *g1Point) bool {
+	if p.isInfinity() || q.isInfinity() {
+		return p.isInfinity() && q.isInfinity()
+	}
+	var z1z1, z2z2, u1, u2, s1, s2 fe
+	z1z1.square(&p.z)
+	z2z2.square(&q.z)
+	u1.mul(&p.x, &z2z2)
+	u2.mul(&q.x, &z1z1)
+	s1.mul(&p.y, &q.z).mul(&s1, &z2z2)
+	s2.mul(&q.y, &p.z).mul(&s2, &z1z1)
+	return u1.equal(&u2) && s1.equal(&s2)
+}
+
+// neg sets p to -q.
+func (p *g1Point) neg(q *g1Point) *g1Point {
+	p.x = q.x
+	p.y.neg(&q.y)
+	p.z = q.z
+	return p
+}
+
+// double sets p to 2q.
+func (p *g1Point) double(q *g1Point) *g1Point {
+	if q.isInfinity() {
+		*p = *q
+		return p
+	}
+	var a, b, c, d, e, f, t fe
+	a.square(&q.x)
+	b.square(&q.y)
+	c.square(&b)
+	d.add(&q.x, &b).square(&d).sub(&d, &a).sub(&d, &c).double(&d)
+	e.double(&a).add(&e, &a)
+	f.square(&e)
+
+	var x3, y3, z3 fe
+	x3.sub(&f, t.double(&d))
+	y3.sub(&d, &x3).mul(&y3, &e)
+	t.double(&c).double(&t).double(&t)
+	y3.sub(&y3, &t)
+	z3.mul(&q.y, &q.z).double(&z3)
+	p.x, p.y, p.z = x3, y3, z3
+	return p
+}
+
+// add sets p to q+r.
+func (p *g1Point) add(q, r *g1Point) *g1Point {
+	if q.isInfinity() {
+		*p = *r
+		return p
+	}
+	if r.isInfinity() {
+		*p = *q
+		return p
+	}
+
+	var z1z1, z2z2, u1, u2, s1, s2, h, i, j, rr, v, t fe
+	z1z1.square(&q.z)
+	z2z2.square(&r.z)
+	u1.mul(&q.x, &z2z2)
+	u2.mul(&r.x, &z1z1)
+	s1.mul(&q.y, &r.z).mul(&s1, &z2z2)
+	s2.mul(&r.y, &q.z).mul(&s2, &z1z1)
+	h.sub(&u2, &u1)
+	rr.sub(&s2, &s1)
+	if h.isZero() {
+		if rr.isZero() {
+			return p.double(q)
+		}
+		*p = g1Point{}
+		return p
+	}
+	rr.double(&rr)
+	i.double(&h).square(&i)
+	j.mul(&h, &i)
+	v.mul(&u1, &i)
+
+	var x3, y3, z3 fe
+	x3.square(&rr).sub(&x3, &j).sub(&x3, t.double(&v))
+	y3.sub(&v, &x3).mul(&y3, &rr)
+	t.mul(&s1, &j).double(&t)
+	y3.sub(&y3, &t)
+	z3.add(&q.z, &r.z).square(&z3).sub(&z3, &z1z1).sub(&z3, &z2z2)
+	z3.mul(&z3, &h)
+	p.x, p.y, p.z = x3, y3, z3
+	return p
+}
+
+// mul sets p to k*q for a non-negative scalar k.
+func (p *g1Point) mul(q *g1Point, k *big.Int) *g1Point {
+	var r g1Point
+	base := *q
+	for i := k.BitLen() - 1; i >= 0; i-- {
+		r.double(&r)
+		if k.Bit(i) == 1 {
+			r.add(&r, &base)
+		}
+	}
+	*p = r
+	return p
+}
+
+// isInSubgroup returns whether p is in the subgroup of order r.
+func (p *g1Point) isInSubgroup() bool {
+	var t g1Point
+	return t.mul(p, rBig).isInfinity()
+}
+
+// g1FromBytes decodes a compressed point of G1 in the format of the ZCash
+// BLS12-381 specification, which Dash uses for BLS public keys since the
+// basic BLS scheme was activated.  The point must be in G1.
+func g1FromBytes(b []byte) (*g1Point, error) {
+	if len(b) != g1Size {
+		return nil, errors.New("invalid length of G1 point")
+	}
+	if b[0]&flagCompressed == 0 {
+		return nil, errors.New("G1 point is not compressed")
+	}
+
+	var buf [g1Size]byte
+	copy(buf[:], b)
+	buf[0] &^= flagCompressed | flagInfinity | flagSign
+	if b[0]&flagInfinity != 0 {
+		if b[0]&flagSign != 0 || buf != [g1Size]byte{} {
+			return nil, errors.New("non-canonical G1 point at infinity")
+		}
+		return &g1Point{}, nil
+	}
+
+	var x fe
+	if !x.setBytes(buf[:]) {
+		return nil, errors.New("G1 point x coordinate out of range")
+	}
+	y, ok := g1Y(&x)
+	if !ok {
+		return nil, errors.New("G1 point is not on the curve")
+	}
+	if y.isLexLargest() != (b[0]&flagSign != 0) {
+		y.neg(&y)
+	}
+	pt := &g1Point{x: x, y: y, z: feOne}
+	if !pt.isInSubgroup() {
+		return nil, errors.New("G1 point is not in the subgroup")
+	}
+	return pt, nil
+}
+
+// g1Y returns a y coordinate of the point with the passed x coordinate, and
+// whether there is such a point.
+func g1Y(x *fe) (fe, bool) {
+	var y, rhs fe
+	rhs.square(x).mul(&rhs, x).add(&rhs, &g1B)
+	ok := y.sqrt(&rhs)
+	return y, ok
+}
+
+// bytes returns the compressed encoding of p in the format of the ZCash
+// BLS12-381 specification.
+func (p *g1Point) bytes() []byte {
+	if p.isInfinity() {
+		b := make([]byte, g1Size)
+		b[0] = flagCompressed | flagInfinity
+		return b
+	}
+	x, y := p.affine()
+	b := x.bytes()
+	b[0] |= flagCompressed
+	if y.isLexLargest() {
+		b[0] |= flagSign
+	}
+	return b
+}
@@ -0,0 +1,259 @@
+// Copyright (c) 2013-2016 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package bls
+
+import (
+	"errors"
+	"math/big"
+)
+
+// g2Point is a point of the twist E': y^2 = x^3 + 4(1+u) over Fp2 in Jacobian
+// coordinates, where (x, y, z) represents the affine point (x/z^2, y/z^3).
+// The point at infinity has z = 0.
+type g2Point struct {
+	x, y, z fe2
+}
+
+var (
+	// g2B is the constant b of the curve equation.
+	g2B = func() fe2 {
+		var b fe2
+		b.c0.setBig(big.NewInt(4))
+		b.c1.setBig(big.NewInt(4))
+		return b
+	}()
+
+	// g2Generator is the standard generator of G2.
+	g2Generator = func() g2Point {
+		var g g2Point
+		g.x = fe2FromHex("024aa2b2f08f0a91260805272dc51051c6e47ad4fa403b02"+
+			"b4510b647ae3d1770bac0326a805bbefd48056c8c121bdb8",
+			"13e02b6052719f607dacd3a088274f65596bd0d09920b61a"+
+				"b5da61bbdc7f5049334cf11213945d57e5ac7d055d042b7e")
+		g.y = fe2FromHex("0ce5d527727d6e118cc9cdc6da2e351aadfd9baa8cbdd3a7"+
+			"6d429a695160d12c923ac9cc3baca289e193548608b82801",
+			"0606c4a02ea734cc32acd2b02bc28b99cb3e287e85a763af"+
+				"267492ab572e99ab3f370d275cec1da1aaa9075ff05f79be")
+		g.z = fe2One
+		return g
+	}()
+
+	// psiX and psiY are the factors 1/(1+u)^((p-1)/3) and
+	// 1/(1+u)^((p-1)/2) of the endomorphism psi.
+	psiX, psiY = func() (fe2, fe2) {
+		xi := fe2{c0: feOne, c1: feOne}
+		var inv, x, y fe2
+		inv.inverse(&xi)
+		e := new(big.Int).Sub(pBig, big.NewInt(1))
+		x.exp(&inv, new(big.Int).Div(e, big.NewInt(3)))
+		y.exp(&inv, new(big.Int).Div(e, big.NewInt(2)))
+		return x, y
+	}()
+)
+
+// isInfinity returns whether p is the point at infinity.
+func (p *g2Point) isInfinity() bool {
+	return p.z.isZero()
+}
+
+// affine returns the affine coordinates of p, which must not be the point at
+// infinity.
+func (p *g2Point) affine() (fe2, fe2) {
+	var zInv, zInv2, x, y fe2
+	zInv.inverse(&p.z)
+	zInv2.square(&zInv)
+	x.mul(&p.x, &zInv2)
+	y.mul(&p.y, &zInv2).mul(&y, &zInv)
+	return x, y
+}
+
+// isOnCurve returns whether p is on the curve.
+func (p *g2Point) isOnCurve() bool {
+	if p.isInfinity() {
+		return true
+	}
+	x, y := p.affine()
+	var lhs, rhs fe2
+	lhs.square(&y)
+	rhs.square(&x).mul(&rhs, &x).add(&rhs, &g2B)
+	return lhs.equal(&rhs)
+}
+
+// equal returns whether p and q are the same point.
+func (p *g2Point) equal(q *g2Point) bool {
+	if p.isInfinity() || q.isInfinity() {
+		return p.isInfinity() && q.isInfinity()
+	}
+	var z1z1, z2z2, u1, u2, s1, s2 fe2
+	z1z1.square(&p.z)
+	z2z2.square(&q.z)
+	u1.mul(&p.x, &z2z2)
+	u2.mul(&q.x, &z1z1)
+	s1.mul(&p.y, &q.z).mul(&s1, &z2z2)
+	s2.mul(&q.y, &p.z).mul(&s2, &z1z1)
+	return u1.equal(&u2) && s1.equal(&s2)
+}
+
+// neg sets p to -q.
+func (p *g2Point) neg(q *g2Point) *g2Point {
+	p.x = q.x
+	p.y.neg(&q.y)
+	p.z = q.z
+	return p
+}
+
+// double sets p to 2q.
+func (p *g2Point) double(q *g2Point) *g2Point {
+	if q.isInfinity() {
+		*p = *q
+		return p
+	}
+	var a, b, c, d, e, f, t fe2
+	a.square(&q.x)
+	b.square(&q.y)
+	c.square(&b)
+	d.add(&q.x, &b).square(&d).sub(&d, &a).sub(&d, &c).double(&d)
+	e.double(&a).add(&e, &a)
+	f.square(&e)
+
+	var x3, y3, z3 fe2
+	x3.sub(&f, t.double(&d))
+	y3.sub(&d, &x3).mul(&y3, &e)
+	t.double(&c).double(&t).double(&t)
+	y3.sub(&y3, &t)
+	z3.mul(&q.y, &q.z).double(&z3)
+	p.x, p.y, p.z = x3, y3, z3
+	return p
+}
+
+// add sets p to q+r.
+func (p *g2Point) add(q, r *g2Point) *g2Point {
+	if q.isInfinity() {
+		*p = *r
+		return p
+	}
+	if r.isInfinity() {
+		*p = *q
+		return p
+	}
+
+	var z1z1, z2z2, u1, u2, s1, s2, h, i, j, rr, v, t fe2
+	z1z1.square(&q.z)
+	z2z2.square(&r.z)
+	u1.mul(&q.x, &z2z2)
+	u2.mul(&r.x, &z1z1)
+	s1.mul(&q.y, &r.z).mul(&s1, &z2z2)
+	s2.mul(&r.y, &q.z).mul(&s2, &z1z1)
+	h.sub(&u2, &u1)
+	rr.sub(&s2, &s1)
+	if h.isZero() {
+		if rr.isZero() {
+			return p.double(q)
+		}
+		*p = g2Point{}
+		return p
+	}
+	rr.double(&rr)
+	i.double(&h).square(&i)
+	j.mul(&h, &i)
+	v.mul(&u1, &i)
+
+	var x3, y3, z3 fe2
+	x3.square(&rr).sub(&x3, &j).sub(&x3, t.double(&v))
+	y3.sub(&v, &x3).mul(&y3, &rr)
+	t.mul(&s1, &j).double(&t)
+	y3.sub(&y3, &t)
+	z3.add(&q.z, &r.z).square(&z3).sub(&z3, &z1z1).sub(&z3, &z2z2)
+	z3.mul(&z3, &h)
+	p.x, p.y, p.z = x3, y3, z3
+	return p
+}
+
+// mul sets p to k*q for a non-negative scalar k.
+func (p *g2Point) mul(q *g2Point, k *big.Int) *g2Point {
+	var r g2Point
+	base := *q
+	for i := k.BitLen() - 1; i >= 0; i-- {
+		r.double(&r)
+		if k.Bit(i) == 1 {
+			r.add(&r, &base)
+		}
+	}
+	*p = r
+	return p
+}
+
+// isInSubgroup returns whether p is in the subgroup of order r.
+func (p *g2Point) isInSubgroup() bool {
+	var t g2Point
+	return t.mul(p, rBig).isInfinity()
+}
+
+// psi sets p to the image of q under the endomorphism psi, which untwists q,
+// applies the Frobenius endomorphism and twists the result back.
+func (p *g2Point) psi(q *g2Point) *g2Point {
+	p.x.conjugate(&q.x).mul(&p.x, &psiX)
+	p.y.conjugate(&q.y).mul(&p.y, &psiY)
+	p.z.conjugate(&q.z)
+	return p
+}
+
+// g2FromBytes decodes a compressed point of G2 in the format of the ZCash
+// BLS12-381 specification, which Dash uses for BLS signatures since the basic
+// BLS scheme was activated.  The point must be in G2.
+func g2FromBytes(b []byte) (*g2Point, error) {
+	if len(b) != g2Size {
+		return nil, errors.New("invalid length of G2 point")
+	}
+	if b[0]&flagCompressed == 0 {
+		return nil, errors.New("G2 point is not compressed")
+	}
+
+	var buf [g2Size]byte
+	copy(buf[:], b)
+	buf[0] &^= flagCompressed | flagInfinity | flagSign
+	if b[0]&flagInfinity != 0 {
+		if b[0]&flagSign != 0 || buf != [g2Size]byte{} {
+			return nil, errors.New("non-canonical G2 point at infinity")
+		}
+		return &g2Point{}, nil
+	}
+
+	// The x coordinate is encoded as c1 followed by c0.
+	var x fe2
+	if !x.c1.setBytes(buf[:g1Size]) || !x.c0.setBytes(buf[g1Size:]) {
+		return nil, errors.New("G2 point x coordinate out of range")
+	}
+	var y, rhs fe2
+	rhs.square(&x).mul(&rhs, &x).add(&rhs, &g2B)
+	if !y.sqrt(&rhs) {
+		return nil, errors.New("G2 point is not on the curve")
+	}
+	if y.isLexLargest() != (b[0]&flagSign != 0) {
+		y.neg(&y)
+	}
+	pt := &g2Point{x: x, y: y, z: fe2One}
+	if !pt.isInSubgroup() {
+		return nil, errors.New("G2 point is not in the subgroup")
+	}
+	return pt, nil
+}
+
+// bytes returns the compressed encoding of p in the format of the ZCash
+// BLS12-381 specification.
+func (p *g2Point) bytes() []byte {
+	if p.isInfinity() {
+		b := make([]byte, g2Size)
+		b[0] = flagCompressed | flagInfinity
+		return b
+	}
+	x, y := p.affine()
+	b := append(x.c1.bytes(), x.c0.bytes()...)
+	b[0] |= flagCompressed
+	if y.isLexLargest() {
+		b[0] |= flagSign
+	}
+	return b
+}
@@ -0,0 +1,214 @@
+// Copyright (c) 2013-2016 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package bls
+
+import (
+	"crypto/sha256"
+	"math/big"
+)
+
+// This file implements the BLS12381G2_XMD:SHA-256_SSWU_RO_ suite of the hash
+// to curve specification (RFC 9380), which hashes messages to G2.
+
+var (
+	// sswuA, sswuB and sswuZ are the constants of the simplified SWU map
+	// to the curve E2': y^2 = x^3 + 240u*x + 1012(1+u), which is 3-isogenous
+	// to the twist.
+	sswuA = fe2FromHex("0", "f0")
+	sswuB = fe2FromHex("3f4", "3f4")
+	sswuZ = func() fe2 {
+		z := fe2FromHex("2", "1")
+		z.neg(&z)
+		return z
+	}()
+
+	// isoXNum, isoXDen, isoYNum and isoYDen are the coefficients of the
+	// polynomials of the 3-isogeny from E2' to the twist, lowest degree
+	// first.  The denominators are monic.
+	isoXNum = [4]fe2{
+		fe2FromHex("5c759507e8e333ebb5b7a9a47d7ed8532c52d39fd3a042a88b58423c50ae15d5c2638e343d9c71c6238aaaaaaaa97d6",
+			"5c759507e8e333ebb5b7a9a47d7ed8532c52d39fd3a042a88b58423c50ae15d5c2638e343d9c71c6238aaaaaaaa97d6"),
+		fe2FromHex("0",
+			"11560bf17baa99bc32126fced787c88f984f87adf7ae0c7f9a208c6b4f20a4181472aaa9cb8d555526a9ffffffffc71a"),
+		fe2FromHex("11560bf17baa99bc32126fced787c88f984f87adf7ae0c7f9a208c6b4f20a4181472aaa9cb8d555526a9ffffffffc71e",
+			"8ab05f8bdd54cde190937e76bc3e447cc27c3d6fbd7063fcd104635a790520c0a395554e5c6aaaa9354ffffffffe38d"),
+		fe2FromHex("171d6541fa38ccfaed6dea691f5fb614cb14b4e7f4e810aa22d6108f142b85757098e38d0f671c7188e2aaaaaaaa5ed1",
+			"0"),
+	}
+	isoXDen = [3]fe2{
+		fe2FromHex("0",
+			"1a0111ea397fe69a4b1ba7b6434bacd764774b84f38512bf6730d2a0f6b0f6241eabfffeb153ffffb9feffffffffaa63"),
+		fe2FromHex("c",
+			"1a0111ea397fe69a4b1ba7b6434bacd764774b84f38512bf6730d2a0f6b0f6241eabfffeb153ffffb9feffffffffaa9f"),
+		fe2FromHex("1", "0"),
+	}
+	isoYNum = [4]fe2{
+		fe2FromHex("1530477c7ab4113b59a4c18b076d11930f7da5d4a07f649bf54439d87d27e500fc8c25ebf8c92f6812cfc71c71c6d706",
+			"1530477c7ab4113b59a4c18b076d11930f7da5d4a07f649bf54439d87d27e500fc8c25ebf8c92f6812cfc71c71c6d706"),
+		fe2FromHex("0",
+			"5c759507e8e333ebb5b7a9a47d7ed8532c52d39fd3a042a88b58423c50ae15d5c2638e343d9c71c6238aaaaaaaa97be"),
+		fe2FromHex("11560bf17baa99bc32126fced787c88f984f87adf7ae0c7f9a208c6b4f20a4181472aaa9cb8d555526a9ffffffffc71c",
+			"8ab05f8bdd54cde190937e76bc3e447cc27c3d6fbd7063fcd104635a790520c0a395554e5c6aaaa9354ffffffffe38f"),
+		fe2FromHex("124c9ad43b6cf79bfbf7043de3811ad0761b0f37a1e26286b0e977c69aa274524e79097a56dc4bd9e1b371c71c718b10",
+			"0"),
+	}
+	isoYDen = [4]fe2{
+		fe2FromHex("1a0111ea397fe69a4b1ba7b6434bacd764774b84f38512bf6730d2a0f6b0f6241eabfffeb153ffffb9feffffffffa8fb",
+			"1a0111ea397fe69a4b1ba7b6434bacd764774b84f38512bf6730d2a0f6b0f6241eabfffeb153ffffb9feffffffffa8fb"),
+		fe2FromHex("0",
+			"1a0111ea397fe69a4b1ba7b6434bacd764774b84f38512bf6730d2a0f6b0f6241eabfffeb153ffffb9feffffffffa9d3"),
+		fe2FromHex("12",
+			"1a0111ea397fe69a4b1ba7b6434bacd764774b84f38512bf6730d2a0f6b0f6241eabfffeb153ffffb9feffffffffaa99"),
+		fe2FromHex("1", "0"),
+	}
+)
+
+// expandMessageXMD expands the passed message to the passed number of
+// uniformly random bytes with SHA-256 using the passed domain separation tag.
+// The length must not exceed 255*32 bytes and the tag 255 bytes.
+func expandMessageXMD(msg, dst []byte, length int) []byte {
+	const bInBytes, rInBytes = sha256.Size, sha256.BlockSize
+	ell := (length + bInBytes - 1) / bInBytes
+	dstPrime := append(append([]byte{}, dst...), byte(len(dst)))
+
+	h := sha256.New()
+	h.Write(make([]byte, rInBytes))
+	h.Write(msg)
+	h.Write([]byte{byte(length >> 8), byte(length), 0})
+	h.Write(dstPrime)
+	b0 := h.Sum(nil)
+
+	h.Reset()
+	h.Write(b0)
+	h.Write([]byte{1})
+	h.Write(dstPrime)
+	bi := h.Sum(nil)
+
+	uniform := make([]byte, 0, ell*bInBytes)
+	uniform = append(uniform, bi...)
+	for i := 2; i <= ell; i++ {
+		var x [bInBytes]byte
+		for j := range x {
+			x[j] = b0[j] ^ bi[j]
+		}
+		h.Reset()
+		h.Write(x[:])
+		h.Write([]byte{byte(i)})
+		h.Write(dstPrime)
+		bi = h.Sum(nil)
+		uniform = append(uniform, bi...)
+	}
+	return uniform[:length]
+}
+
+// hashToFieldFp2 hashes the passed message to the passed number of elements
+// of Fp2.
+func hashToFieldFp2(msg, dst []byte, count int) []fe2 {
+	// Each coefficient is reduced from 64 bytes, which makes its bias
+	// negligible.
+	const l = 64
+	uniform := expandMessageXMD(msg, dst, count*2*l)
+	elems := make([]fe2, count)
+	for i := range elems {
+		off := 2 * l * i
+		elems[i].c0.setBig(new(big.Int).SetBytes(uniform[off : off+l]))
+		elems[i].c1.setBig(new(big.Int).SetBytes(uniform[off+l : off+2*l]))
+	}
+	return elems
+}
+
+// mapToCurveSSWU maps the passed field element to a point of E2' with the
+// simplified Shallue-van de Woestijne-Ulas method.
+func mapToCurveSSWU(u *fe2) (fe2, fe2) {
+	var zu2, tv1, t, x1, gx1, x2, gx2 fe2
+	zu2.square(u).mul(&zu2, &sswuZ)
+	tv1.square(&zu2).add(&tv1, &zu2)
+	if tv1.isZero() {
+		// x1 = B / (Z * A)
+		t.mul(&sswuZ, &sswuA).inverse(&t)
+		x1.mul(&sswuB, &t)
+	} else {
+		// x1 = (-B / A) * (1 + 1/(Z^2*u^4 + Z*u^2))
+		tv1.inverse(&tv1).add(&tv1, &fe2One)
+		t.inverse(&sswuA).mul(&t, &sswuB).neg(&t)
+		x1.mul(&t, &tv1)
+	}
+	curveRHS := func(z, x *fe2) {
+		var ax fe2
+		ax.mul(&sswuA, x)
+		z.square(x).mul(z, x).add(z, &ax).add(z, &sswuB)
+	}
+	curveRHS(&gx1, &x1)
+	x2.mul(&zu2, &x1)
+	curveRHS(&gx2, &x2)
+
+	var x, y fe2
+	if gx1.isSquare() {
+		x = x1
+		y.sqrt(&gx1)
+	} else {
+		x = x2
+		y.sqrt(&gx2)
+	}
+	if u.sgn0() != y.sgn0() {
+		y.neg(&y)
+	}
+	return x, y
+}
+
+// evalPoly returns the value of the polynomial with the passed coefficients,
+// lowest degree first, at x.
+func evalPoly(coeffs []fe2, x *fe2) fe2 {
+	r := coeffs[len(coeffs)-1]
+	for i := len(coeffs) - 2; i >= 0; i-- {
+		r.mul(&r, x).add(&r, &coeffs[i])
+	}
+	return r
+}
+
+// isoMap maps the passed point of E2' to the twist with the 3-isogeny.
+func isoMap(x, y *fe2) g2Point {
+	xNum := evalPoly(isoXNum[:], x)
+	xDen := evalPoly(isoXDen[:], x)
+	yNum := evalPoly(isoYNum[:], x)
+	yDen := evalPoly(isoYDen[:], x)
+	if xDen.isZero() || yDen.isZero() {
+		return g2Point{}
+	}
+
+	var xr, yr fe2
+	xr.inverse(&xDen).mul(&xr, &xNum)
+	yr.inverse(&yDen).mul(&yr, &yNum).mul(&yr, y)
+	return g2Point{x: xr, y: yr, z: fe2One}
+}
+
+// clearCofactor multiplies the passed point of the twist by the effective
+// cofactor of G2 with the method of Budroni and Pintore, which maps it to G2.
+func clearCofactor(p *g2Point) g2Point {
+	var t1, t2, t3, q g2Point
+	t1.mul(p, blsXAbs).neg(&t1)
+	t2.psi(p)
+	t3.double(p).psi(&t3).psi(&t3)
+	t3.add(&t3, q.neg(&t2))
+	t2.add(&t1, &t2)
+	t2.mul(&t2, blsXAbs).neg(&t2)
+	t3.add(&t3, &t2)
+	t3.add(&t3, q.neg(&t1))
+	q.neg(p)
+	return *q.add(&t3, &q)
+}
+
+// hashToG2 hashes the passed message to G2 using the passed domain separation
+// tag.
+func hashToG2(msg, dst []byte) g2Point {
+	u := hashToFieldFp2(msg, dst, 2)
+	x0, y0 := mapToCurveSSWU(&u[0])
+	x1, y1 := mapToCurveSSWU(&u[1])
+	q0 := isoMap(&x0, &y0)
+	q1 := isoMap(&x1, &y1)
+	var r g2Point
+	r.add(&q0, &q1)
+	return clearCofactor(&r)
+}
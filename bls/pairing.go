@@ -0,0 +1,118 @@
+// Copyright (c) 2013-2016 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package bls
+
+// lineValue returns the value at the point (xp, yp) of G1 of the line with
+// slope lambda through the point (xt, yt) of the twist, both untwisted to
+// E(Fp12), multiplied by 1+u.
+//
+// Untwisting maps (x, y) to (x/w^2, y/w^3), so the line evaluates to
+// yp - lambda*xp/w + (lambda*xt - yt)/w^3.  Multiplying by 1+u = w^6, which
+// is in a proper subfield and hence does not change the reduced pairing,
+// gives yp*(1+u) - lambda*xp*w^5 + (lambda*xt - yt)*w^3, where w^3 = w*v and
+// w^5 = w*v^2.
+func lineValue(lambda, xt, yt *fe2, xp, yp *fe) fe12 {
+	var l fe12
+	l.c0.c0.c0 = *yp
+	l.c0.c0.mulByNonResidue(&l.c0.c0)
+	l.c1.c1.mul(lambda, xt).sub(&l.c1.c1, yt)
+	l.c1.c2.mulFe(lambda, xp).neg(&l.c1.c2)
+	return l
+}
+
+// millerLoop returns the product of the values of the Miller loop of the
+// optimal ate pairing of the passed pairs of points, none of which may be the
+// point at infinity.  The sign of the curve parameter x is ignored, which
+// inverts the reduced pairing of every pair alike.
+func millerLoop(ps []*g1Point, qs []*g2Point) fe12 {
+	type pair struct {
+		xp, yp         fe
+		xq, yq, xt, yt fe2
+	}
+	pairs := make([]pair, len(ps))
+	for i := range ps {
+		pr := &pairs[i]
+		pr.xp, pr.yp = ps[i].affine()
+		pr.xq, pr.yq = qs[i].affine()
+		pr.xt, pr.yt = pr.xq, pr.yq
+	}
+
+	f := fe12One
+	var lambda, t, x3, y3 fe2
+	for i := blsXAbs.BitLen() - 2; i >= 0; i-- {
+		f.square(&f)
+		for j := range pairs {
+			pr := &pairs[j]
+
+			// Double T: lambda = 3*xt^2 / (2*yt).
+			t.double(&pr.yt).inverse(&t)
+			lambda.square(&pr.xt)
+			x3.double(&lambda)
+			lambda.add(&lambda, &x3).mul(&lambda, &t)
+			line := lineValue(&lambda, &pr.xt, &pr.yt, &pr.xp, &pr.yp)
+			f.mul(&f, &line)
+			x3.square(&lambda).sub(&x3, &pr.xt).sub(&x3, &pr.xt)
+			y3.sub(&pr.xt, &x3).mul(&y3, &lambda).sub(&y3, &pr.yt)
+			pr.xt, pr.yt = x3, y3
+
+			if blsXAbs.Bit(i) == 0 {
+				continue
+			}
+
+			// Add Q: lambda = (yq - yt) / (xq - xt).
+			t.sub(&pr.xq, &pr.xt).inverse(&t)
+			lambda.sub(&pr.yq, &pr.yt).mul(&lambda, &t)
+			line = lineValue(&lambda, &pr.xt, &pr.yt, &pr.xp, &pr.yp)
+			f.mul(&f, &line)
+			x3.square(&lambda).sub(&x3, &pr.xt).sub(&x3, &pr.xq)
+			y3.sub(&pr.xt, &x3).mul(&y3, &lambda).sub(&y3, &pr.yt)
+			pr.xt, pr.yt = x3, y3
+		}
+	}
+	return f
+}
+
+// finalExponentiation returns f^(3*(p^12 - 1)/r), which maps the values of
+// the Miller loop to the group of r-th roots of unity.
+func finalExponentiation(f *fe12) fe12 {
+	// The easy part f^((p^6 - 1)(p^2 + 1)).
+	var r, t fe12
+	t.inverse(f)
+	r.conjugate(f).mul(&r, &t)
+	t.frobenius(&r).frobenius(&t)
+	r.mul(&r, &t)
+
+	// The hard part is raised to the power 3*(p^4 - p^2 + 1)/r instead of
+	// (p^4 - p^2 + 1)/r, which does not change whether the result is one
+	// since 3 does not divide r.  In terms of the curve parameter x, the
+	// exponent is (x-1)^2 * (x+p) * (x^2+p^2-1) + 3.
+	var a, b fe12
+	a.expByX(&r).mul(&a, t.conjugate(&r))
+	b.expByX(&a).mul(&b, t.conjugate(&a))
+	a.expByX(&b).mul(&a, t.frobenius(&b))
+	b.expByX(&a).expByX(&b)
+	t.frobenius(&a).frobenius(&t)
+	b.mul(&b, &t).mul(&b, t.conjugate(&a))
+	t.square(&r).mul(&t, &r)
+	return *b.mul(&b, &t)
+}
+
+// pairingProductIsOne returns whether the product of the reduced pairings of
+// the passed pairs of points is one.  Pairs with a point at infinity are
+// skipped since their pairing is one.
+func pairingProductIsOne(ps []*g1Point, qs []*g2Point) bool {
+	var p1 []*g1Point
+	var q2 []*g2Point
+	for i := range ps {
+		if ps[i].isInfinity() || qs[i].isInfinity() {
+			continue
+		}
+		p1 = append(p1, ps[i])
+		q2 = append(q2, qs[i])
+	}
+	f := millerLoop(p1, q2)
+	r := finalExponentiation(&f)
+	return r.isOne()
+}
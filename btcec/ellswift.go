@@ -0,0 +1,240 @@
+// Copyright (c) 2013-2016 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcec
+
+import (
+	"errors"
+	"io"
+	"math/big"
+)
+
+// EllSwiftPubKeyLen is the length of a public key in the ElligatorSwift
+// encoding.
+const EllSwiftPubKeyLen = 64
+
+// These constants are used by the ElligatorSwift encoding as defined by
+// BIP0324.
+var (
+	// ellSwiftC is the square root of -3 mod P.
+	ellSwiftC = new(fieldVal).SetHex("0a2d2ba93507f1df233770c2a797962c" +
+		"c61f6d15da14ecd47d8d27ae1cd5f852")
+
+	// ellSwiftC1 is (1 - sqrt(-3))/2 mod P.
+	ellSwiftC1 = new(fieldVal).SetHex("7ae96a2b657c07106e64479eac3434e9" +
+		"9cf0497512f58995c1396c28719501ef")
+
+	// ellSwiftC2 is (1 + sqrt(-3))/2 mod P.
+	ellSwiftC2 = new(fieldVal).SetHex("851695d49a83f8ef919bb86153cbcb16" +
+		"630fb68aed0a766a3ec693d68e6afa41")
+
+	// fieldSeven is the constant B of the curve.
+	fieldSeven = new(fieldVal).SetInt(7)
+)
+
+// errEllSwiftNotEncodable is returned when a public key can not be encoded
+// with ElligatorSwift because the source of randomness failed to provide an
+// encoding.
+var errEllSwiftNotEncodable = errors.New("unable to encode public key with " +
+	"ElligatorSwift")
+
+// The following helpers operate on normalized field values and return new
+// normalized field values, which keeps the ElligatorSwift formulas free of
+// magnitude bookkeeping.
+
+func feMul(a, b *fieldVal) *fieldVal {
+	return new(fieldVal).Mul2(a, b).Normalize()
+}
+
+func feSquare(a *fieldVal) *fieldVal {
+	return new(fieldVal).SquareVal(a).Normalize()
+}
+
+func feAdd(a, b *fieldVal) *fieldVal {
+	return new(fieldVal).Set(a).Add(b).Normalize()
+}
+
+func feNeg(a *fieldVal) *fieldVal {
+	return new(fieldVal).NegateVal(a, 1).Normalize()
+}
+
+func feSub(a, b *fieldVal) *fieldVal {
+	return feAdd(a, feNeg(b))
+}
+
+func feDiv(a, b *fieldVal) *fieldVal {
+	return feMul(a, new(fieldVal).Set(b).Inverse().Normalize())
+}
+
+func feHalf(a *fieldVal) *fieldVal {
+	return feDiv(a, new(fieldVal).SetInt(2))
+}
+
+// feSqrt returns the square root of a and whether it exists.
+func feSqrt(a *fieldVal) (*fieldVal, bool) {
+	r := new(fieldVal).SqrtVal(a).Normalize()
+	return r, feSquare(r).Equals(a)
+}
+
+// isValidX returns whether x is the X coordinate of a point on the curve.
+func isValidX(x *fieldVal) bool {
+	_, ok := feSqrt(feAdd(feMul(feSquare(x), x), fieldSeven))
+	return ok
+}
+
+// xSwiftEC decodes the field elements u and t of an ElligatorSwift encoding to
+// the X coordinate of a point on the curve.  Every pair of field elements
+// decodes to a valid X coordinate.
+func xSwiftEC(u, t *fieldVal) *fieldVal {
+	one := new(fieldVal).SetInt(1)
+	if u.IsZero() {
+		u = one
+	}
+	if t.IsZero() {
+		t = one
+	}
+
+	// u^3 + 7
+	g := feAdd(feMul(feSquare(u), u), fieldSeven)
+	if feAdd(g, feSquare(t)).IsZero() {
+		t = feAdd(t, t)
+	}
+
+	// X = (u^3 + 7 - t^2) / (2t)
+	// Y = (X + t) / (sqrt(-3) * u)
+	x := feDiv(feSub(g, feSquare(t)), feAdd(t, t))
+	y := feDiv(feAdd(x, t), feMul(ellSwiftC, u))
+
+	// Return the first of u + 4Y^2, (-X/Y - u)/2 and (X/Y - u)/2 which is
+	// a valid X coordinate.
+	y2 := feSquare(y)
+	candidate := feAdd(u, feAdd(feAdd(y2, y2), feAdd(y2, y2)))
+	if isValidX(candidate) {
+		return candidate
+	}
+	xy := feDiv(x, y)
+	candidate = feHalf(feSub(feNeg(xy), u))
+	if isValidX(candidate) {
+		return candidate
+	}
+	return feHalf(feSub(xy, u))
+}
+
+// xSwiftECInv returns t such that xSwiftEC(u, t) is x, or nil when there is
+// none for the passed case.  The 8 cases, of which the lower 3 bits of c are
+// used, select among the up to 8 solutions.
+func xSwiftECInv(x, u *fieldVal, c byte) *fieldVal {
+	// u^3 + 7
+	g := feAdd(feMul(feSquare(u), u), fieldSeven)
+
+	var s, v *fieldVal
+	if c&2 == 0 {
+		// The encoding would not decode to x when -x - u is a valid X
+		// coordinate since it is tried first by the decoder.
+		if isValidX(feSub(feNeg(x), u)) {
+			return nil
+		}
+
+		// s = -(u^3 + 7) / (u^2 + u*v + v^2)
+		v = x
+		s = feNeg(feDiv(g, feAdd(feAdd(feSquare(u), feMul(u, v)),
+			feSquare(v))))
+	} else {
+		s = feSub(x, u)
+		if s.IsZero() {
+			return nil
+		}
+
+		// r = sqrt(-s * (4(u^3 + 7) + 3u^2 s))
+		g4 := feAdd(feAdd(g, g), feAdd(g, g))
+		u2s := feMul(feSquare(u), s)
+		r, ok := feSqrt(feNeg(feMul(s, feAdd(g4,
+			feAdd(feAdd(u2s, u2s), u2s)))))
+		if !ok {
+			return nil
+		}
+		if c&1 == 1 && r.IsZero() {
+			return nil
+		}
+
+		// v = (r/s - u)/2
+		v = feHalf(feSub(feDiv(r, s), u))
+	}
+
+	w, ok := feSqrt(s)
+	if !ok {
+		return nil
+	}
+
+	switch c & 5 {
+	case 0:
+		return feNeg(feMul(w, feAdd(feMul(u, ellSwiftC1), v)))
+	case 1:
+		return feMul(w, feAdd(feMul(u, ellSwiftC2), v))
+	case 4:
+		return feMul(w, feAdd(feMul(u, ellSwiftC1), v))
+	default:
+		return feNeg(feMul(w, feAdd(feMul(u, ellSwiftC2), v)))
+	}
+}
+
+// EllSwiftEncode returns the ElligatorSwift encoding of the passed public key
+// as defined by BIP0324, which is indistinguishable from 64 uniformly random
+// bytes.  The encoding is randomized with data read from rnd.  Only the X
+// coordinate of the public key is encoded, so it decodes to the public key
+// with the same X coordinate and an even Y coordinate.
+func EllSwiftEncode(pubKey *PublicKey, rnd io.Reader) ([EllSwiftPubKeyLen]byte, error) {
+	var enc [EllSwiftPubKeyLen]byte
+	x := new(fieldVal).SetByteSlice(pubKey.X.Bytes()).Normalize()
+
+	// Pick random values of u and random cases until one of them has a
+	// solution, which happens for about a quarter of the attempts.
+	var buf [33]byte
+	for i := 0; i < 1000; i++ {
+		if _, err := io.ReadFull(rnd, buf[:]); err != nil {
+			return enc, err
+		}
+		u := new(fieldVal).SetByteSlice(buf[:32]).Normalize()
+		if u.IsZero() {
+			continue
+		}
+		t := xSwiftECInv(x, u, buf[32])
+		if t == nil {
+			continue
+		}
+
+		copy(enc[:32], u.Bytes()[:])
+		copy(enc[32:], t.Bytes()[:])
+		return enc, nil
+	}
+
+	return enc, errEllSwiftNotEncodable
+}
+
+// EllSwiftDecode returns the public key encoded by the passed ElligatorSwift
+// encoding, which has an even Y coordinate.  Every 64 byte string is a valid
+// encoding.
+func EllSwiftDecode(enc *[EllSwiftPubKeyLen]byte) *PublicKey {
+	u := new(fieldVal).SetByteSlice(enc[:32]).Normalize()
+	t := new(fieldVal).SetByteSlice(enc[32:]).Normalize()
+	x := new(big.Int).SetBytes(xSwiftEC(u, t).Bytes()[:])
+
+	// The decoded X coordinate is always valid, so lifting it can't fail.
+	curve := S256()
+	y, _ := decompressPoint(curve, x, false)
+	return &PublicKey{Curve: curve, X: x, Y: y}
+}
+
+// EllSwiftSharedX returns the X coordinate of the ECDH shared point of the
+// passed private key and the public key in the passed ElligatorSwift encoding
+// as 32 big endian bytes.
+func EllSwiftSharedX(privKey *PrivateKey, theirs *[EllSwiftPubKeyLen]byte) [32]byte {
+	pubKey := EllSwiftDecode(theirs)
+	x, _ := pubKey.Curve.ScalarMult(pubKey.X, pubKey.Y, privKey.D.Bytes())
+
+	var shared [32]byte
+	xBytes := x.Bytes()
+	copy(shared[32-len(xBytes):], xBytes)
+	return shared
+}
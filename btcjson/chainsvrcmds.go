@@ -46,6 +46,15 @@ func NewAddNodeCmd(addr string, subCmd AddNodeSubCmd) *AddNodeCmd {
 	}
 }
 
+// ClearBannedCmd defines the clearbanned JSON-RPC command.
+type ClearBannedCmd struct{}
+
+// NewClearBannedCmd returns a new instance which can be used to issue a
+// clearbanned JSON-RPC command.
+func NewClearBannedCmd() *ClearBannedCmd {
+	return &ClearBannedCmd{}
+}
+
 // TransactionInput represents the inputs to a transaction.  Specifically a
 // transaction hash and output number pair.
 type TransactionInput struct {
@@ -100,6 +109,38 @@ func NewDecodeScriptCmd(hexScript string) *DecodeScriptCmd {
 	}
 }
 
+// DisconnectNodeCmd defines the disconnectnode JSON-RPC command.  Exactly one
+// of the address and the node ID of the peer must be set.
+type DisconnectNodeCmd struct {
+	Address *string `jsonrpcdefault:"\"\""`
+	NodeID  *int32
+}
+
+// NewDisconnectNodeCmd returns a new instance which can be used to issue a
+// disconnectnode JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewDisconnectNodeCmd(address *string, nodeID *int32) *DisconnectNodeCmd {
+	return &DisconnectNodeCmd{
+		Address: address,
+		NodeID:  nodeID,
+	}
+}
+
+// DumpTxOutSetCmd defines the dumptxoutset JSON-RPC command.
+type DumpTxOutSetCmd struct {
+	Path string
+}
+
+// NewDumpTxOutSetCmd returns a new instance which can be used to issue a
+// dumptxoutset JSON-RPC command.
+func NewDumpTxOutSetCmd(path string) *DumpTxOutSetCmd {
+	return &DumpTxOutSetCmd{
+		Path: path,
+	}
+}
+
 // GetAddedNodeInfoCmd defines the getaddednodeinfo JSON-RPC command.
 type GetAddedNodeInfoCmd struct {
 	DNS  bool
@@ -118,6 +159,140 @@ func NewGetAddedNodeInfoCmd(dns bool, node *string) *GetAddedNodeInfoCmd {
 	}
 }
 
+// AddressIndexRequest defines the addresses, and for some commands the range
+// of blocks, to query in the JSON-RPC commands which are based on the address
+// index.  It is given either as an object or as a single address.
+type AddressIndexRequest struct {
+	Addresses []string `json:"addresses"`
+	Start     *int32   `json:"start,omitempty"`
+	End       *int32   `json:"end,omitempty"`
+	ChainInfo *bool    `json:"chainInfo,omitempty"`
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+func (r *AddressIndexRequest) UnmarshalJSON(data []byte) error {
+	var address string
+	if err := json.Unmarshal(data, &address); err == nil {
+		*r = AddressIndexRequest{Addresses: []string{address}}
+		return nil
+	}
+
+	// The request is decoded as a type without the custom unmarshaler to
+	// avoid infinite recursion.
+	type request AddressIndexRequest
+	var unmarshalled request
+	if err := json.Unmarshal(data, &unmarshalled); err != nil {
+		return err
+	}
+	*r = AddressIndexRequest(unmarshalled)
+	return nil
+}
+
+// GetAddressBalanceCmd defines the getaddressbalance JSON-RPC command.
+type GetAddressBalanceCmd struct {
+	Request AddressIndexRequest
+}
+
+// NewGetAddressBalanceCmd returns a new instance which can be used to issue a
+// getaddressbalance JSON-RPC command.
+func NewGetAddressBalanceCmd(addresses []string) *GetAddressBalanceCmd {
+	return &GetAddressBalanceCmd{
+		Request: AddressIndexRequest{Addresses: addresses},
+	}
+}
+
+// GetAddressDeltasCmd defines the getaddressdeltas JSON-RPC command.
+type GetAddressDeltasCmd struct {
+	Request AddressIndexRequest
+}
+
+// NewGetAddressDeltasCmd returns a new instance which can be used to issue a
+// getaddressdeltas JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewGetAddressDeltasCmd(addresses []string, start, end *int32, chainInfo *bool) *GetAddressDeltasCmd {
+	return &GetAddressDeltasCmd{
+		Request: AddressIndexRequest{
+			Addresses: addresses,
+			Start:     start,
+			End:       end,
+			ChainInfo: chainInfo,
+		},
+	}
+}
+
+// GetAddressMempoolCmd defines the getaddressmempool JSON-RPC command.
+type GetAddressMempoolCmd struct {
+	Request AddressIndexRequest
+}
+
+// NewGetAddressMempoolCmd returns a new instance which can be used to issue a
+// getaddressmempool JSON-RPC command.
+func NewGetAddressMempoolCmd(addresses []string) *GetAddressMempoolCmd {
+	return &GetAddressMempoolCmd{
+		Request: AddressIndexRequest{Addresses: addresses},
+	}
+}
+
+// GetAddressTxIDsCmd defines the getaddresstxids JSON-RPC command.
+type GetAddressTxIDsCmd struct {
+	Request AddressIndexRequest
+}
+
+// NewGetAddressTxIDsCmd returns a new instance which can be used to issue a
+// getaddresstxids JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewGetAddressTxIDsCmd(addresses []string, start, end *int32) *GetAddressTxIDsCmd {
+	return &GetAddressTxIDsCmd{
+		Request: AddressIndexRequest{
+			Addresses: addresses,
+			Start:     start,
+			End:       end,
+		},
+	}
+}
+
+// GetAddressUtxosCmd defines the getaddressutxos JSON-RPC command.
+type GetAddressUtxosCmd struct {
+	Request AddressIndexRequest
+}
+
+// NewGetAddressUtxosCmd returns a new instance which can be used to issue a
+// getaddressutxos JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewGetAddressUtxosCmd(addresses []string, chainInfo *bool) *GetAddressUtxosCmd {
+	return &GetAddressUtxosCmd{
+		Request: AddressIndexRequest{
+			Addresses: addresses,
+			ChainInfo: chainInfo,
+		},
+	}
+}
+
+// GetAssetUnlockStatusesCmd defines the getassetunlockstatuses JSON-RPC
+// command.
+type GetAssetUnlockStatusesCmd struct {
+	Indexes []uint64
+	Height  *int32
+}
+
+// NewGetAssetUnlockStatusesCmd returns a new instance which can be used to
+// issue a getassetunlockstatuses JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewGetAssetUnlockStatusesCmd(indexes []uint64, height *int32) *GetAssetUnlockStatusesCmd {
+	return &GetAssetUnlockStatusesCmd{
+		Indexes: indexes,
+		Height:  height,
+	}
+}
+
 // GetBestBlockHashCmd defines the getbestblockhash JSON-RPC command.
 type GetBestBlockHashCmd struct{}
 
@@ -127,6 +302,15 @@ func NewGetBestBlockHashCmd() *GetBestBlockHashCmd {
 	return &GetBestBlockHashCmd{}
 }
 
+// GetBestChainLockCmd defines the getbestchainlock JSON-RPC command.
+type GetBestChainLockCmd struct{}
+
+// NewGetBestChainLockCmd returns a new instance which can be used to issue a
+// getbestchainlock JSON-RPC command.
+func NewGetBestChainLockCmd() *GetBestChainLockCmd {
+	return &GetBestChainLockCmd{}
+}
+
 // GetBlockCmd defines the getblock JSON-RPC command.
 type GetBlockCmd struct {
 	Hash      string
@@ -176,6 +360,33 @@ func NewGetBlockHashCmd(index int64) *GetBlockHashCmd {
 	}
 }
 
+// GetBlockHashesOptions defines the options of the getblockhashes JSON-RPC
+// command.
+type GetBlockHashesOptions struct {
+	NoOrphans    *bool `json:"noOrphans,omitempty"`
+	LogicalTimes *bool `json:"logicalTimes,omitempty"`
+}
+
+// GetBlockHashesCmd defines the getblockhashes JSON-RPC command.
+type GetBlockHashesCmd struct {
+	High    uint32
+	Low     uint32
+	Options *GetBlockHashesOptions
+}
+
+// NewGetBlockHashesCmd returns a new instance which can be used to issue a
+// getblockhashes JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewGetBlockHashesCmd(high, low uint32, options *GetBlockHashesOptions) *GetBlockHashesCmd {
+	return &GetBlockHashesCmd{
+		High:    high,
+		Low:     low,
+		Options: options,
+	}
+}
+
 // GetBlockHeaderCmd defines the getblockheader JSON-RPC command.
 type GetBlockHeaderCmd struct {
 	Hash    string
@@ -521,6 +732,29 @@ func NewGetRawTransactionCmd(txHash string, verbose *int) *GetRawTransactionCmd
 	}
 }
 
+// SpentInfoRequest defines the output to query in the getspentinfo JSON-RPC
+// command.
+type SpentInfoRequest struct {
+	Txid  string `json:"txid"`
+	Index uint32 `json:"index"`
+}
+
+// GetSpentInfoCmd defines the getspentinfo JSON-RPC command.
+type GetSpentInfoCmd struct {
+	Request SpentInfoRequest
+}
+
+// NewGetSpentInfoCmd returns a new instance which can be used to issue a
+// getspentinfo JSON-RPC command.
+func NewGetSpentInfoCmd(txHash string, index uint32) *GetSpentInfoCmd {
+	return &GetSpentInfoCmd{
+		Request: SpentInfoRequest{
+			Txid:  txHash,
+			Index: index,
+		},
+	}
+}
+
 // GetTxOutCmd defines the gettxout JSON-RPC command.
 type GetTxOutCmd struct {
 	Txid           string
@@ -560,12 +794,21 @@ func NewGetTxOutProofCmd(txIDs []string, blockHash *string) *GetTxOutProofCmd {
 }
 
 // GetTxOutSetInfoCmd defines the gettxoutsetinfo JSON-RPC command.
-type GetTxOutSetInfoCmd struct{}
+type GetTxOutSetInfoCmd struct {
+	HashType     *string `jsonrpcdefault:"\"muhash\"" jsonrpcusage:"\"muhash|none\""`
+	HashOrHeight *HashOrHeight
+}
 
 // NewGetTxOutSetInfoCmd returns a new instance which can be used to issue a
 // gettxoutsetinfo JSON-RPC command.
-func NewGetTxOutSetInfoCmd() *GetTxOutSetInfoCmd {
-	return &GetTxOutSetInfoCmd{}
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewGetTxOutSetInfoCmd(hashType *string, hashOrHeight *HashOrHeight) *GetTxOutSetInfoCmd {
+	return &GetTxOutSetInfoCmd{
+		HashType:     hashType,
+		HashOrHeight: hashOrHeight,
+	}
 }
 
 // GetWorkCmd defines the getwork JSON-RPC command.
@@ -613,6 +856,80 @@ func NewInvalidateBlockCmd(blockHash string) *InvalidateBlockCmd {
 	}
 }
 
+// ListBannedCmd defines the listbanned JSON-RPC command.
+type ListBannedCmd struct{}
+
+// NewListBannedCmd returns a new instance which can be used to issue a
+// listbanned JSON-RPC command.
+func NewListBannedCmd() *ListBannedCmd {
+	return &ListBannedCmd{}
+}
+
+// LoadTxOutSetCmd defines the loadtxoutset JSON-RPC command.
+type LoadTxOutSetCmd struct {
+	Path string
+}
+
+// NewLoadTxOutSetCmd returns a new instance which can be used to issue a
+// loadtxoutset JSON-RPC command.
+func NewLoadTxOutSetCmd(path string) *LoadTxOutSetCmd {
+	return &LoadTxOutSetCmd{
+		Path: path,
+	}
+}
+
+// MasternodeSubCmd defines the type used in the masternode JSON-RPC command
+// for the sub command field.
+type MasternodeSubCmd string
+
+const (
+	// MasternodeCount requests the number of masternodes.
+	MasternodeCount MasternodeSubCmd = "count"
+
+	// MasternodeWinners requests the recent and upcoming masternode
+	// payees.
+	MasternodeWinners MasternodeSubCmd = "winners"
+)
+
+// MasternodeCmd defines the masternode JSON-RPC command.  The count and filter
+// only apply to the winners sub command.
+type MasternodeCmd struct {
+	SubCmd MasternodeSubCmd `jsonrpcusage:"\"count|winners\""`
+	Count  *int             `jsonrpcdefault:"10"`
+	Filter *string          `jsonrpcdefault:"\"\""`
+}
+
+// NewMasternodeCmd returns a new instance which can be used to issue a
+// masternode JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewMasternodeCmd(subCmd MasternodeSubCmd, count *int, filter *string) *MasternodeCmd {
+	return &MasternodeCmd{
+		SubCmd: subCmd,
+		Count:  count,
+		Filter: filter,
+	}
+}
+
+// MasternodeListCmd defines the masternodelist JSON-RPC command.
+type MasternodeListCmd struct {
+	Mode   *string `jsonrpcdefault:"\"json\"" jsonrpcusage:"\"json|addr|payee|status|pubkeyoperator\""`
+	Filter *string `jsonrpcdefault:"\"\""`
+}
+
+// NewMasternodeListCmd returns a new instance which can be used to issue a
+// masternodelist JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewMasternodeListCmd(mode, filter *string) *MasternodeListCmd {
+	return &MasternodeListCmd{
+		Mode:   mode,
+		Filter: filter,
+	}
+}
+
 // PingCmd defines the ping JSON-RPC command.
 type PingCmd struct{}
 
@@ -635,6 +952,127 @@ func NewPreciousBlockCmd(blockHash string) *PreciousBlockCmd {
 	}
 }
 
+// ProTxSubCmd defines the type used in the protx JSON-RPC command for the sub
+// command field.
+type ProTxSubCmd string
+
+const (
+	// ProTxList requests the masternodes of the masternode list.
+	ProTxList ProTxSubCmd = "list"
+
+	// ProTxInfo requests the details of a masternode.
+	ProTxInfo ProTxSubCmd = "info"
+
+	// ProTxDiff requests the changes of the simplified masternode list
+	// between two blocks.
+	ProTxDiff ProTxSubCmd = "diff"
+)
+
+// ProTxArg defines a positional argument of the protx JSON-RPC command.  The
+// type of the value depends on the sub command and position, so it may be a
+// string, an integer or a boolean.
+type ProTxArg struct {
+	Value interface{}
+}
+
+// MarshalJSON implements the json.Marshaler interface.
+func (a ProTxArg) MarshalJSON() ([]byte, error) {
+	return json.Marshal(a.Value)
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+func (a *ProTxArg) UnmarshalJSON(data []byte) error {
+	var unmarshalled interface{}
+	if err := json.Unmarshal(data, &unmarshalled); err != nil {
+		return err
+	}
+
+	switch v := unmarshalled.(type) {
+	case float64:
+		a.Value = int(v)
+	case string, bool:
+		a.Value = v
+	default:
+		return fmt.Errorf("invalid protx argument: %v", unmarshalled)
+	}
+
+	return nil
+}
+
+// ProTxCmd defines the protx JSON-RPC command.  The arguments following the
+// sub command are:
+//
+//   - list: type ("registered", "valid" or "evo"), detailed and height
+//   - info: ProTxHash and block hash
+//   - diff: base block and block, as hashes or heights, and extended
+type ProTxCmd struct {
+	SubCmd ProTxSubCmd `jsonrpcusage:"\"list|info|diff\""`
+	Arg1   *ProTxArg   `jsonrpcusage:"type|proTxHash|baseBlock"`
+	Arg2   *ProTxArg   `jsonrpcusage:"detailed|blockHash|block"`
+	Arg3   *ProTxArg   `jsonrpcusage:"height|extended"`
+}
+
+// NewProTxListCmd returns a new instance which can be used to issue a protx
+// list JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewProTxListCmd(listType *string, detailed *bool, height *int32) *ProTxCmd {
+	cmd := &ProTxCmd{SubCmd: ProTxList}
+
+	// Positional arguments can't be skipped, so the defaults are used
+	// for the arguments preceding one which is set.
+	if height != nil {
+		cmd.Arg3 = &ProTxArg{int(*height)}
+		if detailed == nil {
+			detailed = Bool(false)
+		}
+	}
+	if detailed != nil {
+		cmd.Arg2 = &ProTxArg{*detailed}
+		if listType == nil {
+			listType = String("registered")
+		}
+	}
+	if listType != nil {
+		cmd.Arg1 = &ProTxArg{*listType}
+	}
+	return cmd
+}
+
+// NewProTxInfoCmd returns a new instance which can be used to issue a protx
+// info JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewProTxInfoCmd(proTxHash string, blockHash *string) *ProTxCmd {
+	cmd := &ProTxCmd{
+		SubCmd: ProTxInfo,
+		Arg1:   &ProTxArg{proTxHash},
+	}
+	if blockHash != nil {
+		cmd.Arg2 = &ProTxArg{*blockHash}
+	}
+	return cmd
+}
+
+// NewProTxDiffCmd returns a new instance which can be used to issue a protx
+// diff JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewProTxDiffCmd(baseBlock, block HashOrHeight, extended *bool) *ProTxCmd {
+	cmd := &ProTxCmd{
+		SubCmd: ProTxDiff,
+		Arg1:   &ProTxArg{baseBlock.Value},
+		Arg2:   &ProTxArg{block.Value},
+	}
+	if extended != nil {
+		cmd.Arg3 = &ProTxArg{*extended}
+	}
+	return cmd
+}
+
 // ReconsiderBlockCmd defines the reconsiderblock JSON-RPC command.
 type ReconsiderBlockCmd struct {
 	BlockHash string
@@ -706,6 +1144,42 @@ func NewBitcoindSendRawTransactionCmd(hexTx string, maxFeeRate int32) *SendRawTr
 	}
 }
 
+// SetBanSubCmd defines the type used in the setban JSON-RPC command for the
+// sub command field.
+type SetBanSubCmd string
+
+const (
+	// SBAdd indicates the specified subnet should be banned.
+	SBAdd SetBanSubCmd = "add"
+
+	// SBRemove indicates the ban of the specified subnet should be lifted.
+	SBRemove SetBanSubCmd = "remove"
+)
+
+// SetBanCmd defines the setban JSON-RPC command.
+type SetBanCmd struct {
+	SubNet   string
+	Command  SetBanSubCmd `jsonrpcusage:"\"add|remove\""`
+	BanTime  *int64       `jsonrpcdefault:"0"`
+	Absolute *bool        `jsonrpcdefault:"false"`
+}
+
+// NewSetBanCmd returns a new instance which can be used to issue a setban
+// JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewSetBanCmd(subNet string, command SetBanSubCmd, banTime *int64,
+	absolute *bool) *SetBanCmd {
+
+	return &SetBanCmd{
+		SubNet:   subNet,
+		Command:  command,
+		BanTime:  banTime,
+		Absolute: absolute,
+	}
+}
+
 // SetGenerateCmd defines the setgenerate JSON-RPC command.
 type SetGenerateCmd struct {
 	Generate     bool
@@ -724,6 +1198,22 @@ func NewSetGenerateCmd(generate bool, genProcLimit *int) *SetGenerateCmd {
 	}
 }
 
+// SignMessageWithPrivKeyCmd defines the signmessagewithprivkey JSON-RPC
+// command.
+type SignMessageWithPrivKeyCmd struct {
+	PrivKey string
+	Message string
+}
+
+// NewSignMessageWithPrivKeyCmd returns a new instance which can be used to
+// issue a signmessagewithprivkey JSON-RPC command.
+func NewSignMessageWithPrivKeyCmd(privKey, message string) *SignMessageWithPrivKeyCmd {
+	return &SignMessageWithPrivKeyCmd{
+		PrivKey: privKey,
+		Message: message,
+	}
+}
+
 // StopCmd defines the stop JSON-RPC command.
 type StopCmd struct{}
 
@@ -832,15 +1322,26 @@ func init() {
 	flags := UsageFlag(0)
 
 	MustRegisterCmd("addnode", (*AddNodeCmd)(nil), flags)
+	MustRegisterCmd("clearbanned", (*ClearBannedCmd)(nil), flags)
 	MustRegisterCmd("createrawtransaction", (*CreateRawTransactionCmd)(nil), flags)
 	MustRegisterCmd("decoderawtransaction", (*DecodeRawTransactionCmd)(nil), flags)
 	MustRegisterCmd("decodescript", (*DecodeScriptCmd)(nil), flags)
+	MustRegisterCmd("disconnectnode", (*DisconnectNodeCmd)(nil), flags)
+	MustRegisterCmd("dumptxoutset", (*DumpTxOutSetCmd)(nil), flags)
 	MustRegisterCmd("getaddednodeinfo", (*GetAddedNodeInfoCmd)(nil), flags)
+	MustRegisterCmd("getaddressbalance", (*GetAddressBalanceCmd)(nil), flags)
+	MustRegisterCmd("getaddressdeltas", (*GetAddressDeltasCmd)(nil), flags)
+	MustRegisterCmd("getaddressmempool", (*GetAddressMempoolCmd)(nil), flags)
+	MustRegisterCmd("getaddresstxids", (*GetAddressTxIDsCmd)(nil), flags)
+	MustRegisterCmd("getaddressutxos", (*GetAddressUtxosCmd)(nil), flags)
+	MustRegisterCmd("getassetunlockstatuses", (*GetAssetUnlockStatusesCmd)(nil), flags)
 	MustRegisterCmd("getbestblockhash", (*GetBestBlockHashCmd)(nil), flags)
+	MustRegisterCmd("getbestchainlock", (*GetBestChainLockCmd)(nil), flags)
 	MustRegisterCmd("getblock", (*GetBlockCmd)(nil), flags)
 	MustRegisterCmd("getblockchaininfo", (*GetBlockChainInfoCmd)(nil), flags)
 	MustRegisterCmd("getblockcount", (*GetBlockCountCmd)(nil), flags)
 	MustRegisterCmd("getblockhash", (*GetBlockHashCmd)(nil), flags)
+	MustRegisterCmd("getblockhashes", (*GetBlockHashesCmd)(nil), flags)
 	MustRegisterCmd("getblockheader", (*GetBlockHeaderCmd)(nil), flags)
 	MustRegisterCmd("getblockstats", (*GetBlockStatsCmd)(nil), flags)
 	MustRegisterCmd("getblocktemplate", (*GetBlockTemplateCmd)(nil), flags)
@@ -861,18 +1362,26 @@ func init() {
 	MustRegisterCmd("getpeerinfo", (*GetPeerInfoCmd)(nil), flags)
 	MustRegisterCmd("getrawmempool", (*GetRawMempoolCmd)(nil), flags)
 	MustRegisterCmd("getrawtransaction", (*GetRawTransactionCmd)(nil), flags)
+	MustRegisterCmd("getspentinfo", (*GetSpentInfoCmd)(nil), flags)
 	MustRegisterCmd("gettxout", (*GetTxOutCmd)(nil), flags)
 	MustRegisterCmd("gettxoutproof", (*GetTxOutProofCmd)(nil), flags)
 	MustRegisterCmd("gettxoutsetinfo", (*GetTxOutSetInfoCmd)(nil), flags)
 	MustRegisterCmd("getwork", (*GetWorkCmd)(nil), flags)
 	MustRegisterCmd("help", (*HelpCmd)(nil), flags)
 	MustRegisterCmd("invalidateblock", (*InvalidateBlockCmd)(nil), flags)
+	MustRegisterCmd("listbanned", (*ListBannedCmd)(nil), flags)
+	MustRegisterCmd("loadtxoutset", (*LoadTxOutSetCmd)(nil), flags)
+	MustRegisterCmd("masternode", (*MasternodeCmd)(nil), flags)
+	MustRegisterCmd("masternodelist", (*MasternodeListCmd)(nil), flags)
 	MustRegisterCmd("ping", (*PingCmd)(nil), flags)
 	MustRegisterCmd("preciousblock", (*PreciousBlockCmd)(nil), flags)
+	MustRegisterCmd("protx", (*ProTxCmd)(nil), flags)
 	MustRegisterCmd("reconsiderblock", (*ReconsiderBlockCmd)(nil), flags)
 	MustRegisterCmd("searchrawtransactions", (*SearchRawTransactionsCmd)(nil), flags)
 	MustRegisterCmd("sendrawtransaction", (*SendRawTransactionCmd)(nil), flags)
+	MustRegisterCmd("setban", (*SetBanCmd)(nil), flags)
 	MustRegisterCmd("setgenerate", (*SetGenerateCmd)(nil), flags)
+	MustRegisterCmd("signmessagewithprivkey", (*SignMessageWithPrivKeyCmd)(nil), flags)
 	MustRegisterCmd("stop", (*StopCmd)(nil), flags)
 	MustRegisterCmd("submitblock", (*SubmitBlockCmd)(nil), flags)
 	MustRegisterCmd("uptime", (*UptimeCmd)(nil), flags)
@@ -41,6 +41,17 @@ func TestChainSvrCmds(t *testing.T) {
 			marshalled:   `{"jsonrpc":"1.0","method":"addnode","params":["127.0.0.1","remove"],"id":1}`,
 			unmarshalled: &btcjson.AddNodeCmd{Addr: "127.0.0.1", SubCmd: btcjson.ANRemove},
 		},
+		{
+			name: "clearbanned",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("clearbanned")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewClearBannedCmd()
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"clearbanned","params":[],"id":1}`,
+			unmarshalled: &btcjson.ClearBannedCmd{},
+		},
 		{
 			name: "createrawtransaction",
 			newCmd: func() (interface{}, error) {
@@ -103,6 +114,44 @@ func TestChainSvrCmds(t *testing.T) {
 			marshalled:   `{"jsonrpc":"1.0","method":"decodescript","params":["00"],"id":1}`,
 			unmarshalled: &btcjson.DecodeScriptCmd{HexScript: "00"},
 		},
+		{
+			name: "disconnectnode",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("disconnectnode", "127.0.0.1:9999")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewDisconnectNodeCmd(btcjson.String("127.0.0.1:9999"), nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"disconnectnode","params":["127.0.0.1:9999"],"id":1}`,
+			unmarshalled: &btcjson.DisconnectNodeCmd{
+				Address: btcjson.String("127.0.0.1:9999"),
+			},
+		},
+		{
+			name: "disconnectnode node id",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("disconnectnode", "", 5)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewDisconnectNodeCmd(btcjson.String(""), btcjson.Int32(5))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"disconnectnode","params":["",5],"id":1}`,
+			unmarshalled: &btcjson.DisconnectNodeCmd{
+				Address: btcjson.String(""),
+				NodeID:  btcjson.Int32(5),
+			},
+		},
+		{
+			name: "dumptxoutset",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("dumptxoutset", "utxo.dat")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewDumpTxOutSetCmd("utxo.dat")
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"dumptxoutset","params":["utxo.dat"],"id":1}`,
+			unmarshalled: &btcjson.DumpTxOutSetCmd{Path: "utxo.dat"},
+		},
 		{
 			name: "getaddednodeinfo",
 			newCmd: func() (interface{}, error) {
@@ -128,6 +177,131 @@ func TestChainSvrCmds(t *testing.T) {
 				Node: btcjson.String("127.0.0.1"),
 			},
 		},
+		{
+			name: "getaddressbalance",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getaddressbalance",
+					btcjson.AddressIndexRequest{Addresses: []string{"1Address"}})
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetAddressBalanceCmd([]string{"1Address"})
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getaddressbalance","params":[{"addresses":["1Address"]}],"id":1}`,
+			unmarshalled: &btcjson.GetAddressBalanceCmd{
+				Request: btcjson.AddressIndexRequest{Addresses: []string{"1Address"}},
+			},
+		},
+		{
+			name: "getaddressdeltas",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getaddressdeltas",
+					btcjson.AddressIndexRequest{
+						Addresses: []string{"1Address"},
+						Start:     btcjson.Int32(1),
+						End:       btcjson.Int32(2),
+						ChainInfo: btcjson.Bool(true),
+					})
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetAddressDeltasCmd([]string{"1Address"},
+					btcjson.Int32(1), btcjson.Int32(2), btcjson.Bool(true))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getaddressdeltas","params":[{"addresses":["1Address"],"start":1,"end":2,"chainInfo":true}],"id":1}`,
+			unmarshalled: &btcjson.GetAddressDeltasCmd{
+				Request: btcjson.AddressIndexRequest{
+					Addresses: []string{"1Address"},
+					Start:     btcjson.Int32(1),
+					End:       btcjson.Int32(2),
+					ChainInfo: btcjson.Bool(true),
+				},
+			},
+		},
+		{
+			name: "getaddressmempool",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getaddressmempool",
+					btcjson.AddressIndexRequest{Addresses: []string{"1Address", "1Other"}})
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetAddressMempoolCmd([]string{"1Address", "1Other"})
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getaddressmempool","params":[{"addresses":["1Address","1Other"]}],"id":1}`,
+			unmarshalled: &btcjson.GetAddressMempoolCmd{
+				Request: btcjson.AddressIndexRequest{Addresses: []string{"1Address", "1Other"}},
+			},
+		},
+		{
+			name: "getaddresstxids",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getaddresstxids",
+					btcjson.AddressIndexRequest{
+						Addresses: []string{"1Address"},
+						Start:     btcjson.Int32(1),
+						End:       btcjson.Int32(2),
+					})
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetAddressTxIDsCmd([]string{"1Address"},
+					btcjson.Int32(1), btcjson.Int32(2))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getaddresstxids","params":[{"addresses":["1Address"],"start":1,"end":2}],"id":1}`,
+			unmarshalled: &btcjson.GetAddressTxIDsCmd{
+				Request: btcjson.AddressIndexRequest{
+					Addresses: []string{"1Address"},
+					Start:     btcjson.Int32(1),
+					End:       btcjson.Int32(2),
+				},
+			},
+		},
+		{
+			name: "getaddressutxos",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getaddressutxos",
+					btcjson.AddressIndexRequest{
+						Addresses: []string{"1Address"},
+						ChainInfo: btcjson.Bool(false),
+					})
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetAddressUtxosCmd([]string{"1Address"},
+					btcjson.Bool(false))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getaddressutxos","params":[{"addresses":["1Address"],"chainInfo":false}],"id":1}`,
+			unmarshalled: &btcjson.GetAddressUtxosCmd{
+				Request: btcjson.AddressIndexRequest{
+					Addresses: []string{"1Address"},
+					ChainInfo: btcjson.Bool(false),
+				},
+			},
+		},
+		{
+			name: "getassetunlockstatuses",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getassetunlockstatuses", []uint64{1, 2})
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetAssetUnlockStatusesCmd([]uint64{1, 2}, nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getassetunlockstatuses","params":[[1,2]],"id":1}`,
+			unmarshalled: &btcjson.GetAssetUnlockStatusesCmd{
+				Indexes: []uint64{1, 2},
+				Height:  nil,
+			},
+		},
+		{
+			name: "getassetunlockstatuses optional",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getassetunlockstatuses", []uint64{1}, 100)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetAssetUnlockStatusesCmd([]uint64{1}, btcjson.Int32(100))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getassetunlockstatuses","params":[[1],100],"id":1}`,
+			unmarshalled: &btcjson.GetAssetUnlockStatusesCmd{
+				Indexes: []uint64{1},
+				Height:  btcjson.Int32(100),
+			},
+		},
 		{
 			name: "getbestblockhash",
 			newCmd: func() (interface{}, error) {
@@ -139,6 +313,17 @@ func TestChainSvrCmds(t *testing.T) {
 			marshalled:   `{"jsonrpc":"1.0","method":"getbestblockhash","params":[],"id":1}`,
 			unmarshalled: &btcjson.GetBestBlockHashCmd{},
 		},
+		{
+			name: "getbestchainlock",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getbestchainlock")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetBestChainLockCmd()
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"getbestchainlock","params":[],"id":1}`,
+			unmarshalled: &btcjson.GetBestChainLockCmd{},
+		},
 		{
 			name: "getblock",
 			newCmd: func() (interface{}, error) {
@@ -214,6 +399,46 @@ func TestChainSvrCmds(t *testing.T) {
 			marshalled:   `{"jsonrpc":"1.0","method":"getblockhash","params":[123],"id":1}`,
 			unmarshalled: &btcjson.GetBlockHashCmd{Index: 123},
 		},
+		{
+			name: "getblockhashes",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getblockhashes", 1231614698, 1231024505)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetBlockHashesCmd(1231614698, 1231024505, nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getblockhashes","params":[1231614698,1231024505],"id":1}`,
+			unmarshalled: &btcjson.GetBlockHashesCmd{
+				High: 1231614698,
+				Low:  1231024505,
+			},
+		},
+		{
+			name: "getblockhashes options",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getblockhashes", 1231614698, 1231024505,
+					btcjson.GetBlockHashesOptions{
+						NoOrphans:    btcjson.Bool(true),
+						LogicalTimes: btcjson.Bool(true),
+					})
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetBlockHashesCmd(1231614698, 1231024505,
+					&btcjson.GetBlockHashesOptions{
+						NoOrphans:    btcjson.Bool(true),
+						LogicalTimes: btcjson.Bool(true),
+					})
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getblockhashes","params":[1231614698,1231024505,{"noOrphans":true,"logicalTimes":true}],"id":1}`,
+			unmarshalled: &btcjson.GetBlockHashesCmd{
+				High: 1231614698,
+				Low:  1231024505,
+				Options: &btcjson.GetBlockHashesOptions{
+					NoOrphans:    btcjson.Bool(true),
+					LogicalTimes: btcjson.Bool(true),
+				},
+			},
+		},
 		{
 			name: "getblockheader",
 			newCmd: func() (interface{}, error) {
@@ -627,6 +852,20 @@ func TestChainSvrCmds(t *testing.T) {
 				Verbose: btcjson.Int(1),
 			},
 		},
+		{
+			name: "getspentinfo",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getspentinfo",
+					btcjson.SpentInfoRequest{Txid: "123", Index: 1})
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetSpentInfoCmd("123", 1)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getspentinfo","params":[{"txid":"123","index":1}],"id":1}`,
+			unmarshalled: &btcjson.GetSpentInfoCmd{
+				Request: btcjson.SpentInfoRequest{Txid: "123", Index: 1},
+			},
+		},
 		{
 			name: "gettxout",
 			newCmd: func() (interface{}, error) {
@@ -693,10 +932,42 @@ func TestChainSvrCmds(t *testing.T) {
 				return btcjson.NewCmd("gettxoutsetinfo")
 			},
 			staticCmd: func() interface{} {
-				return btcjson.NewGetTxOutSetInfoCmd()
+				return btcjson.NewGetTxOutSetInfoCmd(nil, nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"gettxoutsetinfo","params":[],"id":1}`,
+			unmarshalled: &btcjson.GetTxOutSetInfoCmd{
+				HashType: btcjson.String("muhash"),
+			},
+		},
+		{
+			name: "gettxoutsetinfo optional height",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("gettxoutsetinfo", "none", btcjson.HashOrHeight{Value: 123})
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetTxOutSetInfoCmd(btcjson.String("none"),
+					&btcjson.HashOrHeight{Value: 123})
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"gettxoutsetinfo","params":["none",123],"id":1}`,
+			unmarshalled: &btcjson.GetTxOutSetInfoCmd{
+				HashType:     btcjson.String("none"),
+				HashOrHeight: &btcjson.HashOrHeight{Value: 123},
+			},
+		},
+		{
+			name: "gettxoutsetinfo optional hash",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("gettxoutsetinfo", "muhash", btcjson.HashOrHeight{Value: "deadbeef"})
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetTxOutSetInfoCmd(btcjson.String("muhash"),
+					&btcjson.HashOrHeight{Value: "deadbeef"})
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"gettxoutsetinfo","params":["muhash","deadbeef"],"id":1}`,
+			unmarshalled: &btcjson.GetTxOutSetInfoCmd{
+				HashType:     btcjson.String("muhash"),
+				HashOrHeight: &btcjson.HashOrHeight{Value: "deadbeef"},
 			},
-			marshalled:   `{"jsonrpc":"1.0","method":"gettxoutsetinfo","params":[],"id":1}`,
-			unmarshalled: &btcjson.GetTxOutSetInfoCmd{},
 		},
 		{
 			name: "getwork",
@@ -763,6 +1034,88 @@ func TestChainSvrCmds(t *testing.T) {
 				BlockHash: "123",
 			},
 		},
+		{
+			name: "listbanned",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("listbanned")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewListBannedCmd()
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"listbanned","params":[],"id":1}`,
+			unmarshalled: &btcjson.ListBannedCmd{},
+		},
+		{
+			name: "loadtxoutset",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("loadtxoutset", "utxo.dat")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewLoadTxOutSetCmd("utxo.dat")
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"loadtxoutset","params":["utxo.dat"],"id":1}`,
+			unmarshalled: &btcjson.LoadTxOutSetCmd{Path: "utxo.dat"},
+		},
+		{
+			name: "masternode",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("masternode", "count")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewMasternodeCmd(btcjson.MasternodeCount, nil, nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"masternode","params":["count"],"id":1}`,
+			unmarshalled: &btcjson.MasternodeCmd{
+				SubCmd: btcjson.MasternodeCount,
+				Count:  btcjson.Int(10),
+				Filter: btcjson.String(""),
+			},
+		},
+		{
+			name: "masternode optional",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("masternode", "winners", 5, "X")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewMasternodeCmd(btcjson.MasternodeWinners,
+					btcjson.Int(5), btcjson.String("X"))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"masternode","params":["winners",5,"X"],"id":1}`,
+			unmarshalled: &btcjson.MasternodeCmd{
+				SubCmd: btcjson.MasternodeWinners,
+				Count:  btcjson.Int(5),
+				Filter: btcjson.String("X"),
+			},
+		},
+		{
+			name: "masternodelist",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("masternodelist")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewMasternodeListCmd(nil, nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"masternodelist","params":[],"id":1}`,
+			unmarshalled: &btcjson.MasternodeListCmd{
+				Mode:   btcjson.String("json"),
+				Filter: btcjson.String(""),
+			},
+		},
+		{
+			name: "masternodelist optional",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("masternodelist", "addr", "1.2.3.4")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewMasternodeListCmd(btcjson.String("addr"),
+					btcjson.String("1.2.3.4"))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"masternodelist","params":["addr","1.2.3.4"],"id":1}`,
+			unmarshalled: &btcjson.MasternodeListCmd{
+				Mode:   btcjson.String("addr"),
+				Filter: btcjson.String("1.2.3.4"),
+			},
+		},
 		{
 			name: "ping",
 			newCmd: func() (interface{}, error) {
@@ -787,6 +1140,73 @@ func TestChainSvrCmds(t *testing.T) {
 				BlockHash: "0123",
 			},
 		},
+		{
+			name: "protx list",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("protx", "list")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewProTxListCmd(nil, nil, nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"protx","params":["list"],"id":1}`,
+			unmarshalled: &btcjson.ProTxCmd{
+				SubCmd: btcjson.ProTxList,
+			},
+		},
+		{
+			name: "protx list height",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("protx", "list",
+					&btcjson.ProTxArg{Value: "registered"},
+					&btcjson.ProTxArg{Value: false},
+					&btcjson.ProTxArg{Value: 100})
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewProTxListCmd(nil, nil, btcjson.Int32(100))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"protx","params":["list","registered",false,100],"id":1}`,
+			unmarshalled: &btcjson.ProTxCmd{
+				SubCmd: btcjson.ProTxList,
+				Arg1:   &btcjson.ProTxArg{Value: "registered"},
+				Arg2:   &btcjson.ProTxArg{Value: false},
+				Arg3:   &btcjson.ProTxArg{Value: 100},
+			},
+		},
+		{
+			name: "protx info",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("protx", "info",
+					&btcjson.ProTxArg{Value: "123"})
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewProTxInfoCmd("123", nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"protx","params":["info","123"],"id":1}`,
+			unmarshalled: &btcjson.ProTxCmd{
+				SubCmd: btcjson.ProTxInfo,
+				Arg1:   &btcjson.ProTxArg{Value: "123"},
+			},
+		},
+		{
+			name: "protx diff",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("protx", "diff",
+					&btcjson.ProTxArg{Value: 1},
+					&btcjson.ProTxArg{Value: "456"},
+					&btcjson.ProTxArg{Value: true})
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewProTxDiffCmd(btcjson.HashOrHeight{Value: 1},
+					btcjson.HashOrHeight{Value: "456"}, btcjson.Bool(true))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"protx","params":["diff",1,"456",true],"id":1}`,
+			unmarshalled: &btcjson.ProTxCmd{
+				SubCmd: btcjson.ProTxDiff,
+				Arg1:   &btcjson.ProTxArg{Value: 1},
+				Arg2:   &btcjson.ProTxArg{Value: "456"},
+				Arg3:   &btcjson.ProTxArg{Value: true},
+			},
+		},
 		{
 			name: "reconsiderblock",
 			newCmd: func() (interface{}, error) {
@@ -967,6 +1387,39 @@ func TestChainSvrCmds(t *testing.T) {
 				AllowHighFees: btcjson.Bool(false),
 			},
 		},
+		{
+			name: "setban",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("setban", "192.168.0.0/24", btcjson.SBAdd)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewSetBanCmd("192.168.0.0/24", btcjson.SBAdd, nil, nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"setban","params":["192.168.0.0/24","add"],"id":1}`,
+			unmarshalled: &btcjson.SetBanCmd{
+				SubNet:   "192.168.0.0/24",
+				Command:  btcjson.SBAdd,
+				BanTime:  btcjson.Int64(0),
+				Absolute: btcjson.Bool(false),
+			},
+		},
+		{
+			name: "setban optional",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("setban", "192.168.0.1", btcjson.SBAdd, 1700000000, true)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewSetBanCmd("192.168.0.1", btcjson.SBAdd,
+					btcjson.Int64(1700000000), btcjson.Bool(true))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"setban","params":["192.168.0.1","add",1700000000,true],"id":1}`,
+			unmarshalled: &btcjson.SetBanCmd{
+				SubNet:   "192.168.0.1",
+				Command:  btcjson.SBAdd,
+				BanTime:  btcjson.Int64(1700000000),
+				Absolute: btcjson.Bool(true),
+			},
+		},
 		{
 			name: "setgenerate",
 			newCmd: func() (interface{}, error) {
@@ -995,6 +1448,20 @@ func TestChainSvrCmds(t *testing.T) {
 				GenProcLimit: btcjson.Int(6),
 			},
 		},
+		{
+			name: "signmessagewithprivkey",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("signmessagewithprivkey", "7rPrivKey", "message")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewSignMessageWithPrivKeyCmd("7rPrivKey", "message")
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"signmessagewithprivkey","params":["7rPrivKey","message"],"id":1}`,
+			unmarshalled: &btcjson.SignMessageWithPrivKeyCmd{
+				PrivKey: "7rPrivKey",
+				Message: "message",
+			},
+		},
 		{
 			name: "stop",
 			newCmd: func() (interface{}, error) {
@@ -1254,3 +1721,23 @@ func TestChainSvrCmdErrors(t *testing.T) {
 		}
 	}
 }
+
+// TestAddressIndexRequest ensures the request of the address index commands
+// can be given as a single address.
+func TestAddressIndexRequest(t *testing.T) {
+	t.Parallel()
+
+	request := []byte(`{"jsonrpc":"1.0","method":"getaddressbalance","params":["1Address"],"id":1}`)
+	var r btcjson.Request
+	if err := json.Unmarshal(request, &r); err != nil {
+		t.Fatalf("Unmarshal: unexpected error %v", err)
+	}
+	cmd, err := btcjson.UnmarshalCmd(&r)
+	if err != nil {
+		t.Fatalf("UnmarshalCmd: unexpected error %v", err)
+	}
+	want := btcjson.NewGetAddressBalanceCmd([]string{"1Address"})
+	if !reflect.DeepEqual(cmd, want) {
+		t.Fatalf("UnmarshalCmd: got %+v want %+v", cmd, want)
+	}
+}
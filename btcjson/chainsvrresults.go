@@ -80,6 +80,8 @@ type GetBlockVerboseResult struct {
 	Difficulty    float64       `json:"difficulty"`
 	PreviousHash  string        `json:"previousblockhash"`
 	NextHash      string        `json:"nextblockhash,omitempty"`
+
+	CreditPoolBalance *float64 `json:"creditPoolBalance,omitempty"`
 }
 
 // GetBlockVerboseTxResult models the data from the getblock command when the
@@ -105,6 +107,8 @@ type GetBlockVerboseTxResult struct {
 	Difficulty    float64       `json:"difficulty"`
 	PreviousHash  string        `json:"previousblockhash"`
 	NextHash      string        `json:"nextblockhash,omitempty"`
+
+	CreditPoolBalance *float64 `json:"creditPoolBalance,omitempty"`
 }
 
 // CreateMultiSigResult models the data returned from the createmultisig
@@ -130,6 +134,24 @@ type GetAddedNodeInfoResultAddr struct {
 	Connected string `json:"connected"`
 }
 
+// DumpTxOutSetResult models the data returned from the dumptxoutset command.
+type DumpTxOutSetResult struct {
+	CoinsWritten uint64 `json:"coins_written"`
+	BaseHash     string `json:"base_hash"`
+	BaseHeight   int32  `json:"base_height"`
+	Path         string `json:"path"`
+	TxOutSetHash string `json:"txoutset_hash"`
+	NChainTx     uint64 `json:"nchaintx"`
+}
+
+// LoadTxOutSetResult models the data returned from the loadtxoutset command.
+type LoadTxOutSetResult struct {
+	CoinsLoaded uint64 `json:"coins_loaded"`
+	TipHash     string `json:"tip_hash"`
+	BaseHeight  int32  `json:"base_height"`
+	Path        string `json:"path"`
+}
+
 // GetAddedNodeInfoResult models the data from the getaddednodeinfo command.
 type GetAddedNodeInfoResult struct {
 	AddedNode string                        `json:"addednode"`
@@ -148,7 +170,8 @@ type SoftForkDescription struct {
 }
 
 // Bip9SoftForkDescription describes the current state of a defined BIP0009
-// version bits soft-fork.
+// version bits soft-fork.  Deployments which are enabled by masternode hard
+// fork signals (DIP0023) are described the same way with EHF set.
 type Bip9SoftForkDescription struct {
 	Status     string `json:"status"`
 	Bit        uint8  `json:"bit"`
@@ -156,6 +179,8 @@ type Bip9SoftForkDescription struct {
 	StartTime2 int64  `json:"start_time"`
 	Timeout    int64  `json:"timeout"`
 	Since      int32  `json:"since"`
+	EHF        bool   `json:"ehf,omitempty"`
+	EHFHeight  int32  `json:"ehf_height,omitempty"`
 }
 
 // StartTime returns the starting time of the softfork as a Unix epoch.
@@ -362,6 +387,17 @@ type GetPeerInfoResult struct {
 	BanScore       int32   `json:"banscore"`
 	FeeFilter      int64   `json:"feefilter"`
 	SyncNode       bool    `json:"syncnode"`
+	MappedAS       uint32  `json:"mapped_as,omitempty"`
+}
+
+// ListBannedResult models the data of a ban returned from the listbanned
+// command.
+type ListBannedResult struct {
+	Address       string `json:"address"`
+	BanCreated    int64  `json:"ban_created"`
+	BannedUntil   int64  `json:"banned_until"`
+	BanDuration   int64  `json:"ban_duration"`
+	TimeRemaining int64  `json:"time_remaining"`
 }
 
 // GetRawMempoolVerboseResult models the data returned from the getrawmempool
@@ -389,6 +425,31 @@ type ScriptPubKeyResult struct {
 	Addresses []string `json:"addresses,omitempty"`
 }
 
+// GetBlockHashesResult models a block of the getblockhashes command when the
+// logical timestamps are requested.
+type GetBlockHashesResult struct {
+	BlockHash string `json:"blockhash"`
+	LogicalTS uint32 `json:"logicalts"`
+}
+
+// GetSpentInfoResult models the data from the getspentinfo command.
+type GetSpentInfoResult struct {
+	Txid   string `json:"txid"`
+	Index  uint32 `json:"index"`
+	Height int32  `json:"height"`
+}
+
+// GetTxOutSetInfoResult models the data from the gettxoutsetinfo command.
+type GetTxOutSetInfoResult struct {
+	Height       int32   `json:"height"`
+	BestBlock    string  `json:"bestblock"`
+	Transactions uint64  `json:"transactions"`
+	TxOuts       uint64  `json:"txouts"`
+	MuHash       string  `json:"muhash,omitempty"`
+	DiskSize     uint64  `json:"disk_size"`
+	TotalAmount  float64 `json:"total_amount"`
+}
+
 // GetTxOutResult models the data from the gettxout command.
 type GetTxOutResult struct {
 	BestBlock     string             `json:"bestblock"`
@@ -564,6 +625,9 @@ type Vout struct {
 	Value        float64            `json:"value"`
 	N            uint32             `json:"n"`
 	ScriptPubKey ScriptPubKeyResult `json:"scriptPubKey"`
+	SpentTxID    string             `json:"spentTxId,omitempty"`
+	SpentIndex   *uint32            `json:"spentIndex,omitempty"`
+	SpentHeight  *int32             `json:"spentHeight,omitempty"`
 }
 
 // GetMiningInfoResult models the data from the getmininginfo command.
@@ -613,6 +677,7 @@ type TxRawResult struct {
 	Vsize         int32  `json:"vsize,omitempty"`
 	Weight        int32  `json:"weight,omitempty"`
 	Version       int32  `json:"version"`
+	Type          uint16 `json:"type"`
 	LockTime      uint32 `json:"locktime"`
 	Vin           []Vin  `json:"vin"`
 	Vout          []Vout `json:"vout"`
@@ -620,6 +685,226 @@ type TxRawResult struct {
 	Confirmations uint64 `json:"confirmations,omitempty"`
 	Time          int64  `json:"time,omitempty"`
 	Blocktime     int64  `json:"blocktime,omitempty"`
+
+	ExtraPayloadSize int                  `json:"extraPayloadSize,omitempty"`
+	ExtraPayload     string               `json:"extraPayload,omitempty"`
+	CbTx             *CbTxResult          `json:"cbTx,omitempty"`
+	AssetLockTx      *AssetLockTxResult   `json:"assetLockTx,omitempty"`
+	AssetUnlockTx    *AssetUnlockTxResult `json:"assetUnlockTx,omitempty"`
+}
+
+// CbTxResult models the payload of a coinbase special transaction as returned
+// by the getrawtransaction and decoderawtransaction commands.
+type CbTxResult struct {
+	Version           uint16   `json:"version"`
+	Height            int32    `json:"height"`
+	MerkleRootMNList  string   `json:"merkleRootMNList"`
+	MerkleRootQuorums string   `json:"merkleRootQuorums,omitempty"`
+	BestCLHeightDiff  *uint64  `json:"bestCLHeightDiff,omitempty"`
+	BestCLSignature   string   `json:"bestCLSignature,omitempty"`
+	CreditPoolBalance *float64 `json:"creditPoolBalance,omitempty"`
+}
+
+// AssetLockTxResult models the payload of an asset lock special transaction
+// as returned by the getrawtransaction and decoderawtransaction commands.
+type AssetLockTxResult struct {
+	Version       uint8  `json:"version"`
+	CreditOutputs []Vout `json:"creditOutputs"`
+}
+
+// AssetUnlockTxResult models the payload of an asset unlock special
+// transaction as returned by the getrawtransaction and decoderawtransaction
+// commands.
+type AssetUnlockTxResult struct {
+	Version         uint8  `json:"version"`
+	Index           uint64 `json:"index"`
+	Fee             uint32 `json:"fee"`
+	RequestedHeight uint32 `json:"requestedHeight"`
+	QuorumHash      string `json:"quorumHash"`
+	QuorumSig       string `json:"quorumSig"`
+}
+
+// AssetUnlockStatusResult models the status of a single withdrawal index as
+// returned by the getassetunlockstatuses command.
+type AssetUnlockStatusResult struct {
+	Index  uint64 `json:"index"`
+	Status string `json:"status"`
+}
+
+// GetAddressBalanceResult models the data returned by the getaddressbalance
+// command.
+type GetAddressBalanceResult struct {
+	Balance          int64 `json:"balance"`
+	BalanceImmature  int64 `json:"balance_immature"`
+	BalanceSpendable int64 `json:"balance_spendable"`
+	Received         int64 `json:"received"`
+}
+
+// AddressDeltaResult models a balance change returned by the getaddressdeltas
+// command.
+type AddressDeltaResult struct {
+	Satoshis   int64  `json:"satoshis"`
+	TxID       string `json:"txid"`
+	Index      uint32 `json:"index"`
+	BlockIndex uint32 `json:"blockindex"`
+	Height     int32  `json:"height"`
+	Address    string `json:"address"`
+}
+
+// AddressBlockResult models a block referenced by the results of the address
+// index commands.
+type AddressBlockResult struct {
+	Hash   string `json:"hash"`
+	Height int32  `json:"height"`
+}
+
+// GetAddressDeltasChainInfoResult models the data returned by the
+// getaddressdeltas command when chain info is requested.
+type GetAddressDeltasChainInfoResult struct {
+	Deltas []AddressDeltaResult `json:"deltas"`
+	Start  AddressBlockResult   `json:"start"`
+	End    AddressBlockResult   `json:"end"`
+}
+
+// AddressUtxoResult models an unspent output returned by the getaddressutxos
+// command.
+type AddressUtxoResult struct {
+	Address     string `json:"address"`
+	TxID        string `json:"txid"`
+	OutputIndex uint32 `json:"outputIndex"`
+	Script      string `json:"script"`
+	Satoshis    int64  `json:"satoshis"`
+	Height      int32  `json:"height"`
+}
+
+// GetAddressUtxosChainInfoResult models the data returned by the
+// getaddressutxos command when chain info is requested.
+type GetAddressUtxosChainInfoResult struct {
+	Utxos  []AddressUtxoResult `json:"utxos"`
+	Hash   string              `json:"hash"`
+	Height int32               `json:"height"`
+}
+
+// AddressMempoolResult models a balance change returned by the
+// getaddressmempool command.
+type AddressMempoolResult struct {
+	Address   string  `json:"address"`
+	TxID      string  `json:"txid"`
+	Index     uint32  `json:"index"`
+	Satoshis  int64   `json:"satoshis"`
+	Timestamp int64   `json:"timestamp"`
+	PrevTxID  string  `json:"prevtxid,omitempty"`
+	PrevOut   *uint32 `json:"prevout,omitempty"`
+}
+
+// GetBestChainLockResult models the data returned by the getbestchainlock
+// command.
+type GetBestChainLockResult struct {
+	BlockHash  string `json:"blockhash"`
+	Height     int32  `json:"height"`
+	Signature  string `json:"signature"`
+	KnownBlock bool   `json:"known_block"`
+}
+
+// ProTxStateResult models the state of a masternode as returned by the protx
+// info and list commands.
+type ProTxStateResult struct {
+	Version               uint16 `json:"version"`
+	Service               string `json:"service"`
+	RegisteredHeight      int32  `json:"registeredHeight"`
+	LastPaidHeight        int32  `json:"lastPaidHeight"`
+	PoSePenalty           int32  `json:"PoSePenalty"`
+	PoSeRevivedHeight     int32  `json:"PoSeRevivedHeight"`
+	PoSeBanHeight         int32  `json:"PoSeBanHeight"`
+	RevocationReason      uint16 `json:"revocationReason"`
+	OwnerAddress          string `json:"ownerAddress"`
+	VotingAddress         string `json:"votingAddress"`
+	PlatformNodeID        string `json:"platformNodeID,omitempty"`
+	PlatformP2PPort       uint16 `json:"platformP2PPort,omitempty"`
+	PlatformHTTPPort      uint16 `json:"platformHTTPPort,omitempty"`
+	PayoutAddress         string `json:"payoutAddress"`
+	PubKeyOperator        string `json:"pubKeyOperator"`
+	OperatorPayoutAddress string `json:"operatorPayoutAddress,omitempty"`
+}
+
+// ProTxInfoResult models the data returned by the protx info command and by
+// the protx list command when the detailed flag is set.
+type ProTxInfoResult struct {
+	Type              string           `json:"type"`
+	ProTxHash         string           `json:"proTxHash"`
+	CollateralHash    string           `json:"collateralHash"`
+	CollateralIndex   uint32           `json:"collateralIndex"`
+	CollateralAddress string           `json:"collateralAddress,omitempty"`
+	OperatorReward    float64          `json:"operatorReward"`
+	State             ProTxStateResult `json:"state"`
+	Confirmations     int32            `json:"confirmations"`
+}
+
+// SimplifiedMNListEntryResult models an entry of the simplified masternode
+// list as returned by the protx diff command.
+type SimplifiedMNListEntryResult struct {
+	Version               uint16 `json:"nVersion"`
+	Type                  uint16 `json:"nType"`
+	ProRegTxHash          string `json:"proRegTxHash"`
+	ConfirmedHash         string `json:"confirmedHash"`
+	Service               string `json:"service"`
+	PubKeyOperator        string `json:"pubKeyOperator"`
+	VotingAddress         string `json:"votingAddress"`
+	IsValid               bool   `json:"isValid"`
+	PlatformHTTPPort      uint16 `json:"platformHTTPPort,omitempty"`
+	PlatformNodeID        string `json:"platformNodeID,omitempty"`
+	PayoutAddress         string `json:"payoutAddress,omitempty"`
+	OperatorPayoutAddress string `json:"operatorPayoutAddress,omitempty"`
+}
+
+// ProTxDiffResult models the data returned by the protx diff command.
+type ProTxDiffResult struct {
+	Version          uint16                        `json:"nVersion"`
+	BaseBlockHash    string                        `json:"baseBlockHash"`
+	BlockHash        string                        `json:"blockHash"`
+	CbTxMerkleTree   string                        `json:"cbTxMerkleTree"`
+	CbTx             string                        `json:"cbTx"`
+	DeletedMNs       []string                      `json:"deletedMNs"`
+	MNList           []SimplifiedMNListEntryResult `json:"mnList"`
+	MerkleRootMNList string                        `json:"merkleRootMNList,omitempty"`
+}
+
+// MasternodeListResult models an entry of the data returned by the
+// masternodelist command in json mode.  The status, payee, proof of service
+// penalty and last payment of the masternodes are not included since proof of
+// service penalties are not processed.
+type MasternodeListResult struct {
+	ProTxHash         string `json:"proTxHash"`
+	Address           string `json:"address"`
+	Type              string `json:"type"`
+	PlatformNodeID    string `json:"platformNodeID,omitempty"`
+	PlatformP2PPort   uint16 `json:"platformP2PPort,omitempty"`
+	PlatformHTTPPort  uint16 `json:"platformHTTPPort,omitempty"`
+	OwnerAddress      string `json:"owneraddress"`
+	VotingAddress     string `json:"votingaddress"`
+	CollateralAddress string `json:"collateraladdress"`
+	PubKeyOperator    string `json:"pubkeyoperator"`
+}
+
+// MasternodeCountDetail models the number of masternodes of a single type as
+// returned by the masternode count command.
+type MasternodeCountDetail struct {
+	Total int `json:"total"`
+}
+
+// MasternodeCountDetailedResult models the number of masternodes per type as
+// returned by the masternode count command.
+type MasternodeCountDetailedResult struct {
+	Regular MasternodeCountDetail `json:"regular"`
+	Evo     MasternodeCountDetail `json:"evo"`
+}
+
+// MasternodeCountResult models the data returned by the masternode count
+// command.  The number of enabled masternodes is not included since proof of
+// service penalties are not processed.
+type MasternodeCountResult struct {
+	Total    int                           `json:"total"`
+	Detailed MasternodeCountDetailedResult `json:"detailed"`
 }
 
 // SearchRawTransactionsResult models the data from the searchrawtransaction
@@ -645,9 +930,16 @@ type SearchRawTransactionsResult struct {
 type TxRawDecodeResult struct {
 	Txid     string `json:"txid"`
 	Version  int32  `json:"version"`
+	Type     uint16 `json:"type"`
 	Locktime uint32 `json:"locktime"`
 	Vin      []Vin  `json:"vin"`
 	Vout     []Vout `json:"vout"`
+
+	ExtraPayloadSize int                  `json:"extraPayloadSize,omitempty"`
+	ExtraPayload     string               `json:"extraPayload,omitempty"`
+	CbTx             *CbTxResult          `json:"cbTx,omitempty"`
+	AssetLockTx      *AssetLockTxResult   `json:"assetLockTx,omitempty"`
+	AssetUnlockTx    *AssetUnlockTxResult `json:"assetUnlockTx,omitempty"`
 }
 
 // ValidateAddressChainResult models the data returned by the chain server
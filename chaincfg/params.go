@@ -7,16 +7,19 @@ package chaincfg
 import (
 	"errors"
 	"github.com/eager7/dashd/chaincfg/chainhash"
+	"math"
 	"math/big"
 
 	"github.com/eager7/dashd/wire"
 )
+
 const (
-	DeploymentSegwit = 1
-	BIP0065Height = 1
+	DeploymentSegwit    = 1
+	BIP0065Height       = 1
 	DeploymentTestDummy = "DeploymentTestDummy"
-	DeploymentCSV = "DeploymentCSV"
+	DeploymentCSV       = "DeploymentCSV"
 )
+
 // These variables are the chain proof-of-work limit parameters for each default
 // network.
 var (
@@ -54,6 +57,68 @@ type Checkpoint struct {
 	Hash   *chainhash.Hash
 }
 
+// UtxoSnapshot identifies a known good snapshot of the unspent transaction
+// output set.  Nodes can be bootstrapped by loading a snapshot of the utxo set
+// at the block with the given hash whose content hash matches instead of
+// processing all blocks up to it, which are then validated in the background.
+//
+// See the documentation for blockchain.BlockChain.LoadUtxoSnapshot for details
+// on how the content hash is calculated.
+type UtxoSnapshot struct {
+	Height      int32
+	BlockHash   *chainhash.Hash
+	ContentHash *chainhash.Hash
+}
+
+// EHFDeployment defines details related to a specific consensus rule change
+// which is enabled by a masternode hard fork signal as defined by DIP0023.
+// Once a quorum has signaled the bit of the deployment, miners vote on it with
+// a threshold which decreases with every window it has not been reached.
+type EHFDeployment struct {
+	// BitNumber defines the specific bit number within the block version
+	// which miners set to vote on the deployment.  It is also the version
+	// bit which masternode quorums signal.
+	BitNumber uint8
+
+	// StartTime is the median block time after which signals for the
+	// deployment are accepted.
+	StartTime uint64
+
+	// ExpireTime is the median block time after which the attempted
+	// deployment expires.
+	ExpireTime uint64
+
+	// MinerConfirmationWindow is the number of blocks in each voting
+	// window.
+	MinerConfirmationWindow uint32
+
+	// ThresholdStart is the number of votes required to lock in the
+	// deployment during the first voting window.
+	ThresholdStart uint32
+
+	// ThresholdMin is the number of votes below which the threshold never
+	// falls.
+	ThresholdMin uint32
+
+	// FalloffCoeff controls how quickly the threshold falls from
+	// ThresholdStart to ThresholdMin.
+	FalloffCoeff uint32
+}
+
+// Constants that define the EHF deployment offset in the EHF deployments field
+// of the parameters for each deployment.  This is useful to be able to get the
+// details of a specific deployment by name.
+const (
+	// EHFDeploymentMnRR defines the rule change deployment ID for the
+	// masternode reward reallocation of the v20 hard fork.
+	EHFDeploymentMnRR = iota
+
+	// DefinedEHFDeployments is the number of currently defined EHF
+	// deployments.  It must always come last since it is used to
+	// determine how many defined deployments there currently are.
+	DefinedEHFDeployments
+)
+
 // Params defines a Bitcoin network by its parameters.  These parameters may be
 // used by Bitcoin applications to differentiate networks as well as addresses
 // and keys for one network from those intended for use on another network.
@@ -75,9 +140,47 @@ type Params struct {
 	BIP0034Height int32
 	BIP0065Height int32
 	BIP0066Height int32
+
+	// BudgetPaymentsStartHeight is the block height after which a share of
+	// the block subsidy is set aside for the superblocks paying the
+	// treasury.
+	BudgetPaymentsStartHeight int32
+
+	// V20Height is the block height at which the v20 hard fork activates.
+	// It enables the asset lock and asset unlock special transactions and
+	// the credit pool of DIP0027.
+	V20Height int32
+
+	// LLMQTypePlatform is the type of the LLMQ whose quorums sign asset
+	// unlock transactions on behalf of Platform.
+	LLMQTypePlatform uint8
+
+	// LLMQTypeMnhf is the type of the LLMQ whose quorums sign masternode
+	// hard fork signals.
+	LLMQTypeMnhf uint8
+
+	// EHFDeployments define the specific consensus rule changes which are
+	// enabled by masternode hard fork signals.
+	EHFDeployments [DefinedEHFDeployments]EHFDeployment
+
+	// CoinJoinMinPoolSize and CoinJoinMaxPoolSize define the
+	// number of participants a CoinJoin mixing session may have.
+	CoinJoinMinPoolSize int
+	CoinJoinMaxPoolSize int
+
 	// Checkpoints ordered from oldest to newest.
 	Checkpoints []Checkpoint
 
+	// AssumeValid is the hash of the block whose ancestors are assumed to
+	// have valid scripts by default once it is part of the best known
+	// header chain with enough work on top of it.  The zero hash causes the
+	// scripts of all blocks to be checked.
+	AssumeValid chainhash.Hash
+
+	// UtxoSnapshots are the snapshots of the utxo set nodes may be
+	// bootstrapped from ordered from oldest to newest.
+	UtxoSnapshots []UtxoSnapshot
+
 	// Enforce current block version once network has
 	// upgraded.  This is part of BIP0034.
 	BlockEnforceNumRequired uint64
@@ -92,6 +195,10 @@ type Params struct {
 	// Mempool parameters
 	RelayNonStdTxs bool
 
+	// MessageMagic is the prefix which is hashed together with a message
+	// when it is signed or verified with the key of an address.
+	MessageMagic string
+
 	// Address encoding magics
 	PubKeyHashAddrID byte // First byte of a P2PKH address
 	ScriptHashAddrID byte // First byte of a P2SH address
@@ -122,13 +229,30 @@ var MainNetParams = Params{
 	},
 
 	// Chain parameters
-	GenesisBlock:           &genesisBlock,
-	GenesisHash:            &genesisHash,
-	PowLimit:               mainPowLimit,
-	PowLimitBits:           0x1d00ffff,
-	SubsidyHalvingInterval: 210240,
-	ResetMinDifficulty:     false,
-	GenerateSupported:      false,
+	GenesisBlock:              &genesisBlock,
+	GenesisHash:               &genesisHash,
+	PowLimit:                  mainPowLimit,
+	PowLimitBits:              0x1d00ffff,
+	SubsidyHalvingInterval:    210240,
+	ResetMinDifficulty:        false,
+	GenerateSupported:         false,
+	BudgetPaymentsStartHeight: 328008,
+	V20Height:                 1987776,
+	LLMQTypePlatform:          4,
+	LLMQTypeMnhf:              3,
+	CoinJoinMinPoolSize:       3,
+	CoinJoinMaxPoolSize:       20,
+	EHFDeployments: [DefinedEHFDeployments]EHFDeployment{
+		EHFDeploymentMnRR: {
+			BitNumber:               10,
+			StartTime:               1702382400,    // December 12th, 2023
+			ExpireTime:              math.MaxInt64, // Never expires
+			MinerConfirmationWindow: 4032,
+			ThresholdStart:          3226,
+			ThresholdMin:            2420,
+			FalloffCoeff:            5,
+		},
+	},
 
 	// Checkpoints ordered from oldest to newest.
 	Checkpoints: []Checkpoint{
@@ -159,6 +283,9 @@ var MainNetParams = Params{
 		{1167570, newShaHashFromStr("000000000000000fb7b1e9b81700283dff0f7d87cf458e5edfdae00c669de661")},
 	},
 
+	// The block of the latest checkpoint.
+	AssumeValid: *newShaHashFromStr("000000000000000fb7b1e9b81700283dff0f7d87cf458e5edfdae00c669de661"),
+
 	// Enforce current block version once majority of the network has
 	// upgraded.
 	// 75% (750 / 1000)
@@ -172,6 +299,9 @@ var MainNetParams = Params{
 	// Mempool parameters
 	RelayNonStdTxs: false,
 
+	// Message signing magic
+	MessageMagic: "DarkCoin Signed Message:\n",
+
 	// Address encoding magics
 	PubKeyHashAddrID: 0x4c, // starts with X
 	ScriptHashAddrID: 0x10, // starts with 7
@@ -196,13 +326,30 @@ var RegressionNetParams = Params{
 	DNSSeeds:    []string{},
 
 	// Chain parameters
-	GenesisBlock:           &regTestGenesisBlock,
-	GenesisHash:            &regTestGenesisHash,
-	PowLimit:               regressionPowLimit,
-	PowLimitBits:           0x207fffff,
-	SubsidyHalvingInterval: 150,
-	ResetMinDifficulty:     true,
-	GenerateSupported:      true,
+	GenesisBlock:              &regTestGenesisBlock,
+	GenesisHash:               &regTestGenesisHash,
+	PowLimit:                  regressionPowLimit,
+	PowLimitBits:              0x207fffff,
+	SubsidyHalvingInterval:    150,
+	ResetMinDifficulty:        true,
+	GenerateSupported:         true,
+	BudgetPaymentsStartHeight: 1000,
+	V20Height:                 1,
+	LLMQTypePlatform:          106,
+	LLMQTypeMnhf:              100,
+	CoinJoinMinPoolSize:       2,
+	CoinJoinMaxPoolSize:       20,
+	EHFDeployments: [DefinedEHFDeployments]EHFDeployment{
+		EHFDeploymentMnRR: {
+			BitNumber:               10,
+			StartTime:               0,             // Always available for vote
+			ExpireTime:              math.MaxInt64, // Never expires
+			MinerConfirmationWindow: 12,
+			ThresholdStart:          9,
+			ThresholdMin:            7,
+			FalloffCoeff:            5,
+		},
+	},
 
 	// Checkpoints ordered from oldest to newest.
 	Checkpoints: nil,
@@ -220,6 +367,9 @@ var RegressionNetParams = Params{
 	// Mempool parameters
 	RelayNonStdTxs: true,
 
+	// Message signing magic
+	MessageMagic: "DarkCoin Signed Message:\n",
+
 	// Address encoding magics
 	PubKeyHashAddrID: 0x6f, // starts with m or n
 	ScriptHashAddrID: 0xc4, // starts with 2
@@ -248,13 +398,30 @@ var TestNet3Params = Params{
 	},
 
 	// Chain parameters
-	GenesisBlock:           &testNet3GenesisBlock,
-	GenesisHash:            &testNet3GenesisHash,
-	PowLimit:               testNet3PowLimit,
-	PowLimitBits:           0x1d00ffff,
-	SubsidyHalvingInterval: 210240,
-	ResetMinDifficulty:     true,
-	GenerateSupported:      false,
+	GenesisBlock:              &testNet3GenesisBlock,
+	GenesisHash:               &testNet3GenesisHash,
+	PowLimit:                  testNet3PowLimit,
+	PowLimitBits:              0x1d00ffff,
+	SubsidyHalvingInterval:    210240,
+	ResetMinDifficulty:        true,
+	GenerateSupported:         false,
+	BudgetPaymentsStartHeight: 4100,
+	V20Height:                 905100,
+	LLMQTypePlatform:          6,
+	LLMQTypeMnhf:              1,
+	CoinJoinMinPoolSize:       2,
+	CoinJoinMaxPoolSize:       20,
+	EHFDeployments: [DefinedEHFDeployments]EHFDeployment{
+		EHFDeploymentMnRR: {
+			BitNumber:               10,
+			StartTime:               1693526400,    // September 1st, 2023
+			ExpireTime:              math.MaxInt64, // Never expires
+			MinerConfirmationWindow: 100,
+			ThresholdStart:          80,
+			ThresholdMin:            60,
+			FalloffCoeff:            5,
+		},
+	},
 
 	// Checkpoints ordered from oldest to newest.
 	Checkpoints: []Checkpoint{
@@ -265,6 +432,9 @@ var TestNet3Params = Params{
 		{200000, newShaHashFromStr("000000001015eb5ef86a8fe2b3074d947bc972c5befe32b28dd5ce915dc0d029")},
 	},
 
+	// The block of the latest checkpoint.
+	AssumeValid: *newShaHashFromStr("000000001015eb5ef86a8fe2b3074d947bc972c5befe32b28dd5ce915dc0d029"),
+
 	// Enforce current block version once majority of the network has
 	// upgraded.
 	// 51% (51 / 100)
@@ -278,6 +448,9 @@ var TestNet3Params = Params{
 	// Mempool parameters
 	RelayNonStdTxs: true,
 
+	// Message signing magic
+	MessageMagic: "DarkCoin Signed Message:\n",
+
 	// Address encoding magics
 	PubKeyHashAddrID: 0x8c, // Testnet Dash addresses start with 'y'
 	ScriptHashAddrID: 0x13, // Testnet Dash script addresses start with '8' or '9'
@@ -306,13 +479,30 @@ var SimNetParams = Params{
 	DNSSeeds:    []string{}, // NOTE: There must NOT be any seeds.
 
 	// Chain parameters
-	GenesisBlock:           &simNetGenesisBlock,
-	GenesisHash:            &simNetGenesisHash,
-	PowLimit:               simNetPowLimit,
-	PowLimitBits:           0x207fffff,
-	SubsidyHalvingInterval: 210000,
-	ResetMinDifficulty:     true,
-	GenerateSupported:      true,
+	GenesisBlock:              &simNetGenesisBlock,
+	GenesisHash:               &simNetGenesisHash,
+	PowLimit:                  simNetPowLimit,
+	PowLimitBits:              0x207fffff,
+	SubsidyHalvingInterval:    210000,
+	ResetMinDifficulty:        true,
+	GenerateSupported:         true,
+	BudgetPaymentsStartHeight: 1000,
+	V20Height:                 1,
+	LLMQTypePlatform:          106,
+	LLMQTypeMnhf:              100,
+	CoinJoinMinPoolSize:       2,
+	CoinJoinMaxPoolSize:       20,
+	EHFDeployments: [DefinedEHFDeployments]EHFDeployment{
+		EHFDeploymentMnRR: {
+			BitNumber:               10,
+			StartTime:               0,             // Always available for vote
+			ExpireTime:              math.MaxInt64, // Never expires
+			MinerConfirmationWindow: 12,
+			ThresholdStart:          9,
+			ThresholdMin:            7,
+			FalloffCoeff:            5,
+		},
+	},
 
 	// Checkpoints ordered from oldest to newest.
 	Checkpoints: nil,
@@ -330,6 +520,9 @@ var SimNetParams = Params{
 	// Mempool parameters
 	RelayNonStdTxs: true,
 
+	// Message signing magic
+	MessageMagic: "DarkCoin Signed Message:\n",
+
 	// Address encoding magics
 	PubKeyHashAddrID: 0x3f, // starts with S
 	ScriptHashAddrID: 0x7b, // starts with s
@@ -456,4 +649,4 @@ func init() {
 	mustRegister(&TestNet3Params)
 	mustRegister(&RegressionNetParams)
 	mustRegister(&SimNetParams)
-}
\ No newline at end of file
+}
@@ -0,0 +1,90 @@
+// Copyright (c) 2013-2016 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package coinjoin
+
+import (
+	"github.com/eager7/dashd/chaincfg"
+	"github.com/eager7/dashd/txscript"
+	"github.com/eager7/dashd/wire"
+	"github.com/eager7/dashutil"
+)
+
+// EntryMaxSize is the maximum number of inputs a single participant may add
+// to a CoinJoin mixing session.
+const EntryMaxSize = 9
+
+// standardDenominations are the amounts CoinJoin mixes, ordered from largest
+// to smallest.  Each is a power of ten of a coin plus a small marker amount
+// that makes the denominations recognizable.
+var standardDenominations = []dashutil.Amount{
+	10*dashutil.SatoshiPerBitcoin + 10000,
+	dashutil.SatoshiPerBitcoin + 1000,
+	dashutil.SatoshiPerBitcoin/10 + 100,
+	dashutil.SatoshiPerBitcoin/100 + 10,
+	dashutil.SatoshiPerBitcoin/1000 + 1,
+}
+
+// IsDenominatedAmount returns whether or not the passed amount is one of the
+// standard CoinJoin denominations.
+func IsDenominatedAmount(amount dashutil.Amount) bool {
+	return AmountToDenomination(amount) != 0
+}
+
+// AmountToDenomination returns the denomination bit of the passed amount, or
+// zero when it is not one of the standard CoinJoin denominations.
+func AmountToDenomination(amount dashutil.Amount) int32 {
+	for i, denom := range standardDenominations {
+		if amount == denom {
+			return 1 << uint(i)
+		}
+	}
+	return 0
+}
+
+// DenominationToAmount returns the amount of the passed denomination bit, or
+// zero when it does not identify exactly one of the standard CoinJoin
+// denominations.
+func DenominationToAmount(denom int32) dashutil.Amount {
+	for i, amount := range standardDenominations {
+		if denom == 1<<uint(i) {
+			return amount
+		}
+	}
+	return 0
+}
+
+// IsValidDenomination returns whether or not the passed denomination bit
+// identifies one of the standard CoinJoin denominations.
+func IsValidDenomination(denom int32) bool {
+	return DenominationToAmount(denom) != 0
+}
+
+// IsValidMixingTx returns whether or not the passed transaction has the
+// structure of a CoinJoin mixing transaction.  That is, it has as many outputs
+// as inputs, the number of inputs is within the bounds of a mixing session on
+// the network, and every output pays one of the standard denominations to a
+// pay-to-pubkey-hash script.
+//
+// Only the structure is checked.  The inputs are not looked up, so it is not
+// known whether they spend denominated outputs.
+func IsValidMixingTx(tx *wire.MsgTx, params *chaincfg.Params) bool {
+	if tx.IsSpecial() || len(tx.TxIn) != len(tx.TxOut) {
+		return false
+	}
+	if len(tx.TxIn) < params.CoinJoinMinPoolSize ||
+		len(tx.TxIn) > params.CoinJoinMaxPoolSize*EntryMaxSize {
+		return false
+	}
+
+	for _, txOut := range tx.TxOut {
+		if !IsDenominatedAmount(dashutil.Amount(txOut.Value)) {
+			return false
+		}
+		if txscript.GetScriptClass(txOut.PkScript) != txscript.PubKeyHashTy {
+			return false
+		}
+	}
+	return true
+}
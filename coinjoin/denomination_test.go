@@ -0,0 +1,121 @@
+// Copyright (c) 2013-2016 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package coinjoin
+
+import (
+	"testing"
+
+	"github.com/eager7/dashd/chaincfg"
+	"github.com/eager7/dashd/wire"
+	"github.com/eager7/dashutil"
+)
+
+// TestDenominations ensures the conversions between amounts and denomination
+// bits work as expected.
+func TestDenominations(t *testing.T) {
+	tests := []struct {
+		amount dashutil.Amount
+		denom  int32
+	}{
+		{1000010000, 1},
+		{100001000, 2},
+		{10000100, 4},
+		{1000010, 8},
+		{100001, 16},
+		{100000000, 0},
+		{100001001, 0},
+		{0, 0},
+	}
+
+	for _, test := range tests {
+		denom := AmountToDenomination(test.amount)
+		if denom != test.denom {
+			t.Errorf("AmountToDenomination(%d): got %d, want %d",
+				test.amount, denom, test.denom)
+			continue
+		}
+		if IsDenominatedAmount(test.amount) != (test.denom != 0) {
+			t.Errorf("IsDenominatedAmount(%d): unexpected result",
+				test.amount)
+			continue
+		}
+		if test.denom == 0 {
+			continue
+		}
+		if amount := DenominationToAmount(test.denom); amount != test.amount {
+			t.Errorf("DenominationToAmount(%d): got %d, want %d",
+				test.denom, amount, test.amount)
+		}
+	}
+
+	// Combinations of denomination bits and bits past the smallest
+	// denomination are not valid.
+	for _, denom := range []int32{0, 3, 31, 32, -1} {
+		if IsValidDenomination(denom) {
+			t.Errorf("IsValidDenomination(%d): got true, want false",
+				denom)
+		}
+	}
+}
+
+// newMixingTx returns a transaction with the given number of inputs which each
+// pay the passed amount to a pay-to-pubkey-hash output.
+func newMixingTx(numInputs int, amount int64) *wire.MsgTx {
+	p2pkh := []byte{
+		0x76, 0xa9, 0x14, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x88, 0xac,
+	}
+
+	tx := wire.NewMsgTx(wire.TxVersion)
+	for i := 0; i < numInputs; i++ {
+		prevOut := wire.OutPoint{Index: uint32(i)}
+		tx.AddTxIn(wire.NewTxIn(&prevOut, nil, nil))
+		tx.AddTxOut(wire.NewTxOut(amount, p2pkh))
+	}
+	return tx
+}
+
+// TestIsValidMixingTx ensures the structure of mixing transactions is checked
+// as expected.
+func TestIsValidMixingTx(t *testing.T) {
+	params := &chaincfg.MainNetParams
+	maxInputs := params.CoinJoinMaxPoolSize * EntryMaxSize
+
+	tooFewOutputs := newMixingTx(3, 100001)
+	tooFewOutputs.TxOut = tooFewOutputs.TxOut[1:]
+
+	p2sh := newMixingTx(3, 100001)
+	p2sh.TxOut[2].PkScript = []byte{
+		0xa9, 0x14, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x87,
+	}
+
+	mixedDenoms := newMixingTx(3, 100001)
+	mixedDenoms.TxOut[0].Value = 1000010
+
+	tests := []struct {
+		name  string
+		tx    *wire.MsgTx
+		valid bool
+	}{
+		{"minimum participants", newMixingTx(3, 100001), true},
+		{"maximum inputs", newMixingTx(maxInputs, 1000010000), true},
+		{"mixed denominations", mixedDenoms, true},
+		{"too few participants", newMixingTx(2, 100001), false},
+		{"too many inputs", newMixingTx(maxInputs+1, 100001), false},
+		{"non-denominated output", newMixingTx(3, 100000), false},
+		{"fewer outputs than inputs", tooFewOutputs, false},
+		{"non pay-to-pubkey-hash output", p2sh, false},
+	}
+
+	for _, test := range tests {
+		if got := IsValidMixingTx(test.tx, params); got != test.valid {
+			t.Errorf("IsValidMixingTx (%s): got %v, want %v",
+				test.name, got, test.valid)
+		}
+	}
+}
@@ -0,0 +1,23 @@
+// Copyright (c) 2013-2016 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+/*
+Package coinjoin implements the relay side of Dash CoinJoin mixing.
+
+CoinJoin sessions are coordinated by masternodes.  A masternode announces that
+it is accepting participants for a denomination with a signed queue (dsq)
+message and, once the session completes, signs the final mixing transaction and
+broadcasts it in a dstx message.  Nodes which are not mixing themselves relay
+both so that CoinJoin clients can find sessions and see the results.
+
+This package provides a Manager which validates these messages against the
+deterministic masternode list, keeps a rate-limited cache of the active queues
+and remembers the broadcast transactions so they can be served to peers which
+request them.  It also provides helpers to recognize the standard CoinJoin
+denominations and the structure of mixing transactions.
+
+The deterministic masternode list itself is not maintained by this package.  It
+is accessed through the MasternodeList interface, which the caller provides.
+*/
+package coinjoin
@@ -0,0 +1,96 @@
+// Copyright (c) 2014-2016 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package coinjoin
+
+import (
+	"fmt"
+)
+
+// ErrorCode identifies a kind of error.
+type ErrorCode int
+
+// These constants are used to identify a specific RuleError.
+const (
+	// ErrInvalidDenomination indicates a queue is for a denomination that
+	// is not one of the standard CoinJoin denominations.
+	ErrInvalidDenomination ErrorCode = iota
+
+	// ErrQueueTimeOutOfBounds indicates the time of a queue is too far
+	// from the current time.
+	ErrQueueTimeOutOfBounds
+
+	// ErrDuplicateQueue indicates the masternode already has a queue with
+	// the same readiness in the cache.
+	ErrDuplicateQueue
+
+	// ErrQueueRateLimited indicates the masternode announced queues more
+	// often than allowed.
+	ErrQueueRateLimited
+
+	// ErrUnknownMasternode indicates the masternode that signed a message
+	// is not in the deterministic masternode list.
+	ErrUnknownMasternode
+
+	// ErrBadSignature indicates the masternode signature of a message is
+	// invalid.
+	ErrBadSignature
+
+	// ErrInvalidStructure indicates a broadcast transaction is not a valid
+	// CoinJoin mixing transaction.
+	ErrInvalidStructure
+
+	// ErrDuplicateDSTx indicates a broadcast transaction is already known.
+	ErrDuplicateDSTx
+
+	// ErrTooManyMixingTxs indicates the masternode broadcast more mixing
+	// transactions than allowed without announcing a new queue.
+	ErrTooManyMixingTxs
+
+	// ErrNoMasternodeList indicates there is no masternode list to check
+	// the signatures of messages against.
+	ErrNoMasternodeList
+)
+
+// Map of ErrorCode values back to their constant names for pretty printing.
+var errorCodeStrings = map[ErrorCode]string{
+	ErrInvalidDenomination:  "ErrInvalidDenomination",
+	ErrQueueTimeOutOfBounds: "ErrQueueTimeOutOfBounds",
+	ErrDuplicateQueue:       "ErrDuplicateQueue",
+	ErrQueueRateLimited:     "ErrQueueRateLimited",
+	ErrUnknownMasternode:    "ErrUnknownMasternode",
+	ErrBadSignature:         "ErrBadSignature",
+	ErrInvalidStructure:     "ErrInvalidStructure",
+	ErrDuplicateDSTx:        "ErrDuplicateDSTx",
+	ErrTooManyMixingTxs:     "ErrTooManyMixingTxs",
+	ErrNoMasternodeList:     "ErrNoMasternodeList",
+}
+
+// String returns the ErrorCode as a human-readable name.
+func (e ErrorCode) String() string {
+	if s := errorCodeStrings[e]; s != "" {
+		return s
+	}
+	return fmt.Sprintf("Unknown ErrorCode (%d)", int(e))
+}
+
+// RuleError identifies a rule violation.  It is used to indicate that
+// processing of a CoinJoin message failed.  The caller can use type
+// assertions to determine if a failure was specifically due to a rule
+// violation and access the ErrorCode field to ascertain the specific reason
+// for the rule violation.
+type RuleError struct {
+	ErrorCode   ErrorCode // Describes the kind of error
+	Description string    // Human readable description of the issue
+}
+
+// Error satisfies the error interface and prints human-readable errors.
+func (e RuleError) Error() string {
+	return e.Description
+}
+
+// ruleError creates an RuleError given a set of arguments.
+func ruleError(c ErrorCode, desc string) RuleError {
+	return RuleError{ErrorCode: c, Description: desc}
+}
@@ -0,0 +1,402 @@
+// Copyright (c) 2013-2016 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package coinjoin
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/eager7/dashd/blockchain"
+	"github.com/eager7/dashd/chaincfg"
+	"github.com/eager7/dashd/chaincfg/chainhash"
+	"github.com/eager7/dashd/wire"
+	"github.com/eager7/dashutil"
+)
+
+const (
+	// QueueTimeout is the maximum amount of time the time of a queue may
+	// differ from the current time for it to be considered active.
+	QueueTimeout = 30 * time.Second
+
+	// maxMixingTxs is the maximum number of mixing transactions a
+	// masternode may broadcast without announcing a new queue.
+	maxMixingTxs = 5
+
+	// dstxMasternodeLookback is the number of blocks before the current
+	// best chain tip whose masternode lists are searched for the
+	// masternode that signed a broadcast transaction.  This allows the
+	// transactions of masternodes which were removed from the list after
+	// the mixing session to still be relayed.
+	dstxMasternodeLookback = 24
+
+	// dstxExpiryDepth is the number of blocks a broadcast transaction is
+	// remembered for after it was mined.
+	dstxExpiryDepth = 24
+)
+
+// MasternodeList provides access to the deterministic masternode list that
+// the signatures of CoinJoin messages are checked against.
+type MasternodeList interface {
+	// ValidMasternodeCount returns the number of valid masternodes in the
+	// list as of the current best chain tip.
+	ValidMasternodeCount() int
+
+	// LookupMasternode returns the ProRegTx hash of the valid masternode
+	// with the passed collateral outpoint or, when the outpoint is null,
+	// the passed ProRegTx hash.  The list as of the current best chain tip
+	// is searched first, followed by the lists as of up to lookback of its
+	// ancestors.  The boolean is false when no masternode is found.
+	LookupMasternode(collateral *wire.OutPoint, proTxHash *chainhash.Hash,
+		lookback int32) (chainhash.Hash, bool)
+
+	// VerifyOperatorSig returns whether or not sig is a valid signature of
+	// hash by the operator key of the masternode with the passed ProRegTx
+	// hash.
+	VerifyOperatorSig(proTxHash, hash *chainhash.Hash, sig []byte) bool
+}
+
+// Config is a descriptor containing the CoinJoin manager configuration.
+type Config struct {
+	// ChainParams identifies which chain parameters the manager is
+	// associated with.
+	ChainParams *chaincfg.Params
+
+	// TimeSource defines the median time source used to decide whether
+	// queues are active.
+	TimeSource blockchain.MedianTimeSource
+
+	// MasternodeList defines the deterministic masternode list used to
+	// check the signatures of queues and broadcast transactions.
+	//
+	// This field can be nil in which case all queues and broadcast
+	// transactions are rejected since they can't be verified.
+	MasternodeList MasternodeList
+}
+
+// masternodeMeta houses the CoinJoin related state of a masternode.
+type masternodeMeta struct {
+	// lastDsq is the value of the queue counter when the masternode last
+	// announced a queue that was accepted.
+	lastDsq int64
+
+	// mixingTxs is the number of mixing transactions the masternode has
+	// broadcast since then.
+	mixingTxs int
+}
+
+// dstxEntry houses a broadcast transaction along with the height of the block
+// it was mined in, or -1 when it has not been mined yet.
+type dstxEntry struct {
+	msg             *wire.MsgDSTx
+	confirmedHeight int32
+}
+
+// Manager validates and caches the CoinJoin queues and broadcast transactions
+// relayed by the node.
+type Manager struct {
+	cfg Config
+
+	mtx      sync.Mutex
+	queues   []*wire.MsgDSQueue
+	dsqCount int64
+	meta     map[chainhash.Hash]*masternodeMeta
+	dstxs    map[chainhash.Hash]*dstxEntry
+}
+
+// metaInfo returns the CoinJoin related state of the masternode with the
+// passed ProRegTx hash, creating it when needed.
+//
+// This function MUST be called with the manager lock held.
+func (m *Manager) metaInfo(proTxHash *chainhash.Hash) *masternodeMeta {
+	meta, ok := m.meta[*proTxHash]
+	if !ok {
+		meta = &masternodeMeta{}
+		m.meta[*proTxHash] = meta
+	}
+	return meta
+}
+
+// isTimeOutOfBounds returns whether or not the passed queue time differs from
+// the current adjusted time by more than the queue timeout.
+func (m *Manager) isTimeOutOfBounds(queueTime int64) bool {
+	now := m.cfg.TimeSource.AdjustedTime().Unix()
+	timeout := int64(QueueTimeout / time.Second)
+	return now-queueTime > timeout || queueTime-now > timeout
+}
+
+// expireQueues removes the queues which are no longer active from the cache.
+//
+// This function MUST be called with the manager lock held.
+func (m *Manager) expireQueues() {
+	queues := m.queues[:0]
+	for _, q := range m.queues {
+		if !m.isTimeOutOfBounds(q.Time) {
+			queues = append(queues, q)
+		}
+	}
+	for i := len(queues); i < len(m.queues); i++ {
+		m.queues[i] = nil
+	}
+	m.queues = queues
+}
+
+// lookupMasternode returns the ProRegTx hash of the masternode identified by
+// the passed collateral outpoint or ProRegTx hash.
+func (m *Manager) lookupMasternode(collateral *wire.OutPoint, proTxHash *chainhash.Hash, lookback int32) (chainhash.Hash, error) {
+	if m.cfg.MasternodeList == nil {
+		str := "no masternode list to verify signatures against"
+		return chainhash.Hash{}, ruleError(ErrNoMasternodeList, str)
+	}
+
+	hash, ok := m.cfg.MasternodeList.LookupMasternode(collateral,
+		proTxHash, lookback)
+	if !ok {
+		str := fmt.Sprintf("masternode %v (collateral %v) is not in "+
+			"the masternode list", proTxHash, collateral)
+		return chainhash.Hash{}, ruleError(ErrUnknownMasternode, str)
+	}
+	return hash, nil
+}
+
+// ProcessQueue validates the passed queue and adds it to the cache.  A nil
+// error means the queue was accepted and should be relayed to the peers which
+// requested queues.
+//
+// The queue must be for a standard denomination, its time must be within
+// QueueTimeout of the current time, and it must be signed by a masternode in
+// the deterministic masternode list which has no other queue of the same
+// readiness in the cache.  A masternode may only announce a queue which is
+// not ready after at least a fifth of the valid masternodes have announced
+// one since its previous queue.
+//
+// The ProTxHash of the queue is filled in from the masternode list when it is
+// not set, which is the case for queues received from peers using protocol
+// versions prior to wire.CoinJoinProTxHashVersion.
+//
+// This function is safe for concurrent access.
+func (m *Manager) ProcessQueue(dsq *wire.MsgDSQueue) error {
+	if !IsValidDenomination(dsq.Denomination) {
+		str := fmt.Sprintf("queue denomination %d is not a standard "+
+			"denomination", dsq.Denomination)
+		return ruleError(ErrInvalidDenomination, str)
+	}
+
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	m.expireQueues()
+	if m.isTimeOutOfBounds(dsq.Time) {
+		str := fmt.Sprintf("queue time %v is out of bounds",
+			time.Unix(dsq.Time, 0))
+		return ruleError(ErrQueueTimeOutOfBounds, str)
+	}
+
+	proTxHash, err := m.lookupMasternode(&dsq.MasternodeOutPoint,
+		&dsq.ProTxHash, 0)
+	if err != nil {
+		return err
+	}
+	dsq.ProTxHash = proTxHash
+
+	for _, q := range m.queues {
+		if q.ProTxHash == proTxHash && q.Ready == dsq.Ready {
+			str := fmt.Sprintf("masternode %v already has a queue "+
+				"(ready %v)", proTxHash, dsq.Ready)
+			return ruleError(ErrDuplicateQueue, str)
+		}
+	}
+
+	sigHash := dsq.SignatureHash()
+	if !m.cfg.MasternodeList.VerifyOperatorSig(&proTxHash, &sigHash,
+		dsq.Sig) {
+
+		str := fmt.Sprintf("invalid signature of queue from masternode "+
+			"%v", proTxHash)
+		return ruleError(ErrBadSignature, str)
+	}
+
+	if !dsq.Ready {
+		meta := m.metaInfo(&proTxHash)
+		mnCount := m.cfg.MasternodeList.ValidMasternodeCount()
+		threshold := meta.lastDsq + int64(mnCount/5)
+		if meta.lastDsq != 0 && threshold > m.dsqCount {
+			str := fmt.Sprintf("masternode %v is sending too many "+
+				"queues", proTxHash)
+			return ruleError(ErrQueueRateLimited, str)
+		}
+
+		// Announcing a new queue allows the masternode to broadcast
+		// mixing transactions again.
+		m.dsqCount++
+		meta.lastDsq = m.dsqCount
+		meta.mixingTxs = 0
+	}
+
+	m.queues = append(m.queues, dsq)
+	log.Debugf("Accepted queue %v from masternode %v", dsq.SignatureHash(),
+		proTxHash)
+	return nil
+}
+
+// Queues returns the active queues in the cache.
+//
+// This function is safe for concurrent access.
+func (m *Manager) Queues() []*wire.MsgDSQueue {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	m.expireQueues()
+	queues := make([]*wire.MsgDSQueue, len(m.queues))
+	copy(queues, m.queues)
+	return queues
+}
+
+// CheckDSTx validates the passed broadcast transaction.  A nil error means the
+// transaction may be accepted to the memory pool with the CoinJoin policy and
+// then added to the manager with AddDSTx.
+//
+// The transaction must have the structure of a mixing transaction and be
+// signed by a masternode in the deterministic masternode list as of the
+// current best chain tip or one of its recent ancestors.  A masternode may
+// only broadcast a limited number of mixing transactions per queue it
+// announces.
+//
+// The ProTxHash of the broadcast transaction is filled in from the masternode
+// list when it is not set.
+//
+// This function is safe for concurrent access.
+func (m *Manager) CheckDSTx(dstx *wire.MsgDSTx) error {
+	txHash := dstx.Tx.TxHash()
+	if !IsValidMixingTx(&dstx.Tx, m.cfg.ChainParams) {
+		str := fmt.Sprintf("transaction %v is not a valid mixing "+
+			"transaction", txHash)
+		return ruleError(ErrInvalidStructure, str)
+	}
+
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	if _, ok := m.dstxs[txHash]; ok {
+		str := fmt.Sprintf("already have mixing transaction %v", txHash)
+		return ruleError(ErrDuplicateDSTx, str)
+	}
+
+	proTxHash, err := m.lookupMasternode(&dstx.MasternodeOutPoint,
+		&dstx.ProTxHash, dstxMasternodeLookback)
+	if err != nil {
+		return err
+	}
+	dstx.ProTxHash = proTxHash
+
+	meta := m.metaInfo(&proTxHash)
+	if meta.mixingTxs >= maxMixingTxs {
+		str := fmt.Sprintf("masternode %v is sending too many mixing "+
+			"transactions", proTxHash)
+		return ruleError(ErrTooManyMixingTxs, str)
+	}
+
+	sigHash := dstx.SignatureHash()
+	if !m.cfg.MasternodeList.VerifyOperatorSig(&proTxHash, &sigHash,
+		dstx.Sig) {
+
+		str := fmt.Sprintf("invalid signature of mixing transaction %v "+
+			"from masternode %v", txHash, proTxHash)
+		return ruleError(ErrBadSignature, str)
+	}
+
+	meta.mixingTxs++
+	return nil
+}
+
+// AddDSTx adds the passed broadcast transaction to the manager so it can be
+// served to peers which request it.
+//
+// This function is safe for concurrent access.
+func (m *Manager) AddDSTx(dstx *wire.MsgDSTx) {
+	m.mtx.Lock()
+	m.dstxs[dstx.Tx.TxHash()] = &dstxEntry{
+		msg:             dstx,
+		confirmedHeight: -1,
+	}
+	m.mtx.Unlock()
+}
+
+// DSTx returns the broadcast transaction for the transaction with the passed
+// hash, or nil when it is not known.
+//
+// This function is safe for concurrent access.
+func (m *Manager) DSTx(hash *chainhash.Hash) *wire.MsgDSTx {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	entry, ok := m.dstxs[*hash]
+	if !ok {
+		return nil
+	}
+	return entry.msg
+}
+
+// HaveDSTx returns whether or not the transaction with the passed hash is a
+// known broadcast transaction.
+//
+// This function is safe for concurrent access.
+func (m *Manager) HaveDSTx(hash *chainhash.Hash) bool {
+	m.mtx.Lock()
+	_, ok := m.dstxs[*hash]
+	m.mtx.Unlock()
+
+	return ok
+}
+
+// BlockConnected records the height the broadcast transactions in the passed
+// block were mined at and removes the transactions which were mined more than
+// dstxExpiryDepth blocks ago.
+//
+// This function is safe for concurrent access.
+func (m *Manager) BlockConnected(block *dashutil.Block) {
+	height := block.Height()
+
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	for _, tx := range block.Transactions() {
+		if entry, ok := m.dstxs[*tx.Hash()]; ok {
+			entry.confirmedHeight = height
+		}
+	}
+
+	for hash, entry := range m.dstxs {
+		if entry.confirmedHeight != -1 &&
+			height-entry.confirmedHeight > dstxExpiryDepth {
+
+			delete(m.dstxs, hash)
+		}
+	}
+}
+
+// BlockDisconnected marks the broadcast transactions in the passed block as
+// no longer mined.
+//
+// This function is safe for concurrent access.
+func (m *Manager) BlockDisconnected(block *dashutil.Block) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	for _, tx := range block.Transactions() {
+		if entry, ok := m.dstxs[*tx.Hash()]; ok {
+			entry.confirmedHeight = -1
+		}
+	}
+}
+
+// New returns a new CoinJoin manager using the provided configuration.
+func New(cfg *Config) *Manager {
+	return &Manager{
+		cfg:   *cfg,
+		meta:  make(map[chainhash.Hash]*masternodeMeta),
+		dstxs: make(map[chainhash.Hash]*dstxEntry),
+	}
+}
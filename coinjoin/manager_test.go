@@ -0,0 +1,242 @@
+// Copyright (c) 2013-2016 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package coinjoin
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/eager7/dashd/blockchain"
+	"github.com/eager7/dashd/chaincfg"
+	"github.com/eager7/dashd/chaincfg/chainhash"
+	"github.com/eager7/dashd/wire"
+)
+
+// fakeMasternodeList provides a masternode list for testing which considers a
+// signature valid when it is the signed hash prefixed with the ProRegTx hash
+// of the masternode.
+type fakeMasternodeList struct {
+	byCollateral map[wire.OutPoint]chainhash.Hash
+	count        int
+}
+
+// ValidMasternodeCount returns the number of masternodes in the fake list.
+func (l *fakeMasternodeList) ValidMasternodeCount() int {
+	return l.count
+}
+
+// LookupMasternode returns the ProRegTx hash of the masternode with the passed
+// collateral or ProRegTx hash.
+func (l *fakeMasternodeList) LookupMasternode(collateral *wire.OutPoint, proTxHash *chainhash.Hash, lookback int32) (chainhash.Hash, bool) {
+	if *collateral != (wire.OutPoint{}) {
+		hash, ok := l.byCollateral[*collateral]
+		return hash, ok
+	}
+	for _, hash := range l.byCollateral {
+		if hash == *proTxHash {
+			return hash, true
+		}
+	}
+	return chainhash.Hash{}, false
+}
+
+// VerifyOperatorSig checks the fake signature of the passed hash.
+func (l *fakeMasternodeList) VerifyOperatorSig(proTxHash, hash *chainhash.Hash, sig []byte) bool {
+	return bytes.Equal(sig, fakeSig(proTxHash, hash))
+}
+
+// fakeSig returns the signature the fake masternode list expects.
+func fakeSig(proTxHash, hash *chainhash.Hash) []byte {
+	sig := make([]byte, 0, 2*chainhash.HashSize)
+	sig = append(sig, proTxHash[:]...)
+	return append(sig, hash[:]...)
+}
+
+// newTestManager returns a manager with a fake masternode list containing the
+// passed number of masternodes along with their collateral outpoints.
+func newTestManager(numMasternodes int) (*Manager, []wire.OutPoint) {
+	mnList := &fakeMasternodeList{
+		byCollateral: make(map[wire.OutPoint]chainhash.Hash),
+		count:        numMasternodes,
+	}
+	collaterals := make([]wire.OutPoint, numMasternodes)
+	for i := range collaterals {
+		collaterals[i] = wire.OutPoint{Hash: chainhash.Hash{byte(i + 1)}}
+		mnList.byCollateral[collaterals[i]] = chainhash.Hash{0xff, byte(i)}
+	}
+
+	m := New(&Config{
+		ChainParams:    &chaincfg.MainNetParams,
+		TimeSource:     blockchain.NewMedianTime(),
+		MasternodeList: mnList,
+	})
+	return m, collaterals
+}
+
+// newSignedQueue returns a queue from the masternode with the passed
+// collateral which is signed as expected by the fake masternode list.
+func newSignedQueue(m *Manager, collateral wire.OutPoint, queueTime int64, ready bool) *wire.MsgDSQueue {
+	mnList := m.cfg.MasternodeList.(*fakeMasternodeList)
+	proTxHash := mnList.byCollateral[collateral]
+	dsq := wire.NewMsgDSQueue(1, &collateral, &proTxHash, queueTime, ready)
+	sigHash := dsq.SignatureHash()
+	dsq.Sig = fakeSig(&proTxHash, &sigHash)
+	return dsq
+}
+
+// assertRuleError ensures the passed error is a RuleError with the given error
+// code, or nil when the code is nil.
+func assertRuleError(t *testing.T, desc string, err error, code *ErrorCode) {
+	t.Helper()
+
+	if code == nil {
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", desc, err)
+		}
+		return
+	}
+	rerr, ok := err.(RuleError)
+	if !ok || rerr.ErrorCode != *code {
+		t.Errorf("%s: got error %v, want %v", desc, err, *code)
+	}
+}
+
+// codePtr returns a pointer to the passed error code.
+func codePtr(c ErrorCode) *ErrorCode {
+	return &c
+}
+
+// TestProcessQueue ensures queues are validated and rate limited as expected.
+func TestProcessQueue(t *testing.T) {
+	m, collaterals := newTestManager(10)
+	now := time.Now().Unix()
+
+	// A valid queue is accepted and its duplicate is not.
+	dsq := newSignedQueue(m, collaterals[0], now, false)
+	assertRuleError(t, "valid queue", m.ProcessQueue(dsq), nil)
+	dsq = newSignedQueue(m, collaterals[0], now+1, false)
+	assertRuleError(t, "duplicate queue", m.ProcessQueue(dsq),
+		codePtr(ErrDuplicateQueue))
+
+	// A ready queue from the same masternode is not a duplicate.
+	dsq = newSignedQueue(m, collaterals[0], now, true)
+	assertRuleError(t, "ready queue", m.ProcessQueue(dsq), nil)
+
+	// Queues with invalid denominations, stale times, unknown masternodes
+	// and bad signatures are rejected.
+	dsq = newSignedQueue(m, collaterals[1], now, false)
+	dsq.Denomination = 3
+	assertRuleError(t, "invalid denomination", m.ProcessQueue(dsq),
+		codePtr(ErrInvalidDenomination))
+	dsq = newSignedQueue(m, collaterals[1], now-60, false)
+	assertRuleError(t, "stale queue", m.ProcessQueue(dsq),
+		codePtr(ErrQueueTimeOutOfBounds))
+	dsq = newSignedQueue(m, wire.OutPoint{Index: 1}, now, false)
+	assertRuleError(t, "unknown masternode", m.ProcessQueue(dsq),
+		codePtr(ErrUnknownMasternode))
+	dsq = newSignedQueue(m, collaterals[1], now, false)
+	dsq.Sig[0] ^= 0xff
+	assertRuleError(t, "bad signature", m.ProcessQueue(dsq),
+		codePtr(ErrBadSignature))
+
+	// The ProTxHash is filled in for queues from older peers which only
+	// identify the masternode by its collateral.
+	dsq = newSignedQueue(m, collaterals[1], now, false)
+	proTxHash := dsq.ProTxHash
+	dsq.ProTxHash = chainhash.Hash{}
+	assertRuleError(t, "queue without ProTxHash", m.ProcessQueue(dsq), nil)
+	if dsq.ProTxHash != proTxHash {
+		t.Errorf("ProcessQueue: ProTxHash not filled in - got %v, "+
+			"want %v", dsq.ProTxHash, proTxHash)
+	}
+
+	if queues := m.Queues(); len(queues) != 3 {
+		t.Errorf("Queues: got %d queues, want %d", len(queues), 3)
+	}
+}
+
+// TestQueueRateLimit ensures a masternode may only announce a new queue once
+// a fifth of the masternodes announced one since its previous queue.
+func TestQueueRateLimit(t *testing.T) {
+	m, collaterals := newTestManager(10)
+	now := time.Now().Unix()
+
+	// Announce a queue from the first masternode and remove it from the
+	// cache as though it expired, so the duplicate check doesn't apply.
+	dsq := newSignedQueue(m, collaterals[0], now, false)
+	assertRuleError(t, "first queue", m.ProcessQueue(dsq), nil)
+	m.queues = nil
+
+	dsq = newSignedQueue(m, collaterals[0], now, false)
+	assertRuleError(t, "rate limited queue", m.ProcessQueue(dsq),
+		codePtr(ErrQueueRateLimited))
+
+	// Two queues from other masternodes (a fifth of 10) lift the limit.
+	for i := 1; i <= 2; i++ {
+		dsq = newSignedQueue(m, collaterals[i], now, false)
+		assertRuleError(t, "other queue", m.ProcessQueue(dsq), nil)
+	}
+	dsq = newSignedQueue(m, collaterals[0], now, false)
+	assertRuleError(t, "queue after limit", m.ProcessQueue(dsq), nil)
+}
+
+// TestCheckDSTx ensures broadcast transactions are validated and limited as
+// expected.
+func TestCheckDSTx(t *testing.T) {
+	m, collaterals := newTestManager(10)
+	mnList := m.cfg.MasternodeList.(*fakeMasternodeList)
+	proTxHash := mnList.byCollateral[collaterals[0]]
+
+	newSignedDSTx := func(amount int64) *wire.MsgDSTx {
+		dstx := wire.NewMsgDSTx(newMixingTx(3, amount),
+			&collaterals[0], &proTxHash, time.Now().Unix())
+		sigHash := dstx.SignatureHash()
+		dstx.Sig = fakeSig(&proTxHash, &sigHash)
+		return dstx
+	}
+
+	dstx := newSignedDSTx(100001)
+	assertRuleError(t, "valid dstx", m.CheckDSTx(dstx), nil)
+	m.AddDSTx(dstx)
+	txHash := dstx.Tx.TxHash()
+	if !m.HaveDSTx(&txHash) || m.DSTx(&txHash) != dstx {
+		t.Fatalf("AddDSTx: transaction %v not found", txHash)
+	}
+	assertRuleError(t, "duplicate dstx", m.CheckDSTx(dstx),
+		codePtr(ErrDuplicateDSTx))
+
+	dstx = newSignedDSTx(100000)
+	assertRuleError(t, "invalid structure", m.CheckDSTx(dstx),
+		codePtr(ErrInvalidStructure))
+
+	dstx = newSignedDSTx(1000010)
+	dstx.Sig[0] ^= 0xff
+	assertRuleError(t, "bad signature", m.CheckDSTx(dstx),
+		codePtr(ErrBadSignature))
+
+	// The masternode may broadcast a limited number of mixing
+	// transactions, including the first one above, before it has to
+	// announce a new queue.
+	for i := 1; i < maxMixingTxs; i++ {
+		dstx = newSignedDSTx(1000010)
+		assertRuleError(t, "dstx within limit", m.CheckDSTx(dstx), nil)
+	}
+	dstx = newSignedDSTx(10000100)
+	assertRuleError(t, "dstx over limit", m.CheckDSTx(dstx),
+		codePtr(ErrTooManyMixingTxs))
+
+	dsq := newSignedQueue(m, collaterals[0], time.Now().Unix(), false)
+	assertRuleError(t, "new queue", m.ProcessQueue(dsq), nil)
+	assertRuleError(t, "dstx after queue", m.CheckDSTx(dstx), nil)
+
+	// Without a masternode list nothing can be verified.
+	m = New(&Config{
+		ChainParams: &chaincfg.MainNetParams,
+		TimeSource:  blockchain.NewMedianTime(),
+	})
+	assertRuleError(t, "no masternode list", m.CheckDSTx(dstx),
+		codePtr(ErrNoMasternodeList))
+}
@@ -20,6 +20,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/eager7/dashd/addrmgr"
 	"github.com/eager7/dashd/blockchain"
 	"github.com/eager7/dashd/chaincfg"
 	"github.com/eager7/dashd/chaincfg/chainhash"
@@ -61,9 +62,12 @@ const (
 	defaultMaxOrphanTransactions = 100
 	defaultMaxOrphanTxSize       = 100000
 	defaultSigCacheMaxSize       = 100000
+	defaultUtxoCacheMaxSizeMiB   = blockchain.DefaultUtxoCacheMaxSize / (1024 * 1024)
 	sampleConfigFilename         = "sample-btcd.conf"
 	defaultTxIndex               = false
 	defaultAddrIndex             = false
+	defaultTorControlPort        = "9051"
+	defaultI2PSAMPort            = "7656"
 )
 
 var (
@@ -95,7 +99,11 @@ func minUint32(a, b uint32) uint32 {
 type config struct {
 	AddCheckpoints       []string      `long:"addcheckpoint" description:"Add a custom checkpoint.  Format: '<height>:<hash>'"`
 	AddPeers             []string      `short:"a" long:"addpeer" description:"Add a peer to connect with at startup"`
+	AddUtxoSnapshots     []string      `long:"addutxosnapshot" description:"Allow loading the utxo set snapshot with the given content hash taken at the given block.  Format: '<height>:<blockhash>:<contenthash>'"`
 	AddrIndex            bool          `long:"addrindex" description:"Maintain a full address-based transaction index which makes the searchrawtransactions RPC available"`
+	AddressIndex         bool          `long:"addressindex" description:"Maintain an index of the balance changes and unspent outputs of addresses which makes the getaddressbalance, getaddressdeltas, getaddressmempool, getaddresstxids and getaddressutxos RPCs available"`
+	AssumeValid          string        `long:"assumevalid" description:"Hash of a block whose ancestors are assumed to have valid scripts, so their scripts are not checked (default: network specific, 0 to check all scripts)"`
+	ASMap                string        `long:"asmap" description:"File mapping IP prefixes to the autonomous systems they belong to, used to make sure outbound peers are in distinct autonomous systems"`
 	AgentBlacklist       []string      `long:"agentblacklist" description:"A comma separated list of user-agent substrings which will cause btcd to reject any peers whose user-agent contains any of the blacklisted substrings."`
 	AgentWhitelist       []string      `long:"agentwhitelist" description:"A comma separated list of user-agent substrings which will cause btcd to require all peers' user-agents to contain one of the whitelisted substrings. The blacklist is applied before the blacklist, and an empty whitelist will allow all agents that do not fail the blacklist."`
 	BanDuration          time.Duration `long:"banduration" description:"How long to ban misbehaving peers.  Valid time units are {s, m, h}.  Minimum 1 second"`
@@ -113,10 +121,14 @@ type config struct {
 	DbType               string        `long:"dbtype" description:"Database backend to use for the Block Chain"`
 	DebugLevel           string        `short:"d" long:"debuglevel" description:"Logging level for all subsystems {trace, debug, info, warn, error, critical} -- You may also specify <subsystem>=<level>,<subsystem2>=<level>,... to set the log level for individual subsystems -- Use show to list available subsystems"`
 	DropAddrIndex        bool          `long:"dropaddrindex" description:"Deletes the address-based transaction index from the database on start up and then exits."`
+	DropAddressIndex     bool          `long:"dropaddressindex" description:"Deletes the index of the balance changes and unspent outputs of addresses from the database on start up and then exits."`
 	DropCfIndex          bool          `long:"dropcfindex" description:"Deletes the index used for committed filtering (CF) support from the database on start up and then exits."`
+	DropSpentIndex       bool          `long:"dropspentindex" description:"Deletes the spent index from the database on start up and then exits."`
+	DropTimestampIndex   bool          `long:"droptimestampindex" description:"Deletes the timestamp index from the database on start up and then exits."`
 	DropTxIndex          bool          `long:"droptxindex" description:"Deletes the hash-based transaction index from the database on start up and then exits."`
 	ExternalIPs          []string      `long:"externalip" description:"Add an ip to the list of local addresses we claim to listen on to peers"`
 	Generate             bool          `long:"generate" description:"Generate (mine) bitcoins using the CPU"`
+	I2PSAM               string        `long:"i2psam" description:"Connect to and accept connections from I2P peers through the SAM v3 bridge of an I2P router (eg. 127.0.0.1:7656)"`
 	FreeTxRelayLimit     float64       `long:"limitfreerelay" description:"Limit relay of transactions with no transaction fee to the given amount in thousands of bytes per minute"`
 	Listeners            []string      `long:"listen" description:"Add an interface/port to listen for connections (default all interfaces port: 8333, testnet: 18333)"`
 	LogDir               string        `long:"logdir" description:"Directory to log output."`
@@ -131,6 +143,7 @@ type config struct {
 	DisableListen        bool          `long:"nolisten" description:"Disable listening for incoming connections -- NOTE: Listening is automatically disabled if the --connect or --proxy options are used without also specifying listen interfaces via --listen"`
 	NoOnion              bool          `long:"noonion" description:"Disable connecting to tor hidden services"`
 	NoPeerBloomFilters   bool          `long:"nopeerbloomfilters" description:"Disable bloom filtering support"`
+	NoV2Transport        bool          `long:"nov2transport" description:"Disable the v2 encrypted P2P transport (BIP0324)"`
 	NoRelayPriority      bool          `long:"norelaypriority" description:"Do not require free or low-fee transactions to have high priority for relaying"`
 	DisableRPC           bool          `long:"norpc" description:"Disable built-in RPC server -- NOTE: The RPC server is disabled by default if no rpcuser/rpcpass or rpclimituser/rpclimitpass is specified"`
 	DisableTLS           bool          `long:"notls" description:"Disable TLS for the RPC server -- NOTE: This is only allowed if the RPC server is bound to localhost"`
@@ -138,6 +151,7 @@ type config struct {
 	OnionProxyPass       string        `long:"onionpass" default-mask:"-" description:"Password for onion proxy server"`
 	OnionProxyUser       string        `long:"onionuser" description:"Username for onion proxy server"`
 	Profile              string        `long:"profile" description:"Enable HTTP profiling on given port -- NOTE port must be between 1024 and 65536"`
+	Prune                uint64        `long:"prune" description:"Delete old blocks to keep the stored blocks below the given size in MiB (minimum 550) -- NOTE: Not possible with the transaction, address, spent and timestamp indexes, and indexes which are behind the pruned blocks can't be caught up"`
 	Proxy                string        `long:"proxy" description:"Connect via SOCKS5 proxy (eg. 127.0.0.1:9050)"`
 	ProxyPass            string        `long:"proxypass" default-mask:"-" description:"Password for proxy server"`
 	ProxyUser            string        `long:"proxyuser" description:"Username for proxy server"`
@@ -158,18 +172,25 @@ type config struct {
 	RPCUser              string        `short:"u" long:"rpcuser" description:"Username for RPC connections"`
 	SigCacheMaxSize      uint          `long:"sigcachemaxsize" description:"The maximum number of entries in the signature verification cache"`
 	SimNet               bool          `long:"simnet" description:"Use the simulation test network"`
+	SpentIndex           bool          `long:"spentindex" description:"Maintain an index of the inputs spending each output which makes the getspentinfo RPC available"`
 	TestNet3             bool          `long:"testnet" description:"Use the test network"`
+	TimestampIndex       bool          `long:"timestampindex" description:"Maintain an index of the blocks ordered by time which makes the getblockhashes RPC available"`
+	TorControl           string        `long:"torcontrol" description:"Create a Tor v3 hidden service for the peer listener through the Tor control port (eg. 127.0.0.1:9051)"`
 	TorIsolation         bool          `long:"torisolation" description:"Enable Tor stream isolation by randomizing user credentials for each connection."`
+	TorPassword          string        `long:"torpassword" default-mask:"-" description:"Password for the Tor control port -- the authentication cookie of Tor is used when not set"`
 	TrickleInterval      time.Duration `long:"trickleinterval" description:"Minimum time between attempts to send new inventory to a connected peer"`
 	TxIndex              bool          `long:"txindex" description:"Maintain a full hash-based transaction index which makes all transactions available via the getrawtransaction RPC"`
 	UserAgentComments    []string      `long:"uacomment" description:"Comment to add to the user agent -- See BIP 14 for more information."`
 	Upnp                 bool          `long:"upnp" description:"Use UPnP to map our listening port outside of NAT"`
+	UtxoCacheMaxSizeMiB  uint          `long:"utxocachemaxsize" description:"The maximum size in MiB of the unspent transaction outputs held in memory before they are written to the database"`
 	ShowVersion          bool          `short:"V" long:"version" description:"Display version information and exit"`
 	Whitelists           []string      `long:"whitelist" description:"Add an IP network or IP that will not be banned. (eg. 192.168.1.0/24 or ::1)"`
 	lookup               func(string) ([]net.IP, error)
 	oniondial            func(string, string, time.Duration) (net.Conn, error)
 	dial                 func(string, string, time.Duration) (net.Conn, error)
 	addCheckpoints       []chaincfg.Checkpoint
+	addUtxoSnapshots     []chaincfg.UtxoSnapshot
+	assumeValid          chainhash.Hash
 	miningAddrs          []dashutil.Address
 	minRelayTxFee        dashutil.Amount
 	whitelists           []*net.IPNet
@@ -372,6 +393,58 @@ func parseCheckpoints(checkpointStrings []string) ([]chaincfg.Checkpoint, error)
 	return checkpoints, nil
 }
 
+// newUtxoSnapshotFromStr parses utxo snapshots in the
+// '<height>:<blockhash>:<contenthash>' format.
+func newUtxoSnapshotFromStr(snapshot string) (chaincfg.UtxoSnapshot, error) {
+	parts := strings.Split(snapshot, ":")
+	if len(parts) != 3 {
+		return chaincfg.UtxoSnapshot{}, fmt.Errorf("unable to parse "+
+			"utxo snapshot %q -- use the syntax "+
+			"<height>:<blockhash>:<contenthash>", snapshot)
+	}
+
+	height, err := strconv.ParseInt(parts[0], 10, 32)
+	if err != nil {
+		return chaincfg.UtxoSnapshot{}, fmt.Errorf("unable to parse "+
+			"utxo snapshot %q due to malformed height", snapshot)
+	}
+	blockHash, err := chainhash.NewHashFromStr(parts[1])
+	if err != nil || len(parts[1]) == 0 {
+		return chaincfg.UtxoSnapshot{}, fmt.Errorf("unable to parse "+
+			"utxo snapshot %q due to malformed block hash", snapshot)
+	}
+	contentHash, err := chainhash.NewHashFromStr(parts[2])
+	if err != nil || len(parts[2]) == 0 {
+		return chaincfg.UtxoSnapshot{}, fmt.Errorf("unable to parse "+
+			"utxo snapshot %q due to malformed content hash",
+			snapshot)
+	}
+
+	return chaincfg.UtxoSnapshot{
+		Height:      int32(height),
+		BlockHash:   blockHash,
+		ContentHash: contentHash,
+	}, nil
+}
+
+// parseUtxoSnapshots checks the utxo snapshot strings for valid syntax
+// ('<height>:<blockhash>:<contenthash>') and parses them to
+// chaincfg.UtxoSnapshot instances.
+func parseUtxoSnapshots(snapshotStrings []string) ([]chaincfg.UtxoSnapshot, error) {
+	if len(snapshotStrings) == 0 {
+		return nil, nil
+	}
+	snapshots := make([]chaincfg.UtxoSnapshot, len(snapshotStrings))
+	for i, snapshotString := range snapshotStrings {
+		snapshot, err := newUtxoSnapshotFromStr(snapshotString)
+		if err != nil {
+			return nil, err
+		}
+		snapshots[i] = snapshot
+	}
+	return snapshots, nil
+}
+
 // filesExists reports whether the named file or directory exists.
 func fileExists(name string) bool {
 	if _, err := os.Stat(name); err != nil {
@@ -429,6 +502,7 @@ func loadConfig() (*config, []string, error) {
 		BlockPrioritySize:    mempool.DefaultBlockPrioritySize,
 		MaxOrphanTxs:         defaultMaxOrphanTransactions,
 		SigCacheMaxSize:      defaultSigCacheMaxSize,
+		UtxoCacheMaxSizeMiB:  defaultUtxoCacheMaxSizeMiB,
 		Generate:             defaultGenerate,
 		TxIndex:              defaultTxIndex,
 		AddrIndex:            defaultAddrIndex,
@@ -592,6 +666,10 @@ func loadConfig() (*config, []string, error) {
 	cfg.LogDir = cleanAndExpandPath(cfg.LogDir)
 	cfg.LogDir = filepath.Join(cfg.LogDir, netName(activeNetParams))
 
+	if cfg.ASMap != "" {
+		cfg.ASMap = cleanAndExpandPath(cfg.ASMap)
+	}
+
 	// Special show command to list supported subsystems and exit.
 	if cfg.DebugLevel == "show" {
 		fmt.Println("Supported subsystems", supportedSubsystems())
@@ -855,6 +933,36 @@ func loadConfig() (*config, []string, error) {
 		return nil, nil, err
 	}
 
+	// --addressindex and --dropaddressindex do not mix.
+	if cfg.AddressIndex && cfg.DropAddressIndex {
+		err := fmt.Errorf("%s: the --addressindex and --dropaddressindex "+
+			"options may not be activated at the same time",
+			funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	// --spentindex and --dropspentindex do not mix.
+	if cfg.SpentIndex && cfg.DropSpentIndex {
+		err := fmt.Errorf("%s: the --spentindex and --dropspentindex "+
+			"options may not be activated at the same time",
+			funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	// --timestampindex and --droptimestampindex do not mix.
+	if cfg.TimestampIndex && cfg.DropTimestampIndex {
+		err := fmt.Errorf("%s: the --timestampindex and "+
+			"--droptimestampindex options may not be activated at "+
+			"the same time", funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
 	// --addrindex and --droptxindex do not mix.
 	if cfg.AddrIndex && cfg.DropTxIndex {
 		err := fmt.Errorf("%s: the --addrindex and --droptxindex "+
@@ -867,6 +975,32 @@ func loadConfig() (*config, []string, error) {
 		return nil, nil, err
 	}
 
+	// Validate the prune target and ensure pruning is not combined with
+	// indexes which require all blocks.
+	if cfg.Prune != 0 {
+		minPrune := uint64(blockchain.MinPruneTarget / (1024 * 1024))
+		if cfg.Prune < minPrune {
+			str := "%s: the prune target may not be less than %d MiB " +
+				"-- parsed [%d]"
+			err := fmt.Errorf(str, funcName, minPrune, cfg.Prune)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+		if cfg.TxIndex || cfg.AddrIndex || cfg.AddressIndex ||
+			cfg.SpentIndex || cfg.TimestampIndex {
+
+			err := fmt.Errorf("%s: the --prune option may not be "+
+				"activated together with the --txindex, "+
+				"--addrindex, --addressindex, --spentindex or "+
+				"--timestampindex options because they require "+
+				"all blocks", funcName)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+	}
+
 	// Check mining addresses are valid and saved parsed versions.
 	cfg.miningAddrs = make([]dashutil.Address, 0, len(cfg.MiningAddrs))
 	for _, strAddr := range cfg.MiningAddrs {
@@ -946,6 +1080,25 @@ func loadConfig() (*config, []string, error) {
 	cfg.ConnectPeers = normalizeAddresses(cfg.ConnectPeers,
 		activeNetParams.DefaultPort)
 
+	// Validate the Tor hidden services of the added peers up front since
+	// they can't be resolved, so a mistyped Tor v3 address would otherwise
+	// only fail once it is dialed.
+	for _, peers := range [][]string{cfg.AddPeers, cfg.ConnectPeers} {
+		for _, addr := range peers {
+			host, _, err := net.SplitHostPort(addr)
+			if err != nil || !strings.HasSuffix(host, ".onion") {
+				continue
+			}
+			if _, _, err := addrmgr.ParseOnionHost(host); err != nil {
+				str := "%s: invalid peer address: %v"
+				err := fmt.Errorf(str, funcName, err)
+				fmt.Fprintln(os.Stderr, err)
+				fmt.Fprintln(os.Stderr, usageMessage)
+				return nil, nil, err
+			}
+		}
+	}
+
 	// --noonion and --onion do not mix.
 	if cfg.NoOnion && cfg.OnionProxy != "" {
 		err := fmt.Errorf("%s: the --noonion and --onion options may "+
@@ -955,6 +1108,34 @@ func loadConfig() (*config, []string, error) {
 		return nil, nil, err
 	}
 
+	// The hidden service created through the Tor control port forwards
+	// inbound connections to the peer listener, so it requires listening
+	// and does not mix with --noonion.
+	if cfg.TorControl != "" {
+		if cfg.DisableListen {
+			err := fmt.Errorf("%s: the --torcontrol option requires "+
+				"listening for inbound connections", funcName)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+		if cfg.NoOnion {
+			err := fmt.Errorf("%s: the --noonion and --torcontrol "+
+				"options may not be activated at the same time",
+				funcName)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+		cfg.TorControl = normalizeAddress(cfg.TorControl,
+			defaultTorControlPort)
+	}
+
+	// Add default port to the SAM bridge address if needed.
+	if cfg.I2PSAM != "" {
+		cfg.I2PSAM = normalizeAddress(cfg.I2PSAM, defaultI2PSAMPort)
+	}
+
 	// Check the checkpoints for syntax errors.
 	cfg.addCheckpoints, err = parseCheckpoints(cfg.AddCheckpoints)
 	if err != nil {
@@ -965,6 +1146,34 @@ func loadConfig() (*config, []string, error) {
 		return nil, nil, err
 	}
 
+	// Check the utxo snapshots for syntax errors.
+	cfg.addUtxoSnapshots, err = parseUtxoSnapshots(cfg.AddUtxoSnapshots)
+	if err != nil {
+		str := "%s: Error parsing utxo snapshots: %v"
+		err := fmt.Errorf(str, funcName, err)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	// Determine the block whose ancestors are assumed to have valid
+	// scripts.  A zero hash causes all scripts to be checked.
+	cfg.assumeValid = activeNetParams.AssumeValid
+	if cfg.AssumeValid != "" {
+		cfg.assumeValid = chainhash.Hash{}
+		if cfg.AssumeValid != "0" {
+			hash, err := chainhash.NewHashFromStr(cfg.AssumeValid)
+			if err != nil {
+				str := "%s: Error parsing assumevalid hash: %v"
+				err := fmt.Errorf(str, funcName, err)
+				fmt.Fprintln(os.Stderr, err)
+				fmt.Fprintln(os.Stderr, usageMessage)
+				return nil, nil, err
+			}
+			cfg.assumeValid = *hash
+		}
+	}
+
 	// Tor stream isolation requires either proxy or onion proxy to be set.
 	if cfg.TorIsolation && cfg.Proxy == "" && cfg.OnionProxy == "" {
 		str := "%s: Tor stream isolation requires either proxy or " +
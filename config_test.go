@@ -70,3 +70,35 @@ func TestCreateDefaultConfigFile(t *testing.T) {
 		t.Error("Could not find rpcpass in generated default config file.")
 	}
 }
+
+// TestParseUtxoSnapshots ensures utxo snapshots given with the
+// --addutxosnapshot option are parsed and malformed ones are rejected.
+func TestParseUtxoSnapshots(t *testing.T) {
+	blockHash := "000000000000000a2d63e6ba6b1c9e8a1c3a0d6d8f3c1b9f4e0b2a4c6d8e0f12"
+	contentHash := "4a5e1e4baab89f3a32518a88c31bc87f618f76673e2cc77ab2127b7afdeda33b"
+
+	snapshots, err := parseUtxoSnapshots([]string{
+		"1000:" + blockHash + ":" + contentHash,
+	})
+	if err != nil {
+		t.Fatalf("parseUtxoSnapshots: unexpected error: %v", err)
+	}
+	if len(snapshots) != 1 || snapshots[0].Height != 1000 ||
+		snapshots[0].BlockHash.String() != blockHash ||
+		snapshots[0].ContentHash.String() != contentHash {
+
+		t.Fatalf("parseUtxoSnapshots: unexpected result %+v", snapshots)
+	}
+
+	malformed := []string{
+		"1000:" + blockHash,
+		"height:" + blockHash + ":" + contentHash,
+		"1000::" + contentHash,
+		"1000:" + blockHash + ":zz",
+	}
+	for _, snapshot := range malformed {
+		if _, err := parseUtxoSnapshots([]string{snapshot}); err == nil {
+			t.Errorf("parseUtxoSnapshots: no error for %q", snapshot)
+		}
+	}
+}
@@ -35,6 +35,10 @@ var (
 	// defaultTargetOutbound is the default number of outbound connections to
 	// maintain.
 	defaultTargetOutbound = uint32(8)
+
+	// defaultFeelerInterval is the default duration of time between feeler
+	// connections.
+	defaultFeelerInterval = time.Minute * 2
 )
 
 // ConnState represents the state of the requested connection.
@@ -53,13 +57,16 @@ const (
 )
 
 // ConnReq is the connection request to a network address. If permanent, the
-// connection will be retried on disconnection.
+// connection will be retried on disconnection.  Block-relay-only connections
+// are only used to relay blocks, and are maintained separately from the other
+// outbound connections.
 type ConnReq struct {
 	// The following variables must only be used atomically.
 	id uint64
 
-	Addr      net.Addr
-	Permanent bool
+	Addr           net.Addr
+	Permanent      bool
+	BlockRelayOnly bool
 
 	conn       net.Conn
 	state      ConnState
@@ -124,6 +131,16 @@ type Config struct {
 	// maintain. Defaults to 8.
 	TargetOutbound uint32
 
+	// TargetBlockRelayOnly is the number of block-relay-only outbound
+	// network connections to maintain in addition to TargetOutbound.
+	TargetBlockRelayOnly uint32
+
+	// Anchors are the addresses of the block-relay-only peers to connect
+	// to first on start, which are usually those of the previous run.
+	// They take the place of new block-relay-only connections and are
+	// replaced by new ones when they fail.
+	Anchors []net.Addr
+
 	// RetryDuration is the duration to wait before retrying connection
 	// requests. Defaults to 5s.
 	RetryDuration time.Duration
@@ -142,6 +159,23 @@ type Config struct {
 
 	// Dial connects to the address on the named network. It cannot be nil.
 	Dial func(net.Addr) (net.Conn, error)
+
+	// GetFeelerAddress is a way to get an address to test with a feeler
+	// connection.  Feeler connections are short-lived outbound connections
+	// made periodically to learn which known addresses are reachable.  They
+	// don't count toward the target number of outbound connections and are
+	// never retried.  If nil, no feeler connections will be made.
+	GetFeelerAddress func() (net.Addr, error)
+
+	// OnFeelerConnection is a callback that is fired when a feeler
+	// connection is established.  It is the caller's responsibility to
+	// close the connection once the address has been tested.  If nil, the
+	// connection is closed right away.
+	OnFeelerConnection func(*ConnReq, net.Conn)
+
+	// FeelerInterval is the duration of time between feeler connections.
+	// Defaults to 2m.
+	FeelerInterval time.Duration
 }
 
 // registerPending is used to register a pending connection attempt. By
@@ -211,10 +245,10 @@ func (cm *ConnManager) handleFailedConn(c *ConnReq) {
 				"-- retrying connection in: %v", maxFailedAttempts,
 				cm.cfg.RetryDuration)
 			time.AfterFunc(cm.cfg.RetryDuration, func() {
-				cm.NewConnReq()
+				cm.newConnReq(c.BlockRelayOnly)
 			})
 		} else {
-			go cm.NewConnReq()
+			go cm.newConnReq(c.BlockRelayOnly)
 		}
 	}
 }
@@ -317,12 +351,16 @@ out:
 				}
 
 				// Otherwise, we will attempt a reconnection if
-				// we do not have enough peers, or if this is a
-				// persistent peer. The connection request is
-				// re added to the pending map, so that
-				// subsequent processing of connections and
+				// we do not have enough peers of its class, or
+				// if this is a persistent peer. The connection
+				// request is re added to the pending map, so
+				// that subsequent processing of connections and
 				// failures do not ignore the request.
-				if uint32(len(conns)) < cm.cfg.TargetOutbound ||
+				target := cm.cfg.TargetOutbound
+				if connReq.BlockRelayOnly {
+					target = cm.cfg.TargetBlockRelayOnly
+				}
+				if numConns(conns, connReq.BlockRelayOnly) < target ||
 					connReq.Permanent {
 
 					connReq.updateState(ConnPending)
@@ -356,9 +394,34 @@ out:
 	log.Trace("Connection handler done")
 }
 
+// numConns returns the number of the passed connections which are
+// block-relay-only connections when blockRelayOnly is set, or which are not
+// otherwise.
+func numConns(conns map[uint64]*ConnReq, blockRelayOnly bool) uint32 {
+	var n uint32
+	for _, c := range conns {
+		if c.BlockRelayOnly == blockRelayOnly {
+			n++
+		}
+	}
+	return n
+}
+
 // NewConnReq creates a new connection request and connects to the
 // corresponding address.
 func (cm *ConnManager) NewConnReq() {
+	cm.newConnReq(false)
+}
+
+// NewBlockRelayConnReq creates a new block-relay-only connection request and
+// connects to the corresponding address.
+func (cm *ConnManager) NewBlockRelayConnReq() {
+	cm.newConnReq(true)
+}
+
+// newConnReq creates a new connection request of the passed class and connects
+// to the corresponding address.
+func (cm *ConnManager) newConnReq(blockRelayOnly bool) {
 	if atomic.LoadInt32(&cm.stop) != 0 {
 		return
 	}
@@ -366,7 +429,7 @@ func (cm *ConnManager) NewConnReq() {
 		return
 	}
 
-	c := &ConnReq{}
+	c := &ConnReq{BlockRelayOnly: blockRelayOnly}
 	atomic.StoreUint64(&c.id, atomic.AddUint64(&cm.connReqCount, 1))
 
 	// Submit a request of a pending connection attempt to the connection
@@ -486,6 +549,61 @@ func (cm *ConnManager) Remove(id uint64) {
 	}
 }
 
+// feelerHandler makes a feeler connection every feeler interval.  It must be
+// run as a goroutine.
+func (cm *ConnManager) feelerHandler() {
+	ticker := time.NewTicker(cm.cfg.FeelerInterval)
+	defer ticker.Stop()
+
+out:
+	for {
+		select {
+		case <-ticker.C:
+			cm.connectFeeler()
+
+		case <-cm.quit:
+			break out
+		}
+	}
+
+	cm.wg.Done()
+	log.Trace("Feeler handler done")
+}
+
+// connectFeeler dials a feeler connection to the address returned by the
+// configured GetFeelerAddress.  Feeler connections are not tracked by the
+// connection handler since they are closed as soon as the address is tested.
+func (cm *ConnManager) connectFeeler() {
+	if atomic.LoadInt32(&cm.stop) != 0 {
+		return
+	}
+
+	addr, err := cm.cfg.GetFeelerAddress()
+	if err != nil {
+		log.Tracef("No feeler connection made: %v", err)
+		return
+	}
+
+	c := &ConnReq{Addr: addr}
+	atomic.StoreUint64(&c.id, atomic.AddUint64(&cm.connReqCount, 1))
+	log.Debugf("Attempting feeler connection to %v", c)
+
+	conn, err := cm.cfg.Dial(addr)
+	if err != nil {
+		c.updateState(ConnFailing)
+		log.Debugf("Failed feeler connection to %v: %v", c, err)
+		return
+	}
+
+	c.updateState(ConnEstablished)
+	c.conn = conn
+	if cm.cfg.OnFeelerConnection == nil {
+		conn.Close()
+		return
+	}
+	go cm.cfg.OnFeelerConnection(c, conn)
+}
+
 // listenHandler accepts incoming connections on a given listener.  It must be
 // run as a goroutine.
 func (cm *ConnManager) listenHandler(listener net.Listener) {
@@ -529,6 +647,23 @@ func (cm *ConnManager) Start() {
 	for i := atomic.LoadUint64(&cm.connReqCount); i < uint64(cm.cfg.TargetOutbound); i++ {
 		go cm.NewConnReq()
 	}
+
+	// Connect to the anchors first for the block-relay-only connections.
+	anchors := cm.cfg.Anchors
+	if uint32(len(anchors)) > cm.cfg.TargetBlockRelayOnly {
+		anchors = anchors[:cm.cfg.TargetBlockRelayOnly]
+	}
+	for _, addr := range anchors {
+		go cm.Connect(&ConnReq{Addr: addr, BlockRelayOnly: true})
+	}
+	for i := uint32(len(anchors)); i < cm.cfg.TargetBlockRelayOnly; i++ {
+		go cm.NewBlockRelayConnReq()
+	}
+
+	if cm.cfg.GetFeelerAddress != nil {
+		cm.wg.Add(1)
+		go cm.feelerHandler()
+	}
 }
 
 // Wait blocks until the connection manager halts gracefully.
@@ -568,6 +703,9 @@ func New(cfg *Config) (*ConnManager, error) {
 	if cfg.TargetOutbound == 0 {
 		cfg.TargetOutbound = defaultTargetOutbound
 	}
+	if cfg.FeelerInterval <= 0 {
+		cfg.FeelerInterval = defaultFeelerInterval
+	}
 	cm := ConnManager{
 		cfg:      *cfg, // Copy so caller can't mutate
 		requests: make(chan interface{}),
@@ -12,6 +12,9 @@ import (
 	"sync/atomic"
 	"testing"
 	"time"
+
+	"github.com/eager7/dashd/addrmgr"
+	"github.com/eager7/dashd/wire"
 )
 
 func init() {
@@ -216,6 +219,82 @@ func TestTargetOutbound(t *testing.T) {
 	cmgr.Stop()
 }
 
+// TestTargetBlockRelayOnly tests that the connection manager maintains the
+// target number of block-relay-only connections in addition to the other
+// outbound connections, connecting to the anchors first up to the target.
+func TestTargetBlockRelayOnly(t *testing.T) {
+	targetOutbound := uint32(2)
+	targetBlockRelayOnly := uint32(2)
+	anchors := []net.Addr{
+		&net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 18556},
+		&net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 18557},
+		&net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 18558},
+	}
+	connected := make(chan *ConnReq)
+	cmgr, err := New(&Config{
+		TargetOutbound:       targetOutbound,
+		TargetBlockRelayOnly: targetBlockRelayOnly,
+		Anchors:              anchors,
+		Dial:                 mockDialer,
+		GetNewAddress: func() (net.Addr, error) {
+			return &net.TCPAddr{
+				IP:   net.ParseIP("127.0.0.1"),
+				Port: 18555,
+			}, nil
+		},
+		OnConnection: func(c *ConnReq, conn net.Conn) {
+			connected <- c
+		},
+	})
+	if err != nil {
+		t.Fatalf("New error: %v", err)
+	}
+	cmgr.Start()
+
+	var blockRelayOnly []*ConnReq
+	var numAnchors uint32
+	for i := uint32(0); i < targetOutbound+targetBlockRelayOnly; i++ {
+		c := <-connected
+		if !c.BlockRelayOnly {
+			continue
+		}
+		blockRelayOnly = append(blockRelayOnly, c)
+		if c.Addr.(*net.TCPAddr).Port != 18555 {
+			numAnchors++
+		}
+	}
+	if uint32(len(blockRelayOnly)) != targetBlockRelayOnly {
+		t.Fatalf("block-relay-only: got %d connections, want %d",
+			len(blockRelayOnly), targetBlockRelayOnly)
+	}
+	if numAnchors != targetBlockRelayOnly {
+		t.Fatalf("block-relay-only: got %d anchor connections, want %d",
+			numAnchors, targetBlockRelayOnly)
+	}
+
+	select {
+	case c := <-connected:
+		t.Fatalf("block-relay-only: got unexpected connection - %v", c.Addr)
+	case <-time.After(time.Millisecond):
+		break
+	}
+
+	// A disconnected block-relay-only connection is replaced by another
+	// one.
+	cmgr.Disconnect(blockRelayOnly[0].ID())
+	select {
+	case c := <-connected:
+		if !c.BlockRelayOnly {
+			t.Fatalf("block-relay-only: got replacement connection " +
+				"which is not block-relay-only")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("block-relay-only: timeout waiting for replacement " +
+			"connection")
+	}
+	cmgr.Stop()
+}
+
 // TestRetryPermanent tests that permanent connection requests are retried.
 //
 // We make a permanent connection request using Connect, disconnect it using
@@ -664,3 +743,125 @@ out:
 	cmgr.Stop()
 	cmgr.Wait()
 }
+
+// TestFeelerConnections ensures feeler connections are made periodically to
+// the addresses returned by GetFeelerAddress, that unreachable addresses are
+// not retried, and that feeler connections are not tracked as outbound
+// connections.
+func TestFeelerConnections(t *testing.T) {
+	reachable := &net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 18555}
+	unreachable := &net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 18556}
+
+	var numAddrs, numDials uint32
+	feelers := make(chan *ConnReq, 10)
+	cmgr, err := New(&Config{
+		FeelerInterval: time.Millisecond,
+		GetFeelerAddress: func() (net.Addr, error) {
+			if atomic.AddUint32(&numAddrs, 1)%2 == 0 {
+				return reachable, nil
+			}
+			return unreachable, nil
+		},
+		Dial: func(addr net.Addr) (net.Conn, error) {
+			atomic.AddUint32(&numDials, 1)
+			if addr.String() == unreachable.String() {
+				return nil, errors.New("unreachable")
+			}
+			return mockDialer(addr)
+		},
+		OnConnection: func(c *ConnReq, conn net.Conn) {
+			t.Errorf("unexpected outbound connection to %v", c)
+		},
+		OnFeelerConnection: func(c *ConnReq, conn net.Conn) {
+			conn.Close()
+			select {
+			case feelers <- c:
+			default:
+			}
+		},
+	})
+	if err != nil {
+		t.Fatalf("New error: %v", err)
+	}
+	cmgr.Start()
+
+	for i := 0; i < 3; i++ {
+		select {
+		case c := <-feelers:
+			if c.Addr.String() != reachable.String() {
+				t.Fatalf("feeler connection to %v, want %v", c.Addr,
+					reachable)
+			}
+			if c.State() != ConnEstablished {
+				t.Fatalf("feeler connection state %v, want %v",
+					c.State(), ConnEstablished)
+			}
+
+		case <-time.After(time.Second):
+			t.Fatal("Timeout waiting for feeler connections")
+		}
+	}
+
+	cmgr.Stop()
+	cmgr.Wait()
+
+	// Every address was dialed once since failed feeler connections are not
+	// retried.
+	if addrs, dials := atomic.LoadUint32(&numAddrs),
+		atomic.LoadUint32(&numDials); addrs != dials {
+
+		t.Fatalf("got %d dials for %d feeler addresses", dials, addrs)
+	}
+}
+
+// TestFeelerAddrManager ensures feeler connections to the new addresses of an
+// address manager move the reachable ones to the tried buckets.
+func TestFeelerAddrManager(t *testing.T) {
+	amgr := addrmgr.New("testfeeler", nil)
+	na := wire.NewNetAddressV2IPPort(net.ParseIP("173.194.115.66"), 8333,
+		wire.SFNodeNetwork)
+	amgr.AddAddress(na, na)
+
+	tested := make(chan struct{}, 10)
+	cmgr, err := New(&Config{
+		FeelerInterval: time.Millisecond,
+		GetFeelerAddress: func() (net.Addr, error) {
+			ka := amgr.GetFeelerAddress()
+			if ka == nil {
+				return nil, errors.New("no address to test")
+			}
+			amgr.Attempt(ka.NetAddress())
+			return &net.TCPAddr{
+				IP:   ka.NetAddress().IP(),
+				Port: int(ka.NetAddress().Port),
+			}, nil
+		},
+		Dial: mockDialer,
+		OnFeelerConnection: func(c *ConnReq, conn net.Conn) {
+			amgr.Good(na)
+			conn.Close()
+			tested <- struct{}{}
+		},
+	})
+	if err != nil {
+		t.Fatalf("New error: %v", err)
+	}
+	cmgr.Start()
+
+	select {
+	case <-tested:
+	case <-time.After(time.Second):
+		t.Fatal("Timeout waiting for feeler connection")
+	}
+	cmgr.Stop()
+	cmgr.Wait()
+
+	// The address is no longer in the new buckets, so there is nothing
+	// left to test.
+	if ka := amgr.GetFeelerAddress(); ka != nil {
+		t.Fatalf("GetFeelerAddress: got %v, want nil", ka.NetAddress())
+	}
+	if n := amgr.NumAddresses(); n != 1 {
+		t.Fatalf("NumAddresses: got %d, want 1", n)
+	}
+}
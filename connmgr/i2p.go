@@ -0,0 +1,572 @@
+// Copyright (c) 2013-2016 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package connmgr
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// I2PPort is the port of I2P addresses.  SAM 3.1 streams have no
+	// ports, so I2P peers are identified by their destination alone.
+	I2PPort = 0
+
+	// i2pSAMVersion is the version of the SAM protocol used to talk to
+	// the I2P router.
+	i2pSAMVersion = "3.1"
+
+	// i2pSignatureType is the signature type of the destinations created
+	// by the session, which is EdDSA-SHA512-Ed25519.
+	i2pSignatureType = 7
+
+	// i2pSuffix is the suffix of the base32 host names of I2P
+	// destinations.
+	i2pSuffix = ".b32.i2p"
+
+	// i2pCommandTimeout is the timeout of the SAM commands which do not
+	// involve building tunnels.
+	i2pCommandTimeout = 10 * time.Second
+
+	// i2pSessionTimeout is the timeout used when creating a session, which
+	// waits for the I2P router to build its tunnels.
+	i2pSessionTimeout = 3 * time.Minute
+)
+
+var (
+	// ErrI2PSessionClosed indicates the I2P session has been closed.
+	ErrI2PSessionClosed = errors.New("i2p session closed")
+
+	// ErrI2PInvalidReply indicates the SAM bridge returned a reply in an
+	// unexpected format.
+	ErrI2PInvalidReply = errors.New("invalid i2p sam reply")
+
+	// i2pRetryInterval is the time to wait before accepting connections
+	// again after a failure, so an unreachable SAM bridge is not
+	// hammered.
+	i2pRetryInterval = 10 * time.Second
+
+	// i2pEncoding is the base64 encoding used by I2P for destinations,
+	// which replaces + and / by - and ~.
+	i2pEncoding = base64.NewEncoding("ABCDEFGHIJKLMNOPQRSTUVWXYZ" +
+		"abcdefghijklmnopqrstuvwxyz0123456789-~")
+
+	// i2pHostEncoding is the base32 encoding of the host names of I2P
+	// destinations.  It is lowercased when encoding.
+	i2pHostEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+)
+
+// I2PError is returned when the SAM bridge reports a failure.
+type I2PError struct {
+	// Result is the RESULT value of the reply, such as CANT_REACH_PEER.
+	Result string
+
+	// Message is the optional human readable description of the error.
+	Message string
+}
+
+// Error satisfies the error interface and prints human-readable errors.
+func (e *I2PError) Error() string {
+	if e.Message == "" {
+		return "i2p sam error " + e.Result
+	}
+	return fmt.Sprintf("i2p sam error %s: %s", e.Result, e.Message)
+}
+
+// I2PAddr implements the net.Addr interface and represents an I2P
+// destination by its .b32.i2p host name.
+type I2PAddr struct {
+	Host string
+	Port int
+}
+
+// Network returns "i2p".
+//
+// This is part of the net.Addr interface.
+func (a *I2PAddr) Network() string {
+	return "i2p"
+}
+
+// String returns the host name and port of the destination.
+//
+// This is part of the net.Addr interface.
+func (a *I2PAddr) String() string {
+	return net.JoinHostPort(a.Host, strconv.Itoa(a.Port))
+}
+
+// Ensure I2PAddr implements the net.Addr interface.
+var _ net.Addr = (*I2PAddr)(nil)
+
+// i2pHost returns the .b32.i2p host name of the passed base64 destination,
+// which is the base32 encoded SHA256 of the binary destination.
+func i2pHost(dest string) (string, error) {
+	data, err := i2pEncoding.DecodeString(dest)
+	if err != nil {
+		return "", fmt.Errorf("malformed i2p destination: %v", err)
+	}
+	hash := sha256.Sum256(data)
+	return strings.ToLower(i2pHostEncoding.EncodeToString(hash[:])) +
+		i2pSuffix, nil
+}
+
+// samConn is a connection to the SAM bridge.  Once a stream is established on
+// it, it carries the stream data and is handed out as a net.Conn.
+type samConn struct {
+	net.Conn
+	r *bufio.Reader
+
+	// local and remote are the I2P addresses of the ends of the stream.
+	local, remote net.Addr
+}
+
+// Read reads data from the stream.  It goes through the buffered reader used
+// for the SAM replies, so no data which was buffered along with them is lost.
+//
+// This is part of the net.Conn interface.
+func (c *samConn) Read(b []byte) (int, error) {
+	return c.r.Read(b)
+}
+
+// LocalAddr returns the I2P address of the session.
+//
+// This is part of the net.Conn interface.
+func (c *samConn) LocalAddr() net.Addr {
+	return c.local
+}
+
+// RemoteAddr returns the I2P address of the peer.
+//
+// This is part of the net.Conn interface.
+func (c *samConn) RemoteAddr() net.Addr {
+	return c.remote
+}
+
+// readLine reads a line from the SAM bridge.
+func (c *samConn) readLine() (string, error) {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// command sends the passed command to the SAM bridge and returns the
+// arguments of its reply, which must start with the passed topic.  Replies
+// with a RESULT other than OK are returned as an I2PError.
+func (c *samConn) command(cmd, topic string) (map[string]string, error) {
+	if _, err := io.WriteString(c.Conn, cmd+"\n"); err != nil {
+		return nil, err
+	}
+	line, err := c.readLine()
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(line, topic+" ") {
+		return nil, ErrI2PInvalidReply
+	}
+	args, err := parseTorReplyArgs(strings.TrimPrefix(line, topic+" "))
+	if err != nil {
+		return nil, err
+	}
+	if result, ok := args["RESULT"]; ok && result != "OK" {
+		return nil, &I2PError{Result: result, Message: args["MESSAGE"]}
+	}
+	return args, nil
+}
+
+// I2PConfig holds the configuration options related to the I2P session.
+type I2PConfig struct {
+	// SAMAddr is the address of the SAM v3 bridge of the I2P router.
+	SAMAddr string
+
+	// KeyFile is the file which holds the private key of the destination
+	// of the session.  A new destination is created and saved to it when
+	// it does not exist.
+	KeyFile string
+
+	// OnSession is invoked with the I2P address of the session each time
+	// it is created.  It is optional.
+	OnSession func(addr *I2PAddr)
+}
+
+// I2PSession dials and accepts connections to and from I2P destinations
+// through the SAM v3 bridge of an I2P router.  The SAM session is created on
+// first use and created again when it is lost.  It implements the net.Listener
+// interface, so inbound connections can be accepted by the connection
+// manager.
+type I2PSession struct {
+	cfg I2PConfig
+
+	// createMtx serializes the creation of SAM sessions.
+	createMtx sync.Mutex
+
+	// The following fields are protected by mtx.  pending holds the
+	// connections to the bridge which wait for a reply, so Close can
+	// interrupt them.
+	mtx     sync.Mutex
+	ctrl    *samConn
+	id      string
+	local   *I2PAddr
+	pending map[*samConn]struct{}
+	quit    chan struct{}
+	closed  bool
+}
+
+// Ensure I2PSession implements the net.Listener interface.
+var _ net.Listener = (*I2PSession)(nil)
+
+// NewI2PSession returns a new I2P session using the passed configuration.
+func NewI2PSession(cfg *I2PConfig) *I2PSession {
+	return &I2PSession{
+		cfg:     *cfg,
+		pending: make(map[*samConn]struct{}),
+		quit:    make(chan struct{}),
+	}
+}
+
+// connect opens a new connection to the SAM bridge and negotiates the version
+// of the protocol.  The connection is pending until it is passed to release.
+func (s *I2PSession) connect(timeout time.Duration) (*samConn, error) {
+	conn, err := net.DialTimeout("tcp", s.cfg.SAMAddr, timeout)
+	if err != nil {
+		return nil, err
+	}
+	c := &samConn{Conn: conn, r: bufio.NewReader(conn)}
+
+	s.mtx.Lock()
+	if s.closed {
+		s.mtx.Unlock()
+		conn.Close()
+		return nil, ErrI2PSessionClosed
+	}
+	s.pending[c] = struct{}{}
+	s.mtx.Unlock()
+
+	conn.SetDeadline(time.Now().Add(timeout))
+	cmd := fmt.Sprintf("HELLO VERSION MIN=%s MAX=%s", i2pSAMVersion,
+		i2pSAMVersion)
+	if _, err := c.command(cmd, "HELLO REPLY"); err != nil {
+		s.release(c)
+		conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// release marks the passed connection to the bridge as no longer pending and
+// clears its deadline.
+func (s *I2PSession) release(c *samConn) {
+	s.mtx.Lock()
+	delete(s.pending, c)
+	s.mtx.Unlock()
+	c.SetDeadline(time.Time{})
+}
+
+// fail releases and closes the passed connection to the bridge, and returns
+// ErrI2PSessionClosed in place of the passed error once the session has been
+// closed, since closing it interrupts pending connections.
+func (s *I2PSession) fail(c *samConn, err error) error {
+	s.release(c)
+	c.Close()
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if s.closed {
+		return ErrI2PSessionClosed
+	}
+	return err
+}
+
+// privateKey returns the private key of the destination of the session.  It
+// is read from the key file, or created on the passed connection and saved
+// to the key file when it does not exist.
+func (s *I2PSession) privateKey(c *samConn) (string, error) {
+	key, err := ioutil.ReadFile(s.cfg.KeyFile)
+	if err == nil {
+		return strings.TrimSpace(string(key)), nil
+	}
+	if !os.IsNotExist(err) {
+		return "", err
+	}
+
+	cmd := fmt.Sprintf("DEST GENERATE SIGNATURE_TYPE=%d", i2pSignatureType)
+	args, err := c.command(cmd, "DEST REPLY")
+	if err != nil {
+		return "", err
+	}
+	priv := args["PRIV"]
+	if priv == "" {
+		return "", ErrI2PInvalidReply
+	}
+	err = ioutil.WriteFile(s.cfg.KeyFile, []byte(priv+"\n"), 0600)
+	if err != nil {
+		return "", err
+	}
+	log.Infof("Created new I2P destination saved to %s", s.cfg.KeyFile)
+	return priv, nil
+}
+
+// current returns the control connection, ID and I2P address of the SAM
+// session, or a nil connection when there is none.
+func (s *I2PSession) current() (*samConn, string, *I2PAddr, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if s.closed {
+		return nil, "", nil, ErrI2PSessionClosed
+	}
+	return s.ctrl, s.id, s.local, nil
+}
+
+// session returns the control connection, ID and I2P address of the SAM
+// session, creating the session when there is none.
+func (s *I2PSession) session() (*samConn, string, *I2PAddr, error) {
+	s.createMtx.Lock()
+	defer s.createMtx.Unlock()
+
+	ctrl, id, local, err := s.current()
+	if err != nil || ctrl != nil {
+		return ctrl, id, local, err
+	}
+
+	c, err := s.connect(i2pSessionTimeout)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	priv, err := s.privateKey(c)
+	if err != nil {
+		return nil, "", nil, s.fail(c, err)
+	}
+
+	var idBytes [8]byte
+	if _, err := rand.Read(idBytes[:]); err != nil {
+		return nil, "", nil, s.fail(c, err)
+	}
+	id = hex.EncodeToString(idBytes[:])
+	cmd := fmt.Sprintf("SESSION CREATE STYLE=STREAM ID=%s DESTINATION=%s "+
+		"SIGNATURE_TYPE=%d", id, priv, i2pSignatureType)
+	if _, err := c.command(cmd, "SESSION STATUS"); err != nil {
+		return nil, "", nil, s.fail(c, err)
+	}
+
+	// Look up the public destination of the session to learn its
+	// address.
+	args, err := c.command("NAMING LOOKUP NAME=ME", "NAMING REPLY")
+	if err != nil {
+		return nil, "", nil, s.fail(c, err)
+	}
+	host, err := i2pHost(args["VALUE"])
+	if err != nil {
+		return nil, "", nil, s.fail(c, err)
+	}
+	local = &I2PAddr{Host: host, Port: I2PPort}
+
+	s.mtx.Lock()
+	delete(s.pending, c)
+	if s.closed {
+		s.mtx.Unlock()
+		c.Close()
+		return nil, "", nil, ErrI2PSessionClosed
+	}
+	s.ctrl, s.id, s.local = c, id, local
+	s.mtx.Unlock()
+	c.SetDeadline(time.Time{})
+	log.Infof("Created I2P session %s for %s", id, host)
+
+	// The session lasts as long as its control connection, so forget it
+	// once the SAM bridge closes the connection.
+	go func() {
+		io.Copy(ioutil.Discard, c.r)
+		s.resetSession(c)
+	}()
+
+	if s.cfg.OnSession != nil {
+		s.cfg.OnSession(local)
+	}
+	return c, id, local, nil
+}
+
+// resetSession forgets the SAM session with the passed control connection,
+// so a new one is created on next use.
+func (s *I2PSession) resetSession(ctrl *samConn) {
+	s.mtx.Lock()
+	if s.ctrl == ctrl {
+		s.ctrl = nil
+		if !s.closed {
+			log.Infof("I2P session %s lost", s.id)
+		}
+	}
+	s.mtx.Unlock()
+	ctrl.Close()
+}
+
+// checkSessionErr forgets the SAM session with the passed control connection
+// when the passed error means the bridge no longer knows it.
+func (s *I2PSession) checkSessionErr(ctrl *samConn, err error) {
+	if e, ok := err.(*I2PError); ok && e.Result == "INVALID_ID" {
+		s.resetSession(ctrl)
+	}
+}
+
+// Dial connects to the passed address, which must be a .b32.i2p host name and
+// a port, through the SAM bridge.
+func (s *I2PSession) Dial(addr string, timeout time.Duration) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasSuffix(host, i2pSuffix) {
+		return nil, fmt.Errorf("%s is not an i2p address", addr)
+	}
+	portNum, err := strconv.Atoi(port)
+	if err != nil {
+		return nil, err
+	}
+
+	ctrl, id, local, err := s.session()
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := s.connect(timeout)
+	if err != nil {
+		return nil, err
+	}
+	args, err := c.command("NAMING LOOKUP NAME="+host, "NAMING REPLY")
+	if err != nil {
+		return nil, s.fail(c, err)
+	}
+	cmd := fmt.Sprintf("STREAM CONNECT ID=%s DESTINATION=%s SILENT=false",
+		id, args["VALUE"])
+	if _, err := c.command(cmd, "STREAM STATUS"); err != nil {
+		s.checkSessionErr(ctrl, err)
+		return nil, s.fail(c, err)
+	}
+	s.release(c)
+
+	c.local = local
+	c.remote = &I2PAddr{Host: host, Port: portNum}
+	return c, nil
+}
+
+// Accept waits for and returns the next connection from an I2P peer.  The
+// remote address of the connection is the .b32.i2p host name of the peer.
+// Failures are reported after a delay, so callers may call Accept in a loop.
+//
+// This is part of the net.Listener interface.
+func (s *I2PSession) Accept() (net.Conn, error) {
+	c, err := s.accept()
+	if err != nil && err != ErrI2PSessionClosed {
+		select {
+		case <-time.After(i2pRetryInterval):
+		case <-s.quit:
+			return nil, ErrI2PSessionClosed
+		}
+	}
+	return c, err
+}
+
+// accept waits for and returns the next connection from an I2P peer.
+func (s *I2PSession) accept() (net.Conn, error) {
+	ctrl, id, local, err := s.session()
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := s.connect(i2pCommandTimeout)
+	if err != nil {
+		return nil, err
+	}
+	cmd := fmt.Sprintf("STREAM ACCEPT ID=%s SILENT=false", id)
+	if _, err := c.command(cmd, "STREAM STATUS"); err != nil {
+		s.checkSessionErr(ctrl, err)
+		return nil, s.fail(c, err)
+	}
+
+	// The bridge sends the destination of the peer, optionally followed
+	// by its ports, once a peer connects.
+	c.SetDeadline(time.Time{})
+	line, err := c.readLine()
+	if err != nil {
+		return nil, s.fail(c, err)
+	}
+	host, err := i2pHost(strings.Fields(line + " ")[0])
+	if err != nil {
+		return nil, s.fail(c, err)
+	}
+	s.release(c)
+
+	c.local = local
+	c.remote = &I2PAddr{Host: host, Port: I2PPort}
+	return c, nil
+}
+
+// Close closes the SAM session, which makes pending and future calls to Accept
+// and Dial fail.  Established connections are not closed.
+//
+// This is part of the net.Listener interface.
+func (s *I2PSession) Close() error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	close(s.quit)
+	if s.ctrl != nil {
+		s.ctrl.Close()
+	}
+	for c := range s.pending {
+		c.Close()
+	}
+	return nil
+}
+
+// samBridgeAddr implements the net.Addr interface and represents the SAM
+// bridge of an I2P session.
+type samBridgeAddr string
+
+// Network returns "i2p".
+//
+// This is part of the net.Addr interface.
+func (a samBridgeAddr) Network() string {
+	return "i2p"
+}
+
+// String returns the address of the SAM bridge.
+//
+// This is part of the net.Addr interface.
+func (a samBridgeAddr) String() string {
+	return "i2p sam bridge " + string(a)
+}
+
+// Addr returns the I2P address of the session, or the address of the SAM
+// bridge while the session has not been created yet.
+//
+// This is part of the net.Listener interface.
+func (s *I2PSession) Addr() net.Addr {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if s.local != nil {
+		return s.local
+	}
+	return samBridgeAddr(s.cfg.SAMAddr)
+}
@@ -0,0 +1,314 @@
+// Copyright (c) 2013-2016 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package connmgr
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// mockSAM is a mock SAM v3 bridge which supports the commands used by
+// I2PSession.  Streams to the destinations it knows echo the data they
+// receive, and incoming streams are pushed to accepting connections with
+// connectPeer.
+type mockSAM struct {
+	listener net.Listener
+
+	mtx       sync.Mutex
+	pub       string
+	priv      string
+	peers     map[string]string
+	sessions  map[string]net.Conn
+	generated int
+	created   int
+	accepts   chan net.Conn
+}
+
+// mockDest returns a fake base64 I2P destination derived from the passed
+// seed.
+func mockDest(seed byte) string {
+	return i2pEncoding.EncodeToString(bytes.Repeat([]byte{seed}, 387))
+}
+
+// newMockSAM starts a mock SAM bridge which knows the passed destinations.
+func newMockSAM(t *testing.T, peers ...string) *mockSAM {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	m := &mockSAM{
+		listener: listener,
+		pub:      mockDest(0x01),
+		priv:     mockDest(0x01) + "AAAA",
+		peers:    make(map[string]string),
+		sessions: make(map[string]net.Conn),
+		accepts:  make(chan net.Conn, 1),
+	}
+	for _, dest := range peers {
+		host, _ := i2pHost(dest)
+		m.peers[host] = dest
+	}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go m.serve(conn)
+		}
+	}()
+	return m
+}
+
+// serve replies to the commands read from the passed connection.
+func (m *mockSAM) serve(conn net.Conn) {
+	r := bufio.NewReader(conn)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			conn.Close()
+			return
+		}
+		line = strings.TrimSpace(line)
+		args, _ := parseTorReplyArgs(line)
+		fields := strings.Fields(line)
+
+		m.mtx.Lock()
+		switch fields[0] + " " + fields[1] {
+		case "HELLO VERSION":
+			fmt.Fprintf(conn, "HELLO REPLY RESULT=OK VERSION=3.1\n")
+
+		case "DEST GENERATE":
+			m.generated++
+			fmt.Fprintf(conn, "DEST REPLY PUB=%s PRIV=%s\n", m.pub,
+				m.priv)
+
+		case "SESSION CREATE":
+			if args["DESTINATION"] != m.priv {
+				fmt.Fprintf(conn, "SESSION STATUS RESULT=INVALID_KEY\n")
+				break
+			}
+			m.created++
+			m.sessions[args["ID"]] = conn
+			fmt.Fprintf(conn, "SESSION STATUS RESULT=OK "+
+				"DESTINATION=%s\n", m.priv)
+
+		case "NAMING LOOKUP":
+			dest := m.peers[args["NAME"]]
+			if args["NAME"] == "ME" {
+				dest = m.pub
+			}
+			if dest == "" {
+				fmt.Fprintf(conn, "NAMING REPLY RESULT=KEY_NOT_FOUND "+
+					"NAME=%s\n", args["NAME"])
+				break
+			}
+			fmt.Fprintf(conn, "NAMING REPLY RESULT=OK NAME=%s "+
+				"VALUE=%s\n", args["NAME"], dest)
+
+		case "STREAM CONNECT":
+			if m.sessions[args["ID"]] == nil {
+				fmt.Fprintf(conn, "STREAM STATUS RESULT=INVALID_ID\n")
+				break
+			}
+			fmt.Fprintf(conn, "STREAM STATUS RESULT=OK\n")
+			m.mtx.Unlock()
+			io.Copy(conn, r)
+			conn.Close()
+			return
+
+		case "STREAM ACCEPT":
+			if m.sessions[args["ID"]] == nil {
+				fmt.Fprintf(conn, "STREAM STATUS RESULT=INVALID_ID\n")
+				break
+			}
+			fmt.Fprintf(conn, "STREAM STATUS RESULT=OK\n")
+			m.mtx.Unlock()
+			m.accepts <- conn
+			return
+		}
+		m.mtx.Unlock()
+	}
+}
+
+// connectPeer makes the passed destination connect to the session, which
+// sends the passed data.
+func (m *mockSAM) connectPeer(dest, data string) {
+	conn := <-m.accepts
+	fmt.Fprintf(conn, "%s FROM_PORT=0 TO_PORT=0\n%s", dest, data)
+}
+
+// dropSessions closes the control connections of the sessions, which ends
+// them.
+func (m *mockSAM) dropSessions() {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	for id, conn := range m.sessions {
+		conn.Close()
+		delete(m.sessions, id)
+	}
+}
+
+// counts returns the number of destinations generated and sessions created.
+func (m *mockSAM) counts() (int, int) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	return m.generated, m.created
+}
+
+// TestI2PSession tests dialing and accepting I2P connections through a mock
+// SAM bridge.
+func TestI2PSession(t *testing.T) {
+	dir, err := ioutil.TempDir("", "i2psession")
+	if err != nil {
+		t.Fatalf("TempDir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	peerDest := mockDest(0x02)
+	peerHost, _ := i2pHost(peerDest)
+	sam := newMockSAM(t, peerDest)
+	defer sam.listener.Close()
+
+	keyFile := filepath.Join(dir, "i2p_private_key")
+	sessions := make(chan *I2PAddr, 2)
+	s := NewI2PSession(&I2PConfig{
+		SAMAddr:   sam.listener.Addr().String(),
+		KeyFile:   keyFile,
+		OnSession: func(addr *I2PAddr) { sessions <- addr },
+	})
+	defer s.Close()
+
+	if _, ok := s.Addr().(samBridgeAddr); !ok {
+		t.Fatalf("Addr: unexpected address %v before the session is "+
+			"created", s.Addr())
+	}
+
+	// Dialing creates the session along with a new destination which is
+	// saved to the key file.
+	conn, err := s.Dial(peerHost+":0", time.Second)
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	if _, err := conn.Write([]byte("ping")); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	buf := make([]byte, 4)
+	if _, err := io.ReadFull(conn, buf); err != nil || string(buf) != "ping" {
+		t.Fatalf("Read: got %q (%v), want %q", buf, err, "ping")
+	}
+	if conn.RemoteAddr().String() != peerHost+":0" {
+		t.Fatalf("RemoteAddr: got %v, want %s:0", conn.RemoteAddr(),
+			peerHost)
+	}
+	conn.Close()
+
+	localHost, _ := i2pHost(sam.pub)
+	select {
+	case addr := <-sessions:
+		if addr.Host != localHost {
+			t.Fatalf("OnSession: got %s, want %s", addr.Host,
+				localHost)
+		}
+	default:
+		t.Fatalf("OnSession was not invoked")
+	}
+	if s.Addr().String() != localHost+":0" {
+		t.Fatalf("Addr: got %v, want %s:0", s.Addr(), localHost)
+	}
+	key, err := ioutil.ReadFile(keyFile)
+	if err != nil || strings.TrimSpace(string(key)) != sam.priv {
+		t.Fatalf("unexpected key file contents %q (%v)", key, err)
+	}
+
+	// Dialing an unknown destination fails with the error of the bridge.
+	_, err = s.Dial(strings.Repeat("a", 52)+".b32.i2p:0", time.Second)
+	if e, ok := err.(*I2PError); !ok || e.Result != "KEY_NOT_FOUND" {
+		t.Fatalf("Dial: unexpected error %v", err)
+	}
+	if _, err := s.Dial("127.0.0.1:0", time.Second); err == nil {
+		t.Fatalf("Dial: dialing a non i2p address succeeded")
+	}
+
+	// Accepted connections carry the address of the peer.
+	go sam.connectPeer(peerDest, "hello")
+	conn, err = s.Accept()
+	if err != nil {
+		t.Fatalf("Accept: %v", err)
+	}
+	buf = make([]byte, 5)
+	if _, err := io.ReadFull(conn, buf); err != nil || string(buf) != "hello" {
+		t.Fatalf("Read: got %q (%v), want %q", buf, err, "hello")
+	}
+	if conn.RemoteAddr().String() != peerHost+":0" {
+		t.Fatalf("RemoteAddr: got %v, want %s:0", conn.RemoteAddr(),
+			peerHost)
+	}
+	conn.Close()
+
+	// A lost session is created again on next use with the saved key.
+	sam.dropSessions()
+	for i := 0; ; i++ {
+		conn, err = s.Dial(peerHost+":0", time.Second)
+		if err == nil {
+			conn.Close()
+			break
+		}
+		if i == 100 {
+			t.Fatalf("Dial after the session was lost: %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if generated, created := sam.counts(); generated != 1 || created != 2 {
+		t.Fatalf("unexpected number of destinations generated (%d) "+
+			"and sessions created (%d)", generated, created)
+	}
+
+	// Closing the session interrupts pending accepts.
+	errChan := make(chan error, 1)
+	go func() {
+		_, err := s.Accept()
+		errChan <- err
+	}()
+	<-sam.accepts
+	s.Close()
+	select {
+	case err := <-errChan:
+		if err != ErrI2PSessionClosed {
+			t.Fatalf("Accept: got %v, want %v", err,
+				ErrI2PSessionClosed)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Accept was not interrupted by Close")
+	}
+	if _, err := s.Dial(peerHost+":0", time.Second); err != ErrI2PSessionClosed {
+		t.Fatalf("Dial: got %v, want %v", err, ErrI2PSessionClosed)
+	}
+}
+
+// TestI2PHost ensures the host names of I2P destinations are computed as
+// expected.
+func TestI2PHost(t *testing.T) {
+	host, err := i2pHost(mockDest(0x00))
+	if err != nil {
+		t.Fatalf("i2pHost: %v", err)
+	}
+	if !strings.HasSuffix(host, ".b32.i2p") || len(host) != 52+8 {
+		t.Fatalf("i2pHost: unexpected host %s", host)
+	}
+	if _, err := i2pHost("not+base64/"); err == nil {
+		t.Fatalf("i2pHost: invalid destination accepted")
+	}
+}
@@ -0,0 +1,339 @@
+// Copyright (c) 2013-2016 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package connmgr
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+const (
+	// torControlOK is the status code of a successful Tor control port
+	// reply.
+	torControlOK = 250
+
+	// torControlDialTimeout is the timeout used when dialing the Tor
+	// control port.
+	torControlDialTimeout = 10 * time.Second
+
+	// torCookieLen is the size of the authentication cookie of the Tor
+	// control port.
+	torCookieLen = 32
+
+	// torNonceLen is the size of the nonces exchanged by the SAFECOOKIE
+	// authentication method.
+	torNonceLen = 32
+
+	// torOnionKeyNew is passed instead of a private key to ADD_ONION to
+	// create a hidden service with a new Tor v3 key.
+	torOnionKeyNew = "NEW:ED25519-V3"
+)
+
+// Tor control port authentication methods as listed by PROTOCOLINFO.
+const (
+	torAuthNull           = "NULL"
+	torAuthHashedPassword = "HASHEDPASSWORD"
+	torAuthCookie         = "COOKIE"
+	torAuthSafeCookie     = "SAFECOOKIE"
+)
+
+var (
+	// ErrTorControlNoAuthMethod indicates none of the authentication
+	// methods offered by the Tor control port can be used.
+	ErrTorControlNoAuthMethod = errors.New("no supported tor control " +
+		"authentication method")
+
+	// ErrTorControlInvalidReply indicates the Tor control port returned a
+	// reply in an unexpected format.
+	ErrTorControlInvalidReply = errors.New("invalid tor control reply")
+
+	// ErrTorControlServerHash indicates the Tor control port failed to
+	// prove it knows the authentication cookie during SAFECOOKIE
+	// authentication.
+	ErrTorControlServerHash = errors.New("tor control server hash " +
+		"mismatch")
+
+	// torSafeCookieServerKey and torSafeCookieClientKey are the HMAC keys
+	// used to prove knowledge of the authentication cookie during
+	// SAFECOOKIE authentication.
+	torSafeCookieServerKey = []byte("Tor safe cookie authentication " +
+		"server-to-controller hash")
+	torSafeCookieClientKey = []byte("Tor safe cookie authentication " +
+		"controller-to-server hash")
+)
+
+// TorControl is a client of the Tor control port which is used to create
+// hidden services.  Hidden services created with AddOnion are ephemeral, so
+// they are removed by Tor once the connection is closed.
+type TorControl struct {
+	conn *textproto.Conn
+}
+
+// NewTorControl returns a Tor control port client which uses the passed
+// connection.
+func NewTorControl(conn net.Conn) *TorControl {
+	return &TorControl{conn: textproto.NewConn(conn)}
+}
+
+// DialTorControl connects to the Tor control port at the passed address.
+func DialTorControl(addr string) (*TorControl, error) {
+	conn, err := net.DialTimeout("tcp", addr, torControlDialTimeout)
+	if err != nil {
+		return nil, err
+	}
+	return NewTorControl(conn), nil
+}
+
+// Close closes the connection to the Tor control port, which removes the
+// hidden services created by it.
+func (c *TorControl) Close() error {
+	return c.conn.Close()
+}
+
+// Wait blocks until the connection to the Tor control port is closed, either
+// by Close or because Tor exits, and returns the error which ended it.  Tor
+// only sends replies to commands unless events are requested, so it must not
+// be called while a command is in progress.
+func (c *TorControl) Wait() error {
+	for {
+		if _, err := c.conn.ReadLine(); err != nil {
+			return err
+		}
+	}
+}
+
+// command sends the passed command to the Tor control port and returns the
+// lines of its reply, without their status code, when it is successful.
+func (c *TorControl) command(format string, args ...interface{}) ([]string, error) {
+	id, err := c.conn.Cmd(format, args...)
+	if err != nil {
+		return nil, err
+	}
+	c.conn.StartResponse(id)
+	defer c.conn.EndResponse(id)
+
+	_, msg, err := c.conn.ReadResponse(torControlOK)
+	if err != nil {
+		return nil, err
+	}
+	return strings.Split(msg, "\n"), nil
+}
+
+// Authenticate authenticates to the Tor control port.  The password is used
+// when it is set, otherwise the authentication cookie of Tor is used, or no
+// authentication at all when Tor does not require any.
+func (c *TorControl) Authenticate(password string) error {
+	lines, err := c.command("PROTOCOLINFO 1")
+	if err != nil {
+		return err
+	}
+
+	var methods map[string]bool
+	var cookieFile string
+	for _, line := range lines {
+		if !strings.HasPrefix(line, "AUTH ") {
+			continue
+		}
+		args, err := parseTorReplyArgs(strings.TrimPrefix(line, "AUTH "))
+		if err != nil {
+			return err
+		}
+		methods = make(map[string]bool)
+		for _, method := range strings.Split(args["METHODS"], ",") {
+			methods[method] = true
+		}
+		cookieFile = args["COOKIEFILE"]
+	}
+	if methods == nil {
+		return ErrTorControlInvalidReply
+	}
+
+	switch {
+	case password != "":
+		if !methods[torAuthHashedPassword] {
+			return ErrTorControlNoAuthMethod
+		}
+		_, err = c.command("AUTHENTICATE %s", quoteTorString(password))
+		return err
+
+	case methods[torAuthSafeCookie] && cookieFile != "":
+		cookie, err := readTorCookie(cookieFile)
+		if err != nil {
+			return err
+		}
+		return c.authenticateSafeCookie(cookie)
+
+	case methods[torAuthCookie] && cookieFile != "":
+		cookie, err := readTorCookie(cookieFile)
+		if err != nil {
+			return err
+		}
+		_, err = c.command("AUTHENTICATE %x", cookie)
+		return err
+
+	case methods[torAuthNull]:
+		_, err = c.command("AUTHENTICATE")
+		return err
+	}
+
+	return ErrTorControlNoAuthMethod
+}
+
+// authenticateSafeCookie authenticates to the Tor control port with the
+// SAFECOOKIE method, which proves knowledge of the cookie without sending it.
+func (c *TorControl) authenticateSafeCookie(cookie []byte) error {
+	clientNonce := make([]byte, torNonceLen)
+	if _, err := rand.Read(clientNonce); err != nil {
+		return err
+	}
+
+	lines, err := c.command("AUTHCHALLENGE SAFECOOKIE %x", clientNonce)
+	if err != nil {
+		return err
+	}
+	if len(lines) != 1 || !strings.HasPrefix(lines[0], "AUTHCHALLENGE ") {
+		return ErrTorControlInvalidReply
+	}
+	args, err := parseTorReplyArgs(strings.TrimPrefix(lines[0],
+		"AUTHCHALLENGE "))
+	if err != nil {
+		return err
+	}
+	serverHash, err := hex.DecodeString(args["SERVERHASH"])
+	if err != nil {
+		return ErrTorControlInvalidReply
+	}
+	serverNonce, err := hex.DecodeString(args["SERVERNONCE"])
+	if err != nil || len(serverNonce) != torNonceLen {
+		return ErrTorControlInvalidReply
+	}
+
+	msg := make([]byte, 0, len(cookie)+len(clientNonce)+len(serverNonce))
+	msg = append(msg, cookie...)
+	msg = append(msg, clientNonce...)
+	msg = append(msg, serverNonce...)
+	if !hmac.Equal(serverHash, torHMAC(torSafeCookieServerKey, msg)) {
+		return ErrTorControlServerHash
+	}
+
+	_, err = c.command("AUTHENTICATE %x", torHMAC(torSafeCookieClientKey, msg))
+	return err
+}
+
+// AddOnion creates a hidden service which forwards connections to the passed
+// virtual port to the target address.  The private key is of the form
+// ED25519-V3:<base64 key> as returned by a previous call, or empty to create
+// a new Tor v3 key.  It returns the service ID, which is the onion host name
+// without its .onion suffix, and the private key when a new one was created.
+func (c *TorControl) AddOnion(privateKey string, virtPort uint16,
+	target string) (string, string, error) {
+
+	if privateKey == "" {
+		privateKey = torOnionKeyNew
+	}
+	lines, err := c.command("ADD_ONION %s Port=%d,%s", privateKey, virtPort,
+		target)
+	if err != nil {
+		return "", "", err
+	}
+
+	var serviceID, newKey string
+	for _, line := range lines {
+		switch {
+		case strings.HasPrefix(line, "ServiceID="):
+			serviceID = strings.TrimPrefix(line, "ServiceID=")
+		case strings.HasPrefix(line, "PrivateKey="):
+			newKey = strings.TrimPrefix(line, "PrivateKey=")
+		}
+	}
+	if serviceID == "" {
+		return "", "", ErrTorControlInvalidReply
+	}
+	return serviceID, newKey, nil
+}
+
+// torHMAC returns the HMAC-SHA256 of the passed message with the passed key.
+func torHMAC(key, msg []byte) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write(msg)
+	return h.Sum(nil)
+}
+
+// readTorCookie reads the authentication cookie of the Tor control port from
+// the passed file.
+func readTorCookie(path string) ([]byte, error) {
+	cookie, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(cookie) != torCookieLen {
+		return nil, fmt.Errorf("tor authentication cookie %s has an "+
+			"invalid size of %d bytes", path, len(cookie))
+	}
+	return cookie, nil
+}
+
+// quoteTorString returns the passed string as a quoted string of the Tor
+// control protocol.
+func quoteTorString(s string) string {
+	s = strings.Replace(s, `\`, `\\`, -1)
+	s = strings.Replace(s, `"`, `\"`, -1)
+	return `"` + s + `"`
+}
+
+// parseTorReplyArgs parses the space separated KEY=VALUE arguments of a Tor
+// control port reply line.  Values may be quoted strings.  Arguments without a
+// value are ignored.
+func parseTorReplyArgs(s string) (map[string]string, error) {
+	args := make(map[string]string)
+	for len(s) > 0 {
+		s = strings.TrimLeft(s, " ")
+		end := strings.IndexAny(s, " =")
+		if end == -1 {
+			break
+		}
+		if s[end] == ' ' {
+			s = s[end:]
+			continue
+		}
+		key := s[:end]
+		s = s[end+1:]
+
+		if !strings.HasPrefix(s, `"`) {
+			end = strings.IndexByte(s, ' ')
+			if end == -1 {
+				end = len(s)
+			}
+			args[key] = s[:end]
+			s = s[end:]
+			continue
+		}
+
+		var value bytes.Buffer
+		i := 1
+		for ; i < len(s) && s[i] != '"'; i++ {
+			if s[i] == '\\' && i+1 < len(s) {
+				i++
+			}
+			value.WriteByte(s[i])
+		}
+		if i == len(s) {
+			return nil, ErrTorControlInvalidReply
+		}
+		args[key] = value.String()
+		s = s[i+1:]
+	}
+	return args, nil
+}
@@ -0,0 +1,343 @@
+// Copyright (c) 2013-2016 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package connmgr
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeTorControl is a fake Tor control port which supports the commands used
+// to authenticate and create hidden services.
+type fakeTorControl struct {
+	methods    string
+	cookieFile string
+	cookie     []byte
+	password   string
+
+	// serverNonce is the nonce sent in reply to AUTHCHALLENGE and
+	// clientNonce the one received with it.
+	serverNonce []byte
+	clientNonce []byte
+
+	authenticated bool
+	commands      []string
+}
+
+// serve replies to the commands read from the passed connection until it is
+// closed.
+func (f *fakeTorControl) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		f.commands = append(f.commands, line)
+		fmt.Fprint(conn, f.reply(line))
+	}
+}
+
+// reply returns the reply of the fake Tor control port to the passed command.
+func (f *fakeTorControl) reply(cmd string) string {
+	fields := strings.Fields(cmd)
+	switch fields[0] {
+	case "PROTOCOLINFO":
+		return fmt.Sprintf("250-PROTOCOLINFO 1\r\n"+
+			"250-AUTH METHODS=%s COOKIEFILE=%q\r\n"+
+			"250-VERSION Tor=\"0.4.8.9\"\r\n250 OK\r\n", f.methods,
+			f.cookieFile)
+
+	case "AUTHCHALLENGE":
+		f.clientNonce, _ = hex.DecodeString(fields[2])
+		msg := append(append(append([]byte(nil), f.cookie...),
+			f.clientNonce...), f.serverNonce...)
+		return fmt.Sprintf("250 AUTHCHALLENGE SERVERHASH=%x "+
+			"SERVERNONCE=%x\r\n", torHMAC(torSafeCookieServerKey, msg),
+			f.serverNonce)
+
+	case "AUTHENTICATE":
+		var want string
+		switch {
+		case f.password != "":
+			want = quoteTorString(f.password)
+		case f.clientNonce != nil:
+			msg := append(append(append([]byte(nil), f.cookie...),
+				f.clientNonce...), f.serverNonce...)
+			want = hex.EncodeToString(torHMAC(torSafeCookieClientKey,
+				msg))
+		case f.cookie != nil:
+			want = hex.EncodeToString(f.cookie)
+		}
+		if strings.TrimSpace(strings.TrimPrefix(cmd, "AUTHENTICATE")) != want {
+			return "515 Authentication failed\r\n"
+		}
+		f.authenticated = true
+		return "250 OK\r\n"
+
+	case "ADD_ONION":
+		if !f.authenticated {
+			return "514 Authentication required.\r\n"
+		}
+		reply := "250-ServiceID=2gzyxa5ihm7nsggfxnu52rck2vv4rvmdlkiu3zzui5du4xyclen53wid\r\n"
+		if fields[1] == torOnionKeyNew {
+			reply += "250-PrivateKey=ED25519-V3:a2V5\r\n"
+		}
+		return reply + "250 OK\r\n"
+	}
+
+	return "510 Unrecognized command\r\n"
+}
+
+// TestTorControl tests authenticating to a fake Tor control port and creating
+// hidden services with it.
+func TestTorControl(t *testing.T) {
+	dir, err := ioutil.TempDir("", "torcontrol")
+	if err != nil {
+		t.Fatalf("TempDir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	cookie := bytes.Repeat([]byte{0x42}, torCookieLen)
+	cookieFile := filepath.Join(dir, "control_auth_cookie")
+	if err := ioutil.WriteFile(cookieFile, cookie, 0600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	shortCookieFile := filepath.Join(dir, "short_cookie")
+	if err := ioutil.WriteFile(shortCookieFile, cookie[:8], 0600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	serverNonce := bytes.Repeat([]byte{0x24}, torNonceLen)
+
+	tests := []struct {
+		name       string
+		server     fakeTorControl
+		password   string
+		privateKey string
+		wantErr    bool
+		wantKey    string
+		wantCmd    string
+	}{
+		{
+			name:    "no authentication",
+			server:  fakeTorControl{methods: "NULL"},
+			wantKey: "ED25519-V3:a2V5",
+			wantCmd: "ADD_ONION NEW:ED25519-V3 Port=9999,127.0.0.1:19999",
+		},
+		{
+			name: "password",
+			server: fakeTorControl{
+				methods:  "HASHEDPASSWORD",
+				password: `pass "word\`,
+			},
+			password: `pass "word\`,
+			wantKey:  "ED25519-V3:a2V5",
+			wantCmd:  "ADD_ONION NEW:ED25519-V3 Port=9999,127.0.0.1:19999",
+		},
+		{
+			name: "wrong password",
+			server: fakeTorControl{
+				methods:  "HASHEDPASSWORD",
+				password: "password",
+			},
+			password: "wrong",
+			wantErr:  true,
+		},
+		{
+			name: "password not supported",
+			server: fakeTorControl{
+				methods:    "COOKIE",
+				cookieFile: cookieFile,
+				cookie:     cookie,
+			},
+			password: "password",
+			wantErr:  true,
+		},
+		{
+			name: "cookie",
+			server: fakeTorControl{
+				methods:    "COOKIE",
+				cookieFile: cookieFile,
+				cookie:     cookie,
+			},
+			privateKey: "ED25519-V3:a2V5",
+			wantCmd:    "ADD_ONION ED25519-V3:a2V5 Port=9999,127.0.0.1:19999",
+		},
+		{
+			name: "safe cookie",
+			server: fakeTorControl{
+				methods:     "COOKIE,SAFECOOKIE",
+				cookieFile:  cookieFile,
+				cookie:      cookie,
+				serverNonce: serverNonce,
+			},
+			wantKey: "ED25519-V3:a2V5",
+			wantCmd: "ADD_ONION NEW:ED25519-V3 Port=9999,127.0.0.1:19999",
+		},
+		{
+			name: "safe cookie server does not know the cookie",
+			server: fakeTorControl{
+				methods:     "SAFECOOKIE",
+				cookieFile:  cookieFile,
+				cookie:      bytes.Repeat([]byte{0x01}, torCookieLen),
+				serverNonce: serverNonce,
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid cookie file",
+			server: fakeTorControl{
+				methods:    "COOKIE",
+				cookieFile: shortCookieFile,
+				cookie:     cookie[:8],
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		fake := test.server
+		client, server := net.Pipe()
+		go fake.serve(server)
+		c := NewTorControl(client)
+
+		err := c.Authenticate(test.password)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("%s: Authenticate succeeded, want error",
+					test.name)
+			}
+			c.Close()
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: Authenticate: %v", test.name, err)
+			c.Close()
+			continue
+		}
+
+		serviceID, key, err := c.AddOnion(test.privateKey, 9999,
+			"127.0.0.1:19999")
+		c.Close()
+		if err != nil {
+			t.Errorf("%s: AddOnion: %v", test.name, err)
+			continue
+		}
+		if serviceID != "2gzyxa5ihm7nsggfxnu52rck2vv4rvmdlkiu3zzui5du4xyclen53wid" {
+			t.Errorf("%s: unexpected service ID %s", test.name,
+				serviceID)
+		}
+		if key != test.wantKey {
+			t.Errorf("%s: unexpected private key - got %q, want %q",
+				test.name, key, test.wantKey)
+		}
+		lastCmd := fake.commands[len(fake.commands)-1]
+		if lastCmd != test.wantCmd {
+			t.Errorf("%s: unexpected command - got %q, want %q",
+				test.name, lastCmd, test.wantCmd)
+		}
+	}
+}
+
+// TestParseTorReplyArgs ensures the arguments of Tor control port replies are
+// parsed as expected.
+func TestParseTorReplyArgs(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    map[string]string
+		wantErr bool
+	}{
+		{
+			in: `METHODS=COOKIE,SAFECOOKIE COOKIEFILE="/var/run/tor/control.authcookie"`,
+			want: map[string]string{
+				"METHODS":    "COOKIE,SAFECOOKIE",
+				"COOKIEFILE": "/var/run/tor/control.authcookie",
+			},
+		},
+		{
+			in: `SERVERHASH=ab SERVERNONCE=cd`,
+			want: map[string]string{
+				"SERVERHASH":  "ab",
+				"SERVERNONCE": "cd",
+			},
+		},
+		{
+			in: `FLAG KEY="a \"quoted\" \\ value" OTHER`,
+			want: map[string]string{
+				"KEY": `a "quoted" \ value`,
+			},
+		},
+		{
+			in:      `KEY="unterminated`,
+			wantErr: true,
+		},
+	}
+
+	for i, test := range tests {
+		got, err := parseTorReplyArgs(test.in)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("parseTorReplyArgs #%d: succeeded, want "+
+					"error", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseTorReplyArgs #%d: %v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("parseTorReplyArgs #%d: got %v, want %v", i,
+				got, test.want)
+		}
+	}
+}
+
+// TestTorControlWait ensures Wait returns once the Tor control port closes the
+// connection, which happens when Tor exits.
+func TestTorControlWait(t *testing.T) {
+	fake := fakeTorControl{methods: "NULL"}
+	client, server := net.Pipe()
+	go fake.serve(server)
+	c := NewTorControl(client)
+	defer c.Close()
+
+	if err := c.Authenticate(""); err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- c.Wait()
+	}()
+	select {
+	case err := <-done:
+		t.Fatalf("Wait returned before the connection was closed: %v",
+			err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	server.Close()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Fatal("Wait returned no error")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Wait did not return after the connection was closed")
+	}
+}
@@ -0,0 +1,121 @@
+// Copyright (c) 2015-2016 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/eager7/dashd/blockchain"
+	"github.com/eager7/dashd/chaincfg"
+	"github.com/eager7/dashd/chaincfg/chainhash"
+)
+
+// loadTxOutSetCmd defines the configuration options for the loadtxoutset
+// command.
+type loadTxOutSetCmd struct {
+	UtxoSnapshot string `long:"utxosnapshot" description:"Allow loading a snapshot which is not pinned by the chain parameters with the given content hash taken at the given block.  Format: '<height>:<blockhash>:<contenthash>'"`
+}
+
+var (
+	// loadTxOutSetCfg defines the configuration options for the command.
+	loadTxOutSetCfg = loadTxOutSetCmd{}
+)
+
+// Execute is the main entry point for the command.  It's invoked by the parser.
+func (cmd *loadTxOutSetCmd) Execute(args []string) error {
+	// Setup the global config options and ensure they are valid.
+	if err := setupGlobalConfig(); err != nil {
+		return err
+	}
+
+	if len(args) < 1 {
+		return errors.New("required snapshot file parameter not " +
+			"specified")
+	}
+	f, err := os.Open(args[0])
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	// Load the block database.
+	db, err := loadBlockDB()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	var snapshots []chaincfg.UtxoSnapshot
+	if cmd.UtxoSnapshot != "" {
+		snapshot, err := parseUtxoSnapshot(cmd.UtxoSnapshot)
+		if err != nil {
+			return err
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+
+	chain, err := blockchain.New(&blockchain.Config{
+		DB:            db,
+		ChainParams:   activeNetParams,
+		UtxoSnapshots: snapshots,
+		TimeSource:    blockchain.NewMedianTime(),
+	})
+	if err != nil {
+		return err
+	}
+
+	startTime := time.Now()
+	info, err := chain.LoadUtxoSnapshot(bufio.NewReader(f))
+	if err != nil {
+		return err
+	}
+	log.Infof("Loaded %d unspent outputs at block %v (height %d) in %v",
+		info.NumUtxos, info.BlockHash, info.Height,
+		time.Since(startTime))
+	return nil
+}
+
+// parseUtxoSnapshot parses a utxo snapshot in the
+// '<height>:<blockhash>:<contenthash>' format.
+func parseUtxoSnapshot(snapshot string) (chaincfg.UtxoSnapshot, error) {
+	parts := strings.Split(snapshot, ":")
+	if len(parts) != 3 {
+		return chaincfg.UtxoSnapshot{}, fmt.Errorf("unable to parse "+
+			"utxo snapshot %q -- use the syntax "+
+			"<height>:<blockhash>:<contenthash>", snapshot)
+	}
+	height, err := strconv.ParseInt(parts[0], 10, 32)
+	if err != nil {
+		return chaincfg.UtxoSnapshot{}, fmt.Errorf("unable to parse "+
+			"utxo snapshot %q due to malformed height", snapshot)
+	}
+	blockHash, err := chainhash.NewHashFromStr(parts[1])
+	if err != nil || len(parts[1]) == 0 {
+		return chaincfg.UtxoSnapshot{}, fmt.Errorf("unable to parse "+
+			"utxo snapshot %q due to malformed block hash", snapshot)
+	}
+	contentHash, err := chainhash.NewHashFromStr(parts[2])
+	if err != nil || len(parts[2]) == 0 {
+		return chaincfg.UtxoSnapshot{}, fmt.Errorf("unable to parse "+
+			"utxo snapshot %q due to malformed content hash",
+			snapshot)
+	}
+	return chaincfg.UtxoSnapshot{
+		Height:      int32(height),
+		BlockHash:   blockHash,
+		ContentHash: contentHash,
+	}, nil
+}
+
+// Usage overrides the usage display for the command.
+func (cmd *loadTxOutSetCmd) Usage() string {
+	return "<snapshot-file>"
+}
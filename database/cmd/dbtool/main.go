@@ -89,6 +89,9 @@ func realMain() error {
 	parser.AddCommand("fetchblockregion",
 		"Fetch the specified block region from the database", "",
 		&blockRegionCfg)
+	parser.AddCommand("loadtxoutset",
+		"Load a utxo set snapshot written by dumptxoutset into an empty "+
+			"database", "", &loadTxOutSetCfg)
 
 	// Parse command line and invoke the Execute function for the specified
 	// command.
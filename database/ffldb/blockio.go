@@ -15,6 +15,8 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"sync"
 
 	"github.com/eager7/dashd/chaincfg/chainhash"
@@ -307,6 +309,105 @@ func (s *blockStore) deleteFile(fileNum uint32) error {
 	return nil
 }
 
+// closeFile closes the read-only file handle for the passed flat file number
+// when it is open and removes it from the least recently used tracking.
+func (s *blockStore) closeFile(fileNum uint32) {
+	s.obfMutex.Lock()
+	defer s.obfMutex.Unlock()
+
+	obf, ok := s.openBlockFiles[fileNum]
+	if !ok {
+		return
+	}
+
+	s.lruMutex.Lock()
+	s.openBlocksLRU.Remove(s.fileNumToLRUElem[fileNum])
+	delete(s.fileNumToLRUElem, fileNum)
+	s.lruMutex.Unlock()
+
+	// Close the file under the write lock for the file in case any readers
+	// are currently reading from it so it's not closed out from under
+	// them.
+	obf.Lock()
+	_ = obf.file.Close()
+	obf.Unlock()
+
+	delete(s.openBlockFiles, fileNum)
+}
+
+// filesToPrune returns the numbers of the oldest flat block files which need to
+// be deleted for the total size of the remaining files to be no more than the
+// passed target size.  The current write file and the files numbered
+// keepFileNum and higher are never selected, so the total size may remain above
+// the target.  The files with the passed numbers are already about to be
+// deleted, so they are neither selected again nor counted.
+//
+// NOTE: This function must only be called during a write transaction so it is
+// effectively locked for writes.
+func (s *blockStore) filesToPrune(targetSize uint64, keepFileNum uint32, pending map[uint32]struct{}) ([]uint32, error) {
+	firstFile := firstBlockFile(s.basePath)
+	if firstFile == -1 {
+		return nil, nil
+	}
+
+	wc := s.writeCursor
+	wc.RLock()
+	curFileNum := wc.curFileNum
+	wc.RUnlock()
+
+	// Determine the size of all of the files.
+	var totalSize uint64
+	fileSizes := make(map[uint32]uint64)
+	for fileNum := uint32(firstFile); fileNum <= curFileNum; fileNum++ {
+		if _, ok := pending[fileNum]; ok {
+			continue
+		}
+		// Files which failed to be deleted before are also pruned,
+		// so later files may be missing.
+		st, err := os.Stat(blockFilePath(s.basePath, fileNum))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, makeDbErr(database.ErrDriverSpecific,
+				err.Error(), err)
+		}
+		fileSizes[fileNum] = uint64(st.Size())
+		totalSize += uint64(st.Size())
+	}
+
+	var prune []uint32
+	for fileNum := uint32(firstFile); totalSize > targetSize &&
+		fileNum < curFileNum && fileNum < keepFileNum; fileNum++ {
+
+		size, ok := fileSizes[fileNum]
+		if !ok {
+			continue
+		}
+		prune = append(prune, fileNum)
+		totalSize -= size
+	}
+
+	return prune, nil
+}
+
+// pruneFiles deletes the flat block files with the passed numbers, which must
+// not include the current write file.  A failure to delete a file is only
+// logged since its blocks have already been removed from the block index, so
+// the file is no longer used either way.
+//
+// NOTE: This function must only be called during a write transaction so it is
+// effectively locked for writes.
+func (s *blockStore) pruneFiles(fileNums []uint32) {
+	for _, fileNum := range fileNums {
+		s.closeFile(fileNum)
+		if err := s.deleteFileFunc(fileNum); err != nil {
+			log.Warnf("Unable to delete pruned block file %d: %v",
+				fileNum, err)
+		}
+	}
+}
+
 // blockFile attempts to return an existing file handle for the passed flat file
 // number if it is already open as well as marking it as most recently used.  It
 // will also open the file when it's not already open subject to the rules
@@ -720,7 +821,11 @@ func (s *blockStore) handleRollback(oldBlockFileNum, oldBlockOffset uint32) {
 func scanBlockFiles(dbPath string) (int, uint32) {
 	lastFile := -1
 	fileLen := uint32(0)
-	for i := 0; ; i++ {
+	firstFile := firstBlockFile(dbPath)
+	if firstFile == -1 {
+		firstFile = 0
+	}
+	for i := firstFile; ; i++ {
 		filePath := blockFilePath(dbPath, uint32(i))
 		st, err := os.Stat(filePath)
 		if err != nil {
@@ -736,6 +841,37 @@ func scanBlockFiles(dbPath string) (int, uint32) {
 	return lastFile, fileLen
 }
 
+// firstBlockFile returns the number of the oldest flat block file in the
+// database directory.  The files before it have been deleted when the database
+// has been pruned.  The return value is -1 when there are no block files.
+func firstBlockFile(dbPath string) int {
+	dir, err := os.Open(dbPath)
+	if err != nil {
+		return -1
+	}
+	names, err := dir.Readdirnames(-1)
+	dir.Close()
+	if err != nil {
+		return -1
+	}
+
+	firstFile := -1
+	for _, name := range names {
+		if !strings.HasSuffix(name, ".fdb") {
+			continue
+		}
+		name = strings.TrimSuffix(name, ".fdb")
+		fileNum, err := strconv.ParseUint(name, 10, 32)
+		if err != nil || len(name) != 9 {
+			continue
+		}
+		if firstFile == -1 || int(fileNum) < firstFile {
+			firstFile = int(fileNum)
+		}
+	}
+	return firstFile
+}
+
 // newBlockStore returns a new block store with the current block file number
 // and offset set and all fields initialized.
 func newBlockStore(basePath string, network wire.BitcoinNet) *blockStore {
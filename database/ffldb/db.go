@@ -8,6 +8,7 @@ import (
 	"bytes"
 	"encoding/binary"
 	"fmt"
+	"math"
 	"os"
 	"path/filepath"
 	"runtime"
@@ -74,6 +75,10 @@ var (
 	// writeLocKeyName is the key used to store the current write file
 	// location.
 	writeLocKeyName = []byte("ffldb-writeloc")
+
+	// prunedKeyName is the key used to record that blocks have been
+	// deleted by PruneBlocks.
+	prunedKeyName = []byte("ffldb-pruned")
 )
 
 // Common error strings.
@@ -970,6 +975,10 @@ type transaction struct {
 	pendingKeys   *treap.Mutable
 	pendingRemove *treap.Mutable
 
+	// Flat block files that need to be deleted once the removal of their
+	// blocks from the block index has been committed.
+	pendingPrunedFiles map[uint32]struct{}
+
 	// Active iterators that need to be notified when the pending keys have
 	// been updated so the cursors can properly handle updates to the
 	// transaction state.
@@ -1583,6 +1592,115 @@ func (tx *transaction) FetchBlockRegions(regions []database.BlockRegion) ([][]by
 	return blockRegions, nil
 }
 
+// PruneBlocks deletes the oldest stored blocks until the stored blocks use no
+// more than the given target size in bytes.  The blocks with the provided
+// hashes, along with all blocks stored after them, are never deleted.  The
+// hashes of the deleted blocks are returned.
+//
+// Blocks are deleted a whole flat file at a time and the current write file is
+// never deleted, so the stored blocks may use more than the target size.  The
+// files are deleted when the transaction is committed, after the removal of
+// their blocks from the block index has been written to the database along with
+// the flag which records that the database has been pruned.
+//
+// Returns the following errors as required by the interface contract:
+//   - ErrTxNotWritable if attempted against a read-only transaction
+//   - ErrTxClosed if the transaction has already been closed
+//
+// This function is part of the database.Tx interface implementation.
+func (tx *transaction) PruneBlocks(targetSize uint64, keep []chainhash.Hash) ([]chainhash.Hash, error) {
+	// Ensure transaction state is valid.
+	if err := tx.checkClosed(); err != nil {
+		return nil, err
+	}
+
+	// Ensure the transaction is writable.
+	if !tx.writable {
+		str := "prune blocks requires a writable database transaction"
+		return nil, makeDbErr(database.ErrTxNotWritable, str, nil)
+	}
+
+	// Keep the file of the oldest stored block which must be kept along
+	// with all later files.  Blocks which are pending to be written on
+	// commit go to the current write file, which is always kept.
+	keepFileNum := uint32(math.MaxUint32)
+	for i := range keep {
+		blockRow := tx.blockIdxBucket.Get(keep[i][:])
+		if blockRow == nil {
+			continue
+		}
+		location := deserializeBlockLoc(blockRow)
+		if location.blockFileNum < keepFileNum {
+			keepFileNum = location.blockFileNum
+		}
+	}
+
+	// The files are only deleted once the transaction is committed, so
+	// their blocks remain available when it is rolled back instead.
+	prune, err := tx.db.store.filesToPrune(targetSize, keepFileNum,
+		tx.pendingPrunedFiles)
+	if err != nil {
+		return nil, err
+	}
+	if len(prune) == 0 {
+		return nil, nil
+	}
+	pruneFiles := make(map[uint32]struct{}, len(prune))
+	for _, fileNum := range prune {
+		pruneFiles[fileNum] = struct{}{}
+	}
+
+	// Remove the block index entries of the blocks in the pruned files.
+	var deletedHashes []chainhash.Hash
+	cursor := tx.blockIdxBucket.Cursor()
+	for ok := cursor.First(); ok; ok = cursor.Next() {
+		location := deserializeBlockLoc(cursor.Value())
+		if _, ok := pruneFiles[location.blockFileNum]; !ok {
+			continue
+		}
+		var hash chainhash.Hash
+		copy(hash[:], cursor.Key())
+		deletedHashes = append(deletedHashes, hash)
+	}
+	for i := range deletedHashes {
+		if err := tx.blockIdxBucket.Delete(deletedHashes[i][:]); err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.metaBucket.Put(prunedKeyName, []byte{1}); err != nil {
+		return nil, err
+	}
+	if tx.pendingPrunedFiles == nil {
+		tx.pendingPrunedFiles = pruneFiles
+	} else {
+		for fileNum := range pruneFiles {
+			tx.pendingPrunedFiles[fileNum] = struct{}{}
+		}
+	}
+
+	log.Debugf("Pruning %d block files containing %d blocks",
+		len(prune), len(deletedHashes))
+	return deletedHashes, nil
+}
+
+// BeenPruned returns whether or not blocks have ever been deleted from the
+// database by PruneBlocks.  It is recorded in the metadata when the transaction
+// which pruned the blocks is committed rather than inferred from the stored
+// block files, whose oldest file may be missing for other reasons.
+//
+// Returns the following errors as required by the interface contract:
+//   - ErrTxClosed if the transaction has already been closed
+//
+// This function is part of the database.Tx interface implementation.
+func (tx *transaction) BeenPruned() (bool, error) {
+	// Ensure transaction state is valid.
+	if err := tx.checkClosed(); err != nil {
+		return false, err
+	}
+
+	return tx.metaBucket.Get(prunedKeyName) != nil, nil
+}
+
 // close marks the transaction closed then releases any pending data, the
 // underlying snapshot, the transaction read lock, and the write lock when the
 // transaction is writable.
@@ -1597,6 +1715,9 @@ func (tx *transaction) close() {
 	tx.pendingKeys = nil
 	tx.pendingRemove = nil
 
+	// Clear pending block files that would have been deleted on commit.
+	tx.pendingPrunedFiles = nil
+
 	// Release the snapshot.
 	if tx.snapshot != nil {
 		tx.snapshot.Release()
@@ -1666,7 +1787,23 @@ func (tx *transaction) writePendingAndCommit() error {
 
 	// Atomically update the database cache.  The cache automatically
 	// handles flushing to the underlying persistent storage database.
-	return tx.db.cache.commitTx(tx)
+	if err := tx.db.cache.commitTx(tx); err != nil {
+		return err
+	}
+
+	// Delete the pruned block files now that the removal of their blocks
+	// from the block index is persisted.
+	if len(tx.pendingPrunedFiles) > 0 {
+		fileNums := make([]uint32, 0, len(tx.pendingPrunedFiles))
+		for fileNum := range tx.pendingPrunedFiles {
+			fileNums = append(fileNums, fileNum)
+		}
+		sort.Slice(fileNums, func(i, j int) bool {
+			return fileNums[i] < fileNums[j]
+		})
+		tx.db.store.pruneFiles(fileNums)
+	}
+	return nil
 }
 
 // Commit commits all changes that have been made to the root metadata bucket
@@ -537,6 +537,13 @@ func (c *dbCache) needsFlush(tx *transaction) bool {
 		return true
 	}
 
+	// A flush is needed when the transaction prunes block files, since
+	// they are deleted once it is committed and the block index entries
+	// which refer to them must not be left behind in the cache.
+	if len(tx.pendingPrunedFiles) > 0 {
+		return true
+	}
+
 	// A flush is needed when the size of the database cache exceeds the
 	// specified max cache size.  The total calculated size is multiplied by
 	// 1.5 here to account for additional memory consumption that will be
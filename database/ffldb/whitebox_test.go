@@ -10,6 +10,7 @@ package ffldb
 import (
 	"compress/bzip2"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"hash/crc32"
 	"io"
@@ -18,6 +19,7 @@ import (
 	"testing"
 
 	"github.com/eager7/dashd/chaincfg"
+	"github.com/eager7/dashd/chaincfg/chainhash"
 	"github.com/eager7/dashd/database"
 	"github.com/eager7/dashd/wire"
 	"github.com/eager7/dashutil"
@@ -704,3 +706,142 @@ func TestFailureScenarios(t *testing.T) {
 	// Test various corruption scenarios.
 	testCorruption(tc)
 }
+
+// TestPruneBlocks ensures pruning deletes the oldest block files along with
+// the block index entries of their blocks, keeps the requested blocks and the
+// current write file, and that the database can be reopened afterwards.
+func TestPruneBlocks(t *testing.T) {
+	// Create a new database to run tests against.
+	dbPath := filepath.Join(os.TempDir(), "ffldb-pruneblocks")
+	_ = os.RemoveAll(dbPath)
+	idb, err := database.Create(dbType, dbPath, blockDataNet)
+	if err != nil {
+		t.Fatalf("Failed to create test database (%s) %v", dbType, err)
+	}
+	defer os.RemoveAll(dbPath)
+	defer func() { idb.Close() }()
+
+	// Change the maximum file size to a small value to force multiple flat
+	// files with the test data set.
+	idb.(*db).store.maxBlockFileSize = 1024 // 1KiB
+
+	blocks, err := loadBlocks(t, blockDataFile, blockDataNet)
+	if err != nil {
+		t.Fatalf("loadBlocks: Unexpected error: %v", err)
+	}
+	err = idb.Update(func(tx database.Tx) error {
+		for _, block := range blocks {
+			if err := tx.StoreBlock(block); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("StoreBlock: Unexpected error: %v", err)
+	}
+
+	// Pruning in a transaction which is rolled back must neither delete
+	// any files nor any blocks.
+	keepBlock := blocks[len(blocks)/2]
+	errRollback := errors.New("rollback")
+	err = idb.Update(func(tx database.Tx) error {
+		pruned, err := tx.PruneBlocks(0, []chainhash.Hash{*keepBlock.Hash()})
+		if err != nil {
+			return err
+		}
+		if len(pruned) == 0 {
+			return errors.New("PruneBlocks: no blocks pruned")
+		}
+		return errRollback
+	})
+	if err != errRollback {
+		t.Fatalf("PruneBlocks rollback: Unexpected error: %v", err)
+	}
+	err = idb.View(func(tx database.Tx) error {
+		if beenPruned, err := tx.BeenPruned(); err != nil || beenPruned {
+			return fmt.Errorf("BeenPruned after rollback: %v, %v",
+				beenPruned, err)
+		}
+		_, err := tx.FetchBlock(blocks[0].Hash())
+		return err
+	})
+	if err != nil {
+		t.Fatalf("FetchBlock after rollback: Unexpected error: %v", err)
+	}
+
+	// Prune everything before the file of a block in the middle of the
+	// test data.
+	var pruned []chainhash.Hash
+	err = idb.Update(func(tx database.Tx) error {
+		if beenPruned, err := tx.BeenPruned(); err != nil || beenPruned {
+			return fmt.Errorf("BeenPruned before pruning: %v, %v",
+				beenPruned, err)
+		}
+
+		var err error
+		pruned, err = tx.PruneBlocks(0, []chainhash.Hash{*keepBlock.Hash()})
+		return err
+	})
+	if err != nil {
+		t.Fatalf("PruneBlocks: Unexpected error: %v", err)
+	}
+	if len(pruned) == 0 || len(pruned) > len(blocks)/2 {
+		t.Fatalf("PruneBlocks: unexpected number of pruned blocks %d",
+			len(pruned))
+	}
+
+	// Ensure the pruned blocks are the oldest ones and are gone while the
+	// remaining blocks are still available.
+	err = idb.View(func(tx database.Tx) error {
+		if beenPruned, err := tx.BeenPruned(); err != nil || !beenPruned {
+			return fmt.Errorf("BeenPruned after pruning: %v, %v",
+				beenPruned, err)
+		}
+
+		for i, block := range blocks {
+			hasBlock, err := tx.HasBlock(block.Hash())
+			if err != nil {
+				return err
+			}
+			if wantBlock := i >= len(pruned); hasBlock != wantBlock {
+				return fmt.Errorf("HasBlock #%d: got %v, want %v", i,
+					hasBlock, wantBlock)
+			}
+		}
+		_, err := tx.FetchBlock(blocks[len(blocks)-1].Hash())
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Ensure the write cursor is restored after reopening the database by
+	// storing another block and reading it back.
+	idb.Close()
+	idb, err = database.Open(dbType, dbPath, blockDataNet)
+	if err != nil {
+		t.Fatalf("Failed to reopen test database (%s) %v", dbType, err)
+	}
+	err = idb.Update(func(tx database.Tx) error {
+		if err := tx.StoreBlock(blocks[0]); err != nil {
+			return err
+		}
+		_, err := tx.FetchBlock(blocks[len(blocks)-1].Hash())
+		return err
+	})
+	if err != nil {
+		t.Fatalf("Update after reopening: Unexpected error: %v", err)
+	}
+	err = idb.View(func(tx database.Tx) error {
+		if beenPruned, err := tx.BeenPruned(); err != nil || !beenPruned {
+			return fmt.Errorf("BeenPruned after reopening: %v, %v",
+				beenPruned, err)
+		}
+		_, err := tx.FetchBlock(blocks[0].Hash())
+		return err
+	})
+	if err != nil {
+		t.Fatalf("FetchBlock after reopening: Unexpected error: %v", err)
+	}
+}
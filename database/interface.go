@@ -389,6 +389,30 @@ type Tx interface {
 	// implementations.
 	FetchBlockRegions(regions []BlockRegion) ([][]byte, error)
 
+	// PruneBlocks deletes the oldest stored blocks until the stored blocks
+	// use no more than the given target size in bytes.  The blocks with the
+	// provided hashes, along with all blocks stored after them, are never
+	// deleted.  The hashes of the deleted blocks are returned.
+	//
+	// Depending on the backend implementation, blocks may only be deleted
+	// in groups, such as whole files, so the target size is approximate.
+	// The block data is only deleted when the transaction is committed, so
+	// the blocks remain stored when it is rolled back instead.
+	//
+	// The interface contract guarantees at least the following errors will
+	// be returned (other implementation-specific errors are possible):
+	//   - ErrTxNotWritable if attempted against a read-only transaction
+	//   - ErrTxClosed if the transaction has already been closed
+	PruneBlocks(targetSize uint64, keep []chainhash.Hash) ([]chainhash.Hash, error)
+
+	// BeenPruned returns whether or not blocks have ever been deleted from
+	// the database by a committed transaction which called PruneBlocks.
+	//
+	// The interface contract guarantees at least the following errors will
+	// be returned (other implementation-specific errors are possible):
+	//   - ErrTxClosed if the transaction has already been closed
+	BeenPruned() (bool, error)
+
 	// ******************************************************************
 	// Methods related to both atomic metadata storage and block storage.
 	// ******************************************************************
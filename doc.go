@@ -24,9 +24,25 @@ Application Options:
       --addcheckpoint=        Add a custom checkpoint.  Format:
                               '<height>:<hash>'
   -a, --addpeer=              Add a peer to connect with at startup
+      --addutxosnapshot=      Allow loading the utxo set snapshot with the
+                              given content hash taken at the given block.
+                              Format: '<height>:<blockhash>:<contenthash>'
       --addrindex             Maintain a full address-based transaction index
                               which makes the searchrawtransactions RPC
                               available
+      --addressindex          Maintain an index of the balance changes and
+                              unspent outputs of addresses which makes the
+                              getaddressbalance, getaddressdeltas,
+                              getaddressmempool, getaddresstxids and
+                              getaddressutxos RPCs available
+      --assumevalid=          Hash of a block whose ancestors are assumed to
+                              have valid scripts, so their scripts are not
+                              checked (default: network specific, 0 to check
+                              all scripts)
+      --asmap=                File mapping IP prefixes to the autonomous
+                              systems they belong to, used to make sure
+                              outbound peers are in distinct autonomous
+                              systems
       --banduration=          How long to ban misbehaving peers.  Valid time
                               units are {s, m, h}.  Minimum 1 second (default:
                               24h0m0s)
@@ -59,9 +75,16 @@ Application Options:
                               info)
       --dropaddrindex         Deletes the address-based transaction index from
                               the database on start up and then exits.
+      --dropaddressindex      Deletes the index of the balance changes and
+                              unspent outputs of addresses from the database
+                              on start up and then exits.
       --dropcfindex           Deletes the index used for committed filtering
                               (CF) support from the database on start up and
                               then exits.
+      --dropspentindex        Deletes the spent index from the database on
+                              start up and then exits.
+      --droptimestampindex    Deletes the timestamp index from the database on
+                              start up and then exits.
       --droptxindex           Deletes the hash-based transaction index from the
                               database on start up and then exits.
       --externalip=           Add an ip to the list of local addresses we claim
@@ -97,6 +120,7 @@ Application Options:
       --nopeerbloomfilters    Disable bloom filtering support
       --norelaypriority       Do not require free or low-fee transactions to
                               have high priority for relaying
+      --nov2transport         Disable the v2 encrypted P2P transport (BIP0324)
       --norpc                 Disable built-in RPC server -- NOTE: The RPC
                               server is disabled by default if no
                               rpcuser/rpcpass or rpclimituser/rpclimitpass is
@@ -110,6 +134,11 @@ Application Options:
       --onionuser=            Username for onion proxy server
       --profile=              Enable HTTP profiling on given port -- NOTE port
                               must be between 1024 and 65536
+      --prune=                Delete old blocks to keep the stored blocks below
+                              the given size in MiB (minimum 550) -- NOTE: Not
+                              possible with the transaction, address, spent
+                              and timestamp indexes, and indexes which are
+                              behind the pruned blocks can't be caught up
       --proxy=                Connect via SOCKS5 proxy (eg. 127.0.0.1:9050)
       --proxypass=            Password for proxy server
       --proxyuser=            Username for proxy server
@@ -138,7 +167,12 @@ Application Options:
       --sigcachemaxsize=      The maximum number of entries in the signature
                               verification cache (default: 100000)
       --simnet                Use the simulation test network
+      --spentindex            Maintain an index of the inputs spending each
+                              output which makes the getspentinfo RPC
+                              available
       --testnet               Use the test network
+      --timestampindex        Maintain an index of the blocks ordered by time
+                              which makes the getblockhashes RPC available
       --torisolation          Enable Tor stream isolation by randomizing user
                               credentials for each connection.
       --trickleinterval=      Minimum time between attempts to send new
@@ -149,6 +183,9 @@ Application Options:
       --uacomment=            Comment to add to the user agent -- See BIP 14
                               for more information.
       --upnp                  Use UPnP to map our listening port outside of NAT
+      --utxocachemaxsize=     The maximum size in MiB of the unspent
+                              transaction outputs held in memory before they
+                              are written to the database (default: 250)
   -V, --version               Display version information and exit
       --whitelist=            Add an IP network or IP that will not be banned.
                               (eg. 192.168.1.0/24 or ::1)
@@ -0,0 +1,217 @@
+// Copyright (c) 2013-2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/binary"
+	"math"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"github.com/eager7/dashd/chaincfg/chainhash"
+)
+
+const (
+	// evictProtectNetGroups is the number of inbound peers protected from
+	// eviction by their netgroup, which is selected deterministically
+	// but unpredictably for an attacker.
+	evictProtectNetGroups = 4
+
+	// evictProtectPing is the number of inbound peers with the lowest ping
+	// times protected from eviction.
+	evictProtectPing = 8
+
+	// evictProtectTxRelay is the number of inbound peers which most
+	// recently relayed new transactions protected from eviction.
+	evictProtectTxRelay = 4
+
+	// evictProtectBlockRelay is the number of inbound peers which most
+	// recently relayed new blocks protected from eviction.
+	evictProtectBlockRelay = 4
+)
+
+// evictionCandidate describes an inbound peer which may be evicted to make
+// room for a new inbound peer.
+type evictionCandidate struct {
+	id            int32
+	addr          string
+	netGroup      string
+	keyedNetGroup uint64
+	pingTime      time.Duration // zero when unknown
+	lastBlockTime time.Time
+	lastTxTime    time.Time
+	relayTxs      bool
+	timeConnected time.Time
+}
+
+// evictionSelector selects the inbound peer to evict among the passed
+// candidates to make room for a new inbound peer.  It returns nil when none of
+// them should be evicted.
+type evictionSelector func(candidates []*evictionCandidate) *evictionCandidate
+
+// protectCandidates sorts the passed candidates with the passed function and
+// removes the first n of them, which are protected from eviction for the
+// passed reason.  It returns the remaining candidates.
+func protectCandidates(candidates []*evictionCandidate, n int, reason string,
+	less func(a, b *evictionCandidate) bool) []*evictionCandidate {
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return less(candidates[i], candidates[j])
+	})
+	if n > len(candidates) {
+		n = len(candidates)
+	}
+	for _, c := range candidates[:n] {
+		srvrLog.Debugf("Protecting inbound peer %s (id %d) from "+
+			"eviction: %s", c.addr, c.id, reason)
+	}
+	return candidates[n:]
+}
+
+// selectEvictionCandidate is the default eviction selector, which follows the
+// reference implementation.  Peers are protected from eviction in turn for
+// their netgroup, low ping time, recent relay of new transactions and blocks,
+// and for the longest uptime, which covers half of the remaining ones.  The
+// most recently connected peer of the netgroup with the most remaining peers
+// is evicted, so an attacker would have to beat honest peers on all of these
+// to take over the inbound slots.
+func selectEvictionCandidate(candidates []*evictionCandidate) *evictionCandidate {
+	remaining := make([]*evictionCandidate, len(candidates))
+	copy(remaining, candidates)
+
+	remaining = protectCandidates(remaining, evictProtectNetGroups,
+		"netgroup", func(a, b *evictionCandidate) bool {
+			return a.keyedNetGroup > b.keyedNetGroup
+		})
+
+	remaining = protectCandidates(remaining, evictProtectPing,
+		"low ping time", func(a, b *evictionCandidate) bool {
+			return pingOrMax(a) < pingOrMax(b)
+		})
+
+	remaining = protectCandidates(remaining, evictProtectTxRelay,
+		"recent transaction relay", func(a, b *evictionCandidate) bool {
+			if !a.lastTxTime.Equal(b.lastTxTime) {
+				return a.lastTxTime.After(b.lastTxTime)
+			}
+			if a.relayTxs != b.relayTxs {
+				return a.relayTxs
+			}
+			return a.timeConnected.Before(b.timeConnected)
+		})
+
+	remaining = protectCandidates(remaining, evictProtectBlockRelay,
+		"recent block relay", func(a, b *evictionCandidate) bool {
+			if !a.lastBlockTime.Equal(b.lastBlockTime) {
+				return a.lastBlockTime.After(b.lastBlockTime)
+			}
+			return a.timeConnected.Before(b.timeConnected)
+		})
+
+	remaining = protectCandidates(remaining, len(remaining)/2,
+		"long uptime", func(a, b *evictionCandidate) bool {
+			return a.timeConnected.Before(b.timeConnected)
+		})
+
+	if len(remaining) == 0 {
+		srvrLog.Debugf("All %d inbound peers are protected from "+
+			"eviction", len(candidates))
+		return nil
+	}
+
+	// Group the remaining candidates by netgroup with the most recently
+	// connected first, and pick the netgroup with the most candidates.
+	// Ties go to the netgroup with the most recent connection.
+	sort.SliceStable(remaining, func(i, j int) bool {
+		return remaining[i].timeConnected.After(remaining[j].timeConnected)
+	})
+	var netGroups []string
+	groups := make(map[string][]*evictionCandidate)
+	for _, c := range remaining {
+		if _, ok := groups[c.netGroup]; !ok {
+			netGroups = append(netGroups, c.netGroup)
+		}
+		groups[c.netGroup] = append(groups[c.netGroup], c)
+	}
+	largest := groups[netGroups[0]]
+	for _, netGroup := range netGroups[1:] {
+		if len(groups[netGroup]) > len(largest) {
+			largest = groups[netGroup]
+		}
+	}
+
+	victim := largest[0]
+	srvrLog.Debugf("Selected inbound peer %s (id %d) for eviction: most "+
+		"recently connected of the %d unprotected peers in the largest "+
+		"netgroup %s", victim.addr, victim.id, len(largest),
+		victim.netGroup)
+	return victim
+}
+
+// pingOrMax returns the ping time of the candidate, or the maximum duration
+// when it is not known yet.
+func pingOrMax(c *evictionCandidate) time.Duration {
+	if c.pingTime == 0 {
+		return math.MaxInt64
+	}
+	return c.pingTime
+}
+
+// newEvictionCandidate returns the eviction candidate describing the passed
+// inbound peer.
+func (s *server) newEvictionCandidate(sp *serverPeer) *evictionCandidate {
+	c := &evictionCandidate{
+		id:            sp.ID(),
+		addr:          sp.Addr(),
+		pingTime:      time.Duration(sp.LastPingMicros()) * time.Microsecond,
+		relayTxs:      !sp.relayTxDisabled(),
+		timeConnected: sp.TimeConnected(),
+	}
+	if na := sp.NA(); na != nil {
+		c.netGroup = s.addrManager.GroupKey(na)
+	}
+
+	// The netgroups are keyed with a secret so an attacker can not tell
+	// which ones are protected.
+	var key [8]byte
+	binary.LittleEndian.PutUint64(key[:], s.netGroupKey)
+	hash := chainhash.HashB(append(key[:], c.netGroup...))
+	c.keyedNetGroup = binary.LittleEndian.Uint64(hash)
+
+	if t := atomic.LoadInt64(&sp.lastBlockTime); t != 0 {
+		c.lastBlockTime = time.Unix(0, t)
+	}
+	if t := atomic.LoadInt64(&sp.lastTxTime); t != 0 {
+		c.lastTxTime = time.Unix(0, t)
+	}
+	return c
+}
+
+// evictInboundPeer disconnects the inbound peer chosen by the eviction
+// selector of the server to make room for a new inbound peer.  Whitelisted
+// peers are never evicted.  It returns whether a peer was evicted.
+func (s *server) evictInboundPeer(state *peerState) bool {
+	var candidates []*evictionCandidate
+	for _, sp := range state.inboundPeers {
+		if !sp.Connected() || sp.isWhitelisted {
+			continue
+		}
+		candidates = append(candidates, s.newEvictionCandidate(sp))
+	}
+
+	victim := s.evictionSelector(candidates)
+	if victim == nil {
+		return false
+	}
+	sp, ok := state.inboundPeers[victim.id]
+	if !ok {
+		return false
+	}
+
+	srvrLog.Infof("Evicting inbound peer %s to make room for a new one", sp)
+	sp.Disconnect()
+	return true
+}
@@ -0,0 +1,215 @@
+// Copyright (c) 2013-2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"math"
+	"testing"
+	"time"
+)
+
+// newTestCandidates returns the passed number of eviction candidates which
+// are all in distinct netgroups and connected one minute apart, with the first
+// one connected the longest time ago.
+func newTestCandidates(n int) []*evictionCandidate {
+	base := time.Unix(1500000000, 0)
+	candidates := make([]*evictionCandidate, 0, n)
+	for i := 0; i < n; i++ {
+		candidates = append(candidates, &evictionCandidate{
+			id:            int32(i),
+			addr:          fmt.Sprintf("10.%d.0.1:9999", i),
+			netGroup:      fmt.Sprintf("10.%d", i),
+			timeConnected: base.Add(time.Duration(i) * time.Minute),
+		})
+	}
+	return candidates
+}
+
+// TestSelectEvictionCandidate ensures the default eviction selector protects
+// the expected inbound peers and evicts the most recently connected peer of the
+// largest remaining netgroup.
+func TestSelectEvictionCandidate(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+
+	// attackers returns 24 honest candidates followed by 6 candidates of
+	// the same netgroup connected after them.  The 20 candidates protected
+	// for their netgroup, ping, and relay come from the honest ones in
+	// order, and the protection for uptime covers the 4 remaining honest
+	// ones along with the oldest attacker.
+	attackers := func() []*evictionCandidate {
+		candidates := newTestCandidates(30)
+		for _, c := range candidates[24:] {
+			c.netGroup = "192.0"
+		}
+		return candidates
+	}
+
+	tests := []struct {
+		name       string
+		candidates func() []*evictionCandidate
+		want       int32 // -1 when no candidate is evicted
+	}{
+		{
+			name:       "no candidates",
+			candidates: func() []*evictionCandidate { return nil },
+			want:       -1,
+		},
+		{
+			name: "all protected",
+			candidates: func() []*evictionCandidate {
+				return newTestCandidates(evictProtectNetGroups +
+					evictProtectPing + evictProtectTxRelay +
+					evictProtectBlockRelay)
+			},
+			want: -1,
+		},
+		{
+			name: "one unprotected",
+			candidates: func() []*evictionCandidate {
+				return newTestCandidates(evictProtectNetGroups +
+					evictProtectPing + evictProtectTxRelay +
+					evictProtectBlockRelay + 1)
+			},
+			want: 20,
+		},
+		{
+			name:       "youngest of largest netgroup",
+			candidates: attackers,
+			want:       29,
+		},
+		{
+			name: "protected by netgroup",
+			candidates: func() []*evictionCandidate {
+				candidates := attackers()
+				candidates[29].keyedNetGroup = math.MaxUint64
+				return candidates
+			},
+			want: 28,
+		},
+		{
+			name: "protected by ping",
+			candidates: func() []*evictionCandidate {
+				candidates := attackers()
+				candidates[29].pingTime = time.Millisecond
+				return candidates
+			},
+			want: 28,
+		},
+		{
+			name: "protected by transaction relay",
+			candidates: func() []*evictionCandidate {
+				candidates := attackers()
+				candidates[29].lastTxTime = now
+				return candidates
+			},
+			want: 28,
+		},
+		{
+			name: "protected by block relay",
+			candidates: func() []*evictionCandidate {
+				candidates := attackers()
+				candidates[29].lastBlockTime = now
+				return candidates
+			},
+			want: 28,
+		},
+		{
+			name: "unknown ping is not protected",
+			candidates: func() []*evictionCandidate {
+				candidates := attackers()
+				for _, c := range candidates[:24] {
+					c.pingTime = time.Second
+				}
+				return candidates
+			},
+			want: 29,
+		},
+		{
+			name: "largest netgroup over youngest",
+			candidates: func() []*evictionCandidate {
+				// The 8 candidates after the 20 protected ones
+				// are split in two netgroups, with the second
+				// one connected last.  The 4 oldest of them are
+				// protected for uptime, leaving 3 of the first
+				// netgroup and 1 of the second.
+				candidates := newTestCandidates(28)
+				for i, c := range candidates[20:] {
+					c.netGroup = "192.0"
+					if i == 0 || i == 7 {
+						c.netGroup = "192.1"
+					}
+				}
+				return candidates
+			},
+			want: 26,
+		},
+		{
+			name: "tie goes to youngest netgroup",
+			candidates: func() []*evictionCandidate {
+				candidates := newTestCandidates(32)
+				for i, c := range candidates[26:] {
+					c.netGroup = fmt.Sprintf("192.%d", i%2)
+				}
+				return candidates
+			},
+			want: 31,
+		},
+	}
+
+	for _, test := range tests {
+		candidates := test.candidates()
+		victim := selectEvictionCandidate(candidates)
+		switch {
+		case test.want == -1 && victim != nil:
+			t.Errorf("%s: unexpected eviction of candidate %d",
+				test.name, victim.id)
+		case test.want != -1 && victim == nil:
+			t.Errorf("%s: no candidate evicted, want %d", test.name,
+				test.want)
+		case test.want != -1 && victim.id != test.want:
+			t.Errorf("%s: evicted candidate %d, want %d", test.name,
+				victim.id, test.want)
+		}
+
+		// The selector must not reorder the passed candidates.
+		for i, c := range candidates {
+			if c.id != int32(i) {
+				t.Errorf("%s: candidates reordered", test.name)
+				break
+			}
+		}
+	}
+}
+
+// TestEvictInboundPeerSelector ensures the server defers to its eviction
+// selector and does not evict any peer when the selector declines.
+func TestEvictInboundPeerSelector(t *testing.T) {
+	var called bool
+	s := &server{
+		evictionSelector: func(candidates []*evictionCandidate) *evictionCandidate {
+			called = true
+			if len(candidates) != 0 {
+				t.Errorf("unexpected candidates %v", candidates)
+			}
+			return nil
+		},
+	}
+	state := &peerState{inboundPeers: make(map[int32]*serverPeer)}
+	if s.evictInboundPeer(state) {
+		t.Fatal("evictInboundPeer: evicted a peer without candidates")
+	}
+	if !called {
+		t.Fatal("evictInboundPeer: eviction selector not called")
+	}
+
+	// A candidate which is not an inbound peer is never evicted.
+	s.evictionSelector = func([]*evictionCandidate) *evictionCandidate {
+		return &evictionCandidate{id: 1}
+	}
+	if s.evictInboundPeer(state) {
+		t.Fatal("evictInboundPeer: evicted an unknown peer")
+	}
+}
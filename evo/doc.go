@@ -0,0 +1,38 @@
+// Copyright (c) 2013-2016 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+/*
+Package evo maintains the deterministic masternode list defined by DIP0003.
+
+Masternodes are registered on chain with provider registration (ProRegTx)
+special transactions and are updated by the provider update service, registrar
+and revoke transactions which refer to the registration by its hash, the
+ProTxHash.  A masternode is removed from the list once its collateral is spent.
+Since the list only depends on the transactions in the main chain, every node
+arrives at the same list for a given block.
+
+This package provides a List type which represents the masternode list as of a
+block, including the selection of the masternode which is paid by the next
+block, and a Manager which builds the list while blocks are connected to the
+main chain.  The Manager implements the indexer interface of the
+blockchain/indexers package so the list is persisted to the database along with
+the undo data needed to disconnect blocks and to reconstruct the list as of any
+earlier block in the main chain.  When the chain is bootstrapped from a utxo
+snapshot, the list is rebuilt from the blocks below the snapshot as their
+history is validated, so it lags behind the main chain until the validation
+completes.
+
+Provider transactions are only applied when they are valid in the context of
+the list.  Their keys, payout scripts, inputs hash and the uniqueness of their
+service address and keys are checked, as are the signatures of the operator and
+owner.  The signature of a registration with an external collateral is made
+with the key of the collateral output, which is not known to the list, and
+operator signatures of version 1 transactions use the legacy BLS scheme, so
+those are not checked.
+
+Proof of service penalties are assigned from the final commitments of the long
+living masternode quorums.  Those are not processed by this package, so
+masternodes are only banned by the provider transactions themselves.
+*/
+package evo
@@ -0,0 +1,517 @@
+// Copyright (c) 2013-2016 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package evo
+
+import (
+	"bytes"
+	"net"
+	"sort"
+
+	"github.com/eager7/dashd/chaincfg/chainhash"
+	"github.com/eager7/dashd/wire"
+	"github.com/eager7/dashutil"
+)
+
+// compareHashes compares the passed hashes as 256-bit little endian numbers
+// the way the reference implementation orders them.  It returns -1, 0 or 1
+// when a is respectively less than, equal to or greater than b.
+func compareHashes(a, b *chainhash.Hash) int {
+	for i := chainhash.HashSize - 1; i >= 0; i-- {
+		switch {
+		case a[i] < b[i]:
+			return -1
+		case a[i] > b[i]:
+			return 1
+		}
+	}
+	return 0
+}
+
+// sortMasternodes sorts the passed masternodes by their ProTxHash.
+func sortMasternodes(mns []*Masternode) {
+	sort.Slice(mns, func(i, j int) bool {
+		return compareHashes(&mns[i].ProTxHash, &mns[j].ProTxHash) < 0
+	})
+}
+
+// payeeLess returns whether or not masternode a is ahead of masternode b in
+// the payment queue.
+func payeeLess(a, b *Masternode) bool {
+	heightA, heightB := a.paymentHeight(), b.paymentHeight()
+	if heightA != heightB {
+		return heightA < heightB
+	}
+	return compareHashes(&a.ProTxHash, &b.ProTxHash) < 0
+}
+
+// undoEntry houses the state of a masternode before it was changed by a block.
+// A nil entry means the masternode was added by the block.
+type undoEntry struct {
+	proTxHash chainhash.Hash
+	prev      *Masternode
+}
+
+// listUndo records the masternodes changed by a block so the changes can be
+// reverted when the block is disconnected.  Only the state before the first
+// change of a masternode in the block is recorded.
+type listUndo struct {
+	entries []undoEntry
+	touched map[chainhash.Hash]struct{}
+}
+
+// List is the deterministic masternode list as of a block.
+type List struct {
+	blockHash   chainhash.Hash
+	height      int32
+	mns         map[chainhash.Hash]*Masternode
+	collaterals map[wire.OutPoint]chainhash.Hash
+}
+
+// newList returns an empty masternode list as of the passed block.
+func newList(blockHash *chainhash.Hash, height int32) *List {
+	return &List{
+		blockHash:   *blockHash,
+		height:      height,
+		mns:         make(map[chainhash.Hash]*Masternode),
+		collaterals: make(map[wire.OutPoint]chainhash.Hash),
+	}
+}
+
+// clone returns a copy of the list.  The masternode entries are shared since
+// they are never modified in place.
+func (l *List) clone() *List {
+	c := &List{
+		blockHash:   l.blockHash,
+		height:      l.height,
+		mns:         make(map[chainhash.Hash]*Masternode, len(l.mns)),
+		collaterals: make(map[wire.OutPoint]chainhash.Hash, len(l.collaterals)),
+	}
+	for hash, mn := range l.mns {
+		c.mns[hash] = mn
+	}
+	for op, hash := range l.collaterals {
+		c.collaterals[op] = hash
+	}
+	return c
+}
+
+// BlockHash returns the hash of the block the list is for.
+func (l *List) BlockHash() chainhash.Hash {
+	return l.blockHash
+}
+
+// Height returns the height of the block the list is for.
+func (l *List) Height() int32 {
+	return l.height
+}
+
+// Count returns the number of masternodes in the list.
+func (l *List) Count() int {
+	return len(l.mns)
+}
+
+// ValidCount returns the number of masternodes in the list which are not
+// banned.
+func (l *List) ValidCount() int {
+	var count int
+	for _, mn := range l.mns {
+		if mn.IsValid() {
+			count++
+		}
+	}
+	return count
+}
+
+// Masternode returns the masternode registered by the passed ProTxHash or nil
+// when there is no such masternode in the list.
+func (l *List) Masternode(proTxHash *chainhash.Hash) *Masternode {
+	return l.mns[*proTxHash]
+}
+
+// MasternodeByCollateral returns the masternode with the passed collateral or
+// nil when there is no such masternode in the list.
+func (l *List) MasternodeByCollateral(collateral *wire.OutPoint) *Masternode {
+	hash, ok := l.collaterals[*collateral]
+	if !ok {
+		return nil
+	}
+	return l.mns[hash]
+}
+
+// Masternodes returns all masternodes in the list ordered by their
+// ProTxHash.
+func (l *List) Masternodes() []*Masternode {
+	mns := make([]*Masternode, 0, len(l.mns))
+	for _, mn := range l.mns {
+		mns = append(mns, mn)
+	}
+	sortMasternodes(mns)
+	return mns
+}
+
+// Payee returns the masternode which is paid by the block after the block the
+// list is for or nil when there are no valid masternodes.  This is the valid
+// masternode which was paid, registered or revived the longest time ago.
+func (l *List) Payee() *Masternode {
+	var best *Masternode
+	for _, mn := range l.mns {
+		if !mn.IsValid() {
+			continue
+		}
+		if best == nil || payeeLess(mn, best) {
+			best = mn
+		}
+	}
+	return best
+}
+
+// ProjectedPayees returns the masternodes which are expected to be paid by the
+// passed number of blocks following the block the list is for, assuming the
+// list does not change in the meantime.
+func (l *List) ProjectedPayees(count int) []*Masternode {
+	mns := make([]*Masternode, 0, len(l.mns))
+	for _, mn := range l.mns {
+		if mn.IsValid() {
+			mns = append(mns, mn)
+		}
+	}
+
+	// Every paid masternode moves to the end of the queue, so the
+	// projection is the queue itself.
+	sort.Slice(mns, func(i, j int) bool {
+		return payeeLess(mns[i], mns[j])
+	})
+	if count < len(mns) {
+		mns = mns[:count]
+	}
+	return mns
+}
+
+// set replaces the masternode registered by the passed ProTxHash with mn,
+// which removes it when mn is nil, and records the previous entry in undo.
+func (l *List) set(proTxHash *chainhash.Hash, mn *Masternode, undo *listUndo) {
+	prev := l.mns[*proTxHash]
+	if undo != nil {
+		if _, ok := undo.touched[*proTxHash]; !ok {
+			undo.touched[*proTxHash] = struct{}{}
+			undo.entries = append(undo.entries, undoEntry{
+				proTxHash: *proTxHash,
+				prev:      prev,
+			})
+		}
+	}
+
+	if prev != nil {
+		delete(l.collaterals, prev.CollateralOutpoint)
+		delete(l.mns, *proTxHash)
+	}
+	if mn != nil {
+		l.mns[*proTxHash] = mn
+		l.collaterals[mn.CollateralOutpoint] = *proTxHash
+	}
+}
+
+// isEmptyService returns whether or not the passed service address is the
+// unset address used by masternodes without an operator.
+func isEmptyService(ip net.IP, port uint16) bool {
+	return port == 0 && (ip == nil || ip.IsUnspecified())
+}
+
+// applyProRegTx adds the masternode registered by the passed transaction.
+func (l *List) applyProRegTx(tx *dashutil.Tx, height int32, undo *listUndo) {
+	payload, err := tx.MsgTx().ProRegTxPayload()
+	if err != nil {
+		log.Warnf("Ignoring provider registration %v: %v", tx.Hash(),
+			err)
+		return
+	}
+
+	// A collateral without a hash refers to an output of the registration
+	// itself.
+	collateral := payload.CollateralOutpoint
+	if collateral.Hash == (chainhash.Hash{}) {
+		collateral.Hash = *tx.Hash()
+	}
+	if err := l.checkProRegTx(tx, payload, &collateral); err != nil {
+		log.Warnf("Ignoring provider registration %v: %v", tx.Hash(),
+			err)
+		return
+	}
+
+	// A registration which reuses the collateral of an existing
+	// masternode replaces it.
+	if replaced := l.MasternodeByCollateral(&collateral); replaced != nil {
+		l.set(&replaced.ProTxHash, nil, undo)
+	}
+
+	mn := &Masternode{
+		ProTxHash:          *tx.Hash(),
+		CollateralOutpoint: collateral,
+		OperatorReward:     payload.OperatorReward,
+		Type:               payload.MasternodeType,
+		State: MasternodeState{
+			Version:           payload.Version,
+			RegisteredHeight:  height,
+			PoSeRevivedHeight: -1,
+			PoSeBanHeight:     -1,
+			KeyIDOwner:        payload.KeyIDOwner,
+			PubKeyOperator:    payload.PubKeyOperator,
+			KeyIDVoting:       payload.KeyIDVoting,
+			ScriptPayout:      payload.ScriptPayout,
+			Platform:          payload.Platform,
+		},
+	}
+
+	// Masternodes registered without a service address start banned
+	// until the operator provides one.
+	if isEmptyService(payload.IP, payload.Port) {
+		mn.State.banIfNotBanned(height)
+	} else {
+		mn.State.IP = payload.IP
+		mn.State.Port = payload.Port
+	}
+	l.set(&mn.ProTxHash, mn, undo)
+}
+
+// applyProUpServTx updates the service of the masternode referenced by the
+// passed transaction and revives it when it is banned.
+func (l *List) applyProUpServTx(tx *dashutil.Tx, height int32, undo *listUndo) {
+	payload, err := tx.MsgTx().ProUpServTxPayload()
+	if err != nil {
+		log.Warnf("Ignoring provider update service %v: %v", tx.Hash(),
+			err)
+		return
+	}
+	prev := l.mns[payload.ProTxHash]
+	if prev == nil {
+		log.Warnf("Ignoring provider update service %v for unknown "+
+			"masternode %v", tx.Hash(), payload.ProTxHash)
+		return
+	}
+	if err := l.checkProUpServTx(tx, payload, prev); err != nil {
+		log.Warnf("Ignoring provider update service %v: %v", tx.Hash(),
+			err)
+		return
+	}
+
+	mn := *prev
+	s := &mn.State
+	if payload.Version > s.Version {
+		s.Version = payload.Version
+	}
+	s.IP = payload.IP
+	s.Port = payload.Port
+	s.ScriptOperatorPayout = payload.ScriptOperatorPayout
+	if mn.Type == wire.MasternodeTypeEvo {
+		s.Platform = payload.Platform
+	}
+	if !mn.IsValid() {
+		s.PoSePenalty = 0
+		s.PoSeBanHeight = -1
+		s.PoSeRevivedHeight = height
+	}
+	l.set(&mn.ProTxHash, &mn, undo)
+}
+
+// applyProUpRegTx updates the keys and payout script of the masternode
+// referenced by the passed transaction.  A new operator key bans the
+// masternode until the new operator provides its service.
+func (l *List) applyProUpRegTx(tx *dashutil.Tx, height int32, undo *listUndo) {
+	payload, err := tx.MsgTx().ProUpRegTxPayload()
+	if err != nil {
+		log.Warnf("Ignoring provider update registrar %v: %v",
+			tx.Hash(), err)
+		return
+	}
+	prev := l.mns[payload.ProTxHash]
+	if prev == nil {
+		log.Warnf("Ignoring provider update registrar %v for unknown "+
+			"masternode %v", tx.Hash(), payload.ProTxHash)
+		return
+	}
+	if err := l.checkProUpRegTx(tx, payload, prev); err != nil {
+		log.Warnf("Ignoring provider update registrar %v: %v", tx.Hash(),
+			err)
+		return
+	}
+
+	mn := *prev
+	s := &mn.State
+	if payload.Version > s.Version {
+		s.Version = payload.Version
+	}
+	if s.PubKeyOperator != payload.PubKeyOperator {
+		s.resetOperatorFields()
+		s.banIfNotBanned(height)
+	}
+	s.PubKeyOperator = payload.PubKeyOperator
+	s.KeyIDVoting = payload.KeyIDVoting
+	s.ScriptPayout = payload.ScriptPayout
+	l.set(&mn.ProTxHash, &mn, undo)
+}
+
+// applyProUpRevTx revokes the operator of the masternode referenced by the
+// passed transaction, which also bans the masternode.
+func (l *List) applyProUpRevTx(tx *dashutil.Tx, height int32, undo *listUndo) {
+	payload, err := tx.MsgTx().ProUpRevTxPayload()
+	if err != nil {
+		log.Warnf("Ignoring provider update revoke %v: %v", tx.Hash(),
+			err)
+		return
+	}
+	prev := l.mns[payload.ProTxHash]
+	if prev == nil {
+		log.Warnf("Ignoring provider update revoke %v for unknown "+
+			"masternode %v", tx.Hash(), payload.ProTxHash)
+		return
+	}
+	if err := checkProUpRevTx(tx, payload, prev); err != nil {
+		log.Warnf("Ignoring provider update revoke %v: %v", tx.Hash(),
+			err)
+		return
+	}
+
+	mn := *prev
+	mn.State.resetOperatorFields()
+	mn.State.banIfNotBanned(height)
+	mn.State.RevocationReason = payload.Reason
+	l.set(&mn.ProTxHash, &mn, undo)
+}
+
+// applyBlock updates the list with the passed block, which must be the block
+// following the block the list is for, and returns the undo data needed to
+// revert the changes.
+func (l *List) applyBlock(block *dashutil.Block) []undoEntry {
+	undo := listUndo{touched: make(map[chainhash.Hash]struct{})}
+	height := block.Height()
+	blockHash := block.Hash()
+
+	// The masternode paid by the block is determined by the list of the
+	// previous block.
+	payee := l.Payee()
+
+	// Masternodes are confirmed by the first block after their
+	// registration.
+	for _, mn := range l.mns {
+		if mn.State.ConfirmedHash != (chainhash.Hash{}) ||
+			height-mn.State.RegisteredHeight < 1 {
+
+			continue
+		}
+		confirmed := *mn
+		confirmed.State.ConfirmedHash = *blockHash
+		l.set(&confirmed.ProTxHash, &confirmed, &undo)
+	}
+
+	for _, tx := range block.Transactions() {
+		msgTx := tx.MsgTx()
+		if msgTx.IsSpecial() {
+			switch msgTx.Type {
+			case wire.TxTypeProRegTx:
+				l.applyProRegTx(tx, height, &undo)
+			case wire.TxTypeProUpServTx:
+				l.applyProUpServTx(tx, height, &undo)
+			case wire.TxTypeProUpRegTx:
+				l.applyProUpRegTx(tx, height, &undo)
+			case wire.TxTypeProUpRevTx:
+				l.applyProUpRevTx(tx, height, &undo)
+			}
+		}
+
+		// Spending the collateral removes the masternode.
+		for _, txIn := range msgTx.TxIn {
+			mn := l.MasternodeByCollateral(&txIn.PreviousOutPoint)
+			if mn != nil {
+				l.set(&mn.ProTxHash, nil, &undo)
+			}
+		}
+	}
+
+	if payee != nil {
+		if cur := l.mns[payee.ProTxHash]; cur != nil {
+			paid := *cur
+			paid.State.LastPaidHeight = height
+			l.set(&paid.ProTxHash, &paid, &undo)
+		}
+	}
+
+	l.blockHash = *blockHash
+	l.height = height
+	return undo.entries
+}
+
+// revertBlock reverts the changes recorded in the passed undo data of the
+// block the list is for, which turns it into the list of the passed previous
+// block.
+func (l *List) revertBlock(entries []undoEntry, prevHash *chainhash.Hash) {
+	for i := len(entries) - 1; i >= 0; i-- {
+		entry := &entries[i]
+		l.set(&entry.proTxHash, entry.prev, nil)
+	}
+	l.blockHash = *prevHash
+	l.height--
+}
+
+// simplifiedEqual returns whether or not the fields of the passed masternodes
+// which are part of the simplified masternode list used by SPV clients are
+// equal.
+func simplifiedEqual(a, b *Masternode) bool {
+	sa, sb := &a.State, &b.State
+	return a.Type == b.Type && sa.Version == sb.Version &&
+		sa.ConfirmedHash == sb.ConfirmedHash &&
+		sa.IP.Equal(sb.IP) && sa.Port == sb.Port &&
+		sa.PubKeyOperator == sb.PubKeyOperator &&
+		sa.KeyIDVoting == sb.KeyIDVoting &&
+		a.IsValid() == b.IsValid() &&
+		sa.Platform == sb.Platform &&
+		bytes.Equal(sa.ScriptPayout, sb.ScriptPayout) &&
+		bytes.Equal(sa.ScriptOperatorPayout, sb.ScriptOperatorPayout)
+}
+
+// ListDiff describes the changes between two masternode lists.
+type ListDiff struct {
+	BaseBlockHash chainhash.Hash
+	BlockHash     chainhash.Hash
+
+	// Added holds the masternodes which are only in the newer list.
+	Added []*Masternode
+
+	// Updated holds the masternodes in both lists whose simplified
+	// entries differ.  The entries are those of the newer list.
+	Updated []*Masternode
+
+	// Removed holds the ProTxHash of the masternodes which are only in
+	// the base list.
+	Removed []chainhash.Hash
+}
+
+// Diff returns the changes from the list to the passed newer list.
+func (l *List) Diff(to *List) *ListDiff {
+	diff := ListDiff{
+		BaseBlockHash: l.blockHash,
+		BlockHash:     to.blockHash,
+	}
+	for hash, mn := range to.mns {
+		base, ok := l.mns[hash]
+		switch {
+		case !ok:
+			diff.Added = append(diff.Added, mn)
+		case !simplifiedEqual(base, mn):
+			diff.Updated = append(diff.Updated, mn)
+		}
+	}
+	for hash := range l.mns {
+		if _, ok := to.mns[hash]; !ok {
+			diff.Removed = append(diff.Removed, hash)
+		}
+	}
+
+	sortMasternodes(diff.Added)
+	sortMasternodes(diff.Updated)
+	sort.Slice(diff.Removed, func(i, j int) bool {
+		return compareHashes(&diff.Removed[i], &diff.Removed[j]) < 0
+	})
+	return &diff
+}
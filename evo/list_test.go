@@ -0,0 +1,404 @@
+// Copyright (c) 2013-2016 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package evo
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/davecgh/go-spew/spew"
+	"github.com/eager7/dashd/bls"
+	"github.com/eager7/dashd/btcec"
+	"github.com/eager7/dashd/chaincfg/chainhash"
+	"github.com/eager7/dashd/txscript"
+	"github.com/eager7/dashd/wire"
+	"github.com/eager7/dashutil"
+)
+
+// payloadSerializer is implemented by all special transaction payloads.
+type payloadSerializer interface {
+	Serialize(w io.Writer) error
+}
+
+// newSpecialTx returns a special transaction of the passed type with the
+// serialized payload and the passed inputs.  The nonce makes the hashes of
+// otherwise identical transactions differ.
+func newSpecialTx(t *testing.T, txType wire.TxType, payload payloadSerializer,
+	nonce uint32, spends ...wire.OutPoint) *wire.MsgTx {
+
+	var buf bytes.Buffer
+	if err := payload.Serialize(&buf); err != nil {
+		t.Fatalf("Serialize: unexpected error %v", err)
+	}
+	tx := &wire.MsgTx{
+		Version:  wire.SpecialTxVersion,
+		Type:     txType,
+		LockTime: nonce,
+		Payload:  buf.Bytes(),
+	}
+	for _, op := range spends {
+		tx.TxIn = append(tx.TxIn, &wire.TxIn{
+			PreviousOutPoint: op,
+			Sequence:         wire.MaxTxInSequenceNum,
+		})
+	}
+	return tx
+}
+
+// newTestBlock returns a block at the passed height with the passed previous
+// block and transactions.
+func newTestBlock(prev *chainhash.Hash, height int32, txs ...*wire.MsgTx) *dashutil.Block {
+	block := dashutil.NewBlock(&wire.MsgBlock{
+		Header: wire.BlockHeader{
+			PrevBlock: *prev,
+			Nonce:     uint32(height),
+		},
+		Transactions: txs,
+	})
+	block.SetHeight(height)
+	return block
+}
+
+// testOperatorKey returns the operator key of the test masternode with the
+// passed collateral index.
+func testOperatorKey(t *testing.T, index uint32) *bls.PrivateKey {
+	var b [bls.PrivKeySize]byte
+	b[0], b[31] = 0x01, byte(index)
+	key, err := bls.PrivKeyFromBytes(b[:])
+	if err != nil {
+		t.Fatalf("PrivKeyFromBytes: unexpected error %v", err)
+	}
+	return key
+}
+
+// testOwnerKey returns the owner key of the test masternode with the passed
+// collateral index.
+func testOwnerKey(index uint32) *btcec.PrivateKey {
+	key, _ := btcec.PrivKeyFromBytes(btcec.S256(), []byte{0x02, byte(index)})
+	return key
+}
+
+// testKeyID returns the key id of the passed owner key.
+func testKeyID(key *btcec.PrivateKey) [wire.KeyIDSize]byte {
+	var keyID [wire.KeyIDSize]byte
+	copy(keyID[:], dashutil.Hash160(key.PubKey().SerializeCompressed()))
+	return keyID
+}
+
+// payToKeyID returns a pay-to-pubkey-hash script paying to the passed key id.
+func payToKeyID(keyID [wire.KeyIDSize]byte) []byte {
+	script := []byte{txscript.OP_DUP, txscript.OP_HASH160,
+		txscript.OP_DATA_20}
+	script = append(script, keyID[:]...)
+	return append(script, txscript.OP_EQUALVERIFY, txscript.OP_CHECKSIG)
+}
+
+// newProRegTx returns a registration of a masternode with the passed
+// collateral.  A nil IP registers the masternode without a service.  The keys
+// of the masternode are derived from the index of the collateral, and a
+// collateral without a hash is added as an output of the registration.
+func newProRegTx(t *testing.T, collateral wire.OutPoint, ip net.IP) *wire.MsgTx {
+	funding := wire.OutPoint{Hash: chainhash.Hash{0xff}, Index: collateral.Index}
+	operatorKey := testOperatorKey(t, collateral.Index)
+	payload := &wire.ProRegTxPayload{
+		Version:            wire.ProTxVersionBasicBLS,
+		CollateralOutpoint: collateral,
+		IP:                 ip,
+		Port:               9999,
+		KeyIDOwner:         testKeyID(testOwnerKey(collateral.Index)),
+		KeyIDVoting:        [wire.KeyIDSize]byte{0x01, byte(collateral.Index)},
+		ScriptPayout: payToKeyID([wire.KeyIDSize]byte{0x02,
+			byte(collateral.Index)}),
+		InputsHash: calcInputsHash(&wire.MsgTx{
+			TxIn: []*wire.TxIn{{PreviousOutPoint: funding}},
+		}),
+	}
+	copy(payload.PubKeyOperator[:], operatorKey.PubKey().Serialize())
+	if ip == nil {
+		payload.Port = 0
+	}
+	tx := newSpecialTx(t, wire.TxTypeProRegTx, payload, collateral.Index,
+		funding)
+	if collateral.Hash == (chainhash.Hash{}) {
+		for i := uint32(0); i <= collateral.Index; i++ {
+			tx.AddTxOut(wire.NewTxOut(0, nil))
+		}
+		tx.TxOut[collateral.Index].Value = collateralAmount
+	}
+	return tx
+}
+
+// newProUpServTx returns an update of the service of the masternode with the
+// passed ProTxHash signed by the operator key of the passed collateral index.
+func newProUpServTx(t *testing.T, proTxHash chainhash.Hash, index uint32,
+	ip net.IP, port uint16) *wire.MsgTx {
+
+	payload := &wire.ProUpServTxPayload{
+		Version:    wire.ProTxVersionBasicBLS,
+		ProTxHash:  proTxHash,
+		IP:         ip,
+		Port:       port,
+		InputsHash: calcInputsHash(&wire.MsgTx{}),
+	}
+	hash, err := proUpServTxSigHash(payload)
+	if err != nil {
+		t.Fatalf("proUpServTxSigHash: unexpected error %v", err)
+	}
+	sig := testOperatorKey(t, index).Sign(hash[:])
+	copy(payload.Sig[:], sig.Serialize())
+	return newSpecialTx(t, wire.TxTypeProUpServTx, payload, 0)
+}
+
+// TestListApplyBlocks ensures provider transactions and collateral spends are
+// applied to the masternode list and reverted by the undo data.
+func TestListApplyBlocks(t *testing.T) {
+	ip := net.ParseIP("1.2.3.4")
+	collateral1 := wire.OutPoint{Hash: chainhash.Hash{0x01}, Index: 1}
+	collateral2 := wire.OutPoint{Hash: chainhash.Hash{0x02}, Index: 2}
+	reg1 := newProRegTx(t, collateral1, ip)
+	reg2 := newProRegTx(t, collateral2, nil)
+	proTxHash1, proTxHash2 := reg1.TxHash(), reg2.TxHash()
+
+	list := newList(&chainhash.Hash{}, -1)
+	genesis := newTestBlock(&chainhash.Hash{}, 0)
+	list.applyBlock(genesis)
+
+	// Block 1 registers both masternodes.  The second one has no service
+	// address, so it starts banned.
+	block1 := newTestBlock(genesis.Hash(), 1, reg1, reg2)
+	undo1 := list.applyBlock(block1)
+	if list.Count() != 2 || list.ValidCount() != 1 {
+		t.Fatalf("block 1: got %d masternodes (%d valid), want 2 (1)",
+			list.Count(), list.ValidCount())
+	}
+	mn1 := list.Masternode(&proTxHash1)
+	if mn1 == nil || mn1.State.RegisteredHeight != 1 ||
+		mn1.Status() != StatusEnabled || mn1.Service() != "1.2.3.4:9999" {
+
+		t.Fatalf("block 1: unexpected masternode %s", spew.Sdump(mn1))
+	}
+	if list.MasternodeByCollateral(&collateral2).Status() != StatusPoSeBanned {
+		t.Fatal("block 1: masternode without service is not banned")
+	}
+	afterBlock1 := list.clone()
+
+	// Block 2 confirms both masternodes and pays the only valid one.  The
+	// second masternode gets a service which revives it.
+	upServ := newProUpServTx(t, proTxHash2, 2, ip, 19999)
+	block2 := newTestBlock(block1.Hash(), 2, upServ)
+	undo2 := list.applyBlock(block2)
+	mn1 = list.Masternode(&proTxHash1)
+	mn2 := list.Masternode(&proTxHash2)
+	if mn1.State.LastPaidHeight != 2 ||
+		mn1.State.ConfirmedHash != *block2.Hash() {
+
+		t.Fatalf("block 2: unexpected masternode %s", spew.Sdump(mn1))
+	}
+	if !mn2.IsValid() || mn2.State.PoSeRevivedHeight != 2 ||
+		mn2.State.Port != 19999 {
+
+		t.Fatalf("block 2: unexpected masternode %s", spew.Sdump(mn2))
+	}
+
+	// The masternodes were paid and revived at the same height, so the
+	// one with the lower hash is paid next.
+	if compareHashes(&proTxHash1, &proTxHash2) < 0 {
+		mn1, mn2 = mn2, mn1
+	}
+	projected := list.ProjectedPayees(5)
+	if len(projected) != 2 || projected[0] != mn2 || projected[1] != mn1 {
+		t.Fatalf("block 2: unexpected projected payees %v", projected)
+	}
+	if list.Payee() != mn2 {
+		t.Fatal("block 2: unexpected payee")
+	}
+	afterBlock2 := list.clone()
+
+	// Block 3 revokes the operator of the first masternode and spends the
+	// collateral of the second one.
+	upRevPayload := &wire.ProUpRevTxPayload{
+		Version:    wire.ProTxVersionBasicBLS,
+		ProTxHash:  proTxHash1,
+		Reason:     2,
+		InputsHash: calcInputsHash(&wire.MsgTx{}),
+	}
+	hash, err := proUpRevTxSigHash(upRevPayload)
+	if err != nil {
+		t.Fatalf("proUpRevTxSigHash: unexpected error %v", err)
+	}
+	copy(upRevPayload.Sig[:], testOperatorKey(t, 1).Sign(hash[:]).Serialize())
+	upRev := newSpecialTx(t, wire.TxTypeProUpRevTx, upRevPayload, 0)
+	spend := &wire.MsgTx{
+		Version: 1,
+		TxIn: []*wire.TxIn{{
+			PreviousOutPoint: collateral2,
+			Sequence:         wire.MaxTxInSequenceNum,
+		}},
+	}
+	block3 := newTestBlock(block2.Hash(), 3, upRev, spend)
+	undo3 := list.applyBlock(block3)
+	if list.Count() != 1 || list.Masternode(&proTxHash2) != nil {
+		t.Fatal("block 3: spent masternode was not removed")
+	}
+	mn1 = list.Masternode(&proTxHash1)
+	if mn1.IsValid() || mn1.State.PoSeBanHeight != 3 ||
+		mn1.State.RevocationReason != 2 || mn1.State.IP != nil ||
+		mn1.State.PubKeyOperator != [wire.BLSPubKeySize]byte{} {
+
+		t.Fatalf("block 3: unexpected masternode %s", spew.Sdump(mn1))
+	}
+	if list.Payee() != nil {
+		t.Fatal("block 3: list without valid masternodes has a payee")
+	}
+
+	// The diff from block 1 must include the removal and the update.
+	diff := afterBlock1.Diff(list)
+	if len(diff.Added) != 0 || len(diff.Updated) != 1 ||
+		diff.Updated[0].ProTxHash != proTxHash1 ||
+		len(diff.Removed) != 1 || diff.Removed[0] != proTxHash2 ||
+		diff.BlockHash != *block3.Hash() {
+
+		t.Fatalf("unexpected diff %s", spew.Sdump(diff))
+	}
+
+	// Reverting the blocks must result in the earlier lists.
+	list.revertBlock(undo3, block2.Hash())
+	if !reflect.DeepEqual(list, afterBlock2) {
+		t.Fatalf("revert block 3\n got: %s want: %s", spew.Sdump(list),
+			spew.Sdump(afterBlock2))
+	}
+	list.revertBlock(undo2, block1.Hash())
+	if !reflect.DeepEqual(list, afterBlock1) {
+		t.Fatalf("revert block 2\n got: %s want: %s", spew.Sdump(list),
+			spew.Sdump(afterBlock1))
+	}
+	list.revertBlock(undo1, genesis.Hash())
+	if list.Count() != 0 || list.Height() != 0 {
+		t.Fatalf("revert block 1: got %d masternodes at height %d",
+			list.Count(), list.Height())
+	}
+}
+
+// TestListInternalCollateral ensures a registration with a collateral output
+// of its own is removed once that output is spent and that a registration
+// reusing a collateral replaces the previous masternode.
+func TestListInternalCollateral(t *testing.T) {
+	reg := newProRegTx(t, wire.OutPoint{Index: 1}, net.ParseIP("1.2.3.4"))
+	proTxHash := reg.TxHash()
+	collateral := wire.OutPoint{Hash: proTxHash, Index: 1}
+
+	list := newList(&chainhash.Hash{}, 0)
+	block1 := newTestBlock(&chainhash.Hash{}, 1, reg)
+	list.applyBlock(block1)
+	mn := list.MasternodeByCollateral(&collateral)
+	if mn == nil || mn.ProTxHash != proTxHash {
+		t.Fatal("internal collateral was not resolved")
+	}
+
+	// A new registration with the same collateral replaces the
+	// masternode.
+	replacement := newProRegTx(t, collateral, net.ParseIP("1.2.3.5"))
+	block2 := newTestBlock(block1.Hash(), 2, replacement)
+	list.applyBlock(block2)
+	if list.Count() != 1 || list.Masternode(&proTxHash) != nil {
+		t.Fatal("masternode was not replaced")
+	}
+
+	spend := &wire.MsgTx{
+		Version: 1,
+		TxIn: []*wire.TxIn{{
+			PreviousOutPoint: collateral,
+			Sequence:         wire.MaxTxInSequenceNum,
+		}},
+	}
+	list.applyBlock(newTestBlock(block2.Hash(), 3, spend))
+	if list.Count() != 0 {
+		t.Fatal("spent masternode was not removed")
+	}
+}
+
+// TestListProTxValidation ensures provider transactions which are not valid in
+// the context of the list are ignored.
+func TestListProTxValidation(t *testing.T) {
+	ip := net.ParseIP("1.2.3.4")
+	collateral1 := wire.OutPoint{Hash: chainhash.Hash{0x01}, Index: 1}
+	reg1 := newProRegTx(t, collateral1, ip)
+	proTxHash1 := reg1.TxHash()
+
+	list := newList(&chainhash.Hash{}, 0)
+	block1 := newTestBlock(&chainhash.Hash{}, 1, reg1)
+	list.applyBlock(block1)
+	if list.Count() != 1 {
+		t.Fatalf("block 1: got %d masternodes, want 1", list.Count())
+	}
+
+	// A registration reusing the service of the first masternode, one
+	// with an inputs hash which does not match its inputs and one with an
+	// operator key which is not on the curve are ignored.
+	collateral2 := wire.OutPoint{Hash: chainhash.Hash{0x02}, Index: 2}
+	dupService := newProRegTx(t, collateral2, ip)
+	badInputs := newProRegTx(t, collateral2, net.ParseIP("1.2.3.5"))
+	badInputs.TxIn[0].PreviousOutPoint.Index++
+	badKeyPayload, err := newProRegTx(t, collateral2,
+		net.ParseIP("1.2.3.6")).ProRegTxPayload()
+	if err != nil {
+		t.Fatalf("ProRegTxPayload: unexpected error %v", err)
+	}
+	badKeyPayload.PubKeyOperator[wire.BLSPubKeySize-1] ^= 0x01
+	badKey := newSpecialTx(t, wire.TxTypeProRegTx, badKeyPayload, 3,
+		wire.OutPoint{Hash: chainhash.Hash{0xff}, Index: 2})
+
+	// An update of the service which is not signed by the operator is
+	// ignored as well.
+	badSig := newProUpServTx(t, proTxHash1, 2, ip, 19999)
+
+	block2 := newTestBlock(block1.Hash(), 2, dupService, badInputs, badKey,
+		badSig)
+	list.applyBlock(block2)
+	if list.Count() != 1 || list.Masternode(&proTxHash1).State.Port != 9999 {
+		t.Fatalf("block 2: invalid provider transactions were applied %s",
+			spew.Sdump(list.Masternodes()))
+	}
+
+	// An update of the registrar is only applied when it is signed by the
+	// owner.
+	newProUpRegTx := func(ownerKey *btcec.PrivateKey) *wire.MsgTx {
+		payload := &wire.ProUpRegTxPayload{
+			Version:      wire.ProTxVersionBasicBLS,
+			ProTxHash:    proTxHash1,
+			KeyIDVoting:  [wire.KeyIDSize]byte{0x03},
+			ScriptPayout: payToKeyID([wire.KeyIDSize]byte{0x04}),
+			InputsHash:   calcInputsHash(&wire.MsgTx{}),
+		}
+		copy(payload.PubKeyOperator[:],
+			testOperatorKey(t, 3).PubKey().Serialize())
+		hash, err := proUpRegTxSigHash(payload)
+		if err != nil {
+			t.Fatalf("proUpRegTxSigHash: unexpected error %v", err)
+		}
+		payload.Sig, err = btcec.SignCompact(btcec.S256(), ownerKey,
+			hash[:], true)
+		if err != nil {
+			t.Fatalf("SignCompact: unexpected error %v", err)
+		}
+		return newSpecialTx(t, wire.TxTypeProUpRegTx, payload, 0)
+	}
+	block3 := newTestBlock(block2.Hash(), 3, newProUpRegTx(testOwnerKey(2)))
+	list.applyBlock(block3)
+	if list.Masternode(&proTxHash1).State.KeyIDVoting[0] != 0x01 {
+		t.Fatal("block 3: registrar update with a foreign signature " +
+			"was applied")
+	}
+	block4 := newTestBlock(block3.Hash(), 4, newProUpRegTx(testOwnerKey(1)))
+	list.applyBlock(block4)
+	if mn := list.Masternode(&proTxHash1); mn.State.KeyIDVoting[0] != 0x03 ||
+		mn.IsValid() {
+
+		t.Fatalf("block 4: unexpected masternode %s", spew.Sdump(mn))
+	}
+}
@@ -0,0 +1,475 @@
+// Copyright (c) 2013-2016 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package evo
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/eager7/dashd/blockchain"
+	"github.com/eager7/dashd/bls"
+	"github.com/eager7/dashd/chaincfg/chainhash"
+	"github.com/eager7/dashd/database"
+	"github.com/eager7/dashd/wire"
+	"github.com/eager7/dashutil"
+)
+
+const (
+	// mnListIndexName is the human-readable name of the masternode list
+	// as reported by the index manager.
+	mnListIndexName = "deterministic masternode list"
+
+	// operatorKeyLookback is the number of blocks before the current tip
+	// whose lists are searched for the operator key of a masternode which
+	// is no longer in the current list.  It matches the number of blocks
+	// CoinJoin searches for the masternode of a broadcast transaction.
+	operatorKeyLookback = 24
+)
+
+var (
+	// mnListBucketName is the name of the bucket used to house the
+	// masternode list.  It is also the key of the list in the index
+	// manager.
+	mnListBucketName = []byte("mnlistidx")
+
+	// mnBucketName is the name of the bucket, below the masternode list
+	// bucket, which maps the ProTxHash of the masternodes in the current
+	// list to their serialized entries.
+	mnBucketName = []byte("mns")
+
+	// undoBucketName is the name of the bucket, below the masternode list
+	// bucket, which maps the height of blocks to the undo data of the
+	// changes the block made to the list.  Blocks which did not change
+	// the list have no entry.
+	undoBucketName = []byte("undo")
+
+	// tipKeyName is the key, in the masternode list bucket, of the hash
+	// and height of the block the current list is for.
+	tipKeyName = []byte("tip")
+
+	// byteOrder is the byte order used to serialize numeric fields for
+	// storage in the database.
+	byteOrder = binary.LittleEndian
+)
+
+// heightKey returns the key of the undo data for the block at the passed
+// height.  The big endian encoding keeps the keys in height order.
+func heightKey(height int32) []byte {
+	var key [4]byte
+	binary.BigEndian.PutUint32(key[:], uint32(height))
+	return key[:]
+}
+
+// serializeUndo returns the serialization of the passed undo data.  It is a
+// count followed by the ProTxHash of each changed masternode, a flag whether
+// or not the masternode existed before the block and, if it did, its
+// serialized previous entry.
+func serializeUndo(entries []undoEntry) []byte {
+	var buf bytes.Buffer
+
+	// Writes to a bytes.Buffer never fail.
+	_ = wire.WriteVarInt(&buf, 0, uint64(len(entries)))
+	for i := range entries {
+		entry := &entries[i]
+		buf.Write(entry.proTxHash[:])
+		if entry.prev == nil {
+			buf.WriteByte(0)
+			continue
+		}
+		buf.WriteByte(1)
+		_ = wire.WriteVarBytes(&buf, 0, serializeMasternode(entry.prev))
+	}
+	return buf.Bytes()
+}
+
+// deserializeUndo decodes the undo data serialized by serializeUndo.
+func deserializeUndo(serialized []byte) ([]undoEntry, error) {
+	r := bytes.NewReader(serialized)
+	count, err := wire.ReadVarInt(r, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	// Each entry is at least a hash and a flag.
+	if count > uint64(len(serialized))/(chainhash.HashSize+1) {
+		return nil, fmt.Errorf("undo entry count %d exceeds the "+
+			"serialized size %d", count, len(serialized))
+	}
+
+	entries := make([]undoEntry, count)
+	for i := range entries {
+		entry := &entries[i]
+		if _, err := io.ReadFull(r, entry.proTxHash[:]); err != nil {
+			return nil, err
+		}
+		existed, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		if existed == 0 {
+			continue
+		}
+		mnBytes, err := wire.ReadVarBytes(r, 0, uint32(len(serialized)),
+			"masternode")
+		if err != nil {
+			return nil, err
+		}
+		entry.prev, err = deserializeMasternode(mnBytes)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return entries, nil
+}
+
+// Manager maintains the deterministic masternode list of the main chain.  It
+// implements the indexer interface of the blockchain/indexers package, which
+// takes care of connecting and disconnecting blocks and of catching up with
+// the main chain when the list is first created.
+type Manager struct {
+	db database.DB
+
+	mtx  sync.RWMutex
+	list *List
+}
+
+// Key returns the database key of the masternode list.  This is part of the
+// indexers.Indexer interface.
+func (m *Manager) Key() []byte {
+	return mnListBucketName
+}
+
+// Name returns the human-readable name of the masternode list.  This is part
+// of the indexers.Indexer interface.
+func (m *Manager) Name() string {
+	return mnListIndexName
+}
+
+// Create creates the buckets of the masternode list the first time it is
+// enabled.  This is part of the indexers.Indexer interface.
+func (m *Manager) Create(dbTx database.Tx) error {
+	bucket, err := dbTx.Metadata().CreateBucket(mnListBucketName)
+	if err != nil {
+		return err
+	}
+	if _, err := bucket.CreateBucket(mnBucketName); err != nil {
+		return err
+	}
+	_, err = bucket.CreateBucket(undoBucketName)
+	return err
+}
+
+// Init loads the current masternode list from the database.  This is part of
+// the indexers.Indexer interface.
+func (m *Manager) Init() error {
+	return m.db.View(func(dbTx database.Tx) error {
+		bucket := dbTx.Metadata().Bucket(mnListBucketName)
+
+		// The list is empty as of the block before the genesis block
+		// until the first block is connected.
+		list := newList(&chainhash.Hash{}, -1)
+		if tip := bucket.Get(tipKeyName); tip != nil {
+			if len(tip) != chainhash.HashSize+4 {
+				return fmt.Errorf("unexpected masternode list tip "+
+					"size %d", len(tip))
+			}
+			copy(list.blockHash[:], tip)
+			list.height = int32(byteOrder.Uint32(
+				tip[chainhash.HashSize:]))
+		}
+
+		err := bucket.Bucket(mnBucketName).ForEach(func(k, v []byte) error {
+			mn, err := deserializeMasternode(v)
+			if err != nil {
+				return err
+			}
+			list.set(&mn.ProTxHash, mn, nil)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		m.mtx.Lock()
+		m.list = list
+		m.mtx.Unlock()
+		log.Infof("Loaded %d masternodes as of height %d", list.Count(),
+			list.height)
+		return nil
+	})
+}
+
+// dbPutChanges stores the changes recorded in the passed undo data, the undo
+// data itself and the tip of the passed list, which must be the list the
+// changes were applied to.  When store is false, the undo data is removed
+// instead.
+func dbPutChanges(dbTx database.Tx, list *List, entries []undoEntry,
+	height int32, store bool) error {
+
+	bucket := dbTx.Metadata().Bucket(mnListBucketName)
+	mnBucket := bucket.Bucket(mnBucketName)
+	for i := range entries {
+		hash := &entries[i].proTxHash
+		mn := list.mns[*hash]
+		if mn == nil {
+			if err := mnBucket.Delete(hash[:]); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := mnBucket.Put(hash[:], serializeMasternode(mn)); err != nil {
+			return err
+		}
+	}
+
+	undoBucket := bucket.Bucket(undoBucketName)
+	var err error
+	switch {
+	case !store:
+		err = undoBucket.Delete(heightKey(height))
+	case len(entries) != 0:
+		err = undoBucket.Put(heightKey(height), serializeUndo(entries))
+	}
+	if err != nil {
+		return err
+	}
+
+	var tip [chainhash.HashSize + 4]byte
+	copy(tip[:], list.blockHash[:])
+	byteOrder.PutUint32(tip[chainhash.HashSize:], uint32(list.height))
+	return bucket.Put(tipKeyName, tip[:])
+}
+
+// ConnectBlock updates the masternode list with the passed block.  This is
+// part of the indexers.Indexer interface.
+func (m *Manager) ConnectBlock(dbTx database.Tx, block *dashutil.Block,
+	stxos []blockchain.SpentTxOut) error {
+
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	prevHash := block.MsgBlock().Header.PrevBlock
+	if prevHash != m.list.blockHash {
+		return fmt.Errorf("block %v does not extend the masternode "+
+			"list tip %v", block.Hash(), m.list.blockHash)
+	}
+
+	entries := m.list.applyBlock(block)
+	err := dbPutChanges(dbTx, m.list, entries, block.Height(), true)
+	if err != nil {
+		m.list.revertBlock(entries, &prevHash)
+		return err
+	}
+	return nil
+}
+
+// DisconnectBlock reverts the changes the passed block made to the
+// masternode list.  This is part of the indexers.Indexer interface.
+func (m *Manager) DisconnectBlock(dbTx database.Tx, block *dashutil.Block,
+	stxos []blockchain.SpentTxOut) error {
+
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	if *block.Hash() != m.list.blockHash {
+		return fmt.Errorf("block %v is not the masternode list tip %v",
+			block.Hash(), m.list.blockHash)
+	}
+
+	entries, err := dbFetchUndo(dbTx, block.Height())
+	if err != nil {
+		return err
+	}
+	prevHash := block.MsgBlock().Header.PrevBlock
+	m.list.revertBlock(entries, &prevHash)
+	err = dbPutChanges(dbTx, m.list, entries, block.Height(), false)
+	if err != nil {
+		m.list.applyBlock(block)
+		return err
+	}
+	return nil
+}
+
+// dbFetchUndo returns the undo data of the block at the passed height.
+func dbFetchUndo(dbTx database.Tx, height int32) ([]undoEntry, error) {
+	bucket := dbTx.Metadata().Bucket(mnListBucketName).Bucket(undoBucketName)
+	serialized := bucket.Get(heightKey(height))
+	if serialized == nil {
+		return nil, nil
+	}
+	return deserializeUndo(serialized)
+}
+
+// List returns the masternode list as of the current tip of the main chain.
+//
+// This function is safe for concurrent access.
+func (m *Manager) List() *List {
+	m.mtx.RLock()
+	list := m.list.clone()
+	m.mtx.RUnlock()
+	return list
+}
+
+// ListAt returns the masternode list as of the passed block of the main chain
+// at the passed height.  The caller is responsible for ensuring the block is
+// part of the main chain.  Since the list is reconstructed by reverting the
+// blocks after the passed height, this is more expensive the further back the
+// block is.
+//
+// This function is safe for concurrent access.
+func (m *Manager) ListAt(blockHash *chainhash.Hash, height int32) (*List, error) {
+	m.mtx.RLock()
+	defer m.mtx.RUnlock()
+
+	if height < 0 || height > m.list.height {
+		return nil, fmt.Errorf("no masternode list at height %d, the "+
+			"list tip is at height %d", height, m.list.height)
+	}
+
+	list := m.list.clone()
+	err := m.db.View(func(dbTx database.Tx) error {
+		for list.height > height {
+			entries, err := dbFetchUndo(dbTx, list.height)
+			if err != nil {
+				return err
+			}
+
+			// The undo data does not include the hashes of the
+			// blocks, so the hash is set once the list is reached.
+			list.revertBlock(entries, &chainhash.Hash{})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	list.blockHash = *blockHash
+	return list, nil
+}
+
+// ValidMasternodeCount returns the number of masternodes in the list as of the
+// current tip of the main chain which are not banned.  This is part of the
+// coinjoin.MasternodeList interface.
+//
+// This function is safe for concurrent access.
+func (m *Manager) ValidMasternodeCount() int {
+	m.mtx.RLock()
+	defer m.mtx.RUnlock()
+
+	return m.list.ValidCount()
+}
+
+// findMasternode returns the valid masternode with the passed collateral
+// outpoint or, when the outpoint is null, the passed ProTxHash.  The list as
+// of the current tip of the main chain is searched first, followed by the
+// lists as of up to lookback of its ancestors.  It returns nil when no such
+// masternode is found.
+//
+// This function MUST be called with the manager lock held (for reads).
+func (m *Manager) findMasternode(collateral *wire.OutPoint, proTxHash *chainhash.Hash, lookback int32) *Masternode {
+	find := func(list *List) *Masternode {
+		var mn *Masternode
+		if *collateral != (wire.OutPoint{}) {
+			mn = list.MasternodeByCollateral(collateral)
+		} else {
+			mn = list.Masternode(proTxHash)
+		}
+		if mn == nil || !mn.IsValid() {
+			return nil
+		}
+		return mn
+	}
+
+	if mn := find(m.list); mn != nil || lookback <= 0 {
+		return mn
+	}
+
+	var mn *Masternode
+	list := m.list.clone()
+	err := m.db.View(func(dbTx database.Tx) error {
+		for i := int32(0); i < lookback && list.height > 0; i++ {
+			entries, err := dbFetchUndo(dbTx, list.height)
+			if err != nil {
+				return err
+			}
+			list.revertBlock(entries, &chainhash.Hash{})
+			if mn = find(list); mn != nil {
+				return nil
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Errorf("Unable to look up masternode in previous lists: %v",
+			err)
+		return nil
+	}
+	return mn
+}
+
+// LookupMasternode returns the ProTxHash of the valid masternode with the
+// passed collateral outpoint or, when the outpoint is null, the passed
+// ProTxHash.  The list as of the current tip of the main chain is searched
+// first, followed by the lists as of up to lookback of its ancestors.  This is
+// part of the coinjoin.MasternodeList interface.
+//
+// This function is safe for concurrent access.
+func (m *Manager) LookupMasternode(collateral *wire.OutPoint, proTxHash *chainhash.Hash, lookback int32) (chainhash.Hash, bool) {
+	m.mtx.RLock()
+	defer m.mtx.RUnlock()
+
+	mn := m.findMasternode(collateral, proTxHash, lookback)
+	if mn == nil {
+		return chainhash.Hash{}, false
+	}
+	return mn.ProTxHash, true
+}
+
+// VerifyOperatorSig returns whether or not sig is a valid BLS signature of hash
+// by the operator key of the masternode with the passed ProTxHash.  The key is
+// taken from the most recent of the lists searched by LookupMasternode with a
+// lookback of operatorKeyLookback which contains the masternode.  Keys which
+// were registered with a version 1 provider transaction are decoded from the
+// legacy serialization.  This is part of the coinjoin.MasternodeList
+// interface.
+//
+// This function is safe for concurrent access.
+func (m *Manager) VerifyOperatorSig(proTxHash, hash *chainhash.Hash, sig []byte) bool {
+	m.mtx.RLock()
+	mn := m.findMasternode(&wire.OutPoint{}, proTxHash, operatorKeyLookback)
+	m.mtx.RUnlock()
+	if mn == nil {
+		return false
+	}
+
+	var pubKey *bls.PublicKey
+	var err error
+	if mn.State.Version == wire.ProTxVersionLegacyBLS {
+		pubKey, err = bls.ParseLegacyPubKey(mn.State.PubKeyOperator[:])
+	} else {
+		pubKey, err = bls.ParsePubKey(mn.State.PubKeyOperator[:])
+	}
+	if err != nil {
+		return false
+	}
+	signature, err := bls.ParseSignature(sig)
+	if err != nil {
+		return false
+	}
+	return signature.Verify(hash[:], pubKey)
+}
+
+// New returns a new manager of the deterministic masternode list which is
+// stored in the passed database.  It must be added to the indexes of the index
+// manager so it is notified of the blocks connected to the main chain.
+func New(db database.DB) *Manager {
+	return &Manager{
+		db:   db,
+		list: newList(&chainhash.Hash{}, -1),
+	}
+}
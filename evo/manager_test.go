@@ -0,0 +1,213 @@
+// Copyright (c) 2013-2016 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package evo
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/davecgh/go-spew/spew"
+	"github.com/eager7/dashd/chaincfg/chainhash"
+	"github.com/eager7/dashd/database"
+	_ "github.com/eager7/dashd/database/ffldb"
+	"github.com/eager7/dashd/wire"
+	"github.com/eager7/dashutil"
+)
+
+// TestSerializeMasternode ensures masternodes and undo data round trip
+// through their database serialization.
+func TestSerializeMasternode(t *testing.T) {
+	mn := &Masternode{
+		ProTxHash:          chainhash.Hash{0x01},
+		CollateralOutpoint: wire.OutPoint{Hash: chainhash.Hash{0x02}, Index: 3},
+		OperatorReward:     1000,
+		Type:               wire.MasternodeTypeEvo,
+		State: MasternodeState{
+			Version:              wire.ProTxVersionBasicBLS,
+			RegisteredHeight:     100,
+			LastPaidHeight:       200,
+			PoSePenalty:          5,
+			PoSeRevivedHeight:    150,
+			PoSeBanHeight:        -1,
+			ConfirmedHash:        chainhash.Hash{0x04},
+			KeyIDOwner:           [wire.KeyIDSize]byte{0x05},
+			PubKeyOperator:       [wire.BLSPubKeySize]byte{0x06},
+			KeyIDVoting:          [wire.KeyIDSize]byte{0x07},
+			IP:                   net.ParseIP("1.2.3.4"),
+			Port:                 9999,
+			ScriptPayout:         []byte{0x51},
+			ScriptOperatorPayout: []byte{0x52, 0x53},
+			Platform: wire.PlatformInfo{
+				NodeID:   [wire.KeyIDSize]byte{0x08},
+				P2PPort:  26656,
+				HTTPPort: 443,
+			},
+		},
+	}
+	got, err := deserializeMasternode(serializeMasternode(mn))
+	if err != nil {
+		t.Fatalf("deserializeMasternode: unexpected error %v", err)
+	}
+	if !reflect.DeepEqual(got, mn) {
+		t.Fatalf("deserializeMasternode\n got: %s want: %s",
+			spew.Sdump(got), spew.Sdump(mn))
+	}
+
+	// Masternodes without operator fields must round trip as well.
+	mn.State.resetOperatorFields()
+	got, err = deserializeMasternode(serializeMasternode(mn))
+	if err != nil {
+		t.Fatalf("deserializeMasternode: unexpected error %v", err)
+	}
+	if !reflect.DeepEqual(got, mn) {
+		t.Fatalf("deserializeMasternode\n got: %s want: %s",
+			spew.Sdump(got), spew.Sdump(mn))
+	}
+
+	entries := []undoEntry{
+		{proTxHash: chainhash.Hash{0x09}},
+		{proTxHash: mn.ProTxHash, prev: mn},
+	}
+	gotEntries, err := deserializeUndo(serializeUndo(entries))
+	if err != nil {
+		t.Fatalf("deserializeUndo: unexpected error %v", err)
+	}
+	if !reflect.DeepEqual(gotEntries, entries) {
+		t.Fatalf("deserializeUndo\n got: %s want: %s",
+			spew.Sdump(gotEntries), spew.Sdump(entries))
+	}
+
+	// Truncated data must be rejected.
+	serialized := serializeMasternode(mn)
+	_, err = deserializeMasternode(serialized[:len(serialized)-1])
+	if err == nil {
+		t.Fatal("deserializeMasternode: did not reject truncated data")
+	}
+}
+
+// TestManager ensures the manager persists the masternode list and can
+// reconstruct the lists of earlier blocks.
+func TestManager(t *testing.T) {
+	dbPath, err := ioutil.TempDir("", "evotest")
+	if err != nil {
+		t.Fatalf("TempDir: unexpected error %v", err)
+	}
+	defer os.RemoveAll(dbPath)
+	db, err := database.Create("ffldb", filepath.Join(dbPath, "db"),
+		wire.MainNet)
+	if err != nil {
+		t.Fatalf("database.Create: unexpected error %v", err)
+	}
+	defer db.Close()
+
+	m := New(db)
+	err = db.Update(func(dbTx database.Tx) error {
+		return m.Create(dbTx)
+	})
+	if err != nil {
+		t.Fatalf("Create: unexpected error %v", err)
+	}
+	if err := m.Init(); err != nil {
+		t.Fatalf("Init: unexpected error %v", err)
+	}
+
+	collateral := wire.OutPoint{Hash: chainhash.Hash{0x01}, Index: 1}
+	reg := newProRegTx(t, collateral, net.ParseIP("1.2.3.4"))
+	spend := &wire.MsgTx{
+		Version: 1,
+		TxIn: []*wire.TxIn{{
+			PreviousOutPoint: collateral,
+			Sequence:         wire.MaxTxInSequenceNum,
+		}},
+	}
+	genesis := newTestBlock(&chainhash.Hash{}, 0)
+	block1 := newTestBlock(genesis.Hash(), 1, reg)
+	block2 := newTestBlock(block1.Hash(), 2)
+	block3 := newTestBlock(block2.Hash(), 3, spend)
+	blocks := []*dashutil.Block{genesis, block1, block2, block3}
+	var lists []*List
+	for _, block := range blocks {
+		err := db.Update(func(dbTx database.Tx) error {
+			return m.ConnectBlock(dbTx, block, nil)
+		})
+		if err != nil {
+			t.Fatalf("ConnectBlock %d: unexpected error %v",
+				block.Height(), err)
+		}
+		lists = append(lists, m.List())
+	}
+	if lists[2].Count() != 1 || lists[3].Count() != 0 {
+		t.Fatalf("unexpected list sizes %d and %d", lists[2].Count(),
+			lists[3].Count())
+	}
+
+	// The masternode whose collateral was spent in the last block must
+	// only be found when looking back past it.
+	proTxHash := reg.TxHash()
+	for _, outpoint := range []wire.OutPoint{collateral, {}} {
+		_, ok := m.LookupMasternode(&outpoint, &proTxHash, 0)
+		if ok {
+			t.Fatalf("LookupMasternode %v: found spent masternode",
+				outpoint)
+		}
+		got, ok := m.LookupMasternode(&outpoint, &proTxHash, 1)
+		if !ok || got != proTxHash {
+			t.Fatalf("LookupMasternode %v: got %v, %v want %v",
+				outpoint, got, ok, proTxHash)
+		}
+	}
+	if m.ValidMasternodeCount() != 0 {
+		t.Fatalf("ValidMasternodeCount: got %d want 0",
+			m.ValidMasternodeCount())
+	}
+
+	// Blocks which do not extend the tip must be rejected.
+	err = db.Update(func(dbTx database.Tx) error {
+		return m.ConnectBlock(dbTx, block1, nil)
+	})
+	if err == nil {
+		t.Fatal("ConnectBlock: did not reject block not extending tip")
+	}
+
+	// The lists of earlier blocks must be reconstructed from the undo
+	// data.
+	for i, block := range blocks {
+		list, err := m.ListAt(block.Hash(), block.Height())
+		if err != nil {
+			t.Fatalf("ListAt %d: unexpected error %v", i, err)
+		}
+		if !reflect.DeepEqual(list, lists[i]) {
+			t.Fatalf("ListAt %d\n got: %s want: %s", i,
+				spew.Sdump(list), spew.Sdump(lists[i]))
+		}
+	}
+	if _, err := m.ListAt(&chainhash.Hash{}, 4); err == nil {
+		t.Fatal("ListAt: did not reject height after the tip")
+	}
+
+	// Disconnect the last two blocks and ensure a new manager loads the
+	// same list from the database.
+	for _, block := range []*dashutil.Block{block3, block2} {
+		err := db.Update(func(dbTx database.Tx) error {
+			return m.DisconnectBlock(dbTx, block, nil)
+		})
+		if err != nil {
+			t.Fatalf("DisconnectBlock %d: unexpected error %v",
+				block.Height(), err)
+		}
+	}
+	m2 := New(db)
+	if err := m2.Init(); err != nil {
+		t.Fatalf("Init: unexpected error %v", err)
+	}
+	if !reflect.DeepEqual(m2.List(), lists[1]) {
+		t.Fatalf("Init\n got: %s want: %s", spew.Sdump(m2.List()),
+			spew.Sdump(lists[1]))
+	}
+}
@@ -0,0 +1,238 @@
+// Copyright (c) 2013-2016 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package evo
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"strconv"
+
+	"github.com/eager7/dashd/chaincfg/chainhash"
+	"github.com/eager7/dashd/wire"
+)
+
+const (
+	// StatusEnabled is the status of masternodes which are eligible for
+	// payments and quorum membership.
+	StatusEnabled = "ENABLED"
+
+	// StatusPoSeBanned is the status of masternodes which were banned
+	// either for failing to provide service or by their provider.
+	StatusPoSeBanned = "POSE_BANNED"
+)
+
+// MasternodeState houses the part of a masternode entry which is changed by
+// provider update transactions and by the processing of blocks.
+type MasternodeState struct {
+	Version              uint16
+	RegisteredHeight     int32
+	LastPaidHeight       int32
+	PoSePenalty          int32
+	PoSeRevivedHeight    int32
+	PoSeBanHeight        int32
+	RevocationReason     uint16
+	ConfirmedHash        chainhash.Hash
+	KeyIDOwner           [wire.KeyIDSize]byte
+	PubKeyOperator       [wire.BLSPubKeySize]byte
+	KeyIDVoting          [wire.KeyIDSize]byte
+	IP                   net.IP
+	Port                 uint16
+	ScriptPayout         []byte
+	ScriptOperatorPayout []byte
+	Platform             wire.PlatformInfo
+}
+
+// Masternode is an entry of the deterministic masternode list.
+//
+// Entries are shared between lists, so they must not be modified once they
+// were added to a list.  Updates are made to a copy which then replaces the
+// entry instead.
+type Masternode struct {
+	ProTxHash          chainhash.Hash
+	CollateralOutpoint wire.OutPoint
+	OperatorReward     uint16
+	Type               wire.MasternodeType
+	State              MasternodeState
+}
+
+// IsValid returns whether or not the masternode is eligible for payments,
+// that is whether or not it is not banned.
+func (mn *Masternode) IsValid() bool {
+	return mn.State.PoSeBanHeight == -1
+}
+
+// Status returns the status of the masternode as reported by the RPC server.
+func (mn *Masternode) Status() string {
+	if mn.IsValid() {
+		return StatusEnabled
+	}
+	return StatusPoSeBanned
+}
+
+// Service returns the service address of the masternode in host:port form.
+func (mn *Masternode) Service() string {
+	ip := mn.State.IP
+	if ip == nil {
+		ip = net.IPv6zero
+	}
+	return net.JoinHostPort(ip.String(), strconv.Itoa(int(mn.State.Port)))
+}
+
+// paymentHeight returns the height which determines the position of the
+// masternode in the payment queue.  It is the most recent of the heights at
+// which the masternode was registered, revived and paid.
+func (mn *Masternode) paymentHeight() int32 {
+	height := mn.State.LastPaidHeight
+	if mn.State.PoSeRevivedHeight > height {
+		height = mn.State.PoSeRevivedHeight
+	}
+	if mn.State.RegisteredHeight > height {
+		height = mn.State.RegisteredHeight
+	}
+	return height
+}
+
+// banIfNotBanned bans the masternode at the passed height unless it is
+// already banned.
+func (s *MasternodeState) banIfNotBanned(height int32) {
+	if s.PoSeBanHeight == -1 {
+		s.PoSeBanHeight = height
+	}
+}
+
+// resetOperatorFields clears the fields set by the operator of the
+// masternode.  This is done when the operator key is changed or revoked.
+func (s *MasternodeState) resetOperatorFields() {
+	s.PubKeyOperator = [wire.BLSPubKeySize]byte{}
+	s.IP = nil
+	s.Port = 0
+	s.ScriptOperatorPayout = nil
+	s.RevocationReason = 0
+	s.Platform = wire.PlatformInfo{}
+}
+
+// serializeMasternode returns the serialization of the passed masternode for
+// storage in the database.
+//
+// The ProTxHash, collateral outpoint, operator reward and type are followed by
+// the state fields in the order they are declared.  Integers are little
+// endian, the IP is the 16 byte IPv6 (or IPv4-mapped) form, all zero when
+// unset, and the payout scripts are variable length byte arrays.
+func serializeMasternode(mn *Masternode) []byte {
+	var buf bytes.Buffer
+	le := binary.LittleEndian
+	var scratch [4]byte
+
+	putUint16 := func(v uint16) {
+		le.PutUint16(scratch[:2], v)
+		buf.Write(scratch[:2])
+	}
+	putInt32 := func(v int32) {
+		le.PutUint32(scratch[:], uint32(v))
+		buf.Write(scratch[:])
+	}
+
+	s := &mn.State
+	buf.Write(mn.ProTxHash[:])
+	buf.Write(mn.CollateralOutpoint.Hash[:])
+	le.PutUint32(scratch[:], mn.CollateralOutpoint.Index)
+	buf.Write(scratch[:])
+	putUint16(mn.OperatorReward)
+	putUint16(uint16(mn.Type))
+	putUint16(s.Version)
+	putInt32(s.RegisteredHeight)
+	putInt32(s.LastPaidHeight)
+	putInt32(s.PoSePenalty)
+	putInt32(s.PoSeRevivedHeight)
+	putInt32(s.PoSeBanHeight)
+	putUint16(s.RevocationReason)
+	buf.Write(s.ConfirmedHash[:])
+	buf.Write(s.KeyIDOwner[:])
+	buf.Write(s.PubKeyOperator[:])
+	buf.Write(s.KeyIDVoting[:])
+	var ip [16]byte
+	if s.IP != nil {
+		copy(ip[:], s.IP.To16())
+	}
+	buf.Write(ip[:])
+	putUint16(s.Port)
+
+	// Writes to a bytes.Buffer never fail.
+	_ = wire.WriteVarBytes(&buf, 0, s.ScriptPayout)
+	_ = wire.WriteVarBytes(&buf, 0, s.ScriptOperatorPayout)
+
+	buf.Write(s.Platform.NodeID[:])
+	putUint16(s.Platform.P2PPort)
+	putUint16(s.Platform.HTTPPort)
+	return buf.Bytes()
+}
+
+// deserializeMasternode decodes a masternode from the passed serialized bytes
+// as described by serializeMasternode.
+func deserializeMasternode(serialized []byte) (*Masternode, error) {
+	r := bytes.NewReader(serialized)
+	le := binary.LittleEndian
+
+	var mn Masternode
+	s := &mn.State
+	var ip [16]byte
+	var mnType uint16
+	err := readFields(r, le, mn.ProTxHash[:], mn.CollateralOutpoint.Hash[:],
+		&mn.CollateralOutpoint.Index, &mn.OperatorReward, &mnType,
+		&s.Version, &s.RegisteredHeight, &s.LastPaidHeight,
+		&s.PoSePenalty, &s.PoSeRevivedHeight, &s.PoSeBanHeight,
+		&s.RevocationReason, s.ConfirmedHash[:], s.KeyIDOwner[:],
+		s.PubKeyOperator[:], s.KeyIDVoting[:], ip[:], &s.Port)
+	if err != nil {
+		return nil, err
+	}
+	mn.Type = wire.MasternodeType(mnType)
+	if ip != [16]byte{} {
+		s.IP = net.IP(ip[:])
+	}
+
+	s.ScriptPayout, err = wire.ReadVarBytes(r, 0, wire.MaxTxExtraPayload,
+		"payout script")
+	if err != nil {
+		return nil, err
+	}
+	s.ScriptOperatorPayout, err = wire.ReadVarBytes(r, 0,
+		wire.MaxTxExtraPayload, "operator payout script")
+	if err != nil {
+		return nil, err
+	}
+	if len(s.ScriptPayout) == 0 {
+		s.ScriptPayout = nil
+	}
+	if len(s.ScriptOperatorPayout) == 0 {
+		s.ScriptOperatorPayout = nil
+	}
+
+	err = readFields(r, le, s.Platform.NodeID[:], &s.Platform.P2PPort,
+		&s.Platform.HTTPPort)
+	if err != nil {
+		return nil, err
+	}
+	return &mn, nil
+}
+
+// readFields reads the passed fields from r.  Byte slices are filled
+// completely while the other fields are decoded with the passed byte order.
+func readFields(r *bytes.Reader, order binary.ByteOrder, fields ...interface{}) error {
+	for _, field := range fields {
+		var err error
+		if b, ok := field.([]byte); ok {
+			_, err = io.ReadFull(r, b)
+		} else {
+			err = binary.Read(r, order, field)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,367 @@
+// Copyright (c) 2013-2016 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package evo
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+
+	"github.com/eager7/dashd/bls"
+	"github.com/eager7/dashd/btcec"
+	"github.com/eager7/dashd/chaincfg/chainhash"
+	"github.com/eager7/dashd/txscript"
+	"github.com/eager7/dashd/wire"
+	"github.com/eager7/dashutil"
+)
+
+const (
+	// maxOperatorReward is the maximum share of the masternode reward in
+	// hundredths of a percent which can be assigned to the operator.
+	maxOperatorReward = 10000
+
+	// maxRevocationReason is the highest known reason for revoking the
+	// operator of a masternode.
+	maxRevocationReason = 3
+
+	// collateralAmount and evoCollateralAmount are the amounts of the
+	// collateral outputs of regular and evo masternodes.
+	collateralAmount    = 1000 * dashutil.SatoshiPerBitcoin
+	evoCollateralAmount = 4000 * dashutil.SatoshiPerBitcoin
+)
+
+// calcInputsHash returns the hash of the outpoints spent by the passed
+// transaction.  Provider transactions commit to it so their signatures can't
+// be replayed in other transactions.
+func calcInputsHash(msgTx *wire.MsgTx) chainhash.Hash {
+	buf := make([]byte, 0, len(msgTx.TxIn)*(chainhash.HashSize+4))
+	var index [4]byte
+	for _, txIn := range msgTx.TxIn {
+		binary.LittleEndian.PutUint32(index[:],
+			txIn.PreviousOutPoint.Index)
+		buf = append(buf, txIn.PreviousOutPoint.Hash[:]...)
+		buf = append(buf, index[:]...)
+	}
+	return chainhash.DoubleHashH(buf)
+}
+
+// payloadSigHash returns the hash the signature of a provider transaction
+// payload commits to, which is the hash of the serialized payload without its
+// trailing signature.  The signature must take sigSize bytes once serialized.
+func payloadSigHash(payload interface {
+	Serialize(w io.Writer) error
+}, sigSize int) (chainhash.Hash, error) {
+
+	var buf bytes.Buffer
+	if err := payload.Serialize(&buf); err != nil {
+		return chainhash.Hash{}, err
+	}
+	return chainhash.DoubleHashH(buf.Bytes()[:buf.Len()-sigSize]), nil
+}
+
+// proUpServTxSigHash returns the hash the operator signs to update the service
+// of a masternode.
+func proUpServTxSigHash(payload *wire.ProUpServTxPayload) (chainhash.Hash, error) {
+	return payloadSigHash(payload, wire.BLSSigSize)
+}
+
+// proUpRegTxSigHash returns the hash the owner signs to update the registrar
+// of a masternode.
+func proUpRegTxSigHash(payload *wire.ProUpRegTxPayload) (chainhash.Hash, error) {
+	p := *payload
+	p.Sig = nil
+	return payloadSigHash(&p, 1)
+}
+
+// proUpRevTxSigHash returns the hash the operator signs to revoke its key.
+func proUpRevTxSigHash(payload *wire.ProUpRevTxPayload) (chainhash.Hash, error) {
+	return payloadSigHash(payload, wire.BLSSigSize)
+}
+
+// parseOperatorKey decodes the passed operator key, which is in the legacy
+// serialization when it was registered by a provider transaction of version 1.
+func parseOperatorKey(key *[wire.BLSPubKeySize]byte, version uint16) (*bls.PublicKey, error) {
+	if version == wire.ProTxVersionLegacyBLS {
+		return bls.ParseLegacyPubKey(key[:])
+	}
+	return bls.ParsePubKey(key[:])
+}
+
+// checkOperatorSig returns an error unless sig is a valid signature of hash by
+// the operator key of the passed masternode state.
+//
+// Provider transactions of version 1 are signed with the legacy BLS scheme,
+// which hashes messages to the curve differently than the basic scheme.  Their
+// signatures can't be checked, so they are accepted as is.
+func checkOperatorSig(hash *chainhash.Hash, sig *[wire.BLSSigSize]byte,
+	state *MasternodeState, payloadVersion uint16) error {
+
+	if payloadVersion == wire.ProTxVersionLegacyBLS {
+		return nil
+	}
+	key, err := parseOperatorKey(&state.PubKeyOperator, state.Version)
+	if err != nil {
+		return fmt.Errorf("invalid operator key: %v", err)
+	}
+	signature, err := bls.ParseSignature(sig[:])
+	if err != nil {
+		return fmt.Errorf("invalid operator signature: %v", err)
+	}
+	if !signature.Verify(hash[:], key) {
+		return errors.New("operator signature is invalid")
+	}
+	return nil
+}
+
+// checkKeyIDSig returns an error unless sig is a valid compact signature of
+// hash by the key with the passed key id.
+func checkKeyIDSig(hash *chainhash.Hash, sig []byte, keyID *[wire.KeyIDSize]byte) error {
+	pubKey, compressed, err := btcec.RecoverCompact(btcec.S256(), sig,
+		hash[:])
+	if err != nil {
+		return fmt.Errorf("invalid signature: %v", err)
+	}
+	serialized := pubKey.SerializeUncompressed()
+	if compressed {
+		serialized = pubKey.SerializeCompressed()
+	}
+	if !bytes.Equal(dashutil.Hash160(serialized), keyID[:]) {
+		return errors.New("signature does not match the key id")
+	}
+	return nil
+}
+
+// checkPayoutScript returns an error unless the passed script pays to a public
+// key hash or a script hash.  Paying to one of the passed key ids is not
+// allowed either, since those keys are meant to be kept apart from funds.
+func checkPayoutScript(script []byte, keyIDs ...[wire.KeyIDSize]byte) error {
+	switch txscript.GetScriptClass(script) {
+	case txscript.PubKeyHashTy:
+		for _, keyID := range keyIDs {
+			if bytes.Equal(script[3:23], keyID[:]) {
+				return errors.New("payout script reuses the " +
+					"owner or voting key")
+			}
+		}
+		return nil
+
+	case txscript.ScriptHashTy:
+		return nil
+	}
+	return errors.New("payout script is neither pay-to-pubkey-hash nor " +
+		"pay-to-script-hash")
+}
+
+// checkService returns an error when the passed service address is not a
+// valid IPv4 address or is already used by a masternode other than the one
+// with the passed ProTxHash.
+func (l *List) checkService(ip net.IP, port uint16, proTxHash *chainhash.Hash) error {
+	if ip.To4() == nil || port == 0 {
+		return fmt.Errorf("invalid service address %v", ip)
+	}
+	for _, mn := range l.mns {
+		if mn.ProTxHash != *proTxHash && mn.State.Port == port &&
+			mn.State.IP.Equal(ip) {
+
+			return fmt.Errorf("service address %s is already used "+
+				"by masternode %v", mn.Service(), mn.ProTxHash)
+		}
+	}
+	return nil
+}
+
+// checkOperatorKey returns an error when the passed operator key is invalid or
+// already used by a masternode other than the one with the passed ProTxHash.
+func (l *List) checkOperatorKey(key *[wire.BLSPubKeySize]byte, version uint16,
+	proTxHash *chainhash.Hash) error {
+
+	if _, err := parseOperatorKey(key, version); err != nil {
+		return fmt.Errorf("invalid operator key: %v", err)
+	}
+	for _, mn := range l.mns {
+		if mn.ProTxHash != *proTxHash && mn.State.PubKeyOperator == *key {
+			return fmt.Errorf("operator key is already used by "+
+				"masternode %v", mn.ProTxHash)
+		}
+	}
+	return nil
+}
+
+// checkProRegTx returns an error when the passed provider registration, which
+// registers a masternode with the passed collateral, is invalid in the context
+// of the list.  Masternodes which already use the collateral are replaced by
+// the registration, so they are not considered duplicates.
+//
+// The signature of a registration with an external collateral is made with the
+// key of the collateral output, which is not known to the list, so it is not
+// checked here.
+func (l *List) checkProRegTx(tx *dashutil.Tx, payload *wire.ProRegTxPayload,
+	collateral *wire.OutPoint) error {
+
+	msgTx := tx.MsgTx()
+	if payload.Mode != 0 {
+		return fmt.Errorf("unknown mode %d", payload.Mode)
+	}
+	amount := int64(collateralAmount)
+	switch payload.MasternodeType {
+	case wire.MasternodeTypeRegular:
+	case wire.MasternodeTypeEvo:
+		if payload.Version < wire.ProTxVersionBasicBLS {
+			return fmt.Errorf("evo masternodes require version %d",
+				wire.ProTxVersionBasicBLS)
+		}
+		if payload.Platform.NodeID == [wire.KeyIDSize]byte{} {
+			return errors.New("platform node id is not set")
+		}
+		amount = evoCollateralAmount
+	default:
+		return fmt.Errorf("unknown masternode type %d",
+			payload.MasternodeType)
+	}
+	if payload.KeyIDOwner == [wire.KeyIDSize]byte{} ||
+		payload.KeyIDVoting == [wire.KeyIDSize]byte{} {
+
+		return errors.New("owner or voting key is not set")
+	}
+	if payload.OperatorReward > maxOperatorReward {
+		return fmt.Errorf("operator reward %d is above the maximum of %d",
+			payload.OperatorReward, maxOperatorReward)
+	}
+	err := checkPayoutScript(payload.ScriptPayout, payload.KeyIDOwner,
+		payload.KeyIDVoting)
+	if err != nil {
+		return err
+	}
+	if payload.InputsHash != calcInputsHash(msgTx) {
+		return errors.New("inputs hash does not match the inputs")
+	}
+
+	// A collateral output of the registration itself must have the exact
+	// collateral amount and makes a signature unnecessary.
+	if payload.CollateralOutpoint.Hash == (chainhash.Hash{}) {
+		index := payload.CollateralOutpoint.Index
+		if index >= uint32(len(msgTx.TxOut)) ||
+			msgTx.TxOut[index].Value != amount {
+
+			return fmt.Errorf("output %d is not a collateral of %v",
+				index, dashutil.Amount(amount))
+		}
+		if len(payload.Sig) != 0 {
+			return errors.New("registration with an internal " +
+				"collateral is signed")
+		}
+	}
+
+	// The keys and service must not be used by any other masternode.
+	var replaced chainhash.Hash
+	if mn := l.MasternodeByCollateral(collateral); mn != nil {
+		replaced = mn.ProTxHash
+	}
+	if !isEmptyService(payload.IP, payload.Port) {
+		err := l.checkService(payload.IP, payload.Port, &replaced)
+		if err != nil {
+			return err
+		}
+	}
+	err = l.checkOperatorKey(&payload.PubKeyOperator, payload.Version,
+		&replaced)
+	if err != nil {
+		return err
+	}
+	for _, mn := range l.mns {
+		if mn.ProTxHash != replaced &&
+			mn.State.KeyIDOwner == payload.KeyIDOwner {
+
+			return fmt.Errorf("owner key is already used by "+
+				"masternode %v", mn.ProTxHash)
+		}
+	}
+	return nil
+}
+
+// checkProUpServTx returns an error when the passed provider update service
+// of the passed masternode is invalid in the context of the list.
+func (l *List) checkProUpServTx(tx *dashutil.Tx, payload *wire.ProUpServTxPayload,
+	mn *Masternode) error {
+
+	if payload.MasternodeType != mn.Type {
+		return fmt.Errorf("masternode type %v does not match %v",
+			payload.MasternodeType, mn.Type)
+	}
+	err := l.checkService(payload.IP, payload.Port, &mn.ProTxHash)
+	if err != nil {
+		return err
+	}
+	if len(payload.ScriptOperatorPayout) != 0 {
+		if mn.OperatorReward == 0 {
+			return errors.New("operator payout script set without " +
+				"an operator reward")
+		}
+		err := checkPayoutScript(payload.ScriptOperatorPayout)
+		if err != nil {
+			return err
+		}
+	}
+	if payload.InputsHash != calcInputsHash(tx.MsgTx()) {
+		return errors.New("inputs hash does not match the inputs")
+	}
+	hash, err := proUpServTxSigHash(payload)
+	if err != nil {
+		return err
+	}
+	return checkOperatorSig(&hash, &payload.Sig, &mn.State, payload.Version)
+}
+
+// checkProUpRegTx returns an error when the passed provider update registrar
+// of the passed masternode is invalid in the context of the list.
+func (l *List) checkProUpRegTx(tx *dashutil.Tx, payload *wire.ProUpRegTxPayload,
+	mn *Masternode) error {
+
+	if payload.Mode != 0 {
+		return fmt.Errorf("unknown mode %d", payload.Mode)
+	}
+	if payload.KeyIDVoting == [wire.KeyIDSize]byte{} {
+		return errors.New("voting key is not set")
+	}
+	err := checkPayoutScript(payload.ScriptPayout, mn.State.KeyIDOwner,
+		payload.KeyIDVoting)
+	if err != nil {
+		return err
+	}
+	err = l.checkOperatorKey(&payload.PubKeyOperator, payload.Version,
+		&mn.ProTxHash)
+	if err != nil {
+		return err
+	}
+	if payload.InputsHash != calcInputsHash(tx.MsgTx()) {
+		return errors.New("inputs hash does not match the inputs")
+	}
+	hash, err := proUpRegTxSigHash(payload)
+	if err != nil {
+		return err
+	}
+	return checkKeyIDSig(&hash, payload.Sig, &mn.State.KeyIDOwner)
+}
+
+// checkProUpRevTx returns an error when the passed provider update revoke of
+// the passed masternode is invalid.
+func checkProUpRevTx(tx *dashutil.Tx, payload *wire.ProUpRevTxPayload,
+	mn *Masternode) error {
+
+	if payload.Reason > maxRevocationReason {
+		return fmt.Errorf("unknown revocation reason %d", payload.Reason)
+	}
+	if payload.InputsHash != calcInputsHash(tx.MsgTx()) {
+		return errors.New("inputs hash does not match the inputs")
+	}
+	hash, err := proUpRevTxSigHash(payload)
+	if err != nil {
+		return err
+	}
+	return checkOperatorSig(&hash, &payload.Sig, &mn.State, payload.Version)
+}
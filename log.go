@@ -13,8 +13,10 @@ import (
 	"github.com/eager7/dashd/addrmgr"
 	"github.com/eager7/dashd/blockchain"
 	"github.com/eager7/dashd/blockchain/indexers"
+	"github.com/eager7/dashd/coinjoin"
 	"github.com/eager7/dashd/connmgr"
 	"github.com/eager7/dashd/database"
+	"github.com/eager7/dashd/evo"
 	"github.com/eager7/dashd/mempool"
 	"github.com/eager7/dashd/mining"
 	"github.com/eager7/dashd/mining/cpuminer"
@@ -60,7 +62,9 @@ var (
 	bcdbLog = backendLog.Logger("BCDB")
 	btcdLog = backendLog.Logger("BTCD")
 	chanLog = backendLog.Logger("CHAN")
+	cjonLog = backendLog.Logger("CJON")
 	discLog = backendLog.Logger("DISC")
+	evoLog  = backendLog.Logger("EVO")
 	indxLog = backendLog.Logger("INDX")
 	minrLog = backendLog.Logger("MINR")
 	peerLog = backendLog.Logger("PEER")
@@ -77,6 +81,8 @@ func init() {
 	connmgr.UseLogger(cmgrLog)
 	database.UseLogger(bcdbLog)
 	blockchain.UseLogger(chanLog)
+	coinjoin.UseLogger(cjonLog)
+	evo.UseLogger(evoLog)
 	indexers.UseLogger(indxLog)
 	mining.UseLogger(minrLog)
 	cpuminer.UseLogger(minrLog)
@@ -94,7 +100,9 @@ var subsystemLoggers = map[string]dashlog.Logger{
 	"BCDB": bcdbLog,
 	"BTCD": btcdLog,
 	"CHAN": chanLog,
+	"CJON": cjonLog,
 	"DISC": discLog,
+	"EVO":  evoLog,
 	"INDX": indxLog,
 	"MINR": minrLog,
 	"PEER": peerLog,
@@ -17,6 +17,7 @@ import (
 	"github.com/eager7/dashd/btcjson"
 	"github.com/eager7/dashd/chaincfg"
 	"github.com/eager7/dashd/chaincfg/chainhash"
+	"github.com/eager7/dashd/coinjoin"
 	"github.com/eager7/dashd/mining"
 	"github.com/eager7/dashd/txscript"
 	"github.com/eager7/dashd/wire"
@@ -89,6 +90,14 @@ type Config struct {
 	// into the mempool or not.
 	IsDeploymentActive func(deploymentID uint32) (bool, error)
 
+	// CheckAssetUnlockTx defines the function to use in order to check
+	// that an asset unlock transaction is valid for inclusion in the next
+	// block.
+	//
+	// This field can be nil in which case all asset unlock transactions
+	// are rejected.
+	CheckAssetUnlockTx func(*dashutil.Tx) error
+
 	// SigCache defines a signature cache to use.
 	SigCache *txscript.SigCache
 
@@ -100,6 +109,17 @@ type Config struct {
 	// This can be nil if the address index is not enabled.
 	AddrIndex *indexers.AddrIndex
 
+	// AddrDeltaIndex defines the optional address delta index instance to
+	// use for indexing the balance changes of the unconfirmed transactions
+	// in the memory pool.  This can be nil if the address delta index is
+	// not enabled.
+	AddrDeltaIndex *indexers.AddrDeltaIndex
+
+	// SpentIndex defines the optional spent index instance to use for
+	// indexing the spends of the unconfirmed transactions in the memory
+	// pool.  This can be nil if the spent index is not enabled.
+	SpentIndex *indexers.SpentIndex
+
 	// FeeEstimatator provides a feeEstimator. If it is not nil, the mempool
 	// records all new transactions it observes into the feeEstimator.
 	FeeEstimator *FeeEstimator
@@ -182,8 +202,9 @@ type TxPool struct {
 	orphans       map[chainhash.Hash]*orphanTx
 	orphansByPrev map[wire.OutPoint]map[chainhash.Hash]*dashutil.Tx
 	outpoints     map[wire.OutPoint]*dashutil.Tx
-	pennyTotal    float64 // exponentially decaying total for penny spends.
-	lastPennyUnix int64   // unix time of last ``penny spend''
+	assetUnlocks  map[uint64]*dashutil.Tx // keyed by withdrawal index
+	pennyTotal    float64                 // exponentially decaying total for penny spends.
+	lastPennyUnix int64                   // unix time of last ``penny spend''
 
 	// nextExpireScan is the time after which the orphan pool will be
 	// scanned in order to evict orphans.  This is NOT a hard deadline as
@@ -462,6 +483,32 @@ func (mp *TxPool) HaveTransaction(hash *chainhash.Hash) bool {
 	return haveTx
 }
 
+// HaveAssetUnlock returns whether or not an asset unlock with the passed
+// withdrawal index exists in the main pool.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) HaveAssetUnlock(index uint64) bool {
+	mp.mtx.RLock()
+	_, exists := mp.assetUnlocks[index]
+	mp.mtx.RUnlock()
+
+	return exists
+}
+
+// assetUnlockIndex returns the withdrawal index of the passed transaction when
+// it is an asset unlock.  The boolean is false for all other transactions.
+func assetUnlockIndex(tx *dashutil.Tx) (uint64, bool) {
+	if !blockchain.IsAssetUnlockTx(tx.MsgTx()) {
+		return 0, false
+	}
+	payload, err := tx.MsgTx().AssetUnlockPayload()
+	if err != nil {
+		return 0, false
+	}
+
+	return payload.Index, true
+}
+
 // removeTransaction is the internal function which implements the public
 // RemoveTransaction.  See the comment for RemoveTransaction for more details.
 //
@@ -485,11 +532,22 @@ func (mp *TxPool) removeTransaction(tx *dashutil.Tx, removeRedeemers bool) {
 		if mp.cfg.AddrIndex != nil {
 			mp.cfg.AddrIndex.RemoveUnconfirmedTx(txHash)
 		}
+		if mp.cfg.AddrDeltaIndex != nil {
+			mp.cfg.AddrDeltaIndex.RemoveUnconfirmedTx(txHash)
+		}
+		if mp.cfg.SpentIndex != nil {
+			mp.cfg.SpentIndex.RemoveUnconfirmedTx(txHash)
+		}
 
 		// Mark the referenced outpoints as unspent by the pool.
 		for _, txIn := range txDesc.Tx.MsgTx().TxIn {
 			delete(mp.outpoints, txIn.PreviousOutPoint)
 		}
+
+		// Release the withdrawal index of asset unlocks.
+		if index, ok := assetUnlockIndex(tx); ok {
+			delete(mp.assetUnlocks, index)
+		}
 		delete(mp.pool, *txHash)
 		atomic.StoreInt64(&mp.lastUpdated, time.Now().Unix())
 	}
@@ -525,6 +583,16 @@ func (mp *TxPool) RemoveDoubleSpends(tx *dashutil.Tx) {
 			}
 		}
 	}
+
+	// Asset unlocks don't spend any outputs, but conflict with any other
+	// asset unlock using the same withdrawal index.
+	if index, ok := assetUnlockIndex(tx); ok {
+		if conflict, ok := mp.assetUnlocks[index]; ok {
+			if !conflict.Hash().IsEqual(tx.Hash()) {
+				mp.removeTransaction(conflict, true)
+			}
+		}
+	}
 	mp.mtx.Unlock()
 }
 
@@ -551,6 +619,9 @@ func (mp *TxPool) addTransaction(utxoView *blockchain.UtxoViewpoint, tx *dashuti
 	for _, txIn := range tx.MsgTx().TxIn {
 		mp.outpoints[txIn.PreviousOutPoint] = tx
 	}
+	if index, ok := assetUnlockIndex(tx); ok {
+		mp.assetUnlocks[index] = tx
+	}
 	atomic.StoreInt64(&mp.lastUpdated, time.Now().Unix())
 
 	// Add unconfirmed address index entries associated with the transaction
@@ -558,6 +629,12 @@ func (mp *TxPool) addTransaction(utxoView *blockchain.UtxoViewpoint, tx *dashuti
 	if mp.cfg.AddrIndex != nil {
 		mp.cfg.AddrIndex.AddUnconfirmedTx(tx, utxoView)
 	}
+	if mp.cfg.AddrDeltaIndex != nil {
+		mp.cfg.AddrDeltaIndex.AddUnconfirmedTx(tx, utxoView)
+	}
+	if mp.cfg.SpentIndex != nil {
+		mp.cfg.SpentIndex.AddUnconfirmedTx(tx)
+	}
 
 	// Record this tx for fee estimation if enabled.
 	if mp.cfg.FeeEstimator != nil {
@@ -921,10 +998,12 @@ func (mp *TxPool) validateReplacement(tx *dashutil.Tx,
 
 // maybeAcceptTransaction is the internal function which implements the public
 // MaybeAcceptTransaction.  See the comment for MaybeAcceptTransaction for
-// more details.
+// more details.  The isDSTx flag indicates the transaction was relayed as a
+// masternode-signed CoinJoin mixing transaction, which exempts it from the
+// fee requirements.
 //
 // This function MUST be called with the mempool lock held (for writes).
-func (mp *TxPool) maybeAcceptTransaction(tx *dashutil.Tx, isNew, rateLimit, rejectDupOrphans bool) ([]*chainhash.Hash, *TxDesc, error) {
+func (mp *TxPool) maybeAcceptTransaction(tx *dashutil.Tx, isNew, rateLimit, rejectDupOrphans, isDSTx bool) ([]*chainhash.Hash, *TxDesc, error) {
 	txHash := tx.Hash()
 
 	// If a transaction has iwtness data, and segwit isn't active yet, If
@@ -978,6 +1057,43 @@ func (mp *TxPool) maybeAcceptTransaction(tx *dashutil.Tx, isNew, rateLimit, reje
 	bestHeight := mp.cfg.BestHeight()
 	nextBlockHeight := bestHeight + 1
 
+	// Asset locks and unlocks can't be mined before the v20 hard fork.
+	msgTx := tx.MsgTx()
+	isAssetUnlock := blockchain.IsAssetUnlockTx(msgTx)
+	if (blockchain.IsAssetLockTx(msgTx) || isAssetUnlock) &&
+		nextBlockHeight < mp.cfg.ChainParams.V20Height {
+
+		str := fmt.Sprintf("transaction %v of type %v is not active "+
+			"before height %d", txHash, msgTx.Type,
+			mp.cfg.ChainParams.V20Height)
+		return nil, nil, txRuleError(wire.RejectInvalid, str)
+	}
+
+	// Asset unlocks must not reuse the withdrawal index of another asset
+	// unlock in the pool and must be valid in the context of the chain,
+	// which includes being signed by a platform quorum.
+	if isAssetUnlock {
+		index, _ := assetUnlockIndex(tx)
+		if _, exists := mp.assetUnlocks[index]; exists {
+			str := fmt.Sprintf("transaction %v uses asset unlock "+
+				"index %d which is already used in the pool",
+				txHash, index)
+			return nil, nil, txRuleError(wire.RejectDuplicate, str)
+		}
+		if mp.cfg.CheckAssetUnlockTx == nil {
+			str := fmt.Sprintf("transaction %v is an asset unlock "+
+				"which can't be verified", txHash)
+			return nil, nil, txRuleError(wire.RejectNonstandard, str)
+		}
+		err := mp.cfg.CheckAssetUnlockTx(tx)
+		if err != nil {
+			if cerr, ok := err.(blockchain.RuleError); ok {
+				return nil, nil, chainRuleError(cerr)
+			}
+			return nil, nil, err
+		}
+	}
+
 	medianTimePast := mp.cfg.MedianTimePast()
 
 	// Don't allow non-standard transactions if the network parameters
@@ -1142,6 +1258,15 @@ func (mp *TxPool) maybeAcceptTransaction(tx *dashutil.Tx, isNew, rateLimit, reje
 	serializedSize := GetTxVirtualSize(tx)
 	minFee := calcMinRequiredTxRelayFee(serializedSize,
 		mp.cfg.Policy.MinRelayTxFee)
+
+	// Mixing transactions only move denominated amounts, so they usually
+	// pay no fee at all.  Since they are signed by the masternode which
+	// coordinated the mixing session, and masternodes may only sign a
+	// limited number of them, they are exempt from the minimum fee,
+	// priority and rate limiting requirements below.
+	if isDSTx {
+		minFee = 0
+	}
 	if serializedSize >= (DefaultBlockPrioritySize-1000) && txFee < minFee {
 		str := fmt.Sprintf("transaction %v has %d fees which is under "+
 			"the required amount of %d", txHash, txFee,
@@ -1246,7 +1371,8 @@ func (mp *TxPool) maybeAcceptTransaction(tx *dashutil.Tx, isNew, rateLimit, reje
 func (mp *TxPool) MaybeAcceptTransaction(tx *dashutil.Tx, isNew, rateLimit bool) ([]*chainhash.Hash, *TxDesc, error) {
 	// Protect concurrent access.
 	mp.mtx.Lock()
-	hashes, txD, err := mp.maybeAcceptTransaction(tx, isNew, rateLimit, true)
+	hashes, txD, err := mp.maybeAcceptTransaction(tx, isNew, rateLimit, true,
+		false)
 	mp.mtx.Unlock()
 
 	return hashes, txD, err
@@ -1289,7 +1415,7 @@ func (mp *TxPool) processOrphans(acceptedTx *dashutil.Tx) []*TxDesc {
 			// Potentially accept an orphan into the tx pool.
 			for _, tx := range orphans {
 				missing, txD, err := mp.maybeAcceptTransaction(
-					tx, true, true, false)
+					tx, true, true, false, false)
 				if err != nil {
 					// The orphan is now invalid, so there
 					// is no way any other orphans which
@@ -1371,9 +1497,46 @@ func (mp *TxPool) ProcessTransaction(tx *dashutil.Tx, allowOrphan, rateLimit boo
 	mp.mtx.Lock()
 	defer mp.mtx.Unlock()
 
+	return mp.processTransaction(tx, allowOrphan, rateLimit, false, tag)
+}
+
+// ProcessDSTransaction handles insertion of a CoinJoin mixing transaction that
+// was relayed in a dstx message into the memory pool.  The caller is expected
+// to have verified the signature of the masternode which coordinated the
+// mixing session.
+//
+// The transaction must have the structure of a mixing transaction, in which
+// case it is exempt from the minimum relay fee, priority and free transaction
+// rate limiting requirements.  Otherwise, it is subject to the same rules as
+// transactions passed to ProcessTransaction, except that orphans are always
+// rejected.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) ProcessDSTransaction(tx *dashutil.Tx, tag Tag) ([]*TxDesc, error) {
+	log.Tracef("Processing mixing transaction %v", tx.Hash())
+
+	if !coinjoin.IsValidMixingTx(tx.MsgTx(), mp.cfg.ChainParams) {
+		str := fmt.Sprintf("transaction %v is not a valid mixing "+
+			"transaction", tx.Hash())
+		return nil, txRuleError(wire.RejectNonstandard, str)
+	}
+
+	// Protect concurrent access.
+	mp.mtx.Lock()
+	defer mp.mtx.Unlock()
+
+	return mp.processTransaction(tx, false, false, true, tag)
+}
+
+// processTransaction is the internal function which implements the public
+// ProcessTransaction and ProcessDSTransaction.  See the comments for them for
+// more details.
+//
+// This function MUST be called with the mempool lock held (for writes).
+func (mp *TxPool) processTransaction(tx *dashutil.Tx, allowOrphan, rateLimit, isDSTx bool, tag Tag) ([]*TxDesc, error) {
 	// Potentially accept the transaction to the memory pool.
 	missingParents, txD, err := mp.maybeAcceptTransaction(tx, true, rateLimit,
-		true)
+		true, isDSTx)
 	if err != nil {
 		return nil, err
 	}
@@ -1549,5 +1712,6 @@ func New(cfg *Config) *TxPool {
 		orphansByPrev:  make(map[wire.OutPoint]map[chainhash.Hash]*dashutil.Tx),
 		nextExpireScan: time.Now().Add(orphanExpireScanInterval),
 		outpoints:      make(map[wire.OutPoint]*dashutil.Tx),
+		assetUnlocks:   make(map[uint64]*dashutil.Tx),
 	}
 }
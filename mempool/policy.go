@@ -288,6 +288,16 @@ func checkTransactionStandard(tx *dashutil.Tx, height int32,
 		return txRuleError(wire.RejectNonstandard, str)
 	}
 
+	// Asset locks and unlocks are the only special transactions which are
+	// supported by the memory pool.
+	if msgTx.IsSpecial() && !blockchain.IsAssetLockTx(msgTx) &&
+		!blockchain.IsAssetUnlockTx(msgTx) {
+
+		str := fmt.Sprintf("special transaction type %v is not "+
+			"supported", msgTx.Type)
+		return txRuleError(wire.RejectNonstandard, str)
+	}
+
 	// The transaction must be finalized to be standard and therefore
 	// considered for inclusion in a block.
 	if !blockchain.IsFinalizedTransaction(tx, height, medianTimePast) {
@@ -0,0 +1,341 @@
+// Copyright (c) 2013-2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package netsync
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/eager7/dashd/blockchain"
+	"github.com/eager7/dashd/chaincfg/chainhash"
+	peerpkg "github.com/eager7/dashd/peer"
+	"github.com/eager7/dashd/wire"
+	"github.com/eager7/dashutil"
+)
+
+// errShortIDCollision is returned when a compact block contains the same short
+// transaction ID several times, so its transactions can not be told apart and
+// the full block must be requested instead.
+var errShortIDCollision = errors.New("duplicate short transaction IDs in " +
+	"compact block")
+
+// errMerkleRootMismatch is returned when the transactions of a reconstructed
+// block do not match the merkle root of its header, which happens when a
+// transaction of the memory pool has the short ID of another one of the block.
+var errMerkleRootMismatch = errors.New("reconstructed block does not match " +
+	"its merkle root")
+
+// partialBlock houses a block announced with a compact block (BIP0152) while
+// its transactions are gathered from the memory pool and from the peer which
+// sent it.
+type partialBlock struct {
+	header wire.BlockHeader
+	txns   []*wire.MsgTx
+}
+
+// newPartialBlock returns a partial block for the passed compact block with
+// the prefilled transactions and the transactions among the passed ones that
+// match its short transaction IDs filled in.  Short IDs which match several of
+// the passed transactions are left missing so those transactions are requested
+// from the peer.
+func newPartialBlock(msg *wire.MsgCmpctBlock, txns []*dashutil.Tx) (*partialBlock, error) {
+	if msg.TxCount() == 0 {
+		return nil, errors.New("compact block without transactions")
+	}
+
+	// The prefilled transactions are known to be in increasing order and
+	// within the block once decoded.
+	pb := &partialBlock{
+		header: msg.Header,
+		txns:   make([]*wire.MsgTx, msg.TxCount()),
+	}
+	for _, ptx := range msg.PrefilledTxs {
+		pb.txns[ptx.Index] = ptx.Tx
+	}
+
+	// The short IDs are for the transactions which were not prefilled in
+	// order.
+	indexes := make(map[uint64]int, len(msg.ShortIDs))
+	index := 0
+	for _, id := range msg.ShortIDs {
+		for pb.txns[index] != nil {
+			index++
+		}
+		if _, exists := indexes[id]; exists {
+			return nil, errShortIDCollision
+		}
+		indexes[id] = index
+		index++
+	}
+
+	k0, k1 := msg.ShortTxIDKeys()
+	collisions := make(map[int]struct{})
+	for _, tx := range txns {
+		index, ok := indexes[wire.ShortTxID(k0, k1, tx.Hash())]
+		if !ok {
+			continue
+		}
+		if _, ok := collisions[index]; ok {
+			continue
+		}
+		if pb.txns[index] != nil {
+			pb.txns[index] = nil
+			collisions[index] = struct{}{}
+			continue
+		}
+		pb.txns[index] = tx.MsgTx()
+	}
+
+	return pb, nil
+}
+
+// missing returns the indexes of the transactions of the block which are
+// still missing.
+func (pb *partialBlock) missing() []uint32 {
+	var indexes []uint32
+	for i, tx := range pb.txns {
+		if tx == nil {
+			indexes = append(indexes, uint32(i))
+		}
+	}
+	return indexes
+}
+
+// fill fills in the passed transactions, which must be all of the missing
+// transactions in order.
+func (pb *partialBlock) fill(txns []*wire.MsgTx) error {
+	missing := pb.missing()
+	if len(txns) != len(missing) {
+		return fmt.Errorf("received %d transactions for %d missing ones",
+			len(txns), len(missing))
+	}
+	for i, index := range missing {
+		pb.txns[index] = txns[i]
+	}
+	return nil
+}
+
+// block returns the block once all of its transactions are known, after
+// checking they match the merkle root of its header.
+func (pb *partialBlock) block() (*dashutil.Block, error) {
+	if missing := pb.missing(); len(missing) != 0 {
+		return nil, fmt.Errorf("%d transactions are missing",
+			len(missing))
+	}
+
+	msgBlock := wire.NewMsgBlock(&pb.header)
+	for _, tx := range pb.txns {
+		msgBlock.AddTransaction(tx)
+	}
+	block := dashutil.NewBlock(msgBlock)
+
+	merkles := blockchain.BuildMerkleTreeStore(block.Transactions(), false)
+	if !merkles[len(merkles)-1].IsEqual(&pb.header.MerkleRoot) {
+		return nil, errMerkleRootMismatch
+	}
+	return block, nil
+}
+
+// markBlockRequested records that the block with the passed hash is expected
+// from the peer with the passed state.
+func (sm *SyncManager) markBlockRequested(state *peerSyncState, hash *chainhash.Hash) {
+	sm.requestedBlocks[*hash] = struct{}{}
+	sm.limitMap(sm.requestedBlocks, maxRequestedBlocks)
+	state.requestedBlocks[*hash] = struct{}{}
+}
+
+// requestFullBlock requests the block with the passed hash from the peer with a
+// getdata message.  It is used when a compact block can not be reconstructed.
+func (sm *SyncManager) requestFullBlock(peer *peerpkg.Peer, state *peerSyncState, hash *chainhash.Hash) {
+	sm.markBlockRequested(state, hash)
+
+	gdmsg := wire.NewMsgGetData()
+	gdmsg.AddInvVect(wire.NewInvVect(wire.InvTypeBlock, hash))
+	peer.QueueMessage(gdmsg, nil)
+}
+
+// handleCmpctBlockMsg handles cmpctblock messages from all peers.  Once its
+// header has been added to the chain, the block is reconstructed from the
+// transactions of the memory pool, and those which are missing are requested
+// from the peer with a getblocktxn message.  Compact
+// blocks are accepted unrequested once the chain is current, since peers in
+// high bandwidth mode announce new blocks with them.
+func (sm *SyncManager) handleCmpctBlockMsg(cmsg *cmpctBlockMsg) {
+	peer := cmsg.peer
+	state, exists := sm.peerStates[peer]
+	if !exists {
+		log.Warnf("Received cmpctblock message from unknown peer %s",
+			peer)
+		return
+	}
+
+	msg := cmsg.cmpctBlock
+	blockHash := msg.BlockHash()
+	if _, requested := state.requestedBlocks[blockHash]; !requested &&
+		!sm.current() {
+
+		log.Debugf("Ignoring unrequested compact block %v from %s "+
+			"while not current", blockHash, peer)
+		return
+	}
+
+	// Nothing more is needed for blocks which are already known.
+	haveBlock, err := sm.chain.HaveBlock(&blockHash)
+	if err != nil {
+		log.Warnf("Unexpected failure when checking for existing "+
+			"block %v: %v", blockHash, err)
+		return
+	}
+	if haveBlock {
+		delete(state.requestedBlocks, blockHash)
+		delete(sm.requestedBlocks, blockHash)
+		return
+	}
+
+	// The header must connect to a known block and pass the proof of work
+	// and checkpoint checks before any work is done to reconstruct the
+	// block.  Blocks which do not extend a known block are orphans, which
+	// are handled along with their parents as full blocks.
+	err = sm.chain.ProcessBlockHeader(&msg.Header)
+	if ruleErr, ok := err.(blockchain.RuleError); ok &&
+		ruleErr.ErrorCode == blockchain.ErrPreviousBlockUnknown {
+
+		sm.requestFullBlock(peer, state, &blockHash)
+		return
+	}
+	if err != nil {
+		log.Warnf("Received compact block %v with invalid header from "+
+			"%s -- disconnecting: %v", blockHash, peer, err)
+		peer.Disconnect()
+		return
+	}
+
+	// Only a single block is reconstructed at a time for each peer, so
+	// the block which is still waiting for transactions is requested in
+	// full instead.
+	if pb := state.partialBlock; pb != nil {
+		hash := pb.header.BlockHash()
+		if hash == blockHash {
+			return
+		}
+		state.partialBlock = nil
+		sm.requestFullBlock(peer, state, &hash)
+	}
+
+	// Blocks whose parent has only been announced by its header are
+	// handled as full blocks as well.
+	haveParent, err := sm.chain.HaveBlock(&msg.Header.PrevBlock)
+	if err != nil || !haveParent {
+		sm.requestFullBlock(peer, state, &blockHash)
+		return
+	}
+
+	descs := sm.txMemPool.TxDescs()
+	txns := make([]*dashutil.Tx, 0, len(descs))
+	for _, desc := range descs {
+		txns = append(txns, desc.Tx)
+	}
+	pb, err := newPartialBlock(msg, txns)
+	if err == errShortIDCollision {
+		log.Debugf("Requesting block %v from %s in full: %v", blockHash,
+			peer, err)
+		sm.requestFullBlock(peer, state, &blockHash)
+		return
+	}
+	if err != nil {
+		log.Warnf("Invalid compact block %v from %s: %v -- "+
+			"disconnecting", blockHash, peer, err)
+		peer.Disconnect()
+		return
+	}
+
+	sm.markBlockRequested(state, &blockHash)
+	missing := pb.missing()
+	if len(missing) == 0 {
+		sm.processPartialBlock(peer, state, pb)
+		return
+	}
+
+	log.Debugf("Requesting %d of %d transactions of compact block %v "+
+		"from %s", len(missing), len(pb.txns), blockHash, peer)
+	state.partialBlock = pb
+	peer.QueueMessage(wire.NewMsgGetBlockTxn(&blockHash, missing), nil)
+}
+
+// handleBlockTxnMsg handles blocktxn messages from all peers, which deliver
+// the missing transactions of a compact block.
+func (sm *SyncManager) handleBlockTxnMsg(bmsg *blockTxnMsg) {
+	peer := bmsg.peer
+	state, exists := sm.peerStates[peer]
+	if !exists {
+		log.Warnf("Received blocktxn message from unknown peer %s",
+			peer)
+		return
+	}
+
+	msg := bmsg.blockTxn
+	pb := state.partialBlock
+	if pb == nil || pb.header.BlockHash() != msg.BlockHash {
+		log.Debugf("Ignoring unrequested blocktxn message for block "+
+			"%v from %s", msg.BlockHash, peer)
+		return
+	}
+	state.partialBlock = nil
+
+	if err := pb.fill(msg.Transactions); err != nil {
+		log.Warnf("Invalid blocktxn message for block %v from %s: %v "+
+			"-- disconnecting", msg.BlockHash, peer, err)
+		peer.Disconnect()
+		return
+	}
+	sm.processPartialBlock(peer, state, pb)
+}
+
+// processPartialBlock processes the block reconstructed from a compact block
+// once all of its transactions are known.  The full block is requested instead
+// when the transactions turn out not to match the block.
+func (sm *SyncManager) processPartialBlock(peer *peerpkg.Peer, state *peerSyncState, pb *partialBlock) {
+	block, err := pb.block()
+	if err != nil {
+		blockHash := pb.header.BlockHash()
+		log.Debugf("Requesting block %v from %s in full: %v", blockHash,
+			peer, err)
+		sm.requestFullBlock(peer, state, &blockHash)
+		return
+	}
+
+	sm.handleBlockMsg(&blockMsg{block: block, peer: peer})
+}
+
+// addHighBandwidthPeer asks the passed peer to announce new blocks with compact
+// blocks before validating them when it supports compact blocks.  Once there
+// are maxHighBandwidthPeers such peers, the one which was asked the longest
+// time ago is asked to stop.
+func (sm *SyncManager) addHighBandwidthPeer(peer *peerpkg.Peer) {
+	if !peer.WantsCmpctBlocks() {
+		return
+	}
+
+	// Move the peer to the back of the list when it was asked already.
+	for i, p := range sm.highBandwidthPeers {
+		if p == peer {
+			copy(sm.highBandwidthPeers[i:], sm.highBandwidthPeers[i+1:])
+			sm.highBandwidthPeers[len(sm.highBandwidthPeers)-1] = peer
+			return
+		}
+	}
+
+	if len(sm.highBandwidthPeers) >= maxHighBandwidthPeers {
+		oldest := sm.highBandwidthPeers[0]
+		oldest.QueueMessage(wire.NewMsgSendCmpct(false,
+			wire.CmpctBlockVersion), nil)
+		sm.highBandwidthPeers = sm.highBandwidthPeers[1:]
+	}
+
+	log.Debugf("Asking %s to announce new blocks with compact blocks", peer)
+	peer.QueueMessage(wire.NewMsgSendCmpct(true, wire.CmpctBlockVersion),
+		nil)
+	sm.highBandwidthPeers = append(sm.highBandwidthPeers, peer)
+}
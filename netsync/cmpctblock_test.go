@@ -0,0 +1,116 @@
+// Copyright (c) 2013-2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package netsync
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/eager7/dashd/blockchain"
+	"github.com/eager7/dashd/wire"
+	"github.com/eager7/dashutil"
+)
+
+// testBlock returns a block with a coinbase and the passed number of other
+// distinct transactions along with those transactions.
+func testBlock(numTxns int) (*wire.MsgBlock, []*dashutil.Tx) {
+	var txns []*dashutil.Tx
+	for i := 0; i <= numTxns; i++ {
+		tx := wire.NewMsgTx(wire.TxVersion)
+		tx.AddTxIn(&wire.TxIn{Sequence: wire.MaxTxInSequenceNum})
+		tx.AddTxOut(wire.NewTxOut(int64(i), nil))
+		tx.LockTime = uint32(i)
+		txns = append(txns, dashutil.NewTx(tx))
+	}
+
+	merkles := blockchain.BuildMerkleTreeStore(txns, false)
+	header := wire.BlockHeader{MerkleRoot: *merkles[len(merkles)-1]}
+	block := wire.NewMsgBlock(&header)
+	for _, tx := range txns {
+		block.AddTransaction(tx.MsgTx())
+	}
+	return block, txns[1:]
+}
+
+// TestPartialBlock ensures blocks are reconstructed from compact blocks with
+// the transactions of the memory pool and the missing ones.
+func TestPartialBlock(t *testing.T) {
+	block, txns := testBlock(4)
+	msg := wire.NewMsgCmpctBlockFromBlock(block, 42)
+
+	// All of the transactions are in the pool along with an unrelated
+	// one.
+	other, _ := testBlock(6)
+	pool := append([]*dashutil.Tx{dashutil.NewTx(other.Transactions[6])},
+		txns...)
+	pb, err := newPartialBlock(msg, pool)
+	if err != nil {
+		t.Fatalf("newPartialBlock: unexpected error: %v", err)
+	}
+	if missing := pb.missing(); len(missing) != 0 {
+		t.Fatalf("missing: unexpected missing transactions %v", missing)
+	}
+	got, err := pb.block()
+	if err != nil {
+		t.Fatalf("block: unexpected error: %v", err)
+	}
+	if *got.Hash() != block.BlockHash() ||
+		!reflect.DeepEqual(got.MsgBlock(), block) {
+		t.Fatalf("block: reconstructed block does not match")
+	}
+
+	// The transactions which are not in the pool are missing and filled
+	// in from the peer.
+	pb, err = newPartialBlock(msg, []*dashutil.Tx{txns[1], txns[2]})
+	if err != nil {
+		t.Fatalf("newPartialBlock: unexpected error: %v", err)
+	}
+	wantMissing := []uint32{1, 4}
+	if missing := pb.missing(); !reflect.DeepEqual(missing, wantMissing) {
+		t.Fatalf("missing: got %v, want %v", missing, wantMissing)
+	}
+	if _, err := pb.block(); err == nil {
+		t.Fatalf("block: unexpected success with missing transactions")
+	}
+	if err := pb.fill([]*wire.MsgTx{txns[0].MsgTx()}); err == nil {
+		t.Fatalf("fill: unexpected success with too few transactions")
+	}
+	err = pb.fill([]*wire.MsgTx{txns[0].MsgTx(), txns[3].MsgTx()})
+	if err != nil {
+		t.Fatalf("fill: unexpected error: %v", err)
+	}
+	if got, err := pb.block(); err != nil || *got.Hash() != block.BlockHash() {
+		t.Fatalf("block: unexpected result %v, %v", got, err)
+	}
+
+	// A pool transaction which has the short ID of another transaction
+	// of the block yields a block which does not match its merkle root.
+	collision := *msg
+	collision.ShortIDs = append([]uint64{}, msg.ShortIDs...)
+	k0, k1 := msg.ShortTxIDKeys()
+	collision.ShortIDs[0] = wire.ShortTxID(k0, k1, pool[0].Hash())
+	pb, err = newPartialBlock(&collision, pool)
+	if err != nil {
+		t.Fatalf("newPartialBlock: unexpected error: %v", err)
+	}
+	if _, err := pb.block(); err != errMerkleRootMismatch {
+		t.Fatalf("block: got %v, want %v", err, errMerkleRootMismatch)
+	}
+
+	// Duplicate short IDs within the compact block require the full
+	// block.
+	collision.ShortIDs[0] = collision.ShortIDs[1]
+	if _, err := newPartialBlock(&collision, pool); err != errShortIDCollision {
+		t.Fatalf("newPartialBlock: got %v, want %v", err,
+			errShortIDCollision)
+	}
+
+	// Compact blocks without transactions are invalid.
+	empty := wire.NewMsgCmpctBlock(&block.Header, 0)
+	if _, err := newPartialBlock(empty, pool); err == nil {
+		t.Fatalf("newPartialBlock: unexpected success without " +
+			"transactions")
+	}
+}
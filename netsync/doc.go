@@ -6,8 +6,8 @@
 Package netsync implements a concurrency safe block syncing protocol. The
 SyncManager communicates with connected peers to perform an initial block
 download, keep the chain and unconfirmed transaction pool in sync, and announce
-new blocks connected to the chain. Currently the sync manager selects a single
-sync peer that it downloads all blocks from until it is up to date with the
-longest chain the sync peer is aware of.
+new blocks connected to the chain. The sync manager selects a single sync peer
+that it downloads the headers of the longest chain it is aware of from, after
+which the blocks are downloaded from all suitable peers in parallel.
 */
 package netsync
@@ -5,7 +5,6 @@
 package netsync
 
 import (
-	"container/list"
 	"math/rand"
 	"net"
 	"sync"
@@ -23,10 +22,36 @@ import (
 )
 
 const (
-	// minInFlightBlocks is the minimum number of blocks that should be
-	// in the request queue for headers-first mode before requesting
-	// more.
-	minInFlightBlocks = 10
+	// blockDownloadWindow is the maximum number of blocks after the first
+	// one which has not been downloaded yet that are requested during the
+	// initial block download.  The blocks received ahead of their parents
+	// are held in memory until they can be processed, so this also limits
+	// the memory used for them.
+	blockDownloadWindow = 1024
+
+	// maxBlocksInFlightPerPeer is the maximum number of blocks which are
+	// requested from a single peer at once during the initial block
+	// download.
+	maxBlocksInFlightPerPeer = 16
+
+	// blockStallTimeout is the time after which the peer which was asked
+	// for the first block of an exhausted download window is disconnected
+	// when it has not delivered the block, so it can be requested from
+	// another peer.
+	blockStallTimeout = 10 * time.Second
+
+	// blockDownloadTimeout is the time after which a peer is disconnected
+	// when it has not delivered a block requested during the initial block
+	// download.
+	blockDownloadTimeout = time.Minute
+
+	// downloadSampleInterval is the interval at which the initial block
+	// download is checked for stalling peers.
+	downloadSampleInterval = 2 * time.Second
+
+	// maxRejectedBlocks is the maximum number of rejected block hashes to
+	// store in memory.
+	maxRejectedBlocks = 100
 
 	// maxRejectedTxns is the maximum number of rejected transactions
 	// hashes to store in memory.
@@ -47,6 +72,15 @@ const (
 	// stallSampleInterval the interval at which we will check to see if our
 	// sync has stalled.
 	stallSampleInterval = 30 * time.Second
+
+	// maxHistoricalBlocksInFlight is the maximum number of blocks below the
+	// base of a loaded utxo snapshot which are requested at once.
+	maxHistoricalBlocksInFlight = 16
+
+	// maxHighBandwidthPeers is the maximum number of peers which are asked
+	// to announce new blocks with compact blocks (BIP0152) before
+	// validating them, which is known as high bandwidth mode.
+	maxHighBandwidthPeers = 3
 )
 
 // zeroHash is the zero value hash (all zeros).  It is defined as a convenience.
@@ -65,6 +99,22 @@ type blockMsg struct {
 	reply chan struct{}
 }
 
+// cmpctBlockMsg packages a bitcoin cmpctblock message and the peer it came
+// from together so the block handler has access to that information.
+type cmpctBlockMsg struct {
+	cmpctBlock *wire.MsgCmpctBlock
+	peer       *peerpkg.Peer
+	reply      chan struct{}
+}
+
+// blockTxnMsg packages a bitcoin blocktxn message and the peer it came from
+// together so the block handler has access to that information.
+type blockTxnMsg struct {
+	blockTxn *wire.MsgBlockTxn
+	peer     *peerpkg.Peer
+	reply    chan struct{}
+}
+
 // invMsg packages a bitcoin inv message and the peer it came from together
 // so the block handler has access to that information.
 type invMsg struct {
@@ -131,11 +181,27 @@ type pauseMsg struct {
 	unpause <-chan struct{}
 }
 
-// headerNode is used as a node in a list of headers that are linked together
-// between checkpoints.
-type headerNode struct {
-	height int32
-	hash   *chainhash.Hash
+// downloadChain houses the methods of the chain which are used to download and
+// process the blocks of the best known header chain during the initial block
+// download.  It is implemented by blockchain.BlockChain.
+type downloadChain interface {
+	NextBlocksToDownload(maxHashes int) ([]chainhash.Hash, int32)
+	ProcessBlock(block *dashutil.Block, flags blockchain.BehaviorFlags) (bool, bool, error)
+	IsKnownInvalid(hash *chainhash.Hash) bool
+}
+
+// blockRequest describes a block which has been requested from a peer during
+// the initial block download.
+type blockRequest struct {
+	peer      *peerpkg.Peer
+	requested time.Time
+}
+
+// downloadedBlock houses a block received during the initial block download
+// ahead of its parent along with the peer it came from.
+type downloadedBlock struct {
+	block *dashutil.Block
+	peer  *peerpkg.Peer
 }
 
 // peerSyncState stores additional information that the SyncManager tracks
@@ -145,6 +211,7 @@ type peerSyncState struct {
 	requestQueue    []*wire.InvVect
 	requestedTxns   map[chainhash.Hash]struct{}
 	requestedBlocks map[chainhash.Hash]struct{}
+	partialBlock    *partialBlock
 }
 
 // SyncManager is used to communicate block related messages with peers. The
@@ -172,60 +239,41 @@ type SyncManager struct {
 	peerStates       map[*peerpkg.Peer]*peerSyncState
 	lastProgressTime time.Time
 
-	// The following fields are used for headers-first mode.
-	headersFirstMode bool
-	headerList       *list.List
-	startHeader      *list.Element
-	nextCheckpoint   *chaincfg.Checkpoint
+	// The following field is used to download the headers up to the tip
+	// of the sync peer before any blocks.
+	headerSyncMode bool
+
+	// The following fields are used to download the blocks of the best
+	// known header chain in parallel from all sync candidates.  The blocks
+	// are requested within a window after the first one which has not
+	// been downloaded yet and those received ahead of their parents are
+	// held until they can be processed.  Blocks up to fastAddHeight have
+	// headers verified against the checkpoints and are added with less
+	// validation.  The blocks are processed by ibdChain, which is the
+	// chain of the manager.
+	ibdChain       downloadChain
+	blocksInFlight map[chainhash.Hash]*blockRequest
+	receivedBlocks map[chainhash.Hash]*downloadedBlock
+	rejectedBlocks map[chainhash.Hash]struct{}
+	fastAddHeight  int32
+
+	// The following fields are used to download the blocks below the base
+	// of a loaded utxo snapshot in the background once the chain is
+	// current.  The blocks are requested from a single peer at a time and
+	// those received out of order are held until they can be processed.
+	historyPeer      *peerpkg.Peer
+	requestedHistory map[chainhash.Hash]struct{}
+	receivedHistory  map[chainhash.Hash]*dashutil.Block
+	lastHistoryTime  time.Time
+
+	// highBandwidthPeers houses the peers which were asked to announce new
+	// blocks with compact blocks, oldest first.
+	highBandwidthPeers []*peerpkg.Peer
 
 	// An optional fee estimator.
 	feeEstimator *mempool.FeeEstimator
 }
 
-// resetHeaderState sets the headers-first mode state to values appropriate for
-// syncing from a new peer.
-func (sm *SyncManager) resetHeaderState(newestHash *chainhash.Hash, newestHeight int32) {
-	sm.headersFirstMode = false
-	sm.headerList.Init()
-	sm.startHeader = nil
-
-	// When there is a next checkpoint, add an entry for the latest known
-	// block into the header pool.  This allows the next downloaded header
-	// to prove it links to the chain properly.
-	if sm.nextCheckpoint != nil {
-		node := headerNode{height: newestHeight, hash: newestHash}
-		sm.headerList.PushBack(&node)
-	}
-}
-
-// findNextHeaderCheckpoint returns the next checkpoint after the passed height.
-// It returns nil when there is not one either because the height is already
-// later than the final checkpoint or some other reason such as disabled
-// checkpoints.
-func (sm *SyncManager) findNextHeaderCheckpoint(height int32) *chaincfg.Checkpoint {
-	checkpoints := sm.chain.Checkpoints()
-	if len(checkpoints) == 0 {
-		return nil
-	}
-
-	// There is no next checkpoint if the height is already after the final
-	// checkpoint.
-	finalCheckpoint := &checkpoints[len(checkpoints)-1]
-	if height >= finalCheckpoint.Height {
-		return nil
-	}
-
-	// Find the next checkpoint.
-	nextCheckpoint := finalCheckpoint
-	for i := len(checkpoints) - 2; i >= 0; i-- {
-		if height >= checkpoints[i].Height {
-			break
-		}
-		nextCheckpoint = &checkpoints[i]
-	}
-	return nextCheckpoint
-}
-
 // startSync will choose the best peer among the available candidate peers to
 // download/sync the blockchain from.  When syncing is already running, it
 // simply returns.  It also examines the candidates for any which are no longer
@@ -287,7 +335,7 @@ func (sm *SyncManager) startSync() {
 	// falling back to a random peer of the same height if none are greater.
 	//
 	// TODO(conner): Use a better algorithm to ranking peers based on
-	// observed metrics and/or sync in parallel.
+	// observed metrics.
 	var bestPeer *peerpkg.Peer
 	switch {
 	case len(higherPeers) > 0:
@@ -299,61 +347,41 @@ func (sm *SyncManager) startSync() {
 
 	// Start syncing from the best peer if one was selected.
 	if bestPeer != nil {
-		// Clear the requestedBlocks if the sync peer changes, otherwise
-		// we may ignore blocks we need that the last sync peer failed
-		// to send.
-		sm.requestedBlocks = make(map[chainhash.Hash]struct{})
-
-		locator, err := sm.chain.LatestBlockLocator()
-		if err != nil {
-			log.Errorf("Failed to get block locator for the "+
-				"latest block: %v", err)
-			return
-		}
-
 		log.Infof("Syncing to block height %d from peer %v",
 			bestPeer.LastBlock(), bestPeer.Addr())
 
-		// When the current height is less than a known checkpoint we
-		// can use block headers to learn about which blocks comprise
-		// the chain up to the checkpoint and perform less validation
-		// for them.  This is possible since each header contains the
-		// hash of the previous header and a merkle root.  Therefore if
-		// we validate all of the received headers link together
-		// properly and the checkpoint hashes match, we can be sure the
-		// hashes for the blocks in between are accurate.  Further, once
-		// the full blocks are downloaded, the merkle root is computed
-		// and compared against the value in the header which proves the
-		// full block hasn't been tampered with.
-		//
-		// Once we have passed the final checkpoint, or checkpoints are
-		// disabled, use standard inv messages learn about the blocks
-		// and fully validate them.  Finally, regression test mode does
-		// not support the headers-first approach so do normal block
-		// downloads when in regression test mode.
-		if sm.nextCheckpoint != nil &&
-			best.Height < sm.nextCheckpoint.Height &&
-			sm.chainParams != &chaincfg.RegressionNetParams {
-
-			bestPeer.PushGetHeadersMsg(locator, sm.nextCheckpoint.Hash)
-			sm.headersFirstMode = true
-			log.Infof("Downloading headers for blocks %d to "+
-				"%d from peer %s", best.Height+1,
-				sm.nextCheckpoint.Height, bestPeer.Addr())
-		} else {
-			bestPeer.PushGetBlocksMsg(locator, &zeroHash)
-		}
 		sm.syncPeer = bestPeer
-
-		// Reset the last progress time now that we have a non-nil
-		// syncPeer to avoid instantly detecting it as stalled in the
-		// event the progress time hasn't been updated recently.
 		sm.lastProgressTime = time.Now()
+
+		// Download the headers up to the tip of the sync peer before
+		// any blocks.  This allows the blocks to be requested from all
+		// sync candidates in parallel, and the chain to tell whether
+		// the blocks are ancestors of the assumed valid block and skip
+		// their scripts.
+		sm.requestHeaders(bestPeer)
 	} else {
 		log.Warnf("No sync peer candidates available")
 	}
 }
 
+// requestHeaders requests the headers after the tip of the best known header
+// chain from the passed peer, which must be the sync peer.  The blocks are not
+// downloaded until the peer has sent all of its headers.
+func (sm *SyncManager) requestHeaders(peer *peerpkg.Peer) {
+	locator := sm.chain.LatestHeaderLocator()
+	err := peer.PushGetHeadersMsg(locator, &zeroHash)
+	if err != nil {
+		log.Warnf("Failed to send getheaders message to peer %s: %v",
+			peer.Addr(), err)
+		return
+	}
+
+	sm.headerSyncMode = true
+	_, height := sm.chain.BestHeader()
+	log.Infof("Downloading headers after height %d from peer %s", height,
+		peer.Addr())
+}
+
 // isSyncCandidate returns whether or not the peer is a candidate to consider
 // syncing from.
 func (sm *SyncManager) isSyncCandidate(peer *peerpkg.Peer) bool {
@@ -375,13 +403,20 @@ func (sm *SyncManager) isSyncCandidate(peer *peerpkg.Peer) bool {
 		// The peer is not a candidate for sync if it's not a full
 		// node. Additionally, if the segwit soft-fork package has
 		// activated, then the peer must also be upgraded.
+		//
+		// Peers which only serve the latest blocks, such as pruned
+		// nodes, are also candidates once the chain is current since
+		// only recent blocks are needed from them then (BIP0159).
 		segwitActive, err := sm.chain.IsDeploymentActive(chaincfg.DeploymentSegwit)
 		if err != nil {
 			log.Errorf("Unable to query for segwit "+
 				"soft-fork state: %v", err)
 		}
 		nodeServices := peer.Services()
-		if nodeServices&wire.SFNodeNetwork != wire.SFNodeNetwork ||
+		fullNode := nodeServices&wire.SFNodeNetwork == wire.SFNodeNetwork
+		limitedNode := nodeServices&wire.SFNodeNetworkLimited ==
+			wire.SFNodeNetworkLimited
+		if !(fullNode || (limitedNode && sm.current())) ||
 			(segwitActive && !peer.IsWitnessEnabled()) {
 			return false
 		}
@@ -410,10 +445,12 @@ func (sm *SyncManager) handleNewPeerMsg(peer *peerpkg.Peer) {
 		requestedBlocks: make(map[chainhash.Hash]struct{}),
 	}
 
-	// Start syncing by choosing the best candidate if needed.
+	// Start syncing by choosing the best candidate if needed, and let the
+	// peer help downloading the blocks otherwise.
 	if isSyncCandidate && sm.syncPeer == nil {
 		sm.startSync()
 	}
+	sm.fetchBlocks()
 }
 
 // handleStallSample will switch to a new sync peer if the current one has
@@ -425,6 +462,18 @@ func (sm *SyncManager) handleStallSample() {
 		return
 	}
 
+	// Request the blocks below a loaded utxo snapshot from another peer
+	// when the current one has stalled, and start downloading them once
+	// the chain is current.
+	if len(sm.requestedHistory) > 0 &&
+		time.Since(sm.lastHistoryTime) > maxStallDuration {
+
+		log.Infof("Peer %s stalled while downloading blocks below the "+
+			"utxo snapshot", sm.historyPeer)
+		sm.resetHistoryRequests()
+	}
+	sm.requestHistoricalBlocks()
+
 	// If we don't have an active sync peer, exit early.
 	if sm.syncPeer == nil {
 		return
@@ -441,7 +490,7 @@ func (sm *SyncManager) handleStallSample() {
 		return
 	}
 
-	sm.clearRequestedState(state)
+	sm.clearRequestedState(sm.syncPeer, state)
 
 	disconnectSyncPeer := sm.shouldDCStalledSyncPeer()
 	sm.updateSyncPeer(disconnectSyncPeer)
@@ -485,19 +534,33 @@ func (sm *SyncManager) handleDonePeerMsg(peer *peerpkg.Peer) {
 
 	log.Infof("Lost peer %s", peer)
 
-	sm.clearRequestedState(state)
+	sm.clearRequestedState(peer, state)
+	if peer == sm.historyPeer {
+		sm.resetHistoryRequests()
+	}
+	for i, p := range sm.highBandwidthPeers {
+		if p == peer {
+			sm.highBandwidthPeers = append(sm.highBandwidthPeers[:i],
+				sm.highBandwidthPeers[i+1:]...)
+			break
+		}
+	}
 
 	if peer == sm.syncPeer {
 		// Update the sync peer. The server has already disconnected the
 		// peer before signaling to the sync manager.
 		sm.updateSyncPeer(false)
 	}
+
+	// Request the blocks which were in flight from the peer from the
+	// remaining peers.
+	sm.fetchBlocks()
 }
 
 // clearRequestedState wipes all expected transactions and blocks from the sync
 // manager's requested maps that were requested under a peer's sync state, This
 // allows them to be rerequested by a subsequent sync peer.
-func (sm *SyncManager) clearRequestedState(state *peerSyncState) {
+func (sm *SyncManager) clearRequestedState(peer *peerpkg.Peer, state *peerSyncState) {
 	// Remove requested transactions from the global map so that they will
 	// be fetched from elsewhere next time we get an inv.
 	for txHash := range state.requestedTxns {
@@ -505,11 +568,14 @@ func (sm *SyncManager) clearRequestedState(state *peerSyncState) {
 	}
 
 	// Remove requested blocks from the global map so that they will be
-	// fetched from elsewhere next time we get an inv.
-	// TODO: we could possibly here check which peers have these blocks
-	// and request them now to speed things up a little.
+	// fetched from elsewhere next time we get an inv or, during the
+	// initial block download, by the next call to fetchBlocks.
 	for blockHash := range state.requestedBlocks {
 		delete(sm.requestedBlocks, blockHash)
+		request, exists := sm.blocksInFlight[blockHash]
+		if exists && request.peer == peer {
+			delete(sm.blocksInFlight, blockHash)
+		}
 	}
 }
 
@@ -527,10 +593,7 @@ func (sm *SyncManager) updateSyncPeer(dcSyncPeer bool) {
 	}
 
 	// Reset any header state before we choose our next active sync peer.
-	if sm.headersFirstMode {
-		best := sm.chain.BestSnapshot()
-		sm.resetHeaderState(&best.Hash, best.Height)
-	}
+	sm.headerSyncMode = false
 
 	sm.syncPeer = nil
 	sm.startSync()
@@ -634,8 +697,26 @@ func (sm *SyncManager) handleBlockMsg(bmsg *blockMsg) {
 		return
 	}
 
-	// If we didn't ask for this block then the peer is misbehaving.
+	// Blocks below the base of a loaded utxo snapshot are handled
+	// separately.
 	blockHash := bmsg.block.Hash()
+	if _, exists := sm.requestedHistory[*blockHash]; exists &&
+		peer == sm.historyPeer {
+
+		sm.handleHistoricalBlock(peer, bmsg.block)
+		return
+	}
+
+	// Blocks requested during the initial block download are processed in
+	// the order of the best known header chain.
+	if request, exists := sm.blocksInFlight[*blockHash]; exists &&
+		request.peer == peer {
+
+		sm.handleDownloadedBlock(peer, state, bmsg.block)
+		return
+	}
+
+	// If we didn't ask for this block then the peer is misbehaving.
 	if _, exists = state.requestedBlocks[*blockHash]; !exists {
 		// The regression test intentionally sends some blocks twice
 		// to test duplicate block insertion fails.  Don't disconnect
@@ -650,30 +731,6 @@ func (sm *SyncManager) handleBlockMsg(bmsg *blockMsg) {
 		}
 	}
 
-	// When in headers-first mode, if the block matches the hash of the
-	// first header in the list of headers that are being fetched, it's
-	// eligible for less validation since the headers have already been
-	// verified to link together and are valid up to the next checkpoint.
-	// Also, remove the list entry for all blocks except the checkpoint
-	// since it is needed to verify the next round of headers links
-	// properly.
-	isCheckpointBlock := false
-	behaviorFlags := blockchain.BFNone
-	if sm.headersFirstMode {
-		firstNodeEl := sm.headerList.Front()
-		if firstNodeEl != nil {
-			firstNode := firstNodeEl.Value.(*headerNode)
-			if blockHash.IsEqual(firstNode.hash) {
-				behaviorFlags |= blockchain.BFFastAdd
-				if firstNode.hash.IsEqual(sm.nextCheckpoint.Hash) {
-					isCheckpointBlock = true
-				} else {
-					sm.headerList.Remove(firstNodeEl)
-				}
-			}
-		}
-	}
-
 	// Remove block from request maps. Either chain will know about it and
 	// so we shouldn't have any more instances of trying to fetch it, or we
 	// will fail the insert and thus we'll retry next time we get an inv.
@@ -682,7 +739,8 @@ func (sm *SyncManager) handleBlockMsg(bmsg *blockMsg) {
 
 	// Process the block to include validation, best chain selection, orphan
 	// handling, etc.
-	_, isOrphan, err := sm.chain.ProcessBlock(bmsg.block, behaviorFlags)
+	isMainChain, isOrphan, err := sm.chain.ProcessBlock(bmsg.block,
+		blockchain.BFNone)
 	if err != nil {
 		// When the error is a rule error, it means the block was simply
 		// rejected as opposed to something actually going wrong, so log
@@ -766,6 +824,12 @@ func (sm *SyncManager) handleBlockMsg(bmsg *blockMsg) {
 
 		// Clear the rejected transactions.
 		sm.rejectedTxns = make(map[chainhash.Hash]struct{})
+
+		// Peers which deliver new blocks first are the best ones to
+		// announce the next ones with compact blocks.
+		if isMainChain && sm.current() {
+			sm.addHighBandwidthPeer(peer)
+		}
 	}
 
 	// Update the block height for this peer. But only send a message to
@@ -779,115 +843,308 @@ func (sm *SyncManager) handleBlockMsg(bmsg *blockMsg) {
 				peer)
 		}
 	}
+}
 
-	// Nothing more to do if we aren't in headers-first mode.
-	if !sm.headersFirstMode {
+// fetchBlocks requests the blocks of the best known header chain which have not
+// been downloaded yet from the sync candidates with free download slots.  Only
+// the blocks within the download window after the first one which has not been
+// downloaded yet are requested, so blocks arriving ahead of their parents can
+// not pile up in memory, and peers are only asked for blocks up to the height
+// they have advertised.
+func (sm *SyncManager) fetchBlocks() {
+	// The blocks are downloaded once all headers of the sync peer are
+	// known.
+	if sm.syncPeer == nil || sm.headerSyncMode {
 		return
 	}
 
-	// This is headers-first mode, so if the block is not a checkpoint
-	// request more blocks using the header list when the request queue is
-	// getting short.
-	if !isCheckpointBlock {
-		if sm.startHeader != nil &&
-			len(state.requestedBlocks) < minInFlightBlocks {
-			sm.fetchHeaderBlocks()
-		}
+	hashes, startHeight := sm.ibdChain.NextBlocksToDownload(blockDownloadWindow)
+	needed := sm.neededBlocks(hashes)
+	if len(needed) == 0 {
 		return
 	}
 
-	// This is headers-first mode and the block is a checkpoint.  When
-	// there is a next checkpoint, get the next round of headers by asking
-	// for headers starting from the block after this one up to the next
-	// checkpoint.
-	prevHeight := sm.nextCheckpoint.Height
-	prevHash := sm.nextCheckpoint.Hash
-	sm.nextCheckpoint = sm.findNextHeaderCheckpoint(prevHeight)
-	if sm.nextCheckpoint != nil {
-		locator := blockchain.BlockLocator([]*chainhash.Hash{prevHash})
-		err := peer.PushGetHeadersMsg(locator, sm.nextCheckpoint.Hash)
-		if err != nil {
-			log.Warnf("Failed to send getheaders message to "+
-				"peer %s: %v", peer.Addr(), err)
-			return
+	// Hand out the blocks to the peers with free download slots in order
+	// of their heights.
+	now := time.Now()
+	next := 0
+	for peer, state := range sm.peerStates {
+		if next == len(needed) {
+			break
+		}
+		if !state.syncCandidate {
+			continue
+		}
+
+		free := maxBlocksInFlightPerPeer - len(state.requestedBlocks)
+		if free <= 0 {
+			continue
+		}
+		gdmsg := wire.NewMsgGetDataSizeHint(uint(free))
+		for next < len(needed) && len(gdmsg.InvList) < free {
+			i := needed[next]
+			if startHeight+int32(i) > peer.LastBlock() {
+				break
+			}
+
+			iv := wire.NewInvVect(wire.InvTypeBlock, &hashes[i])
+			if peer.IsWitnessEnabled() {
+				iv.Type = wire.InvTypeWitnessBlock
+			}
+			sm.blocksInFlight[hashes[i]] = &blockRequest{
+				peer:      peer,
+				requested: now,
+			}
+			sm.requestedBlocks[hashes[i]] = struct{}{}
+			state.requestedBlocks[hashes[i]] = struct{}{}
+			gdmsg.AddInvVect(iv)
+			next++
+		}
+		if len(gdmsg.InvList) > 0 {
+			peer.QueueMessage(gdmsg, nil)
 		}
-		log.Infof("Downloading headers for blocks %d to %d from "+
-			"peer %s", prevHeight+1, sm.nextCheckpoint.Height,
-			sm.syncPeer.Addr())
-		return
 	}
+}
 
-	// This is headers-first mode, the block is a checkpoint, and there are
-	// no more checkpoints, so switch to normal mode by requesting blocks
-	// from the block after this one up to the end of the chain (zero hash).
-	sm.headersFirstMode = false
-	sm.headerList.Init()
-	log.Infof("Reached the final checkpoint -- switching to normal mode")
-	locator := blockchain.BlockLocator([]*chainhash.Hash{blockHash})
-	err = peer.PushGetBlocksMsg(locator, &zeroHash)
-	if err != nil {
-		log.Warnf("Failed to send getblocks message to peer %s: %v",
-			peer.Addr(), err)
-		return
+// neededBlocks returns the indexes of the passed blocks of the download window
+// which have been neither requested nor received yet.  Blocks after a rejected
+// block are not needed since they can not be connected.
+func (sm *SyncManager) neededBlocks(hashes []chainhash.Hash) []int {
+	var needed []int
+	for i := range hashes {
+		hash := &hashes[i]
+		if _, exists := sm.rejectedBlocks[*hash]; exists {
+			break
+		}
+		if _, exists := sm.blocksInFlight[*hash]; exists {
+			continue
+		}
+		if _, exists := sm.requestedBlocks[*hash]; exists {
+			continue
+		}
+		if _, exists := sm.receivedBlocks[*hash]; exists {
+			continue
+		}
+		needed = append(needed, i)
 	}
+	return needed
 }
 
-// fetchHeaderBlocks creates and sends a request to the syncPeer for the next
-// list of blocks to be downloaded based on the current list of headers.
-func (sm *SyncManager) fetchHeaderBlocks() {
-	// Nothing to do if there is no start header.
-	if sm.startHeader == nil {
-		log.Warnf("fetchHeaderBlocks called with no start header")
+// handleDownloadedBlock handles a block requested during the initial block
+// download.  The blocks are processed in the order of the best known header
+// chain, so blocks received ahead of their parents are held until they can be
+// processed, after which more blocks are requested.
+func (sm *SyncManager) handleDownloadedBlock(peer *peerpkg.Peer, state *peerSyncState, block *dashutil.Block) {
+	blockHash := block.Hash()
+	delete(sm.blocksInFlight, *blockHash)
+	delete(sm.requestedBlocks, *blockHash)
+	delete(state.requestedBlocks, *blockHash)
+	sm.receivedBlocks[*blockHash] = &downloadedBlock{block: block, peer: peer}
+
+	for {
+		next, height := sm.ibdChain.NextBlocksToDownload(1)
+		if len(next) == 0 {
+			break
+		}
+		downloaded, exists := sm.receivedBlocks[next[0]]
+		if !exists {
+			break
+		}
+		delete(sm.receivedBlocks, next[0])
+
+		// Blocks up to the final checkpoint are eligible for less
+		// validation since their headers have been verified against
+		// the checkpoints.
+		behaviorFlags := blockchain.BFNone
+		if height <= sm.fastAddHeight {
+			behaviorFlags |= blockchain.BFFastAdd
+		}
+
+		_, _, err := sm.ibdChain.ProcessBlock(downloaded.block, behaviorFlags)
+		if err != nil {
+			// When the error is a rule error, it means the block
+			// was simply rejected as opposed to something actually
+			// going wrong, so log it as such and disconnect the
+			// peer which sent it.  Otherwise, something really did
+			// go wrong, so log it as an actual error.
+			//
+			// Only blocks the chain has marked invalid are never
+			// requested again.  Other rejected blocks, such as
+			// those with a body which does not match the merkle
+			// root of the header, are requested again from another
+			// peer since the peer may have sent a corrupted copy of
+			// a valid block.
+			if _, ok := err.(blockchain.RuleError); ok {
+				log.Infof("Rejected block %v from %s: %v",
+					next[0], downloaded.peer, err)
+				if sm.ibdChain.IsKnownInvalid(&next[0]) {
+					sm.rejectedBlocks[next[0]] = struct{}{}
+					sm.limitMap(sm.rejectedBlocks,
+						maxRejectedBlocks)
+				}
+				if state, exists := sm.peerStates[downloaded.peer]; exists {
+					state.syncCandidate = false
+					sm.clearRequestedState(downloaded.peer, state)
+				}
+				downloaded.peer.Disconnect()
+			} else {
+				log.Errorf("Failed to process block %v: %v",
+					next[0], err)
+			}
+			if dbErr, ok := err.(database.Error); ok && dbErr.ErrorCode ==
+				database.ErrCorruption {
+				panic(dbErr)
+			}
+			break
+		}
+
+		sm.lastProgressTime = time.Now()
+		sm.progressLogger.LogBlockHeight(downloaded.block)
+
+		// Clear the rejected transactions.
+		sm.rejectedTxns = make(map[chainhash.Hash]struct{})
+	}
+
+	sm.fetchBlocks()
+}
+
+// handleDownloadSample disconnects the peers which stall the initial block
+// download, so the blocks they were asked for are requested from other peers.
+// A peer stalls the download when it does not deliver a requested block in
+// time.  The peer which was asked for the first block of the download window
+// is given less time once all other blocks of the window have been requested,
+// since all other peers wait for it then.
+func (sm *SyncManager) handleDownloadSample() {
+	if atomic.LoadInt32(&sm.shutdown) != 0 || len(sm.blocksInFlight) == 0 {
 		return
 	}
 
-	// Build up a getdata request for the list of blocks the headers
-	// describe.  The size hint will be limited to wire.MaxInvPerMsg by
-	// the function, so no need to double check it here.
-	gdmsg := wire.NewMsgGetDataSizeHint(uint(sm.headerList.Len()))
-	numRequested := 0
-	for e := sm.startHeader; e != nil; e = e.Next() {
-		node, ok := e.Value.(*headerNode)
-		if !ok {
-			log.Warn("Header list node type is not a headerNode")
-			continue
+	stalling := make(map[*peerpkg.Peer]struct{})
+	for _, request := range sm.blocksInFlight {
+		if time.Since(request.requested) > blockDownloadTimeout {
+			stalling[request.peer] = struct{}{}
 		}
+	}
 
-		iv := wire.NewInvVect(wire.InvTypeBlock, node.hash)
-		haveInv, err := sm.haveInventory(iv)
-		if err != nil {
-			log.Warnf("Unexpected failure when checking for "+
-				"existing inventory during header block "+
-				"fetch: %v", err)
+	hashes, _ := sm.ibdChain.NextBlocksToDownload(blockDownloadWindow)
+	if len(hashes) == blockDownloadWindow && len(sm.neededBlocks(hashes)) == 0 {
+		request, exists := sm.blocksInFlight[hashes[0]]
+		if exists && time.Since(request.requested) > blockStallTimeout {
+			stalling[request.peer] = struct{}{}
 		}
-		if !haveInv {
-			syncPeerState := sm.peerStates[sm.syncPeer]
+	}
 
-			sm.requestedBlocks[*node.hash] = struct{}{}
-			syncPeerState.requestedBlocks[*node.hash] = struct{}{}
+	for peer := range stalling {
+		log.Infof("Peer %s stalled the block download -- "+
+			"disconnecting", peer)
+		if state, exists := sm.peerStates[peer]; exists {
+			state.syncCandidate = false
+			sm.clearRequestedState(peer, state)
+		}
+		peer.Disconnect()
+	}
+	if len(stalling) > 0 {
+		sm.fetchBlocks()
+	}
+}
 
-			// If we're fetching from a witness enabled peer
-			// post-fork, then ensure that we receive all the
-			// witness data in the blocks.
-			if sm.syncPeer.IsWitnessEnabled() {
-				iv.Type = wire.InvTypeWitnessBlock
+// requestHistoricalBlocks requests the next blocks below the base of a loaded
+// utxo snapshot from a full node peer once the chain is current and no such
+// blocks are in flight.
+func (sm *SyncManager) requestHistoricalBlocks() {
+	if len(sm.requestedHistory) > 0 || !sm.current() {
+		return
+	}
+	hashes := sm.chain.NextHistoricalBlocks(maxHistoricalBlocksInFlight)
+	if len(hashes) == 0 {
+		return
+	}
+
+	// Keep downloading from the current peer and choose another one
+	// which serves all blocks otherwise.
+	peer := sm.historyPeer
+	if peer == nil {
+		for candidate := range sm.peerStates {
+			services := candidate.Services()
+			if services&wire.SFNodeNetwork == wire.SFNodeNetwork {
+				peer = candidate
+				break
 			}
+		}
+		if peer == nil {
+			return
+		}
+		log.Infof("Downloading blocks below the utxo snapshot from "+
+			"peer %s", peer)
+	}
 
-			gdmsg.AddInvVect(iv)
-			numRequested++
+	gdmsg := wire.NewMsgGetDataSizeHint(uint(len(hashes)))
+	for i := range hashes {
+		if _, exists := sm.receivedHistory[hashes[i]]; exists {
+			continue
 		}
-		sm.startHeader = e.Next()
-		if numRequested >= wire.MaxInvPerMsg {
+		sm.requestedHistory[hashes[i]] = struct{}{}
+		gdmsg.AddInvVect(wire.NewInvVect(wire.InvTypeBlock, &hashes[i]))
+	}
+	if len(gdmsg.InvList) == 0 {
+		return
+	}
+	sm.historyPeer = peer
+	sm.lastHistoryTime = time.Now()
+	peer.QueueMessage(gdmsg, nil)
+}
+
+// handleHistoricalBlock handles a requested block below the base of a loaded
+// utxo snapshot.  The blocks are processed in order, so blocks received ahead
+// of those before them are held until they can be processed.
+func (sm *SyncManager) handleHistoricalBlock(peer *peerpkg.Peer, block *dashutil.Block) {
+	delete(sm.requestedHistory, *block.Hash())
+	sm.receivedHistory[*block.Hash()] = block
+	sm.lastHistoryTime = time.Now()
+
+	for {
+		next := sm.chain.NextHistoricalBlocks(1)
+		if len(next) == 0 {
 			break
 		}
+		nextBlock, exists := sm.receivedHistory[next[0]]
+		if !exists {
+			break
+		}
+		delete(sm.receivedHistory, next[0])
+
+		err := sm.chain.ProcessHistoricalBlock(nextBlock)
+		if err != nil {
+			if _, ok := err.(blockchain.RuleError); ok {
+				log.Infof("Rejected block %v below the utxo "+
+					"snapshot from %s: %v", nextBlock.Hash(),
+					peer, err)
+				peer.Disconnect()
+			} else {
+				log.Errorf("Failed to process block %v below "+
+					"the utxo snapshot: %v", nextBlock.Hash(),
+					err)
+			}
+			sm.resetHistoryRequests()
+			return
+		}
 	}
-	if len(gdmsg.InvList) > 0 {
-		sm.syncPeer.QueueMessage(gdmsg, nil)
-	}
+
+	sm.requestHistoricalBlocks()
+}
+
+// resetHistoryRequests forgets about the blocks below the base of a loaded utxo
+// snapshot which have been requested or received but not processed yet, so
+// they are requested again, possibly from another peer.
+func (sm *SyncManager) resetHistoryRequests() {
+	sm.historyPeer = nil
+	sm.requestedHistory = make(map[chainhash.Hash]struct{})
+	sm.receivedHistory = make(map[chainhash.Hash]*dashutil.Block)
 }
 
 // handleHeadersMsg handles block header messages from all peers.  Headers are
-// requested when performing a headers-first sync.
+// only requested from the sync peer, which sends the headers up to its tip
+// before any blocks are downloaded.
 func (sm *SyncManager) handleHeadersMsg(hmsg *headersMsg) {
 	peer := hmsg.peer
 	_, exists := sm.peerStates[peer]
@@ -896,102 +1153,65 @@ func (sm *SyncManager) handleHeadersMsg(hmsg *headersMsg) {
 		return
 	}
 
-	// The remote peer is misbehaving if we didn't request headers.
+	// Headers downloaded ahead of the blocks are passed to the chain.
 	msg := hmsg.headers
-	numHeaders := len(msg.Headers)
-	if !sm.headersFirstMode {
-		log.Warnf("Got %d unrequested headers from %s -- "+
-			"disconnecting", numHeaders, peer.Addr())
-		peer.Disconnect()
+	if sm.headerSyncMode && peer == sm.syncPeer {
+		sm.handleSyncHeaders(peer, msg)
 		return
 	}
 
-	// Nothing to do for an empty headers message.
-	if numHeaders == 0 {
-		return
-	}
+	// The remote peer is misbehaving if we didn't request headers.
+	log.Warnf("Got %d unrequested headers from %s -- disconnecting",
+		len(msg.Headers), peer.Addr())
+	peer.Disconnect()
+}
 
-	// Process all of the received headers ensuring each one connects to the
-	// previous and that checkpoints match.
-	receivedCheckpoint := false
+// handleSyncHeaders handles the headers the sync peer sends while the headers
+// up to its tip are downloaded ahead of the blocks.  The headers are added to
+// the chain and more headers are requested until the peer sends less than a
+// full message, after which the blocks are requested from all sync candidates.
+func (sm *SyncManager) handleSyncHeaders(peer *peerpkg.Peer, msg *wire.MsgHeaders) {
 	var finalHash *chainhash.Hash
 	for _, blockHeader := range msg.Headers {
-		blockHash := blockHeader.BlockHash()
-		finalHash = &blockHash
-
-		// Ensure there is a previous header to compare against.
-		prevNodeEl := sm.headerList.Back()
-		if prevNodeEl == nil {
-			log.Warnf("Header list does not contain a previous" +
-				"element as expected -- disconnecting peer")
-			peer.Disconnect()
-			return
-		}
-
-		// Ensure the header properly connects to the previous one and
-		// add it to the list of headers.
-		node := headerNode{hash: &blockHash}
-		prevNode := prevNodeEl.Value.(*headerNode)
-		if prevNode.hash.IsEqual(&blockHeader.PrevBlock) {
-			node.height = prevNode.height + 1
-			e := sm.headerList.PushBack(&node)
-			if sm.startHeader == nil {
-				sm.startHeader = e
-			}
-		} else {
-			log.Warnf("Received block header that does not "+
-				"properly connect to the chain from peer %s "+
-				"-- disconnecting", peer.Addr())
+		err := sm.chain.ProcessBlockHeader(blockHeader)
+		if err != nil {
+			log.Warnf("Received invalid block header from peer %s "+
+				"-- disconnecting: %v", peer.Addr(), err)
 			peer.Disconnect()
 			return
 		}
-
-		// Verify the header at the next checkpoint height matches.
-		if node.height == sm.nextCheckpoint.Height {
-			if node.hash.IsEqual(sm.nextCheckpoint.Hash) {
-				receivedCheckpoint = true
-				log.Infof("Verified downloaded block "+
-					"header against checkpoint at height "+
-					"%d/hash %s", node.height, node.hash)
-			} else {
-				log.Warnf("Block header at height %d/hash "+
-					"%s from peer %s does NOT match "+
-					"expected checkpoint hash of %s -- "+
-					"disconnecting", node.height,
-					node.hash, peer.Addr(),
-					sm.nextCheckpoint.Hash)
-				peer.Disconnect()
-				return
-			}
-			break
-		}
+		blockHash := blockHeader.BlockHash()
+		finalHash = &blockHash
 	}
+	sm.lastProgressTime = time.Now()
 
-	// When this header is a checkpoint, switch to fetching the blocks for
-	// all of the headers since the last checkpoint.
-	if receivedCheckpoint {
-		// Since the first entry of the list is always the final block
-		// that is already in the database and is only used to ensure
-		// the next header links properly, it must be removed before
-		// fetching the blocks.
-		sm.headerList.Remove(sm.headerList.Front())
-		log.Infof("Received %v block headers: Fetching blocks",
-			sm.headerList.Len())
-		sm.progressLogger.SetLastLogTime(time.Now())
-		sm.fetchHeaderBlocks()
+	// Request the next batch of headers when the message was full.
+	if len(msg.Headers) == wire.MaxBlockHeadersPerMsg {
+		locator := blockchain.BlockLocator([]*chainhash.Hash{finalHash})
+		err := peer.PushGetHeadersMsg(locator, &zeroHash)
+		if err != nil {
+			log.Warnf("Failed to send getheaders message to "+
+				"peer %s: %v", peer.Addr(), err)
+		}
 		return
 	}
 
-	// This header is not a checkpoint, so request the next batch of
-	// headers starting from the latest known header and ending with the
-	// next checkpoint.
-	locator := blockchain.BlockLocator([]*chainhash.Hash{finalHash})
-	err := peer.PushGetHeadersMsg(locator, sm.nextCheckpoint.Hash)
-	if err != nil {
-		log.Warnf("Failed to send getheaders message to "+
-			"peer %s: %v", peer.Addr(), err)
-		return
+	// All headers of the peer are known, so switch to downloading the
+	// blocks.
+	sm.headerSyncMode = false
+	if finalHash != nil {
+		log.Infof("Downloaded headers up to block %v from peer %s",
+			finalHash, peer.Addr())
+
+		// Update the height of the peer, so it is asked for the blocks
+		// up to its tip.
+		tipHash, tipHeight := sm.chain.BestHeader()
+		if tipHash.IsEqual(finalHash) {
+			peer.UpdateLastBlockHeight(tipHeight)
+		}
 	}
+	sm.progressLogger.SetLastLogTime(time.Now())
+	sm.fetchBlocks()
 }
 
 // haveInventory returns whether or not the inventory represented by the passed
@@ -1109,8 +1329,13 @@ func (sm *SyncManager) handleInvMsg(imsg *invMsg) {
 		// for the peer.
 		peer.AddKnownInventory(iv)
 
-		// Ignore inventory when we're in headers-first mode.
-		if sm.headersFirstMode {
+		// Blocks are downloaded from the best known header chain until
+		// the chain is current, so only request the headers of blocks
+		// the sync peer announces meanwhile.
+		if iv.Type == wire.InvTypeBlock && !sm.current() {
+			if !sm.headerSyncMode && !sm.chain.HaveHeader(&iv.Hash) {
+				sm.requestHeaders(peer)
+			}
 			continue
 		}
 
@@ -1206,7 +1431,13 @@ func (sm *SyncManager) handleInvMsg(imsg *invMsg) {
 				sm.limitMap(sm.requestedBlocks, maxRequestedBlocks)
 				state.requestedBlocks[iv.Hash] = struct{}{}
 
-				if peer.IsWitnessEnabled() {
+				// New blocks are requested as compact blocks
+				// from peers which support them, since most of
+				// their transactions are likely in the memory
+				// pool already.
+				if sm.current() && peer.WantsCmpctBlocks() {
+					iv.Type = wire.InvTypeCmpctBlock
+				} else if peer.IsWitnessEnabled() {
 					iv.Type = wire.InvTypeWitnessBlock
 				}
 
@@ -1272,6 +1503,8 @@ func (sm *SyncManager) limitMap(m map[chainhash.Hash]struct{}, limit int) {
 func (sm *SyncManager) blockHandler() {
 	stallTicker := time.NewTicker(stallSampleInterval)
 	defer stallTicker.Stop()
+	downloadTicker := time.NewTicker(downloadSampleInterval)
+	defer downloadTicker.Stop()
 
 out:
 	for {
@@ -1289,6 +1522,14 @@ out:
 				sm.handleBlockMsg(msg)
 				msg.reply <- struct{}{}
 
+			case *cmpctBlockMsg:
+				sm.handleCmpctBlockMsg(msg)
+				msg.reply <- struct{}{}
+
+			case *blockTxnMsg:
+				sm.handleBlockTxnMsg(msg)
+				msg.reply <- struct{}{}
+
 			case *invMsg:
 				sm.handleInvMsg(msg)
 
@@ -1335,6 +1576,9 @@ out:
 		case <-stallTicker.C:
 			sm.handleStallSample()
 
+		case <-downloadTicker.C:
+			sm.handleDownloadSample()
+
 		case <-sm.quit:
 			break out
 		}
@@ -1469,6 +1713,32 @@ func (sm *SyncManager) QueueBlock(block *dashutil.Block, peer *peerpkg.Peer, don
 	sm.msgChan <- &blockMsg{block: block, peer: peer, reply: done}
 }
 
+// QueueCmpctBlock adds the passed cmpctblock message and peer to the block
+// handling queue.  Responds to the done channel argument after the message is
+// processed.
+func (sm *SyncManager) QueueCmpctBlock(msg *wire.MsgCmpctBlock, peer *peerpkg.Peer, done chan struct{}) {
+	// Don't accept more blocks if we're shutting down.
+	if atomic.LoadInt32(&sm.shutdown) != 0 {
+		done <- struct{}{}
+		return
+	}
+
+	sm.msgChan <- &cmpctBlockMsg{cmpctBlock: msg, peer: peer, reply: done}
+}
+
+// QueueBlockTxn adds the passed blocktxn message and peer to the block handling
+// queue.  Responds to the done channel argument after the message is
+// processed.
+func (sm *SyncManager) QueueBlockTxn(msg *wire.MsgBlockTxn, peer *peerpkg.Peer, done chan struct{}) {
+	// Don't accept more blocks if we're shutting down.
+	if atomic.LoadInt32(&sm.shutdown) != 0 {
+		done <- struct{}{}
+		return
+	}
+
+	sm.msgChan <- &blockTxnMsg{blockTxn: msg, peer: peer, reply: done}
+}
+
 // QueueInv adds the passed inv message and peer to the block handling queue.
 func (sm *SyncManager) QueueInv(inv *wire.MsgInv, peer *peerpkg.Peer) {
 	// No channel handling here because peers do not need to block on inv
@@ -1567,27 +1837,33 @@ func (sm *SyncManager) Pause() chan<- struct{} {
 // block, tx, and inv updates.
 func New(config *Config) (*SyncManager, error) {
 	sm := SyncManager{
-		peerNotifier:    config.PeerNotifier,
-		chain:           config.Chain,
-		txMemPool:       config.TxMemPool,
-		chainParams:     config.ChainParams,
-		rejectedTxns:    make(map[chainhash.Hash]struct{}),
-		requestedTxns:   make(map[chainhash.Hash]struct{}),
-		requestedBlocks: make(map[chainhash.Hash]struct{}),
-		peerStates:      make(map[*peerpkg.Peer]*peerSyncState),
-		progressLogger:  newBlockProgressLogger("Processed", log),
-		msgChan:         make(chan interface{}, config.MaxPeers*3),
-		headerList:      list.New(),
-		quit:            make(chan struct{}),
-		feeEstimator:    config.FeeEstimator,
+		peerNotifier:     config.PeerNotifier,
+		chain:            config.Chain,
+		ibdChain:         config.Chain,
+		txMemPool:        config.TxMemPool,
+		chainParams:      config.ChainParams,
+		rejectedTxns:     make(map[chainhash.Hash]struct{}),
+		requestedTxns:    make(map[chainhash.Hash]struct{}),
+		requestedBlocks:  make(map[chainhash.Hash]struct{}),
+		peerStates:       make(map[*peerpkg.Peer]*peerSyncState),
+		blocksInFlight:   make(map[chainhash.Hash]*blockRequest),
+		receivedBlocks:   make(map[chainhash.Hash]*downloadedBlock),
+		rejectedBlocks:   make(map[chainhash.Hash]struct{}),
+		requestedHistory: make(map[chainhash.Hash]struct{}),
+		receivedHistory:  make(map[chainhash.Hash]*dashutil.Block),
+		progressLogger:   newBlockProgressLogger("Processed", log),
+		msgChan:          make(chan interface{}, config.MaxPeers*3),
+		quit:             make(chan struct{}),
+		feeEstimator:     config.FeeEstimator,
 	}
 
-	best := sm.chain.BestSnapshot()
 	if !config.DisableCheckpoints {
-		// Initialize the next checkpoint based on the current height.
-		sm.nextCheckpoint = sm.findNextHeaderCheckpoint(best.Height)
-		if sm.nextCheckpoint != nil {
-			sm.resetHeaderState(&best.Hash, best.Height)
+		// The headers up to the final checkpoint are verified against
+		// the checkpoints, so the blocks up to it are added with less
+		// validation.
+		checkpoints := sm.chain.Checkpoints()
+		if len(checkpoints) > 0 {
+			sm.fastAddHeight = checkpoints[len(checkpoints)-1].Height
 		}
 	} else {
 		log.Info("Checkpoints are disabled")
@@ -0,0 +1,404 @@
+// Copyright (c) 2013-2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package netsync
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/eager7/dashd/blockchain"
+	"github.com/eager7/dashd/chaincfg/chainhash"
+	peerpkg "github.com/eager7/dashd/peer"
+	"github.com/eager7/dashd/wire"
+	"github.com/eager7/dashutil"
+)
+
+// fakeDownloadChain is a downloadChain whose best known header chain consists
+// of the passed blocks after the genesis block.  The blocks must be processed
+// in order and those marked invalid or with bad bodies are rejected.
+type fakeDownloadChain struct {
+	blocks       []*dashutil.Block
+	processed    int
+	flags        map[chainhash.Hash]blockchain.BehaviorFlags
+	invalid      map[chainhash.Hash]struct{}
+	badBodies    map[chainhash.Hash]struct{}
+	knownInvalid map[chainhash.Hash]struct{}
+}
+
+// Ensure the fakeDownloadChain type implements the downloadChain interface.
+var _ downloadChain = (*fakeDownloadChain)(nil)
+
+// newFakeDownloadChain returns a fakeDownloadChain with the passed number of
+// distinct blocks to download.
+func newFakeDownloadChain(numBlocks int) *fakeDownloadChain {
+	blocks := make([]*dashutil.Block, numBlocks)
+	var prevHash chainhash.Hash
+	for i := range blocks {
+		header := wire.BlockHeader{PrevBlock: prevHash, Nonce: uint32(i)}
+		blocks[i] = dashutil.NewBlock(wire.NewMsgBlock(&header))
+		blocks[i].SetHeight(int32(i + 1))
+		prevHash = *blocks[i].Hash()
+	}
+	return &fakeDownloadChain{
+		blocks:       blocks,
+		flags:        make(map[chainhash.Hash]blockchain.BehaviorFlags),
+		invalid:      make(map[chainhash.Hash]struct{}),
+		badBodies:    make(map[chainhash.Hash]struct{}),
+		knownInvalid: make(map[chainhash.Hash]struct{}),
+	}
+}
+
+// NextBlocksToDownload returns the hashes of up to the passed number of blocks
+// after the processed ones, along with the height of the first one.  Nothing
+// is returned past a block known to be invalid.
+func (c *fakeDownloadChain) NextBlocksToDownload(maxHashes int) ([]chainhash.Hash, int32) {
+	var hashes []chainhash.Hash
+	for i := c.processed; i < len(c.blocks) && len(hashes) < maxHashes; i++ {
+		if _, exists := c.knownInvalid[*c.blocks[i].Hash()]; exists {
+			return nil, 0
+		}
+		hashes = append(hashes, *c.blocks[i].Hash())
+	}
+	return hashes, int32(c.processed + 1)
+}
+
+// ProcessBlock processes the passed block when it is the next one to download
+// and rejects it when it is marked invalid or has a bad body.
+func (c *fakeDownloadChain) ProcessBlock(block *dashutil.Block, flags blockchain.BehaviorFlags) (bool, bool, error) {
+	hash := *block.Hash()
+	if c.processed == len(c.blocks) || hash != *c.blocks[c.processed].Hash() {
+		return false, false, fmt.Errorf("block %v processed out of "+
+			"order", hash)
+	}
+	if _, exists := c.invalid[hash]; exists {
+		c.knownInvalid[hash] = struct{}{}
+		return false, false, blockchain.RuleError{
+			ErrorCode:   blockchain.ErrBadMerkleRoot,
+			Description: "invalid block",
+		}
+	}
+	if _, exists := c.badBodies[hash]; exists {
+		return false, false, blockchain.RuleError{
+			ErrorCode:   blockchain.ErrBadMerkleRoot,
+			Description: "bad body",
+		}
+	}
+	c.flags[hash] = flags
+	c.processed++
+	return true, false, nil
+}
+
+// IsKnownInvalid returns whether or not the passed block has been marked
+// invalid by processing it.
+func (c *fakeDownloadChain) IsKnownInvalid(hash *chainhash.Hash) bool {
+	_, exists := c.knownInvalid[*hash]
+	return exists
+}
+
+// newDownloadSyncManager returns a sync manager which downloads the blocks of
+// the passed chain.
+func newDownloadSyncManager(chain downloadChain) *SyncManager {
+	return &SyncManager{
+		ibdChain:         chain,
+		rejectedTxns:     make(map[chainhash.Hash]struct{}),
+		requestedTxns:    make(map[chainhash.Hash]struct{}),
+		requestedBlocks:  make(map[chainhash.Hash]struct{}),
+		peerStates:       make(map[*peerpkg.Peer]*peerSyncState),
+		blocksInFlight:   make(map[chainhash.Hash]*blockRequest),
+		receivedBlocks:   make(map[chainhash.Hash]*downloadedBlock),
+		rejectedBlocks:   make(map[chainhash.Hash]struct{}),
+		requestedHistory: make(map[chainhash.Hash]struct{}),
+		progressLogger:   newBlockProgressLogger("Processed", log),
+	}
+}
+
+// addDownloadPeer adds a sync candidate which has advertised the passed height
+// to the sync manager.  The first peer which is added becomes the sync peer.
+func addDownloadPeer(t *testing.T, sm *SyncManager, lastBlock int32) *peerpkg.Peer {
+	t.Helper()
+
+	addr := fmt.Sprintf("127.0.0.1:%d", 18000+len(sm.peerStates))
+	peer, err := peerpkg.NewOutboundPeer(&peerpkg.Config{}, addr)
+	if err != nil {
+		t.Fatalf("NewOutboundPeer: unexpected error: %v", err)
+	}
+	peer.UpdateLastBlockHeight(lastBlock)
+	sm.peerStates[peer] = &peerSyncState{
+		syncCandidate:   true,
+		requestedTxns:   make(map[chainhash.Hash]struct{}),
+		requestedBlocks: make(map[chainhash.Hash]struct{}),
+	}
+	if sm.syncPeer == nil {
+		sm.syncPeer = peer
+	}
+	return peer
+}
+
+// isDisconnected returns whether or not the passed peer has been disconnected.
+func isDisconnected(peer *peerpkg.Peer) bool {
+	disconnected := make(chan struct{})
+	go func() {
+		peer.WaitForDisconnect()
+		close(disconnected)
+	}()
+	select {
+	case <-disconnected:
+		return true
+	case <-time.After(10 * time.Millisecond):
+		return false
+	}
+}
+
+// blockHeights returns the heights of the blocks of the passed chain which are
+// in flight from the passed peer, keyed by height.
+func blockHeights(sm *SyncManager, chain *fakeDownloadChain, peer *peerpkg.Peer) map[int32]struct{} {
+	heights := make(map[int32]struct{})
+	for _, block := range chain.blocks {
+		request, exists := sm.blocksInFlight[*block.Hash()]
+		if exists && request.peer == peer {
+			heights[block.Height()] = struct{}{}
+		}
+	}
+	return heights
+}
+
+// TestFetchBlocks ensures the blocks of the best known header chain are handed
+// out to the sync candidates within the download window, up to the maximum
+// number of blocks in flight per peer and the heights the peers advertise.
+func TestFetchBlocks(t *testing.T) {
+	chain := newFakeDownloadChain(blockDownloadWindow + 100)
+	sm := newDownloadSyncManager(chain)
+	full := addDownloadPeer(t, sm, int32(len(chain.blocks)))
+	short := addDownloadPeer(t, sm, 10)
+
+	// Nothing is requested while the headers are downloaded.
+	sm.headerSyncMode = true
+	sm.fetchBlocks()
+	if len(sm.blocksInFlight) != 0 {
+		t.Fatalf("unexpected blocks in flight during header sync: %d",
+			len(sm.blocksInFlight))
+	}
+	sm.headerSyncMode = false
+
+	// The blocks are requested in order of their heights and a peer is
+	// only asked for blocks up to the height it advertised.
+	sm.fetchBlocks()
+	fullHeights := blockHeights(sm, chain, full)
+	shortHeights := blockHeights(sm, chain, short)
+	if len(fullHeights) != maxBlocksInFlightPerPeer {
+		t.Fatalf("unexpected number of blocks in flight: got %d, "+
+			"want %d", len(fullHeights), maxBlocksInFlightPerPeer)
+	}
+	for height := range shortHeights {
+		if height > short.LastBlock() {
+			t.Fatalf("block at height %d requested from a peer "+
+				"at height %d", height, short.LastBlock())
+		}
+	}
+	numInFlight := len(fullHeights) + len(shortHeights)
+	for height := int32(1); height <= int32(numInFlight); height++ {
+		_, fromFull := fullHeights[height]
+		_, fromShort := shortHeights[height]
+		if !fromFull && !fromShort {
+			t.Fatalf("block at height %d was skipped", height)
+		}
+	}
+	if len(sm.blocksInFlight) != numInFlight ||
+		len(sm.requestedBlocks) != numInFlight {
+
+		t.Fatalf("unexpected number of requested blocks: got %d and "+
+			"%d, want %d", len(sm.blocksInFlight),
+			len(sm.requestedBlocks), numInFlight)
+	}
+
+	// Peers without free download slots are not asked for more blocks.
+	sm.fetchBlocks()
+	if len(blockHeights(sm, chain, full)) != maxBlocksInFlightPerPeer {
+		t.Fatalf("more than %d blocks in flight from a peer",
+			maxBlocksInFlightPerPeer)
+	}
+
+	// No blocks past the download window are requested regardless of the
+	// number of peers.
+	for i := 0; i < blockDownloadWindow/maxBlocksInFlightPerPeer+1; i++ {
+		addDownloadPeer(t, sm, int32(len(chain.blocks)))
+	}
+	sm.fetchBlocks()
+	if len(sm.blocksInFlight) != blockDownloadWindow {
+		t.Fatalf("unexpected number of blocks in flight: got %d, "+
+			"want %d", len(sm.blocksInFlight), blockDownloadWindow)
+	}
+	for _, block := range chain.blocks[blockDownloadWindow:] {
+		if _, exists := sm.blocksInFlight[*block.Hash()]; exists {
+			t.Fatalf("block at height %d past the download window "+
+				"requested", block.Height())
+		}
+	}
+
+	// Blocks after a rejected block are not requested.
+	for hash := range sm.blocksInFlight {
+		delete(sm.blocksInFlight, hash)
+		delete(sm.requestedBlocks, hash)
+	}
+	for _, state := range sm.peerStates {
+		state.requestedBlocks = make(map[chainhash.Hash]struct{})
+	}
+	sm.rejectedBlocks[*chain.blocks[5].Hash()] = struct{}{}
+	sm.fetchBlocks()
+	if len(sm.blocksInFlight) != 5 {
+		t.Fatalf("unexpected number of blocks in flight before a "+
+			"rejected block: got %d, want 5", len(sm.blocksInFlight))
+	}
+}
+
+// TestHandleDownloadedBlock ensures blocks received during the initial block
+// download are processed in order, more blocks are requested once they are,
+// and peers sending rejected blocks are disconnected while only blocks known to
+// be invalid are never requested again.
+func TestHandleDownloadedBlock(t *testing.T) {
+	chain := newFakeDownloadChain(40)
+	sm := newDownloadSyncManager(chain)
+	sm.fastAddHeight = 1
+	peer := addDownloadPeer(t, sm, int32(len(chain.blocks)))
+	sm.fetchBlocks()
+
+	// A block received ahead of its parent is held until the parent is
+	// received.
+	blocks := chain.blocks
+	sm.handleBlockMsg(&blockMsg{block: blocks[1], peer: peer})
+	if chain.processed != 0 || len(sm.receivedBlocks) != 1 {
+		t.Fatalf("block processed ahead of its parent")
+	}
+	sm.handleBlockMsg(&blockMsg{block: blocks[0], peer: peer})
+	if chain.processed != 2 || len(sm.receivedBlocks) != 0 {
+		t.Fatalf("unexpected number of processed blocks: got %d, "+
+			"want 2", chain.processed)
+	}
+
+	// Blocks up to the final checkpoint are added with less validation.
+	if chain.flags[*blocks[0].Hash()] != blockchain.BFFastAdd ||
+		chain.flags[*blocks[1].Hash()] != blockchain.BFNone {
+
+		t.Fatalf("unexpected behavior flags %v and %v",
+			chain.flags[*blocks[0].Hash()],
+			chain.flags[*blocks[1].Hash()])
+	}
+
+	// The processed blocks free download slots for the next blocks.
+	heights := blockHeights(sm, chain, peer)
+	if len(heights) != maxBlocksInFlightPerPeer {
+		t.Fatalf("unexpected number of blocks in flight: got %d, "+
+			"want %d", len(heights), maxBlocksInFlightPerPeer)
+	}
+	if _, exists := heights[int32(maxBlocksInFlightPerPeer+2)]; !exists {
+		t.Fatalf("next block was not requested")
+	}
+
+	// A block with a bad body is requested again from another peer and
+	// the peer which sent it is disconnected.
+	other := addDownloadPeer(t, sm, int32(len(chain.blocks)))
+	chain.badBodies[*blocks[2].Hash()] = struct{}{}
+	sm.handleBlockMsg(&blockMsg{block: blocks[2], peer: peer})
+	if !isDisconnected(peer) || sm.peerStates[peer].syncCandidate {
+		t.Fatalf("peer sending a bad block was not disconnected")
+	}
+	if _, exists := sm.rejectedBlocks[*blocks[2].Hash()]; exists {
+		t.Fatalf("block with a bad body was rejected")
+	}
+	if len(blockHeights(sm, chain, peer)) != 0 {
+		t.Fatalf("blocks still in flight from a disconnected peer")
+	}
+	if _, exists := blockHeights(sm, chain, other)[3]; !exists {
+		t.Fatalf("block with a bad body was not requested again")
+	}
+
+	// A block which is known to be invalid is never requested again and
+	// neither are the blocks after it.
+	delete(chain.badBodies, *blocks[2].Hash())
+	chain.invalid[*blocks[2].Hash()] = struct{}{}
+	sm.handleBlockMsg(&blockMsg{block: blocks[2], peer: other})
+	if !isDisconnected(other) {
+		t.Fatalf("peer sending an invalid block was not disconnected")
+	}
+	if _, exists := sm.rejectedBlocks[*blocks[2].Hash()]; !exists {
+		t.Fatalf("invalid block was not rejected")
+	}
+	addDownloadPeer(t, sm, int32(len(chain.blocks)))
+	sm.fetchBlocks()
+	if len(sm.blocksInFlight) != 0 {
+		t.Fatalf("unexpected blocks in flight after an invalid "+
+			"block: %d", len(sm.blocksInFlight))
+	}
+}
+
+// TestHandleDownloadSample ensures peers which do not deliver requested blocks
+// in time are disconnected and the blocks are requested from other peers, and
+// that the peer holding up an exhausted download window is given less time.
+func TestHandleDownloadSample(t *testing.T) {
+	chain := newFakeDownloadChain(blockDownloadWindow + 100)
+	sm := newDownloadSyncManager(chain)
+	slow := addDownloadPeer(t, sm, int32(len(chain.blocks)))
+	sm.fetchBlocks()
+	other := addDownloadPeer(t, sm, int32(len(chain.blocks)))
+
+	// Requests which have not timed out yet are kept, even when the first
+	// block of the window is late, as long as the window is not exhausted.
+	firstHash := *chain.blocks[0].Hash()
+	sm.blocksInFlight[firstHash].requested = time.Now().Add(
+		-blockStallTimeout - time.Second)
+	sm.handleDownloadSample()
+	if isDisconnected(slow) {
+		t.Fatalf("peer disconnected before the download window is " +
+			"exhausted")
+	}
+
+	// A peer with a request which timed out is disconnected and the blocks
+	// it was asked for are requested from other peers.
+	sm.blocksInFlight[firstHash].requested = time.Now().Add(
+		-blockDownloadTimeout - time.Second)
+	sm.handleDownloadSample()
+	if !isDisconnected(slow) || sm.peerStates[slow].syncCandidate {
+		t.Fatalf("peer with a timed out request was not disconnected")
+	}
+	if request := sm.blocksInFlight[firstHash]; request == nil ||
+		request.peer != other {
+
+		t.Fatalf("block of a timed out request was not requested " +
+			"again")
+	}
+	if isDisconnected(other) {
+		t.Fatalf("peer without a timed out request was disconnected")
+	}
+
+	// Once the download window is exhausted, the peer which was asked
+	// for the first block of the window is disconnected after the stall
+	// timeout, but the other peers are not.
+	for i := 0; i < blockDownloadWindow/maxBlocksInFlightPerPeer; i++ {
+		addDownloadPeer(t, sm, int32(len(chain.blocks)))
+	}
+	sm.fetchBlocks()
+	hashes, _ := chain.NextBlocksToDownload(blockDownloadWindow)
+	if len(sm.neededBlocks(hashes)) != 0 {
+		t.Fatalf("download window is not exhausted")
+	}
+	sm.blocksInFlight[firstHash].requested = time.Now().Add(
+		-blockStallTimeout - time.Second)
+	sm.handleDownloadSample()
+	if !isDisconnected(other) || sm.peerStates[other].syncCandidate {
+		t.Fatalf("peer stalling the download window was not " +
+			"disconnected")
+	}
+	for peer, state := range sm.peerStates {
+		if peer != slow && peer != other && isDisconnected(peer) {
+			t.Fatalf("peer not stalling the download window was " +
+				"disconnected")
+		}
+		if peer != slow && peer != other && !state.syncCandidate {
+			t.Fatalf("peer not stalling the download window is " +
+				"no longer a sync candidate")
+		}
+	}
+}
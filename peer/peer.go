@@ -21,6 +21,7 @@ import (
 	"github.com/eager7/dashd/blockchain"
 	"github.com/eager7/dashd/chaincfg"
 	"github.com/eager7/dashd/chaincfg/chainhash"
+	"github.com/eager7/dashd/v2transport"
 	"github.com/eager7/dashd/wire"
 	"github.com/btcsuite/go-socks/socks"
 	"github.com/davecgh/go-spew/spew"
@@ -106,6 +107,9 @@ type MessageListeners struct {
 	// OnAddr is invoked when a peer receives an addr bitcoin message.
 	OnAddr func(p *Peer, msg *wire.MsgAddr)
 
+	// OnAddrV2 is invoked when a peer receives an addrv2 message.
+	OnAddrV2 func(p *Peer, msg *wire.MsgAddrV2)
+
 	// OnPing is invoked when a peer receives a ping bitcoin message.
 	OnPing func(p *Peer, msg *wire.MsgPing)
 
@@ -201,6 +205,31 @@ type MessageListeners struct {
 	// message.
 	OnSendHeaders func(p *Peer, msg *wire.MsgSendHeaders)
 
+	// OnDSQueue is invoked when a peer receives a dsq message.
+	OnDSQueue func(p *Peer, msg *wire.MsgDSQueue)
+
+	// OnDSTx is invoked when a peer receives a dstx message.
+	OnDSTx func(p *Peer, msg *wire.MsgDSTx)
+
+	// OnSendDSQueue is invoked when a peer receives a senddsq message.
+	OnSendDSQueue func(p *Peer, msg *wire.MsgSendDSQueue)
+
+	// OnSendCmpct is invoked when a peer receives a sendcmpct bitcoin
+	// message.
+	OnSendCmpct func(p *Peer, msg *wire.MsgSendCmpct)
+
+	// OnCmpctBlock is invoked when a peer receives a cmpctblock bitcoin
+	// message.
+	OnCmpctBlock func(p *Peer, msg *wire.MsgCmpctBlock)
+
+	// OnGetBlockTxn is invoked when a peer receives a getblocktxn bitcoin
+	// message.
+	OnGetBlockTxn func(p *Peer, msg *wire.MsgGetBlockTxn)
+
+	// OnBlockTxn is invoked when a peer receives a blocktxn bitcoin
+	// message.
+	OnBlockTxn func(p *Peer, msg *wire.MsgBlockTxn)
+
 	// OnRead is invoked when a peer receives a bitcoin message.  It
 	// consists of the number of bytes read, the message, and whether or not
 	// an error in the read occurred.  Typically, callers will opt to use
@@ -275,6 +304,20 @@ type Config struct {
 	// TrickleInterval is the duration of the ticker which trickles down the
 	// inventory to a peer.
 	TrickleInterval time.Duration
+
+	// V2Transport specifies whether to use the v2 encrypted transport
+	// (BIP0324).  Outbound peers start a v2 handshake, so it should only
+	// be set for them when the remote peer is known to support it.
+	// Inbound peers fall back to the v1 transport when the remote peer
+	// starts a v1 connection.
+	V2Transport bool
+
+	// CmpctBlocks specifies whether compact blocks (BIP0152) are
+	// supported.  When set, peers which advertise a protocol version that
+	// supports them are sent a sendcmpct message once the protocol has
+	// been negotiated, which requests low bandwidth mode.  The caller is
+	// responsible for handling the related messages.
+	CmpctBlocks bool
 }
 
 // minUint32 is a helper function to return the minimum of two uint32s.
@@ -287,14 +330,16 @@ func minUint32(a, b uint32) uint32 {
 }
 
 // newNetAddress attempts to extract the IP address and port from the passed
-// net.Addr interface and create a bitcoin NetAddress structure using that
-// information.
-func newNetAddress(addr net.Addr, services wire.ServiceFlag) (*wire.NetAddress, error) {
+// net.Addr interface and create a NetAddressV2 structure using that
+// information.  Host names which are not IP addresses are converted with the
+// passed function when it is set.
+func newNetAddress(addr net.Addr, services wire.ServiceFlag,
+	hostToNetAddr HostToNetAddrFunc) (*wire.NetAddressV2, error) {
 	// addr will be a net.TCPAddr when not using a proxy.
 	if tcpAddr, ok := addr.(*net.TCPAddr); ok {
 		ip := tcpAddr.IP
 		port := uint16(tcpAddr.Port)
-		na := wire.NewNetAddressIPPort(ip, port, services)
+		na := wire.NewNetAddressV2IPPort(ip, port, services)
 		return na, nil
 	}
 
@@ -305,7 +350,7 @@ func newNetAddress(addr net.Addr, services wire.ServiceFlag) (*wire.NetAddress,
 			ip = net.ParseIP("0.0.0.0")
 		}
 		port := uint16(proxiedAddr.Port)
-		na := wire.NewNetAddressIPPort(ip, port, services)
+		na := wire.NewNetAddressV2IPPort(ip, port, services)
 		return na, nil
 	}
 
@@ -321,7 +366,13 @@ func newNetAddress(addr net.Addr, services wire.ServiceFlag) (*wire.NetAddress,
 	if err != nil {
 		return nil, err
 	}
-	na := wire.NewNetAddressIPPort(ip, uint16(port), services)
+
+	// Connections accepted from networks which are not IP networks, such
+	// as I2P, have the host name of the remote peer as address.
+	if ip == nil && hostToNetAddr != nil {
+		return hostToNetAddr(host, uint16(port), services)
+	}
+	na := wire.NewNetAddressV2IPPort(ip, uint16(port), services)
 	return na, nil
 }
 
@@ -386,12 +437,12 @@ type StatsSnap struct {
 type HashFunc func() (hash *chainhash.Hash, height int32, err error)
 
 // AddrFunc is a func which takes an address and returns a related address.
-type AddrFunc func(remoteAddr *wire.NetAddress) *wire.NetAddress
+type AddrFunc func(remoteAddr *wire.NetAddressV2) *wire.NetAddressV2
 
 // HostToNetAddrFunc is a func which takes a host, port, services and returns
 // the netaddress.
 type HostToNetAddrFunc func(host string, port uint16,
-	services wire.ServiceFlag) (*wire.NetAddress, error)
+	services wire.ServiceFlag) (*wire.NetAddressV2, error)
 
 // NOTE: The overall data flow of a peer is split into 3 goroutines.  Inbound
 // messages are read via the inHandler goroutine and generally dispatched to
@@ -430,6 +481,13 @@ type Peer struct {
 
 	conn net.Conn
 
+	// These fields are set before the protocol negotiation and never
+	// modified afterwards.  connReader reads the messages of the v1
+	// transport, which includes the bytes read from a peer which fell back
+	// to it, and v2 is set when the v2 transport is used.
+	connReader io.Reader
+	v2         *v2transport.Transport
+
 	// These fields are set at creation time and never modified, so they are
 	// safe to read from concurrently without a mutex.
 	addr    string
@@ -437,7 +495,7 @@ type Peer struct {
 	inbound bool
 
 	flagsMtx             sync.Mutex // protects the peer flags below
-	na                   *wire.NetAddress
+	na                   *wire.NetAddressV2
 	id                   int32
 	userAgent            string
 	services             wire.ServiceFlag
@@ -445,6 +503,10 @@ type Peer struct {
 	advertisedProtoVer   uint32 // protocol version advertised by remote
 	protocolVersion      uint32 // negotiated protocol version
 	sendHeadersPreferred bool   // peer sent a sendheaders message
+	sendAddrV2           bool   // peer sent a sendaddrv2 message
+	cmpctBlocks          bool   // peer sent a supported sendcmpct message
+	cmpctHighBandwidth   bool   // peer wants cmpctblock announcements
+	v2Transport          bool   // v2 transport established
 	verAckReceived       bool
 	witnessEnabled       bool
 
@@ -520,6 +582,14 @@ func (p *Peer) AddKnownInventory(invVect *wire.InvVect) {
 	p.knownInventory.Add(invVect)
 }
 
+// HasKnownInventory returns whether the passed inventory is in the cache of
+// known inventory for the peer.
+//
+// This function is safe for concurrent access.
+func (p *Peer) HasKnownInventory(invVect *wire.InvVect) bool {
+	return p.knownInventory.Exists(invVect)
+}
+
 // StatsSnapshot returns a snapshot of the current peer flags and statistics.
 //
 // This function is safe for concurrent access.
@@ -573,7 +643,7 @@ func (p *Peer) ID() int32 {
 // NA returns the peer network address.
 //
 // This function is safe for concurrent access.
-func (p *Peer) NA() *wire.NetAddress {
+func (p *Peer) NA() *wire.NetAddressV2 {
 	p.flagsMtx.Lock()
 	na := p.na
 	p.flagsMtx.Unlock()
@@ -796,6 +866,55 @@ func (p *Peer) WantsHeaders() bool {
 	return sendHeadersPreferred
 }
 
+// WantsAddrV2 returns if the peer signaled with a sendaddrv2 message that it
+// wants addrv2 messages instead of addr messages.
+//
+// This function is safe for concurrent access.
+func (p *Peer) WantsAddrV2() bool {
+	p.flagsMtx.Lock()
+	sendAddrV2 := p.sendAddrV2
+	p.flagsMtx.Unlock()
+
+	return sendAddrV2
+}
+
+// WantsCmpctBlocks returns if the peer signaled with a sendcmpct message that
+// it supports compact blocks (BIP0152) of the version known to this package.
+//
+// This function is safe for concurrent access.
+func (p *Peer) WantsCmpctBlocks() bool {
+	p.flagsMtx.Lock()
+	cmpctBlocks := p.cmpctBlocks
+	p.flagsMtx.Unlock()
+
+	return cmpctBlocks
+}
+
+// WantsHighBandwidthCmpctBlocks returns if the peer signaled with a sendcmpct
+// message that it wants new blocks to be announced with cmpctblock messages
+// instead of inventory vectors or headers.
+//
+// This function is safe for concurrent access.
+func (p *Peer) WantsHighBandwidthCmpctBlocks() bool {
+	p.flagsMtx.Lock()
+	highBandwidth := p.cmpctBlocks && p.cmpctHighBandwidth
+	p.flagsMtx.Unlock()
+
+	return highBandwidth
+}
+
+// V2Transport returns whether messages are exchanged with the peer through the
+// v2 encrypted transport (BIP0324).
+//
+// This function is safe for concurrent access.
+func (p *Peer) V2Transport() bool {
+	p.flagsMtx.Lock()
+	v2Transport := p.v2Transport
+	p.flagsMtx.Unlock()
+
+	return v2Transport
+}
+
 // IsWitnessEnabled returns true if the peer has signalled that it supports
 // segregated witness.
 //
@@ -812,36 +931,74 @@ func (p *Peer) IsWitnessEnabled() bool {
 // addresses.  This function is useful over manually sending the message via
 // QueueMessage since it automatically limits the addresses to the maximum
 // number allowed by the message and randomizes the chosen addresses when there
-// are too many.  It returns the addresses that were actually sent and no
-// message will be sent if there are no entries in the provided addresses slice.
+// are too many.  Addresses which can't be represented in an addr message, such
+// as Tor v3 addresses, are skipped.  It returns the addresses that were
+// actually sent and no message will be sent if there are no entries in the
+// provided addresses slice.
 //
 // This function is safe for concurrent access.
-func (p *Peer) PushAddrMsg(addresses []*wire.NetAddress) ([]*wire.NetAddress, error) {
-	addressCount := len(addresses)
+func (p *Peer) PushAddrMsg(addresses []*wire.NetAddressV2) ([]*wire.NetAddressV2, error) {
+	addrList := make([]*wire.NetAddressV2, 0, len(addresses))
+	for _, na := range addresses {
+		if na.ToLegacy() != nil {
+			addrList = append(addrList, na)
+		}
+	}
+	addrList = randomizeAddrList(addrList)
 
 	// Nothing to send.
-	if addressCount == 0 {
+	if len(addrList) == 0 {
 		return nil, nil
 	}
 
 	msg := wire.NewMsgAddr()
-	msg.AddrList = make([]*wire.NetAddress, addressCount)
-	copy(msg.AddrList, addresses)
-
-	// Randomize the addresses sent if there are more than the maximum allowed.
-	if addressCount > wire.MaxAddrPerMsg {
-		// Shuffle the address list.
-		for i := 0; i < wire.MaxAddrPerMsg; i++ {
-			j := i + rand.Intn(addressCount-i)
-			msg.AddrList[i], msg.AddrList[j] = msg.AddrList[j], msg.AddrList[i]
-		}
+	for _, na := range addrList {
+		msg.AddAddress(na.ToLegacy())
+	}
+
+	p.QueueMessage(msg, nil)
+	return addrList, nil
+}
+
+// PushAddrV2Msg sends an addrv2 message to the connected peer using the
+// provided addresses.  It limits and randomizes the addresses like PushAddrMsg,
+// but all addresses can be sent.  It must only be used for peers which sent a
+// sendaddrv2 message, see WantsAddrV2.
+//
+// This function is safe for concurrent access.
+func (p *Peer) PushAddrV2Msg(addresses []*wire.NetAddressV2) ([]*wire.NetAddressV2, error) {
+	addrList := randomizeAddrList(append([]*wire.NetAddressV2(nil),
+		addresses...))
 
-		// Truncate it to the maximum size.
-		msg.AddrList = msg.AddrList[:wire.MaxAddrPerMsg]
+	// Nothing to send.
+	if len(addrList) == 0 {
+		return nil, nil
 	}
 
+	msg := wire.NewMsgAddrV2()
+	msg.AddrList = addrList
+
 	p.QueueMessage(msg, nil)
-	return msg.AddrList, nil
+	return addrList, nil
+}
+
+// randomizeAddrList limits the passed addresses to the maximum number allowed
+// by an addr message, randomizing the chosen addresses when there are too many.
+// The passed slice is modified.
+func randomizeAddrList(addrList []*wire.NetAddressV2) []*wire.NetAddressV2 {
+	addressCount := len(addrList)
+	if addressCount <= wire.MaxAddrPerMsg {
+		return addrList
+	}
+
+	// Shuffle the address list.
+	for i := 0; i < wire.MaxAddrPerMsg; i++ {
+		j := i + rand.Intn(addressCount-i)
+		addrList[i], addrList[j] = addrList[j], addrList[i]
+	}
+
+	// Truncate it to the maximum size.
+	return addrList[:wire.MaxAddrPerMsg]
 }
 
 // PushGetBlocksMsg sends a getblocks message for the provided block locator
@@ -1006,8 +1163,16 @@ func (p *Peer) handlePongMsg(msg *wire.MsgPong) {
 
 // readMessage reads the next bitcoin message from the peer with logging.
 func (p *Peer) readMessage(encoding wire.MessageEncoding) (wire.Message, []byte, error) {
-	n, msg, buf, err := wire.ReadMessageWithEncodingN(p.conn,
-		p.ProtocolVersion(), p.cfg.ChainParams.Net, encoding)
+	var n int
+	var msg wire.Message
+	var buf []byte
+	var err error
+	if p.v2 != nil {
+		n, msg, buf, err = p.v2.ReadMessage(p.ProtocolVersion(), encoding)
+	} else {
+		n, msg, buf, err = wire.ReadMessageWithEncodingN(p.connReader,
+			p.ProtocolVersion(), p.cfg.ChainParams.Net, encoding)
+	}
 	atomic.AddUint64(&p.bytesReceived, uint64(n))
 	if p.cfg.Listeners.OnRead != nil {
 		p.cfg.Listeners.OnRead(p, n, msg, err)
@@ -1069,8 +1234,14 @@ func (p *Peer) writeMessage(msg wire.Message, enc wire.MessageEncoding) error {
 	}))
 
 	// Write the message to the peer.
-	n, err := wire.WriteMessageWithEncodingN(p.conn, msg,
-		p.ProtocolVersion(), p.cfg.ChainParams.Net, enc)
+	var n int
+	var err error
+	if p.v2 != nil {
+		n, err = p.v2.WriteMessage(msg, p.ProtocolVersion(), enc)
+	} else {
+		n, err = wire.WriteMessageWithEncodingN(p.conn, msg,
+			p.ProtocolVersion(), p.cfg.ChainParams.Net, enc)
+	}
 	atomic.AddUint64(&p.bytesSent, uint64(n))
 	if p.cfg.Listeners.OnWrite != nil {
 		p.cfg.Listeners.OnWrite(p, n, msg, err)
@@ -1153,12 +1324,18 @@ func (p *Peer) maybeAddDeadline(pendingResponses map[string]time.Time, msgCmd st
 		pendingResponses[wire.CmdInv] = deadline
 
 	case wire.CmdGetData:
-		// Expects a block, merkleblock, tx, or notfound message.
+		// Expects a block, cmpctblock, merkleblock, tx, or notfound
+		// message.
 		pendingResponses[wire.CmdBlock] = deadline
+		pendingResponses[wire.CmdCmpctBlock] = deadline
 		pendingResponses[wire.CmdMerkleBlock] = deadline
 		pendingResponses[wire.CmdTx] = deadline
 		pendingResponses[wire.CmdNotFound] = deadline
 
+	case wire.CmdGetBlockTxn:
+		// Expects a blocktxn message.
+		pendingResponses[wire.CmdBlockTxn] = deadline
+
 	case wire.CmdGetHeaders:
 		// Expects a headers message.  Use a longer deadline since it
 		// can take a while for the remote peer to load all of the
@@ -1212,12 +1389,15 @@ out:
 				switch msgCmd := msg.message.Command(); msgCmd {
 				case wire.CmdBlock:
 					fallthrough
+				case wire.CmdCmpctBlock:
+					fallthrough
 				case wire.CmdMerkleBlock:
 					fallthrough
 				case wire.CmdTx:
 					fallthrough
 				case wire.CmdNotFound:
 					delete(pendingResponses, wire.CmdBlock)
+					delete(pendingResponses, wire.CmdCmpctBlock)
 					delete(pendingResponses, wire.CmdMerkleBlock)
 					delete(pendingResponses, wire.CmdTx)
 					delete(pendingResponses, wire.CmdNotFound)
@@ -1394,6 +1574,17 @@ out:
 				p.cfg.Listeners.OnAddr(p, msg)
 			}
 
+		case *wire.MsgAddrV2:
+			if p.cfg.Listeners.OnAddrV2 != nil {
+				p.cfg.Listeners.OnAddrV2(p, msg)
+			}
+
+		case *wire.MsgSendAddrV2:
+			// A sendaddrv2 message is only valid before the verack
+			// message, where it is handled during negotiation.
+			log.Debugf("Ignoring sendaddrv2 message after verack "+
+				"from %v", p)
+
 		case *wire.MsgPing:
 			p.handlePingMsg(msg)
 			if p.cfg.Listeners.OnPing != nil {
@@ -1520,6 +1711,51 @@ out:
 				p.cfg.Listeners.OnSendHeaders(p, msg)
 			}
 
+		case *wire.MsgDSQueue:
+			if p.cfg.Listeners.OnDSQueue != nil {
+				p.cfg.Listeners.OnDSQueue(p, msg)
+			}
+
+		case *wire.MsgDSTx:
+			if p.cfg.Listeners.OnDSTx != nil {
+				p.cfg.Listeners.OnDSTx(p, msg)
+			}
+
+		case *wire.MsgSendDSQueue:
+			if p.cfg.Listeners.OnSendDSQueue != nil {
+				p.cfg.Listeners.OnSendDSQueue(p, msg)
+			}
+
+		case *wire.MsgSendCmpct:
+			// Versions other than the supported one are ignored.
+			// The latest supported sendcmpct message determines
+			// whether the peer wants high bandwidth mode.
+			if msg.CmpctBlockVersion == wire.CmpctBlockVersion {
+				p.flagsMtx.Lock()
+				p.cmpctBlocks = true
+				p.cmpctHighBandwidth = msg.AnnounceUsingCmpctBlock
+				p.flagsMtx.Unlock()
+			}
+
+			if p.cfg.Listeners.OnSendCmpct != nil {
+				p.cfg.Listeners.OnSendCmpct(p, msg)
+			}
+
+		case *wire.MsgCmpctBlock:
+			if p.cfg.Listeners.OnCmpctBlock != nil {
+				p.cfg.Listeners.OnCmpctBlock(p, msg)
+			}
+
+		case *wire.MsgGetBlockTxn:
+			if p.cfg.Listeners.OnGetBlockTxn != nil {
+				p.cfg.Listeners.OnGetBlockTxn(p, msg)
+			}
+
+		case *wire.MsgBlockTxn:
+			if p.cfg.Listeners.OnBlockTxn != nil {
+				p.cfg.Listeners.OnBlockTxn(p, msg)
+			}
+
 		default:
 			log.Debugf("Received unhandled message of type %v "+
 				"from %v", rmsg.Command(), p)
@@ -1968,8 +2204,8 @@ func (p *Peer) readRemoteVersionMsg() error {
 
 // readRemoteVerAckMsg waits for the next message to arrive from the remote
 // peer. If this message is not a verack message, then an error is returned.
-// This method is to be used as part of the version negotiation upon a new
-// connection.
+// A sendaddrv2 message may precede the verack message.  This method is to be
+// used as part of the version negotiation upon a new connection.
 func (p *Peer) readRemoteVerAckMsg() error {
 	// Read the next message from the wire.
 	remoteMsg, _, err := p.readMessage(wire.LatestEncoding)
@@ -1977,6 +2213,19 @@ func (p *Peer) readRemoteVerAckMsg() error {
 		return err
 	}
 
+	// The peer signals that it prefers addrv2 messages by sending a
+	// sendaddrv2 message before its verack message.
+	if _, ok := remoteMsg.(*wire.MsgSendAddrV2); ok {
+		p.flagsMtx.Lock()
+		p.sendAddrV2 = true
+		p.flagsMtx.Unlock()
+
+		remoteMsg, _, err = p.readMessage(wire.LatestEncoding)
+		if err != nil {
+			return err
+		}
+	}
+
 	// It should be a verack message, otherwise send a reject message to the
 	// peer explaining why.
 	msg, ok := remoteMsg.(*wire.MsgVerAck)
@@ -2020,12 +2269,20 @@ func (p *Peer) localVersionMsg() (*wire.MsgVersion, error) {
 	if p.cfg.Proxy != "" {
 		proxyaddress, _, err := net.SplitHostPort(p.cfg.Proxy)
 		// invalid proxy means poorly configured, be on the safe side.
-		if err != nil || p.na.IP.String() == proxyaddress {
-			theirNA = wire.NewNetAddressIPPort(net.IP([]byte{0, 0, 0, 0}), 0,
+		if err != nil || p.na.IP().String() == proxyaddress {
+			theirNA = wire.NewNetAddressV2IPPort(net.IP([]byte{0, 0, 0, 0}), 0,
 				theirNA.Services)
 		}
 	}
 
+	// The version message can only hold legacy addresses, so addresses of
+	// other networks, such as Tor v3, are sent as an unroutable address.
+	theirLegacyNA := theirNA.ToLegacy()
+	if theirLegacyNA == nil {
+		theirLegacyNA = wire.NewNetAddressIPPort(net.IPv4zero, 0,
+			theirNA.Services)
+	}
+
 	// Create a wire.NetAddress with only the services set to use as the
 	// "addrme" in the version message.
 	//
@@ -2046,7 +2303,7 @@ func (p *Peer) localVersionMsg() (*wire.MsgVersion, error) {
 	sentNonces.Add(nonce)
 
 	// Version message.
-	msg := wire.NewMsgVersion(ourNA, theirNA, nonce, blockNum)
+	msg := wire.NewMsgVersion(ourNA, theirLegacyNA, nonce, blockNum)
 	msg.AddUserAgent(p.cfg.UserAgentName, p.cfg.UserAgentVersion,
 		p.cfg.UserAgentComments...)
 
@@ -2072,14 +2329,26 @@ func (p *Peer) writeLocalVersionMsg() error {
 	return p.writeMessage(localVerMsg, wire.LatestEncoding)
 }
 
+// writeSendAddrV2Msg sends a sendaddrv2 message to the remote peer when the
+// protocol version it advertised supports addrv2 messages.  It must be sent
+// after the version message and before the verack message.
+func (p *Peer) writeSendAddrV2Msg() error {
+	if p.advertisedProtoVer < wire.AddrV2Version {
+		return nil
+	}
+
+	return p.writeMessage(wire.NewMsgSendAddrV2(), wire.LatestEncoding)
+}
+
 // negotiateInboundProtocol performs the negotiation protocol for an inbound
 // peer. The events should occur in the following order, otherwise an error is
 // returned:
 //
 //   1. Remote peer sends their version.
 //   2. We send our version.
-//   3. We send our verack.
-//   4. Remote peer sends their verack.
+//   3. We send our sendaddrv2 if the remote peer supports it.
+//   4. We send our verack.
+//   5. Remote peer sends their verack, optionally preceded by a sendaddrv2.
 func (p *Peer) negotiateInboundProtocol() error {
 	if err := p.readRemoteVersionMsg(); err != nil {
 		return err
@@ -2089,6 +2358,10 @@ func (p *Peer) negotiateInboundProtocol() error {
 		return err
 	}
 
+	if err := p.writeSendAddrV2Msg(); err != nil {
+		return err
+	}
+
 	err := p.writeMessage(wire.NewMsgVerAck(), wire.LatestEncoding)
 	if err != nil {
 		return err
@@ -2103,8 +2376,9 @@ func (p *Peer) negotiateInboundProtocol() error {
 //
 //   1. We send our version.
 //   2. Remote peer sends their version.
-//   3. Remote peer sends their verack.
-//   4. We send our verack.
+//   3. Remote peer sends their verack, optionally preceded by a sendaddrv2.
+//   4. We send our sendaddrv2 if the remote peer supports it.
+//   5. We send our verack.
 func (p *Peer) negotiateOutboundProtocol() error {
 	if err := p.writeLocalVersionMsg(); err != nil {
 		return err
@@ -2118,15 +2392,50 @@ func (p *Peer) negotiateOutboundProtocol() error {
 		return err
 	}
 
+	if err := p.writeSendAddrV2Msg(); err != nil {
+		return err
+	}
+
 	return p.writeMessage(wire.NewMsgVerAck(), wire.LatestEncoding)
 }
 
+// handshakeV2 performs the handshake of the v2 transport.  Inbound peers
+// which start a v1 connection instead continue with the v1 transport.
+func (p *Peer) handshakeV2() error {
+	t := v2transport.NewTransport(p.conn, p.cfg.ChainParams.Net, !p.inbound)
+	err := t.Handshake()
+	if err == v2transport.ErrV1Peer {
+		log.Debugf("Peer %s uses the v1 transport", p)
+		p.connReader = io.MultiReader(bytes.NewReader(t.V1Prefix()),
+			p.conn)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	log.Debugf("Established v2 transport with %s, session id %x", p,
+		t.SessionID())
+	p.v2 = t
+	p.flagsMtx.Lock()
+	p.v2Transport = true
+	p.flagsMtx.Unlock()
+	return nil
+}
+
 // start begins processing input and output messages.
 func (p *Peer) start() error {
 	log.Tracef("Starting peer %s", p)
 
 	negotiateErr := make(chan error, 1)
 	go func() {
+		if p.cfg.V2Transport {
+			if err := p.handshakeV2(); err != nil {
+				negotiateErr <- err
+				return
+			}
+		}
+
 		if p.inbound {
 			negotiateErr <- p.negotiateInboundProtocol()
 		} else {
@@ -2155,6 +2464,15 @@ func (p *Peer) start() error {
 	go p.outHandler()
 	go p.pingHandler()
 
+	// Signal support for compact blocks without asking for them to be
+	// announced right away.
+	if p.cfg.CmpctBlocks &&
+		p.advertisedProtoVer >= wire.ShortIDsBlocksVersion {
+
+		p.QueueMessage(wire.NewMsgSendCmpct(false,
+			wire.CmpctBlockVersion), nil)
+	}
+
 	return nil
 }
 
@@ -2167,6 +2485,7 @@ func (p *Peer) AssociateConnection(conn net.Conn) {
 	}
 
 	p.conn = conn
+	p.connReader = conn
 	p.timeConnected = time.Now()
 
 	if p.inbound {
@@ -2175,7 +2494,8 @@ func (p *Peer) AssociateConnection(conn net.Conn) {
 		// Set up a NetAddress for the peer to be used with AddrManager.  We
 		// only do this inbound because outbound set this up at connection time
 		// and no point recomputing.
-		na, err := newNetAddress(p.conn.RemoteAddr(), p.services)
+		na, err := newNetAddress(p.conn.RemoteAddr(), p.services,
+			p.cfg.HostToNetAddress)
 		if err != nil {
 			log.Errorf("Cannot create remote net address: %v", err)
 			p.Disconnect()
@@ -2269,7 +2589,7 @@ func NewOutboundPeer(cfg *Config, addr string) (*Peer, error) {
 		}
 		p.na = na
 	} else {
-		p.na = wire.NewNetAddressIPPort(net.ParseIP(host), uint16(port), 0)
+		p.na = wire.NewNetAddressV2IPPort(net.ParseIP(host), uint16(port), 0)
 	}
 
 	return p, nil
@@ -366,6 +366,9 @@ func TestPeerListeners(t *testing.T) {
 			OnAddr: func(p *peer.Peer, msg *wire.MsgAddr) {
 				ok <- msg
 			},
+			OnAddrV2: func(p *peer.Peer, msg *wire.MsgAddrV2) {
+				ok <- msg
+			},
 			OnPing: func(p *peer.Peer, msg *wire.MsgPing) {
 				ok <- msg
 			},
@@ -445,6 +448,27 @@ func TestPeerListeners(t *testing.T) {
 			OnSendHeaders: func(p *peer.Peer, msg *wire.MsgSendHeaders) {
 				ok <- msg
 			},
+			OnDSQueue: func(p *peer.Peer, msg *wire.MsgDSQueue) {
+				ok <- msg
+			},
+			OnDSTx: func(p *peer.Peer, msg *wire.MsgDSTx) {
+				ok <- msg
+			},
+			OnSendDSQueue: func(p *peer.Peer, msg *wire.MsgSendDSQueue) {
+				ok <- msg
+			},
+			OnSendCmpct: func(p *peer.Peer, msg *wire.MsgSendCmpct) {
+				ok <- msg
+			},
+			OnCmpctBlock: func(p *peer.Peer, msg *wire.MsgCmpctBlock) {
+				ok <- msg
+			},
+			OnGetBlockTxn: func(p *peer.Peer, msg *wire.MsgGetBlockTxn) {
+				ok <- msg
+			},
+			OnBlockTxn: func(p *peer.Peer, msg *wire.MsgBlockTxn) {
+				ok <- msg
+			},
 		},
 		UserAgentName:     "peer",
 		UserAgentVersion:  "1.0",
@@ -493,6 +517,10 @@ func TestPeerListeners(t *testing.T) {
 			"OnAddr",
 			wire.NewMsgAddr(),
 		},
+		{
+			"OnAddrV2",
+			wire.NewMsgAddrV2(),
+		},
 		{
 			"OnPing",
 			wire.NewMsgPing(42),
@@ -594,6 +622,37 @@ func TestPeerListeners(t *testing.T) {
 			"OnSendHeaders",
 			wire.NewMsgSendHeaders(),
 		},
+		{
+			"OnDSQueue",
+			wire.NewMsgDSQueue(1, &wire.OutPoint{}, &chainhash.Hash{},
+				0, false),
+		},
+		{
+			"OnDSTx",
+			wire.NewMsgDSTx(wire.NewMsgTx(wire.TxVersion),
+				&wire.OutPoint{}, &chainhash.Hash{}, 0),
+		},
+		{
+			"OnSendDSQueue",
+			wire.NewMsgSendDSQueue(true),
+		},
+		{
+			"OnSendCmpct",
+			wire.NewMsgSendCmpct(false, wire.CmpctBlockVersion),
+		},
+		{
+			"OnCmpctBlock",
+			wire.NewMsgCmpctBlock(wire.NewBlockHeader(1,
+				&chainhash.Hash{}, &chainhash.Hash{}, 1, 1), 1),
+		},
+		{
+			"OnGetBlockTxn",
+			wire.NewMsgGetBlockTxn(&chainhash.Hash{}, []uint32{1}),
+		},
+		{
+			"OnBlockTxn",
+			wire.NewMsgBlockTxn(&chainhash.Hash{}),
+		},
 	}
 	t.Logf("Running %d tests", len(tests))
 	for _, test := range tests {
@@ -722,15 +781,28 @@ func TestOutboundPeer(t *testing.T) {
 	p2.AssociateConnection(c2)
 
 	// Test PushXXX
-	var addrs []*wire.NetAddress
+	var addrs []*wire.NetAddressV2
 	for i := 0; i < 5; i++ {
-		na := wire.NetAddress{}
-		addrs = append(addrs, &na)
+		na := wire.NewNetAddressV2IPPort(net.IPv4(10, 0, 0, byte(i)),
+			8333, 0)
+		addrs = append(addrs, na)
 	}
-	if _, err := p2.PushAddrMsg(addrs); err != nil {
+	addrs = append(addrs, wire.NewNetAddressV2(wire.NetTorV3,
+		make([]byte, 32), 8333, 0))
+	sent, err := p2.PushAddrMsg(addrs)
+	if err != nil {
 		t.Errorf("PushAddrMsg: unexpected err %v\n", err)
 		return
 	}
+	if len(sent) != 5 {
+		t.Errorf("PushAddrMsg: unexpected number of sent addresses - "+
+			"got %d, want 5", len(sent))
+		return
+	}
+	if _, err := p2.PushAddrV2Msg(addrs); err != nil {
+		t.Errorf("PushAddrV2Msg: unexpected err %v\n", err)
+		return
+	}
 	if err := p2.PushGetBlocksMsg(nil, &chainhash.Hash{}); err != nil {
 		t.Errorf("PushGetBlocksMsg: unexpected err %v\n", err)
 		return
@@ -917,6 +989,224 @@ func TestDuplicateVersionMsg(t *testing.T) {
 	}
 }
 
+// TestSendAddrV2Negotiation ensures peers only send a sendaddrv2 message during
+// the protocol negotiation when the remote peer advertised a protocol version
+// which supports addrv2 messages.
+func TestSendAddrV2Negotiation(t *testing.T) {
+	tests := []struct {
+		name       string
+		inVersion  uint32
+		outVersion uint32
+		wantInV2   bool // inbound peer received sendaddrv2
+		wantOutV2  bool // outbound peer received sendaddrv2
+	}{
+		{"both support addrv2", wire.AddrV2Version, wire.AddrV2Version,
+			true, true},
+		{"outbound lacks addrv2", wire.AddrV2Version, 0, true, false},
+		{"inbound lacks addrv2", 0, wire.AddrV2Version, false, true},
+	}
+
+	for _, test := range tests {
+		verack := make(chan struct{})
+		listeners := peer.MessageListeners{
+			OnVerAck: func(p *peer.Peer, msg *wire.MsgVerAck) {
+				verack <- struct{}{}
+			},
+		}
+		inCfg := &peer.Config{
+			Listeners:       listeners,
+			ChainParams:     &chaincfg.MainNetParams,
+			ProtocolVersion: test.inVersion,
+		}
+		outCfg := &peer.Config{
+			Listeners:       listeners,
+			ChainParams:     &chaincfg.MainNetParams,
+			ProtocolVersion: test.outVersion,
+		}
+		inConn, outConn := pipe(
+			&conn{laddr: "10.0.0.1:9999", raddr: "10.0.0.2:9999"},
+			&conn{laddr: "10.0.0.2:9999", raddr: "10.0.0.1:9999"},
+		)
+		outPeer, err := peer.NewOutboundPeer(outCfg, inConn.laddr)
+		if err != nil {
+			t.Fatalf("NewOutboundPeer: unexpected err: %v\n", err)
+		}
+		outPeer.AssociateConnection(outConn)
+		inPeer := peer.NewInboundPeer(inCfg)
+		inPeer.AssociateConnection(inConn)
+
+		// Wait for the veracks from the protocol version negotiation.
+		for i := 0; i < 2; i++ {
+			select {
+			case <-verack:
+			case <-time.After(time.Second):
+				t.Fatalf("%s: verack timeout", test.name)
+			}
+		}
+
+		if got := inPeer.WantsAddrV2(); got != test.wantInV2 {
+			t.Errorf("%s: inbound WantsAddrV2 - got %v, want %v",
+				test.name, got, test.wantInV2)
+		}
+		if got := outPeer.WantsAddrV2(); got != test.wantOutV2 {
+			t.Errorf("%s: outbound WantsAddrV2 - got %v, want %v",
+				test.name, got, test.wantOutV2)
+		}
+
+		inPeer.Disconnect()
+		outPeer.Disconnect()
+	}
+}
+
+// TestSendCmpct ensures peers which support compact blocks signal it once the
+// protocol is negotiated and track the compact block mode the remote peer
+// asks for.
+func TestSendCmpct(t *testing.T) {
+	sendCmpct := make(chan *wire.MsgSendCmpct, 2)
+	listeners := peer.MessageListeners{
+		OnSendCmpct: func(p *peer.Peer, msg *wire.MsgSendCmpct) {
+			sendCmpct <- msg
+		},
+	}
+	inCfg := &peer.Config{
+		Listeners:       listeners,
+		ChainParams:     &chaincfg.MainNetParams,
+		ProtocolVersion: wire.ShortIDsBlocksVersion,
+		CmpctBlocks:     true,
+	}
+	outCfg := &peer.Config{
+		Listeners:       listeners,
+		ChainParams:     &chaincfg.MainNetParams,
+		ProtocolVersion: wire.ShortIDsBlocksVersion,
+	}
+	inConn, outConn := pipe(
+		&conn{laddr: "10.0.0.1:9999", raddr: "10.0.0.2:9999"},
+		&conn{laddr: "10.0.0.2:9999", raddr: "10.0.0.1:9999"},
+	)
+	outPeer, err := peer.NewOutboundPeer(outCfg, inConn.laddr)
+	if err != nil {
+		t.Fatalf("NewOutboundPeer: unexpected err: %v\n", err)
+	}
+	outPeer.AssociateConnection(outConn)
+	inPeer := peer.NewInboundPeer(inCfg)
+	inPeer.AssociateConnection(inConn)
+	defer inPeer.Disconnect()
+	defer outPeer.Disconnect()
+
+	// Only the inbound peer supports compact blocks, so only the outbound
+	// peer receives a sendcmpct message, which asks for low bandwidth
+	// mode.
+	select {
+	case msg := <-sendCmpct:
+		if msg.AnnounceUsingCmpctBlock {
+			t.Fatalf("sendcmpct requests high bandwidth mode")
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("sendcmpct timeout")
+	}
+	if !outPeer.WantsCmpctBlocks() || outPeer.WantsHighBandwidthCmpctBlocks() {
+		t.Fatalf("outbound peer: unexpected compact block mode - "+
+			"cmpct %v, high bandwidth %v", outPeer.WantsCmpctBlocks(),
+			outPeer.WantsHighBandwidthCmpctBlocks())
+	}
+
+	tests := []struct {
+		name              string
+		msg               *wire.MsgSendCmpct
+		wantCmpct         bool
+		wantHighBandwidth bool
+	}{
+		{"unsupported version", wire.NewMsgSendCmpct(true, 2), false,
+			false},
+		{"high bandwidth", wire.NewMsgSendCmpct(true, 1), true, true},
+		{"low bandwidth", wire.NewMsgSendCmpct(false, 1), true, false},
+	}
+	for _, test := range tests {
+		outPeer.QueueMessage(test.msg, nil)
+		select {
+		case <-sendCmpct:
+		case <-time.After(time.Second):
+			t.Fatalf("%s: sendcmpct timeout", test.name)
+		}
+		if got := inPeer.WantsCmpctBlocks(); got != test.wantCmpct {
+			t.Errorf("%s: WantsCmpctBlocks - got %v, want %v",
+				test.name, got, test.wantCmpct)
+		}
+		got := inPeer.WantsHighBandwidthCmpctBlocks()
+		if got != test.wantHighBandwidth {
+			t.Errorf("%s: WantsHighBandwidthCmpctBlocks - got %v, "+
+				"want %v", test.name, got, test.wantHighBandwidth)
+		}
+	}
+}
+
+// TestV2Transport ensures peers negotiate the protocol through the v2
+// transport when both use it and that inbound peers fall back to the v1
+// transport for outbound peers which don't.
+func TestV2Transport(t *testing.T) {
+	tests := []struct {
+		name   string
+		inV2   bool
+		outV2  bool
+		wantV2 bool
+	}{
+		{"both use v2", true, true, true},
+		{"outbound uses v1", true, false, false},
+		{"neither uses v2", false, false, false},
+	}
+
+	for _, test := range tests {
+		verack := make(chan struct{})
+		listeners := peer.MessageListeners{
+			OnVerAck: func(p *peer.Peer, msg *wire.MsgVerAck) {
+				verack <- struct{}{}
+			},
+		}
+		inCfg := &peer.Config{
+			Listeners:   listeners,
+			ChainParams: &chaincfg.MainNetParams,
+			V2Transport: test.inV2,
+		}
+		outCfg := &peer.Config{
+			Listeners:   listeners,
+			ChainParams: &chaincfg.MainNetParams,
+			V2Transport: test.outV2,
+		}
+		inConn, outConn := pipe(
+			&conn{laddr: "10.0.0.1:9999", raddr: "10.0.0.2:9999"},
+			&conn{laddr: "10.0.0.2:9999", raddr: "10.0.0.1:9999"},
+		)
+		outPeer, err := peer.NewOutboundPeer(outCfg, inConn.laddr)
+		if err != nil {
+			t.Fatalf("NewOutboundPeer: unexpected err: %v\n", err)
+		}
+		outPeer.AssociateConnection(outConn)
+		inPeer := peer.NewInboundPeer(inCfg)
+		inPeer.AssociateConnection(inConn)
+
+		// Wait for the veracks from the protocol version negotiation.
+		for i := 0; i < 2; i++ {
+			select {
+			case <-verack:
+			case <-time.After(time.Second):
+				t.Fatalf("%s: verack timeout", test.name)
+			}
+		}
+
+		if got := inPeer.V2Transport(); got != test.wantV2 {
+			t.Errorf("%s: inbound V2Transport - got %v, want %v",
+				test.name, got, test.wantV2)
+		}
+		if got := outPeer.V2Transport(); got != test.wantV2 {
+			t.Errorf("%s: outbound V2Transport - got %v, want %v",
+				test.name, got, test.wantV2)
+		}
+
+		inPeer.Disconnect()
+		outPeer.Disconnect()
+	}
+}
+
 func init() {
 	// Allow self connection when running the tests.
 	peer.TstAllowSelfConns()
@@ -6,6 +6,7 @@ package main
 
 import (
 	"sync/atomic"
+	"time"
 
 	"github.com/eager7/dashd/blockchain"
 	"github.com/eager7/dashd/chaincfg/chainhash"
@@ -61,6 +62,20 @@ func (p *rpcPeer) FeeFilter() int64 {
 	return atomic.LoadInt64(&(*serverPeer)(p).feeFilter)
 }
 
+// MappedAS returns the number of the autonomous system the peer belongs to
+// according to the asmap, or 0 when it is unknown or no asmap is used.
+//
+// This function is safe for concurrent access and is part of the rpcserverPeer
+// interface implementation.
+func (p *rpcPeer) MappedAS() uint32 {
+	sp := (*serverPeer)(p)
+	na := sp.NA()
+	if na == nil {
+		return 0
+	}
+	return sp.server.addrManager.ASN(na)
+}
+
 // rpcConnManager provides a connection manager for use with the RPC server and
 // implements the rpcserverConnManager interface.
 type rpcConnManager struct {
@@ -147,6 +162,57 @@ func (cm *rpcConnManager) DisconnectByAddr(addr string) error {
 	return <-replyChan
 }
 
+// BanSubnet bans the passed subnet, IP address, or Tor or I2P host until the
+// passed time and disconnects its peers.  Attempting to ban a subnet which is
+// already banned will return an error.
+//
+// This function is safe for concurrent access and is part of the
+// rpcserverConnManager interface implementation.
+func (cm *rpcConnManager) BanSubnet(subnet string, until time.Time) error {
+	replyChan := make(chan error)
+	cm.server.query <- banSubnetMsg{
+		subnet: subnet,
+		until:  until,
+		reply:  replyChan,
+	}
+	return <-replyChan
+}
+
+// UnbanSubnet lifts the ban of the passed subnet, IP address, or Tor or I2P
+// host.  Attempting to unban a subnet which is not banned will return an
+// error.
+//
+// This function is safe for concurrent access and is part of the
+// rpcserverConnManager interface implementation.
+func (cm *rpcConnManager) UnbanSubnet(subnet string) error {
+	replyChan := make(chan error)
+	cm.server.query <- unbanSubnetMsg{
+		subnet: subnet,
+		reply:  replyChan,
+	}
+	return <-replyChan
+}
+
+// BannedSubnets returns the bans which have not expired yet.
+//
+// This function is safe for concurrent access and is part of the
+// rpcserverConnManager interface implementation.
+func (cm *rpcConnManager) BannedSubnets() []*banEntry {
+	replyChan := make(chan []*banEntry)
+	cm.server.query <- getBannedMsg{reply: replyChan}
+	return <-replyChan
+}
+
+// ClearBanned lifts all bans.
+//
+// This function is safe for concurrent access and is part of the
+// rpcserverConnManager interface implementation.
+func (cm *rpcConnManager) ClearBanned() {
+	replyChan := make(chan struct{})
+	cm.server.query <- clearBannedMsg{reply: replyChan}
+	<-replyChan
+}
+
 // ConnectedCount returns the number of currently connected peers.
 //
 // This function is safe for concurrent access and is part of the
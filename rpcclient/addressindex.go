@@ -0,0 +1,225 @@
+// Copyright (c) 2014-2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package rpcclient
+
+import (
+	"encoding/json"
+
+	"github.com/eager7/dashd/btcjson"
+	"github.com/eager7/dashd/chaincfg/chainhash"
+)
+
+// FutureGetAddressBalanceResult is a future promise to deliver the result of a
+// GetAddressBalanceAsync RPC invocation (or an applicable error).
+type FutureGetAddressBalanceResult chan *response
+
+// Receive waits for the response promised by the future and returns the
+// balance of the requested addresses.
+func (r FutureGetAddressBalanceResult) Receive() (*btcjson.GetAddressBalanceResult, error) {
+	res, err := receiveFuture(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// Unmarshal the result as a balance object.
+	var balance btcjson.GetAddressBalanceResult
+	err = json.Unmarshal(res, &balance)
+	if err != nil {
+		return nil, err
+	}
+	return &balance, nil
+}
+
+// GetAddressBalanceAsync returns an instance of a type that can be used to get
+// the result of the RPC at some future time by invoking the Receive function
+// on the returned instance.
+//
+// See GetAddressBalance for the blocking version and more details.
+func (c *Client) GetAddressBalanceAsync(addresses []string) FutureGetAddressBalanceResult {
+	cmd := btcjson.NewGetAddressBalanceCmd(addresses)
+	return c.sendCmd(cmd)
+}
+
+// GetAddressBalance returns the combined balance of the passed addresses.
+//
+// NOTE: This is a Dash Core extension and requires the server to maintain the
+// address index (--addressindex).
+func (c *Client) GetAddressBalance(addresses []string) (*btcjson.GetAddressBalanceResult, error) {
+	return c.GetAddressBalanceAsync(addresses).Receive()
+}
+
+// FutureGetAddressDeltasResult is a future promise to deliver the result of a
+// GetAddressDeltasAsync RPC invocation (or an applicable error).
+type FutureGetAddressDeltasResult chan *response
+
+// Receive waits for the response promised by the future and returns the
+// changes to the balance of the requested addresses.
+func (r FutureGetAddressDeltasResult) Receive() ([]btcjson.AddressDeltaResult, error) {
+	res, err := receiveFuture(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// Unmarshal the result as an array of delta objects.
+	var deltas []btcjson.AddressDeltaResult
+	err = json.Unmarshal(res, &deltas)
+	if err != nil {
+		return nil, err
+	}
+	return deltas, nil
+}
+
+// GetAddressDeltasAsync returns an instance of a type that can be used to get
+// the result of the RPC at some future time by invoking the Receive function
+// on the returned instance.
+//
+// See GetAddressDeltas for the blocking version and more details.
+func (c *Client) GetAddressDeltasAsync(addresses []string, start, end *int32) FutureGetAddressDeltasResult {
+	cmd := btcjson.NewGetAddressDeltasCmd(addresses, start, end, nil)
+	return c.sendCmd(cmd)
+}
+
+// GetAddressDeltas returns the changes to the balance of the passed addresses.
+// The changes are limited to the blocks from the start to the end height when
+// both are given.
+//
+// NOTE: This is a Dash Core extension and requires the server to maintain the
+// address index (--addressindex).
+func (c *Client) GetAddressDeltas(addresses []string, start, end *int32) ([]btcjson.AddressDeltaResult, error) {
+	return c.GetAddressDeltasAsync(addresses, start, end).Receive()
+}
+
+// FutureGetAddressMempoolResult is a future promise to deliver the result of a
+// GetAddressMempoolAsync RPC invocation (or an applicable error).
+type FutureGetAddressMempoolResult chan *response
+
+// Receive waits for the response promised by the future and returns the
+// changes to the balance of the requested addresses by unconfirmed
+// transactions.
+func (r FutureGetAddressMempoolResult) Receive() ([]btcjson.AddressMempoolResult, error) {
+	res, err := receiveFuture(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// Unmarshal the result as an array of mempool delta objects.
+	var deltas []btcjson.AddressMempoolResult
+	err = json.Unmarshal(res, &deltas)
+	if err != nil {
+		return nil, err
+	}
+	return deltas, nil
+}
+
+// GetAddressMempoolAsync returns an instance of a type that can be used to get
+// the result of the RPC at some future time by invoking the Receive function
+// on the returned instance.
+//
+// See GetAddressMempool for the blocking version and more details.
+func (c *Client) GetAddressMempoolAsync(addresses []string) FutureGetAddressMempoolResult {
+	cmd := btcjson.NewGetAddressMempoolCmd(addresses)
+	return c.sendCmd(cmd)
+}
+
+// GetAddressMempool returns the changes to the balance of the passed addresses
+// by the transactions in the memory pool of the server.
+//
+// NOTE: This is a Dash Core extension and requires the server to maintain the
+// address index (--addressindex).
+func (c *Client) GetAddressMempool(addresses []string) ([]btcjson.AddressMempoolResult, error) {
+	return c.GetAddressMempoolAsync(addresses).Receive()
+}
+
+// FutureGetAddressTxIDsResult is a future promise to deliver the result of a
+// GetAddressTxIDsAsync RPC invocation (or an applicable error).
+type FutureGetAddressTxIDsResult chan *response
+
+// Receive waits for the response promised by the future and returns the hashes
+// of the transactions involving the requested addresses.
+func (r FutureGetAddressTxIDsResult) Receive() ([]*chainhash.Hash, error) {
+	res, err := receiveFuture(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// Unmarshal the result as an array of strings.
+	var txHashStrs []string
+	err = json.Unmarshal(res, &txHashStrs)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create a slice of hashes from the string slice.
+	txHashes := make([]*chainhash.Hash, 0, len(txHashStrs))
+	for _, hashStr := range txHashStrs {
+		txHash, err := chainhash.NewHashFromStr(hashStr)
+		if err != nil {
+			return nil, err
+		}
+		txHashes = append(txHashes, txHash)
+	}
+
+	return txHashes, nil
+}
+
+// GetAddressTxIDsAsync returns an instance of a type that can be used to get
+// the result of the RPC at some future time by invoking the Receive function
+// on the returned instance.
+//
+// See GetAddressTxIDs for the blocking version and more details.
+func (c *Client) GetAddressTxIDsAsync(addresses []string, start, end *int32) FutureGetAddressTxIDsResult {
+	cmd := btcjson.NewGetAddressTxIDsCmd(addresses, start, end)
+	return c.sendCmd(cmd)
+}
+
+// GetAddressTxIDs returns the hashes of the transactions involving the passed
+// addresses in the order they appear in the chain.  The transactions are
+// limited to the blocks from the start to the end height when both are given.
+//
+// NOTE: This is a Dash Core extension and requires the server to maintain the
+// address index (--addressindex).
+func (c *Client) GetAddressTxIDs(addresses []string, start, end *int32) ([]*chainhash.Hash, error) {
+	return c.GetAddressTxIDsAsync(addresses, start, end).Receive()
+}
+
+// FutureGetAddressUtxosResult is a future promise to deliver the result of a
+// GetAddressUtxosAsync RPC invocation (or an applicable error).
+type FutureGetAddressUtxosResult chan *response
+
+// Receive waits for the response promised by the future and returns the
+// unspent outputs paying the requested addresses.
+func (r FutureGetAddressUtxosResult) Receive() ([]btcjson.AddressUtxoResult, error) {
+	res, err := receiveFuture(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// Unmarshal the result as an array of unspent output objects.
+	var utxos []btcjson.AddressUtxoResult
+	err = json.Unmarshal(res, &utxos)
+	if err != nil {
+		return nil, err
+	}
+	return utxos, nil
+}
+
+// GetAddressUtxosAsync returns an instance of a type that can be used to get
+// the result of the RPC at some future time by invoking the Receive function
+// on the returned instance.
+//
+// See GetAddressUtxos for the blocking version and more details.
+func (c *Client) GetAddressUtxosAsync(addresses []string) FutureGetAddressUtxosResult {
+	cmd := btcjson.NewGetAddressUtxosCmd(addresses, nil)
+	return c.sendCmd(cmd)
+}
+
+// GetAddressUtxos returns the unspent outputs paying the passed addresses
+// ordered by the height of the block they were created in.
+//
+// NOTE: This is a Dash Core extension and requires the server to maintain the
+// address index (--addressindex).
+func (c *Client) GetAddressUtxos(addresses []string) ([]btcjson.AddressUtxoResult, error) {
+	return c.GetAddressUtxosAsync(addresses).Receive()
+}
@@ -399,6 +399,59 @@ func (c *Client) GetBlockHash(blockHeight int64) (*chainhash.Hash, error) {
 	return c.GetBlockHashAsync(blockHeight).Receive()
 }
 
+// FutureGetBlockHashesResult is a future promise to deliver the result of a
+// GetBlockHashesAsync RPC invocation (or an applicable error).
+type FutureGetBlockHashesResult chan *response
+
+// Receive waits for the response promised by the future and returns the
+// hashes of the blocks in the requested range of time.
+func (r FutureGetBlockHashesResult) Receive() ([]*chainhash.Hash, error) {
+	res, err := receiveFuture(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// Unmarshal the result as an array of strings.
+	var hashStrs []string
+	err = json.Unmarshal(res, &hashStrs)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create a slice of hashes from the string slice.
+	hashes := make([]*chainhash.Hash, 0, len(hashStrs))
+	for _, hashStr := range hashStrs {
+		hash, err := chainhash.NewHashFromStr(hashStr)
+		if err != nil {
+			return nil, err
+		}
+		hashes = append(hashes, hash)
+	}
+
+	return hashes, nil
+}
+
+// GetBlockHashesAsync returns an instance of a type that can be used to get
+// the result of the RPC at some future time by invoking the Receive function on
+// the returned instance.
+//
+// See GetBlockHashes for the blocking version and more details.
+func (c *Client) GetBlockHashesAsync(high, low uint32, noOrphans bool) FutureGetBlockHashesResult {
+	cmd := btcjson.NewGetBlockHashesCmd(high, low,
+		&btcjson.GetBlockHashesOptions{NoOrphans: &noOrphans})
+	return c.sendCmd(cmd)
+}
+
+// GetBlockHashes returns the hashes of the blocks with a logical timestamp
+// from low, inclusive, to high, exclusive.  Blocks which are not in the main
+// chain are omitted when noOrphans is set.
+//
+// NOTE: This is a Dash Core extension and requires the server to maintain the
+// timestamp index (--timestampindex).
+func (c *Client) GetBlockHashes(high, low uint32, noOrphans bool) ([]*chainhash.Hash, error) {
+	return c.GetBlockHashesAsync(high, low, noOrphans).Receive()
+}
+
 // FutureGetBlockHeaderResult is a future promise to deliver the result of a
 // GetBlockHeaderAsync RPC invocation (or an applicable error).
 type FutureGetBlockHeaderResult chan *response
@@ -842,6 +895,93 @@ func (c *Client) GetTxOut(txHash *chainhash.Hash, index uint32, mempool bool) (*
 	return c.GetTxOutAsync(txHash, index, mempool).Receive()
 }
 
+// FutureGetTxOutSetInfoResult is a future promise to deliver the result of a
+// GetTxOutSetInfoAsync RPC invocation (or an applicable error).
+type FutureGetTxOutSetInfoResult chan *response
+
+// Receive waits for the response promised by the future and returns the
+// statistics of the utxo set.
+func (r FutureGetTxOutSetInfoResult) Receive() (*btcjson.GetTxOutSetInfoResult, error) {
+	res, err := receiveFuture(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// Unmarshal result as a gettxoutsetinfo result object.
+	var info btcjson.GetTxOutSetInfoResult
+	err = json.Unmarshal(res, &info)
+	if err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// GetTxOutSetInfoAsync returns an instance of a type that can be used to get
+// the result of the RPC at some future time by invoking the Receive function on
+// the returned instance.
+//
+// See GetTxOutSetInfo for the blocking version and more details.
+func (c *Client) GetTxOutSetInfoAsync(blockHash *chainhash.Hash) FutureGetTxOutSetInfoResult {
+	var hashOrHeight *btcjson.HashOrHeight
+	if blockHash != nil {
+		hashOrHeight = &btcjson.HashOrHeight{Value: blockHash.String()}
+	}
+	cmd := btcjson.NewGetTxOutSetInfoCmd(nil, hashOrHeight)
+	return c.sendCmd(cmd)
+}
+
+// GetTxOutSetInfo returns the statistics of the utxo set, including its
+// MuHash3072, as of the block with the given hash.  The statistics as of the
+// best block are returned when the hash is nil.
+func (c *Client) GetTxOutSetInfo(blockHash *chainhash.Hash) (*btcjson.GetTxOutSetInfoResult, error) {
+	return c.GetTxOutSetInfoAsync(blockHash).Receive()
+}
+
+// FutureGetSpentInfoResult is a future promise to deliver the result of a
+// GetSpentInfoAsync RPC invocation (or an applicable error).
+type FutureGetSpentInfoResult chan *response
+
+// Receive waits for the response promised by the future and returns the input
+// spending the requested transaction output.
+func (r FutureGetSpentInfoResult) Receive() (*btcjson.GetSpentInfoResult, error) {
+	res, err := receiveFuture(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// Unmarshal result as a getspentinfo result object.
+	var spentInfo btcjson.GetSpentInfoResult
+	err = json.Unmarshal(res, &spentInfo)
+	if err != nil {
+		return nil, err
+	}
+
+	return &spentInfo, nil
+}
+
+// GetSpentInfoAsync returns an instance of a type that can be used to get
+// the result of the RPC at some future time by invoking the Receive function on
+// the returned instance.
+//
+// See GetSpentInfo for the blocking version and more details.
+func (c *Client) GetSpentInfoAsync(txHash *chainhash.Hash, index uint32) FutureGetSpentInfoResult {
+	hash := ""
+	if txHash != nil {
+		hash = txHash.String()
+	}
+
+	cmd := btcjson.NewGetSpentInfoCmd(hash, index)
+	return c.sendCmd(cmd)
+}
+
+// GetSpentInfo returns the input spending the transaction output.
+//
+// NOTE: This is a Dash Core extension and requires the server to maintain the
+// spent index (--spentindex).
+func (c *Client) GetSpentInfo(txHash *chainhash.Hash, index uint32) (*btcjson.GetSpentInfoResult, error) {
+	return c.GetSpentInfoAsync(txHash, index).Receive()
+}
+
 // FutureRescanBlocksResult is a future promise to deliver the result of a
 // RescanBlocksAsync RPC invocation (or an applicable error).
 //
@@ -1078,3 +1218,117 @@ func (c *Client) GetBlockStatsAsync(hashOrHeight interface{}, stats *[]string) F
 func (c *Client) GetBlockStats(hashOrHeight interface{}, stats *[]string) (*btcjson.GetBlockStatsResult, error) {
 	return c.GetBlockStatsAsync(hashOrHeight, stats).Receive()
 }
+
+// FutureGetAssetUnlockStatusesResult is a future promise to deliver the result
+// of a GetAssetUnlockStatusesAsync RPC invocation (or an applicable error).
+type FutureGetAssetUnlockStatusesResult chan *response
+
+// Receive waits for the response promised by the future and returns the
+// statuses of the requested asset unlock withdrawal indexes.
+func (r FutureGetAssetUnlockStatusesResult) Receive() ([]btcjson.AssetUnlockStatusResult, error) {
+	res, err := receiveFuture(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// Unmarshal the result as an array of asset unlock status objects.
+	var statuses []btcjson.AssetUnlockStatusResult
+	err = json.Unmarshal(res, &statuses)
+	if err != nil {
+		return nil, err
+	}
+	return statuses, nil
+}
+
+// GetAssetUnlockStatusesAsync returns an instance of a type that can be used
+// to get the result of the RPC at some future time by invoking the Receive
+// function on the returned instance.
+//
+// See GetAssetUnlockStatuses for the blocking version and more details.
+func (c *Client) GetAssetUnlockStatusesAsync(indexes []uint64, height *int32) FutureGetAssetUnlockStatusesResult {
+	cmd := btcjson.NewGetAssetUnlockStatusesCmd(indexes, height)
+	return c.sendCmd(cmd)
+}
+
+// GetAssetUnlockStatuses returns whether the asset unlocks with the passed
+// withdrawal indexes are mined, in the memory pool or unknown.  When height is
+// not nil, the statuses are reported as of that height and the memory pool is
+// not considered.
+func (c *Client) GetAssetUnlockStatuses(indexes []uint64, height *int32) ([]btcjson.AssetUnlockStatusResult, error) {
+	return c.GetAssetUnlockStatusesAsync(indexes, height).Receive()
+}
+
+// FutureDumpTxOutSetResult is a future promise to deliver the result of a
+// DumpTxOutSetAsync RPC invocation (or an applicable error).
+type FutureDumpTxOutSetResult chan *response
+
+// Receive waits for the response promised by the future and returns
+// information about the written utxo set snapshot.
+func (r FutureDumpTxOutSetResult) Receive() (*btcjson.DumpTxOutSetResult, error) {
+	res, err := receiveFuture(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// Unmarshal result as a dumptxoutset result object.
+	var dumpResult btcjson.DumpTxOutSetResult
+	err = json.Unmarshal(res, &dumpResult)
+	if err != nil {
+		return nil, err
+	}
+	return &dumpResult, nil
+}
+
+// DumpTxOutSetAsync returns an instance of a type that can be used to get the
+// result of the RPC at some future time by invoking the Receive function on the
+// returned instance.
+//
+// See DumpTxOutSet for the blocking version and more details.
+func (c *Client) DumpTxOutSetAsync(path string) FutureDumpTxOutSetResult {
+	cmd := btcjson.NewDumpTxOutSetCmd(path)
+	return c.sendCmd(cmd)
+}
+
+// DumpTxOutSet writes a snapshot of the utxo set at the best block of the
+// server to the passed path, which is relative to its data directory unless
+// absolute.
+func (c *Client) DumpTxOutSet(path string) (*btcjson.DumpTxOutSetResult, error) {
+	return c.DumpTxOutSetAsync(path).Receive()
+}
+
+// FutureLoadTxOutSetResult is a future promise to deliver the result of a
+// LoadTxOutSetAsync RPC invocation (or an applicable error).
+type FutureLoadTxOutSetResult chan *response
+
+// Receive waits for the response promised by the future and returns
+// information about the loaded utxo set snapshot.
+func (r FutureLoadTxOutSetResult) Receive() (*btcjson.LoadTxOutSetResult, error) {
+	res, err := receiveFuture(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// Unmarshal result as a loadtxoutset result object.
+	var loadResult btcjson.LoadTxOutSetResult
+	err = json.Unmarshal(res, &loadResult)
+	if err != nil {
+		return nil, err
+	}
+	return &loadResult, nil
+}
+
+// LoadTxOutSetAsync returns an instance of a type that can be used to get the
+// result of the RPC at some future time by invoking the Receive function on the
+// returned instance.
+//
+// See LoadTxOutSet for the blocking version and more details.
+func (c *Client) LoadTxOutSetAsync(path string) FutureLoadTxOutSetResult {
+	cmd := btcjson.NewLoadTxOutSetCmd(path)
+	return c.sendCmd(cmd)
+}
+
+// LoadTxOutSet loads the utxo set snapshot at the passed path into a server
+// which has not processed any blocks.
+func (c *Client) LoadTxOutSet(path string) (*btcjson.LoadTxOutSetResult, error) {
+	return c.LoadTxOutSetAsync(path).Receive()
+}
@@ -0,0 +1,367 @@
+// Copyright (c) 2014-2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package rpcclient
+
+import (
+	"encoding/json"
+
+	"github.com/eager7/dashd/btcjson"
+	"github.com/eager7/dashd/chaincfg/chainhash"
+)
+
+// FutureProTxListResult is a future promise to deliver the result of a
+// ProTxListAsync RPC invocation (or an applicable error).
+type FutureProTxListResult chan *response
+
+// Receive waits for the response promised by the future and returns the
+// hashes of the provider registrations of the masternodes in the list.
+func (r FutureProTxListResult) Receive() ([]*chainhash.Hash, error) {
+	res, err := receiveFuture(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// Unmarshal the result as an array of strings.
+	var txHashStrs []string
+	err = json.Unmarshal(res, &txHashStrs)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create a slice of hashes from the string slice.
+	txHashes := make([]*chainhash.Hash, 0, len(txHashStrs))
+	for _, hashStr := range txHashStrs {
+		txHash, err := chainhash.NewHashFromStr(hashStr)
+		if err != nil {
+			return nil, err
+		}
+		txHashes = append(txHashes, txHash)
+	}
+
+	return txHashes, nil
+}
+
+// ProTxListAsync returns an instance of a type that can be used to get the
+// result of the RPC at some future time by invoking the Receive function on
+// the returned instance.
+//
+// See ProTxList for the blocking version and more details.
+func (c *Client) ProTxListAsync(listType *string, height *int32) FutureProTxListResult {
+	cmd := btcjson.NewProTxListCmd(listType, nil, height)
+	return c.sendCmd(cmd)
+}
+
+// ProTxList returns the hashes of the provider registrations of the
+// masternodes in the masternode list.  The list type is one of "registered",
+// "valid" or "evo", and the list is that of the best block unless a height is
+// given.
+func (c *Client) ProTxList(listType *string, height *int32) ([]*chainhash.Hash, error) {
+	return c.ProTxListAsync(listType, height).Receive()
+}
+
+// FutureProTxListDetailedResult is a future promise to deliver the result of
+// a ProTxListDetailedAsync RPC invocation (or an applicable error).
+type FutureProTxListDetailedResult chan *response
+
+// Receive waits for the response promised by the future and returns the
+// details of the masternodes in the list.
+func (r FutureProTxListDetailedResult) Receive() ([]btcjson.ProTxInfoResult, error) {
+	res, err := receiveFuture(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// Unmarshal the result as an array of protx info objects.
+	var infos []btcjson.ProTxInfoResult
+	err = json.Unmarshal(res, &infos)
+	if err != nil {
+		return nil, err
+	}
+	return infos, nil
+}
+
+// ProTxListDetailedAsync returns an instance of a type that can be used to
+// get the result of the RPC at some future time by invoking the Receive
+// function on the returned instance.
+//
+// See ProTxListDetailed for the blocking version and more details.
+func (c *Client) ProTxListDetailedAsync(listType *string, height *int32) FutureProTxListDetailedResult {
+	cmd := btcjson.NewProTxListCmd(listType, btcjson.Bool(true), height)
+	return c.sendCmd(cmd)
+}
+
+// ProTxListDetailed returns the details of the masternodes in the masternode
+// list.  See ProTxList for the meaning of the parameters.
+func (c *Client) ProTxListDetailed(listType *string, height *int32) ([]btcjson.ProTxInfoResult, error) {
+	return c.ProTxListDetailedAsync(listType, height).Receive()
+}
+
+// FutureProTxInfoResult is a future promise to deliver the result of a
+// ProTxInfoAsync RPC invocation (or an applicable error).
+type FutureProTxInfoResult chan *response
+
+// Receive waits for the response promised by the future and returns the
+// details of the requested masternode.
+func (r FutureProTxInfoResult) Receive() (*btcjson.ProTxInfoResult, error) {
+	res, err := receiveFuture(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// Unmarshal the result as a protx info object.
+	var info btcjson.ProTxInfoResult
+	err = json.Unmarshal(res, &info)
+	if err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// ProTxInfoAsync returns an instance of a type that can be used to get the
+// result of the RPC at some future time by invoking the Receive function on
+// the returned instance.
+//
+// See ProTxInfo for the blocking version and more details.
+func (c *Client) ProTxInfoAsync(proTxHash *chainhash.Hash, blockHash *chainhash.Hash) FutureProTxInfoResult {
+	var blockHashStr *string
+	if blockHash != nil {
+		blockHashStr = btcjson.String(blockHash.String())
+	}
+	cmd := btcjson.NewProTxInfoCmd(proTxHash.String(), blockHashStr)
+	return c.sendCmd(cmd)
+}
+
+// ProTxInfo returns the details of the masternode registered by the passed
+// provider registration as of the best block, or as of the passed block when
+// it is not nil.
+func (c *Client) ProTxInfo(proTxHash *chainhash.Hash, blockHash *chainhash.Hash) (*btcjson.ProTxInfoResult, error) {
+	return c.ProTxInfoAsync(proTxHash, blockHash).Receive()
+}
+
+// FutureProTxDiffResult is a future promise to deliver the result of a
+// ProTxDiffAsync RPC invocation (or an applicable error).
+type FutureProTxDiffResult chan *response
+
+// Receive waits for the response promised by the future and returns the
+// changes of the simplified masternode list between the requested blocks.
+func (r FutureProTxDiffResult) Receive() (*btcjson.ProTxDiffResult, error) {
+	res, err := receiveFuture(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// Unmarshal the result as a protx diff object.
+	var diff btcjson.ProTxDiffResult
+	err = json.Unmarshal(res, &diff)
+	if err != nil {
+		return nil, err
+	}
+	return &diff, nil
+}
+
+// ProTxDiffAsync returns an instance of a type that can be used to get the
+// result of the RPC at some future time by invoking the Receive function on
+// the returned instance.
+//
+// See ProTxDiff for the blocking version and more details.
+func (c *Client) ProTxDiffAsync(baseBlock, block btcjson.HashOrHeight, extended bool) FutureProTxDiffResult {
+	cmd := btcjson.NewProTxDiffCmd(baseBlock, block, &extended)
+	return c.sendCmd(cmd)
+}
+
+// ProTxDiff returns the changes of the simplified masternode list between the
+// passed blocks, which are given either by hash or by height.  The payout
+// addresses of the entries are only included when extended is set.
+func (c *Client) ProTxDiff(baseBlock, block btcjson.HashOrHeight, extended bool) (*btcjson.ProTxDiffResult, error) {
+	return c.ProTxDiffAsync(baseBlock, block, extended).Receive()
+}
+
+// FutureMasternodeListResult is a future promise to deliver the result of a
+// MasternodeListAsync RPC invocation (or an applicable error).
+type FutureMasternodeListResult chan *response
+
+// Receive waits for the response promised by the future and returns the
+// requested information of the masternodes keyed by their collateral outpoint.
+func (r FutureMasternodeListResult) Receive() (map[string]string, error) {
+	res, err := receiveFuture(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// Unmarshal the result as an object of strings.
+	var list map[string]string
+	err = json.Unmarshal(res, &list)
+	if err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// MasternodeListAsync returns an instance of a type that can be used to get
+// the result of the RPC at some future time by invoking the Receive function
+// on the returned instance.
+//
+// See MasternodeList for the blocking version and more details.
+func (c *Client) MasternodeListAsync(mode string, filter *string) FutureMasternodeListResult {
+	cmd := btcjson.NewMasternodeListCmd(&mode, filter)
+	return c.sendCmd(cmd)
+}
+
+// MasternodeList returns a single piece of information of each masternode,
+// keyed by its collateral outpoint in txid-n form.  The mode is one of "addr",
+// "payee", "status" or "pubkeyoperator".  Use MasternodeListJSON for the
+// details of the masternodes.
+func (c *Client) MasternodeList(mode string, filter *string) (map[string]string, error) {
+	return c.MasternodeListAsync(mode, filter).Receive()
+}
+
+// FutureMasternodeListJSONResult is a future promise to deliver the result of
+// a MasternodeListJSONAsync RPC invocation (or an applicable error).
+type FutureMasternodeListJSONResult chan *response
+
+// Receive waits for the response promised by the future and returns the
+// details of the masternodes keyed by their collateral outpoint.
+func (r FutureMasternodeListJSONResult) Receive() (map[string]btcjson.MasternodeListResult, error) {
+	res, err := receiveFuture(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// Unmarshal the result as an object of masternode objects.
+	var list map[string]btcjson.MasternodeListResult
+	err = json.Unmarshal(res, &list)
+	if err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// MasternodeListJSONAsync returns an instance of a type that can be used to
+// get the result of the RPC at some future time by invoking the Receive
+// function on the returned instance.
+//
+// See MasternodeListJSON for the blocking version and more details.
+func (c *Client) MasternodeListJSONAsync(filter *string) FutureMasternodeListJSONResult {
+	cmd := btcjson.NewMasternodeListCmd(btcjson.String("json"), filter)
+	return c.sendCmd(cmd)
+}
+
+// MasternodeListJSON returns the details of the masternodes keyed by their
+// collateral outpoint in txid-n form.
+func (c *Client) MasternodeListJSON(filter *string) (map[string]btcjson.MasternodeListResult, error) {
+	return c.MasternodeListJSONAsync(filter).Receive()
+}
+
+// FutureMasternodeCountResult is a future promise to deliver the result of a
+// MasternodeCountAsync RPC invocation (or an applicable error).
+type FutureMasternodeCountResult chan *response
+
+// Receive waits for the response promised by the future and returns the
+// number of masternodes.
+func (r FutureMasternodeCountResult) Receive() (*btcjson.MasternodeCountResult, error) {
+	res, err := receiveFuture(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// Unmarshal the result as a masternode count object.
+	var count btcjson.MasternodeCountResult
+	err = json.Unmarshal(res, &count)
+	if err != nil {
+		return nil, err
+	}
+	return &count, nil
+}
+
+// MasternodeCountAsync returns an instance of a type that can be used to get
+// the result of the RPC at some future time by invoking the Receive function
+// on the returned instance.
+//
+// See MasternodeCount for the blocking version and more details.
+func (c *Client) MasternodeCountAsync() FutureMasternodeCountResult {
+	cmd := btcjson.NewMasternodeCmd(btcjson.MasternodeCount, nil, nil)
+	return c.sendCmd(cmd)
+}
+
+// MasternodeCount returns the total number of masternodes and the number of
+// masternodes which are not banned, both overall and per type.
+func (c *Client) MasternodeCount() (*btcjson.MasternodeCountResult, error) {
+	return c.MasternodeCountAsync().Receive()
+}
+
+// FutureMasternodeWinnersResult is a future promise to deliver the result of
+// a MasternodeWinnersAsync RPC invocation (or an applicable error).
+type FutureMasternodeWinnersResult chan *response
+
+// Receive waits for the response promised by the future and returns the
+// payees keyed by block height.
+func (r FutureMasternodeWinnersResult) Receive() (map[string]string, error) {
+	res, err := receiveFuture(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// Unmarshal the result as an object of strings.
+	var winners map[string]string
+	err = json.Unmarshal(res, &winners)
+	if err != nil {
+		return nil, err
+	}
+	return winners, nil
+}
+
+// MasternodeWinnersAsync returns an instance of a type that can be used to
+// get the result of the RPC at some future time by invoking the Receive
+// function on the returned instance.
+//
+// See MasternodeWinners for the blocking version and more details.
+func (c *Client) MasternodeWinnersAsync(count *int, filter *string) FutureMasternodeWinnersResult {
+	cmd := btcjson.NewMasternodeCmd(btcjson.MasternodeWinners, count, filter)
+	return c.sendCmd(cmd)
+}
+
+// MasternodeWinners returns the payees of the passed number of most recent
+// blocks and the projected payees of the upcoming blocks, keyed by block
+// height.
+func (c *Client) MasternodeWinners(count *int, filter *string) (map[string]string, error) {
+	return c.MasternodeWinnersAsync(count, filter).Receive()
+}
+
+// FutureGetBestChainLockResult is a future promise to deliver the result of a
+// GetBestChainLockAsync RPC invocation (or an applicable error).
+type FutureGetBestChainLockResult chan *response
+
+// Receive waits for the response promised by the future and returns the best
+// chainlock.
+func (r FutureGetBestChainLockResult) Receive() (*btcjson.GetBestChainLockResult, error) {
+	res, err := receiveFuture(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// Unmarshal the result as a chainlock object.
+	var chainLock btcjson.GetBestChainLockResult
+	err = json.Unmarshal(res, &chainLock)
+	if err != nil {
+		return nil, err
+	}
+	return &chainLock, nil
+}
+
+// GetBestChainLockAsync returns an instance of a type that can be used to get
+// the result of the RPC at some future time by invoking the Receive function
+// on the returned instance.
+//
+// See GetBestChainLock for the blocking version and more details.
+func (c *Client) GetBestChainLockAsync() FutureGetBestChainLockResult {
+	cmd := btcjson.NewGetBestChainLockCmd()
+	return c.sendCmd(cmd)
+}
+
+// GetBestChainLock returns the most recent chainlocked block known to the
+// server.
+func (c *Client) GetBestChainLock() (*btcjson.GetBestChainLockResult, error) {
+	return c.GetBestChainLockAsync().Receive()
+}
@@ -354,3 +354,141 @@ func (c *Client) GetNetTotalsAsync() FutureGetNetTotalsResult {
 func (c *Client) GetNetTotals() (*btcjson.GetNetTotalsResult, error) {
 	return c.GetNetTotalsAsync().Receive()
 }
+
+// FutureDisconnectNodeResult is a future promise to deliver the result of a
+// DisconnectNodeAsync or DisconnectNodeByIDAsync RPC invocation (or an
+// applicable error).
+type FutureDisconnectNodeResult chan *response
+
+// Receive waits for the response promised by the future and returns an error if
+// the peer could not be disconnected.
+func (r FutureDisconnectNodeResult) Receive() error {
+	_, err := receiveFuture(r)
+	return err
+}
+
+// DisconnectNodeAsync returns an instance of a type that can be used to get the
+// result of the RPC at some future time by invoking the Receive function on the
+// returned instance.
+//
+// See DisconnectNode for the blocking version and more details.
+func (c *Client) DisconnectNodeAsync(address string) FutureDisconnectNodeResult {
+	cmd := btcjson.NewDisconnectNodeCmd(&address, nil)
+	return c.sendCmd(cmd)
+}
+
+// DisconnectNode disconnects the non-persistent peer with the passed address.
+func (c *Client) DisconnectNode(address string) error {
+	return c.DisconnectNodeAsync(address).Receive()
+}
+
+// DisconnectNodeByIDAsync returns an instance of a type that can be used to get
+// the result of the RPC at some future time by invoking the Receive function on
+// the returned instance.
+//
+// See DisconnectNodeByID for the blocking version and more details.
+func (c *Client) DisconnectNodeByIDAsync(nodeID int32) FutureDisconnectNodeResult {
+	cmd := btcjson.NewDisconnectNodeCmd(btcjson.String(""), &nodeID)
+	return c.sendCmd(cmd)
+}
+
+// DisconnectNodeByID disconnects the non-persistent peer with the passed ID.
+func (c *Client) DisconnectNodeByID(nodeID int32) error {
+	return c.DisconnectNodeByIDAsync(nodeID).Receive()
+}
+
+// FutureSetBanResult is a future promise to deliver the result of a SetBanAsync
+// RPC invocation (or an applicable error).
+type FutureSetBanResult chan *response
+
+// Receive waits for the response promised by the future and returns an error if
+// any occurred when performing the specified command.
+func (r FutureSetBanResult) Receive() error {
+	_, err := receiveFuture(r)
+	return err
+}
+
+// SetBanAsync returns an instance of a type that can be used to get the result
+// of the RPC at some future time by invoking the Receive function on the
+// returned instance.
+//
+// See SetBan for the blocking version and more details.
+func (c *Client) SetBanAsync(subnet string, command btcjson.SetBanSubCmd,
+	banTime *int64, absolute *bool) FutureSetBanResult {
+
+	cmd := btcjson.NewSetBanCmd(subnet, command, banTime, absolute)
+	return c.sendCmd(cmd)
+}
+
+// SetBan bans the passed IP address or subnet in CIDR notation, or lifts its
+// ban.  The ban lasts for the passed number of seconds, or until the passed
+// unix time when absolute is set.  Passing nil for the ban time uses the ban
+// duration configured on the server.
+func (c *Client) SetBan(subnet string, command btcjson.SetBanSubCmd,
+	banTime *int64, absolute *bool) error {
+
+	return c.SetBanAsync(subnet, command, banTime, absolute).Receive()
+}
+
+// FutureListBannedResult is a future promise to deliver the result of a
+// ListBannedAsync RPC invocation (or an applicable error).
+type FutureListBannedResult chan *response
+
+// Receive waits for the response promised by the future and returns the banned
+// IP addresses and subnets.
+func (r FutureListBannedResult) Receive() ([]btcjson.ListBannedResult, error) {
+	res, err := receiveFuture(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// Unmarshal result as an array of listbanned result objects.
+	var bans []btcjson.ListBannedResult
+	err = json.Unmarshal(res, &bans)
+	if err != nil {
+		return nil, err
+	}
+
+	return bans, nil
+}
+
+// ListBannedAsync returns an instance of a type that can be used to get the
+// result of the RPC at some future time by invoking the Receive function on the
+// returned instance.
+//
+// See ListBanned for the blocking version and more details.
+func (c *Client) ListBannedAsync() FutureListBannedResult {
+	cmd := btcjson.NewListBannedCmd()
+	return c.sendCmd(cmd)
+}
+
+// ListBanned returns the banned IP addresses and subnets.
+func (c *Client) ListBanned() ([]btcjson.ListBannedResult, error) {
+	return c.ListBannedAsync().Receive()
+}
+
+// FutureClearBannedResult is a future promise to deliver the result of a
+// ClearBannedAsync RPC invocation (or an applicable error).
+type FutureClearBannedResult chan *response
+
+// Receive waits for the response promised by the future and returns an error if
+// any occurred when lifting the bans.
+func (r FutureClearBannedResult) Receive() error {
+	_, err := receiveFuture(r)
+	return err
+}
+
+// ClearBannedAsync returns an instance of a type that can be used to get the
+// result of the RPC at some future time by invoking the Receive function on the
+// returned instance.
+//
+// See ClearBanned for the blocking version and more details.
+func (c *Client) ClearBannedAsync() FutureClearBannedResult {
+	cmd := btcjson.NewClearBannedCmd()
+	return c.sendCmd(cmd)
+}
+
+// ClearBanned lifts all bans of IP addresses and subnets.
+func (c *Client) ClearBanned() error {
+	return c.ClearBannedAsync().Receive()
+}
@@ -2013,6 +2013,46 @@ func (c *Client) SignMessage(address dashutil.Address, message string) (string,
 	return c.SignMessageAsync(address, message).Receive()
 }
 
+// FutureSignMessageWithPrivKeyResult is a future promise to deliver the result
+// of a SignMessageWithPrivKeyAsync RPC invocation (or an applicable error).
+type FutureSignMessageWithPrivKeyResult chan *response
+
+// Receive waits for the response promised by the future and returns the message
+// signed with the provided private key.
+func (r FutureSignMessageWithPrivKeyResult) Receive() (string, error) {
+	res, err := receiveFuture(r)
+	if err != nil {
+		return "", err
+	}
+
+	// Unmarshal result as a string.
+	var b64 string
+	err = json.Unmarshal(res, &b64)
+	if err != nil {
+		return "", err
+	}
+
+	return b64, nil
+}
+
+// SignMessageWithPrivKeyAsync returns an instance of a type that can be used to
+// get the result of the RPC at some future time by invoking the Receive
+// function on the returned instance.
+//
+// See SignMessageWithPrivKey for the blocking version and more details.
+func (c *Client) SignMessageWithPrivKeyAsync(privKey *dashutil.WIF, message string) FutureSignMessageWithPrivKeyResult {
+	cmd := btcjson.NewSignMessageWithPrivKeyCmd(privKey.String(), message)
+	return c.sendCmd(cmd)
+}
+
+// SignMessageWithPrivKey signs a message with the provided private key.
+//
+// NOTE: This is handled by the chain server rather than the wallet, so the
+// private key is sent over the RPC connection.
+func (c *Client) SignMessageWithPrivKey(privKey *dashutil.WIF, message string) (string, error) {
+	return c.SignMessageWithPrivKeyAsync(privKey, message).Receive()
+}
+
 // FutureVerifyMessageResult is a future promise to deliver the result of a
 // VerifyMessageAsync RPC invocation (or an applicable error).
 type FutureVerifyMessageResult chan *response
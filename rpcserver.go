@@ -6,6 +6,7 @@
 package main
 
 import (
+	"bufio"
 	"bytes"
 	"crypto/sha256"
 	"crypto/subtle"
@@ -21,6 +22,8 @@ import (
 	"net"
 	"net/http"
 	"os"
+	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -34,6 +37,7 @@ import (
 	"github.com/eager7/dashd/chaincfg"
 	"github.com/eager7/dashd/chaincfg/chainhash"
 	"github.com/eager7/dashd/database"
+	"github.com/eager7/dashd/evo"
 	"github.com/eager7/dashd/mempool"
 	"github.com/eager7/dashd/mining"
 	"github.com/eager7/dashd/mining/cpuminer"
@@ -41,6 +45,7 @@ import (
 	"github.com/eager7/dashd/txscript"
 	"github.com/eager7/dashd/wire"
 	"github.com/eager7/dashutil"
+	"github.com/eager7/dashutil/bloom"
 	"github.com/btcsuite/websocket"
 )
 
@@ -118,6 +123,16 @@ var (
 		Code:    btcjson.ErrRPCNoWallet,
 		Message: "This implementation does not implement wallet commands",
 	}
+
+	// errNoPoSe is an error returned to RPC clients when the requested
+	// masternode information depends on the proof of service penalties
+	// assigned by the quorum commitments, which the masternode list does
+	// not process.
+	errNoPoSe = &btcjson.RPCError{
+		Code: btcjson.ErrRPCMisc,
+		Message: "Masternode statuses and payees are not available " +
+			"since proof of service penalties are not processed",
+	}
 )
 
 type commandHandler func(*rpcServer, interface{}, <-chan struct{}) (interface{}, error)
@@ -127,52 +142,72 @@ type commandHandler func(*rpcServer, interface{}, <-chan struct{}) (interface{},
 // a dependency loop.
 var rpcHandlers map[string]commandHandler
 var rpcHandlersBeforeInit = map[string]commandHandler{
-	"addnode":               handleAddNode,
-	"createrawtransaction":  handleCreateRawTransaction,
-	"debuglevel":            handleDebugLevel,
-	"decoderawtransaction":  handleDecodeRawTransaction,
-	"decodescript":          handleDecodeScript,
-	"estimatefee":           handleEstimateFee,
-	"generate":              handleGenerate,
-	"getaddednodeinfo":      handleGetAddedNodeInfo,
-	"getbestblock":          handleGetBestBlock,
-	"getbestblockhash":      handleGetBestBlockHash,
-	"getblock":              handleGetBlock,
-	"getblockchaininfo":     handleGetBlockChainInfo,
-	"getblockcount":         handleGetBlockCount,
-	"getblockhash":          handleGetBlockHash,
-	"getblockheader":        handleGetBlockHeader,
-	"getblocktemplate":      handleGetBlockTemplate,
-	"getcfilter":            handleGetCFilter,
-	"getcfilterheader":      handleGetCFilterHeader,
-	"getconnectioncount":    handleGetConnectionCount,
-	"getcurrentnet":         handleGetCurrentNet,
-	"getdifficulty":         handleGetDifficulty,
-	"getgenerate":           handleGetGenerate,
-	"gethashespersec":       handleGetHashesPerSec,
-	"getheaders":            handleGetHeaders,
-	"getinfo":               handleGetInfo,
-	"getmempoolinfo":        handleGetMempoolInfo,
-	"getmininginfo":         handleGetMiningInfo,
-	"getnettotals":          handleGetNetTotals,
-	"getnetworkhashps":      handleGetNetworkHashPS,
-	"getpeerinfo":           handleGetPeerInfo,
-	"getrawmempool":         handleGetRawMempool,
-	"getrawtransaction":     handleGetRawTransaction,
-	"gettxout":              handleGetTxOut,
-	"help":                  handleHelp,
-	"node":                  handleNode,
-	"ping":                  handlePing,
-	"searchrawtransactions": handleSearchRawTransactions,
-	"sendrawtransaction":    handleSendRawTransaction,
-	"setgenerate":           handleSetGenerate,
-	"stop":                  handleStop,
-	"submitblock":           handleSubmitBlock,
-	"uptime":                handleUptime,
-	"validateaddress":       handleValidateAddress,
-	"verifychain":           handleVerifyChain,
-	"verifymessage":         handleVerifyMessage,
-	"version":               handleVersion,
+	"addnode":                handleAddNode,
+	"clearbanned":            handleClearBanned,
+	"createrawtransaction":   handleCreateRawTransaction,
+	"debuglevel":             handleDebugLevel,
+	"decoderawtransaction":   handleDecodeRawTransaction,
+	"decodescript":           handleDecodeScript,
+	"disconnectnode":         handleDisconnectNode,
+	"dumptxoutset":           handleDumpTxOutSet,
+	"estimatefee":            handleEstimateFee,
+	"generate":               handleGenerate,
+	"getaddednodeinfo":       handleGetAddedNodeInfo,
+	"getaddressbalance":      handleGetAddressBalance,
+	"getaddressdeltas":       handleGetAddressDeltas,
+	"getaddressmempool":      handleGetAddressMempool,
+	"getaddresstxids":        handleGetAddressTxIDs,
+	"getaddressutxos":        handleGetAddressUtxos,
+	"getbestblock":           handleGetBestBlock,
+	"getassetunlockstatuses": handleGetAssetUnlockStatuses,
+	"getbestblockhash":       handleGetBestBlockHash,
+	"getbestchainlock":       handleGetBestChainLock,
+	"getblock":               handleGetBlock,
+	"getblockchaininfo":      handleGetBlockChainInfo,
+	"getblockcount":          handleGetBlockCount,
+	"getblockhash":           handleGetBlockHash,
+	"getblockhashes":         handleGetBlockHashes,
+	"getblockheader":         handleGetBlockHeader,
+	"getblocktemplate":       handleGetBlockTemplate,
+	"getcfilter":             handleGetCFilter,
+	"getcfilterheader":       handleGetCFilterHeader,
+	"getconnectioncount":     handleGetConnectionCount,
+	"getcurrentnet":          handleGetCurrentNet,
+	"getdifficulty":          handleGetDifficulty,
+	"getgenerate":            handleGetGenerate,
+	"gethashespersec":        handleGetHashesPerSec,
+	"getheaders":             handleGetHeaders,
+	"getinfo":                handleGetInfo,
+	"getmempoolinfo":         handleGetMempoolInfo,
+	"getmininginfo":          handleGetMiningInfo,
+	"getnettotals":           handleGetNetTotals,
+	"getnetworkhashps":       handleGetNetworkHashPS,
+	"getpeerinfo":            handleGetPeerInfo,
+	"getrawmempool":          handleGetRawMempool,
+	"getrawtransaction":      handleGetRawTransaction,
+	"getspentinfo":           handleGetSpentInfo,
+	"gettxout":               handleGetTxOut,
+	"gettxoutsetinfo":        handleGetTxOutSetInfo,
+	"help":                   handleHelp,
+	"listbanned":             handleListBanned,
+	"loadtxoutset":           handleLoadTxOutSet,
+	"masternode":             handleMasternode,
+	"masternodelist":         handleMasternodeList,
+	"node":                   handleNode,
+	"ping":                   handlePing,
+	"protx":                  handleProTx,
+	"searchrawtransactions":  handleSearchRawTransactions,
+	"sendrawtransaction":     handleSendRawTransaction,
+	"setban":                 handleSetBan,
+	"setgenerate":            handleSetGenerate,
+	"signmessagewithprivkey": handleSignMessageWithPrivKey,
+	"stop":                   handleStop,
+	"submitblock":            handleSubmitBlock,
+	"uptime":                 handleUptime,
+	"validateaddress":        handleValidateAddress,
+	"verifychain":            handleVerifyChain,
+	"verifymessage":          handleVerifyMessage,
+	"version":                handleVersion,
 }
 
 // list of commands that we recognize, but for which btcd has no support because
@@ -195,7 +230,6 @@ var rpcAskWallet = map[string]struct{}{
 	"getreceivedbyaccount":   {},
 	"getreceivedbyaddress":   {},
 	"gettransaction":         {},
-	"gettxoutsetinfo":        {},
 	"getunconfirmedbalance":  {},
 	"getwalletinfo":          {},
 	"importprivkey":          {},
@@ -251,34 +285,47 @@ var rpcLimited = map[string]struct{}{
 	"help": {},
 
 	// HTTP/S-only commands
-	"createrawtransaction":  {},
-	"decoderawtransaction":  {},
-	"decodescript":          {},
-	"estimatefee":           {},
-	"getbestblock":          {},
-	"getbestblockhash":      {},
-	"getblock":              {},
-	"getblockcount":         {},
-	"getblockhash":          {},
-	"getblockheader":        {},
-	"getcfilter":            {},
-	"getcfilterheader":      {},
-	"getcurrentnet":         {},
-	"getdifficulty":         {},
-	"getheaders":            {},
-	"getinfo":               {},
-	"getnettotals":          {},
-	"getnetworkhashps":      {},
-	"getrawmempool":         {},
-	"getrawtransaction":     {},
-	"gettxout":              {},
-	"searchrawtransactions": {},
-	"sendrawtransaction":    {},
-	"submitblock":           {},
-	"uptime":                {},
-	"validateaddress":       {},
-	"verifymessage":         {},
-	"version":               {},
+	"createrawtransaction":   {},
+	"decoderawtransaction":   {},
+	"decodescript":           {},
+	"estimatefee":            {},
+	"getaddressbalance":      {},
+	"getaddressdeltas":       {},
+	"getaddressmempool":      {},
+	"getaddresstxids":        {},
+	"getaddressutxos":        {},
+	"getbestblock":           {},
+	"getassetunlockstatuses": {},
+	"getbestblockhash":       {},
+	"getbestchainlock":       {},
+	"getblock":               {},
+	"getblockcount":          {},
+	"getblockhash":           {},
+	"getblockhashes":         {},
+	"getblockheader":         {},
+	"getcfilter":             {},
+	"getcfilterheader":       {},
+	"getcurrentnet":          {},
+	"getdifficulty":          {},
+	"getheaders":             {},
+	"getinfo":                {},
+	"getnettotals":           {},
+	"getnetworkhashps":       {},
+	"getrawmempool":          {},
+	"getrawtransaction":      {},
+	"getspentinfo":           {},
+	"gettxout":               {},
+	"gettxoutsetinfo":        {},
+	"masternode":             {},
+	"masternodelist":         {},
+	"protx":                  {},
+	"searchrawtransactions":  {},
+	"sendrawtransaction":     {},
+	"submitblock":            {},
+	"uptime":                 {},
+	"validateaddress":        {},
+	"verifymessage":          {},
+	"version":                {},
 }
 
 // builderScript is a convenience function which is used for hard-coded scripts
@@ -508,6 +555,12 @@ func messageToHex(msg wire.Message) (string, error) {
 	return hex.EncodeToString(buf.Bytes()), nil
 }
 
+// handleClearBanned implements the clearbanned command.
+func handleClearBanned(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	s.cfg.ConnMgr.ClearBanned()
+	return nil, nil
+}
+
 // handleCreateRawTransaction handles createrawtransaction commands.
 func handleCreateRawTransaction(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	c := cmd.(*btcjson.CreateRawTransactionCmd)
@@ -739,6 +792,76 @@ func createVoutList(mtx *wire.MsgTx, chainParams *chaincfg.Params, filterAddrMap
 	return voutList
 }
 
+// createCbTxResult returns the JSON representation of the payload of the
+// passed transaction when it is a coinbase special transaction and nil
+// otherwise.
+func createCbTxResult(mtx *wire.MsgTx) *btcjson.CbTxResult {
+	if !mtx.IsSpecial() || mtx.Type != wire.TxTypeCoinBase {
+		return nil
+	}
+	payload, err := mtx.CoinbasePayload()
+	if err != nil {
+		return nil
+	}
+
+	result := &btcjson.CbTxResult{
+		Version:          payload.Version,
+		Height:           payload.Height,
+		MerkleRootMNList: payload.MerkleRootMNList.String(),
+	}
+	if payload.Version >= wire.CbTxVersionMerkleRootQuorums {
+		result.MerkleRootQuorums = payload.MerkleRootQuorums.String()
+	}
+	if payload.Version >= wire.CbTxVersionCreditPool {
+		bestCLHeightDiff := payload.BestCLHeightDiff
+		creditPoolBalance := dashutil.Amount(payload.CreditPoolBalance).ToBTC()
+		result.BestCLHeightDiff = &bestCLHeightDiff
+		result.BestCLSignature = hex.EncodeToString(payload.BestCLSignature[:])
+		result.CreditPoolBalance = &creditPoolBalance
+	}
+	return result
+}
+
+// createAssetLockTxResult returns the JSON representation of the payload of
+// the passed transaction when it is an asset lock and nil otherwise.
+func createAssetLockTxResult(mtx *wire.MsgTx, chainParams *chaincfg.Params) *btcjson.AssetLockTxResult {
+	if !blockchain.IsAssetLockTx(mtx) {
+		return nil
+	}
+	payload, err := mtx.AssetLockPayload()
+	if err != nil {
+		return nil
+	}
+
+	// The credit outputs are converted the same way as regular outputs.
+	creditTx := wire.MsgTx{TxOut: payload.CreditOutputs}
+	return &btcjson.AssetLockTxResult{
+		Version:       payload.Version,
+		CreditOutputs: createVoutList(&creditTx, chainParams, nil),
+	}
+}
+
+// createAssetUnlockTxResult returns the JSON representation of the payload of
+// the passed transaction when it is an asset unlock and nil otherwise.
+func createAssetUnlockTxResult(mtx *wire.MsgTx) *btcjson.AssetUnlockTxResult {
+	if !blockchain.IsAssetUnlockTx(mtx) {
+		return nil
+	}
+	payload, err := mtx.AssetUnlockPayload()
+	if err != nil {
+		return nil
+	}
+
+	return &btcjson.AssetUnlockTxResult{
+		Version:         payload.Version,
+		Index:           payload.Index,
+		Fee:             payload.Fee,
+		RequestedHeight: payload.RequestedHeight,
+		QuorumHash:      payload.QuorumHash.String(),
+		QuorumSig:       hex.EncodeToString(payload.QuorumSig[:]),
+	}
+}
+
 // createTxRawResult converts the passed transaction and associated parameters
 // to a raw transaction JSON object.
 func createTxRawResult(chainParams *chaincfg.Params, mtx *wire.MsgTx,
@@ -760,9 +883,18 @@ func createTxRawResult(chainParams *chaincfg.Params, mtx *wire.MsgTx,
 		Vin:      createVinList(mtx),
 		Vout:     createVoutList(mtx, chainParams, nil),
 		Version:  mtx.Version,
+		Type:     uint16(mtx.Type),
 		LockTime: mtx.LockTime,
 	}
 
+	if mtx.IsSpecial() {
+		txReply.ExtraPayloadSize = len(mtx.Payload)
+		txReply.ExtraPayload = hex.EncodeToString(mtx.Payload)
+		txReply.CbTx = createCbTxResult(mtx)
+		txReply.AssetLockTx = createAssetLockTxResult(mtx, chainParams)
+		txReply.AssetUnlockTx = createAssetUnlockTxResult(mtx)
+	}
+
 	if blkHeader != nil {
 		// This is not a typo, they are identical in bitcoind as well.
 		txReply.Time = blkHeader.Timestamp.Unix()
@@ -800,10 +932,18 @@ func handleDecodeRawTransaction(s *rpcServer, cmd interface{}, closeChan <-chan
 	txReply := btcjson.TxRawDecodeResult{
 		Txid:     mtx.TxHash().String(),
 		Version:  mtx.Version,
+		Type:     uint16(mtx.Type),
 		Locktime: mtx.LockTime,
 		Vin:      createVinList(&mtx),
 		Vout:     createVoutList(&mtx, s.cfg.ChainParams, nil),
 	}
+	if mtx.IsSpecial() {
+		txReply.ExtraPayloadSize = len(mtx.Payload)
+		txReply.ExtraPayload = hex.EncodeToString(mtx.Payload)
+		txReply.CbTx = createCbTxResult(&mtx)
+		txReply.AssetLockTx = createAssetLockTxResult(&mtx, s.cfg.ChainParams)
+		txReply.AssetUnlockTx = createAssetUnlockTxResult(&mtx)
+	}
 	return txReply, nil
 }
 
@@ -855,6 +995,97 @@ func handleDecodeScript(s *rpcServer, cmd interface{}, closeChan <-chan struct{}
 	return reply, nil
 }
 
+// txOutSetPath returns the path of a utxo set snapshot file passed to the
+// dumptxoutset and loadtxoutset commands.  Relative paths are relative to the
+// data directory.
+func txOutSetPath(path string) string {
+	path = cleanAndExpandPath(path)
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(cfg.DataDir, path)
+	}
+	return path
+}
+
+// handleDisconnectNode implements the disconnectnode command.
+func handleDisconnectNode(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.DisconnectNodeCmd)
+
+	var address string
+	if c.Address != nil {
+		address = *c.Address
+	}
+
+	var err error
+	switch {
+	case address != "" && c.NodeID == nil:
+		addr := normalizeAddress(address, s.cfg.ChainParams.DefaultPort)
+		err = s.cfg.ConnMgr.DisconnectByAddr(addr)
+	case address == "" && c.NodeID != nil:
+		err = s.cfg.ConnMgr.DisconnectByID(*c.NodeID)
+	default:
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "exactly one of address and nodeid must be provided",
+		}
+	}
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCClientNodeNotConnected,
+			Message: "node not found in connected nodes",
+		}
+	}
+
+	return nil, nil
+}
+
+// handleDumpTxOutSet implements the dumptxoutset command.
+func handleDumpTxOutSet(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.DumpTxOutSetCmd)
+
+	path := txOutSetPath(c.Path)
+	if _, err := os.Stat(path); err == nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: fmt.Sprintf("%s already exists", path),
+		}
+	}
+
+	// The snapshot is written to a temporary file which is only renamed
+	// once it is complete, so a partial snapshot is never left at the
+	// requested path.
+	tmpPath := path + ".incomplete"
+	f, err := os.Create(tmpPath)
+	if err != nil {
+		context := "Failed to create utxo set snapshot file"
+		return nil, internalRPCError(err.Error(), context)
+	}
+	w := bufio.NewWriter(f)
+	info, err := s.cfg.Chain.DumpUtxoSnapshot(w)
+	if err == nil {
+		err = w.Flush()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmpPath, path)
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		context := "Failed to write utxo set snapshot"
+		return nil, internalRPCError(err.Error(), context)
+	}
+
+	return &btcjson.DumpTxOutSetResult{
+		CoinsWritten: info.NumUtxos,
+		BaseHash:     info.BlockHash.String(),
+		BaseHeight:   info.Height,
+		Path:         path,
+		TxOutSetHash: info.ContentHash.String(),
+		NChainTx:     info.TotalTxns,
+	}, nil
+}
+
 // handleEstimateFee handles estimatefee commands.
 func handleEstimateFee(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	c := cmd.(*btcjson.EstimateFeeCmd)
@@ -1021,6 +1252,302 @@ func handleGetAddedNodeInfo(s *rpcServer, cmd interface{}, closeChan <-chan stru
 	return results, nil
 }
 
+// addressCoinbaseMaturity is the number of blocks after which coinbase
+// outputs are no longer reported as immature by getaddressbalance.
+const addressCoinbaseMaturity = 100
+
+// addressIndexAddrs returns the decoded addresses of the passed address index
+// request.  An error is returned when the address delta index is not enabled
+// or an address is invalid.  Like Dash Core, only pay-to-pubkey-hash and
+// pay-to-script-hash addresses are supported.
+func addressIndexAddrs(s *rpcServer, req *btcjson.AddressIndexRequest) ([]dashutil.Address, error) {
+	if s.cfg.AddrDeltaIndex == nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCMisc,
+			Message: "Address index must be enabled (--addressindex)",
+		}
+	}
+	if len(req.Addresses) == 0 {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "No addresses given",
+		}
+	}
+
+	addrs := make([]dashutil.Address, 0, len(req.Addresses))
+	for _, encoded := range req.Addresses {
+		addr, err := dashutil.DecodeAddress(encoded, s.cfg.ChainParams)
+		if err == nil {
+			switch addr.(type) {
+			case *dashutil.AddressPubKeyHash, *dashutil.AddressScriptHash:
+			default:
+				err = errors.New("unsupported address type")
+			}
+		}
+		if err != nil {
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCInvalidAddressOrKey,
+				Message: "Invalid address: " + encoded,
+			}
+		}
+		addrs = append(addrs, addr)
+	}
+	return addrs, nil
+}
+
+// addressIndexRange returns the range of block heights of the passed address
+// index request.  Both heights are zero when no range is given.
+func addressIndexRange(req *btcjson.AddressIndexRequest) (int32, int32, error) {
+	if req.Start == nil && req.End == nil {
+		return 0, 0, nil
+	}
+	if req.Start == nil || req.End == nil || *req.Start <= 0 || *req.End <= 0 {
+		return 0, 0, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "Start and end are expected to be greater than zero",
+		}
+	}
+	if *req.End < *req.Start {
+		return 0, 0, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "End value is expected to be greater than start",
+		}
+	}
+	return *req.Start, *req.End, nil
+}
+
+// addressIndexError returns an error for a failed address delta index lookup.
+func addressIndexError(err error) *btcjson.RPCError {
+	context := "Failed to load address index entries"
+	return internalRPCError(err.Error(), context)
+}
+
+// handleGetAddressBalance implements the getaddressbalance command.
+func handleGetAddressBalance(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.GetAddressBalanceCmd)
+	addrs, err := addressIndexAddrs(s, &c.Request)
+	if err != nil {
+		return nil, err
+	}
+
+	// Coinbase outputs are reported as immature until they can be spent
+	// by a transaction in the next block according to the coinbase
+	// maturity of Dash Core.
+	nextHeight := s.cfg.Chain.BestSnapshot().Height + 1
+
+	var result btcjson.GetAddressBalanceResult
+	for _, addr := range addrs {
+		deltas, err := s.cfg.AddrDeltaIndex.AddrDeltas(addr, 0, 0)
+		if err != nil {
+			return nil, addressIndexError(err)
+		}
+		for i := range deltas {
+			result.Balance += deltas[i].Amount
+			if deltas[i].Amount > 0 {
+				result.Received += deltas[i].Amount
+			}
+		}
+
+		utxos, err := s.cfg.AddrDeltaIndex.AddrUtxos(addr)
+		if err != nil {
+			return nil, addressIndexError(err)
+		}
+		for i := range utxos {
+			utxo := &utxos[i]
+			if utxo.IsCoinBase && nextHeight-utxo.Height < addressCoinbaseMaturity {
+				result.BalanceImmature += utxo.Amount
+			}
+		}
+	}
+	result.BalanceSpendable = result.Balance - result.BalanceImmature
+	return &result, nil
+}
+
+// handleGetAddressDeltas implements the getaddressdeltas command.
+func handleGetAddressDeltas(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.GetAddressDeltasCmd)
+	addrs, err := addressIndexAddrs(s, &c.Request)
+	if err != nil {
+		return nil, err
+	}
+	start, end, err := addressIndexRange(&c.Request)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]btcjson.AddressDeltaResult, 0)
+	for i, addr := range addrs {
+		deltas, err := s.cfg.AddrDeltaIndex.AddrDeltas(addr, start, end)
+		if err != nil {
+			return nil, addressIndexError(err)
+		}
+		for j := range deltas {
+			delta := &deltas[j]
+			results = append(results, btcjson.AddressDeltaResult{
+				Satoshis:   delta.Amount,
+				TxID:       delta.TxHash.String(),
+				Index:      delta.Index,
+				BlockIndex: delta.BlockIndex,
+				Height:     delta.Height,
+				Address:    c.Request.Addresses[i],
+			})
+		}
+	}
+
+	if c.Request.ChainInfo == nil || !*c.Request.ChainInfo || start == 0 {
+		return results, nil
+	}
+
+	// Chain info is only returned when a range is given.
+	blockResult := func(height int32) (btcjson.AddressBlockResult, error) {
+		hash, err := s.cfg.Chain.BlockHashByHeight(height)
+		if err != nil {
+			return btcjson.AddressBlockResult{}, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCInvalidAddressOrKey,
+				Message: "Start or end is outside chain range",
+			}
+		}
+		return btcjson.AddressBlockResult{
+			Hash:   hash.String(),
+			Height: height,
+		}, nil
+	}
+	result := &btcjson.GetAddressDeltasChainInfoResult{Deltas: results}
+	if result.Start, err = blockResult(start); err != nil {
+		return nil, err
+	}
+	if result.End, err = blockResult(end); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// handleGetAddressMempool implements the getaddressmempool command.
+func handleGetAddressMempool(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.GetAddressMempoolCmd)
+	addrs, err := addressIndexAddrs(s, &c.Request)
+	if err != nil {
+		return nil, err
+	}
+
+	type addrDelta struct {
+		address string
+		delta   *indexers.UnconfirmedAddrDelta
+	}
+	var deltas []addrDelta
+	for i, addr := range addrs {
+		addrDeltas := s.cfg.AddrDeltaIndex.UnconfirmedDeltasForAddress(addr)
+		for j := range addrDeltas {
+			deltas = append(deltas, addrDelta{
+				address: c.Request.Addresses[i],
+				delta:   &addrDeltas[j],
+			})
+		}
+	}
+	sort.SliceStable(deltas, func(i, j int) bool {
+		return deltas[i].delta.Time.Before(deltas[j].delta.Time)
+	})
+
+	results := make([]btcjson.AddressMempoolResult, 0, len(deltas))
+	for _, d := range deltas {
+		result := btcjson.AddressMempoolResult{
+			Address:   d.address,
+			TxID:      d.delta.TxHash.String(),
+			Index:     d.delta.Index,
+			Satoshis:  d.delta.Amount,
+			Timestamp: d.delta.Time.Unix(),
+		}
+		if prevOut := d.delta.PrevOut; prevOut != nil {
+			result.PrevTxID = prevOut.Hash.String()
+			result.PrevOut = &prevOut.Index
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// handleGetAddressTxIDs implements the getaddresstxids command.
+func handleGetAddressTxIDs(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.GetAddressTxIDsCmd)
+	addrs, err := addressIndexAddrs(s, &c.Request)
+	if err != nil {
+		return nil, err
+	}
+	start, end, err := addressIndexRange(&c.Request)
+	if err != nil {
+		return nil, err
+	}
+
+	var deltas []indexers.AddrDelta
+	for _, addr := range addrs {
+		addrDeltas, err := s.cfg.AddrDeltaIndex.AddrDeltas(addr, start, end)
+		if err != nil {
+			return nil, addressIndexError(err)
+		}
+		deltas = append(deltas, addrDeltas...)
+	}
+
+	// The transactions of multiple addresses are merged in the order they
+	// appear in the chain.
+	sort.SliceStable(deltas, func(i, j int) bool {
+		if deltas[i].Height != deltas[j].Height {
+			return deltas[i].Height < deltas[j].Height
+		}
+		return deltas[i].BlockIndex < deltas[j].BlockIndex
+	})
+	seen := make(map[chainhash.Hash]struct{}, len(deltas))
+	txIDs := make([]string, 0, len(deltas))
+	for i := range deltas {
+		if _, ok := seen[deltas[i].TxHash]; ok {
+			continue
+		}
+		seen[deltas[i].TxHash] = struct{}{}
+		txIDs = append(txIDs, deltas[i].TxHash.String())
+	}
+	return txIDs, nil
+}
+
+// handleGetAddressUtxos implements the getaddressutxos command.
+func handleGetAddressUtxos(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.GetAddressUtxosCmd)
+	addrs, err := addressIndexAddrs(s, &c.Request)
+	if err != nil {
+		return nil, err
+	}
+
+	best := s.cfg.Chain.BestSnapshot()
+	results := make([]btcjson.AddressUtxoResult, 0)
+	for i, addr := range addrs {
+		utxos, err := s.cfg.AddrDeltaIndex.AddrUtxos(addr)
+		if err != nil {
+			return nil, addressIndexError(err)
+		}
+		for j := range utxos {
+			utxo := &utxos[j]
+			results = append(results, btcjson.AddressUtxoResult{
+				Address:     c.Request.Addresses[i],
+				TxID:        utxo.OutPoint.Hash.String(),
+				OutputIndex: utxo.OutPoint.Index,
+				Script:      hex.EncodeToString(utxo.PkScript),
+				Satoshis:    utxo.Amount,
+				Height:      utxo.Height,
+			})
+		}
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Height < results[j].Height
+	})
+
+	if c.Request.ChainInfo == nil || !*c.Request.ChainInfo {
+		return results, nil
+	}
+	return &btcjson.GetAddressUtxosChainInfoResult{
+		Utxos:  results,
+		Hash:   best.Hash.String(),
+		Height: best.Height,
+	}, nil
+}
+
 // handleGetBestBlock implements the getbestblock command.
 func handleGetBestBlock(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	// All other "get block" commands give either the height, the
@@ -1040,6 +1567,48 @@ func handleGetBestBlockHash(s *rpcServer, cmd interface{}, closeChan <-chan stru
 	return best.Hash.String(), nil
 }
 
+// handleGetAssetUnlockStatuses implements the getassetunlockstatuses command.
+func handleGetAssetUnlockStatuses(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.GetAssetUnlockStatusesCmd)
+
+	// The statuses are reported as of the best chain unless a height is
+	// given, in which case the mempool is not considered.
+	best := s.cfg.Chain.BestSnapshot()
+	height := best.Height
+	if c.Height != nil {
+		if *c.Height < 0 || *c.Height > best.Height {
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCInvalidParameter,
+				Message: "Block height out of range",
+			}
+		}
+		height = *c.Height
+	}
+
+	statuses := make([]btcjson.AssetUnlockStatusResult, 0, len(c.Indexes))
+	for _, index := range c.Indexes {
+		minedHeight, mined, err := s.cfg.Chain.AssetUnlockHeight(index)
+		if err != nil {
+			context := "Failed to look up asset unlock index"
+			return nil, internalRPCError(err.Error(), context)
+		}
+
+		status := "unknown"
+		switch {
+		case mined && minedHeight <= height:
+			status = "mined"
+		case c.Height == nil && s.cfg.TxMemPool.HaveAssetUnlock(index):
+			status = "mempooled"
+		}
+		statuses = append(statuses, btcjson.AssetUnlockStatusResult{
+			Index:  index,
+			Status: status,
+		})
+	}
+
+	return statuses, nil
+}
+
 // getDifficultyRatio returns the proof-of-work difficulty as a multiple of the
 // minimum difficulty using the passed bits field from the header of a block.
 func getDifficultyRatio(bits uint32, params *chaincfg.Params) float64 {
@@ -1137,6 +1706,17 @@ func handleGetBlock(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (i
 		NextHash:      nextHashString,
 	}
 
+	// Include the credit pool balance once the credit pool is tracked.
+	if blockHeight >= params.V20Height {
+		pool, err := s.cfg.Chain.CreditPool(hash)
+		if err != nil {
+			context := "Failed to obtain credit pool"
+			return nil, internalRPCError(err.Error(), context)
+		}
+		balance := dashutil.Amount(pool.Locked).ToBTC()
+		blockReply.CreditPoolBalance = &balance
+	}
+
 	if *c.Verbosity == 1 {
 		transactions := blk.Transactions()
 		txNames := make([]string, len(transactions))
@@ -1291,6 +1871,53 @@ func handleGetBlockChainInfo(s *rpcServer, cmd interface{}, closeChan <-chan str
 	//	}
 	//}
 
+	// Also report the deployments which are enabled by masternode hard
+	// fork signals along with the height of the signal, if any.
+	signals, err := chain.MnHfSignals()
+	if err != nil {
+		context := "Failed to obtain masternode hard fork signals"
+		return nil, internalRPCError(err.Error(), context)
+	}
+	for deployment, deploymentDetails := range params.EHFDeployments {
+		var forkName string
+		switch deployment {
+		case chaincfg.EHFDeploymentMnRR:
+			forkName = "mn_rr"
+
+		default:
+			return nil, &btcjson.RPCError{
+				Code: btcjson.ErrRPCInternal.Code,
+				Message: fmt.Sprintf("Unknown EHF deployment %v "+
+					"detected", deployment),
+			}
+		}
+
+		deploymentStatus, since, err := chain.EHFThresholdState(
+			uint32(deployment))
+		if err != nil {
+			context := "Failed to obtain deployment status"
+			return nil, internalRPCError(err.Error(), context)
+		}
+		statusString, err := softForkStatus(deploymentStatus)
+		if err != nil {
+			return nil, &btcjson.RPCError{
+				Code: btcjson.ErrRPCInternal.Code,
+				Message: fmt.Sprintf("unknown deployment status: %v",
+					deploymentStatus),
+			}
+		}
+
+		chainInfo.SoftForks.Bip9SoftForks[forkName] = &btcjson.Bip9SoftForkDescription{
+			Status:     strings.ToLower(statusString),
+			Bit:        deploymentDetails.BitNumber,
+			StartTime2: int64(deploymentDetails.StartTime),
+			Timeout:    int64(deploymentDetails.ExpireTime),
+			Since:      since,
+			EHF:        true,
+			EHFHeight:  signals[deploymentDetails.BitNumber],
+		}
+	}
+
 	return chainInfo, nil
 }
 
@@ -1314,10 +1941,59 @@ func handleGetBlockHash(s *rpcServer, cmd interface{}, closeChan <-chan struct{}
 	return hash.String(), nil
 }
 
-// handleGetBlockHeader implements the getblockheader command.
-func handleGetBlockHeader(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
-	c := cmd.(*btcjson.GetBlockHeaderCmd)
-
+// handleGetBlockHashes implements the getblockhashes command.
+func handleGetBlockHashes(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.GetBlockHashesCmd)
+	if s.cfg.TimestampIndex == nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCMisc,
+			Message: "Timestamp index must be enabled (--timestampindex)",
+		}
+	}
+
+	var noOrphans, logicalTimes bool
+	if c.Options != nil {
+		if c.Options.NoOrphans != nil {
+			noOrphans = *c.Options.NoOrphans
+		}
+		if c.Options.LogicalTimes != nil {
+			logicalTimes = *c.Options.LogicalTimes
+		}
+	}
+
+	blocks, err := s.cfg.TimestampIndex.BlocksInRange(c.Low, c.High)
+	if err != nil {
+		context := "Failed to load timestamp index entries"
+		return nil, internalRPCError(err.Error(), context)
+	}
+
+	hashes := make([]string, 0, len(blocks))
+	results := make([]btcjson.GetBlockHashesResult, 0, len(blocks))
+	for i := range blocks {
+		block := &blocks[i]
+		if noOrphans && !s.cfg.Chain.MainChainHasBlock(&block.Hash) {
+			continue
+		}
+		if logicalTimes {
+			results = append(results, btcjson.GetBlockHashesResult{
+				BlockHash: block.Hash.String(),
+				LogicalTS: block.LogicalTime,
+			})
+			continue
+		}
+		hashes = append(hashes, block.Hash.String())
+	}
+
+	if logicalTimes {
+		return results, nil
+	}
+	return hashes, nil
+}
+
+// handleGetBlockHeader implements the getblockheader command.
+func handleGetBlockHeader(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.GetBlockHeaderCmd)
+
 	// Fetch the header from chain.
 	hash, err := chainhash.NewHashFromStr(c.Hash)
 	if err != nil {
@@ -2505,6 +3181,7 @@ func handleGetPeerInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct{})
 			BanScore:       int32(p.BanScore()),
 			FeeFilter:      p.FeeFilter(),
 			SyncNode:       statsSnap.ID == syncPeerID,
+			MappedAS:       p.MappedAS(),
 		}
 		if p.ToPeer().LastPingNonce() != 0 {
 			wait := float64(time.Since(statsSnap.LastPingTime).Nanoseconds())
@@ -2647,170 +3324,838 @@ func handleGetRawTransaction(s *rpcServer, cmd interface{}, closeChan <-chan str
 		chainHeight = s.cfg.Chain.BestSnapshot().Height
 	}
 
-	rawTxn, err := createTxRawResult(s.cfg.ChainParams, mtx, txHash.String(),
-		blkHeader, blkHashStr, blkHeight, chainHeight)
-	if err != nil {
-		return nil, err
+	rawTxn, err := createTxRawResult(s.cfg.ChainParams, mtx, txHash.String(),
+		blkHeader, blkHashStr, blkHeight, chainHeight)
+	if err != nil {
+		return nil, err
+	}
+
+	// Report the inputs spending the outputs when the spent index is
+	// enabled.
+	if s.cfg.SpentIndex != nil {
+		for i := range rawTxn.Vout {
+			vout := &rawTxn.Vout[i]
+			outPoint := wire.OutPoint{Hash: *txHash, Index: vout.N}
+			info, err := s.cfg.SpentIndex.SpentInfo(&outPoint)
+			if err != nil {
+				context := "Failed to load spent info"
+				return nil, internalRPCError(err.Error(), context)
+			}
+			if info == nil {
+				continue
+			}
+			vout.SpentTxID = info.TxHash.String()
+			vout.SpentIndex = &info.Index
+			vout.SpentHeight = &info.Height
+		}
+	}
+	return *rawTxn, nil
+}
+
+// handleGetSpentInfo implements the getspentinfo command.
+func handleGetSpentInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.GetSpentInfoCmd)
+	if s.cfg.SpentIndex == nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCMisc,
+			Message: "Spent index must be enabled (--spentindex)",
+		}
+	}
+
+	txHash, err := chainhash.NewHashFromStr(c.Request.Txid)
+	if err != nil {
+		return nil, rpcDecodeHexError(c.Request.Txid)
+	}
+
+	outPoint := wire.OutPoint{Hash: *txHash, Index: c.Request.Index}
+	info, err := s.cfg.SpentIndex.SpentInfo(&outPoint)
+	if err != nil {
+		context := "Failed to load spent info"
+		return nil, internalRPCError(err.Error(), context)
+	}
+	if info == nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidAddressOrKey,
+			Message: "Unable to get spent info",
+		}
+	}
+
+	return &btcjson.GetSpentInfoResult{
+		Txid:   info.TxHash.String(),
+		Index:  info.Index,
+		Height: info.Height,
+	}, nil
+}
+
+// handleGetTxOut handles gettxout commands.
+func handleGetTxOut(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.GetTxOutCmd)
+
+	// Convert the provided transaction hash hex to a Hash.
+	txHash, err := chainhash.NewHashFromStr(c.Txid)
+	if err != nil {
+		return nil, rpcDecodeHexError(c.Txid)
+	}
+
+	// If requested and the tx is available in the mempool try to fetch it
+	// from there, otherwise attempt to fetch from the block database.
+	var bestBlockHash string
+	var confirmations int32
+	var value int64
+	var pkScript []byte
+	var isCoinbase bool
+	includeMempool := true
+	if c.IncludeMempool != nil {
+		includeMempool = *c.IncludeMempool
+	}
+	// TODO: This is racy.  It should attempt to fetch it directly and check
+	// the error.
+	if includeMempool && s.cfg.TxMemPool.HaveTransaction(txHash) {
+		tx, err := s.cfg.TxMemPool.FetchTransaction(txHash)
+		if err != nil {
+			return nil, rpcNoTxInfoError(txHash)
+		}
+
+		mtx := tx.MsgTx()
+		if c.Vout > uint32(len(mtx.TxOut)-1) {
+			return nil, &btcjson.RPCError{
+				Code: btcjson.ErrRPCInvalidTxVout,
+				Message: "Output index number (vout) does not " +
+					"exist for transaction.",
+			}
+		}
+
+		txOut := mtx.TxOut[c.Vout]
+		if txOut == nil {
+			errStr := fmt.Sprintf("Output index: %d for txid: %s "+
+				"does not exist", c.Vout, txHash)
+			return nil, internalRPCError(errStr, "")
+		}
+
+		best := s.cfg.Chain.BestSnapshot()
+		bestBlockHash = best.Hash.String()
+		confirmations = 0
+		value = txOut.Value
+		pkScript = txOut.PkScript
+		isCoinbase = blockchain.IsCoinBaseTx(mtx)
+	} else {
+		out := wire.OutPoint{Hash: *txHash, Index: c.Vout}
+		entry, err := s.cfg.Chain.FetchUtxoEntry(out)
+		if err != nil {
+			return nil, rpcNoTxInfoError(txHash)
+		}
+
+		// To match the behavior of the reference client, return nil
+		// (JSON null) if the transaction output is spent by another
+		// transaction already in the main chain.  Mined transactions
+		// that are spent by a mempool transaction are not affected by
+		// this.
+		if entry == nil || entry.IsSpent() {
+			return nil, nil
+		}
+
+		best := s.cfg.Chain.BestSnapshot()
+		bestBlockHash = best.Hash.String()
+		confirmations = 1 + best.Height - entry.BlockHeight()
+		value = entry.Amount()
+		pkScript = entry.PkScript()
+		isCoinbase = entry.IsCoinBase()
+	}
+
+	// Disassemble script into single line printable format.
+	// The disassembled string will contain [error] inline if the script
+	// doesn't fully parse, so ignore the error here.
+	disbuf, _ := txscript.DisasmString(pkScript)
+
+	// Get further info about the script.
+	// Ignore the error here since an error means the script couldn't parse
+	// and there is no additional information about it anyways.
+	scriptClass, addrs, reqSigs, _ := txscript.ExtractPkScriptAddrs(pkScript,
+		s.cfg.ChainParams)
+	addresses := make([]string, len(addrs))
+	for i, addr := range addrs {
+		addresses[i] = addr.EncodeAddress()
+	}
+
+	txOutReply := &btcjson.GetTxOutResult{
+		BestBlock:     bestBlockHash,
+		Confirmations: int64(confirmations),
+		Value:         dashutil.Amount(value).ToBTC(),
+		ScriptPubKey: btcjson.ScriptPubKeyResult{
+			Asm:       disbuf,
+			Hex:       hex.EncodeToString(pkScript),
+			ReqSigs:   int32(reqSigs),
+			Type:      scriptClass.String(),
+			Addresses: addresses,
+		},
+		Coinbase: isCoinbase,
+	}
+	return txOutReply, nil
+}
+
+// handleGetTxOutSetInfo implements the gettxoutsetinfo command.
+func handleGetTxOutSetInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.GetTxOutSetInfoCmd)
+
+	var includeMuHash bool
+	switch *c.HashType {
+	case "muhash":
+		includeMuHash = true
+	case "none":
+	default:
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: fmt.Sprintf("Unsupported hash type %q", *c.HashType),
+		}
+	}
+
+	// Default to the best block when no block is given.
+	hash := &s.cfg.Chain.BestSnapshot().Hash
+	if c.HashOrHeight != nil {
+		switch v := c.HashOrHeight.Value.(type) {
+		case string:
+			var err error
+			hash, err = chainhash.NewHashFromStr(v)
+			if err != nil {
+				return nil, rpcDecodeHexError(v)
+			}
+		case int:
+			var err error
+			hash, err = s.cfg.Chain.BlockHashByHeight(int32(v))
+			if err != nil {
+				return nil, &btcjson.RPCError{
+					Code:    btcjson.ErrRPCOutOfRange,
+					Message: "Block number out of range",
+				}
+			}
+		default:
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCInvalidParameter,
+				Message: "hash_or_height must be a block hash or height",
+			}
+		}
+	}
+	height, err := s.cfg.Chain.BlockHeightByHash(hash)
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCBlockNotFound,
+			Message: "Block not found in the main chain",
+		}
+	}
+
+	stats, err := s.cfg.Chain.UtxoStats(hash)
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCMisc,
+			Message: err.Error(),
+		}
+	}
+
+	result := &btcjson.GetTxOutSetInfoResult{
+		Height:       height,
+		BestBlock:    hash.String(),
+		Transactions: stats.TotalTxns,
+		TxOuts:       stats.NumUtxos,
+		DiskSize:     stats.DiskSize,
+		TotalAmount:  dashutil.Amount(stats.TotalAmount).ToBTC(),
+	}
+	if includeMuHash {
+		result.MuHash = stats.MuHash.String()
+	}
+	return result, nil
+}
+
+// handleHelp implements the help command.
+func handleHelp(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.HelpCmd)
+
+	// Provide a usage overview of all commands when no specific command
+	// was specified.
+	var command string
+	if c.Command != nil {
+		command = *c.Command
+	}
+	if command == "" {
+		usage, err := s.helpCacher.rpcUsage(false)
+		if err != nil {
+			context := "Failed to generate RPC usage"
+			return nil, internalRPCError(err.Error(), context)
+		}
+		return usage, nil
+	}
+
+	// Check that the command asked for is supported and implemented.  Only
+	// search the main list of handlers since help should not be provided
+	// for commands that are unimplemented or related to wallet
+	// functionality.
+	if _, ok := rpcHandlers[command]; !ok {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "Unknown command: " + command,
+		}
+	}
+
+	// Get the help for the command.
+	help, err := s.helpCacher.rpcMethodHelp(command)
+	if err != nil {
+		context := "Failed to generate help"
+		return nil, internalRPCError(err.Error(), context)
+	}
+	return help, nil
+}
+
+// handlePing implements the ping command.
+func handlePing(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	// Ask server to ping \o_
+	nonce, err := wire.RandomUint64()
+	if err != nil {
+		return nil, internalRPCError("Not sending ping - failed to "+
+			"generate nonce: "+err.Error(), "")
+	}
+	s.cfg.ConnMgr.BroadcastMessage(wire.NewMsgPing(nonce))
+
+	return nil, nil
+}
+
+// keyIDAddress returns the encoded pay-to-pubkey-hash address of the passed
+// key id.
+func keyIDAddress(keyID []byte, params *chaincfg.Params) string {
+	addr, err := dashutil.NewAddressPubKeyHash(keyID, params)
+	if err != nil {
+		return "unknown"
+	}
+	return addr.EncodeAddress()
+}
+
+// scriptAddress returns the encoded address paid by the passed public key
+// script, or "unknown" when the script does not pay to a single address.
+func scriptAddress(pkScript []byte, params *chaincfg.Params) string {
+	_, addrs, _, err := txscript.ExtractPkScriptAddrs(pkScript, params)
+	if err != nil || len(addrs) != 1 {
+		return "unknown"
+	}
+	return addrs[0].EncodeAddress()
+}
+
+// masternodeListAt returns the masternode list as of the main chain block at
+// the passed height.
+func masternodeListAt(s *rpcServer, height int32) (*evo.List, error) {
+	hash, err := s.cfg.Chain.BlockHashByHeight(height)
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCOutOfRange,
+			Message: "Block height out of range",
+		}
+	}
+	list, err := s.cfg.MNList.ListAt(hash, height)
+	if err != nil {
+		context := "Failed to load masternode list"
+		return nil, internalRPCError(err.Error(), context)
+	}
+	return list, nil
+}
+
+// masternodeListAtHash returns the masternode list as of the main chain block
+// with the passed hash.
+func masternodeListAtHash(s *rpcServer, hashStr string) (*evo.List, error) {
+	hash, err := chainhash.NewHashFromStr(hashStr)
+	if err != nil {
+		return nil, rpcDecodeHexError(hashStr)
+	}
+	height, err := s.cfg.Chain.BlockHeightByHash(hash)
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCBlockNotFound,
+			Message: "Block not found in the main chain",
+		}
+	}
+	list, err := s.cfg.MNList.ListAt(hash, height)
+	if err != nil {
+		context := "Failed to load masternode list"
+		return nil, internalRPCError(err.Error(), context)
+	}
+	return list, nil
+}
+
+// handleGetBestChainLock implements the getbestchainlock command.
+//
+// Chainlocks are not relayed to this node, so the best chainlock is the one the
+// coinbase of the best block commits to.  Its signature is not verified since
+// the public keys of mined quorums are not verified against their members.
+func handleGetBestChainLock(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	best := s.cfg.Chain.BestSnapshot()
+	block, err := s.cfg.Chain.BlockByHash(&best.Hash)
+	if err != nil {
+		context := "Failed to load best block"
+		return nil, internalRPCError(err.Error(), context)
+	}
+	noChainLock := &btcjson.RPCError{
+		Code:    btcjson.ErrRPCMisc,
+		Message: "Unable to find any ChainLock",
+	}
+	payload, err := block.Transactions()[0].MsgTx().CoinbasePayload()
+	if err != nil || payload.Version < wire.CbTxVersionCreditPool ||
+		payload.BestCLSignature == [wire.QuorumSigSize]byte{} {
+
+		return nil, noChainLock
+	}
+
+	height := best.Height - 1 - int32(payload.BestCLHeightDiff)
+	hash, err := s.cfg.Chain.BlockHashByHeight(height)
+	if err != nil {
+		return nil, noChainLock
+	}
+	return &btcjson.GetBestChainLockResult{
+		BlockHash:  hash.String(),
+		Height:     height,
+		Signature:  hex.EncodeToString(payload.BestCLSignature[:]),
+		KnownBlock: true,
+	}, nil
+}
+
+// handleListBanned implements the listbanned command.
+func handleListBanned(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	bans := s.cfg.ConnMgr.BannedSubnets()
+	now := time.Now()
+	results := make([]btcjson.ListBannedResult, 0, len(bans))
+	for _, ban := range bans {
+		results = append(results, btcjson.ListBannedResult{
+			Address:       ban.address,
+			BanCreated:    ban.created.Unix(),
+			BannedUntil:   ban.until.Unix(),
+			BanDuration:   int64(ban.until.Sub(ban.created) / time.Second),
+			TimeRemaining: int64(ban.until.Sub(now) / time.Second),
+		})
+	}
+	return results, nil
+}
+
+// handleLoadTxOutSet implements the loadtxoutset command.
+func handleLoadTxOutSet(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.LoadTxOutSetCmd)
+
+	path := txOutSetPath(c.Path)
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: fmt.Sprintf("Couldn't open utxo set snapshot: %v", err),
+		}
+	}
+	defer f.Close()
+
+	info, err := s.cfg.Chain.LoadUtxoSnapshot(bufio.NewReader(f))
+	if err != nil {
+		context := "Failed to load utxo set snapshot"
+		return nil, internalRPCError(err.Error(), context)
+	}
+
+	return &btcjson.LoadTxOutSetResult{
+		CoinsLoaded: info.NumUtxos,
+		TipHash:     info.BlockHash.String(),
+		BaseHeight:  info.Height,
+		Path:        path,
+	}, nil
+}
+
+// handleMasternode implements the masternode command.
+func handleMasternode(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.MasternodeCmd)
+
+	switch c.SubCmd {
+	case btcjson.MasternodeCount:
+		var result btcjson.MasternodeCountResult
+		for _, mn := range s.cfg.MNList.List().Masternodes() {
+			detail := &result.Detailed.Regular
+			if mn.Type == wire.MasternodeTypeEvo {
+				detail = &result.Detailed.Evo
+			}
+			detail.Total++
+			result.Total++
+		}
+		return &result, nil
+
+	case btcjson.MasternodeWinners:
+		return nil, errNoPoSe
+	}
+
+	return nil, &btcjson.RPCError{
+		Code:    btcjson.ErrRPCInvalidParameter,
+		Message: "Unknown masternode command " + string(c.SubCmd),
+	}
+}
+
+// handleMasternodeList implements the masternodelist command.
+func handleMasternodeList(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.MasternodeListCmd)
+	params := s.cfg.ChainParams
+
+	mode := "json"
+	if c.Mode != nil {
+		mode = *c.Mode
+	}
+	var filter string
+	if c.Filter != nil {
+		filter = *c.Filter
+	}
+
+	jsonResults := make(map[string]*btcjson.MasternodeListResult)
+	results := make(map[string]string)
+	for _, mn := range s.cfg.MNList.List().Masternodes() {
+		key := fmt.Sprintf("%s-%d", mn.CollateralOutpoint.Hash,
+			mn.CollateralOutpoint.Index)
+
+		var value string
+		switch mode {
+		case "json":
+			result := &btcjson.MasternodeListResult{
+				ProTxHash: mn.ProTxHash.String(),
+				Address:   mn.Service(),
+				Type:      mn.Type.String(),
+				OwnerAddress: keyIDAddress(mn.State.KeyIDOwner[:],
+					params),
+				VotingAddress: keyIDAddress(mn.State.KeyIDVoting[:],
+					params),
+				PubKeyOperator: hex.EncodeToString(
+					mn.State.PubKeyOperator[:]),
+			}
+			if mn.Type == wire.MasternodeTypeEvo {
+				platform := &mn.State.Platform
+				result.PlatformNodeID = hex.EncodeToString(
+					platform.NodeID[:])
+				result.PlatformP2PPort = platform.P2PPort
+				result.PlatformHTTPPort = platform.HTTPPort
+			}
+			entry, err := s.cfg.Chain.FetchUtxoEntry(mn.CollateralOutpoint)
+			if err == nil && entry != nil && !entry.IsSpent() {
+				result.CollateralAddress = scriptAddress(
+					entry.PkScript(), params)
+			}
+			value = strings.Join([]string{result.ProTxHash,
+				result.Address, result.Type, result.OwnerAddress,
+				result.VotingAddress, result.CollateralAddress,
+				result.PubKeyOperator}, " ")
+			if strings.Contains(key, filter) ||
+				strings.Contains(value, filter) {
+
+				jsonResults[key] = result
+			}
+			continue
+
+		case "addr":
+			value = mn.Service()
+		case "payee", "status":
+			return nil, errNoPoSe
+		case "pubkeyoperator":
+			value = hex.EncodeToString(mn.State.PubKeyOperator[:])
+		default:
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCInvalidParameter,
+				Message: "Unknown masternodelist mode " + mode,
+			}
+		}
+		if strings.Contains(key, filter) || strings.Contains(value, filter) {
+			results[key] = value
+		}
+	}
+
+	if mode == "json" {
+		return jsonResults, nil
+	}
+	return results, nil
+}
+
+// proTxArgError returns an error for a protx argument of the wrong type.
+func proTxArgError(name, kind string) *btcjson.RPCError {
+	return &btcjson.RPCError{
+		Code:    btcjson.ErrRPCInvalidParameter,
+		Message: fmt.Sprintf("%s must be %s", name, kind),
+	}
+}
+
+// proTxInfoResult returns the result of the protx info command for the passed
+// masternode, which is part of the passed list.
+func proTxInfoResult(s *rpcServer, list *evo.List, mn *evo.Masternode) *btcjson.ProTxInfoResult {
+	params := s.cfg.ChainParams
+	state := &mn.State
+	result := &btcjson.ProTxInfoResult{
+		Type:            mn.Type.String(),
+		ProTxHash:       mn.ProTxHash.String(),
+		CollateralHash:  mn.CollateralOutpoint.Hash.String(),
+		CollateralIndex: mn.CollateralOutpoint.Index,
+		OperatorReward:  float64(mn.OperatorReward) / 100,
+		State: btcjson.ProTxStateResult{
+			Version:           state.Version,
+			Service:           mn.Service(),
+			RegisteredHeight:  state.RegisteredHeight,
+			LastPaidHeight:    state.LastPaidHeight,
+			PoSePenalty:       state.PoSePenalty,
+			PoSeRevivedHeight: state.PoSeRevivedHeight,
+			PoSeBanHeight:     state.PoSeBanHeight,
+			RevocationReason:  state.RevocationReason,
+			OwnerAddress:      keyIDAddress(state.KeyIDOwner[:], params),
+			VotingAddress:     keyIDAddress(state.KeyIDVoting[:], params),
+			PayoutAddress:     scriptAddress(state.ScriptPayout, params),
+			PubKeyOperator:    hex.EncodeToString(state.PubKeyOperator[:]),
+		},
+		Confirmations: list.Height() - state.RegisteredHeight + 1,
 	}
-	return *rawTxn, nil
+	if mn.Type == wire.MasternodeTypeEvo {
+		result.State.PlatformNodeID = hex.EncodeToString(
+			state.Platform.NodeID[:])
+		result.State.PlatformP2PPort = state.Platform.P2PPort
+		result.State.PlatformHTTPPort = state.Platform.HTTPPort
+	}
+	if len(state.ScriptOperatorPayout) != 0 {
+		result.State.OperatorPayoutAddress = scriptAddress(
+			state.ScriptOperatorPayout, params)
+	}
+	entry, err := s.cfg.Chain.FetchUtxoEntry(mn.CollateralOutpoint)
+	if err == nil && entry != nil && !entry.IsSpent() {
+		result.CollateralAddress = scriptAddress(entry.PkScript(), params)
+	}
+	return result
 }
 
-// handleGetTxOut handles gettxout commands.
-func handleGetTxOut(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
-	c := cmd.(*btcjson.GetTxOutCmd)
+// simplifiedMNListEntry returns the simplified masternode list entry of the
+// passed masternode.  The payout addresses are only included when extended is
+// set.
+func simplifiedMNListEntry(mn *evo.Masternode, extended bool, params *chaincfg.Params) btcjson.SimplifiedMNListEntryResult {
+	state := &mn.State
+	entry := btcjson.SimplifiedMNListEntryResult{
+		Version:        state.Version,
+		Type:           uint16(mn.Type),
+		ProRegTxHash:   mn.ProTxHash.String(),
+		ConfirmedHash:  state.ConfirmedHash.String(),
+		Service:        mn.Service(),
+		PubKeyOperator: hex.EncodeToString(state.PubKeyOperator[:]),
+		VotingAddress:  keyIDAddress(state.KeyIDVoting[:], params),
+		IsValid:        mn.IsValid(),
+	}
+	if mn.Type == wire.MasternodeTypeEvo {
+		entry.PlatformHTTPPort = state.Platform.HTTPPort
+		entry.PlatformNodeID = hex.EncodeToString(state.Platform.NodeID[:])
+	}
+	if extended {
+		entry.PayoutAddress = scriptAddress(state.ScriptPayout, params)
+		if len(state.ScriptOperatorPayout) != 0 {
+			entry.OperatorPayoutAddress = scriptAddress(
+				state.ScriptOperatorPayout, params)
+		}
+	}
+	return entry
+}
 
-	// Convert the provided transaction hash hex to a Hash.
-	txHash, err := chainhash.NewHashFromStr(c.Txid)
-	if err != nil {
-		return nil, rpcDecodeHexError(c.Txid)
+// handleProTx implements the protx command.
+func handleProTx(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.ProTxCmd)
+
+	switch c.SubCmd {
+	case btcjson.ProTxList:
+		return handleProTxList(s, c)
+	case btcjson.ProTxInfo:
+		return handleProTxInfo(s, c)
+	case btcjson.ProTxDiff:
+		return handleProTxDiff(s, c)
 	}
 
-	// If requested and the tx is available in the mempool try to fetch it
-	// from there, otherwise attempt to fetch from the block database.
-	var bestBlockHash string
-	var confirmations int32
-	var value int64
-	var pkScript []byte
-	var isCoinbase bool
-	includeMempool := true
-	if c.IncludeMempool != nil {
-		includeMempool = *c.IncludeMempool
+	return nil, &btcjson.RPCError{
+		Code:    btcjson.ErrRPCInvalidParameter,
+		Message: "Unknown protx command " + string(c.SubCmd),
 	}
-	// TODO: This is racy.  It should attempt to fetch it directly and check
-	// the error.
-	if includeMempool && s.cfg.TxMemPool.HaveTransaction(txHash) {
-		tx, err := s.cfg.TxMemPool.FetchTransaction(txHash)
-		if err != nil {
-			return nil, rpcNoTxInfoError(txHash)
-		}
+}
 
-		mtx := tx.MsgTx()
-		if c.Vout > uint32(len(mtx.TxOut)-1) {
-			return nil, &btcjson.RPCError{
-				Code: btcjson.ErrRPCInvalidTxVout,
-				Message: "Output index number (vout) does not " +
-					"exist for transaction.",
-			}
+// handleProTxList handles the list sub command of the protx command.
+func handleProTxList(s *rpcServer, c *btcjson.ProTxCmd) (interface{}, error) {
+	listType := "registered"
+	if c.Arg1 != nil {
+		v, ok := c.Arg1.Value.(string)
+		if !ok {
+			return nil, proTxArgError("type", "a string")
 		}
-
-		txOut := mtx.TxOut[c.Vout]
-		if txOut == nil {
-			errStr := fmt.Sprintf("Output index: %d for txid: %s "+
-				"does not exist", c.Vout, txHash)
-			return nil, internalRPCError(errStr, "")
+		listType = v
+	}
+	var detailed bool
+	if c.Arg2 != nil {
+		v, ok := c.Arg2.Value.(bool)
+		if !ok {
+			return nil, proTxArgError("detailed", "a boolean")
 		}
-
-		best := s.cfg.Chain.BestSnapshot()
-		bestBlockHash = best.Hash.String()
-		confirmations = 0
-		value = txOut.Value
-		pkScript = txOut.PkScript
-		isCoinbase = blockchain.IsCoinBaseTx(mtx)
-	} else {
-		out := wire.OutPoint{Hash: *txHash, Index: c.Vout}
-		entry, err := s.cfg.Chain.FetchUtxoEntry(out)
+		detailed = v
+	}
+	list := s.cfg.MNList.List()
+	if c.Arg3 != nil {
+		height, ok := c.Arg3.Value.(int)
+		if !ok {
+			return nil, proTxArgError("height", "an integer")
+		}
+		var err error
+		list, err = masternodeListAt(s, int32(height))
 		if err != nil {
-			return nil, rpcNoTxInfoError(txHash)
+			return nil, err
 		}
+	}
 
-		// To match the behavior of the reference client, return nil
-		// (JSON null) if the transaction output is spent by another
-		// transaction already in the main chain.  Mined transactions
-		// that are spent by a mempool transaction are not affected by
-		// this.
-		if entry == nil || entry.IsSpent() {
-			return nil, nil
+	var include func(mn *evo.Masternode) bool
+	switch listType {
+	case "registered":
+		include = func(mn *evo.Masternode) bool { return true }
+	case "valid":
+		include = (*evo.Masternode).IsValid
+	case "evo":
+		include = func(mn *evo.Masternode) bool {
+			return mn.Type == wire.MasternodeTypeEvo
+		}
+	default:
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "Unknown protx list type " + listType,
 		}
-
-		best := s.cfg.Chain.BestSnapshot()
-		bestBlockHash = best.Hash.String()
-		confirmations = 1 + best.Height - entry.BlockHeight()
-		value = entry.Amount()
-		pkScript = entry.PkScript()
-		isCoinbase = entry.IsCoinBase()
 	}
 
-	// Disassemble script into single line printable format.
-	// The disassembled string will contain [error] inline if the script
-	// doesn't fully parse, so ignore the error here.
-	disbuf, _ := txscript.DisasmString(pkScript)
-
-	// Get further info about the script.
-	// Ignore the error here since an error means the script couldn't parse
-	// and there is no additional information about it anyways.
-	scriptClass, addrs, reqSigs, _ := txscript.ExtractPkScriptAddrs(pkScript,
-		s.cfg.ChainParams)
-	addresses := make([]string, len(addrs))
-	for i, addr := range addrs {
-		addresses[i] = addr.EncodeAddress()
+	hashes := make([]string, 0, list.Count())
+	infos := make([]*btcjson.ProTxInfoResult, 0, list.Count())
+	for _, mn := range list.Masternodes() {
+		if !include(mn) {
+			continue
+		}
+		if detailed {
+			infos = append(infos, proTxInfoResult(s, list, mn))
+			continue
+		}
+		hashes = append(hashes, mn.ProTxHash.String())
 	}
-
-	txOutReply := &btcjson.GetTxOutResult{
-		BestBlock:     bestBlockHash,
-		Confirmations: int64(confirmations),
-		Value:         dashutil.Amount(value).ToBTC(),
-		ScriptPubKey: btcjson.ScriptPubKeyResult{
-			Asm:       disbuf,
-			Hex:       hex.EncodeToString(pkScript),
-			ReqSigs:   int32(reqSigs),
-			Type:      scriptClass.String(),
-			Addresses: addresses,
-		},
-		Coinbase: isCoinbase,
+	if detailed {
+		return infos, nil
 	}
-	return txOutReply, nil
+	return hashes, nil
 }
 
-// handleHelp implements the help command.
-func handleHelp(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
-	c := cmd.(*btcjson.HelpCmd)
-
-	// Provide a usage overview of all commands when no specific command
-	// was specified.
-	var command string
-	if c.Command != nil {
-		command = *c.Command
+// handleProTxInfo handles the info sub command of the protx command.
+func handleProTxInfo(s *rpcServer, c *btcjson.ProTxCmd) (interface{}, error) {
+	if c.Arg1 == nil {
+		return nil, proTxArgError("proTxHash", "given")
 	}
-	if command == "" {
-		usage, err := s.helpCacher.rpcUsage(false)
+	hashStr, ok := c.Arg1.Value.(string)
+	if !ok {
+		return nil, proTxArgError("proTxHash", "a string")
+	}
+	proTxHash, err := chainhash.NewHashFromStr(hashStr)
+	if err != nil {
+		return nil, rpcDecodeHexError(hashStr)
+	}
+
+	list := s.cfg.MNList.List()
+	if c.Arg2 != nil {
+		blockHash, ok := c.Arg2.Value.(string)
+		if !ok {
+			return nil, proTxArgError("blockHash", "a string")
+		}
+		list, err = masternodeListAtHash(s, blockHash)
 		if err != nil {
-			context := "Failed to generate RPC usage"
-			return nil, internalRPCError(err.Error(), context)
+			return nil, err
 		}
-		return usage, nil
 	}
 
-	// Check that the command asked for is supported and implemented.  Only
-	// search the main list of handlers since help should not be provided
-	// for commands that are unimplemented or related to wallet
-	// functionality.
-	if _, ok := rpcHandlers[command]; !ok {
+	mn := list.Masternode(proTxHash)
+	if mn == nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidAddressOrKey,
+			Message: fmt.Sprintf("%s not found", proTxHash),
+		}
+	}
+	return proTxInfoResult(s, list, mn), nil
+}
+
+// handleProTxDiff handles the diff sub command of the protx command.
+func handleProTxDiff(s *rpcServer, c *btcjson.ProTxCmd) (interface{}, error) {
+	// The blocks may be given either by hash or by height.
+	blockList := func(arg *btcjson.ProTxArg, name string) (*evo.List, error) {
+		if arg == nil {
+			return nil, proTxArgError(name, "given")
+		}
+		switch v := arg.Value.(type) {
+		case string:
+			return masternodeListAtHash(s, v)
+		case int:
+			return masternodeListAt(s, int32(v))
+		}
+		return nil, proTxArgError(name, "a block hash or height")
+	}
+	baseList, err := blockList(c.Arg1, "baseBlock")
+	if err != nil {
+		return nil, err
+	}
+	list, err := blockList(c.Arg2, "block")
+	if err != nil {
+		return nil, err
+	}
+	if baseList.Height() > list.Height() {
 		return nil, &btcjson.RPCError{
 			Code:    btcjson.ErrRPCInvalidParameter,
-			Message: "Unknown command: " + command,
+			Message: "Base block is after block",
+		}
+	}
+	var extended bool
+	if c.Arg3 != nil {
+		v, ok := c.Arg3.Value.(bool)
+		if !ok {
+			return nil, proTxArgError("extended", "a boolean")
 		}
+		extended = v
 	}
 
-	// Get the help for the command.
-	help, err := s.helpCacher.rpcMethodHelp(command)
+	blockHash := list.BlockHash()
+	block, err := s.cfg.Chain.BlockByHash(&blockHash)
 	if err != nil {
-		context := "Failed to generate help"
+		context := "Failed to load block"
 		return nil, internalRPCError(err.Error(), context)
 	}
-	return help, nil
-}
 
-// handlePing implements the ping command.
-func handlePing(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
-	// Ask server to ping \o_
-	nonce, err := wire.RandomUint64()
+	// The coinbase is proven by a partial merkle tree which only matches
+	// the coinbase.  The header is not part of the proof.
+	coinbase := block.Transactions()[0]
+	merkleBlock, _ := bloom.NewMerkleBlock(block,
+		txHashFilter([]*chainhash.Hash{coinbase.Hash()}))
+	var buf bytes.Buffer
+	err = merkleBlock.BtcEncode(&buf, maxProtocolVersion, wire.BaseEncoding)
 	if err != nil {
-		return nil, internalRPCError("Not sending ping - failed to "+
-			"generate nonce: "+err.Error(), "")
+		context := "Failed to serialize merkle block"
+		return nil, internalRPCError(err.Error(), context)
+	}
+	merkleTree := buf.Bytes()[wire.MaxBlockHeaderPayload:]
+	cbTxHex, err := messageToHex(coinbase.MsgTx())
+	if err != nil {
+		return nil, err
 	}
-	s.cfg.ConnMgr.BroadcastMessage(wire.NewMsgPing(nonce))
 
-	return nil, nil
+	diff := baseList.Diff(list)
+	result := &btcjson.ProTxDiffResult{
+		Version:        1,
+		BaseBlockHash:  diff.BaseBlockHash.String(),
+		BlockHash:      diff.BlockHash.String(),
+		CbTxMerkleTree: hex.EncodeToString(merkleTree),
+		CbTx:           cbTxHex,
+		DeletedMNs:     make([]string, 0, len(diff.Removed)),
+		MNList: make([]btcjson.SimplifiedMNListEntryResult, 0,
+			len(diff.Added)+len(diff.Updated)),
+	}
+	for i := range diff.Removed {
+		result.DeletedMNs = append(result.DeletedMNs,
+			diff.Removed[i].String())
+	}
+	params := s.cfg.ChainParams
+	for _, mns := range [][]*evo.Masternode{diff.Added, diff.Updated} {
+		for _, mn := range mns {
+			result.MNList = append(result.MNList,
+				simplifiedMNListEntry(mn, extended, params))
+		}
+	}
+	payload, err := coinbase.MsgTx().CoinbasePayload()
+	if err == nil {
+		result.MerkleRootMNList = payload.MerkleRootMNList.String()
+	}
+	return result, nil
 }
 
 // retrievedTx represents a transaction that was either loaded from the
@@ -3400,6 +4745,63 @@ func handleSendRawTransaction(s *rpcServer, cmd interface{}, closeChan <-chan st
 	return tx.Hash().String(), nil
 }
 
+// handleSetBan implements the setban command.
+func handleSetBan(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.SetBanCmd)
+
+	if _, _, err := parseBanAddress(c.SubNet); err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCClientInvalidIPOrSubnet,
+			Message: err.Error(),
+		}
+	}
+
+	switch c.Command {
+	case btcjson.SBAdd:
+		// The ban lasts for the configured ban duration unless a ban
+		// time is given, which is either a number of seconds or an
+		// absolute unix time.
+		until := time.Now().Add(cfg.BanDuration)
+		if c.BanTime != nil && *c.BanTime != 0 {
+			if c.Absolute != nil && *c.Absolute {
+				until = time.Unix(*c.BanTime, 0)
+			} else {
+				until = time.Now().Add(time.Duration(*c.BanTime) *
+					time.Second)
+			}
+		}
+		if !until.After(time.Now()) {
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCInvalidParameter,
+				Message: "ban time is in the past",
+			}
+		}
+
+		if err := s.cfg.ConnMgr.BanSubnet(c.SubNet, until); err != nil {
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCClientNodeAlreadyAdded,
+				Message: "IP/subnet already banned",
+			}
+		}
+
+	case btcjson.SBRemove:
+		if err := s.cfg.ConnMgr.UnbanSubnet(c.SubNet); err != nil {
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCClientInvalidIPOrSubnet,
+				Message: "IP/subnet was not banned",
+			}
+		}
+
+	default:
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "invalid subcommand for setban",
+		}
+	}
+
+	return nil, nil
+}
+
 // handleSetGenerate implements the setgenerate command.
 func handleSetGenerate(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	c := cmd.(*btcjson.SetGenerateCmd)
@@ -3436,6 +4838,38 @@ func handleSetGenerate(s *rpcServer, cmd interface{}, closeChan <-chan struct{})
 	return nil, nil
 }
 
+// handleSignMessageWithPrivKey implements the signmessagewithprivkey command.
+func handleSignMessageWithPrivKey(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.SignMessageWithPrivKeyCmd)
+
+	// Decode the provided private key and make sure it is intended for the
+	// active network.
+	params := s.cfg.ChainParams
+	wif, err := dashutil.DecodeWIF(c.PrivKey)
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidAddressOrKey,
+			Message: "Invalid private key: " + err.Error(),
+		}
+	}
+	if !wif.IsForNet(params) {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidAddressOrKey,
+			Message: "Private key is not for " + params.Name,
+		}
+	}
+
+	messageHash := signedMessageHash(params, c.Message)
+	sig, err := btcec.SignCompact(btcec.S256(), wif.PrivKey, messageHash,
+		wif.CompressPubKey)
+	if err != nil {
+		context := "Failed to sign message"
+		return nil, internalRPCError(err.Error(), context)
+	}
+
+	return base64.StdEncoding.EncodeToString(sig), nil
+}
+
 // handleStop implements the stop command.
 func handleStop(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	select {
@@ -3584,10 +5018,7 @@ func handleVerifyMessage(s *rpcServer, cmd interface{}, closeChan <-chan struct{
 
 	// Validate the signature - this just shows that it was valid at all.
 	// we will compare it with the key next.
-	var buf bytes.Buffer
-	wire.WriteVarString(&buf, 0, "Bitcoin Signed Message:\n")
-	wire.WriteVarString(&buf, 0, c.Message)
-	expectedMessageHash := chainhash.DoubleHashB(buf.Bytes())
+	expectedMessageHash := signedMessageHash(params, c.Message)
 	pk, wasCompressed, err := btcec.RecoverCompact(btcec.S256(), sig,
 		expectedMessageHash)
 	if err != nil {
@@ -3614,6 +5045,16 @@ func handleVerifyMessage(s *rpcServer, cmd interface{}, closeChan <-chan struct{
 	return address.EncodeAddress() == c.Address, nil
 }
 
+// signedMessageHash returns the hash which is signed by signmessagewithprivkey
+// and checked by verifymessage.  It commits to the message magic of the passed
+// network so signatures can't be replayed across coins.
+func signedMessageHash(params *chaincfg.Params, message string) []byte {
+	var buf bytes.Buffer
+	wire.WriteVarString(&buf, 0, params.MessageMagic)
+	wire.WriteVarString(&buf, 0, message)
+	return chainhash.DoubleHashB(buf.Bytes())
+}
+
 // handleVersion implements the version command.
 //
 // NOTE: This is a btcsuite extension ported from github.com/decred/dcrd.
@@ -4160,6 +5601,10 @@ type rpcserverPeer interface {
 	// FeeFilter returns the requested current minimum fee rate for which
 	// transactions should be announced.
 	FeeFilter() int64
+
+	// MappedAS returns the number of the autonomous system the peer
+	// belongs to according to the asmap, or 0 when it is unknown.
+	MappedAS() uint32
 }
 
 // rpcserverConnManager represents a connection manager for use with the RPC
@@ -4195,6 +5640,22 @@ type rpcserverConnManager interface {
 	// error.
 	DisconnectByAddr(addr string) error
 
+	// BanSubnet bans the passed subnet, IP address, or Tor or I2P host
+	// until the passed time and disconnects its peers.  Attempting to ban
+	// a subnet which is already banned will return an error.
+	BanSubnet(subnet string, until time.Time) error
+
+	// UnbanSubnet lifts the ban of the passed subnet, IP address, or Tor
+	// or I2P host.  Attempting to unban a subnet which is not banned will
+	// return an error.
+	UnbanSubnet(subnet string) error
+
+	// BannedSubnets returns the bans which have not expired yet.
+	BannedSubnets() []*banEntry
+
+	// ClearBanned lifts all bans.
+	ClearBanned()
+
 	// ConnectedCount returns the number of currently connected peers.
 	ConnectedCount() int32
 
@@ -4291,9 +5752,15 @@ type rpcserverConfig struct {
 
 	// These fields define any optional indexes the RPC server can make use
 	// of to provide additional data when queried.
-	TxIndex   *indexers.TxIndex
-	AddrIndex *indexers.AddrIndex
-	CfIndex   *indexers.CfIndex
+	TxIndex        *indexers.TxIndex
+	AddrIndex      *indexers.AddrIndex
+	AddrDeltaIndex *indexers.AddrDeltaIndex
+	SpentIndex     *indexers.SpentIndex
+	TimestampIndex *indexers.TimestampIndex
+	CfIndex        *indexers.CfIndex
+
+	// MNList maintains the deterministic masternode list of the main chain.
+	MNList *evo.Manager
 
 	// The fee estimator keeps track of how long transactions are left in
 	// the mempool before they are mined into blocks.
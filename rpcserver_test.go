@@ -0,0 +1,86 @@
+// Copyright (c) 2013-2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/eager7/dashd/btcjson"
+	"github.com/eager7/dashd/chaincfg"
+)
+
+// TestSignVerifyMessage ensures messages signed by signmessagewithprivkey
+// match the signatures Core produces for the same key and message, and that
+// verifymessage accepts them for the signed message only.
+func TestSignVerifyMessage(t *testing.T) {
+	// Signatures are deterministic (RFC 6979), so signing the message of
+	// the signmessage functional test of Bitcoin Core with its key and
+	// message magic must reproduce the signature the test expects, which
+	// pins the signing scheme.  The second vector signs the same message
+	// with the Dash message magic, as dashd's signmessage does.
+	bitcoinParams := chaincfg.TestNet3Params
+	bitcoinParams.MessageMagic = "Bitcoin Signed Message:\n"
+	tests := []struct {
+		name    string
+		params  *chaincfg.Params
+		privKey string
+		address string
+		message string
+		sig     string
+	}{
+		{
+			name:    "bitcoin core",
+			params:  &bitcoinParams,
+			privKey: "cUeKHd5orzT3mz8P9pxyREHfsWtVfgsfDjiZZBcjUBAaGk1BTj7N",
+			address: "yV8uHoxTjpYbv4714UAUZoctcTRV9vrFSm",
+			message: "This is just a test message",
+			sig:     "INbVnW4e6PeRmsv2Qgu8NuopvrVjkcxob+sX8OcZG0SALhWybUjzMLPdAsXI46YZGb0KQTRii+wWIQzRpG/U+S0=",
+		},
+		{
+			name:    "dash",
+			params:  &chaincfg.TestNet3Params,
+			privKey: "cUeKHd5orzT3mz8P9pxyREHfsWtVfgsfDjiZZBcjUBAaGk1BTj7N",
+			address: "yV8uHoxTjpYbv4714UAUZoctcTRV9vrFSm",
+			message: "This is just a test message",
+			sig:     "IOBNWgGelxBxvgwEL1ne8noGI2HyskijKqay1MNNJ9fCbHJe7/GNBYS4tD99uXCl0ze3Z8r0C9QD1E6K3oEfKsk=",
+		},
+	}
+
+	for _, test := range tests {
+		s := &rpcServer{cfg: rpcserverConfig{ChainParams: test.params}}
+		signCmd := btcjson.NewSignMessageWithPrivKeyCmd(test.privKey,
+			test.message)
+		sig, err := handleSignMessageWithPrivKey(s, signCmd, nil)
+		if err != nil {
+			t.Errorf("%s: signmessagewithprivkey: unexpected error %v",
+				test.name, err)
+			continue
+		}
+		if sig != test.sig {
+			t.Errorf("%s: signmessagewithprivkey: got %v, want %v",
+				test.name, sig, test.sig)
+		}
+
+		verifyCmd := btcjson.NewVerifyMessageCmd(test.address, test.sig,
+			test.message)
+		valid, err := handleVerifyMessage(s, verifyCmd, nil)
+		if err != nil {
+			t.Errorf("%s: verifymessage: unexpected error %v",
+				test.name, err)
+			continue
+		}
+		if valid != true {
+			t.Errorf("%s: verifymessage: signature not valid",
+				test.name)
+		}
+
+		verifyCmd.Message += "!"
+		valid, err = handleVerifyMessage(s, verifyCmd, nil)
+		if err != nil || valid != false {
+			t.Errorf("%s: verifymessage: altered message valid "+
+				"(%v, %v)", test.name, valid, err)
+		}
+	}
+}
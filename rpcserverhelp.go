@@ -40,6 +40,9 @@ var helpDescsEnUS = map[string]string{
 	"node-target":        "Either the IP address and port of the peer to operate on, or a valid peer ID.",
 	"node-connectsubcmd": "'perm' to make the connected peer a permanent one, 'temp' to try a single connect to a peer",
 
+	// ClearBannedCmd help.
+	"clearbanned--synopsis": "Lifts all bans of IP addresses and subnets.",
+
 	// TransactionInput help.
 	"transactioninput-txid": "The hash of the input transaction",
 	"transactioninput-vout": "The specific output of the input transaction to redeem",
@@ -92,13 +95,43 @@ var helpDescsEnUS = map[string]string{
 	"vout-value":        "The amount in BTC",
 	"vout-n":            "The index of this transaction output",
 	"vout-scriptPubKey": "The public key script used to pay coins as a JSON object",
+	"vout-spentTxId":    "The hash of the transaction spending this output (only with --spentindex)",
+	"vout-spentIndex":   "The index of the input spending this output (only with --spentindex)",
+	"vout-spentHeight":  "The height of the block containing the spending transaction, or -1 when it is unconfirmed (only with --spentindex)",
 
 	// TxRawDecodeResult help.
-	"txrawdecoderesult-txid":     "The hash of the transaction",
-	"txrawdecoderesult-version":  "The transaction version",
-	"txrawdecoderesult-locktime": "The transaction lock time",
-	"txrawdecoderesult-vin":      "The transaction inputs as JSON objects",
-	"txrawdecoderesult-vout":     "The transaction outputs as JSON objects",
+	"txrawdecoderesult-txid":             "The hash of the transaction",
+	"txrawdecoderesult-version":          "The transaction version",
+	"txrawdecoderesult-type":             "The special transaction type (0 for normal transactions)",
+	"txrawdecoderesult-locktime":         "The transaction lock time",
+	"txrawdecoderesult-vin":              "The transaction inputs as JSON objects",
+	"txrawdecoderesult-vout":             "The transaction outputs as JSON objects",
+	"txrawdecoderesult-extraPayloadSize": "The size of the special transaction payload in bytes (only for special transactions)",
+	"txrawdecoderesult-extraPayload":     "The hex-encoded special transaction payload (only for special transactions)",
+	"txrawdecoderesult-cbTx":             "The decoded coinbase payload (only for coinbase special transactions)",
+	"txrawdecoderesult-assetLockTx":      "The decoded asset lock payload (only for asset lock transactions)",
+	"txrawdecoderesult-assetUnlockTx":    "The decoded asset unlock payload (only for asset unlock transactions)",
+
+	// CbTxResult help.
+	"cbtxresult-version":           "The coinbase payload version",
+	"cbtxresult-height":            "The height of the block containing the coinbase",
+	"cbtxresult-merkleRootMNList":  "The merkle root of the deterministic masternode list",
+	"cbtxresult-merkleRootQuorums": "The merkle root of the active quorums (payload version 2 and later)",
+	"cbtxresult-bestCLHeightDiff":  "The number of blocks between the coinbase and the best known chainlock (payload version 3 and later)",
+	"cbtxresult-bestCLSignature":   "The hex-encoded signature of the best known chainlock (payload version 3 and later)",
+	"cbtxresult-creditPoolBalance": "The balance of the credit pool in DASH (payload version 3 and later)",
+
+	// AssetLockTxResult help.
+	"assetlocktxresult-version":       "The asset lock payload version",
+	"assetlocktxresult-creditOutputs": "The outputs credited on Platform as JSON objects",
+
+	// AssetUnlockTxResult help.
+	"assetunlocktxresult-version":         "The asset unlock payload version",
+	"assetunlocktxresult-index":           "The withdrawal index",
+	"assetunlocktxresult-fee":             "The fee in duffs paid to the miner out of the credit pool",
+	"assetunlocktxresult-requestedHeight": "The height at which Platform requested the withdrawal",
+	"assetunlocktxresult-quorumHash":      "The hash of the quorum which signed the withdrawal",
+	"assetunlocktxresult-quorumSig":       "The hex-encoded quorum signature",
 
 	// DecodeRawTransactionCmd help.
 	"decoderawtransaction--synopsis": "Returns a JSON object representing the provided serialized, hex-encoded transaction.",
@@ -115,6 +148,23 @@ var helpDescsEnUS = map[string]string{
 	"decodescript--synopsis": "Returns a JSON object with information about the provided hex-encoded script.",
 	"decodescript-hexscript": "Hex-encoded script",
 
+	// DisconnectNodeCmd help.
+	"disconnectnode--synopsis": "Disconnects a non-persistent peer identified by either its address or its ID.",
+	"disconnectnode-address":   "The IP address and port of the peer, which must be empty when the node ID is given",
+	"disconnectnode-nodeid":    "The ID of the peer as returned by getpeerinfo",
+
+	// DumpTxOutSetCmd help.
+	"dumptxoutset--synopsis": "Writes a snapshot of the utxo set at the current best block to a file, which can be loaded by a new node with loadtxoutset.",
+	"dumptxoutset-path":      "The path of the snapshot file, relative to the data directory unless absolute",
+
+	// DumpTxOutSetResult help.
+	"dumptxoutsetresult-coins_written": "The number of unspent outputs written",
+	"dumptxoutsetresult-base_hash":     "The hash of the block the snapshot was taken at",
+	"dumptxoutsetresult-base_height":   "The height of the block the snapshot was taken at",
+	"dumptxoutsetresult-path":          "The absolute path of the snapshot file",
+	"dumptxoutsetresult-txoutset_hash": "The content hash of the snapshot",
+	"dumptxoutsetresult-nchaintx":      "The total number of transactions in the chain up to the snapshot block",
+
 	// EstimateFeeCmd help.
 	"estimatefee--synopsis": "Estimate the fee per kilobyte in satoshis " +
 		"required for a transaction to be mined before a certain number of " +
@@ -155,10 +205,110 @@ var helpDescsEnUS = map[string]string{
 	"getbestblock--synopsis": "Get block height and hash of best block in the main chain.",
 	"getbestblock--result0":  "Get block height and hash of best block in the main chain.",
 
+	// AddressIndexRequest help.
+	"addressindexrequest-addresses": "The addresses to query",
+	"addressindexrequest-start":     "The height of the first block to include",
+	"addressindexrequest-end":       "The height of the last block to include",
+	"addressindexrequest-chainInfo": "Include information about the chain in the result",
+
+	// GetAddressBalanceCmd help.
+	"getaddressbalance--synopsis": "Returns the balance of the passed addresses.\n" +
+		"Usage of this RPC requires the optional --addressindex flag to be activated.",
+	"getaddressbalance-request": "An address or an object with the addresses to query",
+
+	// GetAddressBalanceResult help.
+	"getaddressbalanceresult-balance":           "The current balance in duffs",
+	"getaddressbalanceresult-balance_immature":  "The current immature balance of coinbase outputs in duffs",
+	"getaddressbalanceresult-balance_spendable": "The current spendable balance in duffs",
+	"getaddressbalanceresult-received":          "The total number of duffs received, including change",
+
+	// GetAddressDeltasCmd help.
+	"getaddressdeltas--synopsis": "Returns the changes to the balance of the passed addresses, optionally limited to a range of block heights.\n" +
+		"Usage of this RPC requires the optional --addressindex flag to be activated.",
+	"getaddressdeltas-request":     "An address or an object with the addresses and the block range to query",
+	"getaddressdeltas--condition0": "chainInfo=false or no block range",
+	"getaddressdeltas--condition1": "chainInfo=true and a block range",
+
+	// AddressDeltaResult help.
+	"addressdeltaresult-satoshis":   "The change of the balance in duffs, which is negative for spends",
+	"addressdeltaresult-txid":       "The hash of the transaction",
+	"addressdeltaresult-index":      "The index of the output, or of the input for spends",
+	"addressdeltaresult-blockindex": "The index of the transaction in the block",
+	"addressdeltaresult-height":     "The height of the block",
+	"addressdeltaresult-address":    "The address",
+
+	// AddressBlockResult help.
+	"addressblockresult-hash":   "The hash of the block",
+	"addressblockresult-height": "The height of the block",
+
+	// GetAddressDeltasChainInfoResult help.
+	"getaddressdeltaschaininforesult-deltas": "The changes to the balance",
+	"getaddressdeltaschaininforesult-start":  "The first block of the range",
+	"getaddressdeltaschaininforesult-end":    "The last block of the range",
+
+	// GetAddressMempoolCmd help.
+	"getaddressmempool--synopsis": "Returns the changes to the balance of the passed addresses by the transactions in the memory pool.\n" +
+		"Usage of this RPC requires the optional --addressindex flag to be activated.",
+	"getaddressmempool-request": "An address or an object with the addresses to query",
+
+	// AddressMempoolResult help.
+	"addressmempoolresult-address":   "The address",
+	"addressmempoolresult-txid":      "The hash of the transaction",
+	"addressmempoolresult-index":     "The index of the output, or of the input for spends",
+	"addressmempoolresult-satoshis":  "The change of the balance in duffs, which is negative for spends",
+	"addressmempoolresult-timestamp": "The time the transaction entered the memory pool",
+	"addressmempoolresult-prevtxid":  "The hash of the transaction of the spent output",
+	"addressmempoolresult-prevout":   "The index of the spent output",
+
+	// GetAddressTxIDsCmd help.
+	"getaddresstxids--synopsis": "Returns the hashes of the transactions involving the passed addresses, optionally limited to a range of block heights.\n" +
+		"Usage of this RPC requires the optional --addressindex flag to be activated.",
+	"getaddresstxids-request":  "An address or an object with the addresses and the block range to query",
+	"getaddresstxids--result0": "The hashes of the transactions in the order they appear in the chain",
+
+	// GetAddressUtxosCmd help.
+	"getaddressutxos--synopsis": "Returns the unspent outputs paying the passed addresses.\n" +
+		"Usage of this RPC requires the optional --addressindex flag to be activated.",
+	"getaddressutxos-request":     "An address or an object with the addresses to query",
+	"getaddressutxos--condition0": "chainInfo=false",
+	"getaddressutxos--condition1": "chainInfo=true",
+
+	// AddressUtxoResult help.
+	"addressutxoresult-address":     "The address",
+	"addressutxoresult-txid":        "The hash of the transaction",
+	"addressutxoresult-outputIndex": "The index of the output",
+	"addressutxoresult-script":      "The hex-encoded public key script of the output",
+	"addressutxoresult-satoshis":    "The amount of the output in duffs",
+	"addressutxoresult-height":      "The height of the block containing the transaction",
+
+	// GetAddressUtxosChainInfoResult help.
+	"getaddressutxoschaininforesult-utxos":  "The unspent outputs",
+	"getaddressutxoschaininforesult-hash":   "The hash of the best block",
+	"getaddressutxoschaininforesult-height": "The height of the best block",
+
+	// GetAssetUnlockStatusesCmd help.
+	"getassetunlockstatuses--synopsis": "Returns the status of the asset unlocks with the given withdrawal indexes.",
+	"getassetunlockstatuses-indexes":   "The withdrawal indexes to look up",
+	"getassetunlockstatuses-height":    "Report the statuses as of this height instead of the best chain, ignoring the memory pool",
+
+	// AssetUnlockStatusResult help.
+	"assetunlockstatusresult-index":  "The withdrawal index",
+	"assetunlockstatusresult-status": "The status of the withdrawal (mined, mempooled or unknown)",
+
 	// GetBestBlockHashCmd help.
 	"getbestblockhash--synopsis": "Returns the hash of the of the best (most recent) block in the longest block chain.",
 	"getbestblockhash--result0":  "The hex-encoded block hash",
 
+	// GetBestChainLockCmd help.
+	"getbestchainlock--synopsis": "Returns the best chainlock the coinbase of the best block commits to.\n" +
+		"Chainlock signatures are not verified.",
+
+	// GetBestChainLockResult help.
+	"getbestchainlockresult-blockhash":   "The hash of the chainlocked block",
+	"getbestchainlockresult-height":      "The height of the chainlocked block",
+	"getbestchainlockresult-signature":   "The hex-encoded signature of the chainlock",
+	"getbestchainlockresult-known_block": "Whether or not the chainlocked block is known",
+
 	// GetBlockCmd help.
 	"getblock--synopsis":   "Returns information about a block given its hash.",
 	"getblock-hash":        "The hash of the block",
@@ -204,20 +354,26 @@ var helpDescsEnUS = map[string]string{
 	"unifiedsoftforks-softforks--desc":  "JSON object describing an active softfork deployment used by bitcoind on or after v0.19.0",
 
 	// TxRawResult help.
-	"txrawresult-hex":           "Hex-encoded transaction",
-	"txrawresult-txid":          "The hash of the transaction",
-	"txrawresult-version":       "The transaction version",
-	"txrawresult-locktime":      "The transaction lock time",
-	"txrawresult-vin":           "The transaction inputs as JSON objects",
-	"txrawresult-vout":          "The transaction outputs as JSON objects",
-	"txrawresult-blockhash":     "Hash of the block the transaction is part of",
-	"txrawresult-confirmations": "Number of confirmations of the block",
-	"txrawresult-time":          "Transaction time in seconds since 1 Jan 1970 GMT",
-	"txrawresult-blocktime":     "Block time in seconds since the 1 Jan 1970 GMT",
-	"txrawresult-size":          "The size of the transaction in bytes",
-	"txrawresult-vsize":         "The virtual size of the transaction in bytes",
-	"txrawresult-weight":        "The transaction's weight (between vsize*4-3 and vsize*4)",
-	"txrawresult-hash":          "The wtxid of the transaction",
+	"txrawresult-hex":              "Hex-encoded transaction",
+	"txrawresult-txid":             "The hash of the transaction",
+	"txrawresult-version":          "The transaction version",
+	"txrawresult-type":             "The special transaction type (0 for normal transactions)",
+	"txrawresult-locktime":         "The transaction lock time",
+	"txrawresult-vin":              "The transaction inputs as JSON objects",
+	"txrawresult-vout":             "The transaction outputs as JSON objects",
+	"txrawresult-blockhash":        "Hash of the block the transaction is part of",
+	"txrawresult-confirmations":    "Number of confirmations of the block",
+	"txrawresult-time":             "Transaction time in seconds since 1 Jan 1970 GMT",
+	"txrawresult-blocktime":        "Block time in seconds since the 1 Jan 1970 GMT",
+	"txrawresult-size":             "The size of the transaction in bytes",
+	"txrawresult-vsize":            "The virtual size of the transaction in bytes",
+	"txrawresult-weight":           "The transaction's weight (between vsize*4-3 and vsize*4)",
+	"txrawresult-hash":             "The wtxid of the transaction",
+	"txrawresult-extraPayloadSize": "The size of the special transaction payload in bytes (only for special transactions)",
+	"txrawresult-extraPayload":     "The hex-encoded special transaction payload (only for special transactions)",
+	"txrawresult-cbTx":             "The decoded coinbase payload (only for coinbase special transactions)",
+	"txrawresult-assetLockTx":      "The decoded asset lock payload (only for asset lock transactions)",
+	"txrawresult-assetUnlockTx":    "The decoded asset unlock payload (only for asset unlock transactions)",
 
 	// SearchRawTransactionsResult help.
 	"searchrawtransactionsresult-hex":           "Hex-encoded transaction",
@@ -253,6 +409,7 @@ var helpDescsEnUS = map[string]string{
 	"getblockverboseresult-nextblockhash":     "The hash of the next block (only if there is one)",
 	"getblockverboseresult-strippedsize":      "The size of the block without witness data",
 	"getblockverboseresult-weight":            "The weight of the block",
+	"getblockverboseresult-creditPoolBalance": "The balance of the credit pool as of this block in DASH (only once asset locks are active)",
 
 	// GetBlockCountCmd help.
 	"getblockcount--synopsis": "Returns the number of blocks in the longest block chain.",
@@ -263,6 +420,24 @@ var helpDescsEnUS = map[string]string{
 	"getblockhash-index":     "The block height",
 	"getblockhash--result0":  "The block hash",
 
+	// GetBlockHashesOptions help.
+	"getblockhashesoptions-noOrphans":    "Only include blocks in the main chain",
+	"getblockhashesoptions-logicalTimes": "Include the logical timestamp of each block",
+
+	// GetBlockHashesCmd help.
+	"getblockhashes--synopsis": "Returns the hashes of the blocks with a logical timestamp in the passed range.\n" +
+		"This requires the timestamp index to be enabled (--timestampindex).",
+	"getblockhashes-high":        "The timestamp the logical timestamps are less than",
+	"getblockhashes-low":         "The timestamp the logical timestamps are greater than or equal to",
+	"getblockhashes-options":     "Options for the result",
+	"getblockhashes--condition0": "logicalTimes=false",
+	"getblockhashes--condition1": "logicalTimes=true",
+	"getblockhashes--result0":    "The hashes of the blocks",
+
+	// GetBlockHashesResult help.
+	"getblockhashesresult-blockhash": "The hash of the block",
+	"getblockhashesresult-logicalts": "The logical timestamp of the block",
+
 	// GetBlockHeaderCmd help.
 	"getblockheader--synopsis":   "Returns information about a block header given its hash.",
 	"getblockheader-hash":        "The hash of the block",
@@ -474,6 +649,7 @@ var helpDescsEnUS = map[string]string{
 	"getpeerinforesult-banscore":       "The ban score",
 	"getpeerinforesult-feefilter":      "The requested minimum fee a transaction must have to be announced to the peer",
 	"getpeerinforesult-syncnode":       "Whether or not the peer is the sync peer",
+	"getpeerinforesult-mapped_as":      "The autonomous system the peer belongs to according to the asmap (omitted without one)",
 
 	// GetPeerInfoCmd help.
 	"getpeerinfo--synopsis": "Returns data about each connected network peer as an array of json objects.",
@@ -504,6 +680,20 @@ var helpDescsEnUS = map[string]string{
 	"getrawtransaction--condition1": "verbose=true",
 	"getrawtransaction--result0":    "Hex-encoded bytes of the serialized transaction",
 
+	// SpentInfoRequest help.
+	"spentinforequest-txid":  "The hash of the transaction which created the output",
+	"spentinforequest-index": "The index of the output",
+
+	// GetSpentInfoCmd help.
+	"getspentinfo--synopsis": "Returns the input which spends the passed transaction output.\n" +
+		"This requires the spent index to be enabled (--spentindex).",
+	"getspentinfo-request": "An object with the output to query",
+
+	// GetSpentInfoResult help.
+	"getspentinforesult-txid":   "The hash of the spending transaction",
+	"getspentinforesult-index":  "The index of the spending input",
+	"getspentinforesult-height": "The height of the block containing the spending transaction, or -1 when it is unconfirmed",
+
 	// GetTxOutResult help.
 	"gettxoutresult-bestblock":     "The block hash that contains the transaction output",
 	"gettxoutresult-confirmations": "The number of confirmations",
@@ -518,6 +708,23 @@ var helpDescsEnUS = map[string]string{
 	"gettxout-vout":           "The index of the output",
 	"gettxout-includemempool": "Include the mempool when true",
 
+	// GetTxOutSetInfoCmd help.
+	"gettxoutsetinfo--synopsis":    "Returns statistics about the unspent transaction output set as of a block in the main chain.",
+	"gettxoutsetinfo-hashtype":     "The hash of the utxo set to calculate, either the MuHash3072 compatible with Bitcoin Core (muhash) or none",
+	"gettxoutsetinfo-hashorheight": "The hash or height of the block the statistics are returned as of (default: the best block)",
+
+	// HashOrHeight help.
+	"hashorheight-value": "The hash or height of the block",
+
+	// GetTxOutSetInfoResult help.
+	"gettxoutsetinforesult-height":       "The height of the block",
+	"gettxoutsetinforesult-bestblock":    "The hash of the block",
+	"gettxoutsetinforesult-transactions": "The number of transactions in the chain up to and including the block",
+	"gettxoutsetinforesult-txouts":       "The number of unspent transaction outputs",
+	"gettxoutsetinforesult-muhash":       "The MuHash3072 of the unspent transaction outputs (only present for the muhash hash type)",
+	"gettxoutsetinforesult-disk_size":    "The size of the unspent transaction outputs in the database",
+	"gettxoutsetinforesult-total_amount": "The total amount of the unspent transaction outputs in BTC",
+
 	// HelpCmd help.
 	"help--synopsis":   "Returns a list of all commands or help for a specified command.",
 	"help-command":     "The command to retrieve help for",
@@ -526,10 +733,146 @@ var helpDescsEnUS = map[string]string{
 	"help--result0":    "List of commands",
 	"help--result1":    "Help for specified command",
 
+	// ListBannedCmd help.
+	"listbanned--synopsis": "Returns the banned IP addresses and subnets.",
+
+	// ListBannedResult help.
+	"listbannedresult-address":        "The banned subnet, IP address, or Tor or I2P host",
+	"listbannedresult-ban_created":    "Time the ban was created in seconds since 1 Jan 1970 GMT",
+	"listbannedresult-banned_until":   "Time the ban expires in seconds since 1 Jan 1970 GMT",
+	"listbannedresult-ban_duration":   "The total duration of the ban in seconds",
+	"listbannedresult-time_remaining": "The number of seconds until the ban expires",
+
+	// LoadTxOutSetCmd help.
+	"loadtxoutset--synopsis": "Loads a snapshot of the utxo set written by dumptxoutset into a node which has not processed any blocks.\n" +
+		"The snapshot must be pinned by the chain parameters.  The blocks below the snapshot are downloaded and validated in the background afterwards.",
+	"loadtxoutset-path": "The path of the snapshot file, relative to the data directory unless absolute",
+
+	// LoadTxOutSetResult help.
+	"loadtxoutsetresult-coins_loaded": "The number of unspent outputs loaded",
+	"loadtxoutsetresult-tip_hash":     "The hash of the block the snapshot was taken at, which is the new best block",
+	"loadtxoutsetresult-base_height":  "The height of the block the snapshot was taken at",
+	"loadtxoutsetresult-path":         "The absolute path of the snapshot file",
+
+	// MasternodeCmd help.
+	"masternode--synopsis":       "Returns information about the deterministic masternode list.",
+	"masternode-subcmd":          "The information to return: the number of masternodes (count) or the recent and upcoming payees (winners, not available since proof of service penalties are not processed)",
+	"masternode-count":           "The number of recent blocks to return the payees of (winners only)",
+	"masternode-filter":          "Only return payees which contain this string (winners only)",
+	"masternode--condition0":     "subcmd=count",
+	"masternode--condition1":     "subcmd=winners",
+	"masternode--result1--desc":  "Payee addresses keyed by block height",
+	"masternode--result1--key":   "Block height",
+	"masternode--result1--value": "The payout address, followed by the operator payout address if the operator takes a reward",
+
+	// MasternodeCountResult help.
+	"masternodecountresult-total":    "The number of masternodes, without the number of enabled ones since proof of service penalties are not processed",
+	"masternodecountresult-detailed": "The number of masternodes per type",
+
+	// MasternodeCountDetailedResult help.
+	"masternodecountdetailedresult-regular": "The number of regular masternodes",
+	"masternodecountdetailedresult-evo":     "The number of evo masternodes",
+
+	// MasternodeCountDetail help.
+	"masternodecountdetail-total": "The number of masternodes of the type",
+
+	// MasternodeListCmd help.
+	"masternodelist--synopsis":       "Returns the masternodes of the deterministic masternode list keyed by their collateral outpoint (txid-n).",
+	"masternodelist-mode":            "The information to return for each masternode (payee and status are not available and the masternode details omit them, along with the proof of service penalty and last payment, since proof of service penalties are not processed)",
+	"masternodelist-filter":          "Only return masternodes whose key or information contains this string",
+	"masternodelist--condition0":     "mode=json",
+	"masternodelist--condition1":     "mode!=json",
+	"masternodelist--result0--desc":  "Masternode details keyed by collateral outpoint",
+	"masternodelist--result0--key":   "Collateral outpoint (txid-n)",
+	"masternodelist--result0--value": "Masternode details",
+	"masternodelist--result1--desc":  "Masternode information keyed by collateral outpoint",
+	"masternodelist--result1--key":   "Collateral outpoint (txid-n)",
+	"masternodelist--result1--value": "The requested information of the masternode",
+
+	// MasternodeListResult help.
+	"masternodelistresult-proTxHash":         "The hash of the provider registration transaction",
+	"masternodelistresult-address":           "The service address of the masternode",
+	"masternodelistresult-type":              "The type of the masternode (Regular or Evo)",
+	"masternodelistresult-platformNodeID":    "The platform node id of evo masternodes",
+	"masternodelistresult-platformP2PPort":   "The platform P2P port of evo masternodes",
+	"masternodelistresult-platformHTTPPort":  "The platform HTTP port of evo masternodes",
+	"masternodelistresult-owneraddress":      "The owner address",
+	"masternodelistresult-votingaddress":     "The voting address",
+	"masternodelistresult-collateraladdress": "The address of the collateral",
+	"masternodelistresult-pubkeyoperator":    "The hex-encoded BLS public key of the operator",
+
 	// PingCmd help.
 	"ping--synopsis": "Queues a ping to be sent to each connected peer.\n" +
 		"Ping times are provided by getpeerinfo via the pingtime and pingwait fields.",
 
+	// ProTxCmd help.
+	"protx--synopsis": "Returns information about the deterministic masternode list.\n" +
+		"list [type] [detailed] [height]: the masternodes of the list, where type is registered (default), valid or evo.\n" +
+		"info proTxHash [blockHash]: the details of a masternode.\n" +
+		"diff baseBlock block [extended]: the changes of the simplified masternode list between two blocks given by hash or height.",
+	"protx-subcmd":      "The sub command",
+	"protx-arg1":        "The first argument of the sub command",
+	"protx-arg2":        "The second argument of the sub command",
+	"protx-arg3":        "The third argument of the sub command",
+	"protx--condition0": "subcmd=list and detailed=false",
+	"protx--condition1": "subcmd=list and detailed=true",
+	"protx--condition2": "subcmd=info",
+	"protx--condition3": "subcmd=diff",
+	"protx--result0":    "The hashes of the provider registration transactions",
+	"protxarg-value":    "A string, integer or boolean depending on the sub command",
+
+	// ProTxInfoResult help.
+	"protxinforesult-type":              "The type of the masternode (Regular or Evo)",
+	"protxinforesult-proTxHash":         "The hash of the provider registration transaction",
+	"protxinforesult-collateralHash":    "The hash of the collateral transaction",
+	"protxinforesult-collateralIndex":   "The output index of the collateral",
+	"protxinforesult-collateralAddress": "The address of the collateral",
+	"protxinforesult-operatorReward":    "The share of the payouts paid to the operator in percent",
+	"protxinforesult-state":             "The state of the masternode",
+	"protxinforesult-confirmations":     "The number of confirmations of the provider registration transaction",
+
+	// ProTxStateResult help.
+	"protxstateresult-version":               "The version of the provider registration",
+	"protxstateresult-service":               "The service address of the masternode",
+	"protxstateresult-registeredHeight":      "The height the masternode was registered at",
+	"protxstateresult-lastPaidHeight":        "The height the masternode was last paid at",
+	"protxstateresult-PoSePenalty":           "The proof of service penalty score",
+	"protxstateresult-PoSeRevivedHeight":     "The height the masternode was last revived at",
+	"protxstateresult-PoSeBanHeight":         "The height the masternode was banned at or -1 when it is not banned",
+	"protxstateresult-revocationReason":      "The reason the operator was revoked for",
+	"protxstateresult-ownerAddress":          "The owner address",
+	"protxstateresult-votingAddress":         "The voting address",
+	"protxstateresult-platformNodeID":        "The platform node id of evo masternodes",
+	"protxstateresult-platformP2PPort":       "The platform P2P port of evo masternodes",
+	"protxstateresult-platformHTTPPort":      "The platform HTTP port of evo masternodes",
+	"protxstateresult-payoutAddress":         "The payout address",
+	"protxstateresult-pubKeyOperator":        "The hex-encoded BLS public key of the operator",
+	"protxstateresult-operatorPayoutAddress": "The payout address of the operator",
+
+	// ProTxDiffResult help.
+	"protxdiffresult-nVersion":         "The version of the diff",
+	"protxdiffresult-baseBlockHash":    "The hash of the base block",
+	"protxdiffresult-blockHash":        "The hash of the block",
+	"protxdiffresult-cbTxMerkleTree":   "The hex-encoded partial merkle tree proving the coinbase of the block",
+	"protxdiffresult-cbTx":             "The hex-encoded coinbase of the block",
+	"protxdiffresult-deletedMNs":       "The hashes of the provider registrations of the removed masternodes",
+	"protxdiffresult-mnList":           "The added and updated entries of the simplified masternode list",
+	"protxdiffresult-merkleRootMNList": "The merkle root of the masternode list committed to by the coinbase",
+
+	// SimplifiedMNListEntryResult help.
+	"simplifiedmnlistentryresult-nVersion":              "The version of the provider registration",
+	"simplifiedmnlistentryresult-nType":                 "The type of the masternode (0 for regular, 1 for evo)",
+	"simplifiedmnlistentryresult-proRegTxHash":          "The hash of the provider registration transaction",
+	"simplifiedmnlistentryresult-confirmedHash":         "The hash of the block the registration was confirmed in",
+	"simplifiedmnlistentryresult-service":               "The service address of the masternode",
+	"simplifiedmnlistentryresult-pubKeyOperator":        "The hex-encoded BLS public key of the operator",
+	"simplifiedmnlistentryresult-votingAddress":         "The voting address",
+	"simplifiedmnlistentryresult-isValid":               "Whether or not the masternode is not banned",
+	"simplifiedmnlistentryresult-platformHTTPPort":      "The platform HTTP port of evo masternodes",
+	"simplifiedmnlistentryresult-platformNodeID":        "The platform node id of evo masternodes",
+	"simplifiedmnlistentryresult-payoutAddress":         "The payout address (extended only)",
+	"simplifiedmnlistentryresult-operatorPayoutAddress": "The payout address of the operator (extended only)",
+
 	// SearchRawTransactionsCmd help.
 	"searchrawtransactions--synopsis": "Returns raw data for transactions involving the passed address.\n" +
 		"Returned transactions are pulled from both the database, and transactions currently in the mempool.\n" +
@@ -554,11 +897,25 @@ var helpDescsEnUS = map[string]string{
 	"sendrawtransaction-maxfeerate":    "Used by bitcoind on or after v0.19.0",
 	"sendrawtransaction--result0":      "The hash of the transaction",
 
+	// SetBanCmd help.
+	"setban--synopsis": "Bans an IP address or subnet, or lifts its ban.  The peers of a banned subnet are disconnected.\n" +
+		"The ban is saved to the data directory so it persists across restarts.",
+	"setban-subnet":   "The IP address or subnet in CIDR notation (eg. 192.168.0.0/24), or a Tor or I2P host",
+	"setban-command":  "'add' to ban the subnet or 'remove' to lift its ban",
+	"setban-bantime":  "The number of seconds the ban lasts, or the unix time it expires at when absolute is set (0 for the configured ban duration)",
+	"setban-absolute": "Whether bantime is an absolute unix time instead of a number of seconds",
+
 	// SetGenerateCmd help.
 	"setgenerate--synopsis":    "Set the server to generate coins (mine) or not.",
 	"setgenerate-generate":     "Use true to enable generation, false to disable it",
 	"setgenerate-genproclimit": "The number of processors (cores) to limit generation to or -1 for default",
 
+	// SignMessageWithPrivKeyCmd help.
+	"signmessagewithprivkey--synopsis": "Sign a message with the private key of an address.",
+	"signmessagewithprivkey-privkey":   "The WIF-encoded private key to sign the message with",
+	"signmessagewithprivkey-message":   "The message to create a signature of",
+	"signmessagewithprivkey--result0":  "The base-64 encoded signature of the message",
+
 	// StopCmd help.
 	"stop--synopsis": "Shutdown btcd.",
 	"stop--result0":  "The string 'btcd stopping.'",
@@ -687,52 +1044,72 @@ var helpDescsEnUS = map[string]string{
 // This information is used to generate the help.  Each result type must be a
 // pointer to the type (or nil to indicate no return value).
 var rpcResultTypes = map[string][]interface{}{
-	"addnode":               nil,
-	"createrawtransaction":  {(*string)(nil)},
-	"debuglevel":            {(*string)(nil), (*string)(nil)},
-	"decoderawtransaction":  {(*btcjson.TxRawDecodeResult)(nil)},
-	"decodescript":          {(*btcjson.DecodeScriptResult)(nil)},
-	"estimatefee":           {(*float64)(nil)},
-	"generate":              {(*[]string)(nil)},
-	"getaddednodeinfo":      {(*[]string)(nil), (*[]btcjson.GetAddedNodeInfoResult)(nil)},
-	"getbestblock":          {(*btcjson.GetBestBlockResult)(nil)},
-	"getbestblockhash":      {(*string)(nil)},
-	"getblock":              {(*string)(nil), (*btcjson.GetBlockVerboseResult)(nil)},
-	"getblockcount":         {(*int64)(nil)},
-	"getblockhash":          {(*string)(nil)},
-	"getblockheader":        {(*string)(nil), (*btcjson.GetBlockHeaderVerboseResult)(nil)},
-	"getblocktemplate":      {(*btcjson.GetBlockTemplateResult)(nil), (*string)(nil), nil},
-	"getblockchaininfo":     {(*btcjson.GetBlockChainInfoResult)(nil)},
-	"getcfilter":            {(*string)(nil)},
-	"getcfilterheader":      {(*string)(nil)},
-	"getconnectioncount":    {(*int32)(nil)},
-	"getcurrentnet":         {(*uint32)(nil)},
-	"getdifficulty":         {(*float64)(nil)},
-	"getgenerate":           {(*bool)(nil)},
-	"gethashespersec":       {(*float64)(nil)},
-	"getheaders":            {(*[]string)(nil)},
-	"getinfo":               {(*btcjson.InfoChainResult)(nil)},
-	"getmempoolinfo":        {(*btcjson.GetMempoolInfoResult)(nil)},
-	"getmininginfo":         {(*btcjson.GetMiningInfoResult)(nil)},
-	"getnettotals":          {(*btcjson.GetNetTotalsResult)(nil)},
-	"getnetworkhashps":      {(*int64)(nil)},
-	"getpeerinfo":           {(*[]btcjson.GetPeerInfoResult)(nil)},
-	"getrawmempool":         {(*[]string)(nil), (*btcjson.GetRawMempoolVerboseResult)(nil)},
-	"getrawtransaction":     {(*string)(nil), (*btcjson.TxRawResult)(nil)},
-	"gettxout":              {(*btcjson.GetTxOutResult)(nil)},
-	"node":                  nil,
-	"help":                  {(*string)(nil), (*string)(nil)},
-	"ping":                  nil,
-	"searchrawtransactions": {(*string)(nil), (*[]btcjson.SearchRawTransactionsResult)(nil)},
-	"sendrawtransaction":    {(*string)(nil)},
-	"setgenerate":           nil,
-	"stop":                  {(*string)(nil)},
-	"submitblock":           {nil, (*string)(nil)},
-	"uptime":                {(*int64)(nil)},
-	"validateaddress":       {(*btcjson.ValidateAddressChainResult)(nil)},
-	"verifychain":           {(*bool)(nil)},
-	"verifymessage":         {(*bool)(nil)},
-	"version":               {(*map[string]btcjson.VersionResult)(nil)},
+	"addnode":                nil,
+	"clearbanned":            nil,
+	"createrawtransaction":   {(*string)(nil)},
+	"debuglevel":             {(*string)(nil), (*string)(nil)},
+	"decoderawtransaction":   {(*btcjson.TxRawDecodeResult)(nil)},
+	"decodescript":           {(*btcjson.DecodeScriptResult)(nil)},
+	"disconnectnode":         nil,
+	"dumptxoutset":           {(*btcjson.DumpTxOutSetResult)(nil)},
+	"estimatefee":            {(*float64)(nil)},
+	"generate":               {(*[]string)(nil)},
+	"getaddednodeinfo":       {(*[]string)(nil), (*[]btcjson.GetAddedNodeInfoResult)(nil)},
+	"getaddressbalance":      {(*btcjson.GetAddressBalanceResult)(nil)},
+	"getaddressdeltas":       {(*[]btcjson.AddressDeltaResult)(nil), (*btcjson.GetAddressDeltasChainInfoResult)(nil)},
+	"getaddressmempool":      {(*[]btcjson.AddressMempoolResult)(nil)},
+	"getaddresstxids":        {(*[]string)(nil)},
+	"getaddressutxos":        {(*[]btcjson.AddressUtxoResult)(nil), (*btcjson.GetAddressUtxosChainInfoResult)(nil)},
+	"getbestblock":           {(*btcjson.GetBestBlockResult)(nil)},
+	"getassetunlockstatuses": {(*[]btcjson.AssetUnlockStatusResult)(nil)},
+	"getbestblockhash":       {(*string)(nil)},
+	"getbestchainlock":       {(*btcjson.GetBestChainLockResult)(nil)},
+	"getblock":               {(*string)(nil), (*btcjson.GetBlockVerboseResult)(nil)},
+	"getblockcount":          {(*int64)(nil)},
+	"getblockhash":           {(*string)(nil)},
+	"getblockhashes":         {(*[]string)(nil), (*[]btcjson.GetBlockHashesResult)(nil)},
+	"getblockheader":         {(*string)(nil), (*btcjson.GetBlockHeaderVerboseResult)(nil)},
+	"getblocktemplate":       {(*btcjson.GetBlockTemplateResult)(nil), (*string)(nil), nil},
+	"getblockchaininfo":      {(*btcjson.GetBlockChainInfoResult)(nil)},
+	"getcfilter":             {(*string)(nil)},
+	"getcfilterheader":       {(*string)(nil)},
+	"getconnectioncount":     {(*int32)(nil)},
+	"getcurrentnet":          {(*uint32)(nil)},
+	"getdifficulty":          {(*float64)(nil)},
+	"getgenerate":            {(*bool)(nil)},
+	"gethashespersec":        {(*float64)(nil)},
+	"getheaders":             {(*[]string)(nil)},
+	"getinfo":                {(*btcjson.InfoChainResult)(nil)},
+	"getmempoolinfo":         {(*btcjson.GetMempoolInfoResult)(nil)},
+	"getmininginfo":          {(*btcjson.GetMiningInfoResult)(nil)},
+	"getnettotals":           {(*btcjson.GetNetTotalsResult)(nil)},
+	"getnetworkhashps":       {(*int64)(nil)},
+	"getpeerinfo":            {(*[]btcjson.GetPeerInfoResult)(nil)},
+	"getrawmempool":          {(*[]string)(nil), (*btcjson.GetRawMempoolVerboseResult)(nil)},
+	"getrawtransaction":      {(*string)(nil), (*btcjson.TxRawResult)(nil)},
+	"getspentinfo":           {(*btcjson.GetSpentInfoResult)(nil)},
+	"gettxout":               {(*btcjson.GetTxOutResult)(nil)},
+	"gettxoutsetinfo":        {(*btcjson.GetTxOutSetInfoResult)(nil)},
+	"node":                   nil,
+	"help":                   {(*string)(nil), (*string)(nil)},
+	"listbanned":             {(*[]btcjson.ListBannedResult)(nil)},
+	"loadtxoutset":           {(*btcjson.LoadTxOutSetResult)(nil)},
+	"masternode":             {(*btcjson.MasternodeCountResult)(nil), (*map[string]string)(nil)},
+	"masternodelist":         {(*map[string]btcjson.MasternodeListResult)(nil), (*map[string]string)(nil)},
+	"ping":                   nil,
+	"protx":                  {(*[]string)(nil), (*[]btcjson.ProTxInfoResult)(nil), (*btcjson.ProTxInfoResult)(nil), (*btcjson.ProTxDiffResult)(nil)},
+	"searchrawtransactions":  {(*string)(nil), (*[]btcjson.SearchRawTransactionsResult)(nil)},
+	"sendrawtransaction":     {(*string)(nil)},
+	"setban":                 nil,
+	"setgenerate":            nil,
+	"signmessagewithprivkey": {(*string)(nil)},
+	"stop":                   {(*string)(nil)},
+	"submitblock":            {nil, (*string)(nil)},
+	"uptime":                 {(*int64)(nil)},
+	"validateaddress":        {(*btcjson.ValidateAddressChainResult)(nil)},
+	"verifychain":            {(*bool)(nil)},
+	"verifymessage":          {(*bool)(nil)},
+	"version":                {(*map[string]btcjson.VersionResult)(nil)},
 
 	// Websocket commands.
 	"loadtxfilter":              nil,
@@ -12,8 +12,11 @@ import (
 	"encoding/binary"
 	"errors"
 	"fmt"
+	"io/ioutil"
 	"math"
 	"net"
+	"os"
+	"path/filepath"
 	"runtime"
 	"sort"
 	"strconv"
@@ -27,8 +30,10 @@ import (
 	"github.com/eager7/dashd/blockchain/indexers"
 	"github.com/eager7/dashd/chaincfg"
 	"github.com/eager7/dashd/chaincfg/chainhash"
+	"github.com/eager7/dashd/coinjoin"
 	"github.com/eager7/dashd/connmgr"
 	"github.com/eager7/dashd/database"
+	"github.com/eager7/dashd/evo"
 	"github.com/eager7/dashd/mempool"
 	"github.com/eager7/dashd/mining"
 	"github.com/eager7/dashd/mining/cpuminer"
@@ -53,10 +58,35 @@ const (
 	// defaultTargetOutbound is the default number of outbound peers to target.
 	defaultTargetOutbound = 8
 
+	// defaultTargetBlockRelayOnly is the default number of block-relay-only
+	// outbound peers to target in addition to the other outbound peers.
+	// They never relay transactions nor addresses, which makes them harder
+	// to discover for an attacker trying to eclipse the node.
+	defaultTargetBlockRelayOnly = 2
+
 	// connectionRetryInterval is the base amount of time to wait in between
 	// retries when connecting to persistent peers.  It is adjusted by the
 	// number of retries such that there is a retry backoff.
 	connectionRetryInterval = time.Second * 5
+
+	// maxCmpctBlockDepth is the maximum depth below the best chain tip of
+	// blocks which are sent as compact blocks when requested.  Older blocks
+	// are sent in full since peers are unlikely to have their
+	// transactions.
+	maxCmpctBlockDepth = 5
+
+	// maxBlockTxnDepth is the maximum depth below the best chain tip of
+	// blocks whose transactions are served by getblocktxn messages.  The
+	// full block is sent for older blocks.
+	maxBlockTxnDepth = 10
+
+	// torControlRetryMin and torControlRetryMax are the minimum and maximum
+	// amounts of time to wait before the Tor hidden service is created
+	// again after the connection to the Tor control port failed or was
+	// lost, such as when Tor restarts.  The time doubles after each
+	// failed attempt.
+	torControlRetryMin = time.Second * 5
+	torControlRetryMax = time.Minute * 10
 )
 
 var (
@@ -94,6 +124,31 @@ func (oa *onionAddr) Network() string {
 // Ensure onionAddr implements the net.Addr interface.
 var _ net.Addr = (*onionAddr)(nil)
 
+// onionListener wraps the listener which accepts the inbound connections of
+// the Tor hidden service created through the Tor control port, so they are
+// marked as onion connections.
+type onionListener struct {
+	net.Listener
+}
+
+// Accept waits for and returns the next connection to the listener.
+//
+// This is part of the net.Listener interface.
+func (l *onionListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return &onionConn{Conn: conn}, nil
+}
+
+// onionConn is an inbound connection of the Tor hidden service.  Its remote
+// address is the loopback address Tor connects from, which does not identify
+// the peer.
+type onionConn struct {
+	net.Conn
+}
+
 // simpleAddr implements the net.Addr interface with two struct fields
 type simpleAddr struct {
 	net, addr string
@@ -156,7 +211,7 @@ type peerState struct {
 	inboundPeers    map[int32]*serverPeer
 	outboundPeers   map[int32]*serverPeer
 	persistentPeers map[int32]*serverPeer
-	banned          map[string]time.Time
+	banned          *banList
 	outboundGroups  map[string]int
 }
 
@@ -205,6 +260,10 @@ type server struct {
 	shutdownSched int32
 	startupTime   int64
 
+	// netGroupKey is a random secret used to select the netgroups of the
+	// inbound peers which are protected from eviction.
+	netGroupKey uint64
+
 	chainParams          *chaincfg.Params
 	addrManager          *addrmgr.AddrManager
 	connManager          *connmgr.ConnManager
@@ -214,6 +273,8 @@ type server struct {
 	syncManager          *netsync.SyncManager
 	chain                *blockchain.BlockChain
 	txMemPool            *mempool.TxPool
+	coinJoin             *coinjoin.Manager
+	mnList               *evo.Manager
 	cpuMiner             *cpuminer.CPUMiner
 	modifyRebroadcastInv chan interface{}
 	newPeers             chan *serverPeer
@@ -226,6 +287,8 @@ type server struct {
 	wg                   sync.WaitGroup
 	quit                 chan struct{}
 	nat                  NAT
+	i2p                  *connmgr.I2PSession
+	onionListener        net.Listener
 	db                   database.DB
 	timeSource           blockchain.MedianTimeSource
 	services             wire.ServiceFlag
@@ -234,9 +297,12 @@ type server struct {
 	// if the associated index is not enabled.  These fields are set during
 	// initial creation of the server and never changed afterwards, so they
 	// do not need to be protected for concurrent access.
-	txIndex   *indexers.TxIndex
-	addrIndex *indexers.AddrIndex
-	cfIndex   *indexers.CfIndex
+	txIndex        *indexers.TxIndex
+	addrIndex      *indexers.AddrIndex
+	addrDeltaIndex *indexers.AddrDeltaIndex
+	spentIndex     *indexers.SpentIndex
+	timestampIndex *indexers.TimestampIndex
+	cfIndex        *indexers.CfIndex
 
 	// The fee estimator keeps track of how long transactions are left in
 	// the mempool before they are mined into blocks.
@@ -254,24 +320,34 @@ type server struct {
 	// agentWhitelist is a list of whitelisted user agent substrings, no
 	// whitelisting will be applied if the list is empty or nil.
 	agentWhitelist []string
+
+	// evictionSelector selects the inbound peer to evict to make room for
+	// a new inbound peer once the maximum number of peers is reached.
+	evictionSelector evictionSelector
 }
 
 // serverPeer extends the peer to maintain state shared by the server and
 // the blockmanager.
 type serverPeer struct {
 	// The following variables must only be used atomically
-	feeFilter int64
+	feeFilter     int64
+	lastBlockTime int64 // Unix nanoseconds of the last new block relayed.
+	lastTxTime    int64 // Unix nanoseconds of the last new tx relayed.
 
 	*peer.Peer
 
 	connReq        *connmgr.ConnReq
 	server         *server
 	persistent     bool
+	feeler         bool
+	blockRelayOnly bool
 	continueHash   *chainhash.Hash
 	relayMtx       sync.Mutex
 	disableRelayTx bool
+	sendDSQueue    bool
 	sentAddrs      bool
 	isWhitelisted  bool
+	isOnion        bool
 	filter         *bloom.Filter
 	addressesMtx   sync.RWMutex
 	knownAddresses map[string]struct{}
@@ -305,7 +381,7 @@ func (sp *serverPeer) newestBlock() (*chainhash.Hash, int32, error) {
 
 // addKnownAddresses adds the given addresses to the set of known addresses to
 // the peer to prevent sending duplicate addresses.
-func (sp *serverPeer) addKnownAddresses(addresses []*wire.NetAddress) {
+func (sp *serverPeer) addKnownAddresses(addresses []*wire.NetAddressV2) {
 	sp.addressesMtx.Lock()
 	for _, na := range addresses {
 		sp.knownAddresses[addrmgr.NetAddressKey(na)] = struct{}{}
@@ -314,7 +390,7 @@ func (sp *serverPeer) addKnownAddresses(addresses []*wire.NetAddress) {
 }
 
 // addressKnown true if the given address is already known to the peer.
-func (sp *serverPeer) addressKnown(na *wire.NetAddress) bool {
+func (sp *serverPeer) addressKnown(na *wire.NetAddressV2) bool {
 	sp.addressesMtx.RLock()
 	_, exists := sp.knownAddresses[addrmgr.NetAddressKey(na)]
 	sp.addressesMtx.RUnlock()
@@ -340,17 +416,43 @@ func (sp *serverPeer) relayTxDisabled() bool {
 	return isDisabled
 }
 
-// pushAddrMsg sends an addr message to the connected peer using the provided
-// addresses.
-func (sp *serverPeer) pushAddrMsg(addresses []*wire.NetAddress) {
+// setSendDSQueue sets whether or not CoinJoin queues are relayed to the given
+// peer.
+// It is safe for concurrent access.
+func (sp *serverPeer) setSendDSQueue(send bool) {
+	sp.relayMtx.Lock()
+	sp.sendDSQueue = send
+	sp.relayMtx.Unlock()
+}
+
+// wantsDSQueue returns whether or not the given peer requested CoinJoin queues
+// to be relayed to it.
+// It is safe for concurrent access.
+func (sp *serverPeer) wantsDSQueue() bool {
+	sp.relayMtx.Lock()
+	send := sp.sendDSQueue
+	sp.relayMtx.Unlock()
+
+	return send
+}
+
+// pushAddrMsg sends an addrv2 message to the connected peer using the provided
+// addresses when it signaled support for them, and an addr message otherwise.
+func (sp *serverPeer) pushAddrMsg(addresses []*wire.NetAddressV2) {
 	// Filter addresses already known to the peer.
-	addrs := make([]*wire.NetAddress, 0, len(addresses))
+	addrs := make([]*wire.NetAddressV2, 0, len(addresses))
 	for _, addr := range addresses {
 		if !sp.addressKnown(addr) {
 			addrs = append(addrs, addr)
 		}
 	}
-	known, err := sp.PushAddrMsg(addrs)
+	var known []*wire.NetAddressV2
+	var err error
+	if sp.WantsAddrV2() {
+		known, err = sp.PushAddrV2Msg(addrs)
+	} else {
+		known, err = sp.PushAddrMsg(addrs)
+	}
 	if err != nil {
 		peerLog.Errorf("Can't push address message to %s: %v", sp.Peer, err)
 		sp.Disconnect()
@@ -468,8 +570,9 @@ func (sp *serverPeer) OnVersion(_ *peer.Peer, msg *wire.MsgVersion) *wire.MsgRej
 	sp.server.timeSource.AddTimeSample(sp.Addr(), msg.Timestamp)
 
 	// Choose whether or not to relay transactions before a filter command
-	// is received.
-	sp.setDisableRelayTx(msg.DisableRelayTx)
+	// is received.  Transactions are never relayed to block-relay-only
+	// peers.
+	sp.setDisableRelayTx(msg.DisableRelayTx || sp.blockRelayOnly)
 
 	return nil
 }
@@ -513,7 +616,7 @@ func (sp *serverPeer) OnMemPool(_ *peer.Peer, msg *wire.MsgMemPool) {
 		// Either add all transactions when there is no bloom filter,
 		// or only the transactions that match the filter when there is
 		// one.
-		if !sp.filter.IsLoaded() || sp.filter.MatchTxAndUpdate(txDesc.Tx) {
+		if !sp.filter.IsLoaded() || filterMatchTxAndUpdate(sp.filter, txDesc.Tx) {
 			iv := wire.NewInvVect(wire.InvTypeTx, txDesc.Tx.Hash())
 			invMsg.AddInvVect(iv)
 			if len(invMsg.InvList)+1 > wire.MaxInvPerMsg {
@@ -539,6 +642,14 @@ func (sp *serverPeer) OnTx(_ *peer.Peer, msg *wire.MsgTx) {
 		return
 	}
 
+	// Block-relay-only peers were asked not to relay transactions.
+	if sp.blockRelayOnly {
+		peerLog.Infof("Block-relay-only peer %v sent tx %v -- "+
+			"disconnecting", sp, msg.TxHash())
+		sp.Disconnect()
+		return
+	}
+
 	// Add the transaction to the known inventory for the peer.
 	// Convert the raw MsgTx to a dashutil.Tx which provides some convenience
 	// methods and things such as hash caching.
@@ -551,8 +662,15 @@ func (sp *serverPeer) OnTx(_ *peer.Peer, msg *wire.MsgTx) {
 	// processed and known good or bad.  This helps prevent a malicious peer
 	// from queuing up a bunch of bad transactions before disconnecting (or
 	// being disconnected) and wasting memory.
+	isNew := !sp.server.txMemPool.HaveTransaction(tx.Hash())
 	sp.server.syncManager.QueueTx(tx, sp.Peer, sp.txProcessed)
 	<-sp.txProcessed
+
+	// Peers which relay new transactions accepted to the memory pool are
+	// protected from eviction.
+	if isNew && sp.server.txMemPool.HaveTransaction(tx.Hash()) {
+		atomic.StoreInt64(&sp.lastTxTime, time.Now().UnixNano())
+	}
 }
 
 // OnBlock is invoked when a peer receives a block bitcoin message.  It
@@ -577,8 +695,78 @@ func (sp *serverPeer) OnBlock(_ *peer.Peer, msg *wire.MsgBlock, buf []byte) {
 	// reference implementation processes blocks in the same
 	// thread and therefore blocks further messages until
 	// the bitcoin block has been fully processed.
+	isNew := !sp.server.chain.MainChainHasBlock(block.Hash())
 	sp.server.syncManager.QueueBlock(block, sp.Peer, sp.blockProcessed)
 	<-sp.blockProcessed
+	sp.recordBlockRelay(block.Hash(), isNew)
+}
+
+// recordBlockRelay records the time the peer relayed the block with the passed
+// hash when it was new and is now part of the main chain, since such peers are
+// protected from eviction.
+func (sp *serverPeer) recordBlockRelay(hash *chainhash.Hash, isNew bool) {
+	if isNew && sp.server.chain.MainChainHasBlock(hash) {
+		atomic.StoreInt64(&sp.lastBlockTime, time.Now().UnixNano())
+	}
+}
+
+// OnCmpctBlock is invoked when a peer receives a cmpctblock bitcoin message.  It
+// queues the compact block to be reconstructed by the sync manager and blocks
+// further receives until it is processed like full blocks.
+func (sp *serverPeer) OnCmpctBlock(_ *peer.Peer, msg *wire.MsgCmpctBlock) {
+	blockHash := msg.BlockHash()
+	sp.AddKnownInventory(wire.NewInvVect(wire.InvTypeBlock, &blockHash))
+
+	isNew := !sp.server.chain.MainChainHasBlock(&blockHash)
+	sp.server.syncManager.QueueCmpctBlock(msg, sp.Peer, sp.blockProcessed)
+	<-sp.blockProcessed
+	sp.recordBlockRelay(&blockHash, isNew)
+}
+
+// OnGetBlockTxn is invoked when a peer receives a getblocktxn bitcoin message.
+// It sends the requested transactions of a recent block of the main chain in a
+// blocktxn message, or the full block when it is not recent.
+func (sp *serverPeer) OnGetBlockTxn(_ *peer.Peer, msg *wire.MsgGetBlockTxn) {
+	s := sp.server
+	height, err := s.chain.BlockHeightByHash(&msg.BlockHash)
+	if err != nil {
+		peerLog.Debugf("Unable to find block %v requested with "+
+			"getblocktxn by %v: %v", msg.BlockHash, sp, err)
+		return
+	}
+	if s.chain.BestSnapshot().Height-height >= maxBlockTxnDepth {
+		s.pushBlockMsg(sp, &msg.BlockHash, nil, nil, wire.BaseEncoding)
+		return
+	}
+
+	block, err := s.chain.BlockByHash(&msg.BlockHash)
+	if err != nil {
+		peerLog.Debugf("Unable to fetch block %v requested with "+
+			"getblocktxn by %v: %v", msg.BlockHash, sp, err)
+		return
+	}
+
+	txns := block.MsgBlock().Transactions
+	blockTxn := wire.NewMsgBlockTxn(&msg.BlockHash)
+	for _, index := range msg.Indexes {
+		if int(index) >= len(txns) {
+			sp.addBanScore(100, 0, "getblocktxn with out of range "+
+				"transaction indexes")
+			return
+		}
+		blockTxn.AddTransaction(txns[index])
+	}
+	sp.QueueMessage(blockTxn, nil)
+}
+
+// OnBlockTxn is invoked when a peer receives a blocktxn bitcoin message.  It
+// queues the transactions to complete a compact block to the sync manager and
+// blocks further receives until the block is processed.
+func (sp *serverPeer) OnBlockTxn(_ *peer.Peer, msg *wire.MsgBlockTxn) {
+	isNew := !sp.server.chain.MainChainHasBlock(&msg.BlockHash)
+	sp.server.syncManager.QueueBlockTxn(msg, sp.Peer, sp.blockProcessed)
+	<-sp.blockProcessed
+	sp.recordBlockRelay(&msg.BlockHash, isNew)
 }
 
 // OnInv is invoked when a peer receives an inv bitcoin message and is
@@ -586,7 +774,8 @@ func (sp *serverPeer) OnBlock(_ *peer.Peer, msg *wire.MsgBlock, buf []byte) {
 // accordingly.  We pass the message down to blockmanager which will call
 // QueueMessage with any appropriate responses.
 func (sp *serverPeer) OnInv(_ *peer.Peer, msg *wire.MsgInv) {
-	if !cfg.BlocksOnly {
+	if !cfg.BlocksOnly && !sp.blockRelayOnly {
+		msg = sp.requestDSTxs(msg)
 		if len(msg.InvList) > 0 {
 			sp.server.syncManager.QueueInv(msg, sp.Peer)
 		}
@@ -618,6 +807,48 @@ func (sp *serverPeer) OnInv(_ *peer.Peer, msg *wire.MsgInv) {
 	}
 }
 
+// requestDSTxs requests the unknown CoinJoin mixing transactions announced in
+// the passed inv message from the peer and returns the remaining inventory.
+// Mixing transactions are processed by the server itself rather than by the
+// sync manager since they are relayed in dstx messages.
+func (sp *serverPeer) requestDSTxs(msg *wire.MsgInv) *wire.MsgInv {
+	var gdmsg *wire.MsgGetData
+	newInv := msg
+	for i, invVect := range msg.InvList {
+		if invVect.Type != wire.InvTypeDSTx {
+			if newInv != msg {
+				newInv.AddInvVect(invVect)
+			}
+			continue
+		}
+
+		// Copy the inventory preceding the first mixing transaction
+		// so it can be removed from the inventory passed on.
+		if newInv == msg {
+			newInv = wire.NewMsgInvSizeHint(uint(len(msg.InvList)))
+			for _, iv := range msg.InvList[:i] {
+				newInv.AddInvVect(iv)
+			}
+		}
+
+		sp.AddKnownInventory(invVect)
+		if sp.server.coinJoin.HaveDSTx(&invVect.Hash) ||
+			sp.server.txMemPool.HaveTransaction(&invVect.Hash) {
+
+			continue
+		}
+		if gdmsg == nil {
+			gdmsg = wire.NewMsgGetData()
+		}
+		gdmsg.AddInvVect(invVect)
+	}
+
+	if gdmsg != nil {
+		sp.QueueMessage(gdmsg, nil)
+	}
+	return newInv
+}
+
 // OnHeaders is invoked when a peer receives a headers bitcoin
 // message.  The message is passed down to the sync manager.
 func (sp *serverPeer) OnHeaders(_ *peer.Peer, msg *wire.MsgHeaders) {
@@ -662,10 +893,14 @@ func (sp *serverPeer) OnGetData(_ *peer.Peer, msg *wire.MsgGetData) {
 			err = sp.server.pushTxMsg(sp, &iv.Hash, c, waitChan, wire.WitnessEncoding)
 		case wire.InvTypeTx:
 			err = sp.server.pushTxMsg(sp, &iv.Hash, c, waitChan, wire.BaseEncoding)
+		case wire.InvTypeDSTx:
+			err = sp.server.pushDSTxMsg(sp, &iv.Hash, c, waitChan)
 		case wire.InvTypeWitnessBlock:
 			err = sp.server.pushBlockMsg(sp, &iv.Hash, c, waitChan, wire.WitnessEncoding)
 		case wire.InvTypeBlock:
 			err = sp.server.pushBlockMsg(sp, &iv.Hash, c, waitChan, wire.BaseEncoding)
+		case wire.InvTypeCmpctBlock:
+			err = sp.server.pushCmpctBlockMsg(sp, &iv.Hash, c, waitChan)
 		case wire.InvTypeFilteredWitnessBlock:
 			err = sp.server.pushMerkleBlockMsg(sp, &iv.Hash, c, waitChan, wire.WitnessEncoding)
 		case wire.InvTypeFilteredBlock:
@@ -1201,11 +1436,85 @@ func (sp *serverPeer) OnFilterLoad(_ *peer.Peer, msg *wire.MsgFilterLoad) {
 		return
 	}
 
-	sp.setDisableRelayTx(false)
+	// Transactions are never relayed to block-relay-only peers.
+	sp.setDisableRelayTx(sp.blockRelayOnly)
 
 	sp.filter.Reload(msg)
 }
 
+// OnDSQueue is invoked when a peer receives a dsq message.  Queues which are
+// signed by a masternode in the deterministic masternode list and pass the
+// rate limits are cached and relayed to the peers that requested queues.  The
+// peer is penalized when the signature of the queue is invalid.
+func (sp *serverPeer) OnDSQueue(_ *peer.Peer, msg *wire.MsgDSQueue) {
+	err := sp.server.coinJoin.ProcessQueue(msg)
+	if err != nil {
+		if rerr, ok := err.(coinjoin.RuleError); ok &&
+			rerr.ErrorCode == coinjoin.ErrBadSignature {
+
+			sp.addBanScore(10, 0, "dsq")
+		}
+		peerLog.Debugf("Rejected dsq from %v: %v", sp, err)
+		return
+	}
+
+	sp.server.BroadcastMessage(msg, sp)
+}
+
+// OnDSTx is invoked when a peer receives a dstx message.  The mixing
+// transaction is accepted to the memory pool with the CoinJoin policy when it
+// is signed by a masternode in the deterministic masternode list, and is then
+// announced to other peers as a mixing transaction.  The peer is penalized when
+// the signature of the transaction is invalid.
+func (sp *serverPeer) OnDSTx(_ *peer.Peer, msg *wire.MsgDSTx) {
+	if cfg.BlocksOnly {
+		peerLog.Tracef("Ignoring dstx %v from %v - blocksonly enabled",
+			msg.Tx.TxHash(), sp)
+		return
+	}
+
+	tx := dashutil.NewTx(&msg.Tx)
+	iv := wire.NewInvVect(wire.InvTypeDSTx, tx.Hash())
+	sp.AddKnownInventory(iv)
+
+	err := sp.server.coinJoin.CheckDSTx(msg)
+	if err != nil {
+		if rerr, ok := err.(coinjoin.RuleError); ok &&
+			rerr.ErrorCode == coinjoin.ErrBadSignature {
+
+			sp.addBanScore(10, 0, "dstx")
+		}
+		peerLog.Debugf("Rejected dstx %v from %v: %v", tx.Hash(), sp,
+			err)
+		return
+	}
+
+	acceptedTxs, err := sp.server.txMemPool.ProcessDSTransaction(tx,
+		mempool.Tag(sp.ID()))
+	if err != nil {
+		peerLog.Debugf("Rejected mixing transaction %v from %v: %v",
+			tx.Hash(), sp, err)
+		return
+	}
+
+	sp.server.coinJoin.AddDSTx(msg)
+	sp.server.AnnounceNewTransactions(acceptedTxs)
+}
+
+// OnSendDSQueue is invoked when a peer receives a senddsq message.  It is used
+// by CoinJoin clients to request that the CoinJoin queues the server learns
+// about are relayed to them.  The active queues are sent right away.
+func (sp *serverPeer) OnSendDSQueue(_ *peer.Peer, msg *wire.MsgSendDSQueue) {
+	sp.setSendDSQueue(msg.Send)
+	if !msg.Send {
+		return
+	}
+
+	for _, dsq := range sp.server.coinJoin.Queues() {
+		sp.QueueMessage(dsq, nil)
+	}
+}
+
 // OnGetAddr is invoked when a peer receives a getaddr bitcoin message
 // and is used to provide the peer with known addresses from the address
 // manager.
@@ -1245,6 +1554,28 @@ func (sp *serverPeer) OnGetAddr(_ *peer.Peer, msg *wire.MsgGetAddr) {
 // OnAddr is invoked when a peer receives an addr bitcoin message and is
 // used to notify the server about advertised addresses.
 func (sp *serverPeer) OnAddr(_ *peer.Peer, msg *wire.MsgAddr) {
+	// Ignore old style addresses which don't include a timestamp.
+	if sp.ProtocolVersion() < wire.NetAddressTimeVersion {
+		return
+	}
+
+	addrList := make([]*wire.NetAddressV2, 0, len(msg.AddrList))
+	for _, na := range msg.AddrList {
+		addrList = append(addrList, wire.NetAddressV2FromLegacy(na))
+	}
+	sp.handleAddrList(msg.Command(), addrList)
+}
+
+// OnAddrV2 is invoked when a peer receives an addrv2 message and is used to
+// notify the server about advertised addresses, which unlike those of addr
+// messages may include Tor v3, I2P and CJDNS addresses.
+func (sp *serverPeer) OnAddrV2(_ *peer.Peer, msg *wire.MsgAddrV2) {
+	sp.handleAddrList(msg.Command(), msg.AddrList)
+}
+
+// handleAddrList adds the addresses advertised by the peer with the passed
+// command to the set of known addresses of the peer and the address manager.
+func (sp *serverPeer) handleAddrList(command string, addrList []*wire.NetAddressV2) {
 	// Ignore addresses when running on the simulation test network.  This
 	// helps prevent the network from becoming another public test network
 	// since it will not be able to learn about other peers that have not
@@ -1253,20 +1584,23 @@ func (sp *serverPeer) OnAddr(_ *peer.Peer, msg *wire.MsgAddr) {
 		return
 	}
 
-	// Ignore old style addresses which don't include a timestamp.
-	if sp.ProtocolVersion() < wire.NetAddressTimeVersion {
+	// Ignore addresses from block-relay-only peers, which are only used to
+	// relay blocks so they can't be discovered through address relay.
+	if sp.blockRelayOnly {
+		peerLog.Debugf("Ignoring %s message from block-relay-only peer %v",
+			command, sp)
 		return
 	}
 
 	// A message that has no addresses is invalid.
-	if len(msg.AddrList) == 0 {
+	if len(addrList) == 0 {
 		peerLog.Errorf("Command [%s] from %s does not contain any addresses",
-			msg.Command(), sp.Peer)
+			command, sp.Peer)
 		sp.Disconnect()
 		return
 	}
 
-	for _, na := range msg.AddrList {
+	for _, na := range addrList {
 		// Don't add more address if we're disconnecting.
 		if !sp.Connected() {
 			return
@@ -1281,7 +1615,7 @@ func (sp *serverPeer) OnAddr(_ *peer.Peer, msg *wire.MsgAddr) {
 		}
 
 		// Add address to known addresses for this peer.
-		sp.addKnownAddresses([]*wire.NetAddress{na})
+		sp.addKnownAddresses([]*wire.NetAddressV2{na})
 	}
 
 	// Add addresses to server address manager.  The address manager handles
@@ -1289,7 +1623,7 @@ func (sp *serverPeer) OnAddr(_ *peer.Peer, msg *wire.MsgAddr) {
 	// addresses, and last seen updates.
 	// XXX bitcoind gives a 2 hour time penalty here, do we want to do the
 	// same?
-	sp.server.addrManager.AddAddresses(msg.AddrList, sp.NA())
+	sp.server.addrManager.AddAddresses(addrList, sp.NA())
 }
 
 // OnRead is invoked when a peer receives a message and it is used to update
@@ -1348,7 +1682,13 @@ func (s *server) RemoveRebroadcastInventory(iv *wire.InvVect) {
 // passed transactions to all connected peers.
 func (s *server) relayTransactions(txns []*mempool.TxDesc) {
 	for _, txD := range txns {
-		iv := wire.NewInvVect(wire.InvTypeTx, txD.Tx.Hash())
+		// Mixing transactions are announced as such so peers request
+		// them along with the masternode signature.
+		invType := wire.InvTypeTx
+		if s.coinJoin.HaveDSTx(txD.Tx.Hash()) {
+			invType = wire.InvTypeDSTx
+		}
+		iv := wire.NewInvVect(invType, txD.Tx.Hash())
 		s.RelayInventory(iv, txD)
 	}
 }
@@ -1381,6 +1721,23 @@ func (s *server) TransactionConfirmed(tx *dashutil.Tx) {
 	s.RemoveRebroadcastInventory(iv)
 }
 
+// handleCoinJoinNotification keeps track of the blocks the CoinJoin mixing
+// transactions known to the server are mined in so they are forgotten once
+// they are buried deeply enough.
+func (s *server) handleCoinJoinNotification(notification *blockchain.Notification) {
+	switch notification.Type {
+	case blockchain.NTBlockConnected:
+		if block, ok := notification.Data.(*dashutil.Block); ok {
+			s.coinJoin.BlockConnected(block)
+		}
+
+	case blockchain.NTBlockDisconnected:
+		if block, ok := notification.Data.(*dashutil.Block); ok {
+			s.coinJoin.BlockDisconnected(block)
+		}
+	}
+}
+
 // pushTxMsg sends a tx message for the provided transaction hash to the
 // connected peer.  An error is returned if the transaction hash is not known.
 func (s *server) pushTxMsg(sp *serverPeer, hash *chainhash.Hash, doneChan chan<- struct{},
@@ -1410,11 +1767,101 @@ func (s *server) pushTxMsg(sp *serverPeer, hash *chainhash.Hash, doneChan chan<-
 	return nil
 }
 
+// pushDSTxMsg sends a dstx message for the provided transaction hash to the
+// connected peer.  An error is returned if the transaction hash is not a known
+// mixing transaction.
+func (s *server) pushDSTxMsg(sp *serverPeer, hash *chainhash.Hash, doneChan chan<- struct{},
+	waitChan <-chan struct{}) error {
+
+	dstx := s.coinJoin.DSTx(hash)
+	if dstx == nil {
+		peerLog.Tracef("Unable to fetch mixing tx %v", hash)
+
+		if doneChan != nil {
+			doneChan <- struct{}{}
+		}
+		return fmt.Errorf("unknown mixing transaction %v", hash)
+	}
+
+	// Once we have fetched data wait for any previous operation to finish.
+	if waitChan != nil {
+		<-waitChan
+	}
+
+	sp.QueueMessage(dstx, doneChan)
+
+	return nil
+}
+
+// fetchCmpctBlock returns a compact block (BIP0152) with a random nonce for the
+// block of the main chain with the passed hash.
+func (s *server) fetchCmpctBlock(hash *chainhash.Hash) (*wire.MsgCmpctBlock, error) {
+	block, err := s.chain.BlockByHash(hash)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := wire.RandomUint64()
+	if err != nil {
+		return nil, err
+	}
+	return wire.NewMsgCmpctBlockFromBlock(block.MsgBlock(), nonce), nil
+}
+
+// pushCmpctBlockMsg sends a compact block (BIP0152) for the provided block hash
+// to the connected peer.  Blocks which are not among the latest ones of the
+// main chain are sent in full instead.  An error is returned if the block hash
+// is not known.
+func (s *server) pushCmpctBlockMsg(sp *serverPeer, hash *chainhash.Hash, doneChan chan<- struct{},
+	waitChan <-chan struct{}) error {
+
+	height, err := s.chain.BlockHeightByHash(hash)
+	if err != nil || s.chain.BestSnapshot().Height-height >= maxCmpctBlockDepth {
+		return s.pushBlockMsg(sp, hash, doneChan, waitChan,
+			wire.BaseEncoding)
+	}
+
+	msg, err := s.fetchCmpctBlock(hash)
+	if err != nil {
+		peerLog.Tracef("Unable to fetch requested block hash %v: %v",
+			hash, err)
+
+		if doneChan != nil {
+			doneChan <- struct{}{}
+		}
+		return err
+	}
+
+	// Once we have fetched data wait for any previous operation to finish.
+	if waitChan != nil {
+		<-waitChan
+	}
+
+	sp.QueueMessage(msg, doneChan)
+	return nil
+}
+
 // pushBlockMsg sends a block message for the provided block hash to the
 // connected peer.  An error is returned if the block hash is not known.
 func (s *server) pushBlockMsg(sp *serverPeer, hash *chainhash.Hash, doneChan chan<- struct{},
 	waitChan <-chan struct{}, encoding wire.MessageEncoding) error {
 
+	// Pruned nodes only serve the latest blocks of the main chain per
+	// BIP0159 to avoid revealing how many older blocks they still store.
+	if s.chain.IsPruneMode() {
+		height, err := s.chain.BlockHeightByHash(hash)
+		best := s.chain.BestSnapshot()
+		if err == nil && best.Height-height >= blockchain.MinBlocksToKeep {
+			peerLog.Tracef("Not serving requested block hash %v at "+
+				"height %d below the latest blocks", hash, height)
+
+			if doneChan != nil {
+				doneChan <- struct{}{}
+			}
+			return fmt.Errorf("block %v is not served by a pruned "+
+				"node", hash)
+		}
+	}
+
 	// Fetch the raw block bytes from the database.
 	var blockBytes []byte
 	err := sp.server.db.View(func(dbTx database.Tx) error {
@@ -1505,7 +1952,7 @@ func (s *server) pushMerkleBlockMsg(sp *serverPeer, hash *chainhash.Hash,
 
 	// Generate a merkle block by filtering the requested block according
 	// to the filter for the peer.
-	merkle, matchedTxIndices := bloom.NewMerkleBlock(blk, sp.filter)
+	merkle, matchedTxIndices := newMerkleBlock(blk, sp.filter)
 
 	// Once we have fetched data wait for any previous operation to finish.
 	if waitChan != nil {
@@ -1592,22 +2039,31 @@ func (s *server) handleAddPeerMsg(state *peerState, sp *serverPeer) bool {
 		sp.Disconnect()
 		return false
 	}
-	if banEnd, ok := state.banned[host]; ok {
-		if time.Now().Before(banEnd) {
-			srvrLog.Debugf("Peer %s is banned for another %v - disconnecting",
-				host, time.Until(banEnd))
-			sp.Disconnect()
-			return false
-		}
-
-		srvrLog.Infof("Peer %s is no longer banned", host)
-		delete(state.banned, host)
+	if banEnd, ok := state.banned.bannedUntil(host); ok {
+		srvrLog.Debugf("Peer %s is banned for another %v - disconnecting",
+			host, time.Until(banEnd))
+		sp.Disconnect()
+		return false
 	}
 
 	// TODO: Check for max peers from a single IP.
 
-	// Limit max number of total peers.
-	if state.Count() >= cfg.MaxPeers {
+	// Feeler connections are only made to test whether addresses are
+	// reachable, so the address is marked good once the version handshake
+	// is done and the peer is disconnected.
+	if sp.feeler {
+		srvrLog.Debugf("Feeler connection to %s succeeded", sp)
+		s.addrManager.Good(sp.NA())
+		sp.Disconnect()
+		return false
+	}
+
+	// Limit max number of total peers.  New inbound peers take the place of
+	// an existing inbound peer when one can be evicted, so the slots can not
+	// be held forever by whoever fills them first.
+	if state.Count() >= cfg.MaxPeers &&
+		!(sp.Inbound() && s.evictInboundPeer(state)) {
+
 		srvrLog.Infof("Max peers reached [%d] - disconnecting peer %s",
 			cfg.MaxPeers, sp)
 		sp.Disconnect()
@@ -1621,7 +2077,7 @@ func (s *server) handleAddPeerMsg(state *peerState, sp *serverPeer) bool {
 	if sp.Inbound() {
 		state.inboundPeers[sp.ID()] = sp
 	} else {
-		state.outboundGroups[addrmgr.GroupKey(sp.NA())]++
+		state.outboundGroups[s.addrManager.GroupKey(sp.NA())]++
 		if sp.persistent {
 			state.persistentPeers[sp.ID()] = sp
 		} else {
@@ -1646,13 +2102,16 @@ func (s *server) handleAddPeerMsg(state *peerState, sp *serverPeer) bool {
 	if !cfg.SimNet && !sp.Inbound() {
 		// Advertise the local address when the server accepts incoming
 		// connections and it believes itself to be close to the best
-		// known tip.
-		if !cfg.DisableListen && s.syncManager.IsCurrent() {
+		// known tip.  Addresses are never relayed to block-relay-only
+		// peers.
+		if !cfg.DisableListen && !sp.blockRelayOnly &&
+			s.syncManager.IsCurrent() {
+
 			// Get address that best matches.
 			lna := s.addrManager.GetBestLocalAddress(sp.NA())
 			if addrmgr.IsRoutable(lna) {
 				// Filter addresses the peer already knows about.
-				addresses := []*wire.NetAddress{lna}
+				addresses := []*wire.NetAddressV2{lna}
 				sp.pushAddrMsg(addresses)
 			}
 		}
@@ -1661,7 +2120,9 @@ func (s *server) handleAddPeerMsg(state *peerState, sp *serverPeer) bool {
 		// more and the peer has a protocol version new enough to
 		// include a timestamp with addresses.
 		hasTimestamp := sp.ProtocolVersion() >= wire.NetAddressTimeVersion
-		if s.addrManager.NeedMoreAddresses() && hasTimestamp {
+		if s.addrManager.NeedMoreAddresses() && hasTimestamp &&
+			!sp.blockRelayOnly {
+
 			sp.QueueMessage(wire.NewMsgGetAddr(), nil)
 		}
 
@@ -1686,19 +2147,19 @@ func (s *server) handleDonePeerMsg(state *peerState, sp *serverPeer) {
 
 	// Regardless of whether the peer was found in our list, we'll inform
 	// our connection manager about the disconnection. This can happen if we
-	// process a peer's `done` message before its `add`.
-	if !sp.Inbound() {
+	// process a peer's `done` message before its `add`.  Feeler
+	// connections are not tracked by the connection manager.
+	if !sp.Inbound() && !sp.feeler {
 		if sp.persistent {
 			s.connManager.Disconnect(sp.connReq.ID())
 		} else {
-			s.connManager.Remove(sp.connReq.ID())
-			go s.connManager.NewConnReq()
+			s.replaceConnReq(sp.connReq)
 		}
 	}
 
 	if _, ok := list[sp.ID()]; ok {
 		if !sp.Inbound() && sp.VersionKnown() {
-			state.outboundGroups[addrmgr.GroupKey(sp.NA())]--
+			state.outboundGroups[s.addrManager.GroupKey(sp.NA())]--
 		}
 		delete(list, sp.ID())
 		srvrLog.Debugf("Removed peer %s", sp)
@@ -1709,25 +2170,69 @@ func (s *server) handleDonePeerMsg(state *peerState, sp *serverPeer) {
 // handleBanPeerMsg deals with banning peers.  It is invoked from the
 // peerHandler goroutine.
 func (s *server) handleBanPeerMsg(state *peerState, sp *serverPeer) {
+	// Peers connecting through the Tor hidden service or another proxy on
+	// the loopback address all share it, so they are only disconnected
+	// since banning the address would ban all of them.
+	if sp.isOnion {
+		srvrLog.Infof("Not banning onion peer %s", sp)
+		return
+	}
 	host, _, err := net.SplitHostPort(sp.Addr())
 	if err != nil {
 		srvrLog.Debugf("can't split ban peer %s %v", sp.Addr(), err)
 		return
 	}
+	if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+		srvrLog.Infof("Not banning loopback peer %s", sp)
+		return
+	}
+	addr, subnet, err := parseBanAddress(host)
+	if err != nil {
+		srvrLog.Debugf("can't ban peer %s: %v", sp.Addr(), err)
+		return
+	}
 	direction := directionString(sp.Inbound())
 	srvrLog.Infof("Banned peer %s (%s) for %v", host, direction,
 		cfg.BanDuration)
-	state.banned[host] = time.Now().Add(cfg.BanDuration)
+	state.banned.add(addr, subnet, time.Now().Add(cfg.BanDuration))
+	s.saveBanList(state)
 }
 
 // handleRelayInvMsg deals with relaying inventory to peers that are not already
 // known to have it.  It is invoked from the peerHandler goroutine.
 func (s *server) handleRelayInvMsg(state *peerState, msg relayMsg) {
+	// The compact block sent to peers in high bandwidth mode is only
+	// created once, when the first of them is found.
+	var cmpctBlock *wire.MsgCmpctBlock
+	var cmpctBlockFetched bool
+
 	state.forAllPeers(func(sp *serverPeer) {
 		if !sp.Connected() {
 			return
 		}
 
+		// Send new blocks of the main chain to the peers which asked for
+		// compact block announcements right away.
+		if msg.invVect.Type == wire.InvTypeBlock &&
+			sp.WantsHighBandwidthCmpctBlocks() &&
+			!sp.HasKnownInventory(msg.invVect) {
+
+			if !cmpctBlockFetched {
+				var err error
+				cmpctBlock, err = s.fetchCmpctBlock(&msg.invVect.Hash)
+				if err != nil {
+					peerLog.Debugf("Unable to create compact "+
+						"block %v: %v", msg.invVect.Hash, err)
+				}
+				cmpctBlockFetched = true
+			}
+			if cmpctBlock != nil {
+				sp.AddKnownInventory(msg.invVect)
+				sp.QueueMessage(cmpctBlock, nil)
+				return
+			}
+		}
+
 		// If the inventory is a block and the peer prefers headers,
 		// generate and send a headers message instead of an inventory
 		// message.
@@ -1748,7 +2253,8 @@ func (s *server) handleRelayInvMsg(state *peerState, msg relayMsg) {
 			return
 		}
 
-		if msg.invVect.Type == wire.InvTypeTx {
+		isDSTx := msg.invVect.Type == wire.InvTypeDSTx
+		if msg.invVect.Type == wire.InvTypeTx || isDSTx {
 			// Don't relay the transaction to the peer when it has
 			// transaction relaying disabled.
 			if sp.relayTxDisabled() {
@@ -1764,16 +2270,17 @@ func (s *server) handleRelayInvMsg(state *peerState, msg relayMsg) {
 			}
 
 			// Don't relay the transaction if the transaction fee-per-kb
-			// is less than the peer's feefilter.
+			// is less than the peer's feefilter.  Mixing transactions
+			// are exempt since they usually pay no fee.
 			feeFilter := atomic.LoadInt64(&sp.feeFilter)
-			if feeFilter > 0 && txD.FeePerKB < feeFilter {
+			if !isDSTx && feeFilter > 0 && txD.FeePerKB < feeFilter {
 				return
 			}
 
 			// Don't relay the transaction if there is a bloom
 			// filter loaded and the transaction doesn't match it.
 			if sp.filter.IsLoaded() {
-				if !sp.filter.MatchTxAndUpdate(txD.Tx) {
+				if !filterMatchTxAndUpdate(sp.filter, txD.Tx) {
 					return
 				}
 			}
@@ -1800,6 +2307,13 @@ func (s *server) handleBroadcastMsg(state *peerState, bmsg *broadcastMsg) {
 			}
 		}
 
+		// Only relay CoinJoin queues to peers that requested them.
+		if _, ok := bmsg.message.(*wire.MsgDSQueue); ok &&
+			!sp.wantsDSQueue() {
+
+			return
+		}
+
 		sp.QueueMessage(bmsg.message, nil)
 	})
 }
@@ -1837,6 +2351,30 @@ type removeNodeMsg struct {
 	reply chan error
 }
 
+type banSubnetMsg struct {
+	subnet string
+	until  time.Time
+	reply  chan error
+}
+
+type unbanSubnetMsg struct {
+	subnet string
+	reply  chan error
+}
+
+type getBannedMsg struct {
+	reply chan []*banEntry
+}
+
+type isBannedMsg struct {
+	host  string
+	reply chan bool
+}
+
+type clearBannedMsg struct {
+	reply chan struct{}
+}
+
 // handleQuery is the central handler for all queries and commands from other
 // goroutines related to peer state.
 func (s *server) handleQuery(state *peerState, querymsg interface{}) {
@@ -1894,7 +2432,7 @@ func (s *server) handleQuery(state *peerState, querymsg interface{}) {
 		found := disconnectPeer(state.persistentPeers, msg.cmp, func(sp *serverPeer) {
 			// Keep group counts ok since we remove from
 			// the list now.
-			state.outboundGroups[addrmgr.GroupKey(sp.NA())]--
+			state.outboundGroups[s.addrManager.GroupKey(sp.NA())]--
 		})
 
 		if found {
@@ -1930,7 +2468,7 @@ func (s *server) handleQuery(state *peerState, querymsg interface{}) {
 		found = disconnectPeer(state.outboundPeers, msg.cmp, func(sp *serverPeer) {
 			// Keep group counts ok since we remove from
 			// the list now.
-			state.outboundGroups[addrmgr.GroupKey(sp.NA())]--
+			state.outboundGroups[s.addrManager.GroupKey(sp.NA())]--
 		})
 		if found {
 			// If there are multiple outbound connections to the same
@@ -1938,7 +2476,7 @@ func (s *server) handleQuery(state *peerState, querymsg interface{}) {
 			// peers are found.
 			for found {
 				found = disconnectPeer(state.outboundPeers, msg.cmp, func(sp *serverPeer) {
-					state.outboundGroups[addrmgr.GroupKey(sp.NA())]--
+					state.outboundGroups[s.addrManager.GroupKey(sp.NA())]--
 				})
 			}
 			msg.reply <- nil
@@ -1946,6 +2484,58 @@ func (s *server) handleQuery(state *peerState, querymsg interface{}) {
 		}
 
 		msg.reply <- errors.New("peer not found")
+
+	case banSubnetMsg:
+		addr, subnet, err := parseBanAddress(msg.subnet)
+		if err != nil {
+			msg.reply <- err
+			return
+		}
+		if state.banned.has(addr) {
+			msg.reply <- errors.New("subnet already banned")
+			return
+		}
+		entry := state.banned.add(addr, subnet, msg.until)
+		s.saveBanList(state)
+		srvrLog.Infof("Banned %s until %v", addr, msg.until)
+
+		// Disconnect the connected peers of the subnet.  Their state is
+		// cleaned up once they are done.
+		state.forAllPeers(func(sp *serverPeer) {
+			host, _, err := net.SplitHostPort(sp.Addr())
+			if err == nil && entry.matches(host) {
+				srvrLog.Infof("Disconnecting banned peer %s", sp)
+				sp.Disconnect()
+			}
+		})
+		msg.reply <- nil
+
+	case unbanSubnetMsg:
+		addr, _, err := parseBanAddress(msg.subnet)
+		if err != nil {
+			msg.reply <- err
+			return
+		}
+		if !state.banned.remove(addr) {
+			msg.reply <- errors.New("subnet not banned")
+			return
+		}
+		s.saveBanList(state)
+		srvrLog.Infof("Unbanned %s", addr)
+		msg.reply <- nil
+
+	case getBannedMsg:
+		msg.reply <- state.banned.list()
+
+	case isBannedMsg:
+		_, banned := state.banned.bannedUntil(msg.host)
+		msg.reply <- banned
+
+	case clearBannedMsg:
+		state.banned.clear()
+		s.saveBanList(state)
+		srvrLog.Infof("Cleared all bans")
+		msg.reply <- struct{}{}
 	}
 }
 
@@ -1982,6 +2572,9 @@ func newPeerConfig(sp *serverPeer) *peer.Config {
 			OnMemPool:      sp.OnMemPool,
 			OnTx:           sp.OnTx,
 			OnBlock:        sp.OnBlock,
+			OnCmpctBlock:   sp.OnCmpctBlock,
+			OnGetBlockTxn:  sp.OnGetBlockTxn,
+			OnBlockTxn:     sp.OnBlockTxn,
 			OnInv:          sp.OnInv,
 			OnHeaders:      sp.OnHeaders,
 			OnGetData:      sp.OnGetData,
@@ -1996,6 +2589,10 @@ func newPeerConfig(sp *serverPeer) *peer.Config {
 			OnFilterLoad:   sp.OnFilterLoad,
 			OnGetAddr:      sp.OnGetAddr,
 			OnAddr:         sp.OnAddr,
+			OnAddrV2:       sp.OnAddrV2,
+			OnDSQueue:      sp.OnDSQueue,
+			OnDSTx:         sp.OnDSTx,
+			OnSendDSQueue:  sp.OnSendDSQueue,
 			OnRead:         sp.OnRead,
 			OnWrite:        sp.OnWrite,
 
@@ -2016,6 +2613,8 @@ func newPeerConfig(sp *serverPeer) *peer.Config {
 		DisableRelayTx:    cfg.BlocksOnly,
 		ProtocolVersion:   peer.MaxProtocolVersion,
 		TrickleInterval:   cfg.TrickleInterval,
+		V2Transport:       sp.server.services&wire.SFNodeP2PV2 != 0,
+		CmpctBlocks:       true,
 	}
 }
 
@@ -2024,8 +2623,21 @@ func newPeerConfig(sp *serverPeer) *peer.Config {
 // instance, associates it with the connection, and starts a goroutine to wait
 // for disconnection.
 func (s *server) inboundPeerConnected(conn net.Conn) {
+	// Refuse connections from banned hosts right away rather than after
+	// the version handshake.  The address of the connections of the Tor
+	// hidden service is the one of Tor, so it is neither banned nor
+	// whitelisted.
+	_, isOnion := conn.(*onionConn)
+	if !isOnion && s.IsBanned(conn.RemoteAddr().String()) {
+		srvrLog.Debugf("Rejecting connection from banned peer %s",
+			conn.RemoteAddr())
+		conn.Close()
+		return
+	}
+
 	sp := newServerPeer(s, false)
-	sp.isWhitelisted = isWhitelisted(conn.RemoteAddr())
+	sp.isOnion = isOnion
+	sp.isWhitelisted = !isOnion && isWhitelisted(conn.RemoteAddr())
 	sp.Peer = peer.NewInboundPeer(newPeerConfig(sp))
 	sp.AssociateConnection(conn)
 	go s.peerDoneHandler(sp)
@@ -2037,33 +2649,92 @@ func (s *server) inboundPeerConnected(conn net.Conn) {
 // request instance and the connection itself, and finally notifies the address
 // manager of the attempt.
 func (s *server) outboundPeerConnected(c *connmgr.ConnReq, conn net.Conn) {
+	// Drop automatic connections to banned hosts, such as anchors which
+	// were banned since the last run, before the version handshake.
+	// Peers added by the user are connected to regardless.
+	if !c.Permanent && s.IsBanned(c.Addr.String()) {
+		srvrLog.Debugf("Not connecting to banned peer %s", c.Addr)
+		conn.Close()
+		s.replaceConnReq(c)
+		return
+	}
+
 	sp := newServerPeer(s, c.Permanent)
-	p, err := peer.NewOutboundPeer(newPeerConfig(sp), c.Addr.String())
+	peerCfg := newPeerConfig(sp)
+	peerCfg.V2Transport = peerCfg.V2Transport && s.supportsV2Transport(c.Addr)
+	peerCfg.DisableRelayTx = peerCfg.DisableRelayTx || c.BlockRelayOnly
+	p, err := peer.NewOutboundPeer(peerCfg, c.Addr.String())
 	if err != nil {
 		srvrLog.Debugf("Cannot create outbound peer %s: %v", c.Addr, err)
 		if c.Permanent {
 			s.connManager.Disconnect(c.ID())
 		} else {
-			s.connManager.Remove(c.ID())
-			go s.connManager.NewConnReq()
+			s.replaceConnReq(c)
 		}
 		return
 	}
 	sp.Peer = p
 	sp.connReq = c
+	sp.blockRelayOnly = c.BlockRelayOnly
 	sp.isWhitelisted = isWhitelisted(conn.RemoteAddr())
 	sp.AssociateConnection(conn)
 	go s.peerDoneHandler(sp)
 }
 
+// replaceConnReq removes the passed non-persistent outbound connection request
+// from the connection manager and requests a new connection of the same class
+// in its place.
+func (s *server) replaceConnReq(c *connmgr.ConnReq) {
+	s.connManager.Remove(c.ID())
+	if c.BlockRelayOnly {
+		go s.connManager.NewBlockRelayConnReq()
+	} else {
+		go s.connManager.NewConnReq()
+	}
+}
+
+// feelerPeerConnected is invoked by the connection manager when a feeler
+// connection is established.  It initializes a new outbound server peer which
+// is disconnected once the version handshake is done, since feeler connections
+// only test whether addresses are reachable.
+func (s *server) feelerPeerConnected(c *connmgr.ConnReq, conn net.Conn) {
+	sp := newServerPeer(s, false)
+	sp.feeler = true
+	peerCfg := newPeerConfig(sp)
+	peerCfg.V2Transport = peerCfg.V2Transport && s.supportsV2Transport(c.Addr)
+	p, err := peer.NewOutboundPeer(peerCfg, c.Addr.String())
+	if err != nil {
+		srvrLog.Debugf("Cannot create feeler peer %s: %v", c.Addr, err)
+		conn.Close()
+		return
+	}
+	sp.Peer = p
+	sp.connReq = c
+	sp.AssociateConnection(conn)
+	go s.peerDoneHandler(sp)
+}
+
+// supportsV2Transport returns whether the passed address is known to support
+// the v2 transport.  Outbound connections only start a v2 handshake with such
+// addresses since peers which don't support it would disconnect.
+func (s *server) supportsV2Transport(addr net.Addr) bool {
+	na, err := s.addrManager.DeserializeNetAddress(addr.String(), 0)
+	if err != nil {
+		return false
+	}
+	services, _ := s.addrManager.Services(na)
+	return services&wire.SFNodeP2PV2 != 0
+}
+
 // peerDoneHandler handles peer disconnects by notifiying the server that it's
 // done along with other performing other desirable cleanup.
 func (s *server) peerDoneHandler(sp *serverPeer) {
 	sp.WaitForDisconnect()
 	s.donePeers <- sp
 
-	// Only tell sync manager we are gone if we ever told it we existed.
-	if sp.VerAckReceived() {
+	// Only tell sync manager we are gone if we ever told it we existed,
+	// which is never the case for feeler connections.
+	if sp.VerAckReceived() && !sp.feeler {
 		s.syncManager.DonePeer(sp.Peer)
 
 		// Evict any remaining orphans that were sent by the peer.
@@ -2095,14 +2766,14 @@ func (s *server) peerHandler() {
 		inboundPeers:    make(map[int32]*serverPeer),
 		persistentPeers: make(map[int32]*serverPeer),
 		outboundPeers:   make(map[int32]*serverPeer),
-		banned:          make(map[string]time.Time),
+		banned:          s.loadBanList(),
 		outboundGroups:  make(map[string]int),
 	}
 
 	if !cfg.DisableDNSSeed {
 		// Add peers discovered through DNS to the address manager.
 		connmgr.SeedFromDNS(activeNetParams.Params, defaultRequiredServices,
-			btcdLookup, func(addrs []*wire.NetAddress) {
+			btcdLookup, func(addrs []*wire.NetAddressV2) {
 				// Bitcoind uses a lookup of the dns seeder here. This
 				// is rather strange since the values looked up by the
 				// DNS seed lookups will vary quite a lot.
@@ -2145,6 +2816,10 @@ out:
 			s.handleQuery(state, qmsg)
 
 		case <-s.quit:
+			// Save the block-relay-only peers as the anchors of the
+			// next start before they are disconnected.
+			s.saveAnchors(state)
+
 			// Disconnect all peers on server shutdown.
 			state.forAllPeers(func(sp *serverPeer) {
 				srvrLog.Tracef("Shutdown peer %s", sp)
@@ -2158,6 +2833,12 @@ out:
 	s.syncManager.Stop()
 	s.addrManager.Stop()
 
+	// Write the unspent transaction outputs held in memory to the database
+	// now that no more blocks are processed.
+	if err := s.chain.FlushUtxoCache(); err != nil {
+		srvrLog.Errorf("Unable to flush the utxo cache: %v", err)
+	}
+
 	// Drain channels before exiting so nothing is left waiting around
 	// to send.
 cleanup:
@@ -2219,6 +2900,28 @@ func (s *server) OutboundGroupCount(key string) int {
 	return <-replyChan
 }
 
+// IsBanned returns whether the host of the passed address, in the host:port
+// form, is banned.
+func (s *server) IsBanned(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+
+	replyChan := make(chan bool, 1)
+	select {
+	case s.query <- isBannedMsg{host: host, reply: replyChan}:
+	case <-s.quit:
+		return false
+	}
+	select {
+	case banned := <-replyChan:
+		return banned
+	case <-s.quit:
+		return false
+	}
+}
+
 // AddBytesSent adds the passed number of bytes to the total bytes sent counter
 // for the server.  It is safe for concurrent access.
 func (s *server) AddBytesSent(bytesSent uint64) {
@@ -2330,6 +3033,11 @@ func (s *server) Start() {
 		go s.upnpUpdateThread()
 	}
 
+	if cfg.TorControl != "" {
+		s.wg.Add(1)
+		go s.torControlThread()
+	}
+
 	if !cfg.DisableRPC {
 		s.wg.Add(1)
 
@@ -2360,6 +3068,12 @@ func (s *server) Stop() error {
 	// Stop the CPU miner if needed
 	s.cpuMiner.Stop()
 
+	// Close the I2P session, which is also a listener, so it no longer
+	// accepts connections.
+	if s.i2p != nil {
+		s.i2p.Close()
+	}
+
 	// Shutdown the RPC server if it's not disabled.
 	if !cfg.DisableRPC {
 		s.rpcServer.Stop()
@@ -2495,7 +3209,7 @@ out:
 					srvrLog.Warnf("UPnP can't get external address: %v", err)
 					continue out
 				}
-				na := wire.NewNetAddressIPPort(externalip, uint16(listenPort),
+				na := wire.NewNetAddressV2IPPort(externalip, uint16(listenPort),
 					s.services)
 				err = s.addrManager.AddLocalAddress(na, addrmgr.UpnpPrio)
 				if err != nil {
@@ -2521,6 +3235,148 @@ out:
 	s.wg.Done()
 }
 
+// i2pKeyFilename is the name of the file in the data directory which holds the
+// private key of the I2P destination, so the node keeps its I2P address across
+// restarts.
+const i2pKeyFilename = "i2p_private_key"
+
+// dial connects to the passed address.  I2P destinations are dialed through
+// the SAM bridge and other addresses with btcdDial.
+func (s *server) dial(addr net.Addr) (net.Conn, error) {
+	if i2pAddr, ok := addr.(*connmgr.I2PAddr); ok {
+		if s.i2p == nil {
+			return nil, errors.New("i2p has not been enabled")
+		}
+		return s.i2p.Dial(i2pAddr.String(), defaultConnectTimeout)
+	}
+	return btcdDial(addr)
+}
+
+// addI2PLocalAddress advertises the passed I2P address of the node, which is
+// invoked each time the I2P session is created.
+func (s *server) addI2PLocalAddress(addr *connmgr.I2PAddr) {
+	na, err := s.addrManager.HostToNetAddress(addr.Host, connmgr.I2PPort,
+		s.services)
+	if err != nil {
+		srvrLog.Warnf("Unable to advertise I2P address %s: %v", addr, err)
+		return
+	}
+	if err := s.addrManager.AddLocalAddress(na, addrmgr.ManualPrio); err != nil {
+		srvrLog.Warnf("Unable to advertise I2P address %s: %v", addr, err)
+		return
+	}
+	srvrLog.Infof("Accepting I2P connections on %s", addr)
+}
+
+// torOnionKeyFilename is the name of the file in the data directory which
+// holds the private key of the Tor hidden service created through the Tor
+// control port, so the node keeps its onion address across restarts.
+const torOnionKeyFilename = "onion_v3_private_key"
+
+// addTorHiddenService authenticates to the Tor control port, creates a Tor v3
+// hidden service which forwards to the dedicated onion listener and advertises
+// its onion address.  The private key of the hidden service is created on
+// first use and persisted in the data directory.  The hidden service lasts as
+// long as the returned connection to the control port.
+func (s *server) addTorHiddenService() (*connmgr.TorControl, error) {
+	target := s.onionListener.Addr().String()
+	port, err := strconv.ParseUint(activeNetParams.DefaultPort, 10, 16)
+	if err != nil {
+		return nil, err
+	}
+
+	keyPath := filepath.Join(cfg.DataDir, torOnionKeyFilename)
+	key, err := ioutil.ReadFile(keyPath)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	ctrl, err := connmgr.DialTorControl(cfg.TorControl)
+	if err != nil {
+		return nil, err
+	}
+	if err := ctrl.Authenticate(cfg.TorPassword); err != nil {
+		ctrl.Close()
+		return nil, err
+	}
+	serviceID, newKey, err := ctrl.AddOnion(strings.TrimSpace(string(key)),
+		uint16(port), target)
+	if err != nil {
+		ctrl.Close()
+		return nil, err
+	}
+	if newKey != "" {
+		err := ioutil.WriteFile(keyPath, []byte(newKey+"\n"), 0600)
+		if err != nil {
+			srvrLog.Warnf("Unable to save the Tor hidden service "+
+				"key to %s: %v", keyPath, err)
+		}
+	}
+
+	na, err := s.addrManager.HostToNetAddress(serviceID+".onion",
+		uint16(port), s.services)
+	if err != nil {
+		ctrl.Close()
+		return nil, err
+	}
+	if err := s.addrManager.AddLocalAddress(na, addrmgr.ManualPrio); err != nil {
+		ctrl.Close()
+		return nil, err
+	}
+	srvrLog.Infof("Tor hidden service %s forwards to %s",
+		addrmgr.NetAddressKey(na), target)
+	return ctrl, nil
+}
+
+// torControlThread creates the Tor hidden service of the peer listener and
+// keeps the connection to the Tor control port open until the server shuts
+// down, since Tor removes the hidden service once it is closed.  The hidden
+// service is created again when the connection is lost, such as when Tor
+// restarts, and attempts which fail are retried with a growing delay.
+func (s *server) torControlThread() {
+	defer s.wg.Done()
+
+	retryDelay := torControlRetryMin
+	for {
+		ctrl, err := s.addTorHiddenService()
+		if err != nil {
+			srvrLog.Warnf("Unable to create Tor hidden service through "+
+				"%s: %v -- retrying in %v", cfg.TorControl, err,
+				retryDelay)
+		} else {
+			retryDelay = torControlRetryMin
+			done := make(chan error, 1)
+			go func() {
+				done <- ctrl.Wait()
+			}()
+			select {
+			case err := <-done:
+				srvrLog.Warnf("Lost connection to the Tor control "+
+					"port %s: %v -- reconnecting in %v",
+					cfg.TorControl, err, retryDelay)
+				ctrl.Close()
+
+			case <-s.quit:
+				ctrl.Close()
+				<-done
+				return
+			}
+		}
+
+		select {
+		case <-time.After(retryDelay):
+		case <-s.quit:
+			return
+		}
+		if err != nil {
+			retryDelay *= 2
+			if retryDelay > torControlRetryMax {
+				retryDelay = torControlRetryMax
+			}
+		}
+	}
+}
+
 // setupRPCListeners returns a slice of listeners that are configured for use
 // with the RPC server depending on the configuration settings for listen
 // addresses and TLS.
@@ -2584,8 +3440,27 @@ func newServer(listenAddrs, agentBlacklist, agentWhitelist []string,
 	if cfg.NoCFilters {
 		services &^= wire.SFNodeCF
 	}
+	if cfg.Prune != 0 {
+		// Pruned nodes only serve the latest blocks, so they signal
+		// that in place of being a full node per BIP0159.
+		services &^= wire.SFNodeNetwork
+		services |= wire.SFNodeNetworkLimited
+	}
+	if !cfg.NoV2Transport {
+		services |= wire.SFNodeP2PV2
+	}
 
 	amgr := addrmgr.New(cfg.DataDir, btcdLookup)
+	if cfg.ASMap != "" {
+		asmap, err := addrmgr.LoadASMap(cfg.ASMap)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load asmap %s: %v",
+				cfg.ASMap, err)
+		}
+		amgr.SetASMap(asmap)
+		srvrLog.Infof("Using asmap %s with checksum %s", cfg.ASMap,
+			asmap.Checksum())
+	}
 
 	var listeners []net.Listener
 	var nat NAT
@@ -2607,6 +3482,11 @@ func newServer(listenAddrs, agentBlacklist, agentWhitelist []string,
 		srvrLog.Infof("User-agent whitelist %s", agentWhitelist)
 	}
 
+	netGroupKey, err := wire.RandomUint64()
+	if err != nil {
+		return nil, err
+	}
+
 	s := server{
 		chainParams:          chainParams,
 		addrManager:          amgr,
@@ -2628,6 +3508,8 @@ func newServer(listenAddrs, agentBlacklist, agentWhitelist []string,
 		cfCheckptCaches:      make(map[wire.FilterType][]cfHeaderKV),
 		agentBlacklist:       agentBlacklist,
 		agentWhitelist:       agentWhitelist,
+		netGroupKey:          netGroupKey,
+		evictionSelector:     selectEvictionCandidate,
 	}
 
 	// Create the transaction and address indexes if needed.
@@ -2656,12 +3538,33 @@ func newServer(listenAddrs, agentBlacklist, agentWhitelist []string,
 		s.addrIndex = indexers.NewAddrIndex(db, chainParams)
 		indexes = append(indexes, s.addrIndex)
 	}
+	if cfg.AddressIndex {
+		indxLog.Info("Address delta index is enabled")
+		s.addrDeltaIndex = indexers.NewAddrDeltaIndex(db, chainParams)
+		indexes = append(indexes, s.addrDeltaIndex)
+	}
+	if cfg.SpentIndex {
+		indxLog.Info("Spent index is enabled")
+		s.spentIndex = indexers.NewSpentIndex(db)
+		indexes = append(indexes, s.spentIndex)
+	}
+	if cfg.TimestampIndex {
+		indxLog.Info("Timestamp index is enabled")
+		s.timestampIndex = indexers.NewTimestampIndex(db)
+		indexes = append(indexes, s.timestampIndex)
+	}
 	if !cfg.NoCFilters {
 		indxLog.Info("Committed filter index is enabled")
 		s.cfIndex = indexers.NewCfIndex(db, chainParams)
 		indexes = append(indexes, s.cfIndex)
 	}
 
+	// The deterministic masternode list is always maintained.  It is
+	// managed like the indexes so it is stored along with the blocks and
+	// caught up with the main chain when it is first created.
+	s.mnList = evo.New(db)
+	indexes = append(indexes, s.mnList)
+
 	// Create an index manager if any of the optional indexes are enabled.
 	var indexManager blockchain.IndexManager
 	if len(indexes) > 0 {
@@ -2675,16 +3578,19 @@ func newServer(listenAddrs, agentBlacklist, agentWhitelist []string,
 	}
 
 	// Create a new block chain instance with the appropriate configuration.
-	var err error
 	s.chain, err = blockchain.New(&blockchain.Config{
-		DB:           s.db,
-		Interrupt:    interrupt,
-		ChainParams:  s.chainParams,
-		Checkpoints:  checkpoints,
-		TimeSource:   s.timeSource,
-		SigCache:     s.sigCache,
-		IndexManager: indexManager,
-		HashCache:    s.hashCache,
+		DB:               s.db,
+		Interrupt:        interrupt,
+		ChainParams:      s.chainParams,
+		Checkpoints:      checkpoints,
+		UtxoSnapshots:    cfg.addUtxoSnapshots,
+		TimeSource:       s.timeSource,
+		SigCache:         s.sigCache,
+		IndexManager:     indexManager,
+		HashCache:        s.hashCache,
+		Prune:            cfg.Prune * 1024 * 1024,
+		UtxoCacheMaxSize: uint64(cfg.UtxoCacheMaxSizeMiB) * 1024 * 1024,
+		AssumeValid:      cfg.assumeValid,
 	})
 	if err != nil {
 		return nil, err
@@ -2729,7 +3635,7 @@ func newServer(listenAddrs, agentBlacklist, agentWhitelist []string,
 			MaxOrphanTxSize:      defaultMaxOrphanTxSize,
 			MaxSigOpCostPerTx:    blockchain.MaxBlockSigOpsCost / 4,
 			MinRelayTxFee:        cfg.minRelayTxFee,
-			MaxTxVersion:         2,
+			MaxTxVersion:         wire.SpecialTxVersion,
 			RejectReplacement:    cfg.RejectReplacement,
 		},
 		ChainParams:    chainParams,
@@ -2740,13 +3646,25 @@ func newServer(listenAddrs, agentBlacklist, agentWhitelist []string,
 			return s.chain.CalcSequenceLock(tx, view, true)
 		},
 		IsDeploymentActive: s.chain.IsDeploymentActive,
+		// Asset unlocks are neither relayed nor mined since their quorum
+		// signatures can't be verified.
+		CheckAssetUnlockTx: nil,
 		SigCache:           s.sigCache,
 		HashCache:          s.hashCache,
 		AddrIndex:          s.addrIndex,
+		AddrDeltaIndex:     s.addrDeltaIndex,
+		SpentIndex:         s.spentIndex,
 		FeeEstimator:       s.feeEstimator,
 	}
 	s.txMemPool = mempool.New(&txC)
 
+	s.coinJoin = coinjoin.New(&coinjoin.Config{
+		ChainParams:    chainParams,
+		TimeSource:     s.timeSource,
+		MasternodeList: s.mnList,
+	})
+	s.chain.Subscribe(s.handleCoinJoinNotification)
+
 	s.syncManager, err = netsync.New(&netsync.Config{
 		PeerNotifier:       &s,
 		Chain:              s.chain,
@@ -2791,7 +3709,7 @@ func newServer(listenAddrs, agentBlacklist, agentWhitelist []string,
 	// specified peers and actively avoid advertising and connecting to
 	// discovered peers in order to prevent it from becoming a public test
 	// network.
-	var newAddressFunc func() (net.Addr, error)
+	var newAddressFunc, feelerAddressFunc func() (net.Addr, error)
 	if !cfg.SimNet && len(cfg.ConnectPeers) == 0 {
 		newAddressFunc = func() (net.Addr, error) {
 			for tries := 0; tries < 100; tries++ {
@@ -2806,11 +3724,26 @@ func newServer(listenAddrs, agentBlacklist, agentWhitelist []string,
 				// in the same group so that we are not connecting
 				// to the same network segment at the expense of
 				// others.
-				key := addrmgr.GroupKey(addr.NetAddress())
+				key := s.addrManager.GroupKey(addr.NetAddress())
 				if s.OutboundGroupCount(key) != 0 {
 					continue
 				}
 
+				// Skip addresses of networks which can't be
+				// dialed, which are Tor hidden services when Tor
+				// is disabled and I2P destinations when there is
+				// no SAM bridge.
+				if (cfg.NoOnion && addrmgr.IsTor(addr.NetAddress())) ||
+					(s.i2p == nil && addrmgr.IsI2P(addr.NetAddress())) {
+					continue
+				}
+
+				// Skip addresses of banned subnets.
+				addrString := addrmgr.NetAddressKey(addr.NetAddress())
+				if s.IsBanned(addrString) {
+					continue
+				}
+
 				// only allow recent nodes (10mins) after we failed 30
 				// times
 				if tries < 30 && time.Since(addr.LastAttempt()) < 10*time.Minute {
@@ -2818,20 +3751,73 @@ func newServer(listenAddrs, agentBlacklist, agentWhitelist []string,
 				}
 
 				// allow nondefault ports after 50 failed tries.
-				if tries < 50 && fmt.Sprintf("%d", addr.NetAddress().Port) !=
-					activeNetParams.DefaultPort {
+				// I2P destinations have no port.
+				if tries < 50 && !addrmgr.IsI2P(addr.NetAddress()) &&
+					fmt.Sprintf("%d", addr.NetAddress().Port) !=
+						activeNetParams.DefaultPort {
 					continue
 				}
 
 				// Mark an attempt for the valid address.
 				s.addrManager.Attempt(addr.NetAddress())
 
-				addrString := addrmgr.NetAddressKey(addr.NetAddress())
 				return addrStringToNetAddr(addrString)
 			}
 
 			return nil, errors.New("no valid connect address")
 		}
+
+		// Feeler connections test addresses of the new buckets so
+		// working ones are moved to tried, along with tried addresses
+		// which would be replaced by colliding new addresses.
+		feelerAddressFunc = func() (net.Addr, error) {
+			addr := s.addrManager.GetFeelerAddress()
+			if addr == nil {
+				return nil, errors.New("no address to test")
+			}
+			if (cfg.NoOnion && addrmgr.IsTor(addr.NetAddress())) ||
+				(s.i2p == nil && addrmgr.IsI2P(addr.NetAddress())) {
+				return nil, errors.New("address is not reachable")
+			}
+			addrString := addrmgr.NetAddressKey(addr.NetAddress())
+			if s.IsBanned(addrString) {
+				return nil, errors.New("address is banned")
+			}
+
+			s.addrManager.Attempt(addr.NetAddress())
+
+			return addrStringToNetAddr(addrString)
+		}
+	}
+
+	// Set up the I2P session when a SAM bridge is configured.  It accepts
+	// inbound connections from I2P peers along with the other listeners
+	// unless listening is disabled.
+	if cfg.I2PSAM != "" {
+		i2pCfg := connmgr.I2PConfig{
+			SAMAddr: cfg.I2PSAM,
+			KeyFile: filepath.Join(cfg.DataDir, i2pKeyFilename),
+		}
+		if !cfg.DisableListen {
+			i2pCfg.OnSession = s.addI2PLocalAddress
+		}
+		s.i2p = connmgr.NewI2PSession(&i2pCfg)
+		if !cfg.DisableListen {
+			listeners = append(listeners, s.i2p)
+		}
+	}
+
+	// Accept the inbound connections of the Tor hidden service created
+	// through the Tor control port on a dedicated loopback listener, so
+	// they can be told apart from other connections from the loopback
+	// address.
+	if cfg.TorControl != "" {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			return nil, err
+		}
+		s.onionListener = listener
+		listeners = append(listeners, &onionListener{Listener: listener})
 	}
 
 	// Create a connection manager.
@@ -2839,14 +3825,36 @@ func newServer(listenAddrs, agentBlacklist, agentWhitelist []string,
 	if cfg.MaxPeers < targetOutbound {
 		targetOutbound = cfg.MaxPeers
 	}
+
+	// Block-relay-only peers are only connected to when new addresses are
+	// connected to automatically, starting with the anchors of the last
+	// run.
+	var targetBlockRelayOnly int
+	var anchors []net.Addr
+	if newAddressFunc != nil {
+		targetBlockRelayOnly = defaultTargetBlockRelayOnly
+		if cfg.MaxPeers-targetOutbound < targetBlockRelayOnly {
+			targetBlockRelayOnly = cfg.MaxPeers - targetOutbound
+		}
+		if targetBlockRelayOnly > 0 {
+			anchors = s.loadAnchors()
+		}
+	}
+
 	cmgr, err := connmgr.New(&connmgr.Config{
 		Listeners:      listeners,
 		OnAccept:       s.inboundPeerConnected,
 		RetryDuration:  connectionRetryInterval,
 		TargetOutbound: uint32(targetOutbound),
-		Dial:           btcdDial,
+		Dial:           s.dial,
 		OnConnection:   s.outboundPeerConnected,
 		GetNewAddress:  newAddressFunc,
+
+		TargetBlockRelayOnly: uint32(targetBlockRelayOnly),
+		Anchors:              anchors,
+
+		GetFeelerAddress:   feelerAddressFunc,
+		OnFeelerConnection: s.feelerPeerConnected,
 	})
 	if err != nil {
 		return nil, err
@@ -2882,21 +3890,25 @@ func newServer(listenAddrs, agentBlacklist, agentWhitelist []string,
 		}
 
 		s.rpcServer, err = newRPCServer(&rpcserverConfig{
-			Listeners:    rpcListeners,
-			StartupTime:  s.startupTime,
-			ConnMgr:      &rpcConnManager{&s},
-			SyncMgr:      &rpcSyncMgr{&s, s.syncManager},
-			TimeSource:   s.timeSource,
-			Chain:        s.chain,
-			ChainParams:  chainParams,
-			DB:           db,
-			TxMemPool:    s.txMemPool,
-			Generator:    blockTemplateGenerator,
-			CPUMiner:     s.cpuMiner,
-			TxIndex:      s.txIndex,
-			AddrIndex:    s.addrIndex,
-			CfIndex:      s.cfIndex,
-			FeeEstimator: s.feeEstimator,
+			Listeners:      rpcListeners,
+			StartupTime:    s.startupTime,
+			ConnMgr:        &rpcConnManager{&s},
+			SyncMgr:        &rpcSyncMgr{&s, s.syncManager},
+			TimeSource:     s.timeSource,
+			Chain:          s.chain,
+			ChainParams:    chainParams,
+			DB:             db,
+			TxMemPool:      s.txMemPool,
+			Generator:      blockTemplateGenerator,
+			CPUMiner:       s.cpuMiner,
+			TxIndex:        s.txIndex,
+			AddrIndex:      s.addrIndex,
+			AddrDeltaIndex: s.addrDeltaIndex,
+			SpentIndex:     s.spentIndex,
+			TimestampIndex: s.timestampIndex,
+			CfIndex:        s.cfIndex,
+			MNList:         s.mnList,
+			FeeEstimator:   s.feeEstimator,
 		})
 		if err != nil {
 			return nil, err
@@ -3013,6 +4025,16 @@ func addrStringToNetAddr(addr string) (net.Addr, error) {
 		}, nil
 	}
 
+	// I2P destinations are dialed through the SAM bridge by their host
+	// name.
+	if strings.HasSuffix(host, ".b32.i2p") {
+		if cfg.I2PSAM == "" {
+			return nil, errors.New("i2p has not been enabled")
+		}
+
+		return &connmgr.I2PAddr{Host: host, Port: port}, nil
+	}
+
 	// Tor addresses cannot be resolved to an IP, so just return an onion
 	// address instead.
 	if strings.HasSuffix(host, ".onion") {
@@ -3069,7 +4091,7 @@ func addLocalAddress(addrMgr *addrmgr.AddrManager, addr string, services wire.Se
 				continue
 			}
 
-			netAddr := wire.NewNetAddressIPPort(ifaceIP, uint16(port), services)
+			netAddr := wire.NewNetAddressV2IPPort(ifaceIP, uint16(port), services)
 			addrMgr.AddLocalAddress(netAddr, addrmgr.BoundPrio)
 		}
 	} else {
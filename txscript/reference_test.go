@@ -6,6 +6,7 @@ package txscript
 
 import (
 	"bytes"
+	"encoding/binary"
 	"encoding/hex"
 	"encoding/json"
 	"errors"
@@ -845,8 +846,18 @@ func TestCalcSignatureHash(t *testing.T) {
 			t.Fatalf("TestCalcSignatureHash: Test #%d has "+
 				"wrong length.", i)
 		}
-		var tx wire.MsgTx
+		// The vectors use random 32-bit versions, so skip those which
+		// describe a DIP0002 special transaction since they do not
+		// carry the extra payload the version implies.
 		rawTx, _ := hex.DecodeString(test[0].(string))
+		rawVersion := binary.LittleEndian.Uint32(rawTx)
+		if int16(rawVersion) >= wire.SpecialTxVersion &&
+			rawVersion>>16 != 0 {
+
+			continue
+		}
+
+		var tx wire.MsgTx
 		err := tx.Deserialize(bytes.NewReader(rawTx))
 		if err != nil {
 			t.Errorf("TestCalcSignatureHash failed test #%d: "+
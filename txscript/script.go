@@ -565,9 +565,11 @@ func shallowCopyTx(tx *wire.MsgTx) wire.MsgTx {
 	// allocations.
 	txCopy := wire.MsgTx{
 		Version:  tx.Version,
+		Type:     tx.Type,
 		TxIn:     make([]*wire.TxIn, len(tx.TxIn)),
 		TxOut:    make([]*wire.TxOut, len(tx.TxOut)),
 		LockTime: tx.LockTime,
+		Payload:  tx.Payload,
 	}
 	txIns := make([]wire.TxIn, len(tx.TxIn))
 	for i, oldTxIn := range tx.TxIn {
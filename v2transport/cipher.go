@@ -0,0 +1,127 @@
+// Copyright (c) 2013-2016 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package v2transport
+
+import (
+	"crypto/cipher"
+	"encoding/binary"
+
+	"golang.org/x/crypto/chacha20"
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+const (
+	// rekeyInterval is the number of messages encrypted with a key before
+	// the ciphers switch to a new key derived from the old one.
+	rekeyInterval = 224
+
+	// keyLen is the length of the keys used by the ciphers.
+	keyLen = chacha20poly1305.KeySize
+
+	// tagLen is the length of the authentication tag of packets.
+	tagLen = 16
+)
+
+// fsChaCha20 is a forward secure ChaCha20 stream cipher used to encrypt the
+// length of packets.  A single keystream is used for all the chunks encrypted
+// with a key, and after every rekeyInterval chunks the next bytes of the
+// keystream become the new key.
+type fsChaCha20 struct {
+	stream       *chacha20.Cipher
+	chunkCounter uint32
+	rekeyCounter uint64
+}
+
+// newFSChaCha20 returns a new forward secure ChaCha20 cipher with the passed
+// initial key.
+func newFSChaCha20(key []byte) *fsChaCha20 {
+	c := &fsChaCha20{}
+	c.setKey(key)
+	return c
+}
+
+// setKey starts the keystream of the passed key for the current rekey
+// counter.
+func (c *fsChaCha20) setKey(key []byte) {
+	var nonce [chacha20.NonceSize]byte
+	binary.LittleEndian.PutUint64(nonce[4:], c.rekeyCounter)
+
+	// The key and nonce always have the expected length.
+	c.stream, _ = chacha20.NewUnauthenticatedCipher(key, nonce[:])
+}
+
+// crypt encrypts or decrypts the passed chunk in place.
+func (c *fsChaCha20) crypt(chunk []byte) {
+	c.stream.XORKeyStream(chunk, chunk)
+
+	c.chunkCounter++
+	if c.chunkCounter == rekeyInterval {
+		var key [keyLen]byte
+		c.stream.XORKeyStream(key[:], key[:])
+		c.chunkCounter = 0
+		c.rekeyCounter++
+		c.setKey(key[:])
+	}
+}
+
+// fsChaCha20Poly1305 is a forward secure ChaCha20-Poly1305 AEAD used to
+// encrypt the contents of packets.  After every rekeyInterval packets the
+// encryption of 32 zero bytes with a reserved nonce becomes the new key.
+type fsChaCha20Poly1305 struct {
+	aead          cipher.AEAD
+	packetCounter uint32
+	rekeyCounter  uint64
+}
+
+// newFSChaCha20Poly1305 returns a new forward secure ChaCha20-Poly1305 AEAD
+// with the passed initial key.
+func newFSChaCha20Poly1305(key []byte) *fsChaCha20Poly1305 {
+	// The key always has the expected length.
+	aead, _ := chacha20poly1305.New(key)
+	return &fsChaCha20Poly1305{aead: aead}
+}
+
+// nonce returns the nonce of the next packet.
+func (c *fsChaCha20Poly1305) nonce(packetCounter uint32) []byte {
+	nonce := make([]byte, chacha20poly1305.NonceSize)
+	binary.LittleEndian.PutUint32(nonce[:4], packetCounter)
+	binary.LittleEndian.PutUint64(nonce[4:], c.rekeyCounter)
+	return nonce
+}
+
+// advance moves to the next packet, switching to a new key when the current
+// one has been used for rekeyInterval packets.
+func (c *fsChaCha20Poly1305) advance() {
+	c.packetCounter++
+	if c.packetCounter != rekeyInterval {
+		return
+	}
+
+	var zero [keyLen]byte
+	key := c.aead.Seal(nil, c.nonce(0xffffffff), zero[:], nil)[:keyLen]
+	c.aead, _ = chacha20poly1305.New(key)
+	c.packetCounter = 0
+	c.rekeyCounter++
+}
+
+// seal encrypts and authenticates the passed plaintext along with the passed
+// additional data, appends the result to dst and returns the updated slice.
+func (c *fsChaCha20Poly1305) seal(dst, plaintext, aad []byte) []byte {
+	dst = c.aead.Seal(dst, c.nonce(c.packetCounter), plaintext, aad)
+	c.advance()
+	return dst
+}
+
+// open decrypts and authenticates the passed ciphertext along with the passed
+// additional data, appends the resulting plaintext to dst and returns the
+// updated slice.
+func (c *fsChaCha20Poly1305) open(dst, ciphertext, aad []byte) ([]byte, error) {
+	dst, err := c.aead.Open(dst, c.nonce(c.packetCounter), ciphertext, aad)
+	if err != nil {
+		return nil, err
+	}
+	c.advance()
+	return dst, nil
+}
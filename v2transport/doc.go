@@ -0,0 +1,30 @@
+// Copyright (c) 2013-2016 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+/*
+Package v2transport implements the v2 encrypted P2P transport defined by
+BIP0324.
+
+Overview
+
+The v2 transport encrypts all traffic between two peers so that passive
+observers can neither read the exchanged messages nor recognize the traffic as
+bitcoin traffic.  Both peers exchange ephemeral public keys in the
+ElligatorSwift encoding, which is indistinguishable from random bytes, followed
+by a random amount of garbage.  The keys derived from the ECDH secret encrypt
+the length of every packet with ChaCha20 and its contents with
+ChaCha20-Poly1305, and both ciphers switch to new keys after 224 messages for
+forward secrecy.  Messages use short message IDs in place of the 12 byte
+commands of the v1 transport where possible.
+
+A responding transport detects peers which start a v1 connection from the
+first bytes they send, so listening peers are able to serve both transports on
+the same port.  Peers signal support for the v2 transport with the
+SFNodeP2PV2 service flag.
+
+Note that the v2 transport does not authenticate the remote peer, so an active
+man in the middle remains possible.  The session ID returned by the transport
+may be compared out of band to detect one.
+*/
+package v2transport
@@ -0,0 +1,424 @@
+// Copyright (c) 2013-2016 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package v2transport
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"io"
+
+	"github.com/eager7/dashd/btcec"
+	"github.com/eager7/dashd/wire"
+	"golang.org/x/crypto/hkdf"
+)
+
+const (
+	// garbageTerminatorLen is the length of the garbage terminators which
+	// mark the end of the garbage sent during the handshake.
+	garbageTerminatorLen = 16
+
+	// MaxGarbageLen is the maximum number of garbage bytes which may be
+	// sent after the public key during the handshake.
+	MaxGarbageLen = 4095
+
+	// lengthFieldLen is the length of the encrypted length of the contents
+	// which starts every packet.
+	lengthFieldLen = 3
+
+	// headerLen is the length of the header which is encrypted along with
+	// the contents of every packet.
+	headerLen = 1
+
+	// ignoreBit is the bit of the packet header which marks decoy packets
+	// the receiver must ignore.
+	ignoreBit = 1 << 7
+
+	// MaxContentsLen is the maximum length of the contents of a packet,
+	// which is limited by the size of the length field.
+	MaxContentsLen = 1<<(8*lengthFieldLen) - 1
+)
+
+var (
+	// ErrV1Peer is returned by the handshake of a responding transport
+	// when the remote peer started a v1 connection instead.  The bytes
+	// read until this was detected are available from V1Prefix.
+	ErrV1Peer = errors.New("peer uses the v1 transport")
+
+	// ErrNoGarbageTerminator is returned during the handshake when the
+	// garbage terminator of the remote peer was not found after the
+	// maximum amount of garbage.
+	ErrNoGarbageTerminator = errors.New("garbage terminator not found")
+
+	// ErrPacketAuth is returned when a packet fails authentication.
+	ErrPacketAuth = errors.New("packet authentication failed")
+
+	// ErrPacketTooLarge is returned when the contents of a packet to send
+	// exceed MaxContentsLen.
+	ErrPacketTooLarge = errors.New("packet contents are too large")
+)
+
+// Transport implements the v2 encrypted P2P transport defined by BIP0324 on
+// top of a connection.  Handshake must complete successfully before packets
+// are read or written.  Reading and writing packets use independent ciphers,
+// so one goroutine may read while another one writes, but neither may be
+// done concurrently with itself.
+type Transport struct {
+	rw        io.ReadWriter
+	btcnet    wire.BitcoinNet
+	initiator bool
+
+	v1Prefix  []byte
+	sessionID [32]byte
+
+	sendL                 *fsChaCha20
+	sendP                 *fsChaCha20Poly1305
+	sendGarbageTerminator []byte
+
+	recvL                 *fsChaCha20
+	recvP                 *fsChaCha20Poly1305
+	recvGarbageTerminator []byte
+}
+
+// NewTransport returns a new v2 transport over the passed connection for the
+// passed bitcoin network.  The initiator flag must be set on the side which
+// opened the connection.
+func NewTransport(rw io.ReadWriter, btcnet wire.BitcoinNet, initiator bool) *Transport {
+	return &Transport{
+		rw:        rw,
+		btcnet:    btcnet,
+		initiator: initiator,
+	}
+}
+
+// v1Prefix returns the first bytes of the version message a v1 peer sends to
+// start a connection on the passed bitcoin network.
+func v1Prefix(btcnet wire.BitcoinNet) []byte {
+	prefix := make([]byte, 4+wire.CommandSize)
+	binary.LittleEndian.PutUint32(prefix, uint32(btcnet))
+	copy(prefix[4:], wire.CmdVersion)
+	return prefix
+}
+
+// taggedHash returns the BIP0340 tagged hash of the concatenation of the
+// passed messages.
+func taggedHash(tag string, msgs ...[]byte) []byte {
+	tagHash := sha256.Sum256([]byte(tag))
+	h := sha256.New()
+	h.Write(tagHash[:])
+	h.Write(tagHash[:])
+	for _, msg := range msgs {
+		h.Write(msg)
+	}
+	return h.Sum(nil)
+}
+
+// handshakeWriter writes the data queued during the handshake from a separate
+// goroutine, so that both sides can send their handshake data before reading
+// the data of the other side regardless of the buffering of the connection.
+type handshakeWriter struct {
+	queue chan []byte
+	done  chan error
+}
+
+// newHandshakeWriter returns a new handshake writer for the passed writer.
+func newHandshakeWriter(w io.Writer) *handshakeWriter {
+	hw := &handshakeWriter{
+		queue: make(chan []byte, 2),
+		done:  make(chan error, 1),
+	}
+	go func() {
+		var err error
+		for b := range hw.queue {
+			if err == nil {
+				_, err = w.Write(b)
+			}
+		}
+		hw.done <- err
+	}()
+	return hw
+}
+
+// write queues the passed data.
+func (hw *handshakeWriter) write(b []byte) {
+	hw.queue <- b
+}
+
+// close waits for the queued data to be written and returns the first error
+// that occurred.
+func (hw *handshakeWriter) close() error {
+	close(hw.queue)
+	return <-hw.done
+}
+
+// Handshake performs the v2 handshake with the remote peer.  For a responding
+// transport, ErrV1Peer is returned when the remote peer started a v1
+// connection.
+func (t *Transport) Handshake() error {
+	hw := newHandshakeWriter(t.rw)
+	if err := t.handshake(hw); err != nil {
+		// Don't wait for pending writes since the remote peer might
+		// never read them.  They fail once the connection is closed.
+		close(hw.queue)
+		return err
+	}
+	return hw.close()
+}
+
+// handshake performs the v2 handshake, queueing the data to send on the
+// passed handshake writer.
+func (t *Transport) handshake(hw *handshakeWriter) error {
+	privKey, err := btcec.NewPrivateKey(btcec.S256())
+	if err != nil {
+		return err
+	}
+
+	// The encoded public key of the initiator must not look like the
+	// start of a v1 connection, which the responder would fall back to.
+	prefix := v1Prefix(t.btcnet)
+	var ours [btcec.EllSwiftPubKeyLen]byte
+	for {
+		ours, err = btcec.EllSwiftEncode(privKey.PubKey(), rand.Reader)
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(ours[:len(prefix)], prefix) {
+			break
+		}
+	}
+
+	// Pick a random amount of random garbage to send along with the public
+	// key.
+	var garbageLen [2]byte
+	if _, err := rand.Read(garbageLen[:]); err != nil {
+		return err
+	}
+	garbage := make([]byte, binary.LittleEndian.Uint16(garbageLen[:])%
+		(MaxGarbageLen+1))
+	if _, err := rand.Read(garbage); err != nil {
+		return err
+	}
+	keyAndGarbage := append(ours[:], garbage...)
+
+	// The initiator sends its public key first, while the responder first
+	// checks whether the peer started a v1 connection instead.
+	var theirs [btcec.EllSwiftPubKeyLen]byte
+	if t.initiator {
+		hw.write(keyAndGarbage)
+		if _, err := io.ReadFull(t.rw, theirs[:]); err != nil {
+			return err
+		}
+	} else {
+		if _, err := io.ReadFull(t.rw, theirs[:len(prefix)]); err != nil {
+			return err
+		}
+		if bytes.Equal(theirs[:len(prefix)], prefix) {
+			t.v1Prefix = prefix
+			return ErrV1Peer
+		}
+		if _, err := io.ReadFull(t.rw, theirs[len(prefix):]); err != nil {
+			return err
+		}
+		hw.write(keyAndGarbage)
+	}
+
+	t.initCiphers(privKey, &ours, &theirs)
+
+	// Send the garbage terminator followed by the version packet, which
+	// authenticates the garbage sent.
+	versionPacket := append([]byte(nil), t.sendGarbageTerminator...)
+	versionPacket = t.encryptPacket(versionPacket, nil, garbage, false)
+	hw.write(versionPacket)
+
+	return t.receiveGarbageAndVersion()
+}
+
+// initCiphers derives the keys of the ciphers and the session ID from the ECDH
+// exchange of the passed private key and the encoded public keys.
+func (t *Transport) initCiphers(privKey *btcec.PrivateKey, ours,
+	theirs *[btcec.EllSwiftPubKeyLen]byte) {
+
+	initiatorKey, responderKey := ours, theirs
+	if !t.initiator {
+		initiatorKey, responderKey = theirs, ours
+	}
+	sharedX := btcec.EllSwiftSharedX(privKey, theirs)
+	secret := taggedHash("bip324_ellswift_xonly_ecdh", initiatorKey[:],
+		responderKey[:], sharedX[:])
+
+	var magic [4]byte
+	binary.LittleEndian.PutUint32(magic[:], uint32(t.btcnet))
+	salt := append([]byte("bitcoin_v2_shared_secret"), magic[:]...)
+	prk := hkdf.Extract(sha256.New, secret, salt)
+	expand := func(label string) []byte {
+		key := make([]byte, keyLen)
+		io.ReadFull(hkdf.Expand(sha256.New, prk, []byte(label)), key)
+		return key
+	}
+
+	initiatorL := newFSChaCha20(expand("initiator_L"))
+	initiatorP := newFSChaCha20Poly1305(expand("initiator_P"))
+	responderL := newFSChaCha20(expand("responder_L"))
+	responderP := newFSChaCha20Poly1305(expand("responder_P"))
+	terminators := expand("garbage_terminators")
+	copy(t.sessionID[:], expand("session_id"))
+
+	if t.initiator {
+		t.sendL, t.sendP = initiatorL, initiatorP
+		t.recvL, t.recvP = responderL, responderP
+		t.sendGarbageTerminator = terminators[:garbageTerminatorLen]
+		t.recvGarbageTerminator = terminators[garbageTerminatorLen:]
+	} else {
+		t.sendL, t.sendP = responderL, responderP
+		t.recvL, t.recvP = initiatorL, initiatorP
+		t.sendGarbageTerminator = terminators[garbageTerminatorLen:]
+		t.recvGarbageTerminator = terminators[:garbageTerminatorLen]
+	}
+}
+
+// receiveGarbageAndVersion reads the garbage of the remote peer up to its
+// garbage terminator followed by its version packet.
+func (t *Transport) receiveGarbageAndVersion() error {
+	buf := make([]byte, garbageTerminatorLen,
+		garbageTerminatorLen+MaxGarbageLen)
+	if _, err := io.ReadFull(t.rw, buf); err != nil {
+		return err
+	}
+	for !bytes.Equal(buf[len(buf)-garbageTerminatorLen:],
+		t.recvGarbageTerminator) {
+
+		if len(buf) == cap(buf) {
+			return ErrNoGarbageTerminator
+		}
+		var b [1]byte
+		if _, err := io.ReadFull(t.rw, b[:]); err != nil {
+			return err
+		}
+		buf = append(buf, b[0])
+	}
+
+	// The first packet authenticates the garbage.  Decoy packets may be
+	// sent before the version packet, whose contents are reserved for
+	// future extensions and ignored.
+	aad := buf[:len(buf)-garbageTerminatorLen]
+	for {
+		_, ignore, _, err := t.readPacket(aad)
+		if err != nil {
+			return err
+		}
+		if !ignore {
+			return nil
+		}
+		aad = nil
+	}
+}
+
+// encryptPacket appends the packet of the passed contents and additional data
+// to dst and returns the updated slice.  The packet is a decoy the remote peer
+// ignores when the ignore flag is set.
+func (t *Transport) encryptPacket(dst, contents, aad []byte, ignore bool) []byte {
+	var length [lengthFieldLen]byte
+	length[0] = byte(len(contents))
+	length[1] = byte(len(contents) >> 8)
+	length[2] = byte(len(contents) >> 16)
+	t.sendL.crypt(length[:])
+	dst = append(dst, length[:]...)
+
+	plaintext := make([]byte, headerLen+len(contents))
+	if ignore {
+		plaintext[0] = ignoreBit
+	}
+	copy(plaintext[headerLen:], contents)
+	return t.sendP.seal(dst, plaintext, aad)
+}
+
+// readPacket reads and decrypts the next packet with the passed additional
+// data.  It returns the contents of the packet, whether it is a decoy and the
+// number of bytes read.
+func (t *Transport) readPacket(aad []byte) ([]byte, bool, int, error) {
+	var length [lengthFieldLen]byte
+	n, err := io.ReadFull(t.rw, length[:])
+	if err != nil {
+		return nil, false, n, err
+	}
+	t.recvL.crypt(length[:])
+	contentsLen := int(length[0]) | int(length[1])<<8 | int(length[2])<<16
+
+	ciphertext := make([]byte, headerLen+contentsLen+tagLen)
+	read, err := io.ReadFull(t.rw, ciphertext)
+	n += read
+	if err != nil {
+		return nil, false, n, err
+	}
+
+	plaintext, err := t.recvP.open(ciphertext[:0], ciphertext, aad)
+	if err != nil {
+		return nil, false, n, ErrPacketAuth
+	}
+	return plaintext[headerLen:], plaintext[0]&ignoreBit != 0, n, nil
+}
+
+// ReadPacket reads the next packet which isn't a decoy and returns its
+// contents along with the number of bytes read.
+func (t *Transport) ReadPacket() ([]byte, int, error) {
+	var total int
+	for {
+		contents, ignore, n, err := t.readPacket(nil)
+		total += n
+		if err != nil {
+			return nil, total, err
+		}
+		if !ignore {
+			return contents, total, nil
+		}
+	}
+}
+
+// WritePacket writes a packet with the passed contents and returns the number
+// of bytes written.  The packet is a decoy the remote peer ignores when the
+// decoy flag is set.
+func (t *Transport) WritePacket(contents []byte, decoy bool) (int, error) {
+	if len(contents) > MaxContentsLen {
+		return 0, ErrPacketTooLarge
+	}
+	return t.rw.Write(t.encryptPacket(nil, contents, nil, decoy))
+}
+
+// ReadMessage reads, decrypts and parses the next message from the remote
+// peer.  It returns the number of bytes read in addition to the parsed message
+// and its raw payload bytes.
+func (t *Transport) ReadMessage(pver uint32, enc wire.MessageEncoding) (int, wire.Message, []byte, error) {
+	contents, n, err := t.ReadPacket()
+	if err != nil {
+		return n, nil, nil, err
+	}
+	msg, payload, err := wire.DecodeV2Message(contents, pver, enc)
+	return n, msg, payload, err
+}
+
+// WriteMessage encrypts and writes the passed message to the remote peer and
+// returns the number of bytes written.
+func (t *Transport) WriteMessage(msg wire.Message, pver uint32, enc wire.MessageEncoding) (int, error) {
+	contents, err := wire.EncodeV2Message(msg, pver, enc)
+	if err != nil {
+		return 0, err
+	}
+	return t.WritePacket(contents, false)
+}
+
+// SessionID returns the session ID both sides derive during the handshake,
+// which may be compared out of band to detect a man in the middle.
+func (t *Transport) SessionID() [32]byte {
+	return t.sessionID
+}
+
+// V1Prefix returns the bytes read from a remote peer which started a v1
+// connection before Handshake returned ErrV1Peer.  They must be processed
+// before the rest of the connection.
+func (t *Transport) V1Prefix() []byte {
+	return t.v1Prefix
+}
@@ -0,0 +1,163 @@
+// Copyright (c) 2013-2016 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package v2transport
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/eager7/dashd/wire"
+)
+
+// handshake performs the handshake of a new pair of transports connected with
+// a pipe and returns them.
+func handshake(t *testing.T) (*Transport, *Transport, net.Conn, net.Conn) {
+	initConn, respConn := net.Pipe()
+	initiator := NewTransport(initConn, wire.MainNet, true)
+	responder := NewTransport(respConn, wire.MainNet, false)
+
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- responder.Handshake()
+	}()
+	if err := initiator.Handshake(); err != nil {
+		t.Fatalf("initiator Handshake: %v", err)
+	}
+	if err := <-errChan; err != nil {
+		t.Fatalf("responder Handshake: %v", err)
+	}
+	if initiator.SessionID() != responder.SessionID() {
+		t.Fatalf("session ID mismatch - initiator: %x, responder: %x",
+			initiator.SessionID(), responder.SessionID())
+	}
+	return initiator, responder, initConn, respConn
+}
+
+// TestTransport ensures messages are exchanged through v2 transports across
+// several rekeys in both directions.
+func TestTransport(t *testing.T) {
+	initiator, responder, initConn, respConn := handshake(t)
+	defer initConn.Close()
+	defer respConn.Close()
+
+	pver := wire.ProtocolVersion
+	tests := []struct {
+		from, to *Transport
+	}{
+		{initiator, responder},
+		{responder, initiator},
+	}
+	for _, test := range tests {
+		errChan := make(chan error, 1)
+		go func(from *Transport) {
+			for i := 0; i < 3*rekeyInterval; i++ {
+				// Send a decoy along the way, which is skipped.
+				if i == rekeyInterval-1 {
+					_, err := from.WritePacket([]byte("decoy"), true)
+					if err != nil {
+						errChan <- err
+						return
+					}
+				}
+				msg := wire.NewMsgPing(uint64(i))
+				_, err := from.WriteMessage(msg, pver, wire.BaseEncoding)
+				if err != nil {
+					errChan <- err
+					return
+				}
+			}
+			errChan <- nil
+		}(test.from)
+
+		for i := 0; i < 3*rekeyInterval; i++ {
+			_, msg, _, err := test.to.ReadMessage(pver, wire.BaseEncoding)
+			if err != nil {
+				t.Fatalf("ReadMessage #%d: %v", i, err)
+			}
+			want := wire.NewMsgPing(uint64(i))
+			if !reflect.DeepEqual(msg, want) {
+				t.Fatalf("ReadMessage #%d: got %v, want %v", i, msg,
+					want)
+			}
+		}
+		if err := <-errChan; err != nil {
+			t.Fatalf("WriteMessage: %v", err)
+		}
+	}
+}
+
+// TestTransportTampered ensures modified packets fail authentication.
+func TestTransportTampered(t *testing.T) {
+	initiator, responder, initConn, respConn := handshake(t)
+	defer initConn.Close()
+	defer respConn.Close()
+
+	// Flip a bit of the contents of the packet on its way.
+	var buf bytes.Buffer
+	initiator.rw = &buf
+	if _, err := initiator.WritePacket([]byte("hello"), false); err != nil {
+		t.Fatalf("WritePacket: %v", err)
+	}
+	packet := buf.Bytes()
+	packet[lengthFieldLen+headerLen] ^= 0x01
+	go initConn.Write(packet)
+
+	if _, _, err := responder.ReadPacket(); err != ErrPacketAuth {
+		t.Fatalf("ReadPacket: got %v, want %v", err, ErrPacketAuth)
+	}
+}
+
+// TestTransportV1Fallback ensures a responding transport detects peers which
+// start a v1 connection.
+func TestTransportV1Fallback(t *testing.T) {
+	initConn, respConn := net.Pipe()
+	defer initConn.Close()
+	defer respConn.Close()
+
+	go wire.WriteMessage(initConn, &wire.MsgVersion{}, wire.ProtocolVersion,
+		wire.MainNet)
+
+	responder := NewTransport(respConn, wire.MainNet, false)
+	if err := responder.Handshake(); err != ErrV1Peer {
+		t.Fatalf("Handshake: got %v, want %v", err, ErrV1Peer)
+	}
+
+	// The bytes read by the handshake followed by the rest of the
+	// connection make up the version message.
+	r := io.MultiReader(bytes.NewReader(responder.V1Prefix()), respConn)
+	msg, _, err := wire.ReadMessage(r, wire.ProtocolVersion, wire.MainNet)
+	if err != nil {
+		t.Fatalf("ReadMessage: %v", err)
+	}
+	if _, ok := msg.(*wire.MsgVersion); !ok {
+		t.Fatalf("ReadMessage: unexpected message %T", msg)
+	}
+}
+
+// TestTransportNoGarbageTerminator ensures the handshake fails when the
+// garbage of the remote peer is too long.
+func TestTransportNoGarbageTerminator(t *testing.T) {
+	initConn, respConn := net.Pipe()
+	defer initConn.Close()
+	defer respConn.Close()
+
+	// Send a public key followed by too much garbage, while discarding
+	// what the responder sends.
+	go func() {
+		initConn.Write(bytes.Repeat([]byte{0x42}, 64))
+		initConn.Write(make([]byte, garbageTerminatorLen+MaxGarbageLen))
+	}()
+	go io.Copy(ioutil.Discard, initConn)
+
+	responder := NewTransport(respConn, wire.MainNet, false)
+	if err := responder.Handshake(); err != ErrNoGarbageTerminator {
+		t.Fatalf("Handshake: got %v, want %v", err,
+			ErrNoGarbageTerminator)
+	}
+}
@@ -0,0 +1,218 @@
+// Copyright (c) 2013-2016 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/eager7/dashd/chaincfg/chainhash"
+)
+
+const (
+	// AssetLockPayloadVersion is the current version of the asset lock
+	// special transaction payload.
+	AssetLockPayloadVersion = 1
+
+	// AssetUnlockPayloadVersion is the current version of the asset unlock
+	// special transaction payload.
+	AssetUnlockPayloadVersion = 1
+
+	// QuorumSigSize is the size of a serialized BLS threshold signature
+	// produced by an LLMQ.
+	QuorumSigSize = 96
+
+	// maxCreditOutputsPerPayload is the maximum number of credit outputs
+	// that could possibly fit into the extra payload of an asset lock.
+	maxCreditOutputsPerPayload = MaxTxExtraPayload / MinTxOutPayload
+)
+
+// AssetLockPayload represents the extra payload of an asset lock special
+// transaction (type 8) as defined by DIP0027.  The credit outputs describe
+// where the locked funds are credited on Platform.
+type AssetLockPayload struct {
+	Version       uint8
+	CreditOutputs []*TxOut
+}
+
+// Deserialize decodes an asset lock payload from r into the receiver.
+func (p *AssetLockPayload) Deserialize(r io.Reader) error {
+	var err error
+	p.Version, err = binarySerializer.Uint8(r)
+	if err != nil {
+		return err
+	}
+
+	count, err := ReadVarInt(r, 0)
+	if err != nil {
+		return err
+	}
+
+	// Prevent more credit outputs than could possibly fit into the extra
+	// payload.
+	if count > maxCreditOutputsPerPayload {
+		str := fmt.Sprintf("too many credit outputs to fit into max "+
+			"payload size [count %d, max %d]", count,
+			maxCreditOutputsPerPayload)
+		return messageError("AssetLockPayload.Deserialize", str)
+	}
+
+	p.CreditOutputs = make([]*TxOut, count)
+	for i := uint64(0); i < count; i++ {
+		to := TxOut{}
+		err := readElement(r, &to.Value)
+		if err != nil {
+			return err
+		}
+		to.PkScript, err = ReadVarBytes(r, 0, MaxTxExtraPayload,
+			"credit output script")
+		if err != nil {
+			return err
+		}
+		p.CreditOutputs[i] = &to
+	}
+
+	return nil
+}
+
+// Serialize encodes the receiver to w.
+func (p *AssetLockPayload) Serialize(w io.Writer) error {
+	err := binarySerializer.PutUint8(w, p.Version)
+	if err != nil {
+		return err
+	}
+
+	err = WriteVarInt(w, 0, uint64(len(p.CreditOutputs)))
+	if err != nil {
+		return err
+	}
+
+	for _, to := range p.CreditOutputs {
+		err = WriteTxOut(w, 0, 0, to)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// SerializeSize returns the number of bytes it would take to serialize the
+// payload.
+func (p *AssetLockPayload) SerializeSize() int {
+	// Version 1 byte + serialized varint size for the number of credit
+	// outputs.
+	n := 1 + VarIntSerializeSize(uint64(len(p.CreditOutputs)))
+	for _, to := range p.CreditOutputs {
+		n += to.SerializeSize()
+	}
+
+	return n
+}
+
+// AssetUnlockPayload represents the extra payload of an asset unlock special
+// transaction (type 9) as defined by DIP0027.  Asset unlocks have no inputs
+// and instead release funds from the credit pool once the withdrawal has been
+// signed by a platform quorum.
+type AssetUnlockPayload struct {
+	Version         uint8
+	Index           uint64
+	Fee             uint32
+	RequestedHeight uint32
+	QuorumHash      chainhash.Hash
+	QuorumSig       [QuorumSigSize]byte
+}
+
+// Deserialize decodes an asset unlock payload from r into the receiver.
+func (p *AssetUnlockPayload) Deserialize(r io.Reader) error {
+	var err error
+	p.Version, err = binarySerializer.Uint8(r)
+	if err != nil {
+		return err
+	}
+
+	err = readElements(r, &p.Index, &p.Fee, &p.RequestedHeight,
+		&p.QuorumHash)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.ReadFull(r, p.QuorumSig[:])
+	return err
+}
+
+// Serialize encodes the receiver to w.
+func (p *AssetUnlockPayload) Serialize(w io.Writer) error {
+	err := binarySerializer.PutUint8(w, p.Version)
+	if err != nil {
+		return err
+	}
+
+	err = writeElements(w, p.Index, p.Fee, p.RequestedHeight,
+		&p.QuorumHash)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(p.QuorumSig[:])
+	return err
+}
+
+// SerializeSize returns the number of bytes it would take to serialize the
+// payload.
+func (p *AssetUnlockPayload) SerializeSize() int {
+	// Version 1 byte + Index 8 bytes + Fee 4 bytes + RequestedHeight 4
+	// bytes + QuorumHash 32 bytes + QuorumSig 96 bytes.
+	return 1 + 8 + 4 + 4 + chainhash.HashSize + QuorumSigSize
+}
+
+// AssetLockPayload decodes and returns the extra payload of an asset lock
+// transaction.  An error is returned when the transaction is not an asset
+// lock or the payload is malformed.
+func (msg *MsgTx) AssetLockPayload() (*AssetLockPayload, error) {
+	if !msg.IsSpecial() || msg.Type != TxTypeAssetLock {
+		str := fmt.Sprintf("transaction type %v is not an asset lock",
+			msg.Type)
+		return nil, messageError("MsgTx.AssetLockPayload", str)
+	}
+
+	var payload AssetLockPayload
+	r := bytes.NewReader(msg.Payload)
+	if err := payload.Deserialize(r); err != nil {
+		return nil, err
+	}
+	if r.Len() != 0 {
+		str := fmt.Sprintf("%d trailing bytes after asset lock "+
+			"payload", r.Len())
+		return nil, messageError("MsgTx.AssetLockPayload", str)
+	}
+
+	return &payload, nil
+}
+
+// AssetUnlockPayload decodes and returns the extra payload of an asset unlock
+// transaction.  An error is returned when the transaction is not an asset
+// unlock or the payload is malformed.
+func (msg *MsgTx) AssetUnlockPayload() (*AssetUnlockPayload, error) {
+	if !msg.IsSpecial() || msg.Type != TxTypeAssetUnlock {
+		str := fmt.Sprintf("transaction type %v is not an asset "+
+			"unlock", msg.Type)
+		return nil, messageError("MsgTx.AssetUnlockPayload", str)
+	}
+
+	var payload AssetUnlockPayload
+	r := bytes.NewReader(msg.Payload)
+	if err := payload.Deserialize(r); err != nil {
+		return nil, err
+	}
+	if r.Len() != 0 {
+		str := fmt.Sprintf("%d trailing bytes after asset unlock "+
+			"payload", r.Len())
+		return nil, messageError("MsgTx.AssetUnlockPayload", str)
+	}
+
+	return &payload, nil
+}
@@ -0,0 +1,145 @@
+// Copyright (c) 2013-2016 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/eager7/dashd/chaincfg/chainhash"
+)
+
+const (
+	// CbTxVersionMerkleRootQuorums is the coinbase payload version which
+	// added the merkle root of the active quorums.
+	CbTxVersionMerkleRootQuorums = 2
+
+	// CbTxVersionCreditPool is the coinbase payload version which added
+	// the best chainlock and the credit pool balance.
+	CbTxVersionCreditPool = 3
+)
+
+// CoinbasePayload represents the extra payload of a coinbase special
+// transaction (type 5) as defined by DIP0004.  Only the fields that are
+// present for the payload version are serialized.
+type CoinbasePayload struct {
+	Version           uint16
+	Height            int32
+	MerkleRootMNList  chainhash.Hash
+	MerkleRootQuorums chainhash.Hash
+	BestCLHeightDiff  uint64
+	BestCLSignature   [QuorumSigSize]byte
+	CreditPoolBalance int64
+}
+
+// Deserialize decodes a coinbase payload from r into the receiver.
+func (p *CoinbasePayload) Deserialize(r io.Reader) error {
+	var err error
+	p.Version, err = binarySerializer.Uint16(r, littleEndian)
+	if err != nil {
+		return err
+	}
+
+	err = readElements(r, &p.Height, &p.MerkleRootMNList)
+	if err != nil {
+		return err
+	}
+	if p.Version < CbTxVersionMerkleRootQuorums {
+		return nil
+	}
+
+	err = readElement(r, &p.MerkleRootQuorums)
+	if err != nil {
+		return err
+	}
+	if p.Version < CbTxVersionCreditPool {
+		return nil
+	}
+
+	p.BestCLHeightDiff, err = ReadVarInt(r, 0)
+	if err != nil {
+		return err
+	}
+	if _, err := io.ReadFull(r, p.BestCLSignature[:]); err != nil {
+		return err
+	}
+	return readElement(r, &p.CreditPoolBalance)
+}
+
+// Serialize encodes the receiver to w.
+func (p *CoinbasePayload) Serialize(w io.Writer) error {
+	err := binarySerializer.PutUint16(w, littleEndian, p.Version)
+	if err != nil {
+		return err
+	}
+
+	err = writeElements(w, p.Height, &p.MerkleRootMNList)
+	if err != nil {
+		return err
+	}
+	if p.Version < CbTxVersionMerkleRootQuorums {
+		return nil
+	}
+
+	err = writeElement(w, &p.MerkleRootQuorums)
+	if err != nil {
+		return err
+	}
+	if p.Version < CbTxVersionCreditPool {
+		return nil
+	}
+
+	err = WriteVarInt(w, 0, p.BestCLHeightDiff)
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(p.BestCLSignature[:]); err != nil {
+		return err
+	}
+	return writeElement(w, p.CreditPoolBalance)
+}
+
+// SerializeSize returns the number of bytes it would take to serialize the
+// payload.
+func (p *CoinbasePayload) SerializeSize() int {
+	// Version 2 bytes + Height 4 bytes + MerkleRootMNList 32 bytes.
+	n := 2 + 4 + chainhash.HashSize
+	if p.Version >= CbTxVersionMerkleRootQuorums {
+		n += chainhash.HashSize
+	}
+	if p.Version >= CbTxVersionCreditPool {
+		// Serialized varint size for the best chainlock height
+		// difference + BestCLSignature 96 bytes + CreditPoolBalance 8
+		// bytes.
+		n += VarIntSerializeSize(p.BestCLHeightDiff) + QuorumSigSize + 8
+	}
+
+	return n
+}
+
+// CoinbasePayload decodes and returns the extra payload of a coinbase special
+// transaction.  An error is returned when the transaction is not a coinbase
+// special transaction or the payload is malformed.
+func (msg *MsgTx) CoinbasePayload() (*CoinbasePayload, error) {
+	if !msg.IsSpecial() || msg.Type != TxTypeCoinBase {
+		str := fmt.Sprintf("transaction type %v is not a coinbase",
+			msg.Type)
+		return nil, messageError("MsgTx.CoinbasePayload", str)
+	}
+
+	var payload CoinbasePayload
+	r := bytes.NewReader(msg.Payload)
+	if err := payload.Deserialize(r); err != nil {
+		return nil, err
+	}
+	if r.Len() != 0 {
+		str := fmt.Sprintf("%d trailing bytes after coinbase payload",
+			r.Len())
+		return nil, messageError("MsgTx.CoinbasePayload", str)
+	}
+
+	return &payload, nil
+}
@@ -22,6 +22,8 @@ func TestInvTypeStringer(t *testing.T) {
 		{InvTypeError, "ERROR"},
 		{InvTypeTx, "MSG_TX"},
 		{InvTypeBlock, "MSG_BLOCK"},
+		{InvTypeDSTx, "MSG_DSTX"},
+		{InvTypeCmpctBlock, "MSG_CMPCT_BLOCK"},
 		{0xffffffff, "Unknown InvType (4294967295)"},
 	}
 
@@ -57,6 +57,15 @@ const (
 	CmdCFilter      = "cfilter"
 	CmdCFHeaders    = "cfheaders"
 	CmdCFCheckpt    = "cfcheckpt"
+	CmdDSQueue      = "dsq"
+	CmdDSTx         = "dstx"
+	CmdSendDSQueue  = "senddsq"
+	CmdAddrV2       = "addrv2"
+	CmdSendAddrV2   = "sendaddrv2"
+	CmdSendCmpct    = "sendcmpct"
+	CmdCmpctBlock   = "cmpctblock"
+	CmdGetBlockTxn  = "getblocktxn"
+	CmdBlockTxn     = "blocktxn"
 )
 
 // MessageEncoding represents the wire message encoding format to be used.
@@ -180,6 +189,33 @@ func makeEmptyMessage(command string) (Message, error) {
 	case CmdCFCheckpt:
 		msg = &MsgCFCheckpt{}
 
+	case CmdDSQueue:
+		msg = &MsgDSQueue{}
+
+	case CmdDSTx:
+		msg = &MsgDSTx{}
+
+	case CmdSendDSQueue:
+		msg = &MsgSendDSQueue{}
+
+	case CmdAddrV2:
+		msg = &MsgAddrV2{}
+
+	case CmdSendAddrV2:
+		msg = &MsgSendAddrV2{}
+
+	case CmdSendCmpct:
+		msg = &MsgSendCmpct{}
+
+	case CmdCmpctBlock:
+		msg = &MsgCmpctBlock{}
+
+	case CmdGetBlockTxn:
+		msg = &MsgGetBlockTxn{}
+
+	case CmdBlockTxn:
+		msg = &MsgBlockTxn{}
+
 	default:
 		return nil, fmt.Errorf("unhandled command [%s]", command)
 	}
@@ -76,6 +76,17 @@ func TestMessage(t *testing.T) {
 		[]byte("payload"))
 	msgCFHeaders := NewMsgCFHeaders()
 	msgCFCheckpt := NewMsgCFCheckpt(GCSFilterRegular, &chainhash.Hash{}, 0)
+	msgDSQueue := NewMsgDSQueue(1, &OutPoint{}, &chainhash.Hash{}, 0, false)
+	msgDSQueue.Sig = []byte{0x01}
+	msgDSTx := NewMsgDSTx(NewMsgTx(1), &OutPoint{}, &chainhash.Hash{}, 0)
+	msgDSTx.Sig = []byte{0x01}
+	msgSendDSQueue := NewMsgSendDSQueue(true)
+	msgAddrV2 := NewMsgAddrV2()
+	msgSendAddrV2 := NewMsgSendAddrV2()
+	msgSendCmpct := NewMsgSendCmpct(false, CmpctBlockVersion)
+	msgCmpctBlock := NewMsgCmpctBlock(bh, 0)
+	msgGetBlockTxn := NewMsgGetBlockTxn(&chainhash.Hash{}, nil)
+	msgBlockTxn := NewMsgBlockTxn(&chainhash.Hash{})
 
 	tests := []struct {
 		in     Message    // Value to encode
@@ -111,6 +122,15 @@ func TestMessage(t *testing.T) {
 		{msgCFilter, msgCFilter, pver, MainNet, 65},
 		{msgCFHeaders, msgCFHeaders, pver, MainNet, 90},
 		{msgCFCheckpt, msgCFCheckpt, pver, MainNet, 58},
+		{msgDSQueue, msgDSQueue, pver, MainNet, 75},
+		{msgDSTx, msgDSTx, pver, MainNet, 80},
+		{msgSendDSQueue, msgSendDSQueue, pver, MainNet, 25},
+		{msgAddrV2, msgAddrV2, pver, MainNet, 25},
+		{msgSendAddrV2, msgSendAddrV2, pver, MainNet, 24},
+		{msgSendCmpct, msgSendCmpct, pver, MainNet, 33},
+		{msgCmpctBlock, msgCmpctBlock, pver, MainNet, 114},
+		{msgGetBlockTxn, msgGetBlockTxn, pver, MainNet, 57},
+		{msgBlockTxn, msgBlockTxn, pver, MainNet, 57},
 	}
 
 	t.Logf("Running %d tests", len(tests))
@@ -0,0 +1,108 @@
+// Copyright (c) 2013-2016 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/eager7/dashd/chaincfg/chainhash"
+)
+
+// MnHfTxPayloadVersion is the current version of the masternode hard fork
+// signal special transaction payload.
+const MnHfTxPayloadVersion = 1
+
+// MnHfSignal is the hard fork signal of a masternode quorum.  It commits to
+// the version bit of the deployment being signaled.
+type MnHfSignal struct {
+	VersionBit uint8
+	QuorumHash chainhash.Hash
+	Sig        [QuorumSigSize]byte
+}
+
+// MnHfTxPayload represents the extra payload of a masternode hard fork signal
+// special transaction (type 7) as defined by DIP0023.
+type MnHfTxPayload struct {
+	Version uint8
+	Signal  MnHfSignal
+}
+
+// Deserialize decodes a masternode hard fork signal payload from r into the
+// receiver.
+func (p *MnHfTxPayload) Deserialize(r io.Reader) error {
+	var err error
+	p.Version, err = binarySerializer.Uint8(r)
+	if err != nil {
+		return err
+	}
+
+	p.Signal.VersionBit, err = binarySerializer.Uint8(r)
+	if err != nil {
+		return err
+	}
+
+	err = readElement(r, &p.Signal.QuorumHash)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.ReadFull(r, p.Signal.Sig[:])
+	return err
+}
+
+// Serialize encodes the receiver to w.
+func (p *MnHfTxPayload) Serialize(w io.Writer) error {
+	err := binarySerializer.PutUint8(w, p.Version)
+	if err != nil {
+		return err
+	}
+
+	err = binarySerializer.PutUint8(w, p.Signal.VersionBit)
+	if err != nil {
+		return err
+	}
+
+	err = writeElement(w, &p.Signal.QuorumHash)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(p.Signal.Sig[:])
+	return err
+}
+
+// SerializeSize returns the number of bytes it would take to serialize the
+// payload.
+func (p *MnHfTxPayload) SerializeSize() int {
+	// Version 1 byte + VersionBit 1 byte + QuorumHash 32 bytes + Sig 96
+	// bytes.
+	return 1 + 1 + chainhash.HashSize + QuorumSigSize
+}
+
+// MnHfTxPayload decodes and returns the extra payload of a masternode hard
+// fork signal transaction.  An error is returned when the transaction is not
+// a hard fork signal or the payload is malformed.
+func (msg *MsgTx) MnHfTxPayload() (*MnHfTxPayload, error) {
+	if !msg.IsSpecial() || msg.Type != TxTypeMnHfSignal {
+		str := fmt.Sprintf("transaction type %v is not a masternode "+
+			"hard fork signal", msg.Type)
+		return nil, messageError("MsgTx.MnHfTxPayload", str)
+	}
+
+	var payload MnHfTxPayload
+	r := bytes.NewReader(msg.Payload)
+	if err := payload.Deserialize(r); err != nil {
+		return nil, err
+	}
+	if r.Len() != 0 {
+		str := fmt.Sprintf("%d trailing bytes after masternode hard "+
+			"fork signal payload", r.Len())
+		return nil, messageError("MsgTx.MnHfTxPayload", str)
+	}
+
+	return &payload, nil
+}
@@ -0,0 +1,113 @@
+// Copyright (c) 2013-2016 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/davecgh/go-spew/spew"
+)
+
+// TestAddrV2 tests the MsgAddrV2 API.
+func TestAddrV2(t *testing.T) {
+	pver := ProtocolVersion
+
+	// Ensure the command is expected value.
+	wantCmd := "addrv2"
+	msg := NewMsgAddrV2()
+	if cmd := msg.Command(); cmd != wantCmd {
+		t.Errorf("NewMsgAddrV2: wrong command - got %v want %v",
+			cmd, wantCmd)
+	}
+
+	// Ensure max payload is expected value.
+	// Num addresses (varInt) + max allowed addresses.
+	wantPayload := uint32(537009)
+	maxPayload := msg.MaxPayloadLength(pver)
+	if maxPayload != wantPayload {
+		t.Errorf("MaxPayloadLength: wrong max payload length for "+
+			"protocol version %d - got %v, want %v", pver,
+			maxPayload, wantPayload)
+	}
+
+	// Ensure NetAddresses are added properly.
+	na := NewNetAddressV2(NetTorV3, make([]byte, 32), 9999, SFNodeNetwork)
+	err := msg.AddAddress(na)
+	if err != nil {
+		t.Errorf("AddAddress: %v", err)
+	}
+	if msg.AddrList[0] != na {
+		t.Errorf("AddAddress: wrong address added - got %v, want %v",
+			spew.Sprint(msg.AddrList[0]), spew.Sprint(na))
+	}
+
+	// Ensure the address list is cleared properly.
+	msg.ClearAddresses()
+	if len(msg.AddrList) != 0 {
+		t.Errorf("ClearAddresses: address list is not empty - "+
+			"got %v [%v], want %v", len(msg.AddrList),
+			spew.Sprint(msg.AddrList[0]), 0)
+	}
+
+	// Ensure adding more than the max allowed addresses per message returns
+	// error.
+	for i := 0; i < MaxAddrPerMsg+1; i++ {
+		err = msg.AddAddress(na)
+	}
+	if err == nil {
+		t.Errorf("AddAddress: expected error on too many addresses " +
+			"not received")
+	}
+}
+
+// TestAddrV2Wire tests the MsgAddrV2 wire encode and decode, and ensures
+// addresses of unknown networks are skipped.
+func TestAddrV2Wire(t *testing.T) {
+	timestamp := time.Unix(0x495fab29, 0) // 2009-01-03 12:15:05 -0600 CST
+	na := NewNetAddressV2Timestamp(timestamp, SFNodeNetwork, NetIPv4,
+		[]byte{127, 0, 0, 1}, 8333)
+
+	msg := NewMsgAddrV2()
+	msg.AddAddresses(na, na)
+	var buf bytes.Buffer
+	err := msg.BtcEncode(&buf, ProtocolVersion, BaseEncoding)
+	if err != nil {
+		t.Fatalf("BtcEncode error %v", err)
+	}
+
+	// Append an address of an unknown network and bump the count.
+	encoded := append(buf.Bytes(), []byte{
+		0x29, 0xab, 0x5f, 0x49, // Timestamp
+		0x01,                   // Services
+		0x2a,                   // Unknown network
+		0x03, 0x01, 0x02, 0x03, // Address
+		0x20, 0x8d, // Port
+	}...)
+	encoded[0] = 3
+
+	var decoded MsgAddrV2
+	err = decoded.BtcDecode(bytes.NewReader(encoded), ProtocolVersion,
+		BaseEncoding)
+	if err != nil {
+		t.Fatalf("BtcDecode error %v", err)
+	}
+	want := []*NetAddressV2{na, na}
+	if !reflect.DeepEqual(decoded.AddrList, want) {
+		t.Errorf("BtcDecode\n got: %s want: %s",
+			spew.Sdump(decoded.AddrList), spew.Sdump(want))
+	}
+
+	// Ensure a message with too many addresses fails to decode.
+	var tooMany bytes.Buffer
+	WriteVarInt(&tooMany, ProtocolVersion, MaxAddrPerMsg+1)
+	err = decoded.BtcDecode(&tooMany, ProtocolVersion, BaseEncoding)
+	if _, ok := err.(*MessageError); !ok {
+		t.Errorf("BtcDecode: did not reject too many addresses - "+
+			"got %v", err)
+	}
+}
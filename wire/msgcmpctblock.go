@@ -0,0 +1,281 @@
+// Copyright (c) 2016 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/eager7/dashd/chaincfg/chainhash"
+)
+
+// maxCmpctBlockTxIndex is the highest transaction index which compact block
+// messages may refer to.  Like the reference implementation, indexes are
+// limited to 16 bits, which is plenty for the number of transactions that can
+// fit into a block.
+const maxCmpctBlockTxIndex = 0xffff
+
+// readTxIndex reads a differentially encoded transaction index from r, which
+// is the difference with the previous index minus one.  prev is the previous
+// index, or -1 for the first one.
+func readTxIndex(r io.Reader, pver uint32, prev int64, f string) (uint32, error) {
+	diff, err := ReadVarInt(r, pver)
+	if err != nil {
+		return 0, err
+	}
+
+	if diff > maxCmpctBlockTxIndex || prev+1+int64(diff) > maxCmpctBlockTxIndex {
+		str := fmt.Sprintf("transaction index is too high [prev %d, "+
+			"diff %d, max %d]", prev, diff, maxCmpctBlockTxIndex)
+		return 0, messageError(f, str)
+	}
+	return uint32(prev + 1 + int64(diff)), nil
+}
+
+// writeTxIndex writes a transaction index to w differentially with the
+// previous index, or -1 for the first one.  Indexes must be strictly
+// increasing.
+func writeTxIndex(w io.Writer, pver uint32, prev int64, index uint32, f string) error {
+	if int64(index) <= prev || index > maxCmpctBlockTxIndex {
+		str := fmt.Sprintf("transaction index %d is out of order or "+
+			"too high [prev %d, max %d]", index, prev,
+			maxCmpctBlockTxIndex)
+		return messageError(f, str)
+	}
+	return WriteVarInt(w, pver, uint64(int64(index)-prev-1))
+}
+
+// PrefilledTx defines a transaction which is sent in full along with the
+// short transaction IDs of a compact block.
+type PrefilledTx struct {
+	// Index is the index of the transaction within the block.
+	Index uint32
+
+	// Tx is the transaction.
+	Tx *MsgTx
+}
+
+// MsgCmpctBlock implements the Message interface and represents a bitcoin
+// cmpctblock message.  It is used to relay a block to peers which likely have
+// most of its transactions already.  Transactions are identified by their
+// short transaction IDs (see ShortTxID), except for the prefilled
+// transactions which are sent in full.  Transactions which cannot be found
+// from their short IDs are requested with a getblocktxn message
+// (MsgGetBlockTxn).
+//
+// This message was not added until protocol version ShortIDsBlocksVersion.
+type MsgCmpctBlock struct {
+	Header       BlockHeader
+	Nonce        uint64
+	ShortIDs     []uint64
+	PrefilledTxs []*PrefilledTx
+}
+
+// AddShortID adds a short transaction ID to the message.
+func (msg *MsgCmpctBlock) AddShortID(id uint64) {
+	msg.ShortIDs = append(msg.ShortIDs, id)
+}
+
+// AddPrefilledTx adds a prefilled transaction to the message.  Prefilled
+// transactions must be added in increasing index order.
+func (msg *MsgCmpctBlock) AddPrefilledTx(index uint32, tx *MsgTx) {
+	msg.PrefilledTxs = append(msg.PrefilledTxs, &PrefilledTx{
+		Index: index,
+		Tx:    tx,
+	})
+}
+
+// TxCount returns the number of transactions in the block described by the
+// message.
+func (msg *MsgCmpctBlock) TxCount() int {
+	return len(msg.ShortIDs) + len(msg.PrefilledTxs)
+}
+
+// ShortTxIDKeys returns the SipHash keys used to compute the short transaction
+// IDs of the message.
+func (msg *MsgCmpctBlock) ShortTxIDKeys() (uint64, uint64) {
+	return ShortTxIDKeys(&msg.Header, msg.Nonce)
+}
+
+// BtcDecode decodes r using the bitcoin protocol encoding into the receiver.
+// This is part of the Message interface implementation.
+func (msg *MsgCmpctBlock) BtcDecode(r io.Reader, pver uint32, enc MessageEncoding) error {
+	err := readBlockHeader(r, pver, &msg.Header)
+	if err != nil {
+		return err
+	}
+
+	err = readElement(r, &msg.Nonce)
+	if err != nil {
+		return err
+	}
+
+	count, err := ReadVarInt(r, pver)
+	if err != nil {
+		return err
+	}
+
+	// Prevent more short IDs than transactions could possibly fit into a
+	// block.
+	if count > maxTxPerBlock {
+		str := fmt.Sprintf("too many short IDs for message "+
+			"[count %d, max %d]", count, maxTxPerBlock)
+		return messageError("MsgCmpctBlock.BtcDecode", str)
+	}
+
+	msg.ShortIDs = make([]uint64, 0, count)
+	var buf [8]byte
+	for i := uint64(0); i < count; i++ {
+		_, err := io.ReadFull(r, buf[:ShortTxIDLen])
+		if err != nil {
+			return err
+		}
+		msg.ShortIDs = append(msg.ShortIDs, binary.LittleEndian.Uint64(buf[:]))
+	}
+
+	count, err = ReadVarInt(r, pver)
+	if err != nil {
+		return err
+	}
+
+	// Prevent more prefilled transactions than could possibly fit into a
+	// block.
+	if count > maxTxPerBlock {
+		str := fmt.Sprintf("too many prefilled transactions for "+
+			"message [count %d, max %d]", count, maxTxPerBlock)
+		return messageError("MsgCmpctBlock.BtcDecode", str)
+	}
+
+	msg.PrefilledTxs = make([]*PrefilledTx, 0, count)
+	prev := int64(-1)
+	for i := uint64(0); i < count; i++ {
+		index, err := readTxIndex(r, pver, prev,
+			"MsgCmpctBlock.BtcDecode")
+		if err != nil {
+			return err
+		}
+		prev = int64(index)
+
+		tx := MsgTx{}
+		err = tx.BtcDecode(r, pver, enc)
+		if err != nil {
+			return err
+		}
+		msg.AddPrefilledTx(index, &tx)
+	}
+
+	// The short IDs and prefilled transactions together make up the
+	// block, so the prefilled transactions must lie within it.
+	if prev >= int64(msg.TxCount()) {
+		str := fmt.Sprintf("prefilled transaction index %d is out "+
+			"of range [txns %d]", prev, msg.TxCount())
+		return messageError("MsgCmpctBlock.BtcDecode", str)
+	}
+
+	return nil
+}
+
+// BtcEncode encodes the receiver to w using the bitcoin protocol encoding.
+// This is part of the Message interface implementation.
+func (msg *MsgCmpctBlock) BtcEncode(w io.Writer, pver uint32, enc MessageEncoding) error {
+	err := writeBlockHeader(w, pver, &msg.Header)
+	if err != nil {
+		return err
+	}
+
+	err = writeElement(w, msg.Nonce)
+	if err != nil {
+		return err
+	}
+
+	err = WriteVarInt(w, pver, uint64(len(msg.ShortIDs)))
+	if err != nil {
+		return err
+	}
+
+	var buf [8]byte
+	for _, id := range msg.ShortIDs {
+		binary.LittleEndian.PutUint64(buf[:], id)
+		_, err := w.Write(buf[:ShortTxIDLen])
+		if err != nil {
+			return err
+		}
+	}
+
+	err = WriteVarInt(w, pver, uint64(len(msg.PrefilledTxs)))
+	if err != nil {
+		return err
+	}
+
+	prev := int64(-1)
+	for _, ptx := range msg.PrefilledTxs {
+		err := writeTxIndex(w, pver, prev, ptx.Index,
+			"MsgCmpctBlock.BtcEncode")
+		if err != nil {
+			return err
+		}
+		prev = int64(ptx.Index)
+
+		err = ptx.Tx.BtcEncode(w, pver, enc)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// BlockHash computes the block identifier hash for the block described by the
+// message.
+func (msg *MsgCmpctBlock) BlockHash() chainhash.Hash {
+	return msg.Header.BlockHash()
+}
+
+// Command returns the protocol command string for the message.  This is part
+// of the Message interface implementation.
+func (msg *MsgCmpctBlock) Command() string {
+	return CmdCmpctBlock
+}
+
+// MaxPayloadLength returns the maximum length the payload can be for the
+// receiver.  This is part of the Message interface implementation.
+func (msg *MsgCmpctBlock) MaxPayloadLength(pver uint32) uint32 {
+	// A compact block is never larger than the block it describes, other
+	// than for the nonce and the short IDs replacing smaller counts.
+	return MaxBlockPayload
+}
+
+// NewMsgCmpctBlock returns a new bitcoin cmpctblock message that conforms to
+// the Message interface using the passed header and nonce and with no
+// transactions.  See MsgCmpctBlock for details.
+func NewMsgCmpctBlock(bh *BlockHeader, nonce uint64) *MsgCmpctBlock {
+	return &MsgCmpctBlock{
+		Header:       *bh,
+		Nonce:        nonce,
+		ShortIDs:     make([]uint64, 0),
+		PrefilledTxs: make([]*PrefilledTx, 0, 1),
+	}
+}
+
+// NewMsgCmpctBlockFromBlock returns a new bitcoin cmpctblock message for the
+// passed block using the passed nonce.  The coinbase transaction, which
+// peers can never have, is prefilled while all other transactions are
+// identified by their short transaction IDs.
+func NewMsgCmpctBlockFromBlock(block *MsgBlock, nonce uint64) *MsgCmpctBlock {
+	msg := NewMsgCmpctBlock(&block.Header, nonce)
+	if len(block.Transactions) == 0 {
+		return msg
+	}
+
+	msg.AddPrefilledTx(0, block.Transactions[0])
+	k0, k1 := msg.ShortTxIDKeys()
+	msg.ShortIDs = make([]uint64, 0, len(block.Transactions)-1)
+	for _, tx := range block.Transactions[1:] {
+		txHash := tx.TxHash()
+		msg.AddShortID(ShortTxID(k0, k1, &txHash))
+	}
+	return msg
+}
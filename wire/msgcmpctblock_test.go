@@ -0,0 +1,155 @@
+// Copyright (c) 2016 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/davecgh/go-spew/spew"
+)
+
+// TestCmpctBlock tests the MsgCmpctBlock API.
+func TestCmpctBlock(t *testing.T) {
+	pver := ProtocolVersion
+
+	msg := NewMsgCmpctBlockFromBlock(&blockOne, 42)
+	if !reflect.DeepEqual(&msg.Header, &blockOne.Header) {
+		t.Errorf("NewMsgCmpctBlockFromBlock: wrong header - got %v, "+
+			"want %v", spew.Sdump(&msg.Header),
+			spew.Sdump(&blockOne.Header))
+	}
+	if msg.Nonce != 42 {
+		t.Errorf("NewMsgCmpctBlockFromBlock: wrong nonce - got %v, "+
+			"want %v", msg.Nonce, 42)
+	}
+	if msg.BlockHash() != blockOne.BlockHash() {
+		t.Errorf("BlockHash: wrong hash - got %v, want %v",
+			msg.BlockHash(), blockOne.BlockHash())
+	}
+
+	// Ensure the command is expected value.
+	wantCmd := "cmpctblock"
+	if cmd := msg.Command(); cmd != wantCmd {
+		t.Errorf("NewMsgCmpctBlockFromBlock: wrong command - got %v "+
+			"want %v", cmd, wantCmd)
+	}
+
+	// Ensure max payload is expected value for latest protocol version.
+	wantPayload := uint32(4000000)
+	maxPayload := msg.MaxPayloadLength(pver)
+	if maxPayload != wantPayload {
+		t.Errorf("MaxPayloadLength: wrong max payload length for "+
+			"protocol version %d - got %v, want %v", pver,
+			maxPayload, wantPayload)
+	}
+
+	// The coinbase is prefilled and the other transactions are
+	// identified by their short IDs.
+	block := blockOne
+	block.Transactions = []*MsgTx{blockOne.Transactions[0], multiTx}
+	msg = NewMsgCmpctBlockFromBlock(&block, 42)
+	if msg.TxCount() != 2 || len(msg.PrefilledTxs) != 1 ||
+		msg.PrefilledTxs[0].Index != 0 ||
+		msg.PrefilledTxs[0].Tx != blockOne.Transactions[0] {
+		t.Fatalf("NewMsgCmpctBlockFromBlock: wrong prefilled "+
+			"transactions %v", spew.Sdump(msg.PrefilledTxs))
+	}
+	k0, k1 := msg.ShortTxIDKeys()
+	txHash := multiTx.TxHash()
+	wantIDs := []uint64{ShortTxID(k0, k1, &txHash)}
+	if !reflect.DeepEqual(msg.ShortIDs, wantIDs) {
+		t.Errorf("NewMsgCmpctBlockFromBlock: wrong short IDs - got "+
+			"%x, want %x", msg.ShortIDs, wantIDs)
+	}
+}
+
+// TestCmpctBlockWire tests the MsgCmpctBlock wire encode and decode.
+func TestCmpctBlockWire(t *testing.T) {
+	pver := ProtocolVersion
+
+	// Compact block with two short IDs and the coinbase prefilled as the
+	// second transaction.
+	msg := NewMsgCmpctBlock(&blockOne.Header, 0x0102030405060708)
+	msg.AddShortID(0x665544332211)
+	msg.AddPrefilledTx(1, blockOne.Transactions[0])
+	msg.AddShortID(0xffeeddccbbaa)
+
+	var want []byte
+	want = append(want, blockOneBytes[:80]...)
+	want = append(want, 0x08, 0x07, 0x06, 0x05, 0x04, 0x03, 0x02, 0x01)
+	want = append(want, 0x02,
+		0x11, 0x22, 0x33, 0x44, 0x55, 0x66,
+		0xaa, 0xbb, 0xcc, 0xdd, 0xee, 0xff)
+	want = append(want, 0x01, 0x01)
+	want = append(want, blockOneBytes[81:]...)
+
+	var buf bytes.Buffer
+	if err := msg.BtcEncode(&buf, pver, BaseEncoding); err != nil {
+		t.Fatalf("BtcEncode error %v", err)
+	}
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Fatalf("BtcEncode\n got: %s want: %s",
+			spew.Sdump(buf.Bytes()), spew.Sdump(want))
+	}
+
+	var readMsg MsgCmpctBlock
+	err := readMsg.BtcDecode(bytes.NewReader(want), pver, BaseEncoding)
+	if err != nil {
+		t.Fatalf("BtcDecode error %v", err)
+	}
+	if !reflect.DeepEqual(&readMsg, msg) {
+		t.Errorf("BtcDecode\n got: %s want: %s", spew.Sdump(&readMsg),
+			spew.Sdump(msg))
+	}
+}
+
+// TestCmpctBlockWireErrors performs negative tests against wire encode and
+// decode of MsgCmpctBlock to confirm error paths work correctly.
+func TestCmpctBlockWireErrors(t *testing.T) {
+	pver := ProtocolVersion
+
+	// Prefilled transactions out of order fail to encode.
+	msg := NewMsgCmpctBlock(&blockOne.Header, 0)
+	msg.AddPrefilledTx(1, blockOne.Transactions[0])
+	msg.AddPrefilledTx(1, blockOne.Transactions[0])
+	var buf bytes.Buffer
+	err := msg.BtcEncode(&buf, pver, BaseEncoding)
+	if _, ok := err.(*MessageError); !ok {
+		t.Errorf("BtcEncode: wrong error - got %T (%v), want "+
+			"*MessageError", err, err)
+	}
+
+	// withHeader returns a new buffer with the header and a zero nonce
+	// followed by the passed bytes.
+	withHeader := func(b ...byte) []byte {
+		buf := append([]byte{}, blockOneBytes[:80]...)
+		buf = append(buf, make([]byte, 8)...)
+		return append(buf, b...)
+	}
+	tests := []struct {
+		name string
+		buf  []byte
+	}{
+		{"too many short IDs", withHeader(0xfe, 0xff, 0xff, 0xff, 0xff)},
+		{"too many prefilled transactions", withHeader(0x00, 0xfe, 0xff,
+			0xff, 0xff, 0xff)},
+		{"index too high", withHeader(0x00, 0x01, 0xfe, 0x00, 0x00, 0x01,
+			0x00)},
+		{"index out of range", withHeader(append([]byte{0x00, 0x01, 0x01},
+			blockOneBytes[81:]...)...)},
+	}
+
+	for _, test := range tests {
+		var msg MsgCmpctBlock
+		err := msg.BtcDecode(bytes.NewReader(test.buf), pver,
+			BaseEncoding)
+		if _, ok := err.(*MessageError); !ok {
+			t.Errorf("BtcDecode %s: wrong error - got %T (%v), "+
+				"want *MessageError", test.name, err, err)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+// Copyright (c) 2013-2016 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/eager7/dashd/chaincfg/chainhash"
+)
+
+// MaxCoinJoinSigSize is the maximum number of bytes a masternode signature of
+// a CoinJoin queue or broadcast transaction can be.
+const MaxCoinJoinSigSize = 96
+
+// maxDSQueuePayload is the maximum number of bytes a dsq message can be.
+// Denomination 4 bytes + MasternodeOutPoint 36 bytes + ProTxHash 32 bytes +
+// Time 8 bytes + Ready 1 byte + Sig varint 1 byte + Sig.
+const maxDSQueuePayload = 4 + 36 + chainhash.HashSize + 8 + 1 + 1 +
+	MaxCoinJoinSigSize
+
+// MsgDSQueue implements the Message interface and represents a Dash dsq
+// message.  It is used by masternodes to announce that they are accepting
+// participants for a CoinJoin mixing session of a denomination, or that a
+// session is ready to start.
+//
+// The ProTxHash field is only encoded for protocol versions starting with
+// CoinJoinProTxHashVersion.
+type MsgDSQueue struct {
+	Denomination       int32
+	MasternodeOutPoint OutPoint
+	ProTxHash          chainhash.Hash
+	Time               int64
+	Ready              bool
+	Sig                []byte
+}
+
+// BtcDecode decodes r using the bitcoin protocol encoding into the receiver.
+// This is part of the Message interface implementation.
+func (msg *MsgDSQueue) BtcDecode(r io.Reader, pver uint32, enc MessageEncoding) error {
+	err := readElement(r, &msg.Denomination)
+	if err != nil {
+		return err
+	}
+
+	err = readOutPoint(r, pver, 0, &msg.MasternodeOutPoint)
+	if err != nil {
+		return err
+	}
+
+	if pver >= CoinJoinProTxHashVersion {
+		err = readElement(r, &msg.ProTxHash)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = readElements(r, &msg.Time, &msg.Ready)
+	if err != nil {
+		return err
+	}
+
+	msg.Sig, err = ReadVarBytes(r, pver, MaxCoinJoinSigSize,
+		"dsq signature")
+	return err
+}
+
+// encode encodes the receiver to w, optionally omitting the signature.
+func (msg *MsgDSQueue) encode(w io.Writer, pver uint32, withSig bool) error {
+	err := writeElement(w, msg.Denomination)
+	if err != nil {
+		return err
+	}
+
+	err = writeOutPoint(w, pver, 0, &msg.MasternodeOutPoint)
+	if err != nil {
+		return err
+	}
+
+	if pver >= CoinJoinProTxHashVersion {
+		err = writeElement(w, &msg.ProTxHash)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = writeElements(w, msg.Time, msg.Ready)
+	if err != nil {
+		return err
+	}
+
+	if !withSig {
+		return nil
+	}
+	return WriteVarBytes(w, pver, msg.Sig)
+}
+
+// BtcEncode encodes the receiver to w using the bitcoin protocol encoding.
+// This is part of the Message interface implementation.
+func (msg *MsgDSQueue) BtcEncode(w io.Writer, pver uint32, enc MessageEncoding) error {
+	if len(msg.Sig) > MaxCoinJoinSigSize {
+		str := fmt.Sprintf("dsq signature is too long [len %v, max %v]",
+			len(msg.Sig), MaxCoinJoinSigSize)
+		return messageError("MsgDSQueue.BtcEncode", str)
+	}
+
+	return msg.encode(w, pver, true)
+}
+
+// SignatureHash returns the hash the masternode signs to authenticate the
+// queue.  It commits to every field except the signature itself and always
+// includes the ProTxHash of the masternode.
+func (msg *MsgDSQueue) SignatureHash() chainhash.Hash {
+	var buf bytes.Buffer
+	_ = msg.encode(&buf, CoinJoinProTxHashVersion, false)
+	return chainhash.DoubleHashH(buf.Bytes())
+}
+
+// Command returns the protocol command string for the message.  This is part
+// of the Message interface implementation.
+func (msg *MsgDSQueue) Command() string {
+	return CmdDSQueue
+}
+
+// MaxPayloadLength returns the maximum length the payload can be for the
+// receiver.  This is part of the Message interface implementation.
+func (msg *MsgDSQueue) MaxPayloadLength(pver uint32) uint32 {
+	return maxDSQueuePayload
+}
+
+// NewMsgDSQueue returns a new Dash dsq message that conforms to the Message
+// interface using the passed parameters and defaults for the remaining fields.
+func NewMsgDSQueue(denom int32, outpoint *OutPoint, proTxHash *chainhash.Hash,
+	time int64, ready bool) *MsgDSQueue {
+
+	return &MsgDSQueue{
+		Denomination:       denom,
+		MasternodeOutPoint: *outpoint,
+		ProTxHash:          *proTxHash,
+		Time:               time,
+		Ready:              ready,
+	}
+}
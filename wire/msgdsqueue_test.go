@@ -0,0 +1,227 @@
+// Copyright (c) 2013-2016 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/davecgh/go-spew/spew"
+	"github.com/eager7/dashd/chaincfg/chainhash"
+)
+
+// TestDSQueue tests the MsgDSQueue API.
+func TestDSQueue(t *testing.T) {
+	pver := ProtocolVersion
+
+	outpoint := OutPoint{Hash: chainhash.Hash{0x01}, Index: 1}
+	proTxHash := chainhash.Hash{0x02}
+	msg := NewMsgDSQueue(4, &outpoint, &proTxHash, 0x5e0be100, false)
+	if msg.Denomination != 4 || msg.MasternodeOutPoint != outpoint ||
+		msg.ProTxHash != proTxHash {
+		t.Errorf("NewMsgDSQueue: wrong fields - got %v", spew.Sdump(msg))
+	}
+
+	// Ensure the command is expected value.
+	wantCmd := "dsq"
+	if cmd := msg.Command(); cmd != wantCmd {
+		t.Errorf("NewMsgDSQueue: wrong command - got %v want %v",
+			cmd, wantCmd)
+	}
+
+	// Ensure max payload is expected value for latest protocol version.
+	wantPayload := uint32(178)
+	maxPayload := msg.MaxPayloadLength(pver)
+	if maxPayload != wantPayload {
+		t.Errorf("MaxPayloadLength: wrong max payload length for "+
+			"protocol version %d - got %v, want %v", pver,
+			maxPayload, wantPayload)
+	}
+
+	// The signature hash must commit to the ProTxHash, but not to the
+	// signature.
+	hash := msg.SignatureHash()
+	msg.Sig = []byte{0x01, 0x02, 0x03}
+	if msg.SignatureHash() != hash {
+		t.Errorf("SignatureHash: hash commits to the signature")
+	}
+	msg.ProTxHash = chainhash.Hash{}
+	if msg.SignatureHash() == hash {
+		t.Errorf("SignatureHash: hash does not commit to ProTxHash")
+	}
+}
+
+// TestDSQueueWire tests the MsgDSQueue wire encode and decode for various
+// protocol versions.
+func TestDSQueueWire(t *testing.T) {
+	msgDSQ := &MsgDSQueue{
+		Denomination: 2,
+		MasternodeOutPoint: OutPoint{
+			Hash:  chainhash.Hash{0xaa},
+			Index: 1,
+		},
+		ProTxHash: chainhash.Hash{0xbb},
+		Time:      0x5e0be100,
+		Ready:     true,
+		Sig:       []byte{0x01, 0x02, 0x03},
+	}
+	msgDSQEncoded := []byte{
+		0x02, 0x00, 0x00, 0x00, // Denomination
+		0xaa, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // Outpoint hash
+		0x01, 0x00, 0x00, 0x00, // Outpoint index
+		0xbb, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // ProTxHash
+		0x00, 0xe1, 0x0b, 0x5e, 0x00, 0x00, 0x00, 0x00, // Time
+		0x01,                   // Ready
+		0x03, 0x01, 0x02, 0x03, // Sig
+	}
+
+	// The ProTxHash is not encoded before CoinJoinProTxHashVersion.
+	msgDSQNoProTx := *msgDSQ
+	msgDSQNoProTx.ProTxHash = chainhash.Hash{}
+	msgDSQNoProTxEncoded := make([]byte, 0, len(msgDSQEncoded))
+	msgDSQNoProTxEncoded = append(msgDSQNoProTxEncoded, msgDSQEncoded[:40]...)
+	msgDSQNoProTxEncoded = append(msgDSQNoProTxEncoded, msgDSQEncoded[72:]...)
+
+	tests := []struct {
+		in   *MsgDSQueue // Message to encode
+		out  *MsgDSQueue // Expected decoded message
+		buf  []byte      // Wire encoding
+		pver uint32      // Protocol version for wire encoding
+	}{
+		// Protocol version CoinJoinProTxHashVersion.
+		{
+			msgDSQ,
+			msgDSQ,
+			msgDSQEncoded,
+			CoinJoinProTxHashVersion,
+		},
+
+		// Protocol version CoinJoinProTxHashVersion - 1.
+		{
+			msgDSQ,
+			&msgDSQNoProTx,
+			msgDSQNoProTxEncoded,
+			CoinJoinProTxHashVersion - 1,
+		},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		// Encode the message to wire format.
+		var buf bytes.Buffer
+		err := test.in.BtcEncode(&buf, test.pver, BaseEncoding)
+		if err != nil {
+			t.Errorf("BtcEncode #%d error %v", i, err)
+			continue
+		}
+		if !bytes.Equal(buf.Bytes(), test.buf) {
+			t.Errorf("BtcEncode #%d\n got: %s want: %s", i,
+				spew.Sdump(buf.Bytes()), spew.Sdump(test.buf))
+			continue
+		}
+
+		// Decode the message from wire format.
+		var msg MsgDSQueue
+		rbuf := bytes.NewReader(test.buf)
+		err = msg.BtcDecode(rbuf, test.pver, BaseEncoding)
+		if err != nil {
+			t.Errorf("BtcDecode #%d error %v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(&msg, test.out) {
+			t.Errorf("BtcDecode #%d\n got: %s want: %s", i,
+				spew.Sdump(msg), spew.Sdump(test.out))
+			continue
+		}
+	}
+}
+
+// TestDSQueueWireErrors performs negative tests against wire encode and
+// decode of MsgDSQueue to confirm error paths work correctly.
+func TestDSQueueWireErrors(t *testing.T) {
+	pver := CoinJoinProTxHashVersion
+	wireErr := &MessageError{}
+
+	baseDSQ := &MsgDSQueue{Sig: []byte{0x01}}
+	baseDSQEncoded := make([]byte, 83)
+	baseDSQEncoded[81] = 0x01
+	baseDSQEncoded[82] = 0x01
+
+	// A signature that exceeds the maximum allowed size.
+	bigSigDSQ := &MsgDSQueue{Sig: make([]byte, MaxCoinJoinSigSize+1)}
+	bigSigDSQEncoded := make([]byte, 82)
+	bigSigDSQEncoded[81] = MaxCoinJoinSigSize + 1
+
+	tests := []struct {
+		in       *MsgDSQueue // Value to encode
+		buf      []byte      // Wire encoding
+		max      int         // Max size of fixed buffer to induce errors
+		writeErr error       // Expected write error
+		readErr  error       // Expected read error
+	}{
+		// Force error in denomination.
+		{baseDSQ, baseDSQEncoded, 0, io.ErrShortWrite, io.EOF},
+		// Force error in masternode outpoint.
+		{baseDSQ, baseDSQEncoded, 4, io.ErrShortWrite, io.EOF},
+		// Force error in ProTxHash.
+		{baseDSQ, baseDSQEncoded, 40, io.ErrShortWrite, io.EOF},
+		// Force error in time.
+		{baseDSQ, baseDSQEncoded, 72, io.ErrShortWrite, io.EOF},
+		// Force error in signature.
+		{baseDSQ, baseDSQEncoded, 81, io.ErrShortWrite, io.EOF},
+		// Force error with signature that is too long.
+		{bigSigDSQ, bigSigDSQEncoded, 82, wireErr, wireErr},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		// Encode to wire format.
+		w := newFixedWriter(test.max)
+		err := test.in.BtcEncode(w, pver, BaseEncoding)
+		if reflect.TypeOf(err) != reflect.TypeOf(test.writeErr) {
+			t.Errorf("BtcEncode #%d wrong error got: %v, want: %v",
+				i, err, test.writeErr)
+			continue
+		}
+
+		// For errors which are not of type MessageError, check them for
+		// equality.
+		if _, ok := err.(*MessageError); !ok {
+			if err != test.writeErr {
+				t.Errorf("BtcEncode #%d wrong error got: %v, "+
+					"want: %v", i, err, test.writeErr)
+				continue
+			}
+		}
+
+		// Decode from wire format.
+		var msg MsgDSQueue
+		r := newFixedReader(test.max, test.buf)
+		err = msg.BtcDecode(r, pver, BaseEncoding)
+		if reflect.TypeOf(err) != reflect.TypeOf(test.readErr) {
+			t.Errorf("BtcDecode #%d wrong error got: %v, want: %v",
+				i, err, test.readErr)
+			continue
+		}
+
+		// For errors which are not of type MessageError, check them for
+		// equality.
+		if _, ok := err.(*MessageError); !ok {
+			if err != test.readErr {
+				t.Errorf("BtcDecode #%d wrong error got: %v, "+
+					"want: %v", i, err, test.readErr)
+				continue
+			}
+		}
+	}
+}
@@ -0,0 +1,131 @@
+// Copyright (c) 2013-2016 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/eager7/dashd/chaincfg/chainhash"
+)
+
+// MsgDSTx implements the Message interface and represents a Dash dstx
+// message.  It is used to relay the final transaction of a CoinJoin mixing
+// session along with the signature of the masternode that coordinated it.
+//
+// The ProTxHash field is only encoded for protocol versions starting with
+// CoinJoinProTxHashVersion.
+type MsgDSTx struct {
+	Tx                 MsgTx
+	MasternodeOutPoint OutPoint
+	ProTxHash          chainhash.Hash
+	Sig                []byte
+	SigTime            int64
+}
+
+// BtcDecode decodes r using the bitcoin protocol encoding into the receiver.
+// This is part of the Message interface implementation.
+func (msg *MsgDSTx) BtcDecode(r io.Reader, pver uint32, enc MessageEncoding) error {
+	err := msg.Tx.BtcDecode(r, pver, BaseEncoding)
+	if err != nil {
+		return err
+	}
+
+	err = readOutPoint(r, pver, 0, &msg.MasternodeOutPoint)
+	if err != nil {
+		return err
+	}
+
+	if pver >= CoinJoinProTxHashVersion {
+		err = readElement(r, &msg.ProTxHash)
+		if err != nil {
+			return err
+		}
+	}
+
+	msg.Sig, err = ReadVarBytes(r, pver, MaxCoinJoinSigSize,
+		"dstx signature")
+	if err != nil {
+		return err
+	}
+
+	return readElement(r, &msg.SigTime)
+}
+
+// encode encodes the receiver to w, optionally omitting the signature.
+func (msg *MsgDSTx) encode(w io.Writer, pver uint32, withSig bool) error {
+	err := msg.Tx.BtcEncode(w, pver, BaseEncoding)
+	if err != nil {
+		return err
+	}
+
+	err = writeOutPoint(w, pver, 0, &msg.MasternodeOutPoint)
+	if err != nil {
+		return err
+	}
+
+	if pver >= CoinJoinProTxHashVersion {
+		err = writeElement(w, &msg.ProTxHash)
+		if err != nil {
+			return err
+		}
+	}
+
+	if withSig {
+		err = WriteVarBytes(w, pver, msg.Sig)
+		if err != nil {
+			return err
+		}
+	}
+
+	return writeElement(w, msg.SigTime)
+}
+
+// BtcEncode encodes the receiver to w using the bitcoin protocol encoding.
+// This is part of the Message interface implementation.
+func (msg *MsgDSTx) BtcEncode(w io.Writer, pver uint32, enc MessageEncoding) error {
+	if len(msg.Sig) > MaxCoinJoinSigSize {
+		str := fmt.Sprintf("dstx signature is too long [len %v, max %v]",
+			len(msg.Sig), MaxCoinJoinSigSize)
+		return messageError("MsgDSTx.BtcEncode", str)
+	}
+
+	return msg.encode(w, pver, true)
+}
+
+// SignatureHash returns the hash the masternode signs to authenticate the
+// broadcast transaction.  It commits to every field except the signature
+// itself and always includes the ProTxHash of the masternode.
+func (msg *MsgDSTx) SignatureHash() chainhash.Hash {
+	var buf bytes.Buffer
+	_ = msg.encode(&buf, CoinJoinProTxHashVersion, false)
+	return chainhash.DoubleHashH(buf.Bytes())
+}
+
+// Command returns the protocol command string for the message.  This is part
+// of the Message interface implementation.
+func (msg *MsgDSTx) Command() string {
+	return CmdDSTx
+}
+
+// MaxPayloadLength returns the maximum length the payload can be for the
+// receiver.  This is part of the Message interface implementation.
+func (msg *MsgDSTx) MaxPayloadLength(pver uint32) uint32 {
+	return MaxBlockPayload
+}
+
+// NewMsgDSTx returns a new Dash dstx message that conforms to the Message
+// interface using the passed parameters and defaults for the remaining fields.
+func NewMsgDSTx(tx *MsgTx, outpoint *OutPoint, proTxHash *chainhash.Hash,
+	sigTime int64) *MsgDSTx {
+
+	return &MsgDSTx{
+		Tx:                 *tx,
+		MasternodeOutPoint: *outpoint,
+		ProTxHash:          *proTxHash,
+		SigTime:            sigTime,
+	}
+}
@@ -0,0 +1,236 @@
+// Copyright (c) 2013-2016 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/davecgh/go-spew/spew"
+	"github.com/eager7/dashd/chaincfg/chainhash"
+)
+
+// TestDSTx tests the MsgDSTx API.
+func TestDSTx(t *testing.T) {
+	pver := ProtocolVersion
+
+	tx := NewMsgTx(1)
+	outpoint := OutPoint{Hash: chainhash.Hash{0x01}, Index: 1}
+	proTxHash := chainhash.Hash{0x02}
+	msg := NewMsgDSTx(tx, &outpoint, &proTxHash, 0x5e0be100)
+	if msg.MasternodeOutPoint != outpoint || msg.ProTxHash != proTxHash ||
+		msg.SigTime != 0x5e0be100 {
+		t.Errorf("NewMsgDSTx: wrong fields - got %v", spew.Sdump(msg))
+	}
+
+	// Ensure the command is expected value.
+	wantCmd := "dstx"
+	if cmd := msg.Command(); cmd != wantCmd {
+		t.Errorf("NewMsgDSTx: wrong command - got %v want %v",
+			cmd, wantCmd)
+	}
+
+	// Ensure max payload is expected value for latest protocol version.
+	wantPayload := uint32(MaxBlockPayload)
+	maxPayload := msg.MaxPayloadLength(pver)
+	if maxPayload != wantPayload {
+		t.Errorf("MaxPayloadLength: wrong max payload length for "+
+			"protocol version %d - got %v, want %v", pver,
+			maxPayload, wantPayload)
+	}
+
+	// The signature hash must commit to the signature time, but not to
+	// the signature.
+	hash := msg.SignatureHash()
+	msg.Sig = []byte{0x01, 0x02, 0x03}
+	if msg.SignatureHash() != hash {
+		t.Errorf("SignatureHash: hash commits to the signature")
+	}
+	msg.SigTime++
+	if msg.SignatureHash() == hash {
+		t.Errorf("SignatureHash: hash does not commit to SigTime")
+	}
+}
+
+// TestDSTxWire tests the MsgDSTx wire encode and decode for various protocol
+// versions.
+func TestDSTxWire(t *testing.T) {
+	msgDSTx := &MsgDSTx{
+		Tx: *NewMsgTx(1),
+		MasternodeOutPoint: OutPoint{
+			Hash:  chainhash.Hash{0xaa},
+			Index: 1,
+		},
+		ProTxHash: chainhash.Hash{0xbb},
+		Sig:       []byte{0x01, 0x02, 0x03},
+		SigTime:   0x5e0be100,
+	}
+	msgDSTxEncoded := []byte{
+		0x01, 0x00, 0x00, 0x00, // Tx version
+		0x00,                   // Tx varint for number of inputs
+		0x00,                   // Tx varint for number of outputs
+		0x00, 0x00, 0x00, 0x00, // Tx lock time
+		0xaa, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // Outpoint hash
+		0x01, 0x00, 0x00, 0x00, // Outpoint index
+		0xbb, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // ProTxHash
+		0x03, 0x01, 0x02, 0x03, // Sig
+		0x00, 0xe1, 0x0b, 0x5e, 0x00, 0x00, 0x00, 0x00, // SigTime
+	}
+
+	// The ProTxHash is not encoded before CoinJoinProTxHashVersion.
+	msgDSTxNoProTx := *msgDSTx
+	msgDSTxNoProTx.ProTxHash = chainhash.Hash{}
+	msgDSTxNoProTxEncoded := make([]byte, 0, len(msgDSTxEncoded))
+	msgDSTxNoProTxEncoded = append(msgDSTxNoProTxEncoded,
+		msgDSTxEncoded[:46]...)
+	msgDSTxNoProTxEncoded = append(msgDSTxNoProTxEncoded,
+		msgDSTxEncoded[78:]...)
+
+	tests := []struct {
+		in   *MsgDSTx // Message to encode
+		out  *MsgDSTx // Expected decoded message
+		buf  []byte   // Wire encoding
+		pver uint32   // Protocol version for wire encoding
+	}{
+		// Protocol version CoinJoinProTxHashVersion.
+		{
+			msgDSTx,
+			msgDSTx,
+			msgDSTxEncoded,
+			CoinJoinProTxHashVersion,
+		},
+
+		// Protocol version CoinJoinProTxHashVersion - 1.
+		{
+			msgDSTx,
+			&msgDSTxNoProTx,
+			msgDSTxNoProTxEncoded,
+			CoinJoinProTxHashVersion - 1,
+		},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		// Encode the message to wire format.
+		var buf bytes.Buffer
+		err := test.in.BtcEncode(&buf, test.pver, BaseEncoding)
+		if err != nil {
+			t.Errorf("BtcEncode #%d error %v", i, err)
+			continue
+		}
+		if !bytes.Equal(buf.Bytes(), test.buf) {
+			t.Errorf("BtcEncode #%d\n got: %s want: %s", i,
+				spew.Sdump(buf.Bytes()), spew.Sdump(test.buf))
+			continue
+		}
+
+		// Decode the message from wire format.
+		var msg MsgDSTx
+		rbuf := bytes.NewReader(test.buf)
+		err = msg.BtcDecode(rbuf, test.pver, BaseEncoding)
+		if err != nil {
+			t.Errorf("BtcDecode #%d error %v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(&msg, test.out) {
+			t.Errorf("BtcDecode #%d\n got: %s want: %s", i,
+				spew.Sdump(msg), spew.Sdump(test.out))
+			continue
+		}
+	}
+}
+
+// TestDSTxWireErrors performs negative tests against wire encode and decode
+// of MsgDSTx to confirm error paths work correctly.
+func TestDSTxWireErrors(t *testing.T) {
+	pver := CoinJoinProTxHashVersion
+	wireErr := &MessageError{}
+
+	baseDSTx := &MsgDSTx{Tx: *NewMsgTx(1), Sig: []byte{0x01}}
+	baseDSTxEncoded := make([]byte, 88)
+	baseDSTxEncoded[0] = 0x01
+	baseDSTxEncoded[78] = 0x01
+	baseDSTxEncoded[79] = 0x01
+
+	// A signature that exceeds the maximum allowed size.
+	bigSigDSTx := &MsgDSTx{
+		Tx:  *NewMsgTx(1),
+		Sig: make([]byte, MaxCoinJoinSigSize+1),
+	}
+	bigSigDSTxEncoded := make([]byte, 79)
+	bigSigDSTxEncoded[0] = 0x01
+	bigSigDSTxEncoded[78] = MaxCoinJoinSigSize + 1
+
+	tests := []struct {
+		in       *MsgDSTx // Value to encode
+		buf      []byte   // Wire encoding
+		max      int      // Max size of fixed buffer to induce errors
+		writeErr error    // Expected write error
+		readErr  error    // Expected read error
+	}{
+		// Force error in transaction.
+		{baseDSTx, baseDSTxEncoded, 0, io.ErrShortWrite, io.EOF},
+		// Force error in masternode outpoint.
+		{baseDSTx, baseDSTxEncoded, 10, io.ErrShortWrite, io.EOF},
+		// Force error in ProTxHash.
+		{baseDSTx, baseDSTxEncoded, 46, io.ErrShortWrite, io.EOF},
+		// Force error in signature.
+		{baseDSTx, baseDSTxEncoded, 78, io.ErrShortWrite, io.EOF},
+		// Force error in signature time.
+		{baseDSTx, baseDSTxEncoded, 80, io.ErrShortWrite, io.EOF},
+		// Force error with signature that is too long.
+		{bigSigDSTx, bigSigDSTxEncoded, 79, wireErr, wireErr},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		// Encode to wire format.
+		w := newFixedWriter(test.max)
+		err := test.in.BtcEncode(w, pver, BaseEncoding)
+		if reflect.TypeOf(err) != reflect.TypeOf(test.writeErr) {
+			t.Errorf("BtcEncode #%d wrong error got: %v, want: %v",
+				i, err, test.writeErr)
+			continue
+		}
+
+		// For errors which are not of type MessageError, check them for
+		// equality.
+		if _, ok := err.(*MessageError); !ok {
+			if err != test.writeErr {
+				t.Errorf("BtcEncode #%d wrong error got: %v, "+
+					"want: %v", i, err, test.writeErr)
+				continue
+			}
+		}
+
+		// Decode from wire format.
+		var msg MsgDSTx
+		r := newFixedReader(test.max, test.buf)
+		err = msg.BtcDecode(r, pver, BaseEncoding)
+		if reflect.TypeOf(err) != reflect.TypeOf(test.readErr) {
+			t.Errorf("BtcDecode #%d wrong error got: %v, want: %v",
+				i, err, test.readErr)
+			continue
+		}
+
+		// For errors which are not of type MessageError, check them for
+		// equality.
+		if _, ok := err.(*MessageError); !ok {
+			if err != test.readErr {
+				t.Errorf("BtcDecode #%d wrong error got: %v, "+
+					"want: %v", i, err, test.readErr)
+				continue
+			}
+		}
+	}
+}
@@ -0,0 +1,121 @@
+// Copyright (c) 2016 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/eager7/dashd/chaincfg/chainhash"
+)
+
+// MsgGetBlockTxn implements the Message interface and represents a bitcoin
+// getblocktxn message.  It is used to request the transactions of a compact
+// block (MsgCmpctBlock) which could not be found from their short transaction
+// IDs.  The transactions are sent back in a blocktxn message (MsgBlockTxn).
+//
+// The indexes of the transactions within the block must be strictly
+// increasing.
+//
+// This message was not added until protocol version ShortIDsBlocksVersion.
+type MsgGetBlockTxn struct {
+	BlockHash chainhash.Hash
+	Indexes   []uint32
+}
+
+// AddIndex adds a transaction index to the message.
+func (msg *MsgGetBlockTxn) AddIndex(index uint32) {
+	msg.Indexes = append(msg.Indexes, index)
+}
+
+// BtcDecode decodes r using the bitcoin protocol encoding into the receiver.
+// This is part of the Message interface implementation.
+func (msg *MsgGetBlockTxn) BtcDecode(r io.Reader, pver uint32, enc MessageEncoding) error {
+	err := readElement(r, &msg.BlockHash)
+	if err != nil {
+		return err
+	}
+
+	count, err := ReadVarInt(r, pver)
+	if err != nil {
+		return err
+	}
+
+	// Prevent more indexes than transactions could possibly fit into a
+	// block.
+	if count > maxTxPerBlock {
+		str := fmt.Sprintf("too many transaction indexes for message "+
+			"[count %d, max %d]", count, maxTxPerBlock)
+		return messageError("MsgGetBlockTxn.BtcDecode", str)
+	}
+
+	msg.Indexes = make([]uint32, 0, count)
+	prev := int64(-1)
+	for i := uint64(0); i < count; i++ {
+		index, err := readTxIndex(r, pver, prev,
+			"MsgGetBlockTxn.BtcDecode")
+		if err != nil {
+			return err
+		}
+		prev = int64(index)
+		msg.AddIndex(index)
+	}
+
+	return nil
+}
+
+// BtcEncode encodes the receiver to w using the bitcoin protocol encoding.
+// This is part of the Message interface implementation.
+func (msg *MsgGetBlockTxn) BtcEncode(w io.Writer, pver uint32, enc MessageEncoding) error {
+	err := writeElement(w, &msg.BlockHash)
+	if err != nil {
+		return err
+	}
+
+	err = WriteVarInt(w, pver, uint64(len(msg.Indexes)))
+	if err != nil {
+		return err
+	}
+
+	prev := int64(-1)
+	for _, index := range msg.Indexes {
+		err := writeTxIndex(w, pver, prev, index,
+			"MsgGetBlockTxn.BtcEncode")
+		if err != nil {
+			return err
+		}
+		prev = int64(index)
+	}
+
+	return nil
+}
+
+// Command returns the protocol command string for the message.  This is part
+// of the Message interface implementation.
+func (msg *MsgGetBlockTxn) Command() string {
+	return CmdGetBlockTxn
+}
+
+// MaxPayloadLength returns the maximum length the payload can be for the
+// receiver.  This is part of the Message interface implementation.
+func (msg *MsgGetBlockTxn) MaxPayloadLength(pver uint32) uint32 {
+	// Block hash + num indexes (varInt) + max allowed indexes, which take
+	// at most 3 bytes each.
+	return chainhash.HashSize + MaxVarIntPayload +
+		(maxCmpctBlockTxIndex+1)*3
+}
+
+// NewMsgGetBlockTxn returns a new bitcoin getblocktxn message that conforms to
+// the Message interface using the passed block hash and transaction indexes.
+// See MsgGetBlockTxn for details.
+func NewMsgGetBlockTxn(blockHash *chainhash.Hash, indexes []uint32) *MsgGetBlockTxn {
+	if indexes == nil {
+		indexes = make([]uint32, 0)
+	}
+	return &MsgGetBlockTxn{
+		BlockHash: *blockHash,
+		Indexes:   indexes,
+	}
+}
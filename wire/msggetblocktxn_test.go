@@ -0,0 +1,116 @@
+// Copyright (c) 2016 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/davecgh/go-spew/spew"
+)
+
+// TestGetBlockTxn tests the MsgGetBlockTxn API.
+func TestGetBlockTxn(t *testing.T) {
+	pver := ProtocolVersion
+
+	hash := blockOne.BlockHash()
+	msg := NewMsgGetBlockTxn(&hash, nil)
+	if msg.BlockHash != hash || len(msg.Indexes) != 0 {
+		t.Errorf("NewMsgGetBlockTxn: wrong message %v", spew.Sdump(msg))
+	}
+	msg.AddIndex(3)
+	if !reflect.DeepEqual(msg.Indexes, []uint32{3}) {
+		t.Errorf("AddIndex: wrong indexes - got %v, want %v",
+			msg.Indexes, []uint32{3})
+	}
+
+	// Ensure the command is expected value.
+	wantCmd := "getblocktxn"
+	if cmd := msg.Command(); cmd != wantCmd {
+		t.Errorf("NewMsgGetBlockTxn: wrong command - got %v want %v",
+			cmd, wantCmd)
+	}
+
+	// Ensure max payload is expected value for latest protocol version.
+	// Block hash 32 bytes + num indexes (varInt) 9 bytes + 65536 indexes
+	// of 3 bytes each.
+	wantPayload := uint32(196649)
+	maxPayload := msg.MaxPayloadLength(pver)
+	if maxPayload != wantPayload {
+		t.Errorf("MaxPayloadLength: wrong max payload length for "+
+			"protocol version %d - got %v, want %v", pver,
+			maxPayload, wantPayload)
+	}
+}
+
+// TestGetBlockTxnWire tests the MsgGetBlockTxn wire encode and decode with
+// its differentially encoded indexes.
+func TestGetBlockTxnWire(t *testing.T) {
+	pver := ProtocolVersion
+
+	hash := blockOne.BlockHash()
+	msg := NewMsgGetBlockTxn(&hash, []uint32{0, 1, 5, 300})
+	want := append(append([]byte{}, hash[:]...), 0x04, 0x00, 0x00, 0x03,
+		0xfd, 0x26, 0x01)
+
+	var buf bytes.Buffer
+	if err := msg.BtcEncode(&buf, pver, BaseEncoding); err != nil {
+		t.Fatalf("BtcEncode error %v", err)
+	}
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Fatalf("BtcEncode\n got: %s want: %s",
+			spew.Sdump(buf.Bytes()), spew.Sdump(want))
+	}
+
+	var readMsg MsgGetBlockTxn
+	err := readMsg.BtcDecode(bytes.NewReader(want), pver, BaseEncoding)
+	if err != nil {
+		t.Fatalf("BtcDecode error %v", err)
+	}
+	if !reflect.DeepEqual(&readMsg, msg) {
+		t.Errorf("BtcDecode\n got: %s want: %s", spew.Sdump(&readMsg),
+			spew.Sdump(msg))
+	}
+}
+
+// TestGetBlockTxnWireErrors performs negative tests against wire encode and
+// decode of MsgGetBlockTxn to confirm error paths work correctly.
+func TestGetBlockTxnWireErrors(t *testing.T) {
+	pver := ProtocolVersion
+	hash := blockOne.BlockHash()
+
+	// Indexes which are not strictly increasing or too high fail to
+	// encode.
+	for _, indexes := range [][]uint32{{2, 1}, {1, 1}, {0x10000}} {
+		msg := NewMsgGetBlockTxn(&hash, indexes)
+		var buf bytes.Buffer
+		err := msg.BtcEncode(&buf, pver, BaseEncoding)
+		if _, ok := err.(*MessageError); !ok {
+			t.Errorf("BtcEncode %v: wrong error - got %T (%v), "+
+				"want *MessageError", indexes, err, err)
+		}
+	}
+
+	tests := []struct {
+		name string
+		buf  []byte
+	}{
+		{"too many indexes", append(append([]byte{}, hash[:]...), 0xfe,
+			0xff, 0xff, 0xff, 0xff)},
+		{"index too high", append(append([]byte{}, hash[:]...), 0x02,
+			0xfd, 0xff, 0xff, 0x00)},
+	}
+
+	for _, test := range tests {
+		var msg MsgGetBlockTxn
+		err := msg.BtcDecode(bytes.NewReader(test.buf), pver,
+			BaseEncoding)
+		if _, ok := err.(*MessageError); !ok {
+			t.Errorf("BtcDecode %s: wrong error - got %T (%v), "+
+				"want *MessageError", test.name, err, err)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+// Copyright (c) 2016 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"bytes"
+	"testing"
+)
+
+// TestSendAddrV2 tests the MsgSendAddrV2 API and its empty wire encoding.
+func TestSendAddrV2(t *testing.T) {
+	pver := ProtocolVersion
+
+	// Ensure the command is expected value.
+	msg := NewMsgSendAddrV2()
+	wantCmd := "sendaddrv2"
+	if cmd := msg.Command(); cmd != wantCmd {
+		t.Errorf("NewMsgSendAddrV2: wrong command - got %v want %v",
+			cmd, wantCmd)
+	}
+
+	// Ensure max payload is expected value.
+	if maxPayload := msg.MaxPayloadLength(pver); maxPayload != 0 {
+		t.Errorf("MaxPayloadLength: wrong max payload length for "+
+			"protocol version %d - got %v, want 0", pver, maxPayload)
+	}
+
+	// The message has no payload.
+	var buf bytes.Buffer
+	if err := msg.BtcEncode(&buf, pver, BaseEncoding); err != nil {
+		t.Errorf("BtcEncode error %v", err)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("BtcEncode: unexpected payload %x", buf.Bytes())
+	}
+	if err := msg.BtcDecode(&buf, pver, BaseEncoding); err != nil {
+		t.Errorf("BtcDecode error %v", err)
+	}
+}
@@ -0,0 +1,104 @@
+// Copyright (c) 2016 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/davecgh/go-spew/spew"
+)
+
+// TestSendCmpct tests the MsgSendCmpct API.
+func TestSendCmpct(t *testing.T) {
+	pver := ProtocolVersion
+
+	msg := NewMsgSendCmpct(true, CmpctBlockVersion)
+	if !msg.AnnounceUsingCmpctBlock {
+		t.Errorf("NewMsgSendCmpct: wrong announce flag - got %v, "+
+			"want %v", msg.AnnounceUsingCmpctBlock, true)
+	}
+	if msg.CmpctBlockVersion != CmpctBlockVersion {
+		t.Errorf("NewMsgSendCmpct: wrong version - got %v, want %v",
+			msg.CmpctBlockVersion, CmpctBlockVersion)
+	}
+
+	// Ensure the command is expected value.
+	wantCmd := "sendcmpct"
+	if cmd := msg.Command(); cmd != wantCmd {
+		t.Errorf("NewMsgSendCmpct: wrong command - got %v want %v",
+			cmd, wantCmd)
+	}
+
+	// Ensure max payload is expected value for latest protocol version.
+	wantPayload := uint32(9)
+	maxPayload := msg.MaxPayloadLength(pver)
+	if maxPayload != wantPayload {
+		t.Errorf("MaxPayloadLength: wrong max payload length for "+
+			"protocol version %d - got %v, want %v", pver,
+			maxPayload, wantPayload)
+	}
+}
+
+// TestSendCmpctWire tests the MsgSendCmpct wire encode and decode.
+func TestSendCmpctWire(t *testing.T) {
+	tests := []struct {
+		in  *MsgSendCmpct // Message to encode
+		out *MsgSendCmpct // Expected decoded message
+		buf []byte        // Wire encoding
+	}{
+		{
+			NewMsgSendCmpct(true, 1),
+			NewMsgSendCmpct(true, 1),
+			[]byte{0x01, 0x01, 0, 0, 0, 0, 0, 0, 0},
+		},
+		{
+			NewMsgSendCmpct(false, 2),
+			NewMsgSendCmpct(false, 2),
+			[]byte{0x00, 0x02, 0, 0, 0, 0, 0, 0, 0},
+		},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		// Encode the message to wire format.
+		var buf bytes.Buffer
+		err := test.in.BtcEncode(&buf, ProtocolVersion, BaseEncoding)
+		if err != nil {
+			t.Errorf("BtcEncode #%d error %v", i, err)
+			continue
+		}
+		if !bytes.Equal(buf.Bytes(), test.buf) {
+			t.Errorf("BtcEncode #%d\n got: %s want: %s", i,
+				spew.Sdump(buf.Bytes()), spew.Sdump(test.buf))
+			continue
+		}
+
+		// Decode the message from wire format.
+		var msg MsgSendCmpct
+		rbuf := bytes.NewReader(test.buf)
+		err = msg.BtcDecode(rbuf, ProtocolVersion, BaseEncoding)
+		if err != nil {
+			t.Errorf("BtcDecode #%d error %v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(&msg, test.out) {
+			t.Errorf("BtcDecode #%d\n got: %s want: %s", i,
+				spew.Sdump(msg), spew.Sdump(test.out))
+			continue
+		}
+	}
+
+	// Ensure a truncated message fails to decode.
+	var msg MsgSendCmpct
+	err := msg.BtcDecode(bytes.NewReader([]byte{0x01, 0x01}),
+		ProtocolVersion, BaseEncoding)
+	if err != io.ErrUnexpectedEOF {
+		t.Errorf("BtcDecode of truncated buffer: got %v, want %v", err,
+			io.ErrUnexpectedEOF)
+	}
+}
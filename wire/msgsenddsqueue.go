@@ -0,0 +1,48 @@
+// Copyright (c) 2016 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"io"
+)
+
+// MsgSendDSQueue implements the Message interface and represents a Dash
+// senddsq message.  It is used to request the receiving peer to relay, or stop
+// relaying, the CoinJoin queues (dsq messages) it learns about.
+type MsgSendDSQueue struct {
+	Send bool
+}
+
+// BtcDecode decodes r using the bitcoin protocol encoding into the receiver.
+// This is part of the Message interface implementation.
+func (msg *MsgSendDSQueue) BtcDecode(r io.Reader, pver uint32, enc MessageEncoding) error {
+	return readElement(r, &msg.Send)
+}
+
+// BtcEncode encodes the receiver to w using the bitcoin protocol encoding.
+// This is part of the Message interface implementation.
+func (msg *MsgSendDSQueue) BtcEncode(w io.Writer, pver uint32, enc MessageEncoding) error {
+	return writeElement(w, msg.Send)
+}
+
+// Command returns the protocol command string for the message.  This is part
+// of the Message interface implementation.
+func (msg *MsgSendDSQueue) Command() string {
+	return CmdSendDSQueue
+}
+
+// MaxPayloadLength returns the maximum length the payload can be for the
+// receiver.  This is part of the Message interface implementation.
+func (msg *MsgSendDSQueue) MaxPayloadLength(pver uint32) uint32 {
+	return 1
+}
+
+// NewMsgSendDSQueue returns a new Dash senddsq message that conforms to the
+// Message interface.  See MsgSendDSQueue for details.
+func NewMsgSendDSQueue(send bool) *MsgSendDSQueue {
+	return &MsgSendDSQueue{
+		Send: send,
+	}
+}
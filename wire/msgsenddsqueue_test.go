@@ -0,0 +1,90 @@
+// Copyright (c) 2016 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/davecgh/go-spew/spew"
+)
+
+// TestSendDSQueue tests the MsgSendDSQueue API.
+func TestSendDSQueue(t *testing.T) {
+	pver := ProtocolVersion
+
+	msg := NewMsgSendDSQueue(true)
+	if !msg.Send {
+		t.Errorf("NewMsgSendDSQueue: wrong send flag - got %v, want %v",
+			msg.Send, true)
+	}
+
+	// Ensure the command is expected value.
+	wantCmd := "senddsq"
+	if cmd := msg.Command(); cmd != wantCmd {
+		t.Errorf("NewMsgSendDSQueue: wrong command - got %v want %v",
+			cmd, wantCmd)
+	}
+
+	// Ensure max payload is expected value for latest protocol version.
+	wantPayload := uint32(1)
+	maxPayload := msg.MaxPayloadLength(pver)
+	if maxPayload != wantPayload {
+		t.Errorf("MaxPayloadLength: wrong max payload length for "+
+			"protocol version %d - got %v, want %v", pver,
+			maxPayload, wantPayload)
+	}
+}
+
+// TestSendDSQueueWire tests the MsgSendDSQueue wire encode and decode.
+func TestSendDSQueueWire(t *testing.T) {
+	tests := []struct {
+		in  MsgSendDSQueue // Message to encode
+		out MsgSendDSQueue // Expected decoded message
+		buf []byte         // Wire encoding
+	}{
+		{MsgSendDSQueue{Send: true}, MsgSendDSQueue{Send: true}, []byte{0x01}},
+		{MsgSendDSQueue{Send: false}, MsgSendDSQueue{Send: false}, []byte{0x00}},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		// Encode the message to wire format.
+		var buf bytes.Buffer
+		err := test.in.BtcEncode(&buf, ProtocolVersion, BaseEncoding)
+		if err != nil {
+			t.Errorf("BtcEncode #%d error %v", i, err)
+			continue
+		}
+		if !bytes.Equal(buf.Bytes(), test.buf) {
+			t.Errorf("BtcEncode #%d\n got: %s want: %s", i,
+				spew.Sdump(buf.Bytes()), spew.Sdump(test.buf))
+			continue
+		}
+
+		// Decode the message from wire format.
+		var msg MsgSendDSQueue
+		rbuf := bytes.NewReader(test.buf)
+		err = msg.BtcDecode(rbuf, ProtocolVersion, BaseEncoding)
+		if err != nil {
+			t.Errorf("BtcDecode #%d error %v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(msg, test.out) {
+			t.Errorf("BtcDecode #%d\n got: %s want: %s", i,
+				spew.Sdump(msg), spew.Sdump(test.out))
+			continue
+		}
+	}
+
+	// Ensure a truncated message fails to decode.
+	var msg MsgSendDSQueue
+	err := msg.BtcDecode(bytes.NewReader(nil), ProtocolVersion, BaseEncoding)
+	if err != io.EOF {
+		t.Errorf("BtcDecode of empty buffer: got %v, want %v", err, io.EOF)
+	}
+}
@@ -286,11 +286,17 @@ func NewTxOut(value int64, pkScript []byte) *TxOut {
 //
 // Use the AddTxIn and AddTxOut functions to build up the list of transaction
 // inputs and outputs.
+//
+// Special transactions as defined by DIP0002 have a version of at least
+// SpecialTxVersion along with a non-normal Type and carry the type specific
+// extra payload in Payload.
 type MsgTx struct {
 	Version  int32
+	Type     TxType
 	TxIn     []*TxIn
 	TxOut    []*TxOut
 	LockTime uint32
+	Payload  []byte
 }
 
 // AddTxIn adds a transaction input to the message.
@@ -336,11 +342,18 @@ func (msg *MsgTx) Copy() *MsgTx {
 	// for the transaction inputs and outputs.
 	newTx := MsgTx{
 		Version:  msg.Version,
+		Type:     msg.Type,
 		TxIn:     make([]*TxIn, 0, len(msg.TxIn)),
 		TxOut:    make([]*TxOut, 0, len(msg.TxOut)),
 		LockTime: msg.LockTime,
 	}
 
+	// Deep copy the extra payload of special transactions.
+	if msg.Payload != nil {
+		newTx.Payload = make([]byte, len(msg.Payload))
+		copy(newTx.Payload, msg.Payload)
+	}
+
 	// Deep copy the old TxIn data.
 	for _, oldTxIn := range msg.TxIn {
 		// Deep copy the old previous outpoint.
@@ -413,7 +426,8 @@ func (msg *MsgTx) BtcDecode(r io.Reader, pver uint32, enc MessageEncoding) error
 	if err != nil {
 		return err
 	}
-	msg.Version = int32(version)
+	msg.Version, msg.Type = splitTxVersion(version)
+	msg.Payload = nil
 
 	count, err := ReadVarInt(r, pver)
 	if err != nil {
@@ -421,9 +435,11 @@ func (msg *MsgTx) BtcDecode(r io.Reader, pver uint32, enc MessageEncoding) error
 	}
 
 	// A count of zero (meaning no TxIn's to the uninitiated) indicates
-	// this is a transaction with witness data.
+	// this is a transaction with witness data.  Special transactions are
+	// excluded since some types, such as asset unlocks, legitimately have
+	// no inputs.
 	var flag [1]byte
-	if count == 0 && enc == WitnessEncoding {
+	if count == 0 && enc == WitnessEncoding && !msg.IsSpecial() {
 		// Next, we need to read the flag, which is a single byte.
 		if _, err = io.ReadFull(r, flag[:]); err != nil {
 			return err
@@ -578,6 +594,16 @@ func (msg *MsgTx) BtcDecode(r io.Reader, pver uint32, enc MessageEncoding) error
 		return err
 	}
 
+	// Special transactions carry their extra payload after the lock time.
+	if msg.IsSpecial() {
+		msg.Payload, err = ReadVarBytes(r, pver, MaxTxExtraPayload,
+			"extra payload")
+		if err != nil {
+			returnScriptBuffers()
+			return err
+		}
+	}
+
 	// Create a single allocation to house all of the scripts and set each
 	// input signature script and output public key script to the
 	// appropriate subslice of the overall contiguous buffer.  Then, return
@@ -679,7 +705,8 @@ func (msg *MsgTx) DeserializeNoWitness(r io.Reader) error {
 // See Serialize for encoding transactions to be stored to disk, such as in a
 // database, as opposed to encoding transactions for the wire.
 func (msg *MsgTx) BtcEncode(w io.Writer, pver uint32, enc MessageEncoding) error {
-	err := binarySerializer.PutUint32(w, littleEndian, uint32(msg.Version))
+	err := binarySerializer.PutUint32(w, littleEndian,
+		joinTxVersion(msg.Version, msg.Type))
 	if err != nil {
 		return err
 	}
@@ -740,7 +767,17 @@ func (msg *MsgTx) BtcEncode(w io.Writer, pver uint32, enc MessageEncoding) error
 		}
 	}
 
-	return binarySerializer.PutUint32(w, littleEndian, msg.LockTime)
+	err = binarySerializer.PutUint32(w, littleEndian, msg.LockTime)
+	if err != nil {
+		return err
+	}
+
+	// Special transactions carry their extra payload after the lock time.
+	if msg.IsSpecial() {
+		return WriteVarBytes(w, pver, msg.Payload)
+	}
+
+	return nil
 }
 
 // HasWitness returns false if none of the inputs within the transaction
@@ -800,6 +837,13 @@ func (msg *MsgTx) baseSize() int {
 		n += txOut.SerializeSize()
 	}
 
+	// Special transactions also serialize the extra payload along with
+	// its varint length.
+	if msg.IsSpecial() {
+		n += VarIntSerializeSize(uint64(len(msg.Payload))) +
+			len(msg.Payload)
+	}
+
 	return n
 }
 
@@ -0,0 +1,304 @@
+// Copyright (c) 2013-2015 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"time"
+)
+
+// MaxNetAddressV2Size is the maximum size of the address of a NetAddressV2 as
+// defined by BIP0155.  Larger addresses are rejected even when their network
+// is unknown.
+const MaxNetAddressV2Size = 512
+
+// NetworkID identifies the network of the address of a NetAddressV2 as defined
+// by BIP0155.
+type NetworkID uint8
+
+const (
+	// NetIPv4 identifies an IPv4 address.
+	NetIPv4 NetworkID = 1
+
+	// NetIPv6 identifies an IPv6 address.
+	NetIPv6 NetworkID = 2
+
+	// NetTorV2 identifies a Tor v2 hidden service address.
+	NetTorV2 NetworkID = 3
+
+	// NetTorV3 identifies a Tor v3 hidden service address.
+	NetTorV3 NetworkID = 4
+
+	// NetI2P identifies an I2P address.
+	NetI2P NetworkID = 5
+
+	// NetCJDNS identifies a CJDNS address.
+	NetCJDNS NetworkID = 6
+)
+
+// netIDAddrSizes maps the known network IDs to the size of their addresses.
+var netIDAddrSizes = map[NetworkID]int{
+	NetIPv4:  4,
+	NetIPv6:  16,
+	NetTorV2: 10,
+	NetTorV3: 32,
+	NetI2P:   32,
+	NetCJDNS: 16,
+}
+
+// netIDStrings is a map of network IDs back to their constant names for
+// pretty printing.
+var netIDStrings = map[NetworkID]string{
+	NetIPv4:  "IPv4",
+	NetIPv6:  "IPv6",
+	NetTorV2: "TorV2",
+	NetTorV3: "TorV3",
+	NetI2P:   "I2P",
+	NetCJDNS: "CJDNS",
+}
+
+// String returns the NetworkID in human-readable form.
+func (id NetworkID) String() string {
+	if s, ok := netIDStrings[id]; ok {
+		return s
+	}
+
+	return fmt.Sprintf("Unknown NetworkID (%d)", uint8(id))
+}
+
+// AddrSize returns the size of the addresses of the network, or zero when the
+// network is unknown.
+func (id NetworkID) AddrSize() int {
+	return netIDAddrSizes[id]
+}
+
+// onionCatPrefix is the prefix of the IPv6 range used by OnionCat, which is how
+// Tor v2 addresses are encoded in a NetAddress (fd87:d87e:eb43::/48).
+var onionCatPrefix = []byte{0xfd, 0x87, 0xd8, 0x7e, 0xeb, 0x43}
+
+// maxNetAddressV2Payload returns the max payload size for a NetAddressV2.
+func maxNetAddressV2Payload() uint32 {
+	// Timestamp 4 bytes + services varint + network id 1 byte + address
+	// length varint + address + port 2 bytes.
+	return 4 + MaxVarIntPayload + 1 + MaxVarIntPayload +
+		MaxNetAddressV2Size + 2
+}
+
+// NetAddressV2 defines information about a peer on the network including the
+// time it was last seen, the services it supports, its address, and port.
+// Unlike NetAddress, the address is of variable length and its network is
+// identified explicitly, so addresses which are not IP addresses, such as Tor
+// v3 hidden services, can be represented (BIP0155).
+type NetAddressV2 struct {
+	// Last time the address was seen.  This is encoded as a uint32 on the
+	// wire and therefore is limited to 2106.
+	Timestamp time.Time
+
+	// Bitfield which identifies the services supported by the address.
+	Services ServiceFlag
+
+	// NetID identifies the network of the address.
+	NetID NetworkID
+
+	// Addr is the address of the peer in the encoding of its network.
+	Addr []byte
+
+	// Port the peer is using.  This is encoded in big endian on the wire
+	// which differs from most everything else.
+	Port uint16
+}
+
+// HasService returns whether the specified service is supported by the address.
+func (na *NetAddressV2) HasService(service ServiceFlag) bool {
+	return na.Services&service == service
+}
+
+// AddService adds service as a supported service by the peer generating the
+// message.
+func (na *NetAddressV2) AddService(service ServiceFlag) {
+	na.Services |= service
+}
+
+// IP returns the IP address of an IPv4 or IPv6 address, or nil when the
+// address is of another network.
+func (na *NetAddressV2) IP() net.IP {
+	switch na.NetID {
+	case NetIPv4, NetIPv6:
+		return net.IP(na.Addr)
+	}
+	return nil
+}
+
+// ToLegacy returns the address as a NetAddress, which is used by the addr and
+// version messages.  Tor v2 addresses are encoded in the OnionCat range.  It
+// returns nil when the address can not be represented as a NetAddress.
+func (na *NetAddressV2) ToLegacy() *NetAddress {
+	var ip net.IP
+	switch na.NetID {
+	case NetIPv4, NetIPv6:
+		ip = net.IP(na.Addr)
+
+	case NetTorV2:
+		ip = make(net.IP, 0, net.IPv6len)
+		ip = append(ip, onionCatPrefix...)
+		ip = append(ip, na.Addr...)
+
+	default:
+		return nil
+	}
+
+	return NewNetAddressTimestamp(na.Timestamp, na.Services, ip, na.Port)
+}
+
+// NewNetAddressV2 returns a new NetAddressV2 using the provided network,
+// address, port, and supported services with defaults for the remaining
+// fields.
+func NewNetAddressV2(netID NetworkID, addr []byte, port uint16, services ServiceFlag) *NetAddressV2 {
+	return NewNetAddressV2Timestamp(time.Now(), services, netID, addr, port)
+}
+
+// NewNetAddressV2Timestamp returns a new NetAddressV2 using the provided
+// timestamp, network, address, port, and supported services.  The timestamp is
+// rounded to single second precision.
+func NewNetAddressV2Timestamp(timestamp time.Time, services ServiceFlag,
+	netID NetworkID, addr []byte, port uint16) *NetAddressV2 {
+
+	// Limit the timestamp to one second precision since the protocol
+	// doesn't support better.
+	na := NetAddressV2{
+		Timestamp: time.Unix(timestamp.Unix(), 0),
+		Services:  services,
+		NetID:     netID,
+		Addr:      addr,
+		Port:      port,
+	}
+	return &na
+}
+
+// NewNetAddressV2IPPort returns a new NetAddressV2 using the provided IP, port,
+// and supported services with defaults for the remaining fields.  The address
+// is an IPv4 address when the IP is an IPv4 or IPv4-mapped IPv6 address and an
+// IPv6 address otherwise.
+func NewNetAddressV2IPPort(ip net.IP, port uint16, services ServiceFlag) *NetAddressV2 {
+	return NewNetAddressV2Timestamp(time.Now(), services, ipNetID(ip),
+		ipAddrBytes(ip), port)
+}
+
+// NetAddressV2FromLegacy returns the passed NetAddress as a NetAddressV2.
+// Addresses in the OnionCat range are Tor v2 addresses.
+func NetAddressV2FromLegacy(na *NetAddress) *NetAddressV2 {
+	netID, addr := ipNetID(na.IP), ipAddrBytes(na.IP)
+	if netID == NetIPv6 && bytes.HasPrefix(addr, onionCatPrefix) {
+		netID, addr = NetTorV2, addr[len(onionCatPrefix):]
+	}
+
+	return &NetAddressV2{
+		Timestamp: na.Timestamp,
+		Services:  na.Services,
+		NetID:     netID,
+		Addr:      addr,
+		Port:      na.Port,
+	}
+}
+
+// ipNetID returns the network of the passed IP address.
+func ipNetID(ip net.IP) NetworkID {
+	if ip.To4() != nil {
+		return NetIPv4
+	}
+	return NetIPv6
+}
+
+// ipAddrBytes returns a copy of the passed IP address in the encoding of its
+// network, which is 4 bytes for IPv4 and 16 bytes for IPv6.  A nil IP results
+// in the unspecified IPv6 address.
+func ipAddrBytes(ip net.IP) []byte {
+	if ip4 := ip.To4(); ip4 != nil {
+		return append([]byte(nil), ip4...)
+	}
+
+	addr := make([]byte, net.IPv6len)
+	copy(addr, ip.To16())
+	return addr
+}
+
+// readNetAddressV2 reads an encoded NetAddressV2 from r.  Addresses of known
+// networks with a size which differs from the one of their network result in
+// an error.  Addresses of unknown networks are read, so the caller may skip
+// them.
+func readNetAddressV2(r io.Reader, pver uint32, na *NetAddressV2) error {
+	err := readElement(r, (*uint32Time)(&na.Timestamp))
+	if err != nil {
+		return err
+	}
+
+	// The services are encoded as a variable length integer unlike in
+	// NetAddress.
+	services, err := ReadVarInt(r, pver)
+	if err != nil {
+		return err
+	}
+	na.Services = ServiceFlag(services)
+
+	var netID uint8
+	err = readElement(r, &netID)
+	if err != nil {
+		return err
+	}
+	na.NetID = NetworkID(netID)
+
+	addr, err := ReadVarBytes(r, pver, MaxNetAddressV2Size, "address")
+	if err != nil {
+		return err
+	}
+	if size := na.NetID.AddrSize(); size != 0 && len(addr) != size {
+		str := fmt.Sprintf("invalid %v address size [got %d, want %d]",
+			na.NetID, len(addr), size)
+		return messageError("readNetAddressV2", str)
+	}
+	na.Addr = addr
+
+	// Sigh.  Bitcoin protocol mixes little and big endian.
+	na.Port, err = binarySerializer.Uint16(r, bigEndian)
+	return err
+}
+
+// writeNetAddressV2 serializes a NetAddressV2 to w.
+func writeNetAddressV2(w io.Writer, pver uint32, na *NetAddressV2) error {
+	if size := na.NetID.AddrSize(); size != 0 && len(na.Addr) != size {
+		str := fmt.Sprintf("invalid %v address size [got %d, want %d]",
+			na.NetID, len(na.Addr), size)
+		return messageError("writeNetAddressV2", str)
+	}
+	if len(na.Addr) > MaxNetAddressV2Size {
+		str := fmt.Sprintf("address is too large [size %d, max %d]",
+			len(na.Addr), MaxNetAddressV2Size)
+		return messageError("writeNetAddressV2", str)
+	}
+
+	err := writeElement(w, uint32(na.Timestamp.Unix()))
+	if err != nil {
+		return err
+	}
+	err = WriteVarInt(w, pver, uint64(na.Services))
+	if err != nil {
+		return err
+	}
+	err = writeElement(w, uint8(na.NetID))
+	if err != nil {
+		return err
+	}
+	err = WriteVarBytes(w, pver, na.Addr)
+	if err != nil {
+		return err
+	}
+
+	// Sigh.  Bitcoin protocol mixes little and big endian.
+	return binary.Write(w, bigEndian, na.Port)
+}
@@ -0,0 +1,167 @@
+// Copyright (c) 2013-2016 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"bytes"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/davecgh/go-spew/spew"
+)
+
+// TestNetAddressV2 tests the NetAddressV2 API.
+func TestNetAddressV2(t *testing.T) {
+	tests := []struct {
+		ip    string    // IP of the legacy address
+		netID NetworkID // Expected network
+		addr  []byte    // Expected address
+	}{
+		{"127.0.0.1", NetIPv4, []byte{127, 0, 0, 1}},
+		{"::ffff:127.0.0.1", NetIPv4, []byte{127, 0, 0, 1}},
+		{"2001:db8::1", NetIPv6, net.ParseIP("2001:db8::1")},
+		{"fd87:d87e:eb43:102:304:506:708:90a", NetTorV2,
+			[]byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		legacy := NewNetAddressIPPort(net.ParseIP(test.ip), 9999,
+			SFNodeNetwork)
+		na := NetAddressV2FromLegacy(legacy)
+		if na.NetID != test.netID || !bytes.Equal(na.Addr, test.addr) {
+			t.Errorf("NetAddressV2FromLegacy #%d: got %v %x, want "+
+				"%v %x", i, na.NetID, na.Addr, test.netID,
+				test.addr)
+			continue
+		}
+		if na.Port != 9999 || !na.HasService(SFNodeNetwork) {
+			t.Errorf("NetAddressV2FromLegacy #%d: wrong port or "+
+				"services - got %v %v", i, na.Port, na.Services)
+			continue
+		}
+
+		// Ensure the legacy address is the same after a round trip.
+		back := na.ToLegacy()
+		if back == nil || !back.IP.Equal(legacy.IP) {
+			t.Errorf("ToLegacy #%d: got %v, want %v", i, back,
+				legacy.IP)
+		}
+	}
+
+	// Addresses of networks which aren't IP based don't have an IP and
+	// can't be converted to legacy addresses unless they are Tor v2
+	// addresses.
+	na := NewNetAddressV2(NetTorV3, make([]byte, 32), 9999, 0)
+	if ip := na.IP(); ip != nil {
+		t.Errorf("IP: got %v for a Tor v3 address, want nil", ip)
+	}
+	if legacy := na.ToLegacy(); legacy != nil {
+		t.Errorf("ToLegacy: got %v for a Tor v3 address, want nil",
+			legacy)
+	}
+
+	// Ensure max payload is expected value.
+	wantPayload := uint32(537)
+	if maxPayload := maxNetAddressV2Payload(); maxPayload != wantPayload {
+		t.Errorf("maxNetAddressV2Payload: wrong max payload length - "+
+			"got %v, want %v", maxPayload, wantPayload)
+	}
+}
+
+// TestNetAddressV2Wire tests the NetAddressV2 wire encode and decode.
+func TestNetAddressV2Wire(t *testing.T) {
+	timestamp := time.Unix(0x495fab29, 0) // 2009-01-03 12:15:05 -0600 CST
+	torV3Addr := bytes.Repeat([]byte{0xab}, 32)
+
+	tests := []struct {
+		in  NetAddressV2 // NetAddressV2 to encode
+		buf []byte       // Wire encoding
+	}{
+		{
+			NetAddressV2{
+				Timestamp: timestamp,
+				Services:  SFNodeNetwork,
+				NetID:     NetIPv4,
+				Addr:      []byte{127, 0, 0, 1},
+				Port:      8333,
+			},
+			[]byte{
+				0x29, 0xab, 0x5f, 0x49, // Timestamp
+				0x01,               // Services
+				0x01,               // Network
+				0x04, 127, 0, 0, 1, // Address
+				0x20, 0x8d, // Port 8333 in big-endian
+			},
+		},
+		{
+			NetAddressV2{
+				Timestamp: timestamp,
+				Services:  SFNodeNetwork | SFNodeBloom,
+				NetID:     NetTorV3,
+				Addr:      torV3Addr,
+				Port:      9999,
+			},
+			append(append([]byte{
+				0x29, 0xab, 0x5f, 0x49, // Timestamp
+				0x05, // Services
+				0x04, // Network
+				0x20, // Address length
+			}, torV3Addr...),
+				0x27, 0x0f, // Port 9999 in big-endian
+			),
+		},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		// Encode to wire format.
+		var buf bytes.Buffer
+		err := writeNetAddressV2(&buf, ProtocolVersion, &test.in)
+		if err != nil {
+			t.Errorf("writeNetAddressV2 #%d error %v", i, err)
+			continue
+		}
+		if !bytes.Equal(buf.Bytes(), test.buf) {
+			t.Errorf("writeNetAddressV2 #%d\n got: %s want: %s", i,
+				spew.Sdump(buf.Bytes()), spew.Sdump(test.buf))
+			continue
+		}
+
+		// Decode the message from wire format.
+		var na NetAddressV2
+		rbuf := bytes.NewReader(test.buf)
+		err = readNetAddressV2(rbuf, ProtocolVersion, &na)
+		if err != nil {
+			t.Errorf("readNetAddressV2 #%d error %v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(na, test.in) {
+			t.Errorf("readNetAddressV2 #%d\n got: %s want: %s", i,
+				spew.Sdump(na), spew.Sdump(test.in))
+			continue
+		}
+	}
+
+	// Ensure addresses of known networks with the wrong size are rejected.
+	badAddr := NetAddressV2{NetID: NetTorV3, Addr: []byte{1, 2, 3, 4}}
+	var buf bytes.Buffer
+	err := writeNetAddressV2(&buf, ProtocolVersion, &badAddr)
+	if _, ok := err.(*MessageError); !ok {
+		t.Errorf("writeNetAddressV2: did not reject address of wrong "+
+			"size - got %v", err)
+	}
+	badAddr.NetID = NetIPv4
+	buf.Reset()
+	writeNetAddressV2(&buf, ProtocolVersion, &badAddr)
+	buf.Bytes()[5] = byte(NetTorV3)
+	err = readNetAddressV2(&buf, ProtocolVersion, &NetAddressV2{})
+	if _, ok := err.(*MessageError); !ok {
+		t.Errorf("readNetAddressV2: did not reject address of wrong "+
+			"size - got %v", err)
+	}
+}
@@ -51,6 +51,19 @@ const (
 	// FeeFilterVersion is the protocol version which added a new
 	// feefilter message.
 	FeeFilterVersion uint32 = 70013
+
+	// ShortIDsBlocksVersion is the protocol version which added the
+	// sendcmpct, cmpctblock, getblocktxn and blocktxn messages for compact
+	// block relay (BIP0152).
+	ShortIDsBlocksVersion uint32 = 70209
+
+	// AddrV2Version is the protocol version from which peers are sent a
+	// sendaddrv2 message to signal support for addrv2 messages (BIP0155).
+	AddrV2Version uint32 = 70223
+
+	// CoinJoinProTxHashVersion is the protocol version which added the
+	// ProRegTx hash of the masternode to the dsq and dstx messages.
+	CoinJoinProTxHashVersion uint32 = 70226
 )
 
 // ServiceFlag identifies services supported by a bitcoin peer.
@@ -86,6 +99,15 @@ const (
 	// SFNode2X is a flag used to indicate a peer is running the Segwit2X
 	// software.
 	SFNode2X
+
+	// SFNodeNetworkLimited is a flag used to indicate a peer serves the
+	// latest 288 blocks of the main chain, but not necessarily older ones,
+	// such as a pruned node (BIP0159).
+	SFNodeNetworkLimited ServiceFlag = 1 << 10
+
+	// SFNodeP2PV2 is a flag used to indicate a peer supports the v2
+	// encrypted P2P transport (BIP0324).
+	SFNodeP2PV2 ServiceFlag = 1 << 11
 )
 
 // Map of service flags back to their constant names for pretty printing.
@@ -98,6 +120,9 @@ var sfStrings = map[ServiceFlag]string{
 	SFNodeBit5:    "SFNodeBit5",
 	SFNodeCF:      "SFNodeCF",
 	SFNode2X:      "SFNode2X",
+
+	SFNodeNetworkLimited: "SFNodeNetworkLimited",
+	SFNodeP2PV2:          "SFNodeP2PV2",
 }
 
 // orderedSFStrings is an ordered list of service flags from highest to
@@ -111,6 +136,8 @@ var orderedSFStrings = []ServiceFlag{
 	SFNodeBit5,
 	SFNodeCF,
 	SFNode2X,
+	SFNodeNetworkLimited,
+	SFNodeP2PV2,
 }
 
 // String returns the ServiceFlag in human-readable form.
@@ -0,0 +1,693 @@
+// Copyright (c) 2013-2016 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+
+	"github.com/eager7/dashd/chaincfg/chainhash"
+)
+
+const (
+	// ProTxVersionLegacyBLS is the provider transaction payload version
+	// which uses the legacy serialization of BLS keys and signatures.
+	ProTxVersionLegacyBLS = 1
+
+	// ProTxVersionBasicBLS is the provider transaction payload version
+	// which uses the basic serialization of BLS keys and signatures and
+	// added the masternode type.
+	ProTxVersionBasicBLS = 2
+
+	// BLSPubKeySize is the size of a serialized BLS public key.
+	BLSPubKeySize = 48
+
+	// BLSSigSize is the size of a serialized BLS signature.
+	BLSSigSize = 96
+
+	// KeyIDSize is the size of the hash160 of a public key which is used
+	// to identify the owner and voting keys of a masternode.
+	KeyIDSize = 20
+)
+
+// MasternodeType identifies the type of a masternode registered by a provider
+// registration transaction.
+type MasternodeType uint16
+
+// These constants define the known masternode types.
+const (
+	MasternodeTypeRegular MasternodeType = 0
+	MasternodeTypeEvo     MasternodeType = 1
+)
+
+// String returns the MasternodeType in human-readable form.
+func (t MasternodeType) String() string {
+	switch t {
+	case MasternodeTypeRegular:
+		return "Regular"
+	case MasternodeTypeEvo:
+		return "Evo"
+	}
+	return fmt.Sprintf("Unknown MasternodeType (%d)", uint16(t))
+}
+
+// PlatformInfo houses the Platform related fields of evo masternodes.
+type PlatformInfo struct {
+	NodeID   [KeyIDSize]byte
+	P2PPort  uint16
+	HTTPPort uint16
+}
+
+// checkProTxVersion returns an error when the passed provider transaction
+// payload version is unknown.
+func checkProTxVersion(version uint16, op string) error {
+	if version == 0 || version > ProTxVersionBasicBLS {
+		str := fmt.Sprintf("unknown provider transaction payload "+
+			"version %d", version)
+		return messageError(op, str)
+	}
+	return nil
+}
+
+// readProTxService reads the service address of a masternode, which is encoded
+// as a 16 byte IPv6 (or IPv4-mapped) address followed by a big endian port.
+func readProTxService(r io.Reader) (net.IP, uint16, error) {
+	var ip [16]byte
+	if _, err := io.ReadFull(r, ip[:]); err != nil {
+		return nil, 0, err
+	}
+
+	port, err := binarySerializer.Uint16(r, bigEndian)
+	if err != nil {
+		return nil, 0, err
+	}
+	return net.IP(ip[:]), port, nil
+}
+
+// writeProTxService writes the service address of a masternode.
+func writeProTxService(w io.Writer, ip net.IP, port uint16) error {
+	var buf [16]byte
+	if ip != nil {
+		copy(buf[:], ip.To16())
+	}
+	if _, err := w.Write(buf[:]); err != nil {
+		return err
+	}
+
+	return binarySerializer.PutUint16(w, bigEndian, port)
+}
+
+// readPlatformInfo reads the Platform related fields of an evo masternode.
+func readPlatformInfo(r io.Reader, info *PlatformInfo) error {
+	if _, err := io.ReadFull(r, info.NodeID[:]); err != nil {
+		return err
+	}
+
+	var err error
+	info.P2PPort, err = binarySerializer.Uint16(r, littleEndian)
+	if err != nil {
+		return err
+	}
+	info.HTTPPort, err = binarySerializer.Uint16(r, littleEndian)
+	return err
+}
+
+// writePlatformInfo writes the Platform related fields of an evo masternode.
+func writePlatformInfo(w io.Writer, info *PlatformInfo) error {
+	if _, err := w.Write(info.NodeID[:]); err != nil {
+		return err
+	}
+
+	err := binarySerializer.PutUint16(w, littleEndian, info.P2PPort)
+	if err != nil {
+		return err
+	}
+	return binarySerializer.PutUint16(w, littleEndian, info.HTTPPort)
+}
+
+// ProRegTxPayload represents the extra payload of a provider registration
+// special transaction (type 1) as defined by DIP0003.  It registers a
+// masternode along with the keys of its owner, operator and voter.
+type ProRegTxPayload struct {
+	Version            uint16
+	MasternodeType     MasternodeType
+	Mode               uint16
+	CollateralOutpoint OutPoint
+	IP                 net.IP
+	Port               uint16
+	KeyIDOwner         [KeyIDSize]byte
+	PubKeyOperator     [BLSPubKeySize]byte
+	KeyIDVoting        [KeyIDSize]byte
+	OperatorReward     uint16
+	ScriptPayout       []byte
+	InputsHash         chainhash.Hash
+	Platform           PlatformInfo
+	Sig                []byte
+}
+
+// Deserialize decodes a provider registration payload from r into the
+// receiver.
+func (p *ProRegTxPayload) Deserialize(r io.Reader) error {
+	var err error
+	p.Version, err = binarySerializer.Uint16(r, littleEndian)
+	if err != nil {
+		return err
+	}
+	err = checkProTxVersion(p.Version, "ProRegTxPayload.Deserialize")
+	if err != nil {
+		return err
+	}
+
+	mnType, err := binarySerializer.Uint16(r, littleEndian)
+	if err != nil {
+		return err
+	}
+	p.MasternodeType = MasternodeType(mnType)
+
+	p.Mode, err = binarySerializer.Uint16(r, littleEndian)
+	if err != nil {
+		return err
+	}
+
+	err = readOutPoint(r, 0, 0, &p.CollateralOutpoint)
+	if err != nil {
+		return err
+	}
+
+	p.IP, p.Port, err = readProTxService(r)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.ReadFull(r, p.KeyIDOwner[:]); err != nil {
+		return err
+	}
+	if _, err := io.ReadFull(r, p.PubKeyOperator[:]); err != nil {
+		return err
+	}
+	if _, err := io.ReadFull(r, p.KeyIDVoting[:]); err != nil {
+		return err
+	}
+
+	p.OperatorReward, err = binarySerializer.Uint16(r, littleEndian)
+	if err != nil {
+		return err
+	}
+
+	p.ScriptPayout, err = ReadVarBytes(r, 0, MaxTxExtraPayload,
+		"payout script")
+	if err != nil {
+		return err
+	}
+
+	err = readElement(r, &p.InputsHash)
+	if err != nil {
+		return err
+	}
+
+	if p.MasternodeType == MasternodeTypeEvo {
+		err = readPlatformInfo(r, &p.Platform)
+		if err != nil {
+			return err
+		}
+	}
+
+	p.Sig, err = ReadVarBytes(r, 0, MaxTxExtraPayload,
+		"provider registration signature")
+	return err
+}
+
+// Serialize encodes the receiver to w.
+func (p *ProRegTxPayload) Serialize(w io.Writer) error {
+	err := binarySerializer.PutUint16(w, littleEndian, p.Version)
+	if err != nil {
+		return err
+	}
+
+	err = binarySerializer.PutUint16(w, littleEndian,
+		uint16(p.MasternodeType))
+	if err != nil {
+		return err
+	}
+
+	err = binarySerializer.PutUint16(w, littleEndian, p.Mode)
+	if err != nil {
+		return err
+	}
+
+	err = writeOutPoint(w, 0, 0, &p.CollateralOutpoint)
+	if err != nil {
+		return err
+	}
+
+	err = writeProTxService(w, p.IP, p.Port)
+	if err != nil {
+		return err
+	}
+
+	if _, err := w.Write(p.KeyIDOwner[:]); err != nil {
+		return err
+	}
+	if _, err := w.Write(p.PubKeyOperator[:]); err != nil {
+		return err
+	}
+	if _, err := w.Write(p.KeyIDVoting[:]); err != nil {
+		return err
+	}
+
+	err = binarySerializer.PutUint16(w, littleEndian, p.OperatorReward)
+	if err != nil {
+		return err
+	}
+
+	err = WriteVarBytes(w, 0, p.ScriptPayout)
+	if err != nil {
+		return err
+	}
+
+	err = writeElement(w, &p.InputsHash)
+	if err != nil {
+		return err
+	}
+
+	if p.MasternodeType == MasternodeTypeEvo {
+		err = writePlatformInfo(w, &p.Platform)
+		if err != nil {
+			return err
+		}
+	}
+
+	return WriteVarBytes(w, 0, p.Sig)
+}
+
+// SerializeSize returns the number of bytes it would take to serialize the
+// payload.
+func (p *ProRegTxPayload) SerializeSize() int {
+	// Version 2 bytes + type 2 bytes + mode 2 bytes + collateral 36
+	// bytes + service 18 bytes + keys 88 bytes + operator reward 2 bytes
+	// + payout script + inputs hash 32 bytes + signature.
+	n := 182 + VarIntSerializeSize(uint64(len(p.ScriptPayout))) +
+		len(p.ScriptPayout) + VarIntSerializeSize(uint64(len(p.Sig))) +
+		len(p.Sig)
+	if p.MasternodeType == MasternodeTypeEvo {
+		n += KeyIDSize + 4
+	}
+	return n
+}
+
+// ProUpServTxPayload represents the extra payload of a provider update service
+// special transaction (type 2) as defined by DIP0003.  It is signed by the
+// operator to update the service address of a masternode.
+type ProUpServTxPayload struct {
+	Version              uint16
+	MasternodeType       MasternodeType
+	ProTxHash            chainhash.Hash
+	IP                   net.IP
+	Port                 uint16
+	ScriptOperatorPayout []byte
+	InputsHash           chainhash.Hash
+	Platform             PlatformInfo
+	Sig                  [BLSSigSize]byte
+}
+
+// Deserialize decodes a provider update service payload from r into the
+// receiver.
+func (p *ProUpServTxPayload) Deserialize(r io.Reader) error {
+	var err error
+	p.Version, err = binarySerializer.Uint16(r, littleEndian)
+	if err != nil {
+		return err
+	}
+	err = checkProTxVersion(p.Version, "ProUpServTxPayload.Deserialize")
+	if err != nil {
+		return err
+	}
+
+	p.MasternodeType = MasternodeTypeRegular
+	if p.Version >= ProTxVersionBasicBLS {
+		mnType, err := binarySerializer.Uint16(r, littleEndian)
+		if err != nil {
+			return err
+		}
+		p.MasternodeType = MasternodeType(mnType)
+	}
+
+	err = readElement(r, &p.ProTxHash)
+	if err != nil {
+		return err
+	}
+
+	p.IP, p.Port, err = readProTxService(r)
+	if err != nil {
+		return err
+	}
+
+	p.ScriptOperatorPayout, err = ReadVarBytes(r, 0, MaxTxExtraPayload,
+		"operator payout script")
+	if err != nil {
+		return err
+	}
+
+	err = readElement(r, &p.InputsHash)
+	if err != nil {
+		return err
+	}
+
+	if p.MasternodeType == MasternodeTypeEvo {
+		err = readPlatformInfo(r, &p.Platform)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = io.ReadFull(r, p.Sig[:])
+	return err
+}
+
+// Serialize encodes the receiver to w.
+func (p *ProUpServTxPayload) Serialize(w io.Writer) error {
+	err := binarySerializer.PutUint16(w, littleEndian, p.Version)
+	if err != nil {
+		return err
+	}
+
+	if p.Version >= ProTxVersionBasicBLS {
+		err = binarySerializer.PutUint16(w, littleEndian,
+			uint16(p.MasternodeType))
+		if err != nil {
+			return err
+		}
+	}
+
+	err = writeElement(w, &p.ProTxHash)
+	if err != nil {
+		return err
+	}
+
+	err = writeProTxService(w, p.IP, p.Port)
+	if err != nil {
+		return err
+	}
+
+	err = WriteVarBytes(w, 0, p.ScriptOperatorPayout)
+	if err != nil {
+		return err
+	}
+
+	err = writeElement(w, &p.InputsHash)
+	if err != nil {
+		return err
+	}
+
+	if p.MasternodeType == MasternodeTypeEvo {
+		err = writePlatformInfo(w, &p.Platform)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = w.Write(p.Sig[:])
+	return err
+}
+
+// SerializeSize returns the number of bytes it would take to serialize the
+// payload.
+func (p *ProUpServTxPayload) SerializeSize() int {
+	// Version 2 bytes + ProTxHash 32 bytes + service 18 bytes + operator
+	// payout script + inputs hash 32 bytes + signature 96 bytes.
+	n := 180 + VarIntSerializeSize(uint64(len(p.ScriptOperatorPayout))) +
+		len(p.ScriptOperatorPayout)
+	if p.Version >= ProTxVersionBasicBLS {
+		n += 2
+	}
+	if p.MasternodeType == MasternodeTypeEvo {
+		n += KeyIDSize + 4
+	}
+	return n
+}
+
+// ProUpRegTxPayload represents the extra payload of a provider update
+// registrar special transaction (type 3) as defined by DIP0003.  It is signed
+// by the owner to update the operator, voting key and payout script of a
+// masternode.
+type ProUpRegTxPayload struct {
+	Version        uint16
+	ProTxHash      chainhash.Hash
+	Mode           uint16
+	PubKeyOperator [BLSPubKeySize]byte
+	KeyIDVoting    [KeyIDSize]byte
+	ScriptPayout   []byte
+	InputsHash     chainhash.Hash
+	Sig            []byte
+}
+
+// Deserialize decodes a provider update registrar payload from r into the
+// receiver.
+func (p *ProUpRegTxPayload) Deserialize(r io.Reader) error {
+	var err error
+	p.Version, err = binarySerializer.Uint16(r, littleEndian)
+	if err != nil {
+		return err
+	}
+	err = checkProTxVersion(p.Version, "ProUpRegTxPayload.Deserialize")
+	if err != nil {
+		return err
+	}
+
+	err = readElement(r, &p.ProTxHash)
+	if err != nil {
+		return err
+	}
+
+	p.Mode, err = binarySerializer.Uint16(r, littleEndian)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.ReadFull(r, p.PubKeyOperator[:]); err != nil {
+		return err
+	}
+	if _, err := io.ReadFull(r, p.KeyIDVoting[:]); err != nil {
+		return err
+	}
+
+	p.ScriptPayout, err = ReadVarBytes(r, 0, MaxTxExtraPayload,
+		"payout script")
+	if err != nil {
+		return err
+	}
+
+	err = readElement(r, &p.InputsHash)
+	if err != nil {
+		return err
+	}
+
+	p.Sig, err = ReadVarBytes(r, 0, MaxTxExtraPayload,
+		"provider update registrar signature")
+	return err
+}
+
+// Serialize encodes the receiver to w.
+func (p *ProUpRegTxPayload) Serialize(w io.Writer) error {
+	err := binarySerializer.PutUint16(w, littleEndian, p.Version)
+	if err != nil {
+		return err
+	}
+
+	err = writeElement(w, &p.ProTxHash)
+	if err != nil {
+		return err
+	}
+
+	err = binarySerializer.PutUint16(w, littleEndian, p.Mode)
+	if err != nil {
+		return err
+	}
+
+	if _, err := w.Write(p.PubKeyOperator[:]); err != nil {
+		return err
+	}
+	if _, err := w.Write(p.KeyIDVoting[:]); err != nil {
+		return err
+	}
+
+	err = WriteVarBytes(w, 0, p.ScriptPayout)
+	if err != nil {
+		return err
+	}
+
+	err = writeElement(w, &p.InputsHash)
+	if err != nil {
+		return err
+	}
+
+	return WriteVarBytes(w, 0, p.Sig)
+}
+
+// SerializeSize returns the number of bytes it would take to serialize the
+// payload.
+func (p *ProUpRegTxPayload) SerializeSize() int {
+	// Version 2 bytes + ProTxHash 32 bytes + mode 2 bytes + keys 68 bytes
+	// + payout script + inputs hash 32 bytes + signature.
+	return 136 + VarIntSerializeSize(uint64(len(p.ScriptPayout))) +
+		len(p.ScriptPayout) + VarIntSerializeSize(uint64(len(p.Sig))) +
+		len(p.Sig)
+}
+
+// ProUpRevTxPayload represents the extra payload of a provider update revoke
+// special transaction (type 4) as defined by DIP0003.  It is signed by the
+// operator to revoke its key and put the masternode into the banned state.
+type ProUpRevTxPayload struct {
+	Version    uint16
+	ProTxHash  chainhash.Hash
+	Reason     uint16
+	InputsHash chainhash.Hash
+	Sig        [BLSSigSize]byte
+}
+
+// Deserialize decodes a provider update revoke payload from r into the
+// receiver.
+func (p *ProUpRevTxPayload) Deserialize(r io.Reader) error {
+	var err error
+	p.Version, err = binarySerializer.Uint16(r, littleEndian)
+	if err != nil {
+		return err
+	}
+	err = checkProTxVersion(p.Version, "ProUpRevTxPayload.Deserialize")
+	if err != nil {
+		return err
+	}
+
+	err = readElement(r, &p.ProTxHash)
+	if err != nil {
+		return err
+	}
+
+	p.Reason, err = binarySerializer.Uint16(r, littleEndian)
+	if err != nil {
+		return err
+	}
+
+	err = readElement(r, &p.InputsHash)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.ReadFull(r, p.Sig[:])
+	return err
+}
+
+// Serialize encodes the receiver to w.
+func (p *ProUpRevTxPayload) Serialize(w io.Writer) error {
+	err := binarySerializer.PutUint16(w, littleEndian, p.Version)
+	if err != nil {
+		return err
+	}
+
+	err = writeElement(w, &p.ProTxHash)
+	if err != nil {
+		return err
+	}
+
+	err = binarySerializer.PutUint16(w, littleEndian, p.Reason)
+	if err != nil {
+		return err
+	}
+
+	err = writeElement(w, &p.InputsHash)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(p.Sig[:])
+	return err
+}
+
+// SerializeSize returns the number of bytes it would take to serialize the
+// payload.
+func (p *ProUpRevTxPayload) SerializeSize() int {
+	// Version 2 bytes + ProTxHash 32 bytes + reason 2 bytes + inputs hash
+	// 32 bytes + signature 96 bytes.
+	return 164
+}
+
+// decodeProTxPayload decodes the extra payload of the passed provider
+// transaction into payload after ensuring the transaction is of the expected
+// type.
+func decodeProTxPayload(msg *MsgTx, txType TxType, payload interface {
+	Deserialize(io.Reader) error
+}, op string) error {
+
+	if !msg.IsSpecial() || msg.Type != txType {
+		str := fmt.Sprintf("transaction type %v is not %v", msg.Type,
+			txType)
+		return messageError(op, str)
+	}
+
+	r := bytes.NewReader(msg.Payload)
+	if err := payload.Deserialize(r); err != nil {
+		return err
+	}
+	if r.Len() != 0 {
+		str := fmt.Sprintf("%d trailing bytes after %v payload",
+			r.Len(), txType)
+		return messageError(op, str)
+	}
+	return nil
+}
+
+// ProRegTxPayload decodes and returns the extra payload of a provider
+// registration transaction.  An error is returned when the transaction is not
+// a provider registration or the payload is malformed.
+func (msg *MsgTx) ProRegTxPayload() (*ProRegTxPayload, error) {
+	var payload ProRegTxPayload
+	err := decodeProTxPayload(msg, TxTypeProRegTx, &payload,
+		"MsgTx.ProRegTxPayload")
+	if err != nil {
+		return nil, err
+	}
+	return &payload, nil
+}
+
+// ProUpServTxPayload decodes and returns the extra payload of a provider
+// update service transaction.  An error is returned when the transaction is
+// not a provider update service or the payload is malformed.
+func (msg *MsgTx) ProUpServTxPayload() (*ProUpServTxPayload, error) {
+	var payload ProUpServTxPayload
+	err := decodeProTxPayload(msg, TxTypeProUpServTx, &payload,
+		"MsgTx.ProUpServTxPayload")
+	if err != nil {
+		return nil, err
+	}
+	return &payload, nil
+}
+
+// ProUpRegTxPayload decodes and returns the extra payload of a provider
+// update registrar transaction.  An error is returned when the transaction is
+// not a provider update registrar or the payload is malformed.
+func (msg *MsgTx) ProUpRegTxPayload() (*ProUpRegTxPayload, error) {
+	var payload ProUpRegTxPayload
+	err := decodeProTxPayload(msg, TxTypeProUpRegTx, &payload,
+		"MsgTx.ProUpRegTxPayload")
+	if err != nil {
+		return nil, err
+	}
+	return &payload, nil
+}
+
+// ProUpRevTxPayload decodes and returns the extra payload of a provider
+// update revoke transaction.  An error is returned when the transaction is not
+// a provider update revoke or the payload is malformed.
+func (msg *MsgTx) ProUpRevTxPayload() (*ProUpRevTxPayload, error) {
+	var payload ProUpRevTxPayload
+	err := decodeProTxPayload(msg, TxTypeProUpRevTx, &payload,
+		"MsgTx.ProUpRevTxPayload")
+	if err != nil {
+		return nil, err
+	}
+	return &payload, nil
+}
@@ -0,0 +1,323 @@
+// Copyright (c) 2013-2016 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/eager7/dashd/chaincfg/chainhash"
+)
+
+const (
+	// QuorumCommitmentPayloadVersion is the current version of the quorum
+	// commitment special transaction payload.
+	QuorumCommitmentPayloadVersion = 1
+
+	// maxQuorumMembers is the maximum number of members of a quorum whose
+	// signer and valid member bit sets are accepted.
+	maxQuorumMembers = 1000
+)
+
+// These constants define the versions of a final quorum commitment.  Versions
+// 1 and 2 carry keys and signatures of the legacy BLS scheme while versions 3
+// and 4 carry those of the basic scheme.  Even versions belong to rotated
+// quorums and carry a quorum index.
+const (
+	QuorumCommitmentVersionLegacy        = 1
+	QuorumCommitmentVersionLegacyIndexed = 2
+	QuorumCommitmentVersionBasic         = 3
+	QuorumCommitmentVersionBasicIndexed  = 4
+)
+
+// QuorumCommitment is the final commitment of a long living masternode quorum
+// (LLMQ) as defined by DIP0006.  It commits to the members which took part in
+// the distributed key generation and to the resulting quorum public key.
+type QuorumCommitment struct {
+	Version         uint16
+	LLMQType        uint8
+	QuorumHash      chainhash.Hash
+	QuorumIndex     int16
+	Signers         []bool
+	ValidMembers    []bool
+	QuorumPublicKey [BLSPubKeySize]byte
+	QuorumVvecHash  chainhash.Hash
+	QuorumSig       [BLSSigSize]byte
+	MembersSig      [BLSSigSize]byte
+}
+
+// HasQuorumIndex returns whether the commitment carries a quorum index, which
+// is the case for the commitments of rotated quorums.
+func (c *QuorumCommitment) HasQuorumIndex() bool {
+	return c.Version == QuorumCommitmentVersionLegacyIndexed ||
+		c.Version == QuorumCommitmentVersionBasicIndexed
+}
+
+// IsLegacy returns whether the keys and signatures of the commitment belong to
+// the legacy BLS scheme.
+func (c *QuorumCommitment) IsLegacy() bool {
+	return c.Version == QuorumCommitmentVersionLegacy ||
+		c.Version == QuorumCommitmentVersionLegacyIndexed
+}
+
+// IsNull returns whether the commitment is a null commitment, which miners
+// include when a quorum failed to form.  Null commitments have no signers.
+func (c *QuorumCommitment) IsNull() bool {
+	for _, signer := range c.Signers {
+		if signer {
+			return false
+		}
+	}
+
+	return true
+}
+
+// readBitSet reads a bit set which is encoded as the number of bits followed
+// by the bits packed into bytes with the least significant bit first.
+func readBitSet(r io.Reader, fieldName string) ([]bool, error) {
+	count, err := ReadVarInt(r, 0)
+	if err != nil {
+		return nil, err
+	}
+	if count > maxQuorumMembers {
+		str := fmt.Sprintf("%s bit set has %d bits which is more than "+
+			"the max allowed of %d", fieldName, count,
+			maxQuorumMembers)
+		return nil, messageError("readBitSet", str)
+	}
+
+	packed := make([]byte, (count+7)/8)
+	if _, err := io.ReadFull(r, packed); err != nil {
+		return nil, err
+	}
+	bits := make([]bool, count)
+	for i := range bits {
+		bits[i] = packed[i/8]&(1<<uint(i%8)) != 0
+	}
+
+	return bits, nil
+}
+
+// writeBitSet writes a bit set in the encoding read by readBitSet.
+func writeBitSet(w io.Writer, bits []bool) error {
+	err := WriteVarInt(w, 0, uint64(len(bits)))
+	if err != nil {
+		return err
+	}
+
+	packed := make([]byte, (len(bits)+7)/8)
+	for i, bit := range bits {
+		if bit {
+			packed[i/8] |= 1 << uint(i%8)
+		}
+	}
+	_, err = w.Write(packed)
+	return err
+}
+
+// bitSetSerializeSize returns the number of bytes it would take to serialize
+// a bit set with the passed number of bits.
+func bitSetSerializeSize(count int) int {
+	return VarIntSerializeSize(uint64(count)) + (count+7)/8
+}
+
+// Deserialize decodes a final quorum commitment from r into the receiver.
+func (c *QuorumCommitment) Deserialize(r io.Reader) error {
+	var err error
+	c.Version, err = binarySerializer.Uint16(r, littleEndian)
+	if err != nil {
+		return err
+	}
+
+	c.LLMQType, err = binarySerializer.Uint8(r)
+	if err != nil {
+		return err
+	}
+
+	err = readElement(r, &c.QuorumHash)
+	if err != nil {
+		return err
+	}
+
+	c.QuorumIndex = 0
+	if c.HasQuorumIndex() {
+		index, err := binarySerializer.Uint16(r, littleEndian)
+		if err != nil {
+			return err
+		}
+		c.QuorumIndex = int16(index)
+	}
+
+	c.Signers, err = readBitSet(r, "signers")
+	if err != nil {
+		return err
+	}
+
+	c.ValidMembers, err = readBitSet(r, "valid members")
+	if err != nil {
+		return err
+	}
+
+	_, err = io.ReadFull(r, c.QuorumPublicKey[:])
+	if err != nil {
+		return err
+	}
+
+	err = readElement(r, &c.QuorumVvecHash)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.ReadFull(r, c.QuorumSig[:])
+	if err != nil {
+		return err
+	}
+
+	_, err = io.ReadFull(r, c.MembersSig[:])
+	return err
+}
+
+// Serialize encodes the receiver to w.
+func (c *QuorumCommitment) Serialize(w io.Writer) error {
+	err := binarySerializer.PutUint16(w, littleEndian, c.Version)
+	if err != nil {
+		return err
+	}
+
+	err = binarySerializer.PutUint8(w, c.LLMQType)
+	if err != nil {
+		return err
+	}
+
+	err = writeElement(w, &c.QuorumHash)
+	if err != nil {
+		return err
+	}
+
+	if c.HasQuorumIndex() {
+		err = binarySerializer.PutUint16(w, littleEndian,
+			uint16(c.QuorumIndex))
+		if err != nil {
+			return err
+		}
+	}
+
+	err = writeBitSet(w, c.Signers)
+	if err != nil {
+		return err
+	}
+
+	err = writeBitSet(w, c.ValidMembers)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(c.QuorumPublicKey[:])
+	if err != nil {
+		return err
+	}
+
+	err = writeElement(w, &c.QuorumVvecHash)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(c.QuorumSig[:])
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(c.MembersSig[:])
+	return err
+}
+
+// SerializeSize returns the number of bytes it would take to serialize the
+// commitment.
+func (c *QuorumCommitment) SerializeSize() int {
+	// Version 2 bytes + LLMQType 1 byte + QuorumHash 32 bytes + bit sets +
+	// QuorumPublicKey 48 bytes + QuorumVvecHash 32 bytes + QuorumSig and
+	// MembersSig 96 bytes each.
+	n := 2 + 1 + chainhash.HashSize + bitSetSerializeSize(len(c.Signers)) +
+		bitSetSerializeSize(len(c.ValidMembers)) + BLSPubKeySize +
+		chainhash.HashSize + 2*BLSSigSize
+	if c.HasQuorumIndex() {
+		// QuorumIndex 2 bytes.
+		n += 2
+	}
+
+	return n
+}
+
+// QuorumCommitmentPayload represents the extra payload of a quorum commitment
+// special transaction (type 6) as defined by DIP0006.  Miners include it to
+// put the final commitment of a quorum on chain.
+type QuorumCommitmentPayload struct {
+	Version    uint16
+	Height     uint32
+	Commitment QuorumCommitment
+}
+
+// Deserialize decodes a quorum commitment payload from r into the receiver.
+func (p *QuorumCommitmentPayload) Deserialize(r io.Reader) error {
+	var err error
+	p.Version, err = binarySerializer.Uint16(r, littleEndian)
+	if err != nil {
+		return err
+	}
+
+	p.Height, err = binarySerializer.Uint32(r, littleEndian)
+	if err != nil {
+		return err
+	}
+
+	return p.Commitment.Deserialize(r)
+}
+
+// Serialize encodes the receiver to w.
+func (p *QuorumCommitmentPayload) Serialize(w io.Writer) error {
+	err := binarySerializer.PutUint16(w, littleEndian, p.Version)
+	if err != nil {
+		return err
+	}
+
+	err = binarySerializer.PutUint32(w, littleEndian, p.Height)
+	if err != nil {
+		return err
+	}
+
+	return p.Commitment.Serialize(w)
+}
+
+// SerializeSize returns the number of bytes it would take to serialize the
+// payload.
+func (p *QuorumCommitmentPayload) SerializeSize() int {
+	// Version 2 bytes + Height 4 bytes + commitment.
+	return 2 + 4 + p.Commitment.SerializeSize()
+}
+
+// QuorumCommitmentPayload decodes and returns the extra payload of a quorum
+// commitment transaction.  An error is returned when the transaction is not a
+// quorum commitment or the payload is malformed.
+func (msg *MsgTx) QuorumCommitmentPayload() (*QuorumCommitmentPayload, error) {
+	if !msg.IsSpecial() || msg.Type != TxTypeQuorumCommitment {
+		str := fmt.Sprintf("transaction type %v is not a quorum "+
+			"commitment", msg.Type)
+		return nil, messageError("MsgTx.QuorumCommitmentPayload", str)
+	}
+
+	var payload QuorumCommitmentPayload
+	r := bytes.NewReader(msg.Payload)
+	if err := payload.Deserialize(r); err != nil {
+		return nil, err
+	}
+	if r.Len() != 0 {
+		str := fmt.Sprintf("%d trailing bytes after quorum commitment "+
+			"payload", r.Len())
+		return nil, messageError("MsgTx.QuorumCommitmentPayload", str)
+	}
+
+	return &payload, nil
+}
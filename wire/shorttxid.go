@@ -0,0 +1,100 @@
+// Copyright (c) 2016 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"math/bits"
+
+	"github.com/eager7/dashd/chaincfg/chainhash"
+)
+
+// ShortTxIDLen is the number of bytes of the short transaction IDs used by
+// compact blocks (BIP0152).
+const ShortTxIDLen = 6
+
+// shortTxIDMask is the mask applied to the SipHash of a transaction hash to
+// produce its short transaction ID.
+const shortTxIDMask = 1<<(8*ShortTxIDLen) - 1
+
+// sipRound performs a SipHash round on the passed state.
+func sipRound(v0, v1, v2, v3 uint64) (uint64, uint64, uint64, uint64) {
+	v0 += v1
+	v1 = bits.RotateLeft64(v1, 13)
+	v1 ^= v0
+	v0 = bits.RotateLeft64(v0, 32)
+	v2 += v3
+	v3 = bits.RotateLeft64(v3, 16)
+	v3 ^= v2
+	v0 += v3
+	v3 = bits.RotateLeft64(v3, 21)
+	v3 ^= v0
+	v2 += v1
+	v1 = bits.RotateLeft64(v1, 17)
+	v1 ^= v2
+	v2 = bits.RotateLeft64(v2, 32)
+	return v0, v1, v2, v3
+}
+
+// sipHash24 returns the SipHash-2-4 of the passed data with the 128-bit key
+// made of k0 and k1.
+func sipHash24(k0, k1 uint64, data []byte) uint64 {
+	v0 := k0 ^ 0x736f6d6570736575
+	v1 := k1 ^ 0x646f72616e646f6d
+	v2 := k0 ^ 0x6c7967656e657261
+	v3 := k1 ^ 0x7465646279746573
+
+	// Compress all full 8 byte words.
+	length := len(data)
+	for len(data) >= 8 {
+		m := binary.LittleEndian.Uint64(data)
+		v3 ^= m
+		v0, v1, v2, v3 = sipRound(v0, v1, v2, v3)
+		v0, v1, v2, v3 = sipRound(v0, v1, v2, v3)
+		v0 ^= m
+		data = data[8:]
+	}
+
+	// The final word holds the remaining bytes along with the length of
+	// the data in its most significant byte.
+	m := uint64(length) << 56
+	for i, b := range data {
+		m |= uint64(b) << (8 * uint(i))
+	}
+	v3 ^= m
+	v0, v1, v2, v3 = sipRound(v0, v1, v2, v3)
+	v0, v1, v2, v3 = sipRound(v0, v1, v2, v3)
+	v0 ^= m
+
+	v2 ^= 0xff
+	for i := 0; i < 4; i++ {
+		v0, v1, v2, v3 = sipRound(v0, v1, v2, v3)
+	}
+	return v0 ^ v1 ^ v2 ^ v3
+}
+
+// ShortTxIDKeys returns the SipHash keys used to compute the short transaction
+// IDs of a compact block with the passed header and nonce.  They are the first
+// two little endian 64-bit integers of the single SHA256 of the serialized
+// header followed by the little endian nonce.
+func ShortTxIDKeys(header *BlockHeader, nonce uint64) (uint64, uint64) {
+	h := sha256.New()
+	writeBlockHeader(h, 0, header)
+	var nonceBytes [8]byte
+	binary.LittleEndian.PutUint64(nonceBytes[:], nonce)
+	h.Write(nonceBytes[:])
+	sum := h.Sum(nil)
+
+	return binary.LittleEndian.Uint64(sum[0:8]),
+		binary.LittleEndian.Uint64(sum[8:16])
+}
+
+// ShortTxID returns the short transaction ID of the transaction with the passed
+// hash for the passed SipHash keys, which is the SipHash-2-4 of the hash with
+// the two most significant bytes dropped.
+func ShortTxID(k0, k1 uint64, hash *chainhash.Hash) uint64 {
+	return sipHash24(k0, k1, hash[:]) & shortTxIDMask
+}
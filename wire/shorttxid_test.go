@@ -0,0 +1,63 @@
+// Copyright (c) 2016 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"testing"
+
+	"github.com/eager7/dashd/chaincfg/chainhash"
+)
+
+// TestSipHash24 ensures SipHash-2-4 matches the test vectors of the reference
+// implementation, which use the key 00..0f and the messages 00..n-1.
+func TestSipHash24(t *testing.T) {
+	tests := []struct {
+		n    int
+		want uint64
+	}{
+		{0, 0x726fdb47dd0e0e31},
+		{8, 0x93f5f5799a932462},
+		{15, 0xa129ca6149be45e5},
+		{32, 0x7127512f72f27cce},
+	}
+
+	k0, k1 := uint64(0x0706050403020100), uint64(0x0f0e0d0c0b0a0908)
+	for _, test := range tests {
+		data := make([]byte, test.n)
+		for i := range data {
+			data[i] = byte(i)
+		}
+		if got := sipHash24(k0, k1, data); got != test.want {
+			t.Errorf("sipHash24 of %d bytes: got %#x, want %#x",
+				test.n, got, test.want)
+		}
+	}
+}
+
+// TestShortTxID ensures short transaction IDs are the 48 least significant
+// bits of the SipHash of the transaction hash keyed by the header and nonce.
+func TestShortTxID(t *testing.T) {
+	txHash := blockOne.Transactions[0].TxHash()
+
+	k0, k1 := ShortTxIDKeys(&blockOne.Header, 0)
+	id := ShortTxID(k0, k1, &txHash)
+	if id>>(8*ShortTxIDLen) != 0 {
+		t.Errorf("ShortTxID: %#x is longer than %d bytes", id,
+			ShortTxIDLen)
+	}
+	if want := sipHash24(k0, k1, txHash[:]) & shortTxIDMask; id != want {
+		t.Errorf("ShortTxID: got %#x, want %#x", id, want)
+	}
+
+	// A different nonce or transaction yields a different short ID.
+	k0, k1 = ShortTxIDKeys(&blockOne.Header, 1)
+	if ShortTxID(k0, k1, &txHash) == id {
+		t.Errorf("ShortTxID: same ID %#x for different nonces", id)
+	}
+	var otherHash chainhash.Hash
+	if ShortTxID(k0, k1, &otherHash) == ShortTxID(k0, k1, &txHash) {
+		t.Errorf("ShortTxID: same ID for different transactions")
+	}
+}
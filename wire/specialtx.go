@@ -0,0 +1,79 @@
+// Copyright (c) 2013-2016 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"fmt"
+)
+
+const (
+	// SpecialTxVersion is the first transaction version which is able to
+	// carry a special transaction type and extra payload as defined by
+	// DIP0002.
+	SpecialTxVersion = 3
+
+	// MaxTxExtraPayload is the maximum number of bytes allowed for the
+	// extra payload of a special transaction.
+	MaxTxExtraPayload = 10000
+)
+
+// TxType identifies the type of a special transaction as defined by DIP0002.
+// The type is encoded in the upper 16 bits of the transaction version field.
+type TxType uint16
+
+// These constants define the known special transaction types.
+const (
+	TxTypeNormal           TxType = 0
+	TxTypeProRegTx         TxType = 1
+	TxTypeProUpServTx      TxType = 2
+	TxTypeProUpRegTx       TxType = 3
+	TxTypeProUpRevTx       TxType = 4
+	TxTypeCoinBase         TxType = 5
+	TxTypeQuorumCommitment TxType = 6
+	TxTypeMnHfSignal       TxType = 7
+	TxTypeAssetLock        TxType = 8
+	TxTypeAssetUnlock      TxType = 9
+)
+
+// Map of transaction types back to their constant names for pretty printing.
+var txTypeStrings = map[TxType]string{
+	TxTypeNormal:           "TxTypeNormal",
+	TxTypeProRegTx:         "TxTypeProRegTx",
+	TxTypeProUpServTx:      "TxTypeProUpServTx",
+	TxTypeProUpRegTx:       "TxTypeProUpRegTx",
+	TxTypeProUpRevTx:       "TxTypeProUpRevTx",
+	TxTypeCoinBase:         "TxTypeCoinBase",
+	TxTypeQuorumCommitment: "TxTypeQuorumCommitment",
+	TxTypeMnHfSignal:       "TxTypeMnHfSignal",
+	TxTypeAssetLock:        "TxTypeAssetLock",
+	TxTypeAssetUnlock:      "TxTypeAssetUnlock",
+}
+
+// String returns the TxType in human-readable form.
+func (t TxType) String() string {
+	if s, ok := txTypeStrings[t]; ok {
+		return s
+	}
+
+	return fmt.Sprintf("Unknown TxType (%d)", uint16(t))
+}
+
+// IsSpecial returns whether or not the transaction is a special transaction,
+// that is, its version supports special transactions and its type is not the
+// normal type.  Only special transactions carry an extra payload.
+func (msg *MsgTx) IsSpecial() bool {
+	return msg.Version >= SpecialTxVersion && msg.Type != TxTypeNormal
+}
+
+// splitTxVersion splits a raw 32-bit version field into the 16-bit
+// transaction version and the special transaction type as defined by DIP0002.
+func splitTxVersion(raw uint32) (int32, TxType) {
+	return int32(int16(raw & 0xffff)), TxType(raw >> 16)
+}
+
+// joinTxVersion is the inverse of splitTxVersion.
+func joinTxVersion(version int32, txType TxType) uint32 {
+	return uint32(uint16(version)) | uint32(txType)<<16
+}
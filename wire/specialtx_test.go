@@ -0,0 +1,569 @@
+// Copyright (c) 2013-2016 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/davecgh/go-spew/spew"
+	"github.com/eager7/dashd/chaincfg/chainhash"
+)
+
+// TestSpecialTxWire tests the wire encode and decode of special transactions
+// which carry a type in the upper bits of the version and an extra payload.
+func TestSpecialTxWire(t *testing.T) {
+	lockPayload := AssetLockPayload{
+		Version: AssetLockPayloadVersion,
+		CreditOutputs: []*TxOut{{
+			Value: 100000000,
+			PkScript: []byte{
+				0x76, 0xa9, 0x14, 0x01, 0x02, 0x03, 0x04, 0x05,
+				0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d,
+				0x0e, 0x0f, 0x10, 0x11, 0x12, 0x13, 0x14, 0x88,
+				0xac,
+			},
+		}},
+	}
+	var buf bytes.Buffer
+	if err := lockPayload.Serialize(&buf); err != nil {
+		t.Fatalf("AssetLockPayload.Serialize: %v", err)
+	}
+	if buf.Len() != lockPayload.SerializeSize() {
+		t.Fatalf("AssetLockPayload.SerializeSize: got %d, want %d",
+			lockPayload.SerializeSize(), buf.Len())
+	}
+	lockTx := &MsgTx{
+		Version: SpecialTxVersion,
+		Type:    TxTypeAssetLock,
+		TxIn: []*TxIn{{
+			PreviousOutPoint: OutPoint{Index: 1},
+			SignatureScript:  []byte{0x51},
+			Sequence:         MaxTxInSequenceNum,
+		}},
+		TxOut: []*TxOut{{
+			Value:    100000000,
+			PkScript: []byte{0x6a, 0x00},
+		}},
+		Payload: buf.Bytes(),
+	}
+
+	unlockPayload := AssetUnlockPayload{
+		Version:         AssetUnlockPayloadVersion,
+		Index:           101,
+		Fee:             70000,
+		RequestedHeight: 1000,
+		QuorumHash:      mainNetGenesisHash,
+	}
+	unlockPayload.QuorumSig[0] = 0x8a
+	buf = bytes.Buffer{}
+	if err := unlockPayload.Serialize(&buf); err != nil {
+		t.Fatalf("AssetUnlockPayload.Serialize: %v", err)
+	}
+	if buf.Len() != unlockPayload.SerializeSize() {
+		t.Fatalf("AssetUnlockPayload.SerializeSize: got %d, want %d",
+			unlockPayload.SerializeSize(), buf.Len())
+	}
+	unlockTx := &MsgTx{
+		Version: SpecialTxVersion,
+		Type:    TxTypeAssetUnlock,
+		TxIn:    []*TxIn{},
+		TxOut: []*TxOut{{
+			Value:    lockTx.TxOut[0].Value,
+			PkScript: lockPayload.CreditOutputs[0].PkScript,
+		}},
+		Payload: buf.Bytes(),
+	}
+
+	tests := []struct {
+		tx      *MsgTx
+		rawHead []byte // Expected leading bytes
+	}{
+		{lockTx, []byte{0x03, 0x00, 0x08, 0x00, 0x01}},
+		// Asset unlocks have no inputs which must not be confused with
+		// the witness marker.
+		{unlockTx, []byte{0x03, 0x00, 0x09, 0x00, 0x00, 0x01}},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		var buf bytes.Buffer
+		if err := test.tx.Serialize(&buf); err != nil {
+			t.Errorf("Serialize #%d error %v", i, err)
+			continue
+		}
+		if !bytes.HasPrefix(buf.Bytes(), test.rawHead) {
+			t.Errorf("Serialize #%d\n got: %s want prefix: %s", i,
+				spew.Sdump(buf.Bytes()), spew.Sdump(test.rawHead))
+			continue
+		}
+		if buf.Len() != test.tx.SerializeSize() {
+			t.Errorf("SerializeSize #%d: got %d, want %d", i,
+				test.tx.SerializeSize(), buf.Len())
+			continue
+		}
+
+		var tx MsgTx
+		if err := tx.Deserialize(&buf); err != nil {
+			t.Errorf("Deserialize #%d error %v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(&tx, test.tx) {
+			t.Errorf("Deserialize #%d\n got: %s want: %s", i,
+				spew.Sdump(&tx), spew.Sdump(test.tx))
+			continue
+		}
+		if !reflect.DeepEqual(tx.Copy(), test.tx) {
+			t.Errorf("Copy #%d\n got: %s want: %s", i,
+				spew.Sdump(tx.Copy()), spew.Sdump(test.tx))
+			continue
+		}
+	}
+
+	gotLock, err := lockTx.AssetLockPayload()
+	if err != nil {
+		t.Fatalf("AssetLockPayload: %v", err)
+	}
+	if !reflect.DeepEqual(gotLock, &lockPayload) {
+		t.Errorf("AssetLockPayload\n got: %s want: %s",
+			spew.Sdump(gotLock), spew.Sdump(&lockPayload))
+	}
+	gotUnlock, err := unlockTx.AssetUnlockPayload()
+	if err != nil {
+		t.Fatalf("AssetUnlockPayload: %v", err)
+	}
+	if !reflect.DeepEqual(gotUnlock, &unlockPayload) {
+		t.Errorf("AssetUnlockPayload\n got: %s want: %s",
+			spew.Sdump(gotUnlock), spew.Sdump(&unlockPayload))
+	}
+
+	// Decoding the payload of the wrong type must fail.
+	if _, err := lockTx.AssetUnlockPayload(); err == nil {
+		t.Errorf("AssetUnlockPayload: did not fail on asset lock")
+	}
+}
+
+// TestCoinbasePayload tests the versioned serialization of the coinbase
+// special transaction payload.
+func TestCoinbasePayload(t *testing.T) {
+	tests := []struct {
+		payload CoinbasePayload
+		size    int
+	}{
+		{CoinbasePayload{Version: 1, Height: 10}, 38},
+		{CoinbasePayload{Version: 2, Height: 20}, 70},
+		{CoinbasePayload{
+			Version:           3,
+			Height:            30,
+			BestCLHeightDiff:  1,
+			CreditPoolBalance: 500000000,
+		}, 175},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		var buf bytes.Buffer
+		if err := test.payload.Serialize(&buf); err != nil {
+			t.Errorf("Serialize #%d error %v", i, err)
+			continue
+		}
+		if buf.Len() != test.size ||
+			test.payload.SerializeSize() != test.size {
+
+			t.Errorf("Serialize #%d: got size %d (%d), want %d", i,
+				buf.Len(), test.payload.SerializeSize(),
+				test.size)
+			continue
+		}
+
+		tx := MsgTx{
+			Version: SpecialTxVersion,
+			Type:    TxTypeCoinBase,
+			Payload: buf.Bytes(),
+		}
+		got, err := tx.CoinbasePayload()
+		if err != nil {
+			t.Errorf("CoinbasePayload #%d error %v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, &test.payload) {
+			t.Errorf("CoinbasePayload #%d\n got: %s want: %s", i,
+				spew.Sdump(got), spew.Sdump(&test.payload))
+			continue
+		}
+	}
+}
+
+// TestMnHfTxPayload tests the serialization of the masternode hard fork signal
+// special transaction payload through the wire encoding of a transaction.
+func TestMnHfTxPayload(t *testing.T) {
+	payload := MnHfTxPayload{
+		Version: MnHfTxPayloadVersion,
+		Signal: MnHfSignal{
+			VersionBit: 10,
+			QuorumHash: chainhash.Hash{0x01, 0x02},
+			Sig:        [QuorumSigSize]byte{0x03, 0x04},
+		},
+	}
+	var buf bytes.Buffer
+	if err := payload.Serialize(&buf); err != nil {
+		t.Fatalf("Serialize: unexpected error %v", err)
+	}
+	if buf.Len() != payload.SerializeSize() || buf.Len() != 130 {
+		t.Fatalf("Serialize: got size %d (%d), want %d", buf.Len(),
+			payload.SerializeSize(), 130)
+	}
+
+	// Round trip a signal transaction which has neither inputs nor
+	// outputs.
+	tx := MsgTx{
+		Version: SpecialTxVersion,
+		Type:    TxTypeMnHfSignal,
+		Payload: buf.Bytes(),
+	}
+	var txBuf bytes.Buffer
+	if err := tx.Serialize(&txBuf); err != nil {
+		t.Fatalf("Serialize tx: unexpected error %v", err)
+	}
+	var decoded MsgTx
+	err := decoded.Deserialize(bytes.NewReader(txBuf.Bytes()))
+	if err != nil {
+		t.Fatalf("Deserialize tx: unexpected error %v", err)
+	}
+	got, err := decoded.MnHfTxPayload()
+	if err != nil {
+		t.Fatalf("MnHfTxPayload: unexpected error %v", err)
+	}
+	if !reflect.DeepEqual(got, &payload) {
+		t.Fatalf("MnHfTxPayload\n got: %s want: %s", spew.Sdump(got),
+			spew.Sdump(&payload))
+	}
+
+	// Trailing data and the wrong transaction type must be rejected.
+	decoded.Payload = append(decoded.Payload, 0x00)
+	if _, err := decoded.MnHfTxPayload(); err == nil {
+		t.Fatal("MnHfTxPayload: did not reject trailing data")
+	}
+	decoded.Type = TxTypeAssetLock
+	if _, err := decoded.MnHfTxPayload(); err == nil {
+		t.Fatal("MnHfTxPayload: did not reject asset lock")
+	}
+}
+
+// TestQuorumCommitmentPayload tests the serialization of the quorum
+// commitment special transaction payload through the wire encoding of a
+// transaction for commitments with and without a quorum index.
+func TestQuorumCommitmentPayload(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload QuorumCommitmentPayload
+		size    int
+		null    bool
+	}{
+		{
+			name: "basic",
+			payload: QuorumCommitmentPayload{
+				Version: QuorumCommitmentPayloadVersion,
+				Height:  1000,
+				Commitment: QuorumCommitment{
+					Version:         QuorumCommitmentVersionBasic,
+					LLMQType:        100,
+					QuorumHash:      chainhash.Hash{0x01},
+					Signers:         []bool{true, false, true},
+					ValidMembers:    []bool{true, true, true},
+					QuorumPublicKey: [BLSPubKeySize]byte{0x02},
+					QuorumVvecHash:  chainhash.Hash{0x03},
+					QuorumSig:       [BLSSigSize]byte{0x04},
+					MembersSig:      [BLSSigSize]byte{0x05},
+				},
+			},
+			size: 6 + 2 + 1 + 32 + 2 + 2 + 48 + 32 + 96 + 96,
+		},
+		{
+			name: "rotated",
+			payload: QuorumCommitmentPayload{
+				Version: QuorumCommitmentPayloadVersion,
+				Height:  2000,
+				Commitment: QuorumCommitment{
+					Version:     QuorumCommitmentVersionBasicIndexed,
+					LLMQType:    103,
+					QuorumHash:  chainhash.Hash{0x06},
+					QuorumIndex: 3,
+					Signers:     make([]bool, 9),
+					ValidMembers: []bool{false, false, false,
+						false, false, false, false, false,
+						true},
+				},
+			},
+			size: 6 + 2 + 1 + 32 + 2 + 3 + 3 + 48 + 32 + 96 + 96,
+			null: true,
+		},
+	}
+
+	for _, test := range tests {
+		var buf bytes.Buffer
+		if err := test.payload.Serialize(&buf); err != nil {
+			t.Errorf("%s: Serialize: unexpected error %v", test.name,
+				err)
+			continue
+		}
+		if buf.Len() != test.payload.SerializeSize() ||
+			buf.Len() != test.size {
+
+			t.Errorf("%s: Serialize: got size %d (%d), want %d",
+				test.name, buf.Len(),
+				test.payload.SerializeSize(), test.size)
+			continue
+		}
+
+		tx := MsgTx{
+			Version: SpecialTxVersion,
+			Type:    TxTypeQuorumCommitment,
+			Payload: buf.Bytes(),
+		}
+		var txBuf bytes.Buffer
+		if err := tx.Serialize(&txBuf); err != nil {
+			t.Errorf("%s: Serialize tx: unexpected error %v",
+				test.name, err)
+			continue
+		}
+		var decoded MsgTx
+		err := decoded.Deserialize(bytes.NewReader(txBuf.Bytes()))
+		if err != nil {
+			t.Errorf("%s: Deserialize tx: unexpected error %v",
+				test.name, err)
+			continue
+		}
+		got, err := decoded.QuorumCommitmentPayload()
+		if err != nil {
+			t.Errorf("%s: QuorumCommitmentPayload: unexpected "+
+				"error %v", test.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, &test.payload) {
+			t.Errorf("%s: QuorumCommitmentPayload\n got: %s "+
+				"want: %s", test.name, spew.Sdump(got),
+				spew.Sdump(&test.payload))
+			continue
+		}
+		if got.Commitment.IsNull() != test.null {
+			t.Errorf("%s: IsNull: got %v, want %v", test.name,
+				got.Commitment.IsNull(), test.null)
+		}
+
+		decoded.Payload = append(decoded.Payload, 0x00)
+		if _, err := decoded.QuorumCommitmentPayload(); err == nil {
+			t.Errorf("%s: QuorumCommitmentPayload: did not "+
+				"reject trailing data", test.name)
+		}
+	}
+}
+
+// TestProTxPayloads tests the serialization of the provider special
+// transaction payloads through the wire encoding of a transaction.
+func TestProTxPayloads(t *testing.T) {
+	payoutScript := []byte{
+		0x76, 0xa9, 0x14, 0x01, 0x02, 0x03, 0x04, 0x05,
+		0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d,
+		0x0e, 0x0f, 0x10, 0x11, 0x12, 0x13, 0x14, 0x88,
+		0xac,
+	}
+	ip := net.ParseIP("1.2.3.4").To16()
+
+	tests := []struct {
+		name    string
+		txType  TxType
+		payload interface {
+			Serialize(io.Writer) error
+			SerializeSize() int
+		}
+		size   int
+		decode func(*MsgTx) (interface{}, error)
+	}{
+		{
+			name:   "ProRegTx regular",
+			txType: TxTypeProRegTx,
+			payload: &ProRegTxPayload{
+				Version:            ProTxVersionLegacyBLS,
+				CollateralOutpoint: OutPoint{Index: 1},
+				IP:                 ip,
+				Port:               9999,
+				KeyIDOwner:         [KeyIDSize]byte{0x01},
+				PubKeyOperator:     [BLSPubKeySize]byte{0x02},
+				KeyIDVoting:        [KeyIDSize]byte{0x03},
+				OperatorReward:     500,
+				ScriptPayout:       payoutScript,
+				InputsHash:         chainhash.Hash{0x04},
+				Sig:                []byte{0x05, 0x06},
+			},
+			size: 182 + 1 + 25 + 1 + 2,
+			decode: func(tx *MsgTx) (interface{}, error) {
+				return tx.ProRegTxPayload()
+			},
+		},
+		{
+			name:   "ProRegTx evo",
+			txType: TxTypeProRegTx,
+			payload: &ProRegTxPayload{
+				Version:        ProTxVersionBasicBLS,
+				MasternodeType: MasternodeTypeEvo,
+				IP:             ip,
+				Port:           9999,
+				ScriptPayout:   payoutScript,
+				Platform: PlatformInfo{
+					NodeID:   [KeyIDSize]byte{0x07},
+					P2PPort:  26656,
+					HTTPPort: 443,
+				},
+				Sig: []byte{},
+			},
+			size: 182 + 1 + 25 + 1 + 24,
+			decode: func(tx *MsgTx) (interface{}, error) {
+				return tx.ProRegTxPayload()
+			},
+		},
+		{
+			name:   "ProUpServTx legacy",
+			txType: TxTypeProUpServTx,
+			payload: &ProUpServTxPayload{
+				Version:              ProTxVersionLegacyBLS,
+				ProTxHash:            chainhash.Hash{0x01},
+				IP:                   ip,
+				Port:                 9999,
+				ScriptOperatorPayout: []byte{},
+				Sig:                  [BLSSigSize]byte{0x02},
+			},
+			size: 180 + 1,
+			decode: func(tx *MsgTx) (interface{}, error) {
+				return tx.ProUpServTxPayload()
+			},
+		},
+		{
+			name:   "ProUpServTx evo",
+			txType: TxTypeProUpServTx,
+			payload: &ProUpServTxPayload{
+				Version:              ProTxVersionBasicBLS,
+				MasternodeType:       MasternodeTypeEvo,
+				ProTxHash:            chainhash.Hash{0x01},
+				IP:                   ip,
+				Port:                 9999,
+				ScriptOperatorPayout: payoutScript,
+				Platform:             PlatformInfo{P2PPort: 1},
+			},
+			size: 180 + 2 + 1 + 25 + 24,
+			decode: func(tx *MsgTx) (interface{}, error) {
+				return tx.ProUpServTxPayload()
+			},
+		},
+		{
+			name:   "ProUpRegTx",
+			txType: TxTypeProUpRegTx,
+			payload: &ProUpRegTxPayload{
+				Version:        ProTxVersionBasicBLS,
+				ProTxHash:      chainhash.Hash{0x01},
+				PubKeyOperator: [BLSPubKeySize]byte{0x02},
+				KeyIDVoting:    [KeyIDSize]byte{0x03},
+				ScriptPayout:   payoutScript,
+				Sig:            []byte{0x04},
+			},
+			size: 136 + 1 + 25 + 1 + 1,
+			decode: func(tx *MsgTx) (interface{}, error) {
+				return tx.ProUpRegTxPayload()
+			},
+		},
+		{
+			name:   "ProUpRevTx",
+			txType: TxTypeProUpRevTx,
+			payload: &ProUpRevTxPayload{
+				Version:   ProTxVersionLegacyBLS,
+				ProTxHash: chainhash.Hash{0x01},
+				Reason:    1,
+				Sig:       [BLSSigSize]byte{0x02},
+			},
+			size: 164,
+			decode: func(tx *MsgTx) (interface{}, error) {
+				return tx.ProUpRevTxPayload()
+			},
+		},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		var buf bytes.Buffer
+		if err := test.payload.Serialize(&buf); err != nil {
+			t.Errorf("Serialize #%d (%s): unexpected error %v", i,
+				test.name, err)
+			continue
+		}
+		if buf.Len() != test.payload.SerializeSize() ||
+			buf.Len() != test.size {
+
+			t.Errorf("Serialize #%d (%s): got size %d (%d), want %d",
+				i, test.name, buf.Len(),
+				test.payload.SerializeSize(), test.size)
+			continue
+		}
+
+		tx := MsgTx{
+			Version: SpecialTxVersion,
+			Type:    test.txType,
+			TxIn: []*TxIn{{
+				PreviousOutPoint: OutPoint{Index: 1},
+				Sequence:         MaxTxInSequenceNum,
+			}},
+			Payload: buf.Bytes(),
+		}
+		var txBuf bytes.Buffer
+		if err := tx.Serialize(&txBuf); err != nil {
+			t.Errorf("Serialize tx #%d (%s): unexpected error %v",
+				i, test.name, err)
+			continue
+		}
+		var decoded MsgTx
+		err := decoded.Deserialize(bytes.NewReader(txBuf.Bytes()))
+		if err != nil {
+			t.Errorf("Deserialize tx #%d (%s): unexpected error %v",
+				i, test.name, err)
+			continue
+		}
+		got, err := test.decode(&decoded)
+		if err != nil {
+			t.Errorf("decode #%d (%s): unexpected error %v", i,
+				test.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.payload) {
+			t.Errorf("decode #%d (%s)\n got: %s want: %s", i,
+				test.name, spew.Sdump(got),
+				spew.Sdump(test.payload))
+			continue
+		}
+
+		// Trailing data, the wrong transaction type and unknown
+		// payload versions must be rejected.
+		trailing := decoded
+		trailing.Payload = append(buf.Bytes(), 0x00)
+		if _, err := test.decode(&trailing); err == nil {
+			t.Errorf("decode #%d (%s): did not reject trailing "+
+				"data", i, test.name)
+		}
+		wrongType := decoded
+		wrongType.Type = TxTypeAssetLock
+		if _, err := test.decode(&wrongType); err == nil {
+			t.Errorf("decode #%d (%s): did not reject asset lock",
+				i, test.name)
+		}
+		badVersion := decoded
+		badVersion.Payload = append([]byte{0x03, 0x00},
+			buf.Bytes()[2:]...)
+		if _, err := test.decode(&badVersion); err == nil {
+			t.Errorf("decode #%d (%s): did not reject version 3", i,
+				test.name)
+		}
+	}
+}
@@ -0,0 +1,208 @@
+// Copyright (c) 2013-2016 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"bytes"
+	"fmt"
+	"unicode/utf8"
+)
+
+// v2MessageIDs maps the short message IDs of the v2 P2P transport (BIP0324)
+// to the commands they stand for.  ID 0 means the command follows in full.
+//
+// IDs 1 through 127 are those of BIP0324, and Dash Core allocates the IDs
+// from 128 on to the Dash messages in the order of V2_DASH_IDS in its
+// net.cpp.  Messages which are not supported by this package are listed by
+// their commands so their IDs are still recognized.
+var v2MessageIDs = [...]string{
+	1:  CmdAddr,
+	2:  CmdBlock,
+	3:  CmdBlockTxn,
+	4:  CmdCmpctBlock,
+	5:  CmdFeeFilter,
+	6:  CmdFilterAdd,
+	7:  CmdFilterClear,
+	8:  CmdFilterLoad,
+	9:  CmdGetBlocks,
+	10: CmdGetBlockTxn,
+	11: CmdGetData,
+	12: CmdGetHeaders,
+	13: CmdHeaders,
+	14: CmdInv,
+	15: CmdMemPool,
+	16: CmdMerkleBlock,
+	17: CmdNotFound,
+	18: CmdPing,
+	19: CmdPong,
+	20: CmdSendCmpct,
+	21: CmdTx,
+	22: CmdGetCFilters,
+	23: CmdCFilter,
+	24: CmdGetCFHeaders,
+	25: CmdCFHeaders,
+	26: CmdGetCFCheckpt,
+	27: CmdCFCheckpt,
+	28: CmdAddrV2,
+
+	128: "spork",
+	129: "getsporks",
+	130: CmdSendDSQueue,
+	131: "dsa",
+	132: "dsi",
+	133: "dsf",
+	134: "dss",
+	135: "dsc",
+	136: "dssu",
+	137: CmdDSTx,
+	138: CmdDSQueue,
+	139: "ssc",
+	140: "govsync",
+	141: "govobj",
+	142: "govobjvote",
+	143: "getmnlistd",
+	144: "mnlistdiff",
+	145: "qsendrecsigs",
+	146: "qfcommit",
+	147: "qcontrib",
+	148: "qcomplaint",
+	149: "qjustify",
+	150: "qpcommit",
+	151: "qwatch",
+	152: "qsigsesann",
+	153: "qsigsinv",
+	154: "qgetsigs",
+	155: "qbsigs",
+	156: "qsigrec",
+	157: "qsigshare",
+	158: "qgetdata",
+	159: "qdata",
+	160: "clsig",
+	161: "isdlock",
+	162: "mnauth",
+	163: "getheaders2",
+	164: "sendheaders2",
+	165: "headers2",
+	166: "getqrinfo",
+	167: "qrinfo",
+}
+
+// v2ShortIDs maps commands to their short message IDs.
+var v2ShortIDs = func() map[string]byte {
+	ids := make(map[string]byte, len(v2MessageIDs))
+	for id, cmd := range v2MessageIDs {
+		if cmd != "" {
+			ids[cmd] = byte(id)
+		}
+	}
+	return ids
+}()
+
+// EncodeV2Message returns the contents of a v2 P2P transport packet (BIP0324)
+// which carries the passed message.  The contents start with the short ID of
+// the message command, or a zero byte followed by the zero padded command
+// when it has none, followed by the message payload.
+func EncodeV2Message(msg Message, pver uint32, enc MessageEncoding) ([]byte, error) {
+	cmd := msg.Command()
+	if len(cmd) > CommandSize {
+		str := fmt.Sprintf("command [%s] is too long [max %v]",
+			cmd, CommandSize)
+		return nil, messageError("EncodeV2Message", str)
+	}
+
+	var bw bytes.Buffer
+	if id, ok := v2ShortIDs[cmd]; ok {
+		bw.WriteByte(id)
+	} else {
+		var command [CommandSize]byte
+		copy(command[:], cmd)
+		bw.WriteByte(0)
+		bw.Write(command[:])
+	}
+	headerLen := bw.Len()
+
+	if err := msg.BtcEncode(&bw, pver, enc); err != nil {
+		return nil, err
+	}
+
+	// Enforce maximum overall message payload and the maximum payload
+	// based on the message type.
+	lenp := bw.Len() - headerLen
+	if lenp > MaxMessagePayload {
+		str := fmt.Sprintf("message payload is too large - encoded "+
+			"%d bytes, but maximum message payload is %d bytes",
+			lenp, MaxMessagePayload)
+		return nil, messageError("EncodeV2Message", str)
+	}
+	mpl := msg.MaxPayloadLength(pver)
+	if uint32(lenp) > mpl {
+		str := fmt.Sprintf("message payload is too large - encoded "+
+			"%d bytes, but maximum message payload size for "+
+			"messages of type [%s] is %d.", lenp, cmd, mpl)
+		return nil, messageError("EncodeV2Message", str)
+	}
+
+	return bw.Bytes(), nil
+}
+
+// DecodeV2Message parses the message carried by the passed contents of a v2
+// P2P transport packet (BIP0324).  It returns the parsed message and the raw
+// payload bytes.
+func DecodeV2Message(contents []byte, pver uint32, enc MessageEncoding) (Message, []byte, error) {
+	if len(contents) == 0 {
+		return nil, nil, messageError("DecodeV2Message", "empty message")
+	}
+
+	// Determine the command from the short ID or the full command.
+	var command string
+	payload := contents[1:]
+	if id := contents[0]; id != 0 {
+		if int(id) >= len(v2MessageIDs) || v2MessageIDs[id] == "" {
+			str := fmt.Sprintf("unknown short message ID %d", id)
+			return nil, nil, messageError("DecodeV2Message", str)
+		}
+		command = v2MessageIDs[id]
+	} else {
+		if len(payload) < CommandSize {
+			str := fmt.Sprintf("message with a long ID of %d bytes "+
+				"is too short", len(contents))
+			return nil, nil, messageError("DecodeV2Message", str)
+		}
+		command = string(bytes.TrimRight(payload[:CommandSize], "\x00"))
+		payload = payload[CommandSize:]
+		if !utf8.ValidString(command) {
+			str := fmt.Sprintf("invalid command %v", []byte(command))
+			return nil, nil, messageError("DecodeV2Message", str)
+		}
+	}
+
+	if len(payload) > MaxMessagePayload {
+		str := fmt.Sprintf("message payload is too large - %d bytes, "+
+			"but max message payload is %d bytes.", len(payload),
+			MaxMessagePayload)
+		return nil, nil, messageError("DecodeV2Message", str)
+	}
+
+	msg, err := makeEmptyMessage(command)
+	if err != nil {
+		return nil, nil, messageError("DecodeV2Message", err.Error())
+	}
+
+	mpl := msg.MaxPayloadLength(pver)
+	if uint32(len(payload)) > mpl {
+		str := fmt.Sprintf("payload exceeds max length - %v bytes, "+
+			"but max payload size for messages of type [%v] is %v.",
+			len(payload), command, mpl)
+		return nil, nil, messageError("DecodeV2Message", str)
+	}
+
+	// NOTE: This must be a *bytes.Buffer since the MsgVersion BtcDecode
+	// function requires it.
+	if err := msg.BtcDecode(bytes.NewBuffer(payload), pver, enc); err != nil {
+		return nil, nil, err
+	}
+
+	return msg, payload, nil
+}
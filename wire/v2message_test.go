@@ -0,0 +1,112 @@
+// Copyright (c) 2013-2016 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/davecgh/go-spew/spew"
+)
+
+// TestV2Message tests encoding and decoding the contents of v2 transport
+// packets.
+func TestV2Message(t *testing.T) {
+	pver := ProtocolVersion
+
+	tests := []struct {
+		in  Message
+		buf []byte
+	}{
+		// Short message ID.
+		{
+			NewMsgPing(0x0102030405060708),
+			[]byte{
+				0x12,                                           // ping
+				0x08, 0x07, 0x06, 0x05, 0x04, 0x03, 0x02, 0x01, // nonce
+			},
+		},
+		{
+			NewMsgFilterClear(),
+			[]byte{0x07}, // filterclear
+		},
+
+		// Dash short message ID.
+		{
+			NewMsgSendDSQueue(true),
+			[]byte{
+				0x82, // senddsq
+				0x01, // send
+			},
+		},
+
+		// Message without a short ID.
+		{
+			NewMsgVerAck(),
+			[]byte{
+				0x00,
+				'v', 'e', 'r', 'a', 'c', 'k', 0, 0, 0, 0, 0, 0,
+			},
+		},
+		{
+			NewMsgSendAddrV2(),
+			[]byte{
+				0x00,
+				's', 'e', 'n', 'd', 'a', 'd', 'd', 'r', 'v', '2', 0, 0,
+			},
+		},
+	}
+
+	for i, test := range tests {
+		buf, err := EncodeV2Message(test.in, pver, BaseEncoding)
+		if err != nil {
+			t.Errorf("EncodeV2Message #%d error %v", i, err)
+			continue
+		}
+		if !bytes.Equal(buf, test.buf) {
+			t.Errorf("EncodeV2Message #%d\n got: %s want: %s", i,
+				spew.Sdump(buf), spew.Sdump(test.buf))
+			continue
+		}
+
+		msg, _, err := DecodeV2Message(test.buf, pver, BaseEncoding)
+		if err != nil {
+			t.Errorf("DecodeV2Message #%d error %v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(msg, test.in) {
+			t.Errorf("DecodeV2Message #%d\n got: %s want: %s", i,
+				spew.Sdump(msg), spew.Sdump(test.in))
+		}
+	}
+}
+
+// TestV2MessageErrors performs negative tests against decoding the contents
+// of v2 transport packets.
+func TestV2MessageErrors(t *testing.T) {
+	pver := ProtocolVersion
+
+	tests := []struct {
+		name string
+		buf  []byte
+	}{
+		{"empty", []byte{}},
+		{"unknown short ID", []byte{0x1d}},
+		{"unknown Dash short ID", []byte{0xa8}},
+		{"unsupported Dash short ID", []byte{0xa0}},
+		{"truncated command", []byte{0x00, 'v', 'e', 'r'}},
+		{"unknown command", append([]byte{0x00}, []byte("unknowncmd\x00\x00")...)},
+		{"payload too large", append([]byte{0x07}, 0x00)},
+	}
+
+	for _, test := range tests {
+		_, _, err := DecodeV2Message(test.buf, pver, BaseEncoding)
+		if _, ok := err.(*MessageError); !ok {
+			t.Errorf("DecodeV2Message %s: wrong error - got %T (%v), "+
+				"want *MessageError", test.name, err, err)
+		}
+	}
+}